@@ -26,6 +26,33 @@ const (
 	ClusterFinalizer = "cloudstackcluster.infrastructure.cluster.x-k8s.io"
 )
 
+// Control plane endpoint providers.
+const (
+	// EndpointProviderIsolatedNetwork load balances the control plane behind a public IP of the isolated network.
+	EndpointProviderIsolatedNetwork = "IsolatedNetwork"
+	// EndpointProviderNetworkLoadBalancer load balances the control plane behind a public IP associated with the
+	// failure domain's network, for networks whose offering provides load balancing (e.g. elastic or NetScaler LB).
+	EndpointProviderNetworkLoadBalancer = "NetworkLoadBalancer"
+	// EndpointProviderExternal leaves the endpoint to something outside of CAPC, such as kube-vip or a
+	// user-supplied controller, which sets spec.controlPlaneEndpoint.
+	EndpointProviderExternal = "External"
+)
+
+// FailureDomainPlacementStrategy selects how worker machines are spread over a cluster's failure domains.
+type FailureDomainPlacementStrategy string
+
+const (
+	// PlacementStrategyRoundRobin places each machine in the failure domain with the fewest of the cluster's machines.
+	PlacementStrategyRoundRobin FailureDomainPlacementStrategy = "RoundRobin"
+	// PlacementStrategyWeighted spreads machines over the failure domains in proportion to their configured weights.
+	PlacementStrategyWeighted FailureDomainPlacementStrategy = "Weighted"
+	// PlacementStrategyCapacity spreads machines over the failure domains in proportion to the CPU or memory,
+	// whichever is scarcer, left free in their zones.
+	PlacementStrategyCapacity FailureDomainPlacementStrategy = "Capacity"
+	// PlacementStrategyRandom places each machine in a failure domain picked at random.
+	PlacementStrategyRandom FailureDomainPlacementStrategy = "Random"
+)
+
 var K8sClient client.Client
 
 // CloudStackClusterSpec defines the desired state of CloudStackCluster.
@@ -34,6 +61,159 @@ type CloudStackClusterSpec struct {
 
 	// The kubernetes control plane endpoint.
 	ControlPlaneEndpoint clusterv1.APIEndpoint `json:"controlPlaneEndpoint"`
+
+	// ControlPlaneEndpointProvider selects what provides the control plane endpoint. Defaults to IsolatedNetwork on
+	// isolated networks and External on other networks.
+	// +kubebuilder:validation:Enum=IsolatedNetwork;NetworkLoadBalancer;External
+	// +optional
+	// +k8s:conversion-gen=false
+	ControlPlaneEndpointProvider string `json:"controlPlaneEndpointProvider,omitempty"`
+
+	// LoadBalancer configures the load balancer rules CAPC manages for the control plane endpoint. When unset, the API
+	// server is balanced round robin and reachable from anywhere.
+	// +optional
+	// +k8s:conversion-gen=false
+	LoadBalancer *LoadBalancerSpec `json:"loadBalancer,omitempty"`
+
+	// AdditionalTags are put on the CloudStack resources CAPC creates for the cluster, alongside the tags CAPC uses to
+	// track them. CloudStackMachines can override them for their VMs and volumes.
+	// +optional
+	// +k8s:conversion-gen=false
+	AdditionalTags map[string]string `json:"additionalTags,omitempty"`
+
+	// FailureDomainPlacement configures how worker machines that don't name a failure domain are spread over the
+	// failure domains. Control plane machines are spread by their control plane provider.
+	// +optional
+	// +k8s:conversion-gen=false
+	FailureDomainPlacement *FailureDomainPlacement `json:"failureDomainPlacement,omitempty"`
+}
+
+// FailureDomainPlacement configures how worker machines are spread over failure domains.
+type FailureDomainPlacement struct {
+	// Strategy used to pick a machine's failure domain. Defaults to RoundRobin.
+	// +kubebuilder:validation:Enum=RoundRobin;Weighted;Capacity;Random
+	// +optional
+	Strategy FailureDomainPlacementStrategy `json:"strategy,omitempty"`
+
+	// Weights of the failure domains by name, for the Weighted strategy. Failure domains without a weight weigh 1,
+	// and those weighing 0 aren't placed in.
+	// +optional
+	Weights map[string]int32 `json:"weights,omitempty"`
+}
+
+// LoadBalancerSpec configures the load balancer rules of the control plane endpoint.
+type LoadBalancerSpec struct {
+	// Algorithm used to spread connections over a rule's machines. Defaults to roundrobin.
+	// +kubebuilder:validation:Enum=roundrobin;leastconn;source
+	// +optional
+	Algorithm string `json:"algorithm,omitempty"`
+
+	// Stickiness keeps a client's connections on the same machine.
+	// +optional
+	Stickiness *LoadBalancerStickinessPolicy `json:"stickiness,omitempty"`
+
+	// HealthCheck takes machines failing the check out of rotation. It applies to the API server rule only.
+	// +optional
+	HealthCheck *LoadBalancerHealthCheckPolicy `json:"healthCheck,omitempty"`
+
+	// AdditionalPorts are forwarded from the control plane endpoint to the same machines as the API server.
+	// +optional
+	AdditionalPorts []LoadBalancerPortMapping `json:"additionalPorts,omitempty"`
+
+	// AllowedCIDRs restricts which source CIDRs may reach the control plane endpoint. Defaults to 0.0.0.0/0.
+	// +optional
+	AllowedCIDRs []string `json:"allowedCIDRs,omitempty"`
+}
+
+// LoadBalancerStickinessPolicy is a CloudStack load balancer stickiness policy.
+type LoadBalancerStickinessPolicy struct {
+	// Method is the stickiness method.
+	// +kubebuilder:validation:Enum=LbCookie;AppCookie;SourceBased
+	Method string `json:"method"`
+
+	// Params of the stickiness method, such as cookie-name or tablesize.
+	// +optional
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// LoadBalancerHealthCheckPolicy is a CloudStack load balancer health check policy. Unset fields take CloudStack's
+// defaults.
+type LoadBalancerHealthCheckPolicy struct {
+	// PingPath is the HTTP path checked on each machine.
+	// +optional
+	PingPath string `json:"pingPath,omitempty"`
+
+	// IntervalSeconds between two checks of a machine.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	IntervalSeconds int `json:"intervalSeconds,omitempty"`
+
+	// TimeoutSeconds to wait for a check's response.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+
+	// HealthyThreshold is the number of consecutive successful checks that put a machine back in rotation.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	HealthyThreshold int `json:"healthyThreshold,omitempty"`
+
+	// UnhealthyThreshold is the number of consecutive failed checks that take a machine out of rotation.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	UnhealthyThreshold int `json:"unhealthyThreshold,omitempty"`
+}
+
+// LoadBalancerPortMapping forwards a public port of a load balancer's public IP address to a port on the machines.
+type LoadBalancerPortMapping struct {
+	// Name of the mapping, used to name its load balancer rule.
+	Name string `json:"name"`
+
+	// PublicPort on the public IP address.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	PublicPort int32 `json:"publicPort"`
+
+	// PrivatePort on the machines. Defaults to the public port.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	PrivatePort int32 `json:"privatePort,omitempty"`
+
+	// AllowedCIDRs overrides the load balancer's allowedCIDRs for this port.
+	// +optional
+	AllowedCIDRs []string `json:"allowedCIDRs,omitempty"`
+}
+
+// AlgorithmOrDefault returns the load balancing algorithm, defaulting to roundrobin.
+func (lb *LoadBalancerSpec) AlgorithmOrDefault() string {
+	if lb == nil || lb.Algorithm == "" {
+		return "roundrobin"
+	}
+	return lb.Algorithm
+}
+
+// AllowedCIDRsFor returns the source CIDRs allowed to reach a port mapping, or the API server when mapping is nil.
+func (lb *LoadBalancerSpec) AllowedCIDRsFor(mapping *LoadBalancerPortMapping) []string {
+	if mapping != nil && len(mapping.AllowedCIDRs) > 0 {
+		return mapping.AllowedCIDRs
+	} else if lb != nil && len(lb.AllowedCIDRs) > 0 {
+		return lb.AllowedCIDRs
+	}
+	return []string{"0.0.0.0/0"}
+}
+
+// ControlPlaneEndpointProviderFor returns the control plane endpoint provider used in a failure domain.
+func (r *CloudStackCluster) ControlPlaneEndpointProviderFor(fd *CloudStackFailureDomainSpec) string {
+	if r.Spec.ControlPlaneEndpointProvider != "" {
+		return r.Spec.ControlPlaneEndpointProvider
+	}
+	// A network that hasn't been resolved is one CAPC will create, as an isolated network.
+	if net := fd.Zone.Network; net.Type == NetworkTypeIsolated || net.Type == NetworkTypeVPC ||
+		(net.Type == "" && net.ID == "") {
+		return EndpointProviderIsolatedNetwork
+	}
+	return EndpointProviderExternal
 }
 
 // The status of the CloudStackCluster object.
@@ -46,6 +226,21 @@ type CloudStackClusterStatus struct {
 
 	// Reflects the readiness of the CS cluster.
 	Ready bool `json:"ready"`
+
+	// Conditions defines current service state of the CloudStackCluster.
+	// +optional
+	// +k8s:conversion-gen=false
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+}
+
+// GetConditions returns the observations of the operational state of the CloudStackCluster resource.
+func (r *CloudStackCluster) GetConditions() clusterv1.Conditions {
+	return r.Status.Conditions
+}
+
+// SetConditions sets the underlying service state of the CloudStackCluster to the predescribed clusterv1.Conditions.
+func (r *CloudStackCluster) SetConditions(conditions clusterv1.Conditions) {
+	r.Status.Conditions = conditions
 }
 
 //+kubebuilder:object:root=true
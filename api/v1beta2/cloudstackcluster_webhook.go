@@ -18,6 +18,11 @@ package v1beta2
 
 import (
 	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -62,7 +67,7 @@ func (r *CloudStackCluster) ValidateCreate() error {
 	if len(r.Spec.FailureDomains) == 0 {
 		errorList = append(errorList, field.Required(field.NewPath("spec", "FailureDomains"), "FailureDomains"))
 	} else {
-		for _, fdSpec := range r.Spec.FailureDomains { // Require failureDomain names meet k8s qualified name spec.
+		for i, fdSpec := range r.Spec.FailureDomains { // Require failureDomain names meet k8s qualified name spec.
 			for _, errMsg := range validation.IsDNS1123Subdomain(fdSpec.Name) {
 				errorList = append(errorList, field.Invalid(
 					field.NewPath("spec", "failureDomains", "name"), fdSpec.Name, errMsg))
@@ -72,6 +77,11 @@ func (r *CloudStackCluster) ValidateCreate() error {
 					field.NewPath("spec", "failureDomains", "Zone", "Network"),
 					"each Zone requires a Network specification"))
 			}
+			netPath := field.NewPath("spec", "failureDomains").Index(i).Child("zone", "network")
+			errorList = append(errorList, validateIsolatedNetworkSettings(netPath, fdSpec.Zone.Network)...)
+			errorList = append(errorList, validateVPC(netPath, fdSpec.Zone.Network)...)
+			errorList = append(errorList, validateZoneScope(
+				field.NewPath("spec", "failureDomains").Index(i).Child("zone"), fdSpec.Zone)...)
 			if fdSpec.ACSEndpoint.Name == "" || fdSpec.ACSEndpoint.Namespace == "" {
 				errorList = append(errorList, field.Required(
 					field.NewPath("spec", "failureDomains", "ACSEndpoint"),
@@ -79,10 +89,242 @@ func (r *CloudStackCluster) ValidateCreate() error {
 			}
 		}
 	}
+	errorList = append(errorList, validateLoadBalancer(r.Spec.LoadBalancer, r.Spec.ControlPlaneEndpoint.Port)...)
+	errorList = append(errorList, validateAdditionalTags(field.NewPath("spec", "additionalTags"), r.Spec.AdditionalTags)...)
+	errorList = append(errorList, validateFailureDomainPlacement(r.Spec.FailureDomainPlacement, r.Spec.FailureDomains)...)
+	errorList = append(errorList, validateControlPlaneFailureDomains(r.Spec.FailureDomains)...)
 
 	return webhookutil.AggregateObjErrors(r.GroupVersionKind().GroupKind(), r.Name, errorList)
 }
 
+// validateZoneScope ensures the pod and cluster a zone's placement is narrowed to can be identified.
+func validateZoneScope(path *field.Path, zone CloudStackZoneSpec) (errorList field.ErrorList) {
+	if zone.Pod != nil && zone.Pod.ID == "" && zone.Pod.Name == "" {
+		errorList = append(errorList, field.Required(path.Child("pod"), "pod ID or name"))
+	}
+	if zone.Cluster != nil && zone.Cluster.ID == "" && zone.Cluster.Name == "" {
+		errorList = append(errorList, field.Required(path.Child("cluster"), "cluster ID or name"))
+	}
+	return errorList
+}
+
+// validateLoadBalancer checks the load balancer's allowlists and that its port mappings don't clash with each other
+// or the API server.
+// validateAdditionalTags ensures additional tags have keys, and don't clash with the tags CAPC tracks resources by.
+func validateAdditionalTags(path *field.Path, tags map[string]string) (errorList field.ErrorList) {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if key == "" {
+			errorList = append(errorList, field.Invalid(path, key, "tag keys must not be empty"))
+		} else if strings.HasPrefix(key, "CAPC_") || key == "created_by_CAPC" {
+			errorList = append(errorList, field.Forbidden(path.Key(key), "tag keys starting with CAPC_ and created_by_CAPC are reserved"))
+		}
+	}
+	return errorList
+}
+
+// validateFailureDomainPlacement ensures placement weights are for listed failure domains, aren't negative, and leave
+// at least one failure domain to place machines in.
+func validateFailureDomainPlacement(placement *FailureDomainPlacement, fds []CloudStackFailureDomainSpec) (errorList field.ErrorList) {
+	if placement == nil || len(placement.Weights) == 0 {
+		return nil
+	}
+	path := field.NewPath("spec", "failureDomainPlacement", "weights")
+	names := make([]string, 0, len(placement.Weights))
+	for name := range placement.Weights {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	listed := map[string]bool{}
+	for _, fd := range fds {
+		listed[fd.Name] = true
+	}
+	for _, name := range names {
+		if !listed[name] {
+			errorList = append(errorList, field.NotFound(path.Key(name), name))
+		} else if placement.Weights[name] < 0 {
+			errorList = append(errorList, field.Invalid(path.Key(name), placement.Weights[name], "must not be negative"))
+		}
+	}
+	for _, fd := range fds {
+		if weight, found := placement.Weights[fd.Name]; !found || weight > 0 {
+			return errorList
+		}
+	}
+	return append(errorList, field.Invalid(path, placement.Weights, "at least one failure domain must weigh more than 0"))
+}
+
+// validateControlPlaneFailureDomains ensures control plane machines can be placed in at least one failure domain.
+func validateControlPlaneFailureDomains(fds []CloudStackFailureDomainSpec) field.ErrorList {
+	for i := range fds {
+		if fds[i].ControlPlaneEligible() {
+			return nil
+		}
+	}
+	if len(fds) == 0 {
+		return nil
+	}
+	return field.ErrorList{field.Invalid(field.NewPath("spec", "failureDomains"), len(fds),
+		"at least one failure domain must be eligible for the control plane")}
+}
+
+func validateLoadBalancer(lb *LoadBalancerSpec, apiPort int32) (errorList field.ErrorList) {
+	if lb == nil {
+		return nil
+	}
+	lbPath := field.NewPath("spec", "loadBalancer")
+	errorList = append(errorList, validateCIDRs(lbPath.Child("allowedCIDRs"), lb.AllowedCIDRs)...)
+
+	if apiPort == 0 {
+		apiPort = 6443
+	}
+	return append(errorList, validatePortMappings(lbPath.Child("additionalPorts"), lb.AdditionalPorts, apiPort)...)
+}
+
+// validatePortMappings checks that port mappings are named uniquely, don't clash with each other or the reserved
+// ports, and have valid allowlists.
+func validatePortMappings(path *field.Path, mappings []LoadBalancerPortMapping, reservedPorts ...int32) (errorList field.ErrorList) {
+	names := map[string]bool{}
+	ports := map[int32]bool{}
+	for _, port := range reservedPorts {
+		ports[port] = true
+	}
+	for i, mapping := range mappings {
+		mappingPath := path.Index(i)
+		if mapping.Name == "" {
+			errorList = append(errorList, field.Required(mappingPath.Child("name"), "name"))
+		} else if names[mapping.Name] {
+			errorList = append(errorList, field.Duplicate(mappingPath.Child("name"), mapping.Name))
+		}
+		if ports[mapping.PublicPort] {
+			errorList = append(errorList, field.Duplicate(mappingPath.Child("publicPort"), mapping.PublicPort))
+		}
+		names[mapping.Name] = true
+		ports[mapping.PublicPort] = true
+		errorList = append(errorList, validateCIDRs(mappingPath.Child("allowedCIDRs"), mapping.AllowedCIDRs)...)
+	}
+	return errorList
+}
+
+// validateCIDRs checks that each entry of an allowlist is a CIDR.
+func validateCIDRs(path *field.Path, cidrs []string) (errorList field.ErrorList) {
+	for i, cidr := range cidrs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			errorList = append(errorList, field.Invalid(path.Index(i), cidr, "must be a CIDR"))
+		}
+	}
+	return errorList
+}
+
+// validateIsolatedNetworkSettings checks the settings CAPC creates an isolated network with, which don't apply to
+// shared networks and VPC tiers.
+func validateIsolatedNetworkSettings(path *field.Path, network Network) (errorList field.ErrorList) {
+	if network.Type == NetworkTypeShared || network.Type == NetworkTypeVPC {
+		settings := []struct {
+			name string
+			set  bool
+		}{{"offering", network.Offering != nil}, {"cidr", network.CIDR != ""}, {"gateway", network.Gateway != ""},
+			{"domain", network.Domain != ""}, {"vlan", network.VLAN != ""}, {"ipv6", network.IPv6 != nil}}
+		for _, setting := range settings {
+			if setting.set {
+				errorList = append(errorList, field.Forbidden(path.Child(setting.name),
+					fmt.Sprintf("not allowed with the %s network type", network.Type)))
+			}
+		}
+		return errorList
+	}
+
+	if network.Offering != nil && network.Offering.ID == "" && network.Offering.Name == "" {
+		errorList = append(errorList, field.Required(path.Child("offering"), "an offering requires an id or name"))
+	}
+	var cidrNet *net.IPNet
+	if network.CIDR != "" {
+		var err error
+		if _, cidrNet, err = net.ParseCIDR(network.CIDR); err != nil || cidrNet.IP.To4() == nil {
+			errorList = append(errorList, field.Invalid(path.Child("cidr"), network.CIDR, "must be an IPv4 CIDR"))
+		}
+	}
+	if network.Gateway != "" {
+		if gateway := net.ParseIP(network.Gateway); gateway == nil {
+			errorList = append(errorList, field.Invalid(path.Child("gateway"), network.Gateway, "must be an IP address"))
+		} else if network.CIDR == "" {
+			errorList = append(errorList, field.Required(path.Child("cidr"), "a gateway requires a CIDR"))
+		} else if cidrNet != nil && !cidrNet.Contains(gateway) {
+			errorList = append(errorList, field.Invalid(path.Child("gateway"), network.Gateway, "must be within the CIDR"))
+		}
+	}
+	if network.Domain != "" {
+		for _, errMsg := range validation.IsDNS1123Subdomain(network.Domain) {
+			errorList = append(errorList, field.Invalid(path.Child("domain"), network.Domain, errMsg))
+		}
+	}
+	if network.VLAN != "" {
+		vlan, err := strconv.Atoi(strings.TrimPrefix(network.VLAN, "vlan://"))
+		if err != nil || vlan < 1 || vlan > 4094 {
+			errorList = append(errorList, field.Invalid(path.Child("vlan"), network.VLAN,
+				"must be a VLAN ID between 1 and 4094, optionally as a vlan:// URI"))
+		}
+	}
+	if network.IPv6 != nil {
+		if network.Offering == nil {
+			errorList = append(errorList, field.Required(path.Child("offering"), "IPv6 requires a dual-stack offering"))
+		}
+		for i, cidr := range network.IPv6.AllowedCIDRs {
+			if ip, _, err := net.ParseCIDR(cidr); err != nil || ip.To4() != nil {
+				errorList = append(errorList, field.Invalid(
+					path.Child("ipv6", "allowedCIDRs").Index(i), cidr, "must be an IPv6 CIDR"))
+			}
+		}
+	}
+	return errorList
+}
+
+// validateVPC checks that a VPC is specified exactly for the VPC network type, and that its CIDRs and network ACL
+// rules are valid.
+func validateVPC(path *field.Path, network Network) (errorList field.ErrorList) {
+	vpc := network.VPC
+	if network.Type != NetworkTypeVPC {
+		if vpc != nil {
+			errorList = append(errorList, field.Forbidden(path.Child("vpc"), "only allowed with the VPC network type"))
+		}
+		return errorList
+	}
+	vpcPath := path.Child("vpc")
+	if vpc == nil {
+		return append(errorList, field.Required(vpcPath, "the VPC network type requires a VPC"))
+	}
+	if vpc.ID == "" && vpc.Name == "" {
+		errorList = append(errorList, field.Required(vpcPath, "a VPC requires an id or name"))
+	}
+	var vpcNet *net.IPNet
+	if vpc.CIDR != "" {
+		var err error
+		if _, vpcNet, err = net.ParseCIDR(vpc.CIDR); err != nil {
+			errorList = append(errorList, field.Invalid(vpcPath.Child("cidr"), vpc.CIDR, "must be a CIDR"))
+		}
+	}
+	if tierIP, _, err := net.ParseCIDR(vpc.TierCIDR); err != nil {
+		errorList = append(errorList, field.Invalid(vpcPath.Child("tierCIDR"), vpc.TierCIDR, "must be a CIDR"))
+	} else if vpcNet != nil && !vpcNet.Contains(tierIP) {
+		errorList = append(errorList, field.Invalid(vpcPath.Child("tierCIDR"), vpc.TierCIDR, "must be within the VPC's CIDR"))
+	}
+
+	for i, rule := range vpc.ACLRules {
+		rulePath := vpcPath.Child("aclRules").Index(i)
+		if rule.Protocol != "tcp" && rule.Protocol != "udp" && (rule.StartPort != 0 || rule.EndPort != 0) {
+			errorList = append(errorList, field.Forbidden(rulePath, "ports are only allowed with the tcp and udp protocols"))
+		} else if rule.EndPort != 0 && rule.EndPort < rule.StartPort {
+			errorList = append(errorList, field.Invalid(rulePath.Child("endPort"), rule.EndPort, "must not be below startPort"))
+		}
+		errorList = append(errorList, validateCIDRs(rulePath.Child("cidrs"), rule.CIDRs)...)
+	}
+	return errorList
+}
+
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
 func (r *CloudStackCluster) ValidateUpdate(old runtime.Object) error {
 	cloudstackclusterlog.V(1).Info("entered validate update webhook", "api resource name", r.Name)
@@ -101,6 +343,13 @@ func (r *CloudStackCluster) ValidateUpdate(old runtime.Object) error {
 		errorList = append(errorList, err)
 	}
 
+	errorList = webhookutil.EnsureStringFieldsAreEqual(
+		spec.ControlPlaneEndpointProvider, oldSpec.ControlPlaneEndpointProvider, "controlPlaneEndpointProvider", errorList)
+	errorList = append(errorList, validateLoadBalancer(spec.LoadBalancer, spec.ControlPlaneEndpoint.Port)...)
+	errorList = append(errorList, validateAdditionalTags(field.NewPath("spec", "additionalTags"), spec.AdditionalTags)...)
+	errorList = append(errorList, validateFailureDomainPlacement(spec.FailureDomainPlacement, spec.FailureDomains)...)
+	errorList = append(errorList, validateControlPlaneFailureDomains(spec.FailureDomains)...)
+
 	if oldSpec.ControlPlaneEndpoint.Host != "" { // Need to allow one time endpoint setting via CAPC cluster controller.
 		errorList = webhookutil.EnsureStringFieldsAreEqual(
 			spec.ControlPlaneEndpoint.Host, oldSpec.ControlPlaneEndpoint.Host, "controlplaneendpoint.host", errorList)
@@ -144,9 +393,10 @@ func FailureDomainsEqual(fd1, fd2 CloudStackFailureDomainSpec) bool {
 		fd1.Domain == fd2.Domain &&
 		fd1.Zone.Name == fd2.Zone.Name &&
 		fd1.Zone.ID == fd2.Zone.ID &&
-		fd1.Zone.Network.Name == fd2.Zone.Network.Name &&
-		fd1.Zone.Network.ID == fd2.Zone.Network.ID &&
-		fd1.Zone.Network.Type == fd2.Zone.Network.Type
+		reflect.DeepEqual(fd1.Zone.Network, fd2.Zone.Network) &&
+		reflect.DeepEqual(fd1.Zone.Pod, fd2.Zone.Pod) &&
+		reflect.DeepEqual(fd1.Zone.Cluster, fd2.Zone.Cluster) &&
+		fd1.Zone.HostTag == fd2.Zone.HostTag
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
@@ -21,6 +21,7 @@ import (
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"k8s.io/utils/pointer"
 	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
 	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta2"
 )
@@ -29,6 +30,9 @@ var _ = Describe("CloudStackCluster webhooks", func() {
 	var ctx context.Context
 	forbiddenRegex := "admission webhook.*denied the request.*Forbidden\\: %s"
 	requiredRegex := "admission webhook.*denied the request.*Required value\\: %s"
+	invalidRegex := "admission webhook.*denied the request.*Invalid value\\: %s"
+	duplicateRegex := "admission webhook.*denied the request.*Duplicate value\\: %s"
+	notFoundRegex := "admission webhook.*denied the request.*Not found\\: %s"
 
 	BeforeEach(func() { // Reset test vars to initial state.
 		ctx = context.Background()
@@ -54,6 +58,76 @@ var _ = Describe("CloudStackCluster webhooks", func() {
 			Ω(k8sClient.Create(ctx, dummies.CSCluster)).Should(MatchError(MatchRegexp(requiredRegex,
 				"each Zone requires a Network specification")))
 		})
+
+		It("Should reject a CloudStackCluster with a malformed load balancer allowlist", func() {
+			dummies.CSCluster.Spec.LoadBalancer = &infrav1.LoadBalancerSpec{AllowedCIDRs: []string{"10.0.0.0"}}
+			Ω(k8sClient.Create(ctx, dummies.CSCluster)).Should(MatchError(MatchRegexp(invalidRegex, "\"10\\.0\\.0\\.0\"")))
+		})
+
+		It("Should reject a CloudStackCluster forwarding the API server port again", func() {
+			dummies.CSCluster.Spec.LoadBalancer = &infrav1.LoadBalancerSpec{AdditionalPorts: []infrav1.LoadBalancerPortMapping{
+				{Name: "konnectivity", PublicPort: 8132}, {Name: "apiserver", PublicPort: dummies.EndPointPort}}}
+			Ω(k8sClient.Create(ctx, dummies.CSCluster)).Should(MatchError(MatchRegexp(duplicateRegex, "5309")))
+		})
+
+		It("Should reject a CloudStackCluster with a network gateway outside of its CIDR", func() {
+			dummies.CSCluster.Spec.FailureDomains[0].Zone.Network.CIDR = "172.16.8.0/22"
+			dummies.CSCluster.Spec.FailureDomains[0].Zone.Network.Gateway = "172.16.12.1"
+			Ω(k8sClient.Create(ctx, dummies.CSCluster)).Should(MatchError(MatchRegexp(invalidRegex, "\"172\\.16\\.12\\.1\"")))
+		})
+
+		It("Should reject a CloudStackCluster setting a VLAN for a shared network", func() {
+			dummies.CSCluster.Spec.FailureDomains[0].Zone.Network.Type = infrav1.NetworkTypeShared
+			dummies.CSCluster.Spec.FailureDomains[0].Zone.Network.VLAN = "120"
+			Ω(k8sClient.Create(ctx, dummies.CSCluster)).Should(MatchError(MatchRegexp(forbiddenRegex, "not allowed with the Shared")))
+		})
+
+		It("Should reject a CloudStackCluster allowing IPv4 CIDRs to reach the API server over IPv6", func() {
+			dummies.CSCluster.Spec.FailureDomains[0].Zone.Network.Offering = &infrav1.CloudStackResourceIdentifier{Name: "DualStack"}
+			dummies.CSCluster.Spec.FailureDomains[0].Zone.Network.IPv6 = &infrav1.IPv6Spec{AllowedCIDRs: []string{"10.0.0.0/8"}}
+			Ω(k8sClient.Create(ctx, dummies.CSCluster)).Should(MatchError(MatchRegexp(invalidRegex, "\"10\\.0\\.0\\.0/8\"")))
+		})
+
+		It("Should reject a CloudStackCluster with a VPC network missing its VPC", func() {
+			dummies.CSCluster.Spec.FailureDomains[0].Zone.Network.Type = infrav1.NetworkTypeVPC
+			Ω(k8sClient.Create(ctx, dummies.CSCluster)).Should(MatchError(MatchRegexp(requiredRegex, "the VPC network type")))
+		})
+
+		It("Should reject a CloudStackCluster with a VPC tier outside of its VPC", func() {
+			dummies.CSCluster.Spec.FailureDomains[0].Zone.Network.Type = infrav1.NetworkTypeVPC
+			dummies.CSCluster.Spec.FailureDomains[0].Zone.Network.VPC = &infrav1.VPC{
+				Name: "vpc", CIDR: "10.40.0.0/16", TierCIDR: "10.50.1.0/24"}
+			Ω(k8sClient.Create(ctx, dummies.CSCluster)).Should(MatchError(MatchRegexp(invalidRegex, "\"10\\.50\\.1\\.0/24\"")))
+		})
+
+		It("Should reject a CloudStackCluster with additional tags CAPC reserves", func() {
+			dummies.CSCluster.Spec.AdditionalTags = map[string]string{"CAPC_cluster_foo": "1"}
+			Ω(k8sClient.Create(ctx, dummies.CSCluster)).Should(MatchError(MatchRegexp(forbiddenRegex, "tag keys starting with CAPC_")))
+		})
+
+		It("Should reject a CloudStackCluster weighing a failure domain it doesn't list", func() {
+			dummies.CSCluster.Spec.FailureDomainPlacement = &infrav1.FailureDomainPlacement{
+				Strategy: infrav1.PlacementStrategyWeighted, Weights: map[string]int32{"fd3": 2}}
+			Ω(k8sClient.Create(ctx, dummies.CSCluster)).Should(MatchError(MatchRegexp(notFoundRegex, "\"fd3\"")))
+		})
+
+		It("Should reject a CloudStackCluster weighing every failure domain at 0", func() {
+			dummies.CSCluster.Spec.FailureDomainPlacement = &infrav1.FailureDomainPlacement{
+				Strategy: infrav1.PlacementStrategyWeighted, Weights: map[string]int32{"fd1": 0, "fd2": 0}}
+			Ω(k8sClient.Create(ctx, dummies.CSCluster)).Should(MatchError(MatchRegexp(invalidRegex, "at least one failure domain")))
+		})
+
+		It("Should reject a CloudStackCluster with no failure domain eligible for the control plane", func() {
+			for i := range dummies.CSCluster.Spec.FailureDomains {
+				dummies.CSCluster.Spec.FailureDomains[i].ControlPlane = pointer.Bool(false)
+			}
+			Ω(k8sClient.Create(ctx, dummies.CSCluster)).Should(MatchError(MatchRegexp(invalidRegex, "eligible for the control plane")))
+		})
+
+		It("Should reject a CloudStackCluster narrowing a failure domain to an unidentified pod", func() {
+			dummies.CSCluster.Spec.FailureDomains[0].Zone.Pod = &infrav1.CloudStackResourceIdentifier{}
+			Ω(k8sClient.Create(ctx, dummies.CSCluster)).Should(MatchError(MatchRegexp(requiredRegex, "pod ID or name")))
+		})
 	})
 
 	Context("When updating a CloudStackCluster", func() {
@@ -65,6 +139,10 @@ var _ = Describe("CloudStackCluster webhooks", func() {
 			dummies.CSCluster.Spec.FailureDomains[0].Zone.Name = "SomeRandomUpdate"
 			Ω(k8sClient.Update(ctx, dummies.CSCluster)).Should(MatchError(MatchRegexp(forbiddenRegex, "Cannot change FailureDomain")))
 		})
+		It("Should reject updates to the host tag of CloudStackCluster Zones", func() {
+			dummies.CSCluster.Spec.FailureDomains[0].Zone.HostTag = "gpu"
+			Ω(k8sClient.Update(ctx, dummies.CSCluster)).Should(MatchError(MatchRegexp(forbiddenRegex, "Cannot change FailureDomain")))
+		})
 		It("Should reject updates to Networks specified in CloudStackCluster Zones", func() {
 			dummies.CSCluster.Spec.FailureDomains[0].Zone.Network.Name = "ArbitraryUpdateNetworkName"
 			Ω(k8sClient.Update(ctx, dummies.CSCluster)).Should(MatchError(MatchRegexp(forbiddenRegex, "Cannot change FailureDomain")))
@@ -80,5 +158,33 @@ var _ = Describe("CloudStackCluster webhooks", func() {
 			Ω(k8sClient.Update(ctx, dummies.CSCluster)).
 				Should(MatchError(MatchRegexp(forbiddenRegex, "controlplaneendpoint\\.port")))
 		})
+
+		It("Should reject updates to CloudStackCluster controlPlaneEndpointProvider", func() {
+			dummies.CSCluster.Spec.ControlPlaneEndpointProvider = infrav1.EndpointProviderExternal
+			Ω(k8sClient.Update(ctx, dummies.CSCluster)).
+				Should(MatchError(MatchRegexp(forbiddenRegex, "controlPlaneEndpointProvider")))
+		})
+
+		It("Should accept updates to the CloudStackCluster's load balancer", func() {
+			dummies.CSCluster.Spec.LoadBalancer = &infrav1.LoadBalancerSpec{
+				Algorithm: "leastconn", AllowedCIDRs: []string{"10.0.0.0/8"}}
+			Ω(k8sClient.Update(ctx, dummies.CSCluster)).Should(Succeed())
+		})
+
+		It("Should accept updates to the CloudStackCluster's additional tags", func() {
+			dummies.CSCluster.Spec.AdditionalTags = map[string]string{"cost-center": "1234"}
+			Ω(k8sClient.Update(ctx, dummies.CSCluster)).Should(Succeed())
+		})
+
+		It("Should accept updates to the control plane eligibility of CloudStackCluster FailureDomains", func() {
+			dummies.CSCluster.Spec.FailureDomains[0].ControlPlane = pointer.Bool(false)
+			Ω(k8sClient.Update(ctx, dummies.CSCluster)).Should(Succeed())
+		})
+
+		It("Should accept updates to the CloudStackCluster's failure domain placement", func() {
+			dummies.CSCluster.Spec.FailureDomainPlacement = &infrav1.FailureDomainPlacement{
+				Strategy: infrav1.PlacementStrategyWeighted, Weights: map[string]int32{"fd1": 3, "fd2": 1}}
+			Ω(k8sClient.Update(ctx, dummies.CSCluster)).Should(Succeed())
+		})
 	})
 })
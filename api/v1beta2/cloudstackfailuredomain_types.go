@@ -22,6 +22,7 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 )
 
 // FailureDomainHashedMetaName returns an MD5 name generated from the FailureDomain and Cluster name.
@@ -37,6 +38,7 @@ const (
 	FailureDomainLabelName = "cloudstackfailuredomain.infrastructure.cluster.x-k8s.io/name"
 	NetworkTypeIsolated    = "Isolated"
 	NetworkTypeShared      = "Shared"
+	NetworkTypeVPC         = "VPC"
 )
 
 type Network struct {
@@ -50,6 +52,110 @@ type Network struct {
 
 	// Cloudstack Network Name the cluster is built in.
 	Name string `json:"name"`
+
+	// Offering is the network offering, by ID or name, CAPC creates an isolated network with. Defaults to
+	// "DefaultIsolatedNetworkOfferingWithSourceNatService".
+	// +optional
+	// +k8s:conversion-gen=false
+	Offering *CloudStackResourceIdentifier `json:"offering,omitempty"`
+
+	// CIDR of an isolated network CAPC creates. CloudStack picks the zone's guest CIDR if unset.
+	// +optional
+	// +k8s:conversion-gen=false
+	CIDR string `json:"cidr,omitempty"`
+
+	// Gateway of an isolated network CAPC creates. Defaults to the first address of the CIDR, which it requires.
+	// +optional
+	// +k8s:conversion-gen=false
+	Gateway string `json:"gateway,omitempty"`
+
+	// Domain is the DNS domain of an isolated network CAPC creates.
+	// +optional
+	// +k8s:conversion-gen=false
+	Domain string `json:"domain,omitempty"`
+
+	// VLAN of an isolated network CAPC creates, as an ID or a vlan:// URI. The offering must allow specifying it.
+	// +optional
+	// +k8s:conversion-gen=false
+	VLAN string `json:"vlan,omitempty"`
+
+	// IPv6 makes an isolated network CAPC creates dual-stack. Its offering must support IPv6.
+	// +optional
+	// +k8s:conversion-gen=false
+	IPv6 *IPv6Spec `json:"ipv6,omitempty"`
+
+	// VPC the network is a tier of. Required for, and only allowed with, the VPC network type.
+	// +optional
+	// +k8s:conversion-gen=false
+	VPC *VPC `json:"vpc,omitempty"`
+}
+
+// IPv6Spec configures the IPv6 side of a dual-stack isolated network. CloudStack routes the network's IPv6 subnet
+// instead of translating it, so machines are reached at their own IPv6 addresses rather than through the load balancer.
+type IPv6Spec struct {
+	// AllowedCIDRs may reach the API server of control plane machines over IPv6. None may by default.
+	// +optional
+	AllowedCIDRs []string `json:"allowedCIDRs,omitempty"`
+}
+
+// VPC specifies a CloudStack VPC and the tier CAPC creates in it for the cluster's network.
+type VPC struct {
+	// ID of an existing VPC.
+	// +optional
+	ID string `json:"id,omitempty"`
+
+	// Name of the VPC. CAPC creates the VPC if there is none of this name in the zone.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// CIDR of the VPC. Required for CAPC to create the VPC.
+	// +optional
+	CIDR string `json:"cidr,omitempty"`
+
+	// Offering is the name of the VPC offering a created VPC uses. Defaults to "Default VPC offering".
+	// +optional
+	Offering string `json:"offering,omitempty"`
+
+	// TierOffering is the name of the network offering the tier is created with. Defaults to
+	// "DefaultIsolatedNetworkOfferingForVpcNetworks".
+	// +optional
+	TierOffering string `json:"tierOffering,omitempty"`
+
+	// TierCIDR is the CIDR of the tier. It must be within the VPC's CIDR.
+	TierCIDR string `json:"tierCIDR"`
+
+	// ACLRules make up the network ACL list of the tier. Defaults to allowing all traffic.
+	// +optional
+	ACLRules []NetworkACLRule `json:"aclRules,omitempty"`
+}
+
+// NetworkACLRule allows or denies traffic into or out of a VPC tier.
+type NetworkACLRule struct {
+	// Protocol of the traffic.
+	// +kubebuilder:validation:Enum=tcp;udp;icmp;all
+	Protocol string `json:"protocol"`
+
+	// StartPort of the tcp or udp port range. All ports if unset.
+	// +optional
+	StartPort int32 `json:"startPort,omitempty"`
+
+	// EndPort of the tcp or udp port range. Defaults to StartPort.
+	// +optional
+	EndPort int32 `json:"endPort,omitempty"`
+
+	// CIDRs the rule applies to. Defaults to 0.0.0.0/0.
+	// +optional
+	CIDRs []string `json:"cidrs,omitempty"`
+
+	// TrafficType is the direction of the traffic. Defaults to Ingress.
+	// +kubebuilder:validation:Enum=Ingress;Egress
+	// +optional
+	TrafficType string `json:"trafficType,omitempty"`
+
+	// Action taken on the traffic. Defaults to Allow.
+	// +kubebuilder:validation:Enum=Allow;Deny
+	// +optional
+	Action string `json:"action,omitempty"`
 }
 
 // CloudStackZoneSpec specifies a Zone's details.
@@ -64,6 +170,23 @@ type CloudStackZoneSpec struct {
 
 	// The network within the Zone to use.
 	Network Network `json:"network"`
+
+	// Pod narrows placement to a CloudStack pod of the zone, by ID or name.
+	// +optional
+	// +k8s:conversion-gen=false
+	Pod *CloudStackResourceIdentifier `json:"pod,omitempty"`
+
+	// Cluster narrows placement to a CloudStack cluster of hosts in the zone, and the pod if one is given, by ID or
+	// name.
+	// +optional
+	// +k8s:conversion-gen=false
+	Cluster *CloudStackResourceIdentifier `json:"cluster,omitempty"`
+
+	// HostTag narrows placement to the hosts carrying the tag, within the pod and cluster if given. Each VM is
+	// deployed on the enabled host with the most unallocated memory.
+	// +optional
+	// +k8s:conversion-gen=false
+	HostTag string `json:"hostTag,omitempty"`
 }
 
 // CloudStackFailureDomainSpec defines the desired state of CloudStackFailureDomain
@@ -84,12 +207,45 @@ type CloudStackFailureDomainSpec struct {
 
 	// Apache CloudStack Endpoint secret reference.
 	ACSEndpoint corev1.SecretReference `json:"acsEndpoint"`
+
+	// ControlPlane makes the failure domain eligible for control plane machines. Defaults to true.
+	// +optional
+	// +k8s:conversion-gen=false
+	ControlPlane *bool `json:"controlPlane,omitempty"`
+}
+
+// ControlPlaneEligible reports whether control plane machines may be placed in the failure domain.
+func (s *CloudStackFailureDomainSpec) ControlPlaneEligible() bool {
+	return s.ControlPlane == nil || *s.ControlPlane
 }
 
 // CloudStackFailureDomainStatus defines the observed state of CloudStackFailureDomain
 type CloudStackFailureDomainStatus struct {
 	// Reflects the readiness of the CloudStack Failure Domain.
 	Ready bool `json:"ready"`
+
+	// AsyncJob is the CloudStack job associating the public IP address of the control plane endpoint with the
+	// failure domain's network, creating its load balancer rules, or disassociating the address, while it runs. Only
+	// the NetworkLoadBalancer endpoint provider sets it.
+	// +optional
+	// +k8s:conversion-gen=false
+	AsyncJob *AsyncJob `json:"asyncJob,omitempty"`
+
+	// Conditions defines current service state of the CloudStackFailureDomain.
+	// +optional
+	// +k8s:conversion-gen=false
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+}
+
+// GetConditions returns the observations of the operational state of the CloudStackFailureDomain resource.
+func (r *CloudStackFailureDomain) GetConditions() clusterv1.Conditions {
+	return r.Status.Conditions
+}
+
+// SetConditions sets the underlying service state of the CloudStackFailureDomain to the predescribed
+// clusterv1.Conditions.
+func (r *CloudStackFailureDomain) SetConditions(conditions clusterv1.Conditions) {
+	r.Status.Conditions = conditions
 }
 
 //+kubebuilder:object:root=true
@@ -0,0 +1,177 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta2
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CloudStackIPPoolSpec defines the desired state of CloudStackIPPool
+type CloudStackIPPoolSpec struct {
+	// Name of the CloudStack network the addresses belong to. Defaults to the failure domain's network.
+	// The network must be one the machines referencing the pool have a NIC on.
+	// +optional
+	Network string `json:"network,omitempty"`
+
+	// Addresses to allocate from. Each entry is a single address (10.0.0.10), an inclusive range
+	// (10.0.0.10-10.0.0.20), or a CIDR (10.0.0.16/28). IPv4 CIDRs wider than /31 leave out their network
+	// and broadcast addresses.
+	// +kubebuilder:validation:MinItems=1
+	Addresses []string `json:"addresses"`
+}
+
+// CloudStackIPPoolStatus defines the observed state of CloudStackIPPool
+type CloudStackIPPoolStatus struct {
+	// Allocations maps each allocated address to the name of the CloudStackMachine holding it.
+	// +optional
+	Allocations map[string]string `json:"allocations,omitempty"`
+}
+
+// CloudStackIPAllocation records an address a machine holds from a CloudStackIPPool.
+type CloudStackIPAllocation struct {
+	// Pool is the name of the CloudStackIPPool the address was allocated from.
+	Pool string `json:"pool"`
+
+	// Network is the name of the network the address is on. Empty for the failure domain's network.
+	// +optional
+	Network string `json:"network,omitempty"`
+
+	// IP is the allocated address.
+	IP string `json:"ip"`
+}
+
+// ParseIPPoolEntry returns the first and last address of a pool address entry. Those of an IPv4 CIDR are its first and
+// last host addresses, so machines don't get its network or broadcast address.
+func ParseIPPoolEntry(entry string) (first, last netip.Addr, err error) {
+	entry = strings.TrimSpace(entry)
+	if strings.Contains(entry, "/") {
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return first, last, err
+		}
+		prefix = prefix.Masked()
+		bytes := prefix.Addr().AsSlice()
+		for bit := prefix.Bits(); bit < len(bytes)*8; bit++ { // Set the host bits to get the last address.
+			bytes[bit/8] |= 1 << (7 - bit%8)
+		}
+		first = prefix.Addr()
+		last, _ = netip.AddrFromSlice(bytes)
+		if first.Is4() && prefix.Bits() < 31 { // Leave out the network and broadcast addresses.
+			first, last = first.Next(), last.Prev()
+		}
+		return first, last, nil
+	}
+	if start, end, isRange := strings.Cut(entry, "-"); isRange {
+		if first, err = netip.ParseAddr(strings.TrimSpace(start)); err != nil {
+			return first, last, err
+		}
+		if last, err = netip.ParseAddr(strings.TrimSpace(end)); err != nil {
+			return first, last, err
+		}
+		if first.BitLen() != last.BitLen() || last.Less(first) {
+			return first, last, fmt.Errorf("invalid address range %s", entry)
+		}
+		return first, last, nil
+	}
+	first, err = netip.ParseAddr(entry)
+	return first, first, err
+}
+
+// Allocate returns the address held by the named machine, allocating the lowest free address in the pool to it if
+// it doesn't hold one yet. The caller is responsible for persisting the pool's status.
+func (p *CloudStackIPPool) Allocate(machineName string) (string, error) {
+	if ip := p.AllocatedTo(machineName); ip != "" {
+		return ip, nil
+	}
+	for _, entry := range p.Spec.Addresses {
+		first, last, err := ParseIPPoolEntry(entry)
+		if err != nil {
+			return "", err
+		}
+		for addr := first; addr.IsValid() && !last.Less(addr); addr = addr.Next() {
+			if _, taken := p.Status.Allocations[addr.String()]; !taken {
+				if p.Status.Allocations == nil {
+					p.Status.Allocations = map[string]string{}
+				}
+				p.Status.Allocations[addr.String()] = machineName
+				return addr.String(), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no free addresses left in IP pool %s", p.Name)
+}
+
+// AllocatedTo returns the address held by the named machine, or an empty string if it doesn't hold one.
+func (p *CloudStackIPPool) AllocatedTo(machineName string) string {
+	var ips []string
+	for ip, holder := range p.Status.Allocations {
+		if holder == machineName {
+			ips = append(ips, ip)
+		}
+	}
+	sort.Strings(ips)
+	if len(ips) == 0 {
+		return ""
+	}
+	return ips[0]
+}
+
+// Release frees any addresses held by the named machine, and reports whether there were any.
+func (p *CloudStackIPPool) Release(machineName string) bool {
+	released := false
+	for ip, holder := range p.Status.Allocations {
+		if holder == machineName {
+			delete(p.Status.Allocations, ip)
+			released = true
+		}
+	}
+	return released
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:path=cloudstackippools,scope=Namespaced,categories=cluster-api,shortName=csippool
+//+kubebuilder:subresource:status
+//+kubebuilder:storageversion
+//+kubebuilder:printcolumn:name="Network",type="string",JSONPath=".spec.network",description="Network the addresses belong to"
+//+ks8:conversion-gen=false
+
+// CloudStackIPPool is the Schema for the cloudstackippools API
+type CloudStackIPPool struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CloudStackIPPoolSpec   `json:"spec"`
+	Status CloudStackIPPoolStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// CloudStackIPPoolList contains a list of CloudStackIPPool
+type CloudStackIPPoolList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CloudStackIPPool `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&CloudStackIPPool{}, &CloudStackIPPoolList{})
+}
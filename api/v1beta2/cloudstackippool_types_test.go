@@ -0,0 +1,75 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta2_test
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
+)
+
+var _ = Describe("CloudStackIPPool", func() {
+	var pool *infrav1.CloudStackIPPool
+
+	// allocateAll allocates addresses to machines until the pool runs out, and returns them in order.
+	allocateAll := func() []string {
+		var ips []string
+		for i := 0; ; i++ {
+			ip, err := pool.Allocate(fmt.Sprintf("machine-%d", i))
+			if err != nil {
+				Ω(err).Should(MatchError(ContainSubstring("no free addresses left")))
+				return ips
+			}
+			ips = append(ips, ip)
+		}
+	}
+
+	BeforeEach(func() {
+		pool = &infrav1.CloudStackIPPool{ObjectMeta: metav1.ObjectMeta{Name: "pool"}}
+	})
+
+	Context("When allocating addresses", func() {
+		It("Should leave out the network and broadcast addresses of an IPv4 CIDR", func() {
+			pool.Spec.Addresses = []string{"10.0.0.0/30"}
+			Ω(allocateAll()).Should(Equal([]string{"10.0.0.1", "10.0.0.2"}))
+		})
+
+		It("Should leave out the network and broadcast addresses of an IPv4 CIDR given by one of its hosts", func() {
+			pool.Spec.Addresses = []string{"10.0.0.6/29"}
+			Ω(allocateAll()).Should(Equal([]string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5", "10.0.0.6"}))
+		})
+
+		It("Should allocate every address of /31 and /32 IPv4 CIDRs", func() {
+			pool.Spec.Addresses = []string{"10.0.0.4/31", "10.0.0.9/32"}
+			Ω(allocateAll()).Should(Equal([]string{"10.0.0.4", "10.0.0.5", "10.0.0.9"}))
+		})
+
+		It("Should allocate every address of an IPv6 CIDR", func() {
+			pool.Spec.Addresses = []string{"fd00::/127"}
+			Ω(allocateAll()).Should(Equal([]string{"fd00::", "fd00::1"}))
+		})
+
+		It("Should allocate the ends of a range", func() {
+			pool.Spec.Addresses = []string{"10.0.0.0-10.0.0.1", "10.0.0.255"}
+			Ω(allocateAll()).Should(Equal([]string{"10.0.0.0", "10.0.0.1", "10.0.0.255"}))
+		})
+	})
+})
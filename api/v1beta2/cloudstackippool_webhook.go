@@ -0,0 +1,85 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta2
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/webhookutil"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var cloudstackippoollog = logf.Log.WithName("cloudstackippool-resource")
+
+func (r *CloudStackIPPool) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-infrastructure-cluster-x-k8s-io-v1beta2-cloudstackippool,mutating=false,failurePolicy=fail,sideEffects=None,groups=infrastructure.cluster.x-k8s.io,resources=cloudstackippools,verbs=create;update,versions=v1beta2,name=vcloudstackippool.kb.io,admissionReviewVersions=v1beta1
+
+var _ webhook.Validator = &CloudStackIPPool{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *CloudStackIPPool) ValidateCreate() error {
+	cloudstackippoollog.V(1).Info("entered validate create webhook", "api resource name", r.Name)
+
+	return webhookutil.AggregateObjErrors(r.GroupVersionKind().GroupKind(), r.Name, r.validateAddresses(nil))
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *CloudStackIPPool) ValidateUpdate(old runtime.Object) error {
+	cloudstackippoollog.V(1).Info("entered validate update webhook", "api resource name", r.Name)
+
+	oldPool, ok := old.(*CloudStackIPPool)
+	if !ok {
+		return errors.NewBadRequest(fmt.Sprintf("expected a CloudStackIPPool but got a %T", old))
+	}
+
+	errorList := r.validateAddresses(nil)
+	errorList = webhookutil.EnsureStringFieldsAreEqual(r.Spec.Network, oldPool.Spec.Network, "network", errorList)
+
+	return webhookutil.AggregateObjErrors(r.GroupVersionKind().GroupKind(), r.Name, errorList)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *CloudStackIPPool) ValidateDelete() error {
+	cloudstackippoollog.V(1).Info("entered validate delete webhook", "api resource name", r.Name)
+	// No deletion validations.  Deletion webhook not enabled.
+	return nil
+}
+
+// validateAddresses ensures each address entry is a valid address, range, or CIDR.
+func (r *CloudStackIPPool) validateAddresses(errorList field.ErrorList) field.ErrorList {
+	if len(r.Spec.Addresses) == 0 {
+		errorList = append(errorList, field.Required(field.NewPath("spec", "addresses"), "addresses"))
+	}
+	for i, entry := range r.Spec.Addresses {
+		if _, _, err := ParseIPPoolEntry(entry); err != nil {
+			errorList = append(errorList, field.Invalid(field.NewPath("spec", "addresses").Index(i), entry,
+				"must be an IP address, an inclusive range of IP addresses, or a CIDR"))
+		}
+	}
+	return errorList
+}
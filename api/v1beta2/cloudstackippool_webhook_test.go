@@ -0,0 +1,68 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta2_test
+
+import (
+	"context"
+
+	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta2"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CloudStackIPPool webhook", func() {
+	var ctx context.Context
+	forbiddenRegex := "admission webhook.*denied the request.*Forbidden\\: %s"
+	invalidRegex := "admission webhook.*denied the request.*Invalid value\\: %s"
+
+	BeforeEach(func() { // Reset test vars to initial state.
+		dummies.SetDummyVars()
+		ctx = context.Background()
+		_ = k8sClient.Delete(ctx, dummies.CSIPPool1) // Delete any remnants.
+	})
+
+	Context("When creating a CloudStackIPPool", func() {
+		It("Should accept a CloudStackIPPool with addresses, ranges, and CIDRs", func() {
+			dummies.CSIPPool1.Spec.Addresses = []string{"10.0.0.5", "10.0.0.10-10.0.0.20", "10.0.1.0/28"}
+			Expect(k8sClient.Create(ctx, dummies.CSIPPool1)).Should(Succeed())
+		})
+
+		It("Should reject a CloudStackIPPool with an invalid address entry", func() {
+			dummies.CSIPPool1.Spec.Addresses = []string{"10.0.0.20-10.0.0.10"}
+			Expect(k8sClient.Create(ctx, dummies.CSIPPool1)).
+				Should(MatchError(MatchRegexp(invalidRegex, `"10.0.0.20-10.0.0.10"`)))
+		})
+	})
+
+	Context("When updating a CloudStackIPPool", func() {
+		BeforeEach(func() {
+			Ω(k8sClient.Create(ctx, dummies.CSIPPool1)).Should(Succeed())
+		})
+
+		It("Should accept added addresses", func() {
+			dummies.CSIPPool1.Spec.Addresses = append(dummies.CSIPPool1.Spec.Addresses, "10.10.0.30")
+			Ω(k8sClient.Update(ctx, dummies.CSIPPool1)).Should(Succeed())
+		})
+
+		It("Should reject updates to the network", func() {
+			dummies.CSIPPool1.Spec.Network = "storage-net"
+			Ω(k8sClient.Update(ctx, dummies.CSIPPool1)).
+				Should(MatchError(MatchRegexp(forbiddenRegex, "network")))
+		})
+	})
+})
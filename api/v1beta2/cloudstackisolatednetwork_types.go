@@ -42,6 +42,11 @@ type CloudStackIsolatedNetworkSpec struct {
 	//+k8s:conversion-gen=false
 	// FailureDomainName -- the FailureDomain the network is placed in.
 	FailureDomainName string `json:"failureDomainName"`
+
+	// VPCID is the ID of the VPC the network is a tier of, when the failure domain uses the VPC network type.
+	// +optional
+	// +k8s:conversion-gen=false
+	VPCID string `json:"vpcID,omitempty"`
 }
 
 // CloudStackIsolatedNetworkStatus defines the observed state of CloudStackIsolatedNetwork
@@ -52,8 +57,51 @@ type CloudStackIsolatedNetworkStatus struct {
 	// The ID of the lb rule used to assign VMs to the lb.
 	LBRuleID string `json:"loadBalancerRuleID,omitempty"`
 
+	// The ID of the network ACL list of a VPC tier.
+	// +optional
+	// +k8s:conversion-gen=false
+	ACLListID string `json:"aclListID,omitempty"`
+
+	// The IPv6 subnet of a dual-stack network.
+	// +optional
+	// +k8s:conversion-gen=false
+	IPv6CIDR string `json:"ipv6CIDR,omitempty"`
+
+	// The routes a dual-stack network's IPv6 subnet needs on the routers upstream of CloudStack.
+	// +optional
+	// +k8s:conversion-gen=false
+	IPv6Routes []IPv6Route `json:"ipv6Routes,omitempty"`
+
+	// AsyncJob is the CloudStack job associating the public IP address of the control plane endpoint with the
+	// network, creating its firewall or load balancer rules, or disassociating the address, while it runs.
+	// +optional
+	// +k8s:conversion-gen=false
+	AsyncJob *AsyncJob `json:"asyncJob,omitempty"`
+
 	// Ready indicates the readiness of this provider resource.
 	Ready bool `json:"ready"`
+
+	// Conditions defines current service state of the CloudStackIsolatedNetwork.
+	// +optional
+	// +k8s:conversion-gen=false
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+}
+
+// GetConditions returns the observations of the operational state of the CloudStackIsolatedNetwork resource.
+func (r *CloudStackIsolatedNetwork) GetConditions() clusterv1.Conditions {
+	return r.Status.Conditions
+}
+
+// SetConditions sets the underlying service state of the CloudStackIsolatedNetwork to the predescribed
+// clusterv1.Conditions.
+func (r *CloudStackIsolatedNetwork) SetConditions(conditions clusterv1.Conditions) {
+	r.Status.Conditions = conditions
+}
+
+// IPv6Route routes an IPv6 subnet via a gateway.
+type IPv6Route struct {
+	Subnet  string `json:"subnet"`
+	Gateway string `json:"gateway"`
 }
 
 func (n *CloudStackIsolatedNetwork) Network() *Network {
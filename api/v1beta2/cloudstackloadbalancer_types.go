@@ -0,0 +1,164 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta2
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+const (
+	LoadBalancerFinalizer = "loadbalancer.infrastructure.cluster.x-k8s.io"
+)
+
+// CloudStackLoadBalancerSpec defines the desired state of CloudStackLoadBalancer
+type CloudStackLoadBalancerSpec struct {
+	// FailureDomainName is the name of the failure domain whose isolated network's public IP address the rules are
+	// created on. Only machines in this failure domain become members.
+	FailureDomainName string `json:"failureDomainName"`
+
+	// Algorithm used to spread connections over a rule's machines. Defaults to roundrobin.
+	// +kubebuilder:validation:Enum=roundrobin;leastconn;source
+	// +optional
+	Algorithm string `json:"algorithm,omitempty"`
+
+	// Stickiness keeps a client's connections on the same machine.
+	// +optional
+	Stickiness *LoadBalancerStickinessPolicy `json:"stickiness,omitempty"`
+
+	// HealthCheck takes machines failing the check out of rotation of every rule.
+	// +optional
+	HealthCheck *LoadBalancerHealthCheckPolicy `json:"healthCheck,omitempty"`
+
+	// Rules forward public ports of the public IP address to the member machines.
+	// +kubebuilder:validation:MinItems=1
+	Rules []LoadBalancerPortMapping `json:"rules"`
+
+	// AllowedCIDRs restricts which source CIDRs may reach the rules. Defaults to 0.0.0.0/0.
+	// +optional
+	AllowedCIDRs []string `json:"allowedCIDRs,omitempty"`
+}
+
+// CloudStackLoadBalancerStatus defines the observed state of CloudStackLoadBalancer
+type CloudStackLoadBalancerStatus struct {
+	// PublicIP is the address the rules are reachable on.
+	// +optional
+	PublicIP string `json:"publicIP,omitempty"`
+
+	// RuleIDs maps each rule's name to the ID of its CloudStack load balancer rule.
+	// +optional
+	RuleIDs map[string]string `json:"ruleIDs,omitempty"`
+
+	// AsyncJob is the CloudStack job creating one of the load balancer's rules, or opening the firewall for it, while
+	// it runs.
+	// +optional
+	AsyncJob *AsyncJob `json:"asyncJob,omitempty"`
+
+	// Ready indicates the rules are set up and have the current members.
+	// +optional
+	Ready bool `json:"ready"`
+
+	// Conditions defines current service state of the CloudStackLoadBalancer.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+}
+
+// GetConditions returns the observations of the operational state of the CloudStackLoadBalancer resource.
+func (r *CloudStackLoadBalancer) GetConditions() clusterv1.Conditions {
+	return r.Status.Conditions
+}
+
+// SetConditions sets the underlying service state of the CloudStackLoadBalancer to the predescribed
+// clusterv1.Conditions.
+func (r *CloudStackLoadBalancer) SetConditions(conditions clusterv1.Conditions) {
+	r.Status.Conditions = conditions
+}
+
+// LoadBalancerMembership registers a machine into a CloudStackLoadBalancer.
+type LoadBalancerMembership struct {
+	// Name of the CloudStackLoadBalancer in the machine's namespace.
+	Name string `json:"name"`
+
+	// Rules to put the machine behind. Defaults to all of the load balancer's rules.
+	// +optional
+	Rules []string `json:"rules,omitempty"`
+}
+
+// LoadBalancerSpec returns the load balancer configuration the rules are created with.
+func (r *CloudStackLoadBalancer) LoadBalancerSpec() *LoadBalancerSpec {
+	return &LoadBalancerSpec{
+		Algorithm:       r.Spec.Algorithm,
+		Stickiness:      r.Spec.Stickiness,
+		HealthCheck:     r.Spec.HealthCheck,
+		AdditionalPorts: r.Spec.Rules,
+		AllowedCIDRs:    r.Spec.AllowedCIDRs,
+	}
+}
+
+// MembershipIn returns the machine's membership in the named load balancer, or nil if it isn't a member.
+func (r *CloudStackMachine) MembershipIn(lbName string) *LoadBalancerMembership {
+	for i := range r.Spec.LoadBalancerMemberships {
+		if r.Spec.LoadBalancerMemberships[i].Name == lbName {
+			return &r.Spec.LoadBalancerMemberships[i]
+		}
+	}
+	return nil
+}
+
+// Includes reports whether the membership puts the machine behind the named rule.
+func (m *LoadBalancerMembership) Includes(ruleName string) bool {
+	if len(m.Rules) == 0 {
+		return true
+	}
+	for _, name := range m.Rules {
+		if name == ruleName {
+			return true
+		}
+	}
+	return false
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:path=cloudstackloadbalancers,scope=Namespaced,categories=cluster-api,shortName=cslb
+//+kubebuilder:subresource:status
+//+kubebuilder:storageversion
+//+kubebuilder:printcolumn:name="Cluster",type="string",JSONPath=".metadata.labels.cluster\\.x-k8s\\.io/cluster-name",description="Cluster to which this CloudStackLoadBalancer belongs"
+//+kubebuilder:printcolumn:name="Public IP",type="string",JSONPath=".status.publicIP",description="Address the rules are reachable on"
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.ready",description="Load balancer ready status"
+//+ks8:conversion-gen=false
+
+// CloudStackLoadBalancer is the Schema for the cloudstackloadbalancers API
+type CloudStackLoadBalancer struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CloudStackLoadBalancerSpec   `json:"spec"`
+	Status CloudStackLoadBalancerStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// CloudStackLoadBalancerList contains a list of CloudStackLoadBalancer
+type CloudStackLoadBalancerList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CloudStackLoadBalancer `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&CloudStackLoadBalancer{}, &CloudStackLoadBalancerList{})
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta2
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/webhookutil"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var cloudstackloadbalancerlog = logf.Log.WithName("cloudstackloadbalancer-resource")
+
+func (r *CloudStackLoadBalancer) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-infrastructure-cluster-x-k8s-io-v1beta2-cloudstackloadbalancer,mutating=false,failurePolicy=fail,sideEffects=None,groups=infrastructure.cluster.x-k8s.io,resources=cloudstackloadbalancers,verbs=create;update,versions=v1beta2,name=vcloudstackloadbalancer.kb.io,admissionReviewVersions=v1beta1
+
+var _ webhook.Validator = &CloudStackLoadBalancer{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *CloudStackLoadBalancer) ValidateCreate() error {
+	cloudstackloadbalancerlog.V(1).Info("entered validate create webhook", "api resource name", r.Name)
+
+	return webhookutil.AggregateObjErrors(r.GroupVersionKind().GroupKind(), r.Name, r.validateSpec(nil))
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *CloudStackLoadBalancer) ValidateUpdate(old runtime.Object) error {
+	cloudstackloadbalancerlog.V(1).Info("entered validate update webhook", "api resource name", r.Name)
+
+	oldLB, ok := old.(*CloudStackLoadBalancer)
+	if !ok {
+		return errors.NewBadRequest(fmt.Sprintf("expected a CloudStackLoadBalancer but got a %T", old))
+	}
+
+	errorList := r.validateSpec(nil)
+	errorList = webhookutil.EnsureStringFieldsAreEqual(
+		r.Spec.FailureDomainName, oldLB.Spec.FailureDomainName, "failureDomainName", errorList)
+
+	return webhookutil.AggregateObjErrors(r.GroupVersionKind().GroupKind(), r.Name, errorList)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *CloudStackLoadBalancer) ValidateDelete() error {
+	cloudstackloadbalancerlog.V(1).Info("entered validate delete webhook", "api resource name", r.Name)
+	// No deletion validations.  Deletion webhook not enabled.
+	return nil
+}
+
+// validateSpec ensures the load balancer belongs to a cluster and failure domain, and that its rules and allowlists
+// are valid.
+func (r *CloudStackLoadBalancer) validateSpec(errorList field.ErrorList) field.ErrorList {
+	if r.GetLabels()[clusterv1.ClusterLabelName] == "" {
+		errorList = append(errorList, field.Required(
+			field.NewPath("metadata", "labels").Key(clusterv1.ClusterLabelName), clusterv1.ClusterLabelName))
+	}
+	errorList = webhookutil.EnsureFieldExists(r.Spec.FailureDomainName, "failureDomainName", errorList)
+	if len(r.Spec.Rules) == 0 {
+		errorList = append(errorList, field.Required(field.NewPath("spec", "rules"), "rules"))
+	}
+	errorList = append(errorList, validatePortMappings(field.NewPath("spec", "rules"), r.Spec.Rules)...)
+	return append(errorList, validateCIDRs(field.NewPath("spec", "allowedCIDRs"), r.Spec.AllowedCIDRs)...)
+}
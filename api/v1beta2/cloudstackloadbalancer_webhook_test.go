@@ -0,0 +1,77 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta2_test
+
+import (
+	"context"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
+	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta2"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CloudStackLoadBalancer webhook", func() {
+	var ctx context.Context
+	forbiddenRegex := "admission webhook.*denied the request.*Forbidden\\: %s"
+	requiredRegex := "admission webhook.*denied the request.*Required value\\: %s"
+	duplicateRegex := "admission webhook.*denied the request.*Duplicate value\\: %s"
+
+	BeforeEach(func() { // Reset test vars to initial state.
+		dummies.SetDummyVars()
+		ctx = context.Background()
+		_ = k8sClient.Delete(ctx, dummies.CSLoadBalancer1) // Delete any remnants.
+	})
+
+	Context("When creating a CloudStackLoadBalancer", func() {
+		It("Should accept a CloudStackLoadBalancer with rules", func() {
+			Expect(k8sClient.Create(ctx, dummies.CSLoadBalancer1)).Should(Succeed())
+		})
+
+		It("Should reject a CloudStackLoadBalancer without a cluster label", func() {
+			dummies.CSLoadBalancer1.Labels = nil
+			Expect(k8sClient.Create(ctx, dummies.CSLoadBalancer1)).
+				Should(MatchError(MatchRegexp(requiredRegex, "cluster.x-k8s.io/cluster-name")))
+		})
+
+		It("Should reject rules forwarding the same public port", func() {
+			dummies.CSLoadBalancer1.Spec.Rules = append(dummies.CSLoadBalancer1.Spec.Rules,
+				infrav1.LoadBalancerPortMapping{Name: "alt-http", PublicPort: 80})
+			Expect(k8sClient.Create(ctx, dummies.CSLoadBalancer1)).
+				Should(MatchError(MatchRegexp(duplicateRegex, "80")))
+		})
+	})
+
+	Context("When updating a CloudStackLoadBalancer", func() {
+		BeforeEach(func() {
+			Ω(k8sClient.Create(ctx, dummies.CSLoadBalancer1)).Should(Succeed())
+		})
+
+		It("Should accept added rules", func() {
+			dummies.CSLoadBalancer1.Spec.Rules = append(dummies.CSLoadBalancer1.Spec.Rules,
+				infrav1.LoadBalancerPortMapping{Name: "metrics", PublicPort: 9100})
+			Ω(k8sClient.Update(ctx, dummies.CSLoadBalancer1)).Should(Succeed())
+		})
+
+		It("Should reject updates to the failure domain", func() {
+			dummies.CSLoadBalancer1.Spec.FailureDomainName = "fd2"
+			Ω(k8sClient.Update(ctx, dummies.CSLoadBalancer1)).
+				Should(MatchError(MatchRegexp(forbiddenRegex, "failureDomainName")))
+		})
+	})
+})
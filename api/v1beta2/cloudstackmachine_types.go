@@ -21,6 +21,8 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
 )
 
 const (
@@ -31,6 +33,33 @@ const (
 	NoAffinity       = "no"
 )
 
+// MachineDeletionPolicy says what becomes of a machine's VM instance when the machine is deleted.
+type MachineDeletionPolicy string
+
+const (
+	// DeletionPolicyExpunge destroys and expunges the instance.
+	DeletionPolicyExpunge MachineDeletionPolicy = "Expunge"
+	// DeletionPolicyDestroy destroys the instance without expunging it, so it can be recovered until the account's
+	// expunge delay has passed.
+	DeletionPolicyDestroy MachineDeletionPolicy = "Destroy"
+	// DeletionPolicyRetain leaves the instance in CloudStack. It is taken out of the cluster's load balancers and the
+	// tags CAPC put on it are removed.
+	DeletionPolicyRetain MachineDeletionPolicy = "Retain"
+)
+
+// DataDiskDeletionPolicy says what becomes of a machine's data disks when its VM instance is destroyed.
+type DataDiskDeletionPolicy string
+
+const (
+	// DataDiskDeletionPolicyDelete deletes the data disks along with the instance.
+	DataDiskDeletionPolicyDelete DataDiskDeletionPolicy = "Delete"
+	// DataDiskDeletionPolicyRetain detaches the data disks and leaves them in CloudStack.
+	DataDiskDeletionPolicyRetain DataDiskDeletionPolicy = "Retain"
+	// DataDiskDeletionPolicySnapshotAndRetain snapshots the data disks, then detaches them and leaves them in
+	// CloudStack.
+	DataDiskDeletionPolicySnapshotAndRetain DataDiskDeletionPolicy = "SnapshotAndRetain"
+)
+
 // CloudStackMachineSpec defines the desired state of CloudStackMachine
 type CloudStackMachineSpec struct {
 	// Name.
@@ -83,6 +112,101 @@ type CloudStackMachineSpec struct {
 	// +optional
 	// +k8s:conversion-gen=false
 	FailureDomainName string `json:"failureDomainName,omitempty"`
+
+	// Networks to attach NICs to in addition to the failure domain's network. Each is resolved within the failure
+	// domain's zone. Listing the failure domain's network here sets options on its NIC rather than adding another.
+	// +optional
+	// +k8s:conversion-gen=false
+	Networks []CloudStackMachineNetwork `json:"networks,omitempty"`
+
+	// IPPoolRef references a CloudStackIPPool in the machine's namespace to allocate a static IP from.
+	// +optional
+	// +k8s:conversion-gen=false
+	IPPoolRef *corev1.LocalObjectReference `json:"ipPoolRef,omitempty"`
+
+	// LoadBalancerMemberships registers the machine into CloudStackLoadBalancers in its failure domain, such as
+	// ones forwarding ingress traffic to workers.
+	// +optional
+	// +k8s:conversion-gen=false
+	LoadBalancerMemberships []LoadBalancerMembership `json:"loadBalancerMemberships,omitempty"`
+
+	// InPlaceResize lets the offering and the cpuNumber, cpuSpeed and memory details change after creation. The
+	// instance is then scaled in place, live if both it and its offering allow dynamic scaling, and otherwise by
+	// stopping it, scaling it and starting it again.
+	// +optional
+	// +k8s:conversion-gen=false
+	InPlaceResize bool `json:"inPlaceResize,omitempty"`
+
+	// AdditionalTags are put on the machine's VM and volumes, overriding the cluster's additional tags of the same
+	// keys.
+	// +optional
+	// +k8s:conversion-gen=false
+	AdditionalTags map[string]string `json:"additionalTags,omitempty"`
+
+	// AdoptInstance takes over an existing VM instance, identified by InstanceID or else by the machine's name,
+	// rather than deploying one. The instance must be in the failure domain's zone and on its network, and use the
+	// machine's offering and template. Adopted instances are retained on deletion unless DeletionPolicy says
+	// otherwise.
+	// +optional
+	// +k8s:conversion-gen=false
+	AdoptInstance bool `json:"adoptInstance,omitempty"`
+
+	// DeletionPolicy says what becomes of the VM instance when the machine is deleted. Defaults to Expunge, or to
+	// Retain for adopted instances.
+	// +kubebuilder:validation:Enum=Expunge;Destroy;Retain
+	// +optional
+	// +k8s:conversion-gen=false
+	DeletionPolicy MachineDeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// DataDiskDeletionPolicy says what becomes of the instance's data disks when it is destroyed. Defaults to Delete.
+	// Retained data disks are recorded in the machine's status and events.
+	// +kubebuilder:validation:Enum=Delete;Retain;SnapshotAndRetain
+	// +optional
+	// +k8s:conversion-gen=false
+	DataDiskDeletionPolicy DataDiskDeletionPolicy `json:"dataDiskDeletionPolicy,omitempty"`
+}
+
+// RetainedDataDisk is a data disk left in CloudStack when its machine's instance was destroyed.
+type RetainedDataDisk struct {
+	// VolumeID is the ID of the data disk's volume.
+	VolumeID string `json:"volumeID"`
+
+	// SnapshotID is the ID of the snapshot taken of the volume before it was detached.
+	// +optional
+	SnapshotID string `json:"snapshotID,omitempty"`
+}
+
+// AsyncJob is a CloudStack asynchronous job a resource waits on. Jobs are polled on requeue rather than waited for,
+// and dropped from the resource's status once they're done.
+type AsyncJob struct {
+	// ID is the ID of the job.
+	ID string `json:"id"`
+
+	// Command is the CloudStack API command that started the job, such as deployVirtualMachine.
+	Command string `json:"command"`
+
+	// StartTime is when the job was started.
+	StartTime metav1.Time `json:"startTime"`
+}
+
+// CloudStackMachineNetwork specifies a network a machine has a NIC on.
+type CloudStackMachineNetwork struct {
+	// Cloudstack network ID.
+	// +optional
+	ID string `json:"id,omitempty"`
+
+	// Cloudstack network name.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Static IP address to give the NIC on this network. Assigned by CloudStack when empty.
+	// +optional
+	IP string `json:"ip,omitempty"`
+
+	// Default makes the NIC on this network the VM's default NIC. At most one network may be the default.
+	// Defaults to the failure domain's network.
+	// +optional
+	Default bool `json:"default,omitempty"`
 }
 
 type CloudStackResourceIdentifier struct {
@@ -133,6 +257,52 @@ type CloudStackMachineStatus struct {
 	// Reason indicates the reason of status failure
 	// +optional
 	Reason *string `json:"reason,omitempty"`
+
+	// IPAllocation is the static IP allocated to the machine from the pool referenced by Spec.IPPoolRef.
+	// +optional
+	// +k8s:conversion-gen=false
+	IPAllocation *CloudStackIPAllocation `json:"ipAllocation,omitempty"`
+
+	// RetainedDataDisks are the data disks kept while deleting the machine, as its DataDiskDeletionPolicy asks.
+	// +optional
+	// +k8s:conversion-gen=false
+	RetainedDataDisks []RetainedDataDisk `json:"retainedDataDisks,omitempty"`
+
+	// FailureDomainsTried are the failure domains CloudStack found no capacity for the machine's instance in, in the
+	// order they were tried. Machines placed by CAPC are moved to a failure domain not tried yet. It's cleared once
+	// the instance runs, or every failure domain was tried.
+	// +optional
+	// +k8s:conversion-gen=false
+	FailureDomainsTried []string `json:"failureDomainsTried,omitempty"`
+
+	// ReschedulingAttempts counts the times CloudStack found no capacity for the machine's instance since it last ran.
+	// Retrying is backed off exponentially with it.
+	// +optional
+	// +k8s:conversion-gen=false
+	ReschedulingAttempts int32 `json:"reschedulingAttempts,omitempty"`
+
+	// AsyncJob is the CloudStack job deploying, stopping, starting or destroying the machine's instance, while it
+	// runs.
+	// +optional
+	// +k8s:conversion-gen=false
+	AsyncJob *AsyncJob `json:"asyncJob,omitempty"`
+
+	// FailureReason is set when CAPC can't recover the machine's instance by retrying, such as when its template or
+	// offering isn't found or its account limits are exceeded. CAPI then marks the Machine failed, so a
+	// MachineHealthCheck can remediate it.
+	// +optional
+	// +k8s:conversion-gen=false
+	FailureReason *capierrors.MachineStatusError `json:"failureReason,omitempty"`
+
+	// FailureMessage describes the error that set FailureReason.
+	// +optional
+	// +k8s:conversion-gen=false
+	FailureMessage *string `json:"failureMessage,omitempty"`
+
+	// Conditions defines current service state of the CloudStackMachine.
+	// +optional
+	// +k8s:conversion-gen=false
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
 }
 
 // TimeSinceLastStateChange returns the amount of time that's elapsed since the state was last updated.  If the state
@@ -144,6 +314,16 @@ func (s *CloudStackMachineStatus) TimeSinceLastStateChange() time.Duration {
 	return time.Since(s.InstanceStateLastUpdated.Time)
 }
 
+// GetConditions returns the observations of the operational state of the CloudStackMachine resource.
+func (r *CloudStackMachine) GetConditions() clusterv1.Conditions {
+	return r.Status.Conditions
+}
+
+// SetConditions sets the underlying service state of the CloudStackMachine to the predescribed clusterv1.Conditions.
+func (r *CloudStackMachine) SetConditions(conditions clusterv1.Conditions) {
+	r.Status.Conditions = conditions
+}
+
 // +kubebuilder:object:root=true
 // +kubebuilder:resource:path=cloudstackmachines,scope=Namespaced,categories=cluster-api,shortName=csm
 // +kubebuilder:storageversion
@@ -18,6 +18,7 @@ package v1beta2
 
 import (
 	"fmt"
+	"net"
 	"reflect"
 
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -63,10 +64,35 @@ func (r *CloudStackMachine) ValidateCreate() error {
 	if len(r.Spec.DiskOffering.ID) > 0 || len(r.Spec.DiskOffering.Name) > 0 {
 		errorList = webhookutil.EnsureIntFieldsAreNotNegative(r.Spec.DiskOffering.CustomSize, "customSizeInGB", errorList)
 	}
+	errorList = validateNetworks(r.Spec.Networks, errorList)
+	errorList = append(errorList, validateAdditionalTags(field.NewPath("spec", "additionalTags"), r.Spec.AdditionalTags)...)
 
 	return webhookutil.AggregateObjErrors(r.GroupVersionKind().GroupKind(), r.Name, errorList)
 }
 
+// validateNetworks ensures each machine network can be identified, has a well-formed static IP if one is given, and
+// that at most one network is marked as the default.
+func validateNetworks(networks []CloudStackMachineNetwork, errorList field.ErrorList) field.ErrorList {
+	defaults := 0
+	for i, network := range networks {
+		path := field.NewPath("spec", "networks").Index(i)
+		if network.ID == "" && network.Name == "" {
+			errorList = append(errorList, field.Required(path, "network ID or name"))
+		}
+		if network.IP != "" && net.ParseIP(network.IP) == nil {
+			errorList = append(errorList, field.Invalid(path.Child("ip"), network.IP, "must be a valid IP address"))
+		}
+		if network.Default {
+			defaults++
+		}
+	}
+	if defaults > 1 {
+		errorList = append(errorList, field.Forbidden(field.NewPath("spec", "networks"),
+			"at most one network can be the default"))
+	}
+	return errorList
+}
+
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
 func (r *CloudStackMachine) ValidateUpdate(old runtime.Object) error {
 	cloudstackmachinelog.V(1).Info("entered validate update webhook", "api resource name", r.Name)
@@ -79,7 +105,11 @@ func (r *CloudStackMachine) ValidateUpdate(old runtime.Object) error {
 	}
 	oldSpec := oldMachine.Spec
 
-	errorList = webhookutil.EnsureBothFieldsAreEqual(r.Spec.Offering.ID, r.Spec.Offering.Name, oldSpec.Offering.ID, oldSpec.Offering.Name, "offering", errorList)
+	if !r.Spec.InPlaceResize {
+		errorList = webhookutil.EnsureBothFieldsAreEqual(r.Spec.Offering.ID, r.Spec.Offering.Name, oldSpec.Offering.ID, oldSpec.Offering.Name, "offering", errorList)
+	} else {
+		errorList = webhookutil.EnsureAtLeastOneFieldExists(r.Spec.Offering.ID, r.Spec.Offering.Name, "offering", errorList)
+	}
 	errorList = webhookutil.EnsureBothFieldsAreEqual(r.Spec.DiskOffering.ID, r.Spec.DiskOffering.Name, oldSpec.DiskOffering.ID, oldSpec.DiskOffering.Name, "diskOffering", errorList)
 	errorList = webhookutil.EnsureIntFieldsAreNotNegative(r.Spec.DiskOffering.CustomSize, "customSizeInGB", errorList)
 	errorList = webhookutil.EnsureStringFieldsAreEqual(r.Spec.DiskOffering.MountPath, oldSpec.DiskOffering.MountPath, "mountPath", errorList)
@@ -88,16 +118,50 @@ func (r *CloudStackMachine) ValidateUpdate(old runtime.Object) error {
 	errorList = webhookutil.EnsureStringFieldsAreEqual(r.Spec.DiskOffering.Label, oldSpec.DiskOffering.Label, "label", errorList)
 	errorList = webhookutil.EnsureStringFieldsAreEqual(r.Spec.SSHKey, oldSpec.SSHKey, "sshkey", errorList)
 	errorList = webhookutil.EnsureBothFieldsAreEqual(r.Spec.Template.ID, r.Spec.Template.Name, oldSpec.Template.ID, oldSpec.Template.Name, "template", errorList)
-	errorList = webhookutil.EnsureStringStringMapFieldsAreEqual(&r.Spec.Details, &oldSpec.Details, "details", errorList)
+	if !r.Spec.InPlaceResize {
+		errorList = webhookutil.EnsureStringStringMapFieldsAreEqual(&r.Spec.Details, &oldSpec.Details, "details", errorList)
+	} else {
+		newDetails, oldDetails := withoutResizableDetails(r.Spec.Details), withoutResizableDetails(oldSpec.Details)
+		errorList = webhookutil.EnsureStringStringMapFieldsAreEqual(&newDetails, &oldDetails, "details", errorList)
+	}
 	errorList = webhookutil.EnsureStringFieldsAreEqual(r.Spec.Affinity, oldSpec.Affinity, "affinity", errorList)
 
+	errorList = append(errorList, validateAdditionalTags(field.NewPath("spec", "additionalTags"), r.Spec.AdditionalTags)...)
+
 	if !reflect.DeepEqual(r.Spec.AffinityGroupIDs, oldSpec.AffinityGroupIDs) { // Equivalent to other Ensure funcs.
 		errorList = append(errorList, field.Forbidden(field.NewPath("spec", "AffinityGroupIDs"), "AffinityGroupIDs"))
 	}
+	if !reflect.DeepEqual(r.Spec.Networks, oldSpec.Networks) {
+		errorList = append(errorList, field.Forbidden(field.NewPath("spec", "networks"), "networks"))
+	}
+	if !reflect.DeepEqual(r.Spec.IPPoolRef, oldSpec.IPPoolRef) {
+		errorList = append(errorList, field.Forbidden(field.NewPath("spec", "ipPoolRef"), "ipPoolRef"))
+	}
+	if !reflect.DeepEqual(r.Spec.LoadBalancerMemberships, oldSpec.LoadBalancerMemberships) {
+		errorList = append(errorList, field.Forbidden(field.NewPath("spec", "loadBalancerMemberships"), "loadBalancerMemberships"))
+	}
+	if r.Spec.AdoptInstance != oldSpec.AdoptInstance {
+		errorList = append(errorList, field.Forbidden(field.NewPath("spec", "adoptInstance"), "adoptInstance"))
+	}
 
 	return webhookutil.AggregateObjErrors(r.GroupVersionKind().GroupKind(), r.Name, errorList)
 }
 
+// ResizableDetails are the VM details of custom compute offerings an in-place resize may change.
+var ResizableDetails = []string{"cpuNumber", "cpuSpeed", "memory"}
+
+// withoutResizableDetails copies details, leaving out the ones an in-place resize may change.
+func withoutResizableDetails(details map[string]string) map[string]string {
+	ret := map[string]string{}
+	for key, value := range details {
+		ret[key] = value
+	}
+	for _, key := range ResizableDetails {
+		delete(ret, key)
+	}
+	return ret
+}
+
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
 func (r *CloudStackMachine) ValidateDelete() error {
 	cloudstackmachinelog.V(1).Info("entered validate delete webhook", "api resource name", r.Name)
@@ -19,6 +19,7 @@ package v1beta2_test
 import (
 	"context"
 
+	corev1 "k8s.io/api/core/v1"
 	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
 	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta2"
 
@@ -70,6 +71,31 @@ var _ = Describe("CloudStackMachine webhook", func() {
 			Expect(k8sClient.Create(ctx, dummies.CSMachine1)).
 				Should(MatchError(MatchRegexp(requiredRegex, "Template")))
 		})
+
+		It("should accept a CloudStackMachine with additional networks", func() {
+			dummies.CSMachine1.Spec.Networks = []infrav1.CloudStackMachineNetwork{
+				{Name: "storage-net", IP: "10.0.0.10"}, {ID: "FakeNetID", Default: true}}
+			Expect(k8sClient.Create(ctx, dummies.CSMachine1)).Should(Succeed())
+		})
+
+		It("should reject a CloudStackMachine with a network missing both ID and name", func() {
+			dummies.CSMachine1.Spec.Networks = []infrav1.CloudStackMachineNetwork{{IP: "10.0.0.10"}}
+			Expect(k8sClient.Create(ctx, dummies.CSMachine1)).
+				Should(MatchError(MatchRegexp(requiredRegex, "network ID or name")))
+		})
+
+		It("should reject a CloudStackMachine with a malformed static IP", func() {
+			dummies.CSMachine1.Spec.Networks = []infrav1.CloudStackMachineNetwork{{Name: "storage-net", IP: "10.0.0"}}
+			Expect(k8sClient.Create(ctx, dummies.CSMachine1)).
+				Should(MatchError(MatchRegexp("admission webhook.*denied the request.*Invalid value.*must be a valid IP address")))
+		})
+
+		It("should reject a CloudStackMachine with more than one default network", func() {
+			dummies.CSMachine1.Spec.Networks = []infrav1.CloudStackMachineNetwork{
+				{Name: "storage-net", Default: true}, {Name: "management-net", Default: true}}
+			Expect(k8sClient.Create(ctx, dummies.CSMachine1)).
+				Should(MatchError(MatchRegexp(forbiddenRegex, "at most one network can be the default")))
+		})
 	})
 
 	Context("When updating a CloudStackMachine", func() {
@@ -83,6 +109,21 @@ var _ = Describe("CloudStackMachine webhook", func() {
 				Should(MatchError(MatchRegexp(forbiddenRegex, "offering")))
 		})
 
+		It("should accept VM offering and compute details updates to a CloudStackMachine resized in place", func() {
+			dummies.CSMachine1.Spec.InPlaceResize = true
+			dummies.CSMachine1.Spec.Offering = infrav1.CloudStackResourceIdentifier{Name: "ArbitraryUpdateOffering"}
+			dummies.CSMachine1.Spec.Details["cpuNumber"] = "4"
+			dummies.CSMachine1.Spec.Details["memory"] = "8192"
+			Ω(k8sClient.Update(ctx, dummies.CSMachine1)).Should(Succeed())
+		})
+
+		It("should reject updates to other VM details of a CloudStackMachine resized in place", func() {
+			dummies.CSMachine1.Spec.InPlaceResize = true
+			dummies.CSMachine1.Spec.Details["memoryOvercommitRatio"] = "1.5"
+			Ω(k8sClient.Update(ctx, dummies.CSMachine1)).
+				Should(MatchError(MatchRegexp(forbiddenRegex, "details")))
+		})
+
 		It("should reject VM template updates to the CloudStackMachine", func() {
 			dummies.CSMachine1.Spec.Template = infrav1.CloudStackResourceIdentifier{Name: "ArbitraryUpdateTemplate"}
 			Ω(k8sClient.Update(ctx, dummies.CSMachine1)).
@@ -106,5 +147,34 @@ var _ = Describe("CloudStackMachine webhook", func() {
 			Ω(k8sClient.Update(ctx, dummies.CSMachine1)).
 				Should(MatchError(MatchRegexp(forbiddenRegex, "AffinityGroupIDs")))
 		})
+
+		It("should reject updates to the networks of the CloudStackMachine", func() {
+			dummies.CSMachine1.Spec.Networks = []infrav1.CloudStackMachineNetwork{{Name: "storage-net"}}
+			Ω(k8sClient.Update(ctx, dummies.CSMachine1)).
+				Should(MatchError(MatchRegexp(forbiddenRegex, "networks")))
+		})
+
+		It("should reject updates to the IP pool of the CloudStackMachine", func() {
+			dummies.CSMachine1.Spec.IPPoolRef = &corev1.LocalObjectReference{Name: dummies.CSIPPool1.Name}
+			Ω(k8sClient.Update(ctx, dummies.CSMachine1)).
+				Should(MatchError(MatchRegexp(forbiddenRegex, "ipPoolRef")))
+		})
+
+		It("should reject updates to the load balancer memberships of the CloudStackMachine", func() {
+			dummies.CSMachine1.Spec.LoadBalancerMemberships = []infrav1.LoadBalancerMembership{{Name: dummies.CSLoadBalancer1.Name}}
+			Ω(k8sClient.Update(ctx, dummies.CSMachine1)).
+				Should(MatchError(MatchRegexp(forbiddenRegex, "loadBalancerMemberships")))
+		})
+
+		It("should reject updates to whether the CloudStackMachine adopts its instance", func() {
+			dummies.CSMachine1.Spec.AdoptInstance = true
+			Ω(k8sClient.Update(ctx, dummies.CSMachine1)).
+				Should(MatchError(MatchRegexp(forbiddenRegex, "adoptInstance")))
+		})
+
+		It("should accept deletion policy updates to the CloudStackMachine", func() {
+			dummies.CSMachine1.Spec.DeletionPolicy = infrav1.DeletionPolicyRetain
+			Ω(k8sClient.Update(ctx, dummies.CSMachine1)).Should(Succeed())
+		})
 	})
 })
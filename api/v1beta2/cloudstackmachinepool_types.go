@@ -0,0 +1,186 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta2
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+const (
+	MachinePoolFinalizer = "cloudstackmachinepool.infrastructure.cluster.x-k8s.io"
+
+	// DefaultMachinePoolMaxBatchSize is how many instances a pool creates or destroys at once if it doesn't say.
+	DefaultMachinePoolMaxBatchSize = 5
+)
+
+// CloudStackMachinePoolInstanceSpec is what each of a pool's VM instances is deployed with. Changing it rolls every
+// instance over to a new one.
+type CloudStackMachinePoolInstanceSpec struct {
+	// CloudStack compute offering.
+	Offering CloudStackResourceIdentifier `json:"offering"`
+
+	// CloudStack template to use.
+	Template CloudStackResourceIdentifier `json:"template"`
+
+	// CloudStack disk offering to use.
+	// +optional
+	DiskOffering CloudStackResourceDiskOffering `json:"diskOffering,omitempty"`
+
+	// CloudStack ssh key to use.
+	// +optional
+	SSHKey string `json:"sshKey,omitempty"`
+
+	// Optional details map for deployVirtualMachine
+	// +optional
+	Details map[string]string `json:"details,omitempty"`
+
+	// AdditionalTags are put on the instances and their volumes, overriding the cluster's additional tags of the same
+	// keys.
+	// +optional
+	AdditionalTags map[string]string `json:"additionalTags,omitempty"`
+}
+
+// CloudStackMachinePoolSpec defines the desired state of CloudStackMachinePool
+type CloudStackMachinePoolSpec struct {
+	CloudStackMachinePoolInstanceSpec `json:",inline"`
+
+	// ProviderIDList holds the provider IDs of the pool's instances, for CAPI to match them to nodes. It's set by CAPC.
+	// +optional
+	ProviderIDList []string `json:"providerIDList,omitempty"`
+
+	// FailureDomains are the names of the CloudStackCluster's failure domains the instances are spread over. Defaults
+	// to all of them.
+	// +optional
+	FailureDomains []string `json:"failureDomains,omitempty"`
+
+	// MaxBatchSize caps how many instances are created, or destroyed, at once when scaling or rolling the pool.
+	// Defaults to 5.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxBatchSize *int32 `json:"maxBatchSize,omitempty"`
+}
+
+// CloudStackMachinePoolInstance is a VM instance of a pool.
+type CloudStackMachinePoolInstance struct {
+	// ID of the VM instance.
+	ID string `json:"id"`
+
+	// Name of the VM instance.
+	Name string `json:"name"`
+
+	// FailureDomainName is the name of the failure domain the instance is in.
+	FailureDomainName string `json:"failureDomainName"`
+
+	// State of the VM instance in CloudStack.
+	// +optional
+	State string `json:"state,omitempty"`
+
+	// UpToDate is whether the instance was deployed with the pool's current instance spec.
+	UpToDate bool `json:"upToDate"`
+}
+
+// CloudStackMachinePoolStatus defines the observed state of CloudStackMachinePool
+type CloudStackMachinePoolStatus struct {
+	// Ready indicates the pool has as many running instances as its MachinePool asks for.
+	// +optional
+	Ready bool `json:"ready"`
+
+	// Replicas is the number of running instances.
+	// +optional
+	Replicas int32 `json:"replicas"`
+
+	// InstanceGroups maps each failure domain's name to the ID of the pool's CloudStack instance group in it.
+	// +optional
+	InstanceGroups map[string]string `json:"instanceGroups,omitempty"`
+
+	// Instances are the pool's VM instances.
+	// +optional
+	Instances []CloudStackMachinePoolInstance `json:"instances,omitempty"`
+
+	// Conditions defines current service state of the CloudStackMachinePool.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+}
+
+// GetConditions returns the observations of the operational state of the CloudStackMachinePool resource.
+func (r *CloudStackMachinePool) GetConditions() clusterv1.Conditions {
+	return r.Status.Conditions
+}
+
+// SetConditions sets the underlying service state of the CloudStackMachinePool to the predescribed
+// clusterv1.Conditions.
+func (r *CloudStackMachinePool) SetConditions(conditions clusterv1.Conditions) {
+	r.Status.Conditions = conditions
+}
+
+// MaxBatch returns how many instances the pool creates or destroys at once.
+func (r *CloudStackMachinePool) MaxBatch() int {
+	if r.Spec.MaxBatchSize != nil && *r.Spec.MaxBatchSize > 0 {
+		return int(*r.Spec.MaxBatchSize)
+	}
+	return DefaultMachinePoolMaxBatchSize
+}
+
+// MachineForInstance returns a CloudStackMachine standing in for one of the pool's instances, so it can be deployed
+// and destroyed the way a machine's instance is.
+func (r *CloudStackMachinePool) MachineForInstance(name, failureDomainName string, instanceID *string) *CloudStackMachine {
+	return &CloudStackMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: r.Namespace, Labels: r.Labels},
+		Spec: CloudStackMachineSpec{
+			InstanceID:        instanceID,
+			Offering:          r.Spec.Offering,
+			Template:          r.Spec.Template,
+			DiskOffering:      r.Spec.DiskOffering,
+			SSHKey:            r.Spec.SSHKey,
+			Details:           r.Spec.Details,
+			AdditionalTags:    r.Spec.AdditionalTags,
+			FailureDomainName: failureDomainName,
+		},
+	}
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:path=cloudstackmachinepools,scope=Namespaced,categories=cluster-api,shortName=csmp
+//+kubebuilder:subresource:status
+//+kubebuilder:storageversion
+//+kubebuilder:printcolumn:name="Cluster",type="string",JSONPath=".metadata.labels.cluster\\.x-k8s\\.io/cluster-name",description="Cluster to which this CloudStackMachinePool belongs"
+//+kubebuilder:printcolumn:name="Replicas",type="integer",JSONPath=".status.replicas",description="Running instances"
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.ready",description="Machine pool ready status"
+//+ks8:conversion-gen=false
+
+// CloudStackMachinePool is the Schema for the cloudstackmachinepools API
+type CloudStackMachinePool struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CloudStackMachinePoolSpec   `json:"spec"`
+	Status CloudStackMachinePoolStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// CloudStackMachinePoolList contains a list of CloudStackMachinePool
+type CloudStackMachinePoolList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CloudStackMachinePool `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&CloudStackMachinePool{}, &CloudStackMachinePoolList{})
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta2
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/webhookutil"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var cloudstackmachinepoollog = logf.Log.WithName("cloudstackmachinepool-resource")
+
+func (r *CloudStackMachinePool) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-infrastructure-cluster-x-k8s-io-v1beta2-cloudstackmachinepool,mutating=false,failurePolicy=fail,sideEffects=None,groups=infrastructure.cluster.x-k8s.io,resources=cloudstackmachinepools,verbs=create;update,versions=v1beta2,name=vcloudstackmachinepool.kb.io,admissionReviewVersions=v1beta1
+
+var _ webhook.Validator = &CloudStackMachinePool{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *CloudStackMachinePool) ValidateCreate() error {
+	cloudstackmachinepoollog.V(1).Info("entered validate create webhook", "api resource name", r.Name)
+
+	return webhookutil.AggregateObjErrors(r.GroupVersionKind().GroupKind(), r.Name, r.validateSpec(nil))
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *CloudStackMachinePool) ValidateUpdate(old runtime.Object) error {
+	cloudstackmachinepoollog.V(1).Info("entered validate update webhook", "api resource name", r.Name)
+
+	if _, ok := old.(*CloudStackMachinePool); !ok {
+		return errors.NewBadRequest(fmt.Sprintf("expected a CloudStackMachinePool but got a %T", old))
+	}
+
+	// The instance spec may change freely: the pool rolls its instances over to the new one.
+	return webhookutil.AggregateObjErrors(r.GroupVersionKind().GroupKind(), r.Name, r.validateSpec(nil))
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *CloudStackMachinePool) ValidateDelete() error {
+	cloudstackmachinepoollog.V(1).Info("entered validate delete webhook", "api resource name", r.Name)
+	// No deletion validations.  Deletion webhook not enabled.
+	return nil
+}
+
+// validateSpec ensures the pool belongs to a cluster, that its instances' offering and template can be identified,
+// and that it lists each failure domain at most once.
+func (r *CloudStackMachinePool) validateSpec(errorList field.ErrorList) field.ErrorList {
+	if r.GetLabels()[clusterv1.ClusterLabelName] == "" {
+		errorList = append(errorList, field.Required(
+			field.NewPath("metadata", "labels").Key(clusterv1.ClusterLabelName), clusterv1.ClusterLabelName))
+	}
+	errorList = webhookutil.EnsureAtLeastOneFieldExists(r.Spec.Offering.ID, r.Spec.Offering.Name, "Offering", errorList)
+	errorList = webhookutil.EnsureAtLeastOneFieldExists(r.Spec.Template.ID, r.Spec.Template.Name, "Template", errorList)
+	if len(r.Spec.DiskOffering.ID) > 0 || len(r.Spec.DiskOffering.Name) > 0 {
+		errorList = webhookutil.EnsureIntFieldsAreNotNegative(r.Spec.DiskOffering.CustomSize, "customSizeInGB", errorList)
+	}
+	errorList = append(errorList, validateAdditionalTags(field.NewPath("spec", "additionalTags"), r.Spec.AdditionalTags)...)
+
+	listed := map[string]bool{}
+	for i, name := range r.Spec.FailureDomains {
+		if listed[name] {
+			errorList = append(errorList, field.Duplicate(field.NewPath("spec", "failureDomains").Index(i), name))
+		}
+		listed[name] = true
+	}
+	return errorList
+}
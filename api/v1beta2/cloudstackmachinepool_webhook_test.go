@@ -0,0 +1,74 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta2_test
+
+import (
+	"context"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
+	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta2"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CloudStackMachinePool webhook", func() {
+	var ctx context.Context
+	requiredRegex := "admission webhook.*denied the request.*Required value\\: %s"
+	duplicateRegex := "admission webhook.*denied the request.*Duplicate value\\: %s"
+
+	BeforeEach(func() { // Reset test vars to initial state.
+		dummies.SetDummyVars()
+		ctx = context.Background()
+		_ = k8sClient.Delete(ctx, dummies.CSMachinePool1) // Delete any remnants.
+	})
+
+	Context("When creating a CloudStackMachinePool", func() {
+		It("Should accept a CloudStackMachinePool with an offering and template", func() {
+			Expect(k8sClient.Create(ctx, dummies.CSMachinePool1)).Should(Succeed())
+		})
+
+		It("Should reject a CloudStackMachinePool without a cluster label", func() {
+			dummies.CSMachinePool1.Labels = nil
+			Expect(k8sClient.Create(ctx, dummies.CSMachinePool1)).
+				Should(MatchError(MatchRegexp(requiredRegex, "cluster.x-k8s.io/cluster-name")))
+		})
+
+		It("Should reject a CloudStackMachinePool without an offering", func() {
+			dummies.CSMachinePool1.Spec.Offering = infrav1.CloudStackResourceIdentifier{}
+			Expect(k8sClient.Create(ctx, dummies.CSMachinePool1)).
+				Should(MatchError(MatchRegexp(requiredRegex, "Offering")))
+		})
+
+		It("Should reject a failure domain listed twice", func() {
+			dummies.CSMachinePool1.Spec.FailureDomains = []string{"fd1", "fd1"}
+			Expect(k8sClient.Create(ctx, dummies.CSMachinePool1)).
+				Should(MatchError(MatchRegexp(duplicateRegex, "fd1")))
+		})
+	})
+
+	Context("When updating a CloudStackMachinePool", func() {
+		BeforeEach(func() {
+			Ω(k8sClient.Create(ctx, dummies.CSMachinePool1)).Should(Succeed())
+		})
+
+		It("Should accept a new offering", func() {
+			dummies.CSMachinePool1.Spec.Offering.Name = "Large Instance"
+			Ω(k8sClient.Update(ctx, dummies.CSMachinePool1)).Should(Succeed())
+		})
+	})
+})
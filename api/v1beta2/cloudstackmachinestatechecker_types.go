@@ -18,6 +18,7 @@ package v1beta2
 
 import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 )
 
 // CloudStackMachineStateCheckerSpec
@@ -30,6 +31,22 @@ type CloudStackMachineStateCheckerSpec struct {
 type CloudStackMachineStateCheckerStatus struct {
 	// Reflects the readiness of the Machine State Checker.
 	Ready bool `json:"ready"`
+
+	// Conditions defines current service state of the CloudStackMachineStateChecker.
+	// +optional
+	// +k8s:conversion-gen=false
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+}
+
+// GetConditions returns the observations of the operational state of the CloudStackMachineStateChecker resource.
+func (r *CloudStackMachineStateChecker) GetConditions() clusterv1.Conditions {
+	return r.Status.Conditions
+}
+
+// SetConditions sets the underlying service state of the CloudStackMachineStateChecker to the predescribed
+// clusterv1.Conditions.
+func (r *CloudStackMachineStateChecker) SetConditions(conditions clusterv1.Conditions) {
+	r.Status.Conditions = conditions
 }
 
 //+kubebuilder:object:root=true
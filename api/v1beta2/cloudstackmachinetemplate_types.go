@@ -17,6 +17,7 @@ limitations under the License.
 package v1beta2
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -34,6 +35,29 @@ type CloudStackMachineTemplateSpec struct {
 	Spec CloudStackMachineTemplateResource `json:"template"`
 }
 
+// NodeInfo describes the nodes the machines of a template become.
+type NodeInfo struct {
+	// Architecture is the CPU architecture of the nodes, such as amd64 or arm64.
+	// +optional
+	Architecture string `json:"architecture,omitempty"`
+
+	// OperatingSystem is the operating system of the nodes, such as linux or windows.
+	// +optional
+	OperatingSystem string `json:"operatingSystem,omitempty"`
+}
+
+// CloudStackMachineTemplateStatus defines the observed state of CloudStackMachineTemplate
+type CloudStackMachineTemplateStatus struct {
+	// Capacity is the CPU, memory and ephemeral storage the machines of the template have, so the cluster autoscaler
+	// can scale their MachineDeployments or MachinePools from zero.
+	// +optional
+	Capacity corev1.ResourceList `json:"capacity,omitempty"`
+
+	// NodeInfo describes the nodes the machines of the template become, taken from their CloudStack template.
+	// +optional
+	NodeInfo *NodeInfo `json:"nodeInfo,omitempty"`
+}
+
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
 //+kubebuilder:storageversion
@@ -43,7 +67,8 @@ type CloudStackMachineTemplate struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
-	Spec CloudStackMachineTemplateSpec `json:"spec,omitempty"`
+	Spec   CloudStackMachineTemplateSpec   `json:"spec,omitempty"`
+	Status CloudStackMachineTemplateStatus `json:"status,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -73,6 +73,14 @@ func (r *CloudStackMachineTemplate) ValidateCreate() error {
 
 	errorList = webhookutil.EnsureAtLeastOneFieldExists(spec.Offering.ID, spec.Offering.Name, "Offering", errorList)
 	errorList = webhookutil.EnsureAtLeastOneFieldExists(spec.Template.ID, spec.Template.Name, "Template", errorList)
+	errorList = validateNetworks(spec.Networks, errorList)
+	for i, network := range spec.Networks {
+		if network.IP != "" { // Every machine stamped out of the template would request the same address.
+			errorList = append(errorList, field.Forbidden(field.NewPath("spec", "networks").Index(i).Child("ip"),
+				"static IPs cannot be set in a machine template"))
+		}
+	}
+	errorList = append(errorList, validateAdditionalTags(field.NewPath("spec", "additionalTags"), spec.AdditionalTags)...)
 
 	return webhookutil.AggregateObjErrors(r.GroupVersionKind().GroupKind(), r.Name, errorList)
 }
@@ -101,6 +109,15 @@ func (r *CloudStackMachineTemplate) ValidateUpdate(old runtime.Object) error {
 	if !reflect.DeepEqual(spec.AffinityGroupIDs, oldSpec.AffinityGroupIDs) { // Equivalent to other Ensure funcs.
 		errorList = append(errorList, field.Forbidden(field.NewPath("spec", "AffinityGroupIDs"), "AffinityGroupIDs"))
 	}
+	if !reflect.DeepEqual(spec.Networks, oldSpec.Networks) {
+		errorList = append(errorList, field.Forbidden(field.NewPath("spec", "networks"), "networks"))
+	}
+	if !reflect.DeepEqual(spec.IPPoolRef, oldSpec.IPPoolRef) {
+		errorList = append(errorList, field.Forbidden(field.NewPath("spec", "ipPoolRef"), "ipPoolRef"))
+	}
+	if !reflect.DeepEqual(spec.LoadBalancerMemberships, oldSpec.LoadBalancerMemberships) {
+		errorList = append(errorList, field.Forbidden(field.NewPath("spec", "loadBalancerMemberships"), "loadBalancerMemberships"))
+	}
 
 	return webhookutil.AggregateObjErrors(r.GroupVersionKind().GroupKind(), r.Name, errorList)
 }
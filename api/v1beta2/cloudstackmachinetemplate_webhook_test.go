@@ -60,6 +60,13 @@ var _ = Describe("CloudStackMachineTemplate webhook", func() {
 			Expect(k8sClient.Create(ctx, dummies.CSMachineTemplate1)).
 				Should(MatchError(MatchRegexp(requiredRegex, "Template")))
 		})
+
+		It("Should reject a CloudStackMachineTemplate with a static IP on a network", func() {
+			dummies.CSMachineTemplate1.Spec.Spec.Spec.Networks = []infrav1.CloudStackMachineNetwork{
+				{Name: "storage-net", IP: "10.0.0.10"}}
+			Expect(k8sClient.Create(ctx, dummies.CSMachineTemplate1)).
+				Should(MatchError(MatchRegexp(forbiddenRegex, "static IPs cannot be set in a machine template")))
+		})
 	})
 
 	Context("When updating a CloudStackMachineTemplate", func() {
@@ -0,0 +1,194 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta2
+
+import clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+const (
+	// InstanceResizedCondition reports whether a CloudStackMachine's instance has the compute offering and details
+	// of its spec. It is only set on machines with InPlaceResize.
+	InstanceResizedCondition clusterv1.ConditionType = "InstanceResized"
+
+	// InstanceResizingReason (Severity=Info) means the instance is being scaled to a new offering or new details.
+	InstanceResizingReason = "Resizing"
+
+	// InstanceResizeFailedReason (Severity=Warning) means CloudStack refused to scale the instance. The resize is
+	// retried.
+	InstanceResizeFailedReason = "ResizeFailed"
+)
+
+const (
+	// InstanceAdoptedCondition reports whether a CloudStackMachine took over its existing instance. It is only set
+	// on machines with AdoptInstance.
+	InstanceAdoptedCondition clusterv1.ConditionType = "InstanceAdopted"
+
+	// InstanceAdoptionFailedReason (Severity=Warning) means no instance matching the machine was found to adopt. The
+	// adoption is retried, and no instance is deployed in the meantime.
+	InstanceAdoptionFailedReason = "AdoptionFailed"
+)
+
+const (
+	// InstancePlacedCondition reports whether a CloudStackMachine's instance was deployed in its failure domain. It is
+	// only set on machines CloudStack found no capacity for in a failure domain.
+	InstancePlacedCondition clusterv1.ConditionType = "InstancePlaced"
+
+	// InsufficientCapacityReason (Severity=Warning) means CloudStack found no capacity for the instance in a failure
+	// domain, and the machine was moved to another one it hasn't been tried in.
+	InsufficientCapacityReason = "InsufficientCapacity"
+
+	// FailureDomainsExhaustedReason (Severity=Error) means CloudStack found no capacity for the instance in any of
+	// the failure domains it could be placed in. Deploying it is retried in the last one tried, and the reason stays
+	// until an instance runs.
+	FailureDomainsExhaustedReason = "FailureDomainsExhausted"
+)
+
+const (
+	// FailureDomainHealthyCondition reports whether a CloudStackFailureDomain can take new machines, as last probed.
+	// Failure domains that can't are left out of the CloudStackCluster's status, so CAPI places no machines in them.
+	FailureDomainHealthyCondition clusterv1.ConditionType = "Healthy"
+
+	// ZoneDisabledReason (Severity=Error) means the failure domain's zone is missing or not enabled for allocation.
+	ZoneDisabledReason = "ZoneDisabled"
+
+	// NetworkUnavailableReason (Severity=Error) means the failure domain's network is missing or shut down.
+	NetworkUnavailableReason = "NetworkUnavailable"
+
+	// APIUnreachableReason (Severity=Warning) means CloudStack couldn't be reached with the failure domain's
+	// credentials.
+	APIUnreachableReason = "APIUnreachable"
+
+	// ResourceLimitReachedReason (Severity=Warning) means the failure domain's account can't deploy another VM
+	// within its resource limits.
+	ResourceLimitReachedReason = "ResourceLimitReached"
+)
+
+const (
+	// FailureDomainsReadyCondition reports whether all of a CloudStackCluster's failure domains are ready.
+	FailureDomainsReadyCondition clusterv1.ConditionType = "FailureDomainsReady"
+
+	// WaitingForFailureDomainsReason (Severity=Info) means some of the cluster's failure domains are missing or not
+	// ready yet.
+	WaitingForFailureDomainsReason = "WaitingForFailureDomains"
+)
+
+const (
+	// NetworkReadyCondition reports whether the network of a CloudStackFailureDomain or CloudStackIsolatedNetwork
+	// was resolved, or created.
+	NetworkReadyCondition clusterv1.ConditionType = "NetworkReady"
+
+	// WaitingForIsolatedNetworkReason (Severity=Info) means the failure domain waits for its
+	// CloudStackIsolatedNetwork to be ready.
+	WaitingForIsolatedNetworkReason = "WaitingForIsolatedNetwork"
+
+	// NetworkFailedReason (Severity=Warning) means the zone or network couldn't be resolved, or the network
+	// couldn't be created. It is retried.
+	NetworkFailedReason = "NetworkFailed"
+)
+
+const (
+	// PublicIPAssociatedCondition reports whether a CloudStackIsolatedNetwork has the public IP address of the
+	// control plane endpoint. It is only set on networks providing the endpoint.
+	PublicIPAssociatedCondition clusterv1.ConditionType = "PublicIPAssociated"
+
+	// PublicIPAssociationFailedReason (Severity=Warning) means no public IP address could be associated with the
+	// network. It is retried.
+	PublicIPAssociationFailedReason = "PublicIPAssociationFailed"
+)
+
+const (
+	// LoadBalancerReadyCondition reports whether a load balancer is set up: the control plane endpoint's of a
+	// CloudStackIsolatedNetwork or CloudStackFailureDomain, or a CloudStackLoadBalancer's rules.
+	LoadBalancerReadyCondition clusterv1.ConditionType = "LoadBalancerReady"
+
+	// WaitingForPublicIPReason (Severity=Info) means the load balancer waits for the public IP address of its
+	// isolated network.
+	WaitingForPublicIPReason = "WaitingForPublicIP"
+
+	// LoadBalancerFailedReason (Severity=Warning) means CloudStack refused to set up the load balancer. It is retried.
+	LoadBalancerFailedReason = "LoadBalancerFailed"
+)
+
+const (
+	// InstanceProvisionedCondition reports whether a CloudStackMachine's instance is deployed and running, or all of
+	// a CloudStackMachinePool's instances are up to date and running.
+	InstanceProvisionedCondition clusterv1.ConditionType = "InstanceProvisioned"
+
+	// InstanceNotRunningReason (Severity=Info) means the instance is deployed but isn't running yet. On a
+	// CloudStackMachineStateChecker (Severity=Warning), it means a running instance stopped.
+	InstanceNotRunningReason = "InstanceNotRunning"
+
+	// InstanceProvisionFailedReason (Severity=Warning) means deploying the instance failed. It is retried.
+	InstanceProvisionFailedReason = "InstanceProvisionFailed"
+
+	// InstanceErrorReason (Severity=Error) means the instance is in the Error state. Its machine is deleted to be
+	// replaced.
+	InstanceErrorReason = "InstanceError"
+
+	// ScalingReason (Severity=Info) means a pool is creating or destroying instances to reach its replicas with
+	// its current instance spec.
+	ScalingReason = "Scaling"
+)
+
+const (
+	// BootstrapDataReadyCondition reports whether a CloudStackMachine's bootstrap data is available to deploy its
+	// instance with.
+	BootstrapDataReadyCondition clusterv1.ConditionType = "BootstrapDataReady"
+
+	// WaitingForBootstrapDataReason (Severity=Info) means the machine's bootstrap provider hasn't produced its
+	// bootstrap data yet.
+	WaitingForBootstrapDataReason = "WaitingForBootstrapData"
+)
+
+const (
+	// LoadBalancerAttachedCondition reports whether a control plane CloudStackMachine's instance is behind the
+	// control plane endpoint. It is only set on control plane machines whose endpoint CAPC provides.
+	LoadBalancerAttachedCondition clusterv1.ConditionType = "LoadBalancerAttached"
+
+	// LoadBalancerAttachFailedReason (Severity=Warning) means the instance couldn't be put behind the endpoint. It
+	// is retried.
+	LoadBalancerAttachFailedReason = "LoadBalancerAttachFailed"
+)
+
+const (
+	// AffinityGroupReadyCondition reports whether the affinity group of a CloudStackMachine, or that of a
+	// CloudStackAffinityGroup, exists in CloudStack. It is only set on machines with managed affinity.
+	AffinityGroupReadyCondition clusterv1.ConditionType = "AffinityGroupReady"
+
+	// WaitingForAffinityGroupReason (Severity=Info) means the machine waits for its CloudStackAffinityGroup to be
+	// ready.
+	WaitingForAffinityGroupReason = "WaitingForAffinityGroup"
+
+	// AffinityGroupFailedReason (Severity=Warning) means the affinity group couldn't be created. It is retried.
+	AffinityGroupFailedReason = "AffinityGroupFailed"
+)
+
+const (
+	// InstanceRunningCondition reports whether a CloudStackMachineStateChecker last found its machine's instance
+	// running and its node up.
+	InstanceRunningCondition clusterv1.ConditionType = "InstanceRunning"
+
+	// NodeUnreachableReason (Severity=Error) means the instance has been running for a while without its machine
+	// becoming a node. The machine is deleted to be replaced.
+	NodeUnreachableReason = "NodeUnreachable"
+)
+
+const (
+	// AsyncJobPendingReason (Severity=Info) means a CloudStack async job acting on the resource, such as deploying its
+	// instance or associating its public IP address, is still running. The job is recorded in the resource's status
+	// and polled on requeue. Jobs that fail are reported with the reason of the operation that started them.
+	AsyncJobPendingReason = "AsyncJobPending"
+)
@@ -57,3 +57,21 @@ func (*CloudStackMachineStateChecker) Hub() {}
 
 // Hub marks CloudStackMachineStateCheckerList as a conversion hub.
 func (*CloudStackMachineStateCheckerList) Hub() {}
+
+// Hub marks CloudStackIPPool as a conversion hub.
+func (*CloudStackIPPool) Hub() {}
+
+// Hub marks CloudStackIPPoolList as a conversion hub.
+func (*CloudStackIPPoolList) Hub() {}
+
+// Hub marks CloudStackLoadBalancer as a conversion hub.
+func (*CloudStackLoadBalancer) Hub() {}
+
+// Hub marks CloudStackLoadBalancerList as a conversion hub.
+func (*CloudStackLoadBalancerList) Hub() {}
+
+// Hub marks CloudStackMachinePool as a conversion hub.
+func (*CloudStackMachinePool) Hub() {}
+
+// Hub marks CloudStackMachinePoolList as a conversion hub.
+func (*CloudStackMachinePoolList) Hub() {}
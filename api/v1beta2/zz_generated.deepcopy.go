@@ -25,15 +25,32 @@ import (
 	"k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/errors"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AsyncJob) DeepCopyInto(out *AsyncJob) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AsyncJob.
+func (in *AsyncJob) DeepCopy() *AsyncJob {
+	if in == nil {
+		return nil
+	}
+	out := new(AsyncJob)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CloudStackAffinityGroup) DeepCopyInto(out *CloudStackAffinityGroup) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackAffinityGroup.
@@ -104,6 +121,13 @@ func (in *CloudStackAffinityGroupSpec) DeepCopy() *CloudStackAffinityGroupSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CloudStackAffinityGroupStatus) DeepCopyInto(out *CloudStackAffinityGroupStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(v1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackAffinityGroupStatus.
@@ -181,9 +205,28 @@ func (in *CloudStackClusterSpec) DeepCopyInto(out *CloudStackClusterSpec) {
 	if in.FailureDomains != nil {
 		in, out := &in.FailureDomains, &out.FailureDomains
 		*out = make([]CloudStackFailureDomainSpec, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 	out.ControlPlaneEndpoint = in.ControlPlaneEndpoint
+	if in.LoadBalancer != nil {
+		in, out := &in.LoadBalancer, &out.LoadBalancer
+		*out = new(LoadBalancerSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AdditionalTags != nil {
+		in, out := &in.AdditionalTags, &out.AdditionalTags
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.FailureDomainPlacement != nil {
+		in, out := &in.FailureDomainPlacement, &out.FailureDomainPlacement
+		*out = new(FailureDomainPlacement)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackClusterSpec.
@@ -206,6 +249,13 @@ func (in *CloudStackClusterStatus) DeepCopyInto(out *CloudStackClusterStatus) {
 			(*out)[key] = *val.DeepCopy()
 		}
 	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(v1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackClusterStatus.
@@ -223,8 +273,8 @@ func (in *CloudStackFailureDomain) DeepCopyInto(out *CloudStackFailureDomain) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackFailureDomain.
@@ -280,8 +330,13 @@ func (in *CloudStackFailureDomainList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CloudStackFailureDomainSpec) DeepCopyInto(out *CloudStackFailureDomainSpec) {
 	*out = *in
-	out.Zone = in.Zone
+	in.Zone.DeepCopyInto(&out.Zone)
 	out.ACSEndpoint = in.ACSEndpoint
+	if in.ControlPlane != nil {
+		in, out := &in.ControlPlane, &out.ControlPlane
+		*out = new(bool)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackFailureDomainSpec.
@@ -297,6 +352,18 @@ func (in *CloudStackFailureDomainSpec) DeepCopy() *CloudStackFailureDomainSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CloudStackFailureDomainStatus) DeepCopyInto(out *CloudStackFailureDomainStatus) {
 	*out = *in
+	if in.AsyncJob != nil {
+		in, out := &in.AsyncJob, &out.AsyncJob
+		*out = new(AsyncJob)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(v1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackFailureDomainStatus.
@@ -310,26 +377,41 @@ func (in *CloudStackFailureDomainStatus) DeepCopy() *CloudStackFailureDomainStat
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CloudStackIsolatedNetwork) DeepCopyInto(out *CloudStackIsolatedNetwork) {
+func (in *CloudStackIPAllocation) DeepCopyInto(out *CloudStackIPAllocation) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackIPAllocation.
+func (in *CloudStackIPAllocation) DeepCopy() *CloudStackIPAllocation {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudStackIPAllocation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudStackIPPool) DeepCopyInto(out *CloudStackIPPool) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackIsolatedNetwork.
-func (in *CloudStackIsolatedNetwork) DeepCopy() *CloudStackIsolatedNetwork {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackIPPool.
+func (in *CloudStackIPPool) DeepCopy() *CloudStackIPPool {
 	if in == nil {
 		return nil
 	}
-	out := new(CloudStackIsolatedNetwork)
+	out := new(CloudStackIPPool)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *CloudStackIsolatedNetwork) DeepCopyObject() runtime.Object {
+func (in *CloudStackIPPool) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -337,31 +419,31 @@ func (in *CloudStackIsolatedNetwork) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CloudStackIsolatedNetworkList) DeepCopyInto(out *CloudStackIsolatedNetworkList) {
+func (in *CloudStackIPPoolList) DeepCopyInto(out *CloudStackIPPoolList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]CloudStackIsolatedNetwork, len(*in))
+		*out = make([]CloudStackIPPool, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackIsolatedNetworkList.
-func (in *CloudStackIsolatedNetworkList) DeepCopy() *CloudStackIsolatedNetworkList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackIPPoolList.
+func (in *CloudStackIPPoolList) DeepCopy() *CloudStackIPPoolList {
 	if in == nil {
 		return nil
 	}
-	out := new(CloudStackIsolatedNetworkList)
+	out := new(CloudStackIPPoolList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *CloudStackIsolatedNetworkList) DeepCopyObject() runtime.Object {
+func (in *CloudStackIPPoolList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -369,57 +451,68 @@ func (in *CloudStackIsolatedNetworkList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CloudStackIsolatedNetworkSpec) DeepCopyInto(out *CloudStackIsolatedNetworkSpec) {
+func (in *CloudStackIPPoolSpec) DeepCopyInto(out *CloudStackIPPoolSpec) {
 	*out = *in
-	out.ControlPlaneEndpoint = in.ControlPlaneEndpoint
+	if in.Addresses != nil {
+		in, out := &in.Addresses, &out.Addresses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackIsolatedNetworkSpec.
-func (in *CloudStackIsolatedNetworkSpec) DeepCopy() *CloudStackIsolatedNetworkSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackIPPoolSpec.
+func (in *CloudStackIPPoolSpec) DeepCopy() *CloudStackIPPoolSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(CloudStackIsolatedNetworkSpec)
+	out := new(CloudStackIPPoolSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CloudStackIsolatedNetworkStatus) DeepCopyInto(out *CloudStackIsolatedNetworkStatus) {
+func (in *CloudStackIPPoolStatus) DeepCopyInto(out *CloudStackIPPoolStatus) {
 	*out = *in
+	if in.Allocations != nil {
+		in, out := &in.Allocations, &out.Allocations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackIsolatedNetworkStatus.
-func (in *CloudStackIsolatedNetworkStatus) DeepCopy() *CloudStackIsolatedNetworkStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackIPPoolStatus.
+func (in *CloudStackIPPoolStatus) DeepCopy() *CloudStackIPPoolStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(CloudStackIsolatedNetworkStatus)
+	out := new(CloudStackIPPoolStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CloudStackMachine) DeepCopyInto(out *CloudStackMachine) {
+func (in *CloudStackIsolatedNetwork) DeepCopyInto(out *CloudStackIsolatedNetwork) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
+	out.Spec = in.Spec
 	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackMachine.
-func (in *CloudStackMachine) DeepCopy() *CloudStackMachine {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackIsolatedNetwork.
+func (in *CloudStackIsolatedNetwork) DeepCopy() *CloudStackIsolatedNetwork {
 	if in == nil {
 		return nil
 	}
-	out := new(CloudStackMachine)
+	out := new(CloudStackIsolatedNetwork)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *CloudStackMachine) DeepCopyObject() runtime.Object {
+func (in *CloudStackIsolatedNetwork) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -427,31 +520,31 @@ func (in *CloudStackMachine) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CloudStackMachineList) DeepCopyInto(out *CloudStackMachineList) {
+func (in *CloudStackIsolatedNetworkList) DeepCopyInto(out *CloudStackIsolatedNetworkList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]CloudStackMachine, len(*in))
+		*out = make([]CloudStackIsolatedNetwork, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackMachineList.
-func (in *CloudStackMachineList) DeepCopy() *CloudStackMachineList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackIsolatedNetworkList.
+func (in *CloudStackIsolatedNetworkList) DeepCopy() *CloudStackIsolatedNetworkList {
 	if in == nil {
 		return nil
 	}
-	out := new(CloudStackMachineList)
+	out := new(CloudStackIsolatedNetworkList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *CloudStackMachineList) DeepCopyObject() runtime.Object {
+func (in *CloudStackIsolatedNetworkList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -459,71 +552,74 @@ func (in *CloudStackMachineList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CloudStackMachineSpec) DeepCopyInto(out *CloudStackMachineSpec) {
+func (in *CloudStackIsolatedNetworkSpec) DeepCopyInto(out *CloudStackIsolatedNetworkSpec) {
 	*out = *in
-	if in.InstanceID != nil {
-		in, out := &in.InstanceID, &out.InstanceID
-		*out = new(string)
-		**out = **in
-	}
-	out.Offering = in.Offering
-	out.Template = in.Template
-	out.DiskOffering = in.DiskOffering
-	if in.Details != nil {
-		in, out := &in.Details, &out.Details
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
+	out.ControlPlaneEndpoint = in.ControlPlaneEndpoint
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackIsolatedNetworkSpec.
+func (in *CloudStackIsolatedNetworkSpec) DeepCopy() *CloudStackIsolatedNetworkSpec {
+	if in == nil {
+		return nil
 	}
-	if in.AffinityGroupIDs != nil {
-		in, out := &in.AffinityGroupIDs, &out.AffinityGroupIDs
-		*out = make([]string, len(*in))
+	out := new(CloudStackIsolatedNetworkSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudStackIsolatedNetworkStatus) DeepCopyInto(out *CloudStackIsolatedNetworkStatus) {
+	*out = *in
+	if in.IPv6Routes != nil {
+		in, out := &in.IPv6Routes, &out.IPv6Routes
+		*out = make([]IPv6Route, len(*in))
 		copy(*out, *in)
 	}
-	if in.AffinityGroupRef != nil {
-		in, out := &in.AffinityGroupRef, &out.AffinityGroupRef
-		*out = new(v1.ObjectReference)
-		**out = **in
+	if in.AsyncJob != nil {
+		in, out := &in.AsyncJob, &out.AsyncJob
+		*out = new(AsyncJob)
+		(*in).DeepCopyInto(*out)
 	}
-	if in.ProviderID != nil {
-		in, out := &in.ProviderID, &out.ProviderID
-		*out = new(string)
-		**out = **in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(v1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackMachineSpec.
-func (in *CloudStackMachineSpec) DeepCopy() *CloudStackMachineSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackIsolatedNetworkStatus.
+func (in *CloudStackIsolatedNetworkStatus) DeepCopy() *CloudStackIsolatedNetworkStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(CloudStackMachineSpec)
+	out := new(CloudStackIsolatedNetworkStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CloudStackMachineStateChecker) DeepCopyInto(out *CloudStackMachineStateChecker) {
+func (in *CloudStackLoadBalancer) DeepCopyInto(out *CloudStackLoadBalancer) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackMachineStateChecker.
-func (in *CloudStackMachineStateChecker) DeepCopy() *CloudStackMachineStateChecker {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackLoadBalancer.
+func (in *CloudStackLoadBalancer) DeepCopy() *CloudStackLoadBalancer {
 	if in == nil {
 		return nil
 	}
-	out := new(CloudStackMachineStateChecker)
+	out := new(CloudStackLoadBalancer)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *CloudStackMachineStateChecker) DeepCopyObject() runtime.Object {
+func (in *CloudStackLoadBalancer) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -531,31 +627,31 @@ func (in *CloudStackMachineStateChecker) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CloudStackMachineStateCheckerList) DeepCopyInto(out *CloudStackMachineStateCheckerList) {
+func (in *CloudStackLoadBalancerList) DeepCopyInto(out *CloudStackLoadBalancerList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]CloudStackMachineStateChecker, len(*in))
+		*out = make([]CloudStackLoadBalancer, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackMachineStateCheckerList.
-func (in *CloudStackMachineStateCheckerList) DeepCopy() *CloudStackMachineStateCheckerList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackLoadBalancerList.
+func (in *CloudStackLoadBalancerList) DeepCopy() *CloudStackLoadBalancerList {
 	if in == nil {
 		return nil
 	}
-	out := new(CloudStackMachineStateCheckerList)
+	out := new(CloudStackLoadBalancerList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *CloudStackMachineStateCheckerList) DeepCopyObject() runtime.Object {
+func (in *CloudStackLoadBalancerList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -563,86 +659,97 @@ func (in *CloudStackMachineStateCheckerList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CloudStackMachineStateCheckerSpec) DeepCopyInto(out *CloudStackMachineStateCheckerSpec) {
+func (in *CloudStackLoadBalancerSpec) DeepCopyInto(out *CloudStackLoadBalancerSpec) {
 	*out = *in
-}
-
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackMachineStateCheckerSpec.
-func (in *CloudStackMachineStateCheckerSpec) DeepCopy() *CloudStackMachineStateCheckerSpec {
-	if in == nil {
-		return nil
+	if in.Stickiness != nil {
+		in, out := &in.Stickiness, &out.Stickiness
+		*out = new(LoadBalancerStickinessPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HealthCheck != nil {
+		in, out := &in.HealthCheck, &out.HealthCheck
+		*out = new(LoadBalancerHealthCheckPolicy)
+		**out = **in
+	}
+	if in.Rules != nil {
+		in, out := &in.Rules, &out.Rules
+		*out = make([]LoadBalancerPortMapping, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.AllowedCIDRs != nil {
+		in, out := &in.AllowedCIDRs, &out.AllowedCIDRs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
 	}
-	out := new(CloudStackMachineStateCheckerSpec)
-	in.DeepCopyInto(out)
-	return out
-}
-
-// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CloudStackMachineStateCheckerStatus) DeepCopyInto(out *CloudStackMachineStateCheckerStatus) {
-	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackMachineStateCheckerStatus.
-func (in *CloudStackMachineStateCheckerStatus) DeepCopy() *CloudStackMachineStateCheckerStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackLoadBalancerSpec.
+func (in *CloudStackLoadBalancerSpec) DeepCopy() *CloudStackLoadBalancerSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(CloudStackMachineStateCheckerStatus)
+	out := new(CloudStackLoadBalancerSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CloudStackMachineStatus) DeepCopyInto(out *CloudStackMachineStatus) {
+func (in *CloudStackLoadBalancerStatus) DeepCopyInto(out *CloudStackLoadBalancerStatus) {
 	*out = *in
-	if in.Addresses != nil {
-		in, out := &in.Addresses, &out.Addresses
-		*out = make([]v1.NodeAddress, len(*in))
-		copy(*out, *in)
+	if in.RuleIDs != nil {
+		in, out := &in.RuleIDs, &out.RuleIDs
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
 	}
-	in.InstanceStateLastUpdated.DeepCopyInto(&out.InstanceStateLastUpdated)
-	if in.Status != nil {
-		in, out := &in.Status, &out.Status
-		*out = new(string)
-		**out = **in
+	if in.AsyncJob != nil {
+		in, out := &in.AsyncJob, &out.AsyncJob
+		*out = new(AsyncJob)
+		(*in).DeepCopyInto(*out)
 	}
-	if in.Reason != nil {
-		in, out := &in.Reason, &out.Reason
-		*out = new(string)
-		**out = **in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(v1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackMachineStatus.
-func (in *CloudStackMachineStatus) DeepCopy() *CloudStackMachineStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackLoadBalancerStatus.
+func (in *CloudStackLoadBalancerStatus) DeepCopy() *CloudStackLoadBalancerStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(CloudStackMachineStatus)
+	out := new(CloudStackLoadBalancerStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CloudStackMachineTemplate) DeepCopyInto(out *CloudStackMachineTemplate) {
+func (in *CloudStackMachine) DeepCopyInto(out *CloudStackMachine) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackMachineTemplate.
-func (in *CloudStackMachineTemplate) DeepCopy() *CloudStackMachineTemplate {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackMachine.
+func (in *CloudStackMachine) DeepCopy() *CloudStackMachine {
 	if in == nil {
 		return nil
 	}
-	out := new(CloudStackMachineTemplate)
+	out := new(CloudStackMachine)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *CloudStackMachineTemplate) DeepCopyObject() runtime.Object {
+func (in *CloudStackMachine) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -650,31 +757,31 @@ func (in *CloudStackMachineTemplate) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CloudStackMachineTemplateList) DeepCopyInto(out *CloudStackMachineTemplateList) {
+func (in *CloudStackMachineList) DeepCopyInto(out *CloudStackMachineList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]CloudStackMachineTemplate, len(*in))
+		*out = make([]CloudStackMachine, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackMachineTemplateList.
-func (in *CloudStackMachineTemplateList) DeepCopy() *CloudStackMachineTemplateList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackMachineList.
+func (in *CloudStackMachineList) DeepCopy() *CloudStackMachineList {
 	if in == nil {
 		return nil
 	}
-	out := new(CloudStackMachineTemplateList)
+	out := new(CloudStackMachineList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *CloudStackMachineTemplateList) DeepCopyObject() runtime.Object {
+func (in *CloudStackMachineList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -682,26 +789,504 @@ func (in *CloudStackMachineTemplateList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CloudStackMachineTemplateResource) DeepCopyInto(out *CloudStackMachineTemplateResource) {
+func (in *CloudStackMachineNetwork) DeepCopyInto(out *CloudStackMachineNetwork) {
 	*out = *in
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackMachineTemplateResource.
-func (in *CloudStackMachineTemplateResource) DeepCopy() *CloudStackMachineTemplateResource {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackMachineNetwork.
+func (in *CloudStackMachineNetwork) DeepCopy() *CloudStackMachineNetwork {
 	if in == nil {
 		return nil
 	}
-	out := new(CloudStackMachineTemplateResource)
+	out := new(CloudStackMachineNetwork)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CloudStackMachineTemplateSpec) DeepCopyInto(out *CloudStackMachineTemplateSpec) {
+func (in *CloudStackMachinePool) DeepCopyInto(out *CloudStackMachinePool) {
 	*out = *in
-	in.Spec.DeepCopyInto(&out.Spec)
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackMachinePool.
+func (in *CloudStackMachinePool) DeepCopy() *CloudStackMachinePool {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudStackMachinePool)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CloudStackMachinePool) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudStackMachinePoolInstance) DeepCopyInto(out *CloudStackMachinePoolInstance) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackMachinePoolInstance.
+func (in *CloudStackMachinePoolInstance) DeepCopy() *CloudStackMachinePoolInstance {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudStackMachinePoolInstance)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudStackMachinePoolInstanceSpec) DeepCopyInto(out *CloudStackMachinePoolInstanceSpec) {
+	*out = *in
+	out.Offering = in.Offering
+	out.Template = in.Template
+	out.DiskOffering = in.DiskOffering
+	if in.Details != nil {
+		in, out := &in.Details, &out.Details
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.AdditionalTags != nil {
+		in, out := &in.AdditionalTags, &out.AdditionalTags
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackMachinePoolInstanceSpec.
+func (in *CloudStackMachinePoolInstanceSpec) DeepCopy() *CloudStackMachinePoolInstanceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudStackMachinePoolInstanceSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudStackMachinePoolList) DeepCopyInto(out *CloudStackMachinePoolList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]CloudStackMachinePool, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackMachinePoolList.
+func (in *CloudStackMachinePoolList) DeepCopy() *CloudStackMachinePoolList {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudStackMachinePoolList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CloudStackMachinePoolList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudStackMachinePoolSpec) DeepCopyInto(out *CloudStackMachinePoolSpec) {
+	*out = *in
+	in.CloudStackMachinePoolInstanceSpec.DeepCopyInto(&out.CloudStackMachinePoolInstanceSpec)
+	if in.ProviderIDList != nil {
+		in, out := &in.ProviderIDList, &out.ProviderIDList
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.FailureDomains != nil {
+		in, out := &in.FailureDomains, &out.FailureDomains
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MaxBatchSize != nil {
+		in, out := &in.MaxBatchSize, &out.MaxBatchSize
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackMachinePoolSpec.
+func (in *CloudStackMachinePoolSpec) DeepCopy() *CloudStackMachinePoolSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudStackMachinePoolSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudStackMachinePoolStatus) DeepCopyInto(out *CloudStackMachinePoolStatus) {
+	*out = *in
+	if in.InstanceGroups != nil {
+		in, out := &in.InstanceGroups, &out.InstanceGroups
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Instances != nil {
+		in, out := &in.Instances, &out.Instances
+		*out = make([]CloudStackMachinePoolInstance, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(v1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackMachinePoolStatus.
+func (in *CloudStackMachinePoolStatus) DeepCopy() *CloudStackMachinePoolStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudStackMachinePoolStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudStackMachineSpec) DeepCopyInto(out *CloudStackMachineSpec) {
+	*out = *in
+	if in.InstanceID != nil {
+		in, out := &in.InstanceID, &out.InstanceID
+		*out = new(string)
+		**out = **in
+	}
+	out.Offering = in.Offering
+	out.Template = in.Template
+	out.DiskOffering = in.DiskOffering
+	if in.Details != nil {
+		in, out := &in.Details, &out.Details
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.AffinityGroupIDs != nil {
+		in, out := &in.AffinityGroupIDs, &out.AffinityGroupIDs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AffinityGroupRef != nil {
+		in, out := &in.AffinityGroupRef, &out.AffinityGroupRef
+		*out = new(v1.ObjectReference)
+		**out = **in
+	}
+	if in.ProviderID != nil {
+		in, out := &in.ProviderID, &out.ProviderID
+		*out = new(string)
+		**out = **in
+	}
+	if in.Networks != nil {
+		in, out := &in.Networks, &out.Networks
+		*out = make([]CloudStackMachineNetwork, len(*in))
+		copy(*out, *in)
+	}
+	if in.IPPoolRef != nil {
+		in, out := &in.IPPoolRef, &out.IPPoolRef
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
+	if in.LoadBalancerMemberships != nil {
+		in, out := &in.LoadBalancerMemberships, &out.LoadBalancerMemberships
+		*out = make([]LoadBalancerMembership, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.AdditionalTags != nil {
+		in, out := &in.AdditionalTags, &out.AdditionalTags
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackMachineSpec.
+func (in *CloudStackMachineSpec) DeepCopy() *CloudStackMachineSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudStackMachineSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudStackMachineStateChecker) DeepCopyInto(out *CloudStackMachineStateChecker) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackMachineStateChecker.
+func (in *CloudStackMachineStateChecker) DeepCopy() *CloudStackMachineStateChecker {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudStackMachineStateChecker)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CloudStackMachineStateChecker) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudStackMachineStateCheckerList) DeepCopyInto(out *CloudStackMachineStateCheckerList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]CloudStackMachineStateChecker, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackMachineStateCheckerList.
+func (in *CloudStackMachineStateCheckerList) DeepCopy() *CloudStackMachineStateCheckerList {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudStackMachineStateCheckerList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CloudStackMachineStateCheckerList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudStackMachineStateCheckerSpec) DeepCopyInto(out *CloudStackMachineStateCheckerSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackMachineStateCheckerSpec.
+func (in *CloudStackMachineStateCheckerSpec) DeepCopy() *CloudStackMachineStateCheckerSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudStackMachineStateCheckerSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudStackMachineStateCheckerStatus) DeepCopyInto(out *CloudStackMachineStateCheckerStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(v1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackMachineStateCheckerStatus.
+func (in *CloudStackMachineStateCheckerStatus) DeepCopy() *CloudStackMachineStateCheckerStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudStackMachineStateCheckerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudStackMachineStatus) DeepCopyInto(out *CloudStackMachineStatus) {
+	*out = *in
+	if in.Addresses != nil {
+		in, out := &in.Addresses, &out.Addresses
+		*out = make([]v1.NodeAddress, len(*in))
+		copy(*out, *in)
+	}
+	in.InstanceStateLastUpdated.DeepCopyInto(&out.InstanceStateLastUpdated)
+	if in.Status != nil {
+		in, out := &in.Status, &out.Status
+		*out = new(string)
+		**out = **in
+	}
+	if in.Reason != nil {
+		in, out := &in.Reason, &out.Reason
+		*out = new(string)
+		**out = **in
+	}
+	if in.IPAllocation != nil {
+		in, out := &in.IPAllocation, &out.IPAllocation
+		*out = new(CloudStackIPAllocation)
+		**out = **in
+	}
+	if in.RetainedDataDisks != nil {
+		in, out := &in.RetainedDataDisks, &out.RetainedDataDisks
+		*out = make([]RetainedDataDisk, len(*in))
+		copy(*out, *in)
+	}
+	if in.FailureDomainsTried != nil {
+		in, out := &in.FailureDomainsTried, &out.FailureDomainsTried
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AsyncJob != nil {
+		in, out := &in.AsyncJob, &out.AsyncJob
+		*out = new(AsyncJob)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.FailureReason != nil {
+		in, out := &in.FailureReason, &out.FailureReason
+		*out = new(errors.MachineStatusError)
+		**out = **in
+	}
+	if in.FailureMessage != nil {
+		in, out := &in.FailureMessage, &out.FailureMessage
+		*out = new(string)
+		**out = **in
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(v1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackMachineStatus.
+func (in *CloudStackMachineStatus) DeepCopy() *CloudStackMachineStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudStackMachineStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudStackMachineTemplate) DeepCopyInto(out *CloudStackMachineTemplate) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackMachineTemplate.
+func (in *CloudStackMachineTemplate) DeepCopy() *CloudStackMachineTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudStackMachineTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CloudStackMachineTemplate) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudStackMachineTemplateList) DeepCopyInto(out *CloudStackMachineTemplateList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]CloudStackMachineTemplate, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackMachineTemplateList.
+func (in *CloudStackMachineTemplateList) DeepCopy() *CloudStackMachineTemplateList {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudStackMachineTemplateList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CloudStackMachineTemplateList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudStackMachineTemplateResource) DeepCopyInto(out *CloudStackMachineTemplateResource) {
+	*out = *in
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackMachineTemplateResource.
+func (in *CloudStackMachineTemplateResource) DeepCopy() *CloudStackMachineTemplateResource {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudStackMachineTemplateResource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudStackMachineTemplateSpec) DeepCopyInto(out *CloudStackMachineTemplateSpec) {
+	*out = *in
+	in.Spec.DeepCopyInto(&out.Spec)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackMachineTemplateSpec.
@@ -714,6 +1299,33 @@ func (in *CloudStackMachineTemplateSpec) DeepCopy() *CloudStackMachineTemplateSp
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudStackMachineTemplateStatus) DeepCopyInto(out *CloudStackMachineTemplateStatus) {
+	*out = *in
+	if in.Capacity != nil {
+		in, out := &in.Capacity, &out.Capacity
+		*out = make(v1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.NodeInfo != nil {
+		in, out := &in.NodeInfo, &out.NodeInfo
+		*out = new(NodeInfo)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackMachineTemplateStatus.
+func (in *CloudStackMachineTemplateStatus) DeepCopy() *CloudStackMachineTemplateStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudStackMachineTemplateStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CloudStackResourceDiskOffering) DeepCopyInto(out *CloudStackResourceDiskOffering) {
 	*out = *in
@@ -748,7 +1360,17 @@ func (in *CloudStackResourceIdentifier) DeepCopy() *CloudStackResourceIdentifier
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CloudStackZoneSpec) DeepCopyInto(out *CloudStackZoneSpec) {
 	*out = *in
-	out.Network = in.Network
+	in.Network.DeepCopyInto(&out.Network)
+	if in.Pod != nil {
+		in, out := &in.Pod, &out.Pod
+		*out = new(CloudStackResourceIdentifier)
+		**out = **in
+	}
+	if in.Cluster != nil {
+		in, out := &in.Cluster, &out.Cluster
+		*out = new(CloudStackResourceIdentifier)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudStackZoneSpec.
@@ -761,9 +1383,195 @@ func (in *CloudStackZoneSpec) DeepCopy() *CloudStackZoneSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FailureDomainPlacement) DeepCopyInto(out *FailureDomainPlacement) {
+	*out = *in
+	if in.Weights != nil {
+		in, out := &in.Weights, &out.Weights
+		*out = make(map[string]int32, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FailureDomainPlacement.
+func (in *FailureDomainPlacement) DeepCopy() *FailureDomainPlacement {
+	if in == nil {
+		return nil
+	}
+	out := new(FailureDomainPlacement)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IPv6Route) DeepCopyInto(out *IPv6Route) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPv6Route.
+func (in *IPv6Route) DeepCopy() *IPv6Route {
+	if in == nil {
+		return nil
+	}
+	out := new(IPv6Route)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IPv6Spec) DeepCopyInto(out *IPv6Spec) {
+	*out = *in
+	if in.AllowedCIDRs != nil {
+		in, out := &in.AllowedCIDRs, &out.AllowedCIDRs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPv6Spec.
+func (in *IPv6Spec) DeepCopy() *IPv6Spec {
+	if in == nil {
+		return nil
+	}
+	out := new(IPv6Spec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LoadBalancerHealthCheckPolicy) DeepCopyInto(out *LoadBalancerHealthCheckPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadBalancerHealthCheckPolicy.
+func (in *LoadBalancerHealthCheckPolicy) DeepCopy() *LoadBalancerHealthCheckPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(LoadBalancerHealthCheckPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LoadBalancerMembership) DeepCopyInto(out *LoadBalancerMembership) {
+	*out = *in
+	if in.Rules != nil {
+		in, out := &in.Rules, &out.Rules
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadBalancerMembership.
+func (in *LoadBalancerMembership) DeepCopy() *LoadBalancerMembership {
+	if in == nil {
+		return nil
+	}
+	out := new(LoadBalancerMembership)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LoadBalancerPortMapping) DeepCopyInto(out *LoadBalancerPortMapping) {
+	*out = *in
+	if in.AllowedCIDRs != nil {
+		in, out := &in.AllowedCIDRs, &out.AllowedCIDRs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadBalancerPortMapping.
+func (in *LoadBalancerPortMapping) DeepCopy() *LoadBalancerPortMapping {
+	if in == nil {
+		return nil
+	}
+	out := new(LoadBalancerPortMapping)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LoadBalancerSpec) DeepCopyInto(out *LoadBalancerSpec) {
+	*out = *in
+	if in.Stickiness != nil {
+		in, out := &in.Stickiness, &out.Stickiness
+		*out = new(LoadBalancerStickinessPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HealthCheck != nil {
+		in, out := &in.HealthCheck, &out.HealthCheck
+		*out = new(LoadBalancerHealthCheckPolicy)
+		**out = **in
+	}
+	if in.AdditionalPorts != nil {
+		in, out := &in.AdditionalPorts, &out.AdditionalPorts
+		*out = make([]LoadBalancerPortMapping, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.AllowedCIDRs != nil {
+		in, out := &in.AllowedCIDRs, &out.AllowedCIDRs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadBalancerSpec.
+func (in *LoadBalancerSpec) DeepCopy() *LoadBalancerSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(LoadBalancerSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LoadBalancerStickinessPolicy) DeepCopyInto(out *LoadBalancerStickinessPolicy) {
+	*out = *in
+	if in.Params != nil {
+		in, out := &in.Params, &out.Params
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadBalancerStickinessPolicy.
+func (in *LoadBalancerStickinessPolicy) DeepCopy() *LoadBalancerStickinessPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(LoadBalancerStickinessPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Network) DeepCopyInto(out *Network) {
 	*out = *in
+	if in.Offering != nil {
+		in, out := &in.Offering, &out.Offering
+		*out = new(CloudStackResourceIdentifier)
+		**out = **in
+	}
+	if in.IPv6 != nil {
+		in, out := &in.IPv6, &out.IPv6
+		*out = new(IPv6Spec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.VPC != nil {
+		in, out := &in.VPC, &out.VPC
+		*out = new(VPC)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Network.
@@ -775,3 +1583,75 @@ func (in *Network) DeepCopy() *Network {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkACLRule) DeepCopyInto(out *NetworkACLRule) {
+	*out = *in
+	if in.CIDRs != nil {
+		in, out := &in.CIDRs, &out.CIDRs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkACLRule.
+func (in *NetworkACLRule) DeepCopy() *NetworkACLRule {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkACLRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeInfo) DeepCopyInto(out *NodeInfo) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeInfo.
+func (in *NodeInfo) DeepCopy() *NodeInfo {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeInfo)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RetainedDataDisk) DeepCopyInto(out *RetainedDataDisk) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RetainedDataDisk.
+func (in *RetainedDataDisk) DeepCopy() *RetainedDataDisk {
+	if in == nil {
+		return nil
+	}
+	out := new(RetainedDataDisk)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VPC) DeepCopyInto(out *VPC) {
+	*out = *in
+	if in.ACLRules != nil {
+		in, out := &in.ACLRules, &out.ACLRules
+		*out = make([]NetworkACLRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VPC.
+func (in *VPC) DeepCopy() *VPC {
+	if in == nil {
+		return nil
+	}
+	out := new(VPC)
+	in.DeepCopyInto(out)
+	return out
+}
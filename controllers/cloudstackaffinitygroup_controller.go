@@ -19,6 +19,8 @@ package controllers
 import (
 	"context"
 
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
@@ -61,15 +63,24 @@ func (reconciler *CloudStackAffinityGroupReconciler) Reconcile(ctx context.Conte
 	r.WithAdditionalCommonStages(
 		r.GetFailureDomainByName(func() string { return r.ReconciliationSubject.Spec.FailureDomainName }, r.FailureDomain),
 		r.AsFailureDomainUser(&r.FailureDomain.Spec))
+	r.WithReadySummary(infrav1.AffinityGroupReadyCondition)
 	return r.RunBaseReconciliationStages()
 }
 
 func (r *CloudStackAGReconciliationRunner) Reconcile() (ctrl.Result, error) {
 	controllerutil.AddFinalizer(r.ReconciliationSubject, infrav1.AffinityGroupFinalizer)
 	affinityGroup := &cloud.AffinityGroup{Name: r.ReconciliationSubject.Spec.Name, Type: r.ReconciliationSubject.Spec.Type}
-	if err := r.CSUser.GetOrCreateAffinityGroup(affinityGroup); err != nil {
+	if err := r.CSUser.GetOrCreateAffinityGroup(r.RequestCtx, affinityGroup); err != nil {
+		conditions.MarkFalse(r.ReconciliationSubject, infrav1.AffinityGroupReadyCondition,
+			infrav1.AffinityGroupFailedReason, clusterv1.ConditionSeverityWarning, "%s", err.Error())
 		return ctrl.Result{}, err
 	}
+	if err := r.CSUser.ReconcileTags(
+		r.RequestCtx,
+		cloud.ResourceTypeAffinityGroup, affinityGroup.ID, cloud.ClusterResourceTags(r.CSCluster, nil)); err != nil {
+		return ctrl.Result{}, err
+	}
+	conditions.MarkTrue(r.ReconciliationSubject, infrav1.AffinityGroupReadyCondition)
 	r.ReconciliationSubject.Spec.ID = affinityGroup.ID
 	r.ReconciliationSubject.Status.Ready = true
 	return ctrl.Result{}, nil
@@ -77,11 +88,11 @@ func (r *CloudStackAGReconciliationRunner) Reconcile() (ctrl.Result, error) {
 
 func (r *CloudStackAGReconciliationRunner) ReconcileDelete() (ctrl.Result, error) {
 	group := &cloud.AffinityGroup{Name: r.ReconciliationSubject.Name}
-	_ = r.CSUser.FetchAffinityGroup(group)
+	_ = r.CSUser.FetchAffinityGroup(r.RequestCtx, group)
 	if group.ID == "" { // Affinity group not found, must have been deleted.
 		return ctrl.Result{}, nil
 	}
-	if err := r.CSUser.DeleteAffinityGroup(group); err != nil {
+	if err := r.CSUser.DeleteAffinityGroup(r.RequestCtx, group); err != nil {
 		return ctrl.Result{}, err
 	}
 	controllerutil.RemoveFinalizer(r.ReconciliationSubject, infrav1.AffinityGroupFinalizer)
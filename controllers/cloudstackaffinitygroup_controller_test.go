@@ -39,7 +39,8 @@ var _ = Describe("CloudStackAffinityGroupReconciler", func() {
 		Ω(k8sClient.Create(ctx, dummies.CSFailureDomain1))
 		Ω(k8sClient.Create(ctx, dummies.CSAffinityGroup)).Should(Succeed())
 
-		mockCloudClient.EXPECT().GetOrCreateAffinityGroup(gomock.Any()).AnyTimes()
+		mockCloudClient.EXPECT().GetOrCreateAffinityGroup(gomock.Any(), gomock.Any()).AnyTimes()
+		mockCloudClient.EXPECT().ReconcileTags(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
 
 		// Test that the AffinityGroup controller sets Status.Ready to true.
 		Eventually(func() bool {
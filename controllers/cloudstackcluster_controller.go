@@ -33,6 +33,7 @@ import (
 	csCtrlrUtils "sigs.k8s.io/cluster-api-provider-cloudstack/controllers/utils"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
 )
 
 // RBAC permissions used in all reconcilers. Events and Secrets.
@@ -81,15 +82,16 @@ func (reconciler *CloudStackClusterReconciler) Reconcile(ctx context.Context, re
 		UsingBaseReconciler(reconciler.ReconcilerBase).
 		ForRequest(req).
 		WithRequestCtx(ctx).
+		WithReadySummary(infrav1.FailureDomainsReadyCondition).
 		RunBaseReconciliationStages()
 }
 
 // Reconcile actually reconciles the CloudStackCluster.
 func (r *CloudStackClusterReconciliationRunner) Reconcile() (res ctrl.Result, reterr error) {
 	return r.RunReconciliationStages(
-		r.SetFailureDomainsStatusMap,
 		r.CreateFailureDomains(r.ReconciliationSubject.Spec.FailureDomains),
 		r.GetFailureDomains(r.FailureDomains),
+		r.SetFailureDomainsStatusMap,
 		r.RemoveExtraneousFailureDomains(r.FailureDomains),
 		r.VerifyFailureDomainCRDs,
 		r.SetReady)
@@ -111,29 +113,63 @@ func (r *CloudStackClusterReconciliationRunner) VerifyFailureDomainCRDs() (ctrl.
 			if requiredFdSpec.Name == fd.Spec.Name {
 				found = true
 				if !fd.Status.Ready {
+					conditions.MarkFalse(r.ReconciliationSubject, infrav1.FailureDomainsReadyCondition,
+						infrav1.WaitingForFailureDomainsReason, clusterv1.ConditionSeverityInfo,
+						"Failure domain %s is not ready", fd.Spec.Name)
 					return r.RequeueWithMessage(fmt.Sprintf("Required FailureDomain %s not ready, requeueing.", fd.Spec.Name))
 				}
 				break
 			}
 		}
 		if !found {
+			conditions.MarkFalse(r.ReconciliationSubject, infrav1.FailureDomainsReadyCondition,
+				infrav1.WaitingForFailureDomainsReason, clusterv1.ConditionSeverityInfo,
+				"Failure domain %s is not created yet", requiredFdSpec.Name)
 			return r.RequeueWithMessage(fmt.Sprintf("Required FailureDomain %s not found, requeueing.", requiredFdSpec.Name))
 		}
 	}
+	conditions.MarkTrue(r.ReconciliationSubject, infrav1.FailureDomainsReadyCondition)
 	return ctrl.Result{}, nil
 }
 
 // SetFailureDomainsStatusMap sets failure domains in CloudStackCluster status to be used for CAPI machine placement.
+// Failure domains last probed unhealthy are left out, so CAPI places no new machines in them, and only those eligible
+// for the control plane are marked so.
 func (r *CloudStackClusterReconciliationRunner) SetFailureDomainsStatusMap() (ctrl.Result, error) {
+	previous := r.ReconciliationSubject.Status.FailureDomains
 	r.ReconciliationSubject.Status.FailureDomains = clusterv1.FailureDomains{}
 	for _, fdSpec := range r.ReconciliationSubject.Spec.FailureDomains {
+		_, wasListed := previous[fdSpec.Name]
+		if fd := r.failureDomainNamed(fdSpec.Name); fd != nil && conditions.IsFalse(fd, infrav1.FailureDomainHealthyCondition) {
+			if wasListed {
+				r.Recorder.Eventf(r.ReconciliationSubject, "Warning", "FailureDomainUnavailable",
+					"Failure domain %s left out of machine placement: %s: %s", fdSpec.Name,
+					conditions.GetReason(fd, infrav1.FailureDomainHealthyCondition),
+					conditions.GetMessage(fd, infrav1.FailureDomainHealthyCondition))
+			}
+			continue
+		}
+		if previous != nil && !wasListed {
+			r.Recorder.Eventf(r.ReconciliationSubject, "Normal", "FailureDomainAvailable",
+				"Failure domain %s available for machine placement", fdSpec.Name)
+		}
 		metaHashName := infrav1.FailureDomainHashedMetaName(fdSpec.Name, r.CAPICluster.Name)
 		r.ReconciliationSubject.Status.FailureDomains[fdSpec.Name] = clusterv1.FailureDomainSpec{
-			ControlPlane: true, Attributes: map[string]string{"MetaHashName": metaHashName}}
+			ControlPlane: fdSpec.ControlPlaneEligible(), Attributes: map[string]string{"MetaHashName": metaHashName}}
 	}
 	return ctrl.Result{}, nil
 }
 
+// failureDomainNamed returns the fetched CloudStackFailureDomain of the given name, or nil if it wasn't found.
+func (r *CloudStackClusterReconciliationRunner) failureDomainNamed(name string) *infrav1.CloudStackFailureDomain {
+	for i := range r.FailureDomains.Items {
+		if r.FailureDomains.Items[i].Spec.Name == name {
+			return &r.FailureDomains.Items[i]
+		}
+	}
+	return nil
+}
+
 // ReconcileDelete cleans up resources used by the cluster and finally removes the CloudStackCluster's finalizers.
 func (r *CloudStackClusterReconciliationRunner) ReconcileDelete() (ctrl.Result, error) {
 	r.Log.Info("Deleting CloudStackCluster.")
@@ -195,5 +231,23 @@ func (reconciler *CloudStackClusterReconciler) SetupWithManager(ctx context.Cont
 			},
 			DeleteFunc: func(e event.DeleteEvent) bool { return false },
 			CreateFunc: func(e event.CreateEvent) bool { return false }})
+	if err != nil {
+		return errors.Wrap(err, "building CloudStackCluster controller")
+	}
+
+	// Add a watch on CloudStackFailureDomains to take them out of and back into machine placement as their health
+	// changes.
+	err = controller.Watch(
+		&source.Kind{Type: &infrav1.CloudStackFailureDomain{}},
+		&handler.EnqueueRequestForOwner{OwnerType: &infrav1.CloudStackCluster{}, IsController: true},
+		predicate.Funcs{
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				oldFD := e.ObjectOld.(*infrav1.CloudStackFailureDomain)
+				newFD := e.ObjectNew.(*infrav1.CloudStackFailureDomain)
+				return conditions.IsFalse(oldFD, infrav1.FailureDomainHealthyCondition) !=
+					conditions.IsFalse(newFD, infrav1.FailureDomainHealthyCondition)
+			},
+			DeleteFunc: func(e event.DeleteEvent) bool { return false },
+			CreateFunc: func(e event.CreateEvent) bool { return false }})
 	return errors.Wrap(err, "building CloudStackCluster controller")
 }
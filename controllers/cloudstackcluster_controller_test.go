@@ -20,9 +20,13 @@ import (
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"k8s.io/utils/pointer"
 	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
 	"sigs.k8s.io/cluster-api-provider-cloudstack/controllers"
 	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta2"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -36,7 +40,8 @@ var _ = Describe("CloudStackClusterReconciler", func() {
 
 		It("Should create a CloudStackFailureDomain.", func() {
 			tempfd := &infrav1.CloudStackFailureDomain{}
-			mockCloudClient.EXPECT().ResolveZone(gomock.Any()).AnyTimes()
+			mockCloudClient.EXPECT().ResolveZone(gomock.Any(), gomock.Any()).AnyTimes()
+			mockCloudClient.EXPECT().ProbeFailureDomain(gomock.Any(), gomock.Any()).AnyTimes()
 			Eventually(func() bool {
 				key := client.ObjectKeyFromObject(dummies.CSFailureDomain1)
 				key.Name = key.Name + "-" + dummies.CSCluster.Name
@@ -48,6 +53,60 @@ var _ = Describe("CloudStackClusterReconciler", func() {
 		})
 	})
 
+	Context("With a fake ctrlRuntimeClient and a CloudStack simulator.", func() {
+		var clusterKey client.ObjectKey
+
+		BeforeEach(func() {
+			setupSimulatorTestClient()
+			clusterKey = client.ObjectKeyFromObject(dummies.CSCluster)
+		})
+
+		It("Should leave failure domains that can't take new machines out of machine placement.", func() {
+			Ω(fakeCtrlClient.Get(ctx, clusterKey, dummies.CSCluster)).Should(Succeed())
+			dummies.CSCluster.Spec.FailureDomains[1].ControlPlane = pointer.Bool(false)
+			Ω(fakeCtrlClient.Update(ctx, dummies.CSCluster)).Should(Succeed())
+			_, err := ClusterReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: clusterKey})
+			Ω(err).ShouldNot(HaveOccurred())
+			csCluster := &infrav1.CloudStackCluster{}
+			Ω(fakeCtrlClient.Get(ctx, clusterKey, csCluster)).Should(Succeed())
+			Ω(conditions.GetReason(csCluster, infrav1.FailureDomainsReadyCondition)).Should(
+				Equal(infrav1.WaitingForFailureDomainsReason))
+			Ω(conditions.IsFalse(csCluster, clusterv1.ReadyCondition)).Should(BeTrue())
+
+			// Probe the failure domain the cluster reconciler created for the simulated zone.
+			fdKey := client.ObjectKey{Namespace: dummies.CSCluster.Namespace,
+				Name: infrav1.FailureDomainHashedMetaName("fd1", dummies.CAPICluster.Name)}
+			fd := &infrav1.CloudStackFailureDomain{}
+			for i := 0; i < 2; i++ {
+				res, err := FailureDomainReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: fdKey})
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(res.RequeueAfter).Should(Equal(controllers.DefaultFailureDomainHealthProbeInterval))
+			}
+			Ω(fakeCtrlClient.Get(ctx, fdKey, fd)).Should(Succeed())
+			Ω(conditions.IsTrue(fd, infrav1.FailureDomainHealthyCondition)).Should(BeTrue())
+			Ω(conditions.IsTrue(fd, infrav1.NetworkReadyCondition)).Should(BeTrue())
+			Ω(conditions.IsTrue(fd, clusterv1.ReadyCondition)).Should(BeTrue())
+
+			Ω(fakeCtrlClient.Get(ctx, clusterKey, csCluster)).Should(Succeed())
+			Ω(csCluster.Status.FailureDomains).Should(HaveKeyWithValue("fd1", HaveField("ControlPlane", BeTrue())))
+			Ω(csCluster.Status.FailureDomains).Should(HaveKeyWithValue("fd2", HaveField("ControlPlane", BeFalse())))
+
+			sim.SetZoneAllocationState(dummies.Zone1.ID, "Disabled")
+			_, err = FailureDomainReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: fdKey})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(fakeCtrlClient.Get(ctx, fdKey, fd)).Should(Succeed())
+			Ω(conditions.GetReason(fd, infrav1.FailureDomainHealthyCondition)).Should(Equal(infrav1.ZoneDisabledReason))
+
+			_, err = ClusterReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: clusterKey})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(fakeCtrlClient.Get(ctx, clusterKey, csCluster)).Should(Succeed())
+			Ω(csCluster.Status.FailureDomains).ShouldNot(HaveKey("fd1"))
+			Ω(csCluster.Status.FailureDomains).Should(HaveKey("fd2"))
+			Ω(<-fakeRecorder.Events).Should(ContainSubstring("Warning FailureDomainUnavailable Failure domain fd1 left out " +
+				"of machine placement: ZoneDisabled"))
+		})
+	})
+
 	Context("Without a k8s test environment.", func() {
 		It("Should create a reconciliation runner with a Cloudstack Cluster as the reconciliation subject.", func() {
 			reconRunenr := controllers.NewCSClusterReconciliationRunner()
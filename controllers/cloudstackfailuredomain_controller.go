@@ -18,17 +18,25 @@ package controllers
 
 import (
 	"context"
+	"time"
+
 	"github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 	"sort"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
 	csCtrlrUtils "sigs.k8s.io/cluster-api-provider-cloudstack/controllers/utils"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
 )
 
 const (
@@ -36,10 +44,15 @@ const (
 	conditionStatusFalse = "False"
 )
 
+// DefaultFailureDomainHealthProbeInterval is how often failure domains are probed unless configured otherwise.
+const DefaultFailureDomainHealthProbeInterval = 5 * time.Minute
+
 // CloudStackFailureDomainReconciler is the k8s controller manager's interface to reconcile a CloudStackFailureDomain.
 // This is primarily to adapt to k8s.
 type CloudStackFailureDomainReconciler struct {
 	csCtrlrUtils.ReconcilerBase
+	// HealthProbeInterval is how often ready failure domains are probed for whether they can take new machines.
+	HealthProbeInterval time.Duration
 }
 
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=cloudstackfailuredomains,verbs=get;list;watch;create;update;patch;delete
@@ -59,6 +72,7 @@ type CloudStackFailureDomainReconciliationRunner struct {
 	ReconciliationSubject *infrav1.CloudStackFailureDomain
 	IsoNet                *infrav1.CloudStackIsolatedNetwork
 	Machines              []infrav1.CloudStackMachine
+	HealthProbeInterval   time.Duration
 }
 
 // Initialize a new CloudStackFailureDomain reconciliation runner with concrete types and initialized member fields.
@@ -74,16 +88,26 @@ func NewCSFailureDomainReconciliationRunner() *CloudStackFailureDomainReconcilia
 
 // Reconcile is the method k8s will call upon a reconciliation request.
 func (reconciler *CloudStackFailureDomainReconciler) Reconcile(ctx context.Context, req ctrl.Request) (retRes ctrl.Result, retErr error) {
-	return NewCSFailureDomainReconciliationRunner().
+	r := NewCSFailureDomainReconciliationRunner()
+	r.HealthProbeInterval = reconciler.HealthProbeInterval
+	if r.HealthProbeInterval <= 0 {
+		r.HealthProbeInterval = DefaultFailureDomainHealthProbeInterval
+	}
+	return r.
 		UsingBaseReconciler(reconciler.ReconcilerBase).
 		ForRequest(req).
 		WithRequestCtx(ctx).
+		WithReadySummary(
+			infrav1.NetworkReadyCondition, infrav1.LoadBalancerReadyCondition, infrav1.FailureDomainHealthyCondition).
 		RunBaseReconciliationStages()
 }
 
 // Reconcile on the ReconciliationRunner actually attempts to modify or create the reconciliation subject.
 func (r *CloudStackFailureDomainReconciliationRunner) Reconcile() (retRes ctrl.Result, retErr error) {
 	res, err := r.AsFailureDomainUser(&r.ReconciliationSubject.Spec)()
+	if err != nil {
+		r.markUnhealthy(infrav1.APIUnreachableReason, err)
+	}
 	if r.ShouldReturn(res, err) {
 		return res, err
 	}
@@ -91,18 +115,27 @@ func (r *CloudStackFailureDomainReconciliationRunner) Reconcile() (retRes ctrl.R
 	controllerutil.AddFinalizer(r.ReconciliationSubject, infrav1.FailureDomainFinalizer)
 
 	// Start by purely data fetching information about the zone and specified network.
-	if err := r.CSUser.ResolveZone(&r.ReconciliationSubject.Spec.Zone); err != nil {
-		return ctrl.Result{}, errors.Wrap(err, "resolving CloudStack zone information")
+	if err := r.CSUser.ResolveZone(r.RequestCtx, &r.ReconciliationSubject.Spec.Zone); err != nil {
+		return r.markNetworkFailed(infrav1.ZoneDisabledReason, errors.Wrap(err, "resolving CloudStack zone information"))
 	}
-	if err := r.CSUser.ResolveNetworkForZone(&r.ReconciliationSubject.Spec.Zone); err != nil &&
-		!csCtrlrUtils.ContainsNoMatchSubstring(err) {
-		return ctrl.Result{}, errors.Wrap(err, "resolving Cloudstack network information")
+	// Only the endpoint's credentials may be allowed to list pods and clusters.
+	if zone := &r.ReconciliationSubject.Spec.Zone; zone.Pod != nil || zone.Cluster != nil {
+		if err := r.CSClient.ResolveZoneScope(r.RequestCtx, zone); err != nil {
+			return r.markNetworkFailed(infrav1.ZoneDisabledReason,
+				errors.Wrap(err, "resolving CloudStack pod and cluster information"))
+		}
+	}
+	if err := r.CSUser.ResolveNetworkForZone(r.RequestCtx, &r.ReconciliationSubject.Spec.Zone); err != nil &&
+		!cloud.IsNotFoundError(err) {
+		return r.markNetworkFailed(infrav1.NetworkUnavailableReason,
+			errors.Wrap(err, "resolving Cloudstack network information"))
 	}
 
-	// Check if the passed network was an isolated network or the network was missing. In either case, create a
-	// CloudStackIsolatedNetwork to manage the many intricacies and wait until CloudStackIsolatedNetwork is ready.
+	// Check if the passed network was an isolated network, a VPC tier, or the network was missing. In any case, create
+	// a CloudStackIsolatedNetwork to manage the many intricacies and wait until CloudStackIsolatedNetwork is ready.
 	if r.ReconciliationSubject.Spec.Zone.Network.ID == "" ||
-		r.ReconciliationSubject.Spec.Zone.Network.Type == infrav1.NetworkTypeIsolated {
+		r.ReconciliationSubject.Spec.Zone.Network.Type == infrav1.NetworkTypeIsolated ||
+		r.ReconciliationSubject.Spec.Zone.Network.Type == infrav1.NetworkTypeVPC {
 		netName := r.ReconciliationSubject.Spec.Zone.Network.Name
 		if res, err := r.GenerateIsolatedNetwork(
 			netName, func() string { return r.ReconciliationSubject.Spec.Name })(); r.ShouldReturn(res, err) {
@@ -110,6 +143,11 @@ func (r *CloudStackFailureDomainReconciliationRunner) Reconcile() (retRes ctrl.R
 		} else if res, err := r.GetObjectByName(r.IsoNetMetaName(netName), r.IsoNet)(); r.ShouldReturn(res, err) {
 			return res, err
 		}
+		if r.IsoNet.Name == "" || !r.IsoNet.Status.Ready {
+			conditions.MarkFalse(r.ReconciliationSubject, infrav1.NetworkReadyCondition,
+				infrav1.WaitingForIsolatedNetworkReason, clusterv1.ConditionSeverityInfo,
+				"Waiting for isolated network %s", netName)
+		}
 		if r.IsoNet.Name == "" {
 			return r.RequeueWithMessage("Couldn't find isolated network.")
 		}
@@ -117,8 +155,52 @@ func (r *CloudStackFailureDomainReconciliationRunner) Reconcile() (retRes ctrl.R
 			return r.RequeueWithMessage("Isolated network dependency not ready.")
 		}
 	}
+	conditions.MarkTrue(r.ReconciliationSubject, infrav1.NetworkReadyCondition)
+	if res, err := r.GetOrCreateControlPlaneEndpoint(); r.ShouldReturn(res, err) {
+		return res, err
+	}
 	r.ReconciliationSubject.Status.Ready = true
-	return ctrl.Result{}, nil
+	return r.ProbeHealth()
+}
+
+// markNetworkFailed records an error resolving the failure domain's zone or network in its NetworkReady and Healthy
+// conditions and returns it. Resources that aren't found are reported with notFoundReason.
+func (r *CloudStackFailureDomainReconciliationRunner) markNetworkFailed(notFoundReason string, err error) (ctrl.Result, error) {
+	conditions.MarkFalse(r.ReconciliationSubject, infrav1.NetworkReadyCondition,
+		infrav1.NetworkFailedReason, clusterv1.ConditionSeverityWarning, "%s", err.Error())
+	if cloud.IsNotFoundError(err) {
+		r.markUnhealthy(notFoundReason, err)
+	} else {
+		r.markUnhealthy(infrav1.APIUnreachableReason, err)
+	}
+	return ctrl.Result{}, err
+}
+
+// markUnhealthy records an error that kept the failure domain from being probed in its Healthy condition, so machines
+// aren't placed in it until it's resolved again.
+func (r *CloudStackFailureDomainReconciliationRunner) markUnhealthy(reason string, err error) {
+	severity := clusterv1.ConditionSeverityWarning
+	if reason != infrav1.APIUnreachableReason {
+		severity = clusterv1.ConditionSeverityError
+	}
+	conditions.MarkFalse(r.ReconciliationSubject, infrav1.FailureDomainHealthyCondition, reason, severity, "%s", err.Error())
+}
+
+// ProbeHealth checks whether the failure domain can take new machines, with its credentials, and records the outcome
+// in its Healthy condition. Failure domains are probed again after the health probe interval, as zones may be
+// disabled, networks shut down, or accounts run out of room at any time.
+func (r *CloudStackFailureDomainReconciliationRunner) ProbeHealth() (ctrl.Result, error) {
+	problem := &cloud.FailureDomainProblem{}
+	if err := r.CSUser.ProbeFailureDomain(r.RequestCtx, &r.ReconciliationSubject.Spec); err == nil {
+		conditions.MarkTrue(r.ReconciliationSubject, infrav1.FailureDomainHealthyCondition)
+	} else if errors.As(err, &problem) {
+		conditions.MarkFalse(r.ReconciliationSubject, infrav1.FailureDomainHealthyCondition,
+			problem.Reason, problem.Severity, "%s", problem.Message)
+	} else {
+		conditions.MarkFalse(r.ReconciliationSubject, infrav1.FailureDomainHealthyCondition,
+			infrav1.APIUnreachableReason, clusterv1.ConditionSeverityWarning, "%s", err.Error())
+	}
+	return ctrl.Result{RequeueAfter: r.HealthProbeInterval}, nil
 }
 
 // ReconcileDelete on the ReconciliationRunner attempts to delete the reconciliation subject.
@@ -131,15 +213,69 @@ func (r *CloudStackFailureDomainReconciliationRunner) ReconcileDelete() (ctrl.Re
 		r.RequeueIfMachineCannotBeRemoved,
 		r.ClearMachines,
 		r.DeleteOwnedObjects(
-			infrav1.GroupVersion.WithKind("CloudStackAffinityGroup"),
-			infrav1.GroupVersion.WithKind("CloudStackIsolatedNetwork")),
+			infrav1.GroupVersion.WithKind("CloudStackLoadBalancer"),
+			infrav1.GroupVersion.WithKind("CloudStackAffinityGroup")),
 		r.CheckOwnedObjectsDeleted(
-			infrav1.GroupVersion.WithKind("CloudStackAffinityGroup"),
-			infrav1.GroupVersion.WithKind("CloudStackIsolatedNetwork")),
+			infrav1.GroupVersion.WithKind("CloudStackLoadBalancer"),
+			infrav1.GroupVersion.WithKind("CloudStackAffinityGroup")),
+		r.DeleteOwnedObjects(infrav1.GroupVersion.WithKind("CloudStackIsolatedNetwork")),
+		r.CheckOwnedObjectsDeleted(infrav1.GroupVersion.WithKind("CloudStackIsolatedNetwork")),
+		r.DisposeControlPlaneEndpoint,
 		r.RemoveFinalizer,
 	)
 }
 
+// GetOrCreateControlPlaneEndpoint sets up the control plane endpoint on the failure domain's network when the
+// CloudStackCluster selects the NetworkLoadBalancer provider, and records the endpoint on the CloudStackCluster.
+// The IsolatedNetwork provider's endpoint is set up by the CloudStackIsolatedNetwork controller instead.
+func (r *CloudStackFailureDomainReconciliationRunner) GetOrCreateControlPlaneEndpoint() (ctrl.Result, error) {
+	if r.CSCluster.ControlPlaneEndpointProviderFor(&r.ReconciliationSubject.Spec) != infrav1.EndpointProviderNetworkLoadBalancer {
+		return ctrl.Result{}, nil
+	}
+	csClusterPatcher, err := patch.NewHelper(r.CSCluster, r.K8sClient)
+	if err != nil {
+		return r.ReturnWrappedError(err, "setting up CloudStackCluster patcher")
+	}
+	provider, err := r.CSUser.ControlPlaneEndpointProvider(r.CSCluster, r.ReconciliationSubject, nil)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	err = provider.GetOrCreateControlPlaneEndpoint(r.RequestCtx)
+	if cloud.IsJobPending(err) {
+		// The endpoint host the address being associated is found by on requeue must be kept.
+		if err := csClusterPatcher.Patch(r.RequestCtx, r.CSCluster); err != nil {
+			return r.ReturnWrappedError(err, "patching endpoint update to CloudStackCluster")
+		}
+		conditions.MarkFalse(r.ReconciliationSubject, infrav1.LoadBalancerReadyCondition,
+			infrav1.AsyncJobPendingReason, clusterv1.ConditionSeverityInfo, "%s", err.Error())
+		return ctrl.Result{}, err
+	} else if err != nil {
+		conditions.MarkFalse(r.ReconciliationSubject, infrav1.LoadBalancerReadyCondition,
+			infrav1.LoadBalancerFailedReason, clusterv1.ConditionSeverityWarning, "%s", err.Error())
+		return r.ReturnWrappedError(err, "getting or creating control plane endpoint")
+	}
+	if err := csClusterPatcher.Patch(r.RequestCtx, r.CSCluster); err != nil {
+		return r.ReturnWrappedError(err, "patching endpoint update to CloudStackCluster")
+	}
+	conditions.MarkTrue(r.ReconciliationSubject, infrav1.LoadBalancerReadyCondition)
+	return ctrl.Result{}, nil
+}
+
+// DisposeControlPlaneEndpoint releases the control plane endpoint set up on the failure domain's network.
+func (r *CloudStackFailureDomainReconciliationRunner) DisposeControlPlaneEndpoint() (ctrl.Result, error) {
+	if r.CSCluster.ControlPlaneEndpointProviderFor(&r.ReconciliationSubject.Spec) != infrav1.EndpointProviderNetworkLoadBalancer {
+		return ctrl.Result{}, nil
+	}
+	if res, err := r.AsFailureDomainUser(&r.ReconciliationSubject.Spec)(); r.ShouldReturn(res, err) {
+		return res, err
+	}
+	provider, err := r.CSUser.ControlPlaneEndpointProvider(r.CSCluster, r.ReconciliationSubject, nil)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, errors.Wrap(provider.DisposeControlPlaneEndpoint(r.RequestCtx), "disposing of control plane endpoint")
+}
+
 // GetAllMachinesInFailureDomain returns all cloudstackmachines deployed in this failure domain sorted by name.
 func (r *CloudStackFailureDomainReconciliationRunner) GetAllMachinesInFailureDomain() (ctrl.Result, error) {
 	machines := &infrav1.CloudStackMachineList{}
@@ -264,6 +400,19 @@ func (r *CloudStackFailureDomainReconciliationRunner) RemoveFinalizer() (ctrl.Re
 
 // SetupWithManager sets up the controller with the Manager.
 func (reconciler *CloudStackFailureDomainReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	_, err := ctrl.NewControllerManagedBy(mgr).For(&infrav1.CloudStackFailureDomain{}).Build(reconciler)
+	// Reapply the load balancer configuration of network load balanced control plane endpoints when it changes.
+	csClusterToFDs, err := csCtrlrUtils.CloudStackClusterToObjectsMapper(
+		reconciler.K8sClient, &infrav1.CloudStackFailureDomainList{}, mgr.GetScheme())
+	if err != nil {
+		return err
+	}
+	_, err = ctrl.NewControllerManagedBy(mgr).
+		For(&infrav1.CloudStackFailureDomain{}).
+		Watches(
+			&source.Kind{Type: &infrav1.CloudStackCluster{}},
+			handler.EnqueueRequestsFromMapFunc(csClusterToFDs),
+			builder.WithPredicates(csCtrlrUtils.CloudStackClusterLoadBalancerChanged),
+		).
+		Build(reconciler)
 	return err
 }
@@ -25,8 +25,11 @@ import (
 	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
 	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
 	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta2"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/test/simulator"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -42,10 +45,11 @@ var _ = Describe("CloudStackFailureDomainReconciler", func() {
 			Ω(k8sClient.Create(ctx, dummies.ACSEndpointSecret1))
 			Ω(k8sClient.Create(ctx, dummies.CSFailureDomain1))
 
-			mockCloudClient.EXPECT().ResolveZone(gomock.Any()).MinTimes(1)
+			mockCloudClient.EXPECT().ResolveZone(gomock.Any(), gomock.Any()).MinTimes(1)
+			mockCloudClient.EXPECT().ProbeFailureDomain(gomock.Any(), gomock.Any()).AnyTimes()
 
-			mockCloudClient.EXPECT().ResolveNetworkForZone(gomock.Any()).AnyTimes().Do(
-				func(arg1 interface{}) {
+			mockCloudClient.EXPECT().ResolveNetworkForZone(gomock.Any(), gomock.Any()).AnyTimes().Do(
+				func(_, arg1 interface{}) {
 					arg1.(*infrav1.CloudStackZoneSpec).Network.ID = "SomeID"
 					arg1.(*infrav1.CloudStackZoneSpec).Network.Type = cloud.NetworkTypeShared
 				}).MinTimes(1)
@@ -129,6 +133,71 @@ var _ = Describe("CloudStackFailureDomainReconciler", func() {
 			Entry("Should not delete machine if status.readyReplicas <> status.replicas", false, pointer.Int32(2), pointer.Int32(2), pointer.Int32(1), pointer.Bool(true), true),
 		)
 	})
+
+	Context("With a fake ctrlRuntimeClient and a CloudStack simulator.", func() {
+		var fdKey client.ObjectKey
+
+		BeforeEach(func() {
+			setupSimulatorTestClient()
+			lbNet := sim.AddNetworkWithOffering(
+				dummies.Zone1.ID, "elb-network", simulator.SharedLBNetworkOffering, "10.30.0.0/24")
+			dummies.CSFailureDomain1.Spec.Zone.Network = infrav1.Network{Name: lbNet.Name}
+			dummies.CSFailureDomain1.Name = dummies.CSFailureDomain1.Name + "-" + dummies.CSCluster.Name
+			Ω(fakeCtrlClient.Create(ctx, dummies.CSFailureDomain1)).Should(Succeed())
+			fdKey = client.ObjectKeyFromObject(dummies.CSFailureDomain1)
+
+			Ω(fakeCtrlClient.Get(ctx, client.ObjectKeyFromObject(dummies.CSCluster), dummies.CSCluster)).Should(Succeed())
+			dummies.CSCluster.Spec.ControlPlaneEndpointProvider = infrav1.EndpointProviderNetworkLoadBalancer
+			dummies.CSCluster.Spec.ControlPlaneEndpoint.Host = ""
+			Ω(fakeCtrlClient.Update(ctx, dummies.CSCluster)).Should(Succeed())
+		})
+
+		It("Should load balance the control plane on the failure domain's network and release it on deletion.", func() {
+			for i := 0; i < 2; i++ {
+				_, err := FailureDomainReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: fdKey})
+				Ω(err).ShouldNot(HaveOccurred())
+			}
+			Ω(sim.LoadBalancerRules()).Should(HaveLen(1))
+
+			csCluster := &infrav1.CloudStackCluster{}
+			Ω(fakeCtrlClient.Get(ctx, client.ObjectKeyFromObject(dummies.CSCluster), csCluster)).Should(Succeed())
+			Ω(csCluster.Spec.ControlPlaneEndpoint.Host).Should(Equal(sim.LoadBalancerRules()[0].Publicip))
+			fd := &infrav1.CloudStackFailureDomain{}
+			Ω(fakeCtrlClient.Get(ctx, fdKey, fd)).Should(Succeed())
+			Ω(fd.Status.Ready).Should(BeTrue())
+
+			Ω(fakeCtrlClient.Delete(ctx, fd)).Should(Succeed())
+			_, err := FailureDomainReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: fdKey})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(sim.LoadBalancerRules()).Should(BeEmpty())
+			Ω(fakeCtrlClient.Get(ctx, fdKey, fd)).ShouldNot(Succeed())
+		})
+
+		It("Should mark the failure domain unhealthy when its zone can't be resolved.", func() {
+			_, err := FailureDomainReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: fdKey})
+			Ω(err).ShouldNot(HaveOccurred())
+			fd := &infrav1.CloudStackFailureDomain{}
+			Ω(fakeCtrlClient.Get(ctx, fdKey, fd)).Should(Succeed())
+			Ω(conditions.IsTrue(fd, infrav1.FailureDomainHealthyCondition)).Should(BeTrue())
+
+			for i := 0; i < 2; i++ {
+				sim.FailNext("listZones", simulator.NewAPIError(simulator.ErrorCodeInternalError, "Management server down"))
+			}
+			_, err = FailureDomainReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: fdKey})
+			Ω(err).Should(HaveOccurred())
+			Ω(fakeCtrlClient.Get(ctx, fdKey, fd)).Should(Succeed())
+			Ω(conditions.GetReason(fd, infrav1.FailureDomainHealthyCondition)).Should(Equal(infrav1.APIUnreachableReason))
+
+			fd.Spec.Zone.ID = "00000000-0000-4000-8000-999999999999"
+			fd.Spec.Zone.Name = "gone-zone"
+			Ω(fakeCtrlClient.Update(ctx, fd)).Should(Succeed())
+			_, err = FailureDomainReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: fdKey})
+			Ω(err).Should(HaveOccurred())
+			Ω(fakeCtrlClient.Get(ctx, fdKey, fd)).Should(Succeed())
+			Ω(conditions.GetReason(fd, infrav1.FailureDomainHealthyCondition)).Should(Equal(infrav1.ZoneDisabledReason))
+			Ω(conditions.IsFalse(fd, clusterv1.ReadyCondition)).Should(BeTrue())
+		})
+	})
 })
 
 func getFailuredomainStatus(failureDomain *infrav1.CloudStackFailureDomain) bool {
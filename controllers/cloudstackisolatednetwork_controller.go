@@ -18,11 +18,15 @@ package controllers
 
 import (
 	"context"
-	"strings"
 
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/pkg/errors"
 	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
@@ -63,6 +67,8 @@ func (reconciler *CloudStackIsoNetReconciler) Reconcile(ctx context.Context, req
 		r.GetFailureDomainByName(func() string { return r.ReconciliationSubject.Spec.FailureDomainName }, r.FailureDomain),
 		r.AsFailureDomainUser(&r.FailureDomain.Spec),
 	)
+	r.WithReadySummary(
+		infrav1.NetworkReadyCondition, infrav1.PublicIPAssociatedCondition, infrav1.LoadBalancerReadyCondition)
 	return r.RunBaseReconciliationStages()
 }
 
@@ -75,11 +81,13 @@ func (r *CloudStackIsoNetReconciliationRunner) Reconcile() (retRes ctrl.Result,
 	if err != nil {
 		return r.ReturnWrappedError(retErr, "setting up CloudStackCluster patcher")
 	}
-	if err := r.CSUser.GetOrCreateIsolatedNetwork(r.FailureDomain, r.ReconciliationSubject, r.CSCluster); err != nil {
+	err = r.CSUser.GetOrCreateIsolatedNetwork(r.RequestCtx, r.FailureDomain, r.ReconciliationSubject, r.CSCluster)
+	r.MarkConditions(err)
+	if err != nil {
 		return ctrl.Result{}, err
 	}
 	// Tag the created network.
-	if err := r.CSUser.AddClusterTag(cloud.ResourceTypeNetwork, r.ReconciliationSubject.Spec.ID, r.CSCluster); err != nil {
+	if err := r.CSUser.AddClusterTag(r.RequestCtx, cloud.ResourceTypeNetwork, r.ReconciliationSubject.Spec.ID, r.CSCluster); err != nil {
 		return ctrl.Result{}, errors.Wrapf(err, "tagging network with id %s", r.ReconciliationSubject.Spec.ID)
 	}
 	if err := csClusterPatcher.Patch(r.RequestCtx, r.CSCluster); err != nil {
@@ -90,10 +98,54 @@ func (r *CloudStackIsoNetReconciliationRunner) Reconcile() (retRes ctrl.Result,
 	return ctrl.Result{}, nil
 }
 
+// isoNetStep is a step of setting up an isolated network, tracked by a condition.
+type isoNetStep struct {
+	condition clusterv1.ConditionType
+	done      bool
+	reason    string // Why the condition is false when the step failed.
+}
+
+// MarkConditions sets the conditions of the network, and of the control plane endpoint on its public IP when the network
+// provides it, after setting them up. An error is put on the first of them not set up yet, or else on the last.
+func (r *CloudStackIsoNetReconciliationRunner) MarkConditions(err error) {
+	isoNet := r.ReconciliationSubject
+	steps := []isoNetStep{{infrav1.NetworkReadyCondition, isoNet.Spec.ID != "", infrav1.NetworkFailedReason}}
+	if provider := r.CSCluster.Spec.ControlPlaneEndpointProvider; provider == "" ||
+		provider == infrav1.EndpointProviderIsolatedNetwork {
+		steps = append(steps,
+			isoNetStep{infrav1.PublicIPAssociatedCondition, isoNet.Status.PublicIPID != "", infrav1.PublicIPAssociationFailedReason},
+			isoNetStep{infrav1.LoadBalancerReadyCondition, isoNet.Status.LBRuleID != "", infrav1.LoadBalancerFailedReason})
+	}
+	failed := len(steps)
+	if err != nil {
+		failed = len(steps) - 1
+		for idx, step := range steps {
+			if !step.done {
+				failed = idx
+				break
+			}
+		}
+	}
+	for idx, step := range steps {
+		switch {
+		case idx < failed:
+			conditions.MarkTrue(isoNet, step.condition)
+		case idx == failed && cloud.IsJobPending(err):
+			conditions.MarkFalse(isoNet, step.condition, infrav1.AsyncJobPendingReason, clusterv1.ConditionSeverityInfo,
+				"%s", err.Error())
+		case idx == failed:
+			conditions.MarkFalse(isoNet, step.condition, step.reason, clusterv1.ConditionSeverityWarning, "%s", err.Error())
+		case step.condition == infrav1.LoadBalancerReadyCondition && steps[idx-1].condition == infrav1.PublicIPAssociatedCondition:
+			conditions.MarkFalse(isoNet, step.condition, infrav1.WaitingForPublicIPReason, clusterv1.ConditionSeverityInfo,
+				"Waiting for the network's public IP address")
+		}
+	}
+}
+
 func (r *CloudStackIsoNetReconciliationRunner) ReconcileDelete() (retRes ctrl.Result, retErr error) {
 	r.Log.Info("Deleting IsolatedNetwork.")
-	if err := r.CSUser.DisposeIsoNetResources(r.FailureDomain, r.ReconciliationSubject, r.CSCluster); err != nil {
-		if !strings.Contains(strings.ToLower(err.Error()), "no match found") {
+	if err := r.CSUser.DisposeIsoNetResources(r.RequestCtx, r.FailureDomain, r.ReconciliationSubject, r.CSCluster); err != nil {
+		if !cloud.IsNotFoundError(err) {
 			return ctrl.Result{}, err
 		}
 	}
@@ -103,7 +155,18 @@ func (r *CloudStackIsoNetReconciliationRunner) ReconcileDelete() (retRes ctrl.Re
 
 // SetupWithManager sets up the controller with the Manager.
 func (reconciler *CloudStackIsoNetReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// Reapply the load balancer configuration when it changes.
+	csClusterToIsoNets, err := csCtrlrUtils.CloudStackClusterToObjectsMapper(
+		reconciler.K8sClient, &infrav1.CloudStackIsolatedNetworkList{}, mgr.GetScheme())
+	if err != nil {
+		return err
+	}
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&infrav1.CloudStackIsolatedNetwork{}).
+		Watches(
+			&source.Kind{Type: &infrav1.CloudStackCluster{}},
+			handler.EnqueueRequestsFromMapFunc(csClusterToIsoNets),
+			builder.WithPredicates(csCtrlrUtils.CloudStackClusterLoadBalancerChanged),
+		).
 		Complete(reconciler)
 }
@@ -0,0 +1,192 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
+	csCtrlrUtils "sigs.k8s.io/cluster-api-provider-cloudstack/controllers/utils"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
+)
+
+//+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=cloudstackloadbalancers,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=cloudstackloadbalancers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=cloudstackloadbalancers/finalizers,verbs=update
+
+// CloudStackLoadBalancerReconciliationRunner is a ReconciliationRunner with extensions specific to CloudStack load
+// balancer reconciliation.
+type CloudStackLoadBalancerReconciliationRunner struct {
+	*csCtrlrUtils.ReconciliationRunner
+	ReconciliationSubject *infrav1.CloudStackLoadBalancer
+	FailureDomain         *infrav1.CloudStackFailureDomain
+	IsoNet                *infrav1.CloudStackIsolatedNetwork
+}
+
+// CloudStackLoadBalancerReconciler is the base reconciler to adapt to k8s.
+type CloudStackLoadBalancerReconciler struct {
+	csCtrlrUtils.ReconcilerBase
+}
+
+// Initialize a new CloudStackLoadBalancer reconciliation runner with concrete types and initialized member fields.
+func NewCSLoadBalancerReconciliationRunner() *CloudStackLoadBalancerReconciliationRunner {
+	// Set concrete type and init pointers.
+	r := &CloudStackLoadBalancerReconciliationRunner{ReconciliationSubject: &infrav1.CloudStackLoadBalancer{}}
+	r.FailureDomain = &infrav1.CloudStackFailureDomain{}
+	r.IsoNet = &infrav1.CloudStackIsolatedNetwork{}
+	// Setup the base runner. Initializes pointers and links reconciliation methods.
+	r.ReconciliationRunner = csCtrlrUtils.NewRunner(r, r.ReconciliationSubject, "CloudStackLoadBalancer")
+	return r
+}
+
+func (reconciler *CloudStackLoadBalancerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	r := NewCSLoadBalancerReconciliationRunner()
+	r.UsingBaseReconciler(reconciler.ReconcilerBase).ForRequest(req).WithRequestCtx(ctx)
+	r.WithAdditionalCommonStages(r.GetFailureDomainAndIsolatedNetwork)
+	r.WithReadySummary(infrav1.LoadBalancerReadyCondition)
+	return r.RunBaseReconciliationStages()
+}
+
+// GetFailureDomainAndIsolatedNetwork fetches the load balancer's failure domain and the isolated network whose public
+// IP address the rules are on. Either may be missing, so that deletion can proceed once they're gone.
+func (r *CloudStackLoadBalancerReconciliationRunner) GetFailureDomainAndIsolatedNetwork() (ctrl.Result, error) {
+	fdName := infrav1.FailureDomainHashedMetaName(r.ReconciliationSubject.Spec.FailureDomainName, r.CAPICluster.Name)
+	if res, err := r.GetObjectByName(fdName, r.FailureDomain)(); r.ShouldReturn(res, err) {
+		return res, err
+	}
+	if r.FailureDomain.Name == "" {
+		return ctrl.Result{}, nil
+	}
+	return r.GetObjectByName(r.IsoNetMetaName(r.FailureDomain.Spec.Zone.Network.Name), r.IsoNet)()
+}
+
+func (r *CloudStackLoadBalancerReconciliationRunner) Reconcile() (ctrl.Result, error) {
+	if r.FailureDomain.Name == "" {
+		return r.RequeueWithMessage("Failure domain not found.", "failureDomainName", r.ReconciliationSubject.Spec.FailureDomainName)
+	}
+	controllerutil.AddFinalizer(r.ReconciliationSubject, infrav1.LoadBalancerFinalizer)
+	// Have the failure domain wait for the rules to be removed before it releases its network.
+	if err := controllerutil.SetOwnerReference(r.FailureDomain, r.ReconciliationSubject, r.Scheme); err != nil {
+		return r.ReturnWrappedError(err, "setting failure domain owner reference")
+	}
+	if r.IsoNet.Name == "" || r.IsoNet.Status.PublicIPID == "" {
+		conditions.MarkFalse(r.ReconciliationSubject, infrav1.LoadBalancerReadyCondition,
+			infrav1.WaitingForPublicIPReason, clusterv1.ConditionSeverityInfo,
+			"Waiting for the public IP address of the isolated network")
+		return r.RequeueWithMessage("Isolated network public IP address not ready.")
+	}
+	if res, err := r.AsFailureDomainUser(&r.FailureDomain.Spec)(); r.ShouldReturn(res, err) {
+		return res, err
+	}
+
+	r.ReconciliationSubject.Status.Ready = false
+	if err := r.CSUser.GetOrCreateLoadBalancerRules(r.RequestCtx, r.ReconciliationSubject, r.IsoNet); err != nil {
+		return r.markFailed(err, "setting up load balancer rules")
+	}
+	r.ReconciliationSubject.Status.PublicIP = r.IsoNet.Spec.ControlPlaneEndpoint.Host
+
+	members, err := r.ruleMembers()
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	for ruleName, ruleID := range r.ReconciliationSubject.Status.RuleIDs {
+		if err := r.CSUser.SetLoadBalancerRuleMembers(r.RequestCtx, ruleID, members[ruleName]); err != nil {
+			return r.markFailed(err, "setting members of load balancer rule "+ruleName)
+		}
+	}
+	conditions.MarkTrue(r.ReconciliationSubject, infrav1.LoadBalancerReadyCondition)
+	r.ReconciliationSubject.Status.Ready = true
+	return ctrl.Result{}, nil
+}
+
+// markFailed records an error setting up the load balancer in its LoadBalancerReady condition and returns it wrapped.
+// Async jobs still running are recorded as such.
+func (r *CloudStackLoadBalancerReconciliationRunner) markFailed(err error, msg string) (ctrl.Result, error) {
+	if cloud.IsJobPending(err) {
+		conditions.MarkFalse(r.ReconciliationSubject, infrav1.LoadBalancerReadyCondition,
+			infrav1.AsyncJobPendingReason, clusterv1.ConditionSeverityInfo, "%s: %s", msg, err.Error())
+	} else {
+		conditions.MarkFalse(r.ReconciliationSubject, infrav1.LoadBalancerReadyCondition,
+			infrav1.LoadBalancerFailedReason, clusterv1.ConditionSeverityWarning, "%s: %s", msg, err.Error())
+	}
+	return r.ReturnWrappedError(err, msg)
+}
+
+// ruleMembers returns the instance IDs of the machines to put behind each rule: those of the cluster in the load
+// balancer's failure domain with a membership including the rule, that have a VM and aren't being deleted.
+func (r *CloudStackLoadBalancerReconciliationRunner) ruleMembers() (map[string][]string, error) {
+	machines := &infrav1.CloudStackMachineList{}
+	if err := r.K8sClient.List(r.RequestCtx, machines, client.InNamespace(r.Request.Namespace),
+		client.MatchingLabels{clusterv1.ClusterLabelName: r.CAPICluster.Name}); err != nil {
+		return nil, errors.Wrap(err, "listing CloudStackMachines")
+	}
+	members := map[string][]string{}
+	for _, machine := range machines.Items {
+		membership := machine.MembershipIn(r.ReconciliationSubject.Name)
+		if membership == nil || machine.Spec.InstanceID == nil || *machine.Spec.InstanceID == "" ||
+			!machine.DeletionTimestamp.IsZero() ||
+			machine.Spec.FailureDomainName != r.ReconciliationSubject.Spec.FailureDomainName {
+			continue
+		}
+		for _, rule := range r.ReconciliationSubject.Spec.Rules {
+			if membership.Includes(rule.Name) {
+				members[rule.Name] = append(members[rule.Name], *machine.Spec.InstanceID)
+			}
+		}
+	}
+	for _, instanceIDs := range members {
+		sort.Strings(instanceIDs)
+	}
+	return members, nil
+}
+
+func (r *CloudStackLoadBalancerReconciliationRunner) ReconcileDelete() (ctrl.Result, error) {
+	r.Log.Info("Deleting CloudStackLoadBalancer")
+	// Without an isolated network there's no public IP address left to remove the rules from.
+	if r.FailureDomain.Name != "" && r.IsoNet.Name != "" {
+		if res, err := r.AsFailureDomainUser(&r.FailureDomain.Spec)(); r.ShouldReturn(res, err) {
+			return res, err
+		}
+		if err := r.CSUser.DeleteLoadBalancerRules(r.RequestCtx, r.ReconciliationSubject, r.IsoNet); err != nil {
+			return r.ReturnWrappedError(err, "deleting load balancer rules")
+		}
+	}
+	controllerutil.RemoveFinalizer(r.ReconciliationSubject, infrav1.LoadBalancerFinalizer)
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (reconciler *CloudStackLoadBalancerReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&infrav1.CloudStackLoadBalancer{}).
+		// Follow machines joining and leaving the load balancer.
+		Watches(
+			&source.Kind{Type: &infrav1.CloudStackMachine{}},
+			handler.EnqueueRequestsFromMapFunc(csCtrlrUtils.CloudStackMachineToLoadBalancers),
+		).
+		Complete(reconciler)
+}
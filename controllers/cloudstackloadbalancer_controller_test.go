@@ -0,0 +1,88 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
+	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta2"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/test/simulator"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("CloudStackLoadBalancerReconciler", func() {
+	Context("With a fake ctrlRuntimeClient and a CloudStack simulator.", func() {
+		var lbKey client.ObjectKey
+
+		BeforeEach(func() {
+			setupSimulatorTestClient()
+
+			// Build out the failure domain's isolated network the way the CloudStackIsolatedNetwork controller would.
+			dummies.SetDummyIsoNetToNameOnly()
+			dummies.CSFailureDomain1.Spec.Zone = dummies.Zone1
+			dummies.CSCluster.Spec.ControlPlaneEndpoint.Host = ""
+			sim.AddNetwork(dummies.Zone1.ID, "other-network", simulator.NetworkTypeShared, "10.20.0.0/24")
+			csClient, err := cloud.NewClientFromConf(dummies.SimulatorConf, nil)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(csClient.GetOrCreateIsolatedNetwork(ctx, dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).Should(Succeed())
+			dummies.CSISONet1.Name = dummies.CSCluster.Name + "-" + dummies.ISONet1.Name
+			Ω(fakeCtrlClient.Create(ctx, dummies.CSFailureDomain1)).Should(Succeed())
+			Ω(fakeCtrlClient.Create(ctx, dummies.CSISONet1)).Should(Succeed())
+			Ω(fakeCtrlClient.Status().Update(ctx, dummies.CSISONet1)).Should(Succeed())
+
+			// A worker VM on the isolated network that's a member of the load balancer's http rule.
+			dummies.CSFailureDomain1.Spec.Zone.Network.ID = dummies.CSISONet1.Spec.ID
+			dummies.CSFailureDomain1.Spec.Zone.Network.Type = cloud.NetworkTypeIsolated
+			Ω(csClient.GetOrCreateVMInstance(ctx, dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster,
+				dummies.CSFailureDomain1, dummies.CSAffinityGroup, "")).Should(Succeed())
+			dummies.CSMachine1.Spec.LoadBalancerMemberships = []infrav1.LoadBalancerMembership{
+				{Name: dummies.CSLoadBalancer1.Name, Rules: []string{"http"}}}
+			Ω(fakeCtrlClient.Create(ctx, dummies.CSMachine1)).Should(Succeed())
+
+			Ω(fakeCtrlClient.Create(ctx, dummies.CSLoadBalancer1)).Should(Succeed())
+			lbKey = client.ObjectKeyFromObject(dummies.CSLoadBalancer1)
+		})
+
+		It("Should forward ports to its members and remove its rules on deletion.", func() {
+			for i := 0; i < 2; i++ {
+				_, err := LoadBalancerReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: lbKey})
+				Ω(err).ShouldNot(HaveOccurred())
+			}
+			Ω(sim.LoadBalancerRules()).Should(HaveLen(3))
+
+			csLB := &infrav1.CloudStackLoadBalancer{}
+			Ω(fakeCtrlClient.Get(ctx, lbKey, csLB)).Should(Succeed())
+			Ω(csLB.Status.Ready).Should(BeTrue())
+			Ω(conditions.IsTrue(csLB, infrav1.LoadBalancerReadyCondition)).Should(BeTrue())
+			Ω(csLB.Status.PublicIP).Should(BeElementOf(dummies.SimulatorPublicIPs))
+			Ω(csLB.OwnerReferences).Should(HaveLen(1))
+			Ω(csLB.OwnerReferences[0].Name).Should(Equal(dummies.CSFailureDomain1.Name))
+			Ω(sim.LoadBalancerRuleMembers(csLB.Status.RuleIDs["http"])).Should(ConsistOf(*dummies.CSMachine1.Spec.InstanceID))
+			Ω(sim.LoadBalancerRuleMembers(csLB.Status.RuleIDs["https"])).Should(BeEmpty())
+
+			Ω(fakeCtrlClient.Delete(ctx, csLB)).Should(Succeed())
+			_, err := LoadBalancerReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: lbKey})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(sim.LoadBalancerRules()).Should(HaveLen(1)) // Only the control plane endpoint's.
+			Ω(fakeCtrlClient.Get(ctx, lbKey, csLB)).ShouldNot(Succeed())
+		})
+	})
+})
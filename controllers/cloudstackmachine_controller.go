@@ -20,15 +20,18 @@ import (
 	"context"
 	"fmt"
 	"k8s.io/utils/pointer"
-	"math/rand"
 	"reflect"
 	"regexp"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
@@ -52,6 +55,7 @@ const (
 	BootstrapDataNotReady                      = "Bootstrap DataSecretName not yet available"
 	CSMachineCreationSuccess                   = "CloudStack instance Created"
 	CSMachineCreationFailed                    = "Creating CloudStack machine failed: %s"
+	CSMachineFailed                            = "Creating CloudStack machine failed for good, %s: %s"
 	MachineInstanceRunning                     = "Machine instance is Running..."
 	MachineInErrorMessage                      = "CloudStackMachine VM in error state. Deleting associated Machine"
 	MachineNotReadyMessage                     = "Instance not ready, is %s"
@@ -59,11 +63,30 @@ const (
 	CSMachineStateCheckerCreationSuccess       = "CloudStackMachineStateChecker created"
 	CSMachineDeletionMessage                   = "Deleting CloudStack Machine %s"
 	CSMachineDeletionInstanceIDNotFoundMessage = "Deleting CloudStack Machine %s instanceID not found"
+	CSMachineIPAllocationFailed                = "Failed to allocate static IP: %s"
+	CSMachineResizeSuccess                     = "CloudStack instance resized"
+	CSMachineResizeFailed                      = "Resizing CloudStack instance failed: %s"
+	CSMachineAdoptionSuccess                   = "CloudStack instance %s adopted"
+	CSMachineAdoptionFailed                    = "Adopting CloudStack instance failed: %s"
+	CSMachineRetentionMessage                  = "Retaining CloudStack instance %s"
+	CSMachineDataDisksRetained                 = "Retained data disks of CloudStack instance %s: %s"
+	CSMachinePlacedMessage                     = "Placed in failure domain %s by the %s"
+	CSMachineRescheduledMessage                = "No capacity for CloudStack instance in failure domain %s, rescheduled to %s by the %s"
+	CSMachineFailureDomainsExhausted           = "No capacity for CloudStack instance in any failure domain, tried %s"
+
+	// InstanceResizeTimeout is how long a machine's instance may be down for an in-place resize before the state
+	// checker replaces the machine.
+	InstanceResizeTimeout = 10 * time.Minute
+
+	// maxReschedulingBackoff caps the backoff of retrying a machine CloudStack keeps finding no capacity for.
+	maxReschedulingBackoff = 5 * time.Minute
 )
 
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=cloudstackmachines,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=cloudstackmachines/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=cloudstackmachines/finalizers,verbs=update
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=cloudstackippools,verbs=get;list;watch
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=cloudstackippools/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machines;machines/status,verbs=get;list;watch
 // +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machinesets,verbs=get;list;watch
 // +kubebuilder:rbac:groups=controlplane.cluster.x-k8s.io,resources=kubeadmcontrolplanes,verbs=get;list;watch
@@ -110,19 +133,35 @@ func (reconciler *CloudStackMachineReconciler) Reconcile(ctx context.Context, re
 		r.SetFailureDomainOnCSMachine,
 		r.GetFailureDomainByName(func() string { return r.ReconciliationSubject.Spec.FailureDomainName }, r.FailureDomain),
 		r.AsFailureDomainUser(&r.FailureDomain.Spec))
+	r.WithReadySummary(
+		infrav1.BootstrapDataReadyCondition,
+		infrav1.AffinityGroupReadyCondition,
+		infrav1.InstancePlacedCondition,
+		infrav1.InstanceAdoptedCondition,
+		infrav1.InstanceProvisionedCondition,
+		infrav1.InstanceResizedCondition,
+		infrav1.LoadBalancerAttachedCondition)
 	return r.RunBaseReconciliationStages()
 }
 
 func (r *CloudStackMachineReconciliationRunner) Reconcile() (retRes ctrl.Result, reterr error) {
 	return r.RunReconciliationStages(
+		r.ReturnIfFailed,
 		r.DeleteMachineIfFailuredomainNotExist,
 		r.GetObjectByName("placeholder", r.IsoNet,
 			func() string { return r.IsoNetMetaName(r.FailureDomain.Spec.Zone.Network.Name) }),
-		r.RunIf(func() bool { return r.FailureDomain.Spec.Zone.Network.Type == cloud.NetworkTypeIsolated },
+		r.RunIf(func() bool {
+			return r.FailureDomain.Spec.Zone.Network.Type == cloud.NetworkTypeIsolated ||
+				r.FailureDomain.Spec.Zone.Network.Type == cloud.NetworkTypeVPC
+		},
 			r.CheckPresent(map[string]client.Object{"CloudStackIsolatedNetwork": r.IsoNet})),
 		r.ConsiderAffinity,
-		r.GetOrCreateVMInstance,
+		r.AdoptInstanceIfRequested,
+		r.AllocateStaticIPIfNeeded,
+		r.RunIf(func() bool { return !r.ReconciliationSubject.Spec.AdoptInstance }, r.GetOrCreateVMInstance),
+		r.ReconcileInstanceTags,
 		r.RequeueIfInstanceNotRunning,
+		r.ResizeInstanceIfNeeded,
 		r.AddToLBIfNeeded,
 		r.GetOrCreateMachineStateChecker,
 	)
@@ -148,24 +187,38 @@ func (r *CloudStackMachineReconciliationRunner) ConsiderAffinity() (ctrl.Result,
 		return res, err
 	}
 	if !r.AffinityGroup.Status.Ready {
+		conditions.MarkFalse(r.ReconciliationSubject, infrav1.AffinityGroupReadyCondition,
+			infrav1.WaitingForAffinityGroupReason, clusterv1.ConditionSeverityInfo,
+			"Waiting for affinity group %s", r.AffinityGroup.Name)
 		return r.RequeueWithMessage("Required affinity group not ready.")
 	}
+	conditions.MarkTrue(r.ReconciliationSubject, infrav1.AffinityGroupReadyCondition)
 
 	return ctrl.Result{}, nil
 }
 
+// failureDomainPinned reports whether the machine's failure domain was picked for it rather than left to CAPC.
+func (r *CloudStackMachineReconciliationRunner) failureDomainPinned() bool {
+	return r.CAPIMachine.Spec.FailureDomain != nil &&
+		(util.IsControlPlaneMachine(r.CAPIMachine) || // Is control plane machine -- CAPI will specify.
+			*r.CAPIMachine.Spec.FailureDomain != "") // Or potentially another machine controller specified.
+}
+
 // SetFailureDomainOnCSMachine sets the failure domain the machine should launch in.
 func (r *CloudStackMachineReconciliationRunner) SetFailureDomainOnCSMachine() (retRes ctrl.Result, reterr error) {
 	if r.ReconciliationSubject.Spec.FailureDomainName == "" {
 		var name string
-		if r.CAPIMachine.Spec.FailureDomain != nil &&
-			(util.IsControlPlaneMachine(r.CAPIMachine) || // Is control plane machine -- CAPI will specify.
-				*r.CAPIMachine.Spec.FailureDomain != "") { // Or potentially another machine controller specified.
+		if r.failureDomainPinned() {
 			name = *r.CAPIMachine.Spec.FailureDomain
 			r.ReconciliationSubject.Spec.FailureDomainName = *r.CAPIMachine.Spec.FailureDomain
-		} else { // Not a control plane machine. Place randomly.
-			randNum := (rand.Int() % len(r.CSCluster.Spec.FailureDomains)) // #nosec G404 -- weak crypt rand doesn't matter here.
-			name = r.CSCluster.Spec.FailureDomains[randNum].Name
+		} else { // Not a control plane machine. Place by the cluster's placement strategy.
+			placed, reason, err := r.PlaceWorkerMachine(r.ReconciliationSubject)
+			if err != nil {
+				return ctrl.Result{}, errors.Wrap(err, "placing machine in a failure domain")
+			}
+			name = placed
+			r.Log.Info("Placed machine.", "failureDomain", name, "reason", reason)
+			r.Recorder.Eventf(r.ReconciliationSubject, "Normal", "Placed", CSMachinePlacedMessage, name, reason)
 		}
 		r.ReconciliationSubject.Spec.FailureDomainName = name
 		r.ReconciliationSubject.Labels[infrav1.FailureDomainLabelName] = infrav1.FailureDomainHashedMetaName(name, r.CAPICluster.Name)
@@ -196,10 +249,73 @@ func (r *CloudStackMachineReconciliationRunner) DeleteMachineIfFailuredomainNotE
 	return ctrl.Result{}, nil
 }
 
+// AllocateStaticIPIfNeeded allocates the machine an address from its IP pool, if it references one, and records the
+// allocation in the machine's status so the VM is deployed with it.
+func (r *CloudStackMachineReconciliationRunner) AllocateStaticIPIfNeeded() (retRes ctrl.Result, reterr error) {
+	poolRef := r.ReconciliationSubject.Spec.IPPoolRef
+	if poolRef == nil || r.ReconciliationSubject.Spec.InstanceID != nil {
+		return ctrl.Result{}, nil
+	}
+
+	pool := &infrav1.CloudStackIPPool{}
+	key := client.ObjectKey{Namespace: r.ReconciliationSubject.Namespace, Name: poolRef.Name}
+	if err := r.K8sClient.Get(r.RequestCtx, key, pool); err != nil {
+		if apierrors.IsNotFound(err) {
+			return r.RequeueWithMessage(fmt.Sprintf("CloudStackIPPool %s not found.", poolRef.Name))
+		}
+		return ctrl.Result{}, err
+	}
+
+	ip := pool.AllocatedTo(r.ReconciliationSubject.Name)
+	if ip == "" {
+		var err error
+		if ip, err = pool.Allocate(r.ReconciliationSubject.Name); err != nil {
+			r.Recorder.Eventf(r.ReconciliationSubject, "Warning", "Creating", CSMachineIPAllocationFailed, err.Error())
+			return r.RequeueWithMessage(err.Error())
+		}
+		// The pool's resource version guards against two machines being handed the same address.
+		if err := r.K8sClient.Status().Update(r.RequestCtx, pool); err != nil {
+			if apierrors.IsConflict(err) {
+				return r.RequeueWithMessage(fmt.Sprintf("CloudStackIPPool %s was modified concurrently.", poolRef.Name))
+			}
+			return ctrl.Result{}, err
+		}
+		r.Log.Info("Allocated static IP", "pool", pool.Name, "ip", ip)
+	}
+	r.ReconciliationSubject.Status.IPAllocation = &infrav1.CloudStackIPAllocation{
+		Pool: pool.Name, Network: pool.Spec.Network, IP: ip}
+	return ctrl.Result{}, nil
+}
+
+// ReleaseStaticIP returns the address the machine holds to its IP pool.
+func (r *CloudStackMachineReconciliationRunner) ReleaseStaticIP() error {
+	allocation := r.ReconciliationSubject.Status.IPAllocation
+	if allocation == nil {
+		return nil
+	}
+	pool := &infrav1.CloudStackIPPool{}
+	key := client.ObjectKey{Namespace: r.ReconciliationSubject.Namespace, Name: allocation.Pool}
+	if err := r.K8sClient.Get(r.RequestCtx, key, pool); err != nil {
+		if apierrors.IsNotFound(err) { // Nothing left to release the address to.
+			return nil
+		}
+		return err
+	}
+	if pool.Release(r.ReconciliationSubject.Name) {
+		if err := r.K8sClient.Status().Update(r.RequestCtx, pool); err != nil {
+			return err
+		}
+		r.Log.Info("Released static IP", "pool", pool.Name, "ip", allocation.IP)
+	}
+	return nil
+}
+
 // GetOrCreateVMInstance gets or creates a VM instance.
 // Implicitly it also fetches its bootstrap secret in order to create said instance.
 func (r *CloudStackMachineReconciliationRunner) GetOrCreateVMInstance() (retRes ctrl.Result, reterr error) {
 	if r.CAPIMachine.Spec.Bootstrap.DataSecretName == nil {
+		conditions.MarkFalse(r.ReconciliationSubject, infrav1.BootstrapDataReadyCondition,
+			infrav1.WaitingForBootstrapDataReason, clusterv1.ConditionSeverityInfo, BootstrapDataNotReady)
 		r.Recorder.Event(r.ReconciliationSubject, "Normal", "Creating", BootstrapDataNotReady)
 		return r.RequeueWithMessage(BootstrapDataNotReady + ".")
 	}
@@ -213,27 +329,286 @@ func (r *CloudStackMachineReconciliationRunner) GetOrCreateVMInstance() (retRes
 	}
 	data, present := secret.Data["value"]
 	if !present {
+		conditions.MarkFalse(r.ReconciliationSubject, infrav1.BootstrapDataReadyCondition,
+			infrav1.WaitingForBootstrapDataReason, clusterv1.ConditionSeverityInfo, "Bootstrap secret data not yet set")
 		return ctrl.Result{}, errors.New("bootstrap secret data not yet set")
 	}
+	conditions.MarkTrue(r.ReconciliationSubject, infrav1.BootstrapDataReadyCondition)
 
-	userData := processCustomMetadata(data, r)
-	err := r.CSUser.GetOrCreateVMInstance(r.ReconciliationSubject, r.CAPIMachine, r.CSCluster, r.FailureDomain, r.AffinityGroup, userData)
+	if r.reschedulingPending() { // Expunging the instance CloudStack found no capacity for failed last time.
+		return r.RescheduleOnInsufficientCapacity()
+	}
 
-	if err != nil {
+	userData := processCustomMetadata(data, r)
+	err := r.CSUser.GetOrCreateVMInstance(r.RequestCtx, r.ReconciliationSubject, r.CAPIMachine, r.CSCluster, r.FailureDomain, r.AffinityGroup, userData)
+
+	if cloud.IsJobPending(err) {
+		conditions.MarkFalse(r.ReconciliationSubject, infrav1.InstanceProvisionedCondition,
+			infrav1.AsyncJobPendingReason, clusterv1.ConditionSeverityInfo, "%s", err.Error())
+	} else if err != nil {
+		conditions.MarkFalse(r.ReconciliationSubject, infrav1.InstanceProvisionedCondition,
+			infrav1.InstanceProvisionFailedReason, clusterv1.ConditionSeverityWarning, CSMachineCreationFailed, err.Error())
 		r.Recorder.Eventf(r.ReconciliationSubject, "Warning", "Creating", CSMachineCreationFailed, err.Error())
 	}
-	if err == nil && !controllerutil.ContainsFinalizer(r.ReconciliationSubject, infrav1.MachineFinalizer) { // Fetched or Created?
+	if r.ReconciliationSubject.Spec.InstanceID != nil && !controllerutil.ContainsFinalizer(r.ReconciliationSubject, infrav1.MachineFinalizer) { // Fetched or Created?
 		// Adding a finalizer will make reconcile-delete try to destroy the associated VM through instanceID.
-		// If err is not nil, it means CAPC could not get an associated VM through instanceID or name, so we should not add a finalizer to this CloudStackMachine,
+		// Without an instanceID, CAPC could not get an associated VM through instanceID or name, so we should not add a finalizer to this CloudStackMachine,
 		// Otherwise, reconcile-delete will be stuck trying to wait for instanceID to be available.
+		// A failed deployment can leave a VM behind too, which must be destroyed with the machine.
 		controllerutil.AddFinalizer(r.ReconciliationSubject, infrav1.MachineFinalizer)
-		r.Recorder.Eventf(r.ReconciliationSubject, "Normal", "Created", CSMachineCreationSuccess)
-		r.Log.Info(CSMachineCreationSuccess, "instanceStatus", r.ReconciliationSubject.Status)
+		if err == nil || cloud.IsJobPending(err) {
+			r.Recorder.Eventf(r.ReconciliationSubject, "Normal", "Created", CSMachineCreationSuccess)
+			r.Log.Info(CSMachineCreationSuccess, "instanceStatus", r.ReconciliationSubject.Status)
+		}
+	}
+
+	if cloud.IsCapacityError(err) && r.reschedulable() {
+		return r.RescheduleOnInsufficientCapacity()
+	} else if terminal := cloud.AsTerminalError(err); terminal != nil {
+		return r.SetFailed(terminal)
+	} else if err == nil && r.ReconciliationSubject.Status.InstanceState != "Error" &&
+		conditions.Has(r.ReconciliationSubject, infrav1.InstancePlacedCondition) {
+		conditions.MarkTrue(r.ReconciliationSubject, infrav1.InstancePlacedCondition)
 	}
 
 	return ctrl.Result{}, err
 }
 
+// SetFailed records an error retrying won't recover the machine from as its failure reason and message, for CAPI to
+// mark the Machine failed and a MachineHealthCheck to remediate it, and stops retrying.
+func (r *CloudStackMachineReconciliationRunner) SetFailed(terminal *cloud.TerminalError) (ctrl.Result, error) {
+	csMachine := r.ReconciliationSubject
+	csMachine.Status.FailureReason = &terminal.Reason
+	csMachine.Status.FailureMessage = pointer.String(terminal.Error())
+	conditions.MarkFalse(csMachine, infrav1.InstanceProvisionedCondition, infrav1.InstanceProvisionFailedReason,
+		clusterv1.ConditionSeverityError, CSMachineCreationFailed, terminal.Error())
+	r.Recorder.Eventf(csMachine, "Warning", "Failed", CSMachineFailed, terminal.Reason, terminal.Error())
+	r.Log.Info("Machine failed for good, leaving it for remediation.", "reason", terminal.Reason, "message", terminal.Error())
+	r.SetReturnEarly()
+	return ctrl.Result{}, nil
+}
+
+// ReturnIfFailed leaves machines that failed for good alone. CAPI deletes them when remediating.
+func (r *CloudStackMachineReconciliationRunner) ReturnIfFailed() (ctrl.Result, error) {
+	if r.ReconciliationSubject.Status.FailureReason != nil {
+		r.Log.V(1).Info("Machine failed, not reconciling.", "reason", *r.ReconciliationSubject.Status.FailureReason)
+		r.SetReturnEarly()
+	}
+	return ctrl.Result{}, nil
+}
+
+// reschedulable reports whether the machine may be moved to another failure domain when CloudStack finds no capacity
+// for its instance. Machines whose failure domain was picked for them, or whose static IPs or affinity groups tie
+// them to a zone, stay where they are.
+func (r *CloudStackMachineReconciliationRunner) reschedulable() bool {
+	csMachine := r.ReconciliationSubject
+	if r.failureDomainPinned() || csMachine.Spec.IPPoolRef != nil || len(csMachine.Spec.AffinityGroupIDs) > 0 {
+		return false
+	}
+	for _, network := range csMachine.Spec.Networks {
+		if network.IP != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// reschedulingPending reports whether the instance CloudStack found no capacity for in the machine's failure domain
+// is still to be expunged before the machine is moved.
+func (r *CloudStackMachineReconciliationRunner) reschedulingPending() bool {
+	csMachine := r.ReconciliationSubject
+	reason := conditions.GetReason(csMachine, infrav1.InstancePlacedCondition)
+	return csMachine.Spec.InstanceID != nil &&
+		(reason == infrav1.InsufficientCapacityReason || reason == infrav1.FailureDomainsExhaustedReason) &&
+		r.triedFailureDomain(csMachine.Spec.FailureDomainName)
+}
+
+// markInsufficientCapacity records CloudStack finding no capacity for the machine's instance in the InstancePlaced
+// condition. Once every failure domain was exhausted, the condition keeps saying so until an instance runs.
+func (r *CloudStackMachineReconciliationRunner) markInsufficientCapacity(msg string) {
+	csMachine := r.ReconciliationSubject
+	if conditions.GetReason(csMachine, infrav1.InstancePlacedCondition) == infrav1.FailureDomainsExhaustedReason {
+		return
+	}
+	conditions.MarkFalse(csMachine, infrav1.InstancePlacedCondition, infrav1.InsufficientCapacityReason,
+		clusterv1.ConditionSeverityWarning, "%s", msg)
+}
+
+// triedFailureDomain reports whether CloudStack already found no capacity for the machine's instance in the named
+// failure domain.
+func (r *CloudStackMachineReconciliationRunner) triedFailureDomain(name string) bool {
+	for _, tried := range r.ReconciliationSubject.Status.FailureDomainsTried {
+		if tried == name {
+			return true
+		}
+	}
+	return false
+}
+
+// RescheduleOnInsufficientCapacity moves the machine to a failure domain it hasn't been tried in, after CloudStack
+// found no capacity for its instance in its current one. The failed instance CloudStack leaves behind is expunged
+// first. Once every failure domain has been tried, the machine stays where it is and deploying is retried there,
+// moving on again from there should it fail. The InstancePlaced condition keeps reporting the failure domains
+// exhausted until an instance runs.
+func (r *CloudStackMachineReconciliationRunner) RescheduleOnInsufficientCapacity() (retRes ctrl.Result, reterr error) {
+	csMachine := r.ReconciliationSubject
+	from := csMachine.Spec.FailureDomainName
+	if !r.triedFailureDomain(from) {
+		csMachine.Status.FailureDomainsTried = append(csMachine.Status.FailureDomainsTried, from)
+		csMachine.Status.ReschedulingAttempts++
+	}
+	r.markInsufficientCapacity("no capacity for the instance in failure domain " + from)
+
+	if csMachine.Spec.InstanceID != nil {
+		failed := csMachine.DeepCopy()
+		failed.Spec.DeletionPolicy = infrav1.DeletionPolicyExpunge
+		failed.Spec.DataDiskDeletionPolicy = infrav1.DataDiskDeletionPolicyDelete
+		// Expunged as admin, like in ReconcileDelete.
+		err := r.CSClient.DestroyVMInstance(r.RequestCtx, failed)
+		csMachine.Status.AsyncJob = failed.Status.AsyncJob
+		if err != nil {
+			return ctrl.Result{}, errors.Wrapf(err, "expunging instance %s CloudStack found no capacity for", *failed.Spec.InstanceID)
+		}
+		csMachine.Spec.InstanceID = nil
+		csMachine.Status.InstanceState = ""
+	}
+
+	exhausted := true
+	for _, fd := range r.CSCluster.Spec.FailureDomains {
+		exhausted = exhausted && r.triedFailureDomain(fd.Name)
+	}
+	if exhausted {
+		msg := fmt.Sprintf(CSMachineFailureDomainsExhausted, strings.Join(csMachine.Status.FailureDomainsTried, ", "))
+		conditions.MarkFalse(csMachine, infrav1.InstancePlacedCondition, infrav1.FailureDomainsExhaustedReason,
+			clusterv1.ConditionSeverityError, msg)
+		r.Recorder.Event(csMachine, "Warning", "FailureDomainsExhausted", msg)
+		csMachine.Status.FailureDomainsTried = nil // Start over with the next failure.
+		return r.requeueRescheduling(msg)
+	}
+
+	to, reason, err := r.PlaceWorkerMachine(csMachine, csMachine.Status.FailureDomainsTried...)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "rescheduling machine to another failure domain")
+	}
+	csMachine.Spec.FailureDomainName = to
+	csMachine.Labels[infrav1.FailureDomainLabelName] = infrav1.FailureDomainHashedMetaName(to, r.CAPICluster.Name)
+	msg := fmt.Sprintf(CSMachineRescheduledMessage, from, to, reason)
+	r.markInsufficientCapacity(msg)
+	r.Recorder.Event(csMachine, "Normal", "Rescheduled", msg)
+	return r.requeueRescheduling(msg)
+}
+
+// requeueRescheduling requeues a machine CloudStack found no capacity for, backing off exponentially with the times
+// it did so since the machine's instance last ran.
+func (r *CloudStackMachineReconciliationRunner) requeueRescheduling(msg string) (ctrl.Result, error) {
+	backoff := utils.RequeueTimeout
+	for i := int32(1); i < r.ReconciliationSubject.Status.ReschedulingAttempts && backoff < maxReschedulingBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxReschedulingBackoff {
+		backoff = maxReschedulingBackoff
+	}
+	r.Log.Info(msg+". Requeuing.", "after", backoff.String())
+	return ctrl.Result{RequeueAfter: backoff}, nil
+}
+
+// AdoptInstanceIfRequested takes over the existing instance of a machine with AdoptInstance, in place of deploying
+// one. The instance must fit the machine and its failure domain; until it does, adoption is retried and recorded as
+// failed in the InstanceAdopted condition. Once adopted, the instance's details are refreshed like a deployed one's.
+func (r *CloudStackMachineReconciliationRunner) AdoptInstanceIfRequested() (retRes ctrl.Result, reterr error) {
+	csMachine := r.ReconciliationSubject
+	if !csMachine.Spec.AdoptInstance {
+		return ctrl.Result{}, nil
+	} else if conditions.IsTrue(csMachine, infrav1.InstanceAdoptedCondition) {
+		return ctrl.Result{}, r.CSUser.ResolveVMInstanceDetails(r.RequestCtx, csMachine)
+	}
+
+	if err := r.CSUser.AdoptVMInstance(r.RequestCtx, csMachine, r.CSCluster, r.FailureDomain); err != nil {
+		r.Recorder.Eventf(csMachine, "Warning", "Adopting", CSMachineAdoptionFailed, err.Error())
+		conditions.MarkFalse(csMachine, infrav1.InstanceAdoptedCondition, infrav1.InstanceAdoptionFailedReason,
+			clusterv1.ConditionSeverityWarning, err.Error())
+		return r.RequeueWithMessage(fmt.Sprintf(CSMachineAdoptionFailed, err.Error()))
+	}
+	controllerutil.AddFinalizer(csMachine, infrav1.MachineFinalizer)
+	conditions.MarkTrue(csMachine, infrav1.InstanceAdoptedCondition)
+	r.Recorder.Eventf(csMachine, "Normal", "Adopted", CSMachineAdoptionSuccess, *csMachine.Spec.InstanceID)
+	r.Log.Info("Adopted instance", "instanceID", *csMachine.Spec.InstanceID)
+	return ctrl.Result{}, nil
+}
+
+// deletionPolicy returns what becomes of a machine's instance when the machine is deleted. Adopted instances are
+// retained unless the machine says otherwise.
+func deletionPolicy(csMachine *infrav1.CloudStackMachine) infrav1.MachineDeletionPolicy {
+	if csMachine.Spec.DeletionPolicy != "" {
+		return csMachine.Spec.DeletionPolicy
+	} else if csMachine.Spec.AdoptInstance {
+		return infrav1.DeletionPolicyRetain
+	}
+	return infrav1.DeletionPolicyExpunge
+}
+
+// ResizeInstanceIfNeeded scales the instance of a machine with InPlaceResize when its offering or custom compute
+// details change. The resize is recorded in the InstanceResized condition before it starts, so the state checker
+// doesn't replace the machine while its instance is stopped for it. The resize is carried on on requeue while the
+// jobs stopping and starting the instance run, keeping the condition as it is.
+func (r *CloudStackMachineReconciliationRunner) ResizeInstanceIfNeeded() (retRes ctrl.Result, reterr error) {
+	csMachine := r.ReconciliationSubject
+	if !csMachine.Spec.InPlaceResize {
+		return ctrl.Result{}, nil
+	}
+	needed, err := r.CSUser.VMInstanceNeedsResize(r.RequestCtx, csMachine, r.FailureDomain)
+	if err != nil {
+		return ctrl.Result{}, err
+	} else if !needed && !resizeUnfinished(csMachine) {
+		conditions.MarkTrue(csMachine, infrav1.InstanceResizedCondition)
+		return ctrl.Result{}, nil
+	}
+
+	if conditions.GetReason(csMachine, infrav1.InstanceResizedCondition) != infrav1.InstanceResizingReason {
+		offering := csMachine.Spec.Offering.Name
+		if offering == "" {
+			offering = csMachine.Spec.Offering.ID
+		}
+		conditions.MarkFalse(csMachine, infrav1.InstanceResizedCondition, infrav1.InstanceResizingReason,
+			clusterv1.ConditionSeverityInfo, "Scaling to offering %s", offering)
+		return r.RequeueWithMessage("Instance resize recorded.")
+	}
+	if err := r.CSUser.ResizeVMInstance(r.RequestCtx, csMachine, r.FailureDomain); cloud.IsJobPending(err) {
+		return ctrl.Result{}, err
+	} else if err != nil {
+		r.Recorder.Eventf(csMachine, "Warning", "Resizing", CSMachineResizeFailed, err.Error())
+		conditions.MarkFalse(csMachine, infrav1.InstanceResizedCondition, infrav1.InstanceResizeFailedReason,
+			clusterv1.ConditionSeverityWarning, err.Error())
+		return r.RequeueWithMessage(fmt.Sprintf(CSMachineResizeFailed, err.Error()))
+	}
+	conditions.MarkTrue(csMachine, infrav1.InstanceResizedCondition)
+	r.Recorder.Event(csMachine, "Normal", "Resized", CSMachineResizeSuccess)
+	r.Log.Info(CSMachineResizeSuccess, "offering", csMachine.Spec.Offering)
+	return ctrl.Result{}, nil
+}
+
+// isResizing reports whether a machine's instance is being resized in place, for no longer than InstanceResizeTimeout.
+func isResizing(csMachine *infrav1.CloudStackMachine) bool {
+	if conditions.GetReason(csMachine, infrav1.InstanceResizedCondition) != infrav1.InstanceResizingReason {
+		return false
+	}
+	return time.Since(conditions.GetLastTransitionTime(csMachine, infrav1.InstanceResizedCondition).Time) < InstanceResizeTimeout
+}
+
+// resizeUnfinished reports whether a machine's instance may still be stopped, or still be starting, for an in-place
+// resize that was started.
+func resizeUnfinished(csMachine *infrav1.CloudStackMachine) bool {
+	return csMachine.Spec.InPlaceResize && conditions.IsFalse(csMachine, infrav1.InstanceResizedCondition)
+}
+
+// ReconcileInstanceTags tags the machine's VM instance and volumes with its cluster and additional tags.
+func (r *CloudStackMachineReconciliationRunner) ReconcileInstanceTags() (retRes ctrl.Result, reterr error) {
+	if r.ReconciliationSubject.Spec.InstanceID == nil {
+		return r.RequeueWithMessage("Instance ID not yet set.")
+	}
+	return ctrl.Result{}, r.CSUser.ReconcileVMInstanceTags(r.RequestCtx, r.ReconciliationSubject, r.CSCluster)
+}
+
 func processCustomMetadata(data []byte, r *CloudStackMachineReconciliationRunner) string {
 	// since cloudstack metadata does not allow custom data added into meta_data, following line is a workaround to specify a hostname name
 	// {{ ds.meta_data.hostname }} is expected to be used as a node name when kubelet register a node
@@ -245,17 +620,31 @@ func processCustomMetadata(data []byte, r *CloudStackMachineReconciliationRunner
 // ConfirmVMStatus checks the Instance's status for running state and requeues otherwise.
 func (r *CloudStackMachineReconciliationRunner) RequeueIfInstanceNotRunning() (retRes ctrl.Result, reterr error) {
 	if r.ReconciliationSubject.Status.InstanceState == "Running" {
+		conditions.MarkTrue(r.ReconciliationSubject, infrav1.InstanceProvisionedCondition)
 		r.Recorder.Event(r.ReconciliationSubject, "Normal", "Running", MachineInstanceRunning)
 		r.Log.Info(MachineInstanceRunning)
 		r.ReconciliationSubject.Status.Ready = true
+		r.ReconciliationSubject.Status.FailureDomainsTried = nil
+		r.ReconciliationSubject.Status.ReschedulingAttempts = 0
+		if conditions.Has(r.ReconciliationSubject, infrav1.InstancePlacedCondition) {
+			conditions.MarkTrue(r.ReconciliationSubject, infrav1.InstancePlacedCondition)
+		}
 	} else if r.ReconciliationSubject.Status.InstanceState == "Error" {
+		conditions.MarkFalse(r.ReconciliationSubject, infrav1.InstanceProvisionedCondition,
+			infrav1.InstanceErrorReason, clusterv1.ConditionSeverityError, MachineInErrorMessage)
 		r.Recorder.Event(r.ReconciliationSubject, "Warning", "Error", MachineInErrorMessage)
 		r.Log.Info(MachineInErrorMessage, "csMachine", r.ReconciliationSubject.GetName())
 		if err := r.K8sClient.Delete(r.RequestCtx, r.CAPIMachine); err != nil {
 			return ctrl.Result{}, err
 		}
 		return ctrl.Result{RequeueAfter: utils.RequeueTimeout}, nil
+	} else if resizeUnfinished(r.ReconciliationSubject) {
+		// Left to ResizeInstanceIfNeeded to start again.
+		r.Log.Info("Instance not running for its resize.", "state", r.ReconciliationSubject.Status.InstanceState)
 	} else {
+		conditions.MarkFalse(r.ReconciliationSubject, infrav1.InstanceProvisionedCondition,
+			infrav1.InstanceNotRunningReason, clusterv1.ConditionSeverityInfo,
+			MachineNotReadyMessage, r.ReconciliationSubject.Status.InstanceState)
 		r.Recorder.Eventf(r.ReconciliationSubject, "Warning", r.ReconciliationSubject.Status.InstanceState, MachineNotReadyMessage, r.ReconciliationSubject.Status.InstanceState)
 		r.Log.Info(fmt.Sprintf(MachineNotReadyMessage, r.ReconciliationSubject.Status.InstanceState))
 		return ctrl.Result{RequeueAfter: utils.RequeueTimeout}, nil
@@ -263,18 +652,33 @@ func (r *CloudStackMachineReconciliationRunner) RequeueIfInstanceNotRunning() (r
 	return ctrl.Result{}, nil
 }
 
-// AddToLBIfNeeded adds instance to load balancer if it is a control plane in an isolated network.
+// AddToLBIfNeeded puts a control plane instance behind the control plane endpoint, using the endpoint provider the
+// CloudStackCluster selects.
 func (r *CloudStackMachineReconciliationRunner) AddToLBIfNeeded() (retRes ctrl.Result, reterr error) {
-	if util.IsControlPlaneMachine(r.CAPIMachine) && r.FailureDomain.Spec.Zone.Network.Type == cloud.NetworkTypeIsolated {
-		r.Log.Info("Assigning VM to load balancer rule.")
-		if r.IsoNet.Spec.Name == "" {
-			return r.RequeueWithMessage("Could not get required Isolated Network for VM, requeueing.")
-		}
-		err := r.CSUser.AssignVMToLoadBalancerRule(r.IsoNet, *r.ReconciliationSubject.Spec.InstanceID)
-		if err != nil {
-			return ctrl.Result{}, err
-		}
+	if !util.IsControlPlaneMachine(r.CAPIMachine) {
+		return ctrl.Result{}, nil
+	}
+	providerName := r.CSCluster.ControlPlaneEndpointProviderFor(&r.FailureDomain.Spec)
+	if providerName == infrav1.EndpointProviderExternal {
+		return ctrl.Result{}, nil
+	}
+	r.Log.Info("Assigning VM to control plane endpoint.", "provider", providerName)
+	if providerName == infrav1.EndpointProviderIsolatedNetwork && r.IsoNet.Spec.Name == "" {
+		conditions.MarkFalse(r.ReconciliationSubject, infrav1.LoadBalancerAttachedCondition,
+			infrav1.WaitingForIsolatedNetworkReason, clusterv1.ConditionSeverityInfo,
+			"Waiting for the isolated network of the control plane endpoint")
+		return r.RequeueWithMessage("Could not get required Isolated Network for VM, requeueing.")
+	}
+	provider, err := r.CSUser.ControlPlaneEndpointProvider(r.CSCluster, r.FailureDomain, r.IsoNet)
+	if err == nil {
+		err = provider.AssignVMToControlPlaneEndpoint(r.RequestCtx, *r.ReconciliationSubject.Spec.InstanceID)
+	}
+	if err != nil {
+		conditions.MarkFalse(r.ReconciliationSubject, infrav1.LoadBalancerAttachedCondition,
+			infrav1.LoadBalancerAttachFailedReason, clusterv1.ConditionSeverityWarning, "%s", err.Error())
+		return ctrl.Result{}, err
 	}
+	conditions.MarkTrue(r.ReconciliationSubject, infrav1.LoadBalancerAttachedCondition)
 	return ctrl.Result{}, nil
 }
 
@@ -296,10 +700,13 @@ func (r *CloudStackMachineReconciliationRunner) GetOrCreateMachineStateChecker()
 }
 
 func (r *CloudStackMachineReconciliationRunner) ReconcileDelete() (retRes ctrl.Result, reterr error) {
+	if deletionPolicy(r.ReconciliationSubject) == infrav1.DeletionPolicyRetain {
+		return r.RetainInstance()
+	}
 	if r.ReconciliationSubject.Spec.InstanceID == nil {
 		// InstanceID is not set until deploying VM finishes which can take minutes, and CloudStack Machine can be deleted before VM deployment complete.
 		// ResolveVMInstanceDetails can get InstanceID by CS machine name
-		err := r.CSClient.ResolveVMInstanceDetails(r.ReconciliationSubject)
+		err := r.CSClient.ResolveVMInstanceDetails(r.RequestCtx, r.ReconciliationSubject)
 		if err != nil {
 			r.ReconciliationSubject.Status.Status = pointer.String(metav1.StatusFailure)
 			r.ReconciliationSubject.Status.Reason = pointer.String(err.Error() +
@@ -316,11 +723,30 @@ func (r *CloudStackMachineReconciliationRunner) ReconcileDelete() (retRes ctrl.R
 	// Use CSClient instead of CSUser here to expunge as admin.
 	// The CloudStack-Go API does not return an error, but the VM won't delete with Expunge set if requested by
 	// non-domain admin user.
-	if err := r.CSClient.DestroyVMInstance(r.ReconciliationSubject); err != nil {
-		if err.Error() == "VM deletion in progress" {
-			r.Log.Info(err.Error())
-			return ctrl.Result{RequeueAfter: utils.DestoryVMRequeueInterval}, nil
+	// Destroying is polled on requeue until the instance is gone.
+	if err := r.CSClient.DestroyVMInstance(r.RequestCtx, r.ReconciliationSubject); cloud.IsJobPending(err) {
+		conditions.MarkFalse(r.ReconciliationSubject, infrav1.InstanceProvisionedCondition,
+			clusterv1.DeletingReason, clusterv1.ConditionSeverityInfo, "%s", err.Error())
+		return ctrl.Result{}, err
+	} else if err != nil {
+		conditions.MarkFalse(r.ReconciliationSubject, infrav1.InstanceProvisionedCondition,
+			clusterv1.DeletionFailedReason, clusterv1.ConditionSeverityWarning, "%s", err.Error())
+		return ctrl.Result{}, err
+	}
+	if retained := r.ReconciliationSubject.Status.RetainedDataDisks; len(retained) > 0 {
+		disks := make([]string, 0, len(retained))
+		for _, disk := range retained {
+			if disk.SnapshotID != "" {
+				disks = append(disks, fmt.Sprintf("volume %s (snapshot %s)", disk.VolumeID, disk.SnapshotID))
+			} else {
+				disks = append(disks, "volume "+disk.VolumeID)
+			}
 		}
+		r.Recorder.Eventf(r.ReconciliationSubject, "Normal", "DataDisksRetained", CSMachineDataDisksRetained,
+			*r.ReconciliationSubject.Spec.InstanceID, strings.Join(disks, ", "))
+		r.Log.Info("Retained data disks", "instanceID", *r.ReconciliationSubject.Spec.InstanceID, "dataDisks", retained)
+	}
+	if err := r.ReleaseStaticIP(); err != nil {
 		return ctrl.Result{}, err
 	}
 
@@ -329,6 +755,26 @@ func (r *CloudStackMachineReconciliationRunner) ReconcileDelete() (retRes ctrl.R
 	return ctrl.Result{}, nil
 }
 
+// RetainInstance deletes a machine while leaving its instance in CloudStack. The instance is taken out of the
+// cluster's load balancers and stripped of the tags that mark it as CAPC's. Its static IP, if any, stays allocated
+// since the instance still holds it.
+func (r *CloudStackMachineReconciliationRunner) RetainInstance() (retRes ctrl.Result, reterr error) {
+	if r.ReconciliationSubject.Spec.InstanceID == nil {
+		if err := r.CSUser.ResolveVMInstanceDetails(r.RequestCtx, r.ReconciliationSubject); err != nil &&
+			!cloud.IsNotFoundError(err) {
+			return ctrl.Result{}, err
+		}
+	}
+	r.Recorder.Eventf(r.ReconciliationSubject, "Normal", "Deleting", CSMachineRetentionMessage,
+		pointer.StringDeref(r.ReconciliationSubject.Spec.InstanceID, r.ReconciliationSubject.Name))
+	if err := r.CSUser.ReleaseVMInstance(r.RequestCtx, r.ReconciliationSubject, r.CSCluster); err != nil {
+		return ctrl.Result{}, err
+	}
+	controllerutil.RemoveFinalizer(r.ReconciliationSubject, infrav1.MachineFinalizer)
+	r.Log.Info("VM Retained", "instanceID", r.ReconciliationSubject.Spec.InstanceID)
+	return ctrl.Result{}, nil
+}
+
 // SetupWithManager registers the machine reconciler to the CAPI controller manager.
 func (reconciler *CloudStackMachineReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	controller, err := ctrl.NewControllerManagedBy(mgr).
@@ -21,13 +21,20 @@ import (
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/utils/pointer"
 	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
+	csReconcilers "sigs.k8s.io/cluster-api-provider-cloudstack/controllers"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/controllers/utils"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/mocks"
 	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta2"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -57,11 +64,12 @@ var _ = Describe("CloudStackMachineReconciler", func() {
 		It("Should call GetOrCreateVMInstance and set Status.Ready to true", func() {
 			// Mock a call to GetOrCreateVMInstance and set the machine to running.
 			mockCloudClient.EXPECT().GetOrCreateVMInstance(
-				gomock.Any(), gomock.Any(), gomock.Any(),
+				gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(),
 				gomock.Any(), gomock.Any(), gomock.Any()).Do(
-				func(arg1, _, _, _, _, _ interface{}) {
+				func(_, arg1, _, _, _, _, _ interface{}) {
 					arg1.(*infrav1.CloudStackMachine).Status.InstanceState = "Running"
 				}).AnyTimes()
+			mockCloudClient.EXPECT().ReconcileVMInstanceTags(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
 
 			// Have to do this here or the reconcile call to GetOrCreateVMInstance may happen too early.
 			setupMachineCRDs()
@@ -82,14 +90,15 @@ var _ = Describe("CloudStackMachineReconciler", func() {
 		It("Should call DestroyVMInstance when CS machine deleted", func() {
 			// Mock a call to GetOrCreateVMInstance and set the machine to running.
 			mockCloudClient.EXPECT().GetOrCreateVMInstance(
-				gomock.Any(), gomock.Any(), gomock.Any(),
+				gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(),
 				gomock.Any(), gomock.Any(), gomock.Any()).Do(
-				func(arg1, _, _, _, _, _ interface{}) {
+				func(_, arg1, _, _, _, _, _ interface{}) {
 					arg1.(*infrav1.CloudStackMachine).Status.InstanceState = "Running"
 					controllerutil.AddFinalizer(arg1.(*infrav1.CloudStackMachine), infrav1.MachineFinalizer)
 				}).AnyTimes()
+			mockCloudClient.EXPECT().ReconcileVMInstanceTags(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
 
-			mockCloudClient.EXPECT().DestroyVMInstance(gomock.Any()).Times(1).Return(nil)
+			mockCloudClient.EXPECT().DestroyVMInstance(gomock.Any(), gomock.Any()).Times(1).Return(nil)
 			// Have to do this here or the reconcile call to GetOrCreateVMInstance may happen too early.
 			setupMachineCRDs()
 
@@ -122,19 +131,20 @@ var _ = Describe("CloudStackMachineReconciler", func() {
 			instanceID := pointer.String("instance-id-123")
 			// Mock a call to GetOrCreateVMInstance and set the machine to running.
 			mockCloudClient.EXPECT().GetOrCreateVMInstance(
-				gomock.Any(), gomock.Any(), gomock.Any(),
+				gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(),
 				gomock.Any(), gomock.Any(), gomock.Any()).Do(
-				func(arg1, _, _, _, _, _ interface{}) {
+				func(_, arg1, _, _, _, _, _ interface{}) {
 					arg1.(*infrav1.CloudStackMachine).Status.InstanceState = "Running"
 					controllerutil.AddFinalizer(arg1.(*infrav1.CloudStackMachine), infrav1.MachineFinalizer)
 				}).AnyTimes()
+			mockCloudClient.EXPECT().ReconcileVMInstanceTags(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
 
-			mockCloudClient.EXPECT().ResolveVMInstanceDetails(gomock.Any()).Do(
-				func(arg1 interface{}) {
+			mockCloudClient.EXPECT().ResolveVMInstanceDetails(gomock.Any(), gomock.Any()).Do(
+				func(_, arg1 interface{}) {
 					arg1.(*infrav1.CloudStackMachine).Spec.InstanceID = instanceID
 				}).AnyTimes().Return(nil)
 
-			mockCloudClient.EXPECT().DestroyVMInstance(gomock.Any()).Times(1).Return(nil)
+			mockCloudClient.EXPECT().DestroyVMInstance(gomock.Any(), gomock.Any()).Times(1).Return(nil)
 			// Have to do this here or the reconcile call to GetOrCreateVMInstance may happen too early.
 			setupMachineCRDs()
 
@@ -171,13 +181,14 @@ var _ = Describe("CloudStackMachineReconciler", func() {
 		It("Should replace ds.meta_data.xxx with proper values.", func() {
 			// Mock a call to GetOrCreateVMInstance and set the machine to running.
 			mockCloudClient.EXPECT().GetOrCreateVMInstance(
-				gomock.Any(), gomock.Any(), gomock.Any(),
+				gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(),
 				gomock.Any(), gomock.Any(), gomock.Any()).Do(
-				func(arg1, _, _, _, _, userdata interface{}) {
+				func(_, arg1, _, _, _, _, userdata interface{}) {
 					expectedUserdata := fmt.Sprintf("%s{{%s}}", dummies.CAPIMachine.Name, dummies.CSMachine1.Spec.FailureDomainName)
 					Ω(userdata == expectedUserdata).Should(BeTrue())
 					arg1.(*infrav1.CloudStackMachine).Status.InstanceState = "Running"
 				}).AnyTimes()
+			mockCloudClient.EXPECT().ReconcileVMInstanceTags(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
 
 			// Have to do this here or the reconcile call to GetOrCreateVMInstance may happen too early.
 			setupMachineCRDs()
@@ -233,11 +244,12 @@ var _ = Describe("CloudStackMachineReconciler", func() {
 				UID:        "uniqueness",
 			})
 			mockCloudClient.EXPECT().GetOrCreateVMInstance(
-				gomock.Any(), gomock.Any(), gomock.Any(),
+				gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(),
 				gomock.Any(), gomock.Any(), gomock.Any()).Do(
-				func(arg1, _, _, _, _, _ interface{}) {
+				func(_, arg1, _, _, _, _, _ interface{}) {
 					arg1.(*infrav1.CloudStackMachine).Status.InstanceState = "Running"
 				}).AnyTimes()
+			mockCloudClient.EXPECT().ReconcileVMInstanceTags(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
 			Ω(fakeCtrlClient.Get(ctx, key, dummies.CSCluster)).Should(Succeed())
 			Ω(fakeCtrlClient.Create(ctx, dummies.CAPIMachine)).Should(Succeed())
 			Ω(fakeCtrlClient.Create(ctx, dummies.CSMachine1)).Should(Succeed())
@@ -263,4 +275,463 @@ var _ = Describe("CloudStackMachineReconciler", func() {
 			}, timeout).Should(BeTrue())
 		})
 	})
+
+	Context("With a fake ctrlRuntimeClient and a CloudStack simulator.", func() {
+		BeforeEach(func() {
+			setupSimulatorTestClient()
+			dummies.CSCluster.Spec.FailureDomains = dummies.CSCluster.Spec.FailureDomains[:1]
+			dummies.CAPIMachine.Name = "someMachine"
+			dummies.CAPIMachine.Spec.Bootstrap.DataSecretName = &dummies.BootstrapSecret.Name
+			dummies.CSMachine1.OwnerReferences = append(dummies.CSMachine1.OwnerReferences, metav1.OwnerReference{
+				Kind:       "Machine",
+				APIVersion: clusterv1.GroupVersion.String(),
+				Name:       dummies.CAPIMachine.Name,
+				UID:        "uniqueness",
+			})
+			Ω(fakeCtrlClient.Create(ctx, dummies.CAPIMachine)).Should(Succeed())
+			Ω(fakeCtrlClient.Create(ctx, dummies.CSMachine1)).Should(Succeed())
+			Ω(fakeCtrlClient.Create(ctx, dummies.CSFailureDomain1)).Should(Succeed())
+			Ω(fakeCtrlClient.Create(ctx, dummies.BootstrapSecret)).Should(Succeed())
+			Ω(fakeCtrlClient.Get(ctx, client.ObjectKeyFromObject(dummies.CSCluster), dummies.CSCluster)).Should(Succeed())
+			setClusterReady(fakeCtrlClient)
+		})
+
+		It("Should deploy a single VM across repeated reconciles and expunge it on deletion.", func() {
+			requestNamespacedName := types.NamespacedName{Namespace: dummies.ClusterNameSpace, Name: dummies.CSMachine1.Name}
+			for i := 0; i < 2; i++ {
+				res, err := MachineReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: requestNamespacedName})
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(res.RequeueAfter).Should(BeZero())
+			}
+			Ω(sim.VirtualMachines()).Should(HaveLen(1))
+			Ω(sim.RequestCount("deployVirtualMachine")).Should(Equal(1))
+
+			tempMachine := &infrav1.CloudStackMachine{}
+			Ω(fakeCtrlClient.Get(ctx, requestNamespacedName, tempMachine)).Should(Succeed())
+			Ω(tempMachine.Status.Ready).Should(BeTrue())
+			Ω(tempMachine.Spec.InstanceID).Should(Equal(pointer.String(sim.VirtualMachines()[0].Id)))
+			Ω(tempMachine.Spec.ProviderID).ShouldNot(BeNil())
+			Ω(conditions.IsTrue(tempMachine, infrav1.BootstrapDataReadyCondition)).Should(BeTrue())
+			Ω(conditions.IsTrue(tempMachine, infrav1.InstanceProvisionedCondition)).Should(BeTrue())
+			Ω(conditions.IsTrue(tempMachine, clusterv1.ReadyCondition)).Should(BeTrue())
+
+			Ω(fakeCtrlClient.Delete(ctx, tempMachine)).Should(Succeed())
+			_, err := MachineReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: requestNamespacedName})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(sim.VirtualMachines()).Should(BeEmpty())
+			Ω(fakeCtrlClient.Get(ctx, requestNamespacedName, tempMachine)).ShouldNot(Succeed())
+		})
+
+		It("Should requeue while CloudStack deploys and destroys the VM, recording its jobs in the machine's status.", func() {
+			requestNamespacedName := types.NamespacedName{Namespace: dummies.ClusterNameSpace, Name: dummies.CSMachine1.Name}
+			tempMachine := &infrav1.CloudStackMachine{}
+			sim.HoldJobs("deployVirtualMachine")
+			res, err := MachineReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: requestNamespacedName})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(res.RequeueAfter).ShouldNot(BeZero())
+			Ω(fakeCtrlClient.Get(ctx, requestNamespacedName, tempMachine)).Should(Succeed())
+			Ω(tempMachine.Status.AsyncJob.Command).Should(Equal(cloud.CommandDeployVM))
+			Ω(tempMachine.Status.Ready).Should(BeFalse())
+			Ω(conditions.GetReason(tempMachine, infrav1.InstanceProvisionedCondition)).Should(
+				Equal(infrav1.AsyncJobPendingReason))
+			Ω(*conditions.GetSeverity(tempMachine, infrav1.InstanceProvisionedCondition)).Should(
+				Equal(clusterv1.ConditionSeverityInfo))
+
+			sim.ReleaseJobs("deployVirtualMachine")
+			_, err = MachineReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: requestNamespacedName})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(fakeCtrlClient.Get(ctx, requestNamespacedName, tempMachine)).Should(Succeed())
+			Ω(tempMachine.Status.AsyncJob).Should(BeNil())
+			Ω(tempMachine.Status.Ready).Should(BeTrue())
+			Ω(sim.RequestCount("deployVirtualMachine")).Should(Equal(1))
+
+			sim.HoldJobs("destroyVirtualMachine")
+			Ω(fakeCtrlClient.Delete(ctx, tempMachine)).Should(Succeed())
+			res, err = MachineReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: requestNamespacedName})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(res.RequeueAfter).ShouldNot(BeZero())
+			Ω(fakeCtrlClient.Get(ctx, requestNamespacedName, tempMachine)).Should(Succeed())
+			Ω(tempMachine.Status.AsyncJob.Command).Should(Equal(cloud.CommandDestroyVM))
+			Ω(conditions.GetReason(tempMachine, infrav1.InstanceProvisionedCondition)).Should(Equal(clusterv1.DeletingReason))
+
+			sim.ReleaseJobs("destroyVirtualMachine")
+			_, err = MachineReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: requestNamespacedName})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(fakeCtrlClient.Get(ctx, requestNamespacedName, tempMachine)).ShouldNot(Succeed())
+			Ω(sim.RequestCount("destroyVirtualMachine")).Should(Equal(1))
+		})
+
+		It("Should record an in-place resize in the machine's conditions before scaling the VM.", func() {
+			requestNamespacedName := types.NamespacedName{Namespace: dummies.ClusterNameSpace, Name: dummies.CSMachine1.Name}
+			_, err := MachineReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: requestNamespacedName})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(sim.VirtualMachines()).Should(HaveLen(1))
+
+			large := sim.AddServiceOffering("large", 4, 8192)
+			tempMachine := &infrav1.CloudStackMachine{}
+			Ω(fakeCtrlClient.Get(ctx, requestNamespacedName, tempMachine)).Should(Succeed())
+			tempMachine.Spec.InPlaceResize = true
+			tempMachine.Spec.Offering = infrav1.CloudStackResourceIdentifier{Name: large.Name}
+			Ω(fakeCtrlClient.Update(ctx, tempMachine)).Should(Succeed())
+
+			res, err := MachineReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: requestNamespacedName})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(res.RequeueAfter).ShouldNot(BeZero())
+			Ω(fakeCtrlClient.Get(ctx, requestNamespacedName, tempMachine)).Should(Succeed())
+			Ω(conditions.GetReason(tempMachine, infrav1.InstanceResizedCondition)).Should(Equal(infrav1.InstanceResizingReason))
+			Ω(sim.RequestCount("scaleVirtualMachine")).Should(BeZero())
+
+			res, err = MachineReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: requestNamespacedName})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(res.RequeueAfter).Should(BeZero())
+			Ω(fakeCtrlClient.Get(ctx, requestNamespacedName, tempMachine)).Should(Succeed())
+			Ω(conditions.IsTrue(tempMachine, infrav1.InstanceResizedCondition)).Should(BeTrue())
+			Ω(sim.VirtualMachines()[0].Serviceofferingid).Should(Equal(large.Id))
+			Ω(tempMachine.Status.InstanceState).Should(Equal("Running"))
+		})
+
+		It("Should deploy the VM with an address from its IP pool and release it on deletion.", func() {
+			requestNamespacedName := types.NamespacedName{Namespace: dummies.ClusterNameSpace, Name: dummies.CSMachine1.Name}
+			Ω(fakeCtrlClient.Create(ctx, dummies.CSIPPool1)).Should(Succeed())
+			tempMachine := &infrav1.CloudStackMachine{}
+			Ω(fakeCtrlClient.Get(ctx, requestNamespacedName, tempMachine)).Should(Succeed())
+			tempMachine.Spec.IPPoolRef = &corev1.LocalObjectReference{Name: dummies.CSIPPool1.Name}
+			Ω(fakeCtrlClient.Update(ctx, tempMachine)).Should(Succeed())
+
+			_, err := MachineReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: requestNamespacedName})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(sim.VirtualMachines()).Should(HaveLen(1))
+			Ω(sim.VirtualMachines()[0].Nic[0].Ipaddress).Should(Equal("10.10.0.20"))
+
+			pool := &infrav1.CloudStackIPPool{}
+			Ω(fakeCtrlClient.Get(ctx, client.ObjectKeyFromObject(dummies.CSIPPool1), pool)).Should(Succeed())
+			Ω(pool.Status.Allocations).Should(Equal(map[string]string{"10.10.0.20": dummies.CSMachine1.Name}))
+			Ω(fakeCtrlClient.Get(ctx, requestNamespacedName, tempMachine)).Should(Succeed())
+			Ω(tempMachine.Status.IPAllocation).Should(Equal(&infrav1.CloudStackIPAllocation{
+				Pool: dummies.CSIPPool1.Name, IP: "10.10.0.20"}))
+
+			Ω(fakeCtrlClient.Delete(ctx, tempMachine)).Should(Succeed())
+			_, err = MachineReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: requestNamespacedName})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(sim.VirtualMachines()).Should(BeEmpty())
+			Ω(fakeCtrlClient.Get(ctx, client.ObjectKeyFromObject(dummies.CSIPPool1), pool)).Should(Succeed())
+			Ω(pool.Status.Allocations).Should(BeEmpty())
+		})
+
+		It("Should record the data disks it retains on deletion.", func() {
+			requestNamespacedName := types.NamespacedName{Namespace: dummies.ClusterNameSpace, Name: dummies.CSMachine1.Name}
+			tempMachine := &infrav1.CloudStackMachine{}
+			Ω(fakeCtrlClient.Get(ctx, requestNamespacedName, tempMachine)).Should(Succeed())
+			tempMachine.Spec.DataDiskDeletionPolicy = infrav1.DataDiskDeletionPolicySnapshotAndRetain
+			Ω(fakeCtrlClient.Update(ctx, tempMachine)).Should(Succeed())
+			_, err := MachineReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: requestNamespacedName})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(sim.VirtualMachines()).Should(HaveLen(1))
+
+			Ω(fakeCtrlClient.Get(ctx, requestNamespacedName, tempMachine)).Should(Succeed())
+			Ω(fakeCtrlClient.Delete(ctx, tempMachine)).Should(Succeed())
+			_, err = MachineReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: requestNamespacedName})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(sim.VirtualMachines()).Should(BeEmpty())
+			Ω(sim.Volumes()).Should(HaveLen(1))
+			Ω(sim.Snapshots()).Should(HaveLen(1))
+
+			var events []string
+			for len(fakeRecorder.Events) > 0 {
+				events = append(events, <-fakeRecorder.Events)
+			}
+			Ω(events).Should(ContainElement(And(ContainSubstring("Normal DataDisksRetained"),
+				ContainSubstring(sim.Volumes()[0].Id), ContainSubstring(sim.Snapshots()[0].Id))))
+		})
+
+		It("Should place workers round robin, in the failure domain with the fewest machines.", func() {
+			fd2 := dummies.CSFailureDomain1.DeepCopy()
+			fd2.Name = infrav1.FailureDomainHashedMetaName("fd2", dummies.CAPICluster.Name)
+			fd2.Spec.Name, fd2.ResourceVersion = "fd2", ""
+			Ω(fakeCtrlClient.Create(ctx, fd2)).Should(Succeed())
+			Ω(fakeCtrlClient.Get(ctx, client.ObjectKeyFromObject(dummies.CSCluster), dummies.CSCluster)).Should(Succeed())
+			dummies.CSCluster.Spec.FailureDomains = []infrav1.CloudStackFailureDomainSpec{dummies.CSFailureDomain1.Spec, fd2.Spec}
+			Ω(fakeCtrlClient.Update(ctx, dummies.CSCluster)).Should(Succeed())
+			other := dummies.CSMachine1.DeepCopy()
+			other.Name, other.ResourceVersion = "other", ""
+			other.Spec.FailureDomainName = dummies.CSFailureDomain1.Spec.Name
+			other.Labels[infrav1.FailureDomainLabelName] = dummies.CSFailureDomain1.Name
+			Ω(fakeCtrlClient.Create(ctx, other)).Should(Succeed())
+
+			requestNamespacedName := types.NamespacedName{Namespace: dummies.ClusterNameSpace, Name: dummies.CSMachine1.Name}
+			unplaceWorker(requestNamespacedName)
+			_, err := MachineReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: requestNamespacedName})
+			Ω(err).ShouldNot(HaveOccurred())
+			tempMachine := &infrav1.CloudStackMachine{}
+			Ω(fakeCtrlClient.Get(ctx, requestNamespacedName, tempMachine)).Should(Succeed())
+			Ω(tempMachine.Spec.FailureDomainName).Should(Equal("fd2"))
+			Ω(tempMachine.Labels).Should(HaveKeyWithValue(infrav1.FailureDomainLabelName, fd2.Name))
+			Ω(<-fakeRecorder.Events).Should(ContainSubstring("Normal Placed Placed in failure domain fd2 by the RoundRobin strategy"))
+		})
+
+		It("Should place workers in the failure domain with the most free capacity.", func() {
+			zone2 := sim.AddZone("Zone2")
+			net2 := sim.AddNetwork(zone2.Id, dummies.Net1.Name, dummies.Net1.Type, "10.20.0.0/24")
+			sim.AddTemplate(zone2.Id, dummies.CSMachine1.Spec.Template.Name)
+			fd2 := dummies.CSFailureDomain1.DeepCopy()
+			fd2.Name = infrav1.FailureDomainHashedMetaName("fd2", dummies.CAPICluster.Name)
+			fd2.Spec.Name, fd2.ResourceVersion = "fd2", ""
+			fd2.Spec.Zone = infrav1.CloudStackZoneSpec{ID: zone2.Id, Name: zone2.Name, Network: dummies.Net1}
+			fd2.Spec.Zone.Network.ID = net2.Id
+			Ω(fakeCtrlClient.Create(ctx, fd2)).Should(Succeed())
+			Ω(fakeCtrlClient.Get(ctx, client.ObjectKeyFromObject(dummies.CSCluster), dummies.CSCluster)).Should(Succeed())
+			dummies.CSCluster.Spec.FailureDomains = []infrav1.CloudStackFailureDomainSpec{dummies.CSFailureDomain1.Spec, fd2.Spec}
+			dummies.CSCluster.Spec.FailureDomainPlacement = &infrav1.FailureDomainPlacement{Strategy: infrav1.PlacementStrategyCapacity}
+			Ω(fakeCtrlClient.Update(ctx, dummies.CSCluster)).Should(Succeed())
+			sim.SetZoneCapacity(dummies.Zone1.ID, 0, 64<<30, 58<<30)
+			sim.SetZoneCapacity(dummies.Zone1.ID, 1, 100000, 0)
+			sim.SetZoneCapacity(zone2.Id, 0, 64<<30, 16<<30)
+			sim.SetZoneCapacity(zone2.Id, 1, 100000, 50000)
+
+			requestNamespacedName := types.NamespacedName{Namespace: dummies.ClusterNameSpace, Name: dummies.CSMachine1.Name}
+			unplaceWorker(requestNamespacedName)
+			_, err := MachineReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: requestNamespacedName})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(sim.VirtualMachines()).Should(HaveLen(1))
+			Ω(sim.VirtualMachines()[0].Zoneid).Should(Equal(zone2.Id))
+			Ω(<-fakeRecorder.Events).Should(ContainSubstring("Normal Placed Placed in failure domain fd2 by the Capacity strategy"))
+		})
+
+		It("Should weigh failure domains by free capacity without switching the runner's clients.", func() {
+			zone2 := sim.AddZone("Zone2")
+			fd2 := dummies.CSFailureDomain1.DeepCopy()
+			fd2.Name = infrav1.FailureDomainHashedMetaName("fd2", dummies.CAPICluster.Name)
+			fd2.Spec.Name, fd2.ResourceVersion = "fd2", ""
+			fd2.Spec.Zone = infrav1.CloudStackZoneSpec{ID: zone2.Id, Name: zone2.Name, Network: dummies.Net1}
+			Ω(fakeCtrlClient.Create(ctx, fd2)).Should(Succeed())
+			Ω(fakeCtrlClient.Get(ctx, client.ObjectKeyFromObject(dummies.CSCluster), dummies.CSCluster)).Should(Succeed())
+			dummies.CSCluster.Spec.FailureDomains = []infrav1.CloudStackFailureDomainSpec{dummies.CSFailureDomain1.Spec, fd2.Spec}
+			dummies.CSCluster.Spec.FailureDomainPlacement = &infrav1.FailureDomainPlacement{Strategy: infrav1.PlacementStrategyCapacity}
+			sim.SetZoneCapacity(dummies.Zone1.ID, 0, 64<<30, 58<<30)
+			sim.SetZoneCapacity(dummies.Zone1.ID, 1, 100000, 0)
+			sim.SetZoneCapacity(zone2.Id, 0, 64<<30, 16<<30)
+			sim.SetZoneCapacity(zone2.Id, 1, 100000, 50000)
+
+			r := csReconcilers.NewCSMachineReconciliationRunner()
+			requestNamespacedName := types.NamespacedName{Namespace: dummies.ClusterNameSpace, Name: dummies.CSMachine1.Name}
+			r.UsingBaseReconciler(MachineReconciler.ReconcilerBase).ForRequest(ctrl.Request{NamespacedName: requestNamespacedName}).
+				WithRequestCtx(ctx)
+			r.CSCluster, r.CAPICluster, r.Log = dummies.CSCluster, dummies.CAPICluster, logger
+			csClient, csUser := mocks.NewMockClient(mockCtrl), mocks.NewMockClient(mockCtrl)
+			r.CSClient, r.CSUser = csClient, csUser
+			placed, _, err := r.PlaceWorkerMachine(dummies.CSMachine1)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(placed).Should(Equal("fd2"))
+			Ω(r.CSClient).Should(BeIdenticalTo(csClient))
+			Ω(r.CSUser).Should(BeIdenticalTo(csUser))
+		})
+
+		It("Should reschedule a worker CloudStack has no capacity for to another failure domain.", func() {
+			zone2 := sim.AddZone("Zone2")
+			net2 := sim.AddNetwork(zone2.Id, dummies.Net1.Name, dummies.Net1.Type, "10.20.0.0/24")
+			sim.AddTemplate(zone2.Id, dummies.CSMachine1.Spec.Template.Name)
+			fd2 := dummies.CSFailureDomain1.DeepCopy()
+			fd2.Name = infrav1.FailureDomainHashedMetaName("fd2", dummies.CAPICluster.Name)
+			fd2.Spec.Name, fd2.ResourceVersion = "fd2", ""
+			fd2.Spec.Zone = infrav1.CloudStackZoneSpec{ID: zone2.Id, Name: zone2.Name, Network: dummies.Net1}
+			fd2.Spec.Zone.Network.ID = net2.Id
+			Ω(fakeCtrlClient.Create(ctx, fd2)).Should(Succeed())
+			Ω(fakeCtrlClient.Get(ctx, client.ObjectKeyFromObject(dummies.CSCluster), dummies.CSCluster)).Should(Succeed())
+			dummies.CSCluster.Spec.FailureDomains = []infrav1.CloudStackFailureDomainSpec{dummies.CSFailureDomain1.Spec, fd2.Spec}
+			Ω(fakeCtrlClient.Update(ctx, dummies.CSCluster)).Should(Succeed())
+			sim.SetZoneCapacity(dummies.Zone1.ID, 0, 16<<30, 14<<30)
+
+			requestNamespacedName := types.NamespacedName{Namespace: dummies.ClusterNameSpace, Name: dummies.CSMachine1.Name}
+			unplaceWorker(requestNamespacedName)
+			res, err := MachineReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: requestNamespacedName})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(res.RequeueAfter).ShouldNot(BeZero())
+			Ω(sim.VirtualMachines()).Should(BeEmpty())
+			tempMachine := &infrav1.CloudStackMachine{}
+			Ω(fakeCtrlClient.Get(ctx, requestNamespacedName, tempMachine)).Should(Succeed())
+			Ω(tempMachine.Spec.FailureDomainName).Should(Equal("fd2"))
+			Ω(tempMachine.Spec.InstanceID).Should(BeNil())
+			Ω(tempMachine.Labels).Should(HaveKeyWithValue(infrav1.FailureDomainLabelName, fd2.Name))
+			Ω(tempMachine.Status.FailureDomainsTried).Should(Equal([]string{"fd1"}))
+			Ω(conditions.GetReason(tempMachine, infrav1.InstancePlacedCondition)).Should(Equal(infrav1.InsufficientCapacityReason))
+
+			_, err = MachineReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: requestNamespacedName})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(sim.VirtualMachines()).Should(HaveLen(1))
+			Ω(sim.VirtualMachines()[0].Zoneid).Should(Equal(zone2.Id))
+			Ω(fakeCtrlClient.Get(ctx, requestNamespacedName, tempMachine)).Should(Succeed())
+			Ω(conditions.IsTrue(tempMachine, infrav1.InstancePlacedCondition)).Should(BeTrue())
+			Ω(tempMachine.Status.FailureDomainsTried).Should(BeEmpty())
+			Ω(tempMachine.Status.ReschedulingAttempts).Should(BeZero())
+
+			var events []string
+			for len(fakeRecorder.Events) > 0 {
+				events = append(events, <-fakeRecorder.Events)
+			}
+			Ω(events).Should(ContainElement(ContainSubstring(
+				"Normal Rescheduled No capacity for CloudStack instance in failure domain fd1, rescheduled to fd2")))
+		})
+
+		It("Should keep retrying a worker in its failure domain once CloudStack had no capacity for it in any.", func() {
+			Ω(fakeCtrlClient.Get(ctx, client.ObjectKeyFromObject(dummies.CSCluster), dummies.CSCluster)).Should(Succeed())
+			dummies.CSCluster.Spec.FailureDomains = []infrav1.CloudStackFailureDomainSpec{dummies.CSFailureDomain1.Spec}
+			Ω(fakeCtrlClient.Update(ctx, dummies.CSCluster)).Should(Succeed())
+			sim.SetZoneCapacity(dummies.Zone1.ID, 0, 16<<30, 14<<30)
+
+			requestNamespacedName := types.NamespacedName{Namespace: dummies.ClusterNameSpace, Name: dummies.CSMachine1.Name}
+			unplaceWorker(requestNamespacedName)
+			for i := 0; i < 2; i++ {
+				res, err := MachineReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: requestNamespacedName})
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(res.RequeueAfter).Should(Equal(utils.RequeueTimeout << i))
+			}
+			Ω(sim.RequestCount("deployVirtualMachine")).Should(Equal(2))
+			Ω(sim.VirtualMachines()).Should(BeEmpty())
+			tempMachine := &infrav1.CloudStackMachine{}
+			Ω(fakeCtrlClient.Get(ctx, requestNamespacedName, tempMachine)).Should(Succeed())
+			Ω(tempMachine.Spec.FailureDomainName).Should(Equal("fd1"))
+			Ω(tempMachine.Status.FailureDomainsTried).Should(BeEmpty())
+			Ω(tempMachine.Status.ReschedulingAttempts).Should(BeEquivalentTo(2))
+			Ω(conditions.GetReason(tempMachine, infrav1.InstancePlacedCondition)).Should(Equal(infrav1.FailureDomainsExhaustedReason))
+			Ω(*conditions.GetSeverity(tempMachine, infrav1.InstancePlacedCondition)).Should(Equal(clusterv1.ConditionSeverityError))
+		})
+
+		It("Should keep reporting the failure domains exhausted while rescheduling starts over, until an instance runs.", func() {
+			zone2 := sim.AddZone("Zone2")
+			net2 := sim.AddNetwork(zone2.Id, dummies.Net1.Name, dummies.Net1.Type, "10.20.0.0/24")
+			sim.AddTemplate(zone2.Id, dummies.CSMachine1.Spec.Template.Name)
+			fd2 := dummies.CSFailureDomain1.DeepCopy()
+			fd2.Name = infrav1.FailureDomainHashedMetaName("fd2", dummies.CAPICluster.Name)
+			fd2.Spec.Name, fd2.ResourceVersion = "fd2", ""
+			fd2.Spec.Zone = infrav1.CloudStackZoneSpec{ID: zone2.Id, Name: zone2.Name, Network: dummies.Net1}
+			fd2.Spec.Zone.Network.ID = net2.Id
+			Ω(fakeCtrlClient.Create(ctx, fd2)).Should(Succeed())
+			Ω(fakeCtrlClient.Get(ctx, client.ObjectKeyFromObject(dummies.CSCluster), dummies.CSCluster)).Should(Succeed())
+			dummies.CSCluster.Spec.FailureDomains = []infrav1.CloudStackFailureDomainSpec{dummies.CSFailureDomain1.Spec, fd2.Spec}
+			Ω(fakeCtrlClient.Update(ctx, dummies.CSCluster)).Should(Succeed())
+			sim.SetZoneCapacity(dummies.Zone1.ID, 0, 16<<30, 14<<30)
+			sim.SetZoneCapacity(zone2.Id, 0, 16<<30, 14<<30)
+
+			requestNamespacedName := types.NamespacedName{Namespace: dummies.ClusterNameSpace, Name: dummies.CSMachine1.Name}
+			unplaceWorker(requestNamespacedName)
+			// Out of fd1 into fd2, out of fd2 with every failure domain tried, and out of fd2 again into fd1.
+			for i := 0; i < 3; i++ {
+				_, err := MachineReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: requestNamespacedName})
+				Ω(err).ShouldNot(HaveOccurred())
+			}
+			tempMachine := &infrav1.CloudStackMachine{}
+			Ω(fakeCtrlClient.Get(ctx, requestNamespacedName, tempMachine)).Should(Succeed())
+			Ω(tempMachine.Spec.FailureDomainName).Should(Equal("fd1"))
+			Ω(tempMachine.Status.FailureDomainsTried).Should(Equal([]string{"fd2"}))
+			Ω(conditions.GetReason(tempMachine, infrav1.InstancePlacedCondition)).Should(Equal(infrav1.FailureDomainsExhaustedReason))
+
+			sim.SetZoneCapacity(dummies.Zone1.ID, 0, 16<<30, 0)
+			_, err := MachineReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: requestNamespacedName})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(sim.VirtualMachines()).Should(HaveLen(1))
+			Ω(fakeCtrlClient.Get(ctx, requestNamespacedName, tempMachine)).Should(Succeed())
+			Ω(conditions.IsTrue(tempMachine, infrav1.InstancePlacedCondition)).Should(BeTrue())
+		})
+
+		It("Should leave a worker pinned to its failure domain there when CloudStack has no capacity for it.", func() {
+			sim.SetZoneCapacity(dummies.Zone1.ID, 0, 16<<30, 14<<30)
+
+			requestNamespacedName := types.NamespacedName{Namespace: dummies.ClusterNameSpace, Name: dummies.CSMachine1.Name}
+			_, err := MachineReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: requestNamespacedName})
+			Ω(cloud.IsCapacityError(err)).Should(BeTrue())
+			tempMachine := &infrav1.CloudStackMachine{}
+			Ω(fakeCtrlClient.Get(ctx, requestNamespacedName, tempMachine)).Should(Succeed())
+			Ω(tempMachine.Status.FailureDomainsTried).Should(BeEmpty())
+			Ω(conditions.Has(tempMachine, infrav1.InstancePlacedCondition)).Should(BeFalse())
+			Ω(tempMachine.Finalizers).Should(ContainElement(infrav1.MachineFinalizer))
+		})
+
+		It("Should fail a machine whose template isn't found rather than retry it.", func() {
+			requestNamespacedName := types.NamespacedName{Namespace: dummies.ClusterNameSpace, Name: dummies.CSMachine1.Name}
+			tempMachine := &infrav1.CloudStackMachine{}
+			Ω(fakeCtrlClient.Get(ctx, requestNamespacedName, tempMachine)).Should(Succeed())
+			tempMachine.Spec.Template = infrav1.CloudStackResourceIdentifier{Name: "missing-template"}
+			Ω(fakeCtrlClient.Update(ctx, tempMachine)).Should(Succeed())
+
+			for i := 0; i < 2; i++ {
+				res, err := MachineReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: requestNamespacedName})
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(res.RequeueAfter).Should(BeZero())
+			}
+			Ω(sim.RequestCount("listTemplates")).Should(Equal(1))
+			Ω(sim.VirtualMachines()).Should(BeEmpty())
+			Ω(fakeCtrlClient.Get(ctx, requestNamespacedName, tempMachine)).Should(Succeed())
+			Ω(*tempMachine.Status.FailureReason).Should(Equal(capierrors.InvalidConfigurationMachineError))
+			Ω(*tempMachine.Status.FailureMessage).Should(ContainSubstring("missing-template"))
+			Ω(conditions.GetReason(tempMachine, infrav1.InstanceProvisionedCondition)).Should(
+				Equal(infrav1.InstanceProvisionFailedReason))
+			Ω(*conditions.GetSeverity(tempMachine, clusterv1.ReadyCondition)).Should(Equal(clusterv1.ConditionSeverityError))
+		})
+
+		It("Should fail a machine its account has no VMs left for.", func() {
+			sim.SetAccountVMsAvailable(sim.Accounts()[0].Id, 0)
+
+			requestNamespacedName := types.NamespacedName{Namespace: dummies.ClusterNameSpace, Name: dummies.CSMachine1.Name}
+			_, err := MachineReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: requestNamespacedName})
+			Ω(err).ShouldNot(HaveOccurred())
+			tempMachine := &infrav1.CloudStackMachine{}
+			Ω(fakeCtrlClient.Get(ctx, requestNamespacedName, tempMachine)).Should(Succeed())
+			Ω(*tempMachine.Status.FailureReason).Should(Equal(capierrors.InsufficientResourcesMachineError))
+			Ω(sim.VirtualMachines()).Should(BeEmpty())
+		})
+
+		It("Should adopt an existing VM rather than deploy one, and retain it on deletion.", func() {
+			requestNamespacedName := types.NamespacedName{Namespace: dummies.ClusterNameSpace, Name: dummies.CSMachine1.Name}
+			tempMachine := &infrav1.CloudStackMachine{}
+			Ω(fakeCtrlClient.Get(ctx, requestNamespacedName, tempMachine)).Should(Succeed())
+			tempMachine.Spec.AdoptInstance = true
+			Ω(fakeCtrlClient.Update(ctx, tempMachine)).Should(Succeed())
+
+			// Nothing to adopt yet.
+			res, err := MachineReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: requestNamespacedName})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(res.RequeueAfter).ShouldNot(BeZero())
+			Ω(sim.VirtualMachines()).Should(BeEmpty())
+			Ω(fakeCtrlClient.Get(ctx, requestNamespacedName, tempMachine)).Should(Succeed())
+			Ω(conditions.GetReason(tempMachine, infrav1.InstanceAdoptedCondition)).Should(Equal(infrav1.InstanceAdoptionFailedReason))
+
+			csClient, err := cloud.NewClientFromConf(dummies.SimulatorConf, nil)
+			Ω(err).ShouldNot(HaveOccurred())
+			existing := dummies.CSMachine1.DeepCopy()
+			Ω(csClient.GetOrCreateVMInstance(ctx, existing, dummies.CAPIMachine, dummies.CSCluster,
+				dummies.CSFailureDomain1, dummies.CSAffinityGroup, "")).Should(Succeed())
+
+			for i := 0; i < 2; i++ {
+				res, err = MachineReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: requestNamespacedName})
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(res.RequeueAfter).Should(BeZero())
+			}
+			Ω(sim.RequestCount("deployVirtualMachine")).Should(Equal(1))
+			Ω(fakeCtrlClient.Get(ctx, requestNamespacedName, tempMachine)).Should(Succeed())
+			Ω(conditions.IsTrue(tempMachine, infrav1.InstanceAdoptedCondition)).Should(BeTrue())
+			Ω(tempMachine.Spec.InstanceID).Should(Equal(existing.Spec.InstanceID))
+			Ω(tempMachine.Status.Ready).Should(BeTrue())
+			Ω(sim.Tags(*existing.Spec.InstanceID)).Should(HaveKey(cloud.AdoptedByCAPCTagName))
+
+			Ω(fakeCtrlClient.Delete(ctx, tempMachine)).Should(Succeed())
+			_, err = MachineReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: requestNamespacedName})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(sim.VirtualMachines()).Should(HaveLen(1))
+			Ω(sim.Tags(*existing.Spec.InstanceID)).ShouldNot(HaveKey(cloud.AdoptedByCAPCTagName))
+			Ω(sim.Tags(*existing.Spec.InstanceID)).ShouldNot(HaveKey(cloud.CreatedByCAPCTagName))
+			Ω(fakeCtrlClient.Get(ctx, requestNamespacedName, tempMachine)).ShouldNot(Succeed())
+		})
+	})
 })
+
+// unplaceWorker clears the failure domain of a CloudStackMachine and its CAPI machine, leaving CAPC to place it.
+func unplaceWorker(key types.NamespacedName) {
+	csMachine := &infrav1.CloudStackMachine{}
+	Ω(fakeCtrlClient.Get(ctx, key, csMachine)).Should(Succeed())
+	csMachine.Spec.FailureDomainName = ""
+	Ω(fakeCtrlClient.Update(ctx, csMachine)).Should(Succeed())
+	capiMachine := &clusterv1.Machine{}
+	Ω(fakeCtrlClient.Get(ctx, client.ObjectKeyFromObject(dummies.CAPIMachine), capiMachine)).Should(Succeed())
+	capiMachine.Spec.FailureDomain = nil
+	Ω(fakeCtrlClient.Update(ctx, capiMachine)).Should(Succeed())
+}
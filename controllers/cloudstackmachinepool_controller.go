@@ -0,0 +1,516 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1beta1"
+	exputil "sigs.k8s.io/cluster-api/exp/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
+	csCtrlrUtils "sigs.k8s.io/cluster-api-provider-cloudstack/controllers/utils"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
+)
+
+const (
+	CSMachinePoolInstanceCreated   = "Created instance %s in failure domain %s"
+	CSMachinePoolInstanceDestroyed = "Destroyed instance %s in failure domain %s"
+	CSMachinePoolCreationFailed    = "Creating instance in failure domain %s failed: %s"
+	CSMachinePoolNoFailureDomains  = "No failure domain to place instances in"
+)
+
+//+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=cloudstackmachinepools,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=cloudstackmachinepools/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=cloudstackmachinepools/finalizers,verbs=update
+//+kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machinepools;machinepools/status,verbs=get;list;watch
+
+// CloudStackMachinePoolReconciliationRunner is a ReconciliationRunner with extensions specific to CloudStack machine
+// pool reconciliation.
+type CloudStackMachinePoolReconciliationRunner struct {
+	*csCtrlrUtils.ReconciliationRunner
+	ReconciliationSubject *infrav1.CloudStackMachinePool
+	CAPIMachinePool       *expv1.MachinePool
+	// FailureDomains are the failure domains the pool places instances in, or still has instances in, by name.
+	FailureDomains map[string]*infrav1.CloudStackFailureDomain
+}
+
+// CloudStackMachinePoolReconciler reconciles a CloudStackMachinePool object
+type CloudStackMachinePoolReconciler struct {
+	csCtrlrUtils.ReconcilerBase
+}
+
+// Initialize a new CloudStackMachinePool reconciliation runner with concrete types and initialized member fields.
+func NewCSMachinePoolReconciliationRunner() *CloudStackMachinePoolReconciliationRunner {
+	// Set concrete type and init pointers.
+	r := &CloudStackMachinePoolReconciliationRunner{ReconciliationSubject: &infrav1.CloudStackMachinePool{}}
+	r.CAPIMachinePool = &expv1.MachinePool{}
+	r.FailureDomains = map[string]*infrav1.CloudStackFailureDomain{}
+	// Setup the base runner. Initializes pointers and links reconciliation methods.
+	r.ReconciliationRunner = csCtrlrUtils.NewRunner(r, r.ReconciliationSubject, "CloudStackMachinePool")
+	return r
+}
+
+func (reconciler *CloudStackMachinePoolReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	r := NewCSMachinePoolReconciliationRunner()
+	r.UsingBaseReconciler(reconciler.ReconcilerBase).ForRequest(req).WithRequestCtx(ctx)
+	r.WithAdditionalCommonStages(r.GetFailureDomains)
+	r.WithReadySummary(infrav1.BootstrapDataReadyCondition, infrav1.InstanceProvisionedCondition)
+	return r.RunBaseReconciliationStages()
+}
+
+func (r *CloudStackMachinePoolReconciliationRunner) Reconcile() (ctrl.Result, error) {
+	return r.RunReconciliationStages(
+		r.GetParent(r.ReconciliationSubject, r.CAPIMachinePool),
+		r.RequeueIfCloudStackClusterNotReady,
+		r.GetOrCreateInstanceGroups,
+		r.ListInstances,
+		r.ScaleInstances,
+	)
+}
+
+// placesIn returns the names of the failure domains the pool places new instances in: those it lists, or all of the
+// CloudStackCluster's, less those the CloudStackCluster leaves out of machine placement as unhealthy.
+func (r *CloudStackMachinePoolReconciliationRunner) placesIn() []string {
+	names := r.ReconciliationSubject.Spec.FailureDomains
+	if len(names) == 0 {
+		for _, fdSpec := range r.CSCluster.Spec.FailureDomains {
+			names = append(names, fdSpec.Name)
+		}
+	}
+	placeable := make([]string, 0, len(names))
+	for _, name := range names {
+		if _, listed := r.CSCluster.Status.FailureDomains[name]; r.CSCluster.Status.FailureDomains == nil || listed {
+			placeable = append(placeable, name)
+		}
+	}
+	return placeable
+}
+
+// GetFailureDomains fetches the failure domains the pool places instances in, and those it has instance groups in.
+// Failure domains that are gone are left out, so that deletion can proceed without them.
+func (r *CloudStackMachinePoolReconciliationRunner) GetFailureDomains() (ctrl.Result, error) {
+	names := r.placesIn()
+	for name := range r.ReconciliationSubject.Status.InstanceGroups {
+		names = append(names, name)
+	}
+	for _, name := range names {
+		if _, fetched := r.FailureDomains[name]; fetched {
+			continue
+		}
+		fd := &infrav1.CloudStackFailureDomain{}
+		if res, err := r.GetObjectByName(infrav1.FailureDomainHashedMetaName(name, r.CAPICluster.Name), fd)(); r.ShouldReturn(res, err) {
+			return res, err
+		}
+		if fd.Name != "" {
+			r.FailureDomains[name] = fd
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// instanceGroupName is the name of the pool's CloudStack instance group in a failure domain.
+func (r *CloudStackMachinePoolReconciliationRunner) instanceGroupName(fd *infrav1.CloudStackFailureDomain) string {
+	return r.ReconciliationSubject.Name + "-" + fd.Name
+}
+
+// GetOrCreateInstanceGroups makes sure the pool has an instance group in each failure domain it places instances in.
+func (r *CloudStackMachinePoolReconciliationRunner) GetOrCreateInstanceGroups() (ctrl.Result, error) {
+	// Have deletion wait for the instances and instance groups to be removed.
+	controllerutil.AddFinalizer(r.ReconciliationSubject, infrav1.MachinePoolFinalizer)
+	if r.ReconciliationSubject.Status.InstanceGroups == nil {
+		r.ReconciliationSubject.Status.InstanceGroups = map[string]string{}
+	}
+	for _, name := range r.placesIn() {
+		fd, found := r.FailureDomains[name]
+		if !found {
+			return r.RequeueWithMessage("Failure domain not found.", "failureDomainName", name)
+		} else if _, created := r.ReconciliationSubject.Status.InstanceGroups[name]; created {
+			continue
+		}
+		if res, err := r.AsFailureDomainUser(&fd.Spec)(); r.ShouldReturn(res, err) {
+			return res, err
+		}
+		groupID, err := r.CSUser.GetOrCreateInstanceGroup(r.RequestCtx, r.instanceGroupName(fd))
+		if err != nil {
+			return r.ReturnWrappedError(err, "getting or creating instance group in failure domain "+name)
+		}
+		r.ReconciliationSubject.Status.InstanceGroups[name] = groupID
+	}
+	return ctrl.Result{}, nil
+}
+
+// instanceSpecHash hashes the pool's instance spec, so instances deployed with an older spec can be told apart.
+func (r *CloudStackMachinePoolReconciliationRunner) instanceSpecHash() (string, error) {
+	spec, err := json.Marshal(r.ReconciliationSubject.Spec.CloudStackMachinePoolInstanceSpec)
+	if err != nil {
+		return "", errors.Wrap(err, "hashing instance spec")
+	}
+	hash := fnv.New32a()
+	_, _ = hash.Write(spec)
+	return fmt.Sprintf("%08x", hash.Sum32()), nil
+}
+
+// ListInstances lists the VM instances in the pool's instance groups into its status. Instances are up-to-date if
+// their name carries the hash of the current instance spec, and they're in a failure domain the pool still uses.
+func (r *CloudStackMachinePoolReconciliationRunner) ListInstances() (ctrl.Result, error) {
+	specHash, err := r.instanceSpecHash()
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	listed := map[string]bool{}
+	for _, name := range r.ReconciliationSubject.Spec.FailureDomains {
+		listed[name] = true
+	}
+
+	instances := []infrav1.CloudStackMachinePoolInstance{}
+	for name, groupID := range r.ReconciliationSubject.Status.InstanceGroups {
+		fd, found := r.FailureDomains[name]
+		if !found {
+			continue
+		}
+		if res, err := r.AsFailureDomainUser(&fd.Spec)(); r.ShouldReturn(res, err) {
+			return res, err
+		}
+		groupInstances, err := r.CSUser.ListInstanceGroupVMs(r.RequestCtx, groupID)
+		if err != nil {
+			return r.ReturnWrappedError(err, "listing instances in failure domain "+name)
+		}
+		for _, instance := range groupInstances {
+			instance.FailureDomainName = name
+			instance.UpToDate = (len(listed) == 0 || listed[name]) &&
+				strings.HasPrefix(instance.Name, r.ReconciliationSubject.Name+"-"+specHash+"-")
+			instances = append(instances, instance)
+		}
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].Name < instances[j].Name })
+	r.ReconciliationSubject.Status.Instances = instances
+	return ctrl.Result{}, nil
+}
+
+// ScaleInstances brings the pool to the number of up-to-date instances its MachinePool asks for, creating or
+// destroying at most a batch of instances at a time. Instances in the Error state are destroyed first. New instances
+// are added before outdated ones are destroyed, and running instances are only destroyed while enough others run.
+func (r *CloudStackMachinePoolReconciliationRunner) ScaleInstances() (ctrl.Result, error) {
+	desired := 1
+	if r.CAPIMachinePool.Spec.Replicas != nil {
+		desired = int(*r.CAPIMachinePool.Spec.Replicas)
+	}
+	batch := r.ReconciliationSubject.MaxBatch()
+
+	instances := append([]infrav1.CloudStackMachinePoolInstance{}, r.ReconciliationSubject.Status.Instances...)
+	for _, instance := range instances {
+		if instance.State == "Error" {
+			if err := r.destroyInstance(instance); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+	}
+
+	total, upToDate, running := r.countInstances()
+
+	if toCreate := minInt(batch, desired-upToDate, desired+batch-total); toCreate > 0 {
+		if res, err := r.createInstances(toCreate); r.ShouldReturn(res, err) {
+			return res, err
+		}
+	} else if excess := total - desired; excess > 0 {
+		if err := r.destroyExcessInstances(minInt(batch, excess), running-desired, upToDate-desired); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	r.setProviderIDs(desired)
+
+	if total, upToDate, running := r.countInstances(); total != desired || upToDate != desired || running != desired {
+		conditions.MarkFalse(r.ReconciliationSubject, infrav1.InstanceProvisionedCondition,
+			infrav1.ScalingReason, clusterv1.ConditionSeverityInfo,
+			"%d of %d instances up to date and running", minInt(upToDate, running), desired)
+		return r.RequeueWithMessage("Machine pool instances not yet scaled.",
+			"desired", desired, "instances", total, "upToDate", upToDate, "running", running)
+	}
+	conditions.MarkTrue(r.ReconciliationSubject, infrav1.InstanceProvisionedCondition)
+	return ctrl.Result{}, nil
+}
+
+// countInstances counts the pool's instances, and how many of them are up-to-date and running.
+func (r *CloudStackMachinePoolReconciliationRunner) countInstances() (total, upToDate, running int) {
+	for _, instance := range r.ReconciliationSubject.Status.Instances {
+		if instance.UpToDate {
+			upToDate++
+		}
+		if instance.State == "Running" {
+			running++
+		}
+	}
+	return len(r.ReconciliationSubject.Status.Instances), upToDate, running
+}
+
+// createInstances creates up-to-date instances, placing each in the failure domain that holds the fewest of the
+// pool's instances for its weight. Failure domains CloudStack finds no capacity in are passed over for the rest of the
+// batch.
+func (r *CloudStackMachinePoolReconciliationRunner) createInstances(count int) (ctrl.Result, error) {
+	dataSecretName := r.CAPIMachinePool.Spec.Template.Spec.Bootstrap.DataSecretName
+	if dataSecretName == nil {
+		conditions.MarkFalse(r.ReconciliationSubject, infrav1.BootstrapDataReadyCondition,
+			infrav1.WaitingForBootstrapDataReason, clusterv1.ConditionSeverityInfo, BootstrapDataNotReady)
+		return r.RequeueWithMessage(BootstrapDataNotReady + ".")
+	}
+	secret := &corev1.Secret{}
+	key := client.ObjectKey{Namespace: r.CAPIMachinePool.Namespace, Name: *dataSecretName}
+	if err := r.K8sClient.Get(r.RequestCtx, key, secret); err != nil {
+		return ctrl.Result{}, err
+	}
+	data, present := secret.Data["value"]
+	if !present {
+		conditions.MarkFalse(r.ReconciliationSubject, infrav1.BootstrapDataReadyCondition,
+			infrav1.WaitingForBootstrapDataReason, clusterv1.ConditionSeverityInfo, "Bootstrap secret data not yet set")
+		return ctrl.Result{}, errors.New("bootstrap secret data not yet set")
+	}
+	conditions.MarkTrue(r.ReconciliationSubject, infrav1.BootstrapDataReadyCondition)
+	specHash, err := r.instanceSpecHash()
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	exhausted := map[string]bool{}
+	for created := 0; created < count; created++ {
+		fdName, err := r.placeInstance(exhausted)
+		if err != nil {
+			r.Recorder.Event(r.ReconciliationSubject, "Warning", "Creating", CSMachinePoolNoFailureDomains)
+			return r.ReturnWrappedError(err, "placing instance")
+		}
+		fd := r.FailureDomains[fdName]
+		if res, err := r.AsFailureDomainUser(&fd.Spec)(); r.ShouldReturn(res, err) {
+			return res, err
+		}
+
+		name := fmt.Sprintf("%s-%s-%s", r.ReconciliationSubject.Name, specHash, utilrand.String(5))
+		machine := r.ReconciliationSubject.MachineForInstance(name, fdName, nil)
+		userData := hostnameMatcher.ReplaceAllString(string(data), name)
+		userData = failuredomainMatcher.ReplaceAllString(userData, fdName)
+		err = r.CSUser.GetOrCreateInstanceGroupVM(r.RequestCtx, machine, r.CSCluster, fd, r.instanceGroupName(fd), userData)
+		if cloud.IsJobPending(err) { // The instance's state is followed by listing the pool's instances from now on.
+			err = nil
+		}
+		if machine.Spec.InstanceID != nil { // A failed deployment can leave an instance behind too.
+			r.ReconciliationSubject.Status.Instances = append(r.ReconciliationSubject.Status.Instances,
+				infrav1.CloudStackMachinePoolInstance{ID: *machine.Spec.InstanceID, Name: name, FailureDomainName: fdName,
+					State: machine.Status.InstanceState, UpToDate: err == nil})
+		}
+		if cloud.IsCapacityError(err) {
+			r.Recorder.Eventf(r.ReconciliationSubject, "Warning", "Creating", CSMachinePoolCreationFailed, fdName, err.Error())
+			exhausted[fdName] = true
+			continue
+		} else if err != nil {
+			conditions.MarkFalse(r.ReconciliationSubject, infrav1.InstanceProvisionedCondition,
+				infrav1.InstanceProvisionFailedReason, clusterv1.ConditionSeverityWarning,
+				CSMachinePoolCreationFailed, fdName, err.Error())
+			r.Recorder.Eventf(r.ReconciliationSubject, "Warning", "Creating", CSMachinePoolCreationFailed, fdName, err.Error())
+			return r.ReturnWrappedError(err, "creating instance "+name)
+		}
+		if err := r.CSUser.ReconcileVMInstanceTags(r.RequestCtx, machine, r.CSCluster); err != nil {
+			return r.ReturnWrappedError(err, "tagging instance "+name)
+		}
+		r.Recorder.Eventf(r.ReconciliationSubject, "Normal", "Created", CSMachinePoolInstanceCreated, name, fdName)
+	}
+	return ctrl.Result{}, nil
+}
+
+// placeInstance picks the failure domain of a new instance by the CloudStackCluster's weights if it places machines
+// by weight, and round robin otherwise, leaving out exhausted failure domains.
+func (r *CloudStackMachinePoolReconciliationRunner) placeInstance(exhausted map[string]bool) (string, error) {
+	var weights map[string]int32
+	if placement := r.CSCluster.Spec.FailureDomainPlacement; placement != nil &&
+		placement.Strategy == infrav1.PlacementStrategyWeighted {
+		weights = placement.Weights
+	}
+	candidates := []csCtrlrUtils.PlacementCandidate{}
+	for _, name := range r.placesIn() {
+		if exhausted[name] {
+			continue
+		}
+		candidate := csCtrlrUtils.PlacementCandidate{Name: name, Weight: 1}
+		if weight, found := weights[name]; found {
+			candidate.Weight = float64(weight)
+		}
+		for _, instance := range r.ReconciliationSubject.Status.Instances {
+			if instance.FailureDomainName == name {
+				candidate.Machines++
+			}
+		}
+		candidates = append(candidates, candidate)
+	}
+	name, _, err := csCtrlrUtils.WeightedPlacer{}.Place(candidates)
+	return name, err
+}
+
+// destroyExcessInstances destroys up to count instances: outdated ones first, and then up-to-date ones beyond the
+// number wanted. No more than removable running instances are destroyed.
+func (r *CloudStackMachinePoolReconciliationRunner) destroyExcessInstances(count, removableRunning, excessUpToDate int) error {
+	instances := r.ReconciliationSubject.Status.Instances
+	// Order victims outdated first, and instances that aren't running before those that are.
+	victims := make([]infrav1.CloudStackMachinePoolInstance, 0, len(instances))
+	for _, upToDate := range []bool{false, true} {
+		for _, running := range []bool{false, true} {
+			for _, instance := range instances {
+				if instance.UpToDate == upToDate && (instance.State == "Running") == running {
+					victims = append(victims, instance)
+				}
+			}
+		}
+	}
+
+	destroyed := 0
+	for _, instance := range victims {
+		if destroyed == count {
+			break
+		} else if instance.State == "Running" && removableRunning <= 0 {
+			continue
+		} else if instance.UpToDate && excessUpToDate <= 0 {
+			continue
+		}
+		if err := r.destroyInstance(instance); err != nil {
+			return err
+		}
+		destroyed++
+		if instance.State == "Running" {
+			removableRunning--
+		}
+		if instance.UpToDate {
+			excessUpToDate--
+		}
+	}
+	return nil
+}
+
+// destroyInstance expunges one of the pool's instances and drops it from the pool's status.
+func (r *CloudStackMachinePoolReconciliationRunner) destroyInstance(instance infrav1.CloudStackMachinePoolInstance) error {
+	fd, found := r.FailureDomains[instance.FailureDomainName]
+	if !found {
+		return errors.Errorf("failure domain %s of instance %s not found", instance.FailureDomainName, instance.Name)
+	}
+	if res, err := r.AsFailureDomainUser(&fd.Spec)(); r.ShouldReturn(res, err) {
+		return errors.Wrapf(err, "getting credentials of failure domain %s", instance.FailureDomainName)
+	}
+	// Use CSClient instead of CSUser to expunge as admin, as the machine controller does.
+	machine := r.ReconciliationSubject.MachineForInstance(instance.Name, instance.FailureDomainName, &instance.ID)
+	if err := r.CSClient.DestroyVMInstance(r.RequestCtx, machine); err != nil && !cloud.IsJobPending(err) {
+		return errors.Wrapf(err, "destroying instance %s", instance.Name)
+	}
+	r.Recorder.Eventf(r.ReconciliationSubject, "Normal", "Destroyed", CSMachinePoolInstanceDestroyed,
+		instance.Name, instance.FailureDomainName)
+
+	remaining := r.ReconciliationSubject.Status.Instances[:0]
+	for _, i := range r.ReconciliationSubject.Status.Instances {
+		if i.ID != instance.ID {
+			remaining = append(remaining, i)
+		}
+	}
+	r.ReconciliationSubject.Status.Instances = remaining
+	return nil
+}
+
+// setProviderIDs reports the pool's instances to CAPI, and how many of them run.
+func (r *CloudStackMachinePoolReconciliationRunner) setProviderIDs(desired int) {
+	providerIDs := []string{}
+	running := 0
+	for _, instance := range r.ReconciliationSubject.Status.Instances {
+		if instance.State == "Error" {
+			continue
+		}
+		providerIDs = append(providerIDs, fmt.Sprintf("cloudstack:///%s", instance.ID))
+		if instance.State == "Running" {
+			running++
+		}
+	}
+	r.ReconciliationSubject.Spec.ProviderIDList = providerIDs
+	r.ReconciliationSubject.Status.Replicas = int32(running)
+	r.ReconciliationSubject.Status.Ready = running >= desired
+}
+
+func minInt(first int, others ...int) int {
+	min := first
+	for _, other := range others {
+		if other < min {
+			min = other
+		}
+	}
+	return min
+}
+
+func (r *CloudStackMachinePoolReconciliationRunner) ReconcileDelete() (ctrl.Result, error) {
+	r.Log.Info("Deleting CloudStackMachinePool")
+	names := make([]string, 0, len(r.ReconciliationSubject.Status.InstanceGroups))
+	for name := range r.ReconciliationSubject.Status.InstanceGroups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		groupID := r.ReconciliationSubject.Status.InstanceGroups[name]
+		fd, found := r.FailureDomains[name]
+		if !found { // Without the failure domain's credentials there's nothing left to remove.
+			delete(r.ReconciliationSubject.Status.InstanceGroups, name)
+			continue
+		}
+		if res, err := r.AsFailureDomainUser(&fd.Spec)(); r.ShouldReturn(res, err) {
+			return res, err
+		}
+		instances, err := r.CSUser.ListInstanceGroupVMs(r.RequestCtx, groupID)
+		if err != nil {
+			return r.ReturnWrappedError(err, "listing instances in failure domain "+name)
+		}
+		for _, instance := range instances {
+			instance.FailureDomainName = name
+			if err := r.destroyInstance(instance); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		if err := r.CSUser.DeleteInstanceGroup(r.RequestCtx, groupID); err != nil {
+			return r.ReturnWrappedError(err, "deleting instance group in failure domain "+name)
+		}
+		delete(r.ReconciliationSubject.Status.InstanceGroups, name)
+	}
+	controllerutil.RemoveFinalizer(r.ReconciliationSubject, infrav1.MachinePoolFinalizer)
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (reconciler *CloudStackMachinePoolReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&infrav1.CloudStackMachinePool{}).
+		// Follow the MachinePool's replicas and bootstrap data.
+		Watches(
+			&source.Kind{Type: &expv1.MachinePool{}},
+			handler.EnqueueRequestsFromMapFunc(exputil.MachinePoolToInfrastructureMapFunc(
+				infrav1.GroupVersion.WithKind("CloudStackMachinePool"), mgr.GetLogger())),
+		).
+		Complete(reconciler)
+}
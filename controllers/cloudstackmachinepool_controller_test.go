@@ -0,0 +1,117 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
+	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta2"
+)
+
+var _ = Describe("CloudStackMachinePoolReconciler", func() {
+	Context("With a fake ctrlRuntimeClient and a CloudStack simulator.", func() {
+		var poolKey client.ObjectKey
+
+		BeforeEach(func() {
+			setupSimulatorTestClient()
+			dummies.CSMachinePool1.Spec.FailureDomains = []string{dummies.CSFailureDomain1.Spec.Name}
+			Ω(fakeCtrlClient.Create(ctx, dummies.CSFailureDomain1)).Should(Succeed())
+			Ω(fakeCtrlClient.Create(ctx, dummies.BootstrapSecret)).Should(Succeed())
+			Ω(fakeCtrlClient.Create(ctx, dummies.CAPIMachinePool)).Should(Succeed())
+			Ω(fakeCtrlClient.Create(ctx, dummies.CSMachinePool1)).Should(Succeed())
+			Ω(fakeCtrlClient.Get(ctx, client.ObjectKeyFromObject(dummies.CSCluster), dummies.CSCluster)).Should(Succeed())
+			setClusterReady(fakeCtrlClient)
+			poolKey = client.ObjectKeyFromObject(dummies.CSMachinePool1)
+		})
+
+		// reconcileUntilScaled reconciles the pool until it stops requeuing, and returns it.
+		reconcileUntilScaled := func() *infrav1.CloudStackMachinePool {
+			for i := 0; i < 10; i++ {
+				res, err := MachinePoolReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: poolKey})
+				Ω(err).ShouldNot(HaveOccurred())
+				if res.RequeueAfter == 0 {
+					break
+				}
+			}
+			csPool := &infrav1.CloudStackMachinePool{}
+			Ω(fakeCtrlClient.Get(ctx, poolKey, csPool)).Should(Succeed())
+			return csPool
+		}
+
+		It("Should scale its instances in an instance group and destroy them on deletion.", func() {
+			csPool := reconcileUntilScaled()
+			Ω(csPool.Status.Ready).Should(BeTrue())
+			Ω(conditions.IsTrue(csPool, clusterv1.ReadyCondition)).Should(BeTrue())
+			Ω(csPool.Status.Replicas).Should(BeEquivalentTo(2))
+			Ω(csPool.Spec.ProviderIDList).Should(HaveLen(2))
+			Ω(sim.InstanceGroups()).Should(HaveLen(1))
+			Ω(csPool.Status.InstanceGroups).Should(HaveKeyWithValue(dummies.CSFailureDomain1.Spec.Name, sim.InstanceGroups()[0].Id))
+			Ω(sim.VirtualMachines()).Should(HaveLen(2))
+			for _, vm := range sim.VirtualMachines() {
+				Ω(vm.Groupid).Should(Equal(sim.InstanceGroups()[0].Id))
+				Ω(csPool.Spec.ProviderIDList).Should(ContainElement("cloudstack:///" + vm.Id))
+			}
+
+			dummies.CAPIMachinePool.Spec.Replicas = pointer.Int32(1)
+			Ω(fakeCtrlClient.Update(ctx, dummies.CAPIMachinePool)).Should(Succeed())
+			csPool = reconcileUntilScaled()
+			Ω(csPool.Status.Replicas).Should(BeEquivalentTo(1))
+			Ω(sim.VirtualMachines()).Should(HaveLen(1))
+
+			Ω(fakeCtrlClient.Delete(ctx, csPool)).Should(Succeed())
+			_, err := MachinePoolReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: poolKey})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(sim.VirtualMachines()).Should(BeEmpty())
+			Ω(sim.InstanceGroups()).Should(BeEmpty())
+			Ω(fakeCtrlClient.Get(ctx, poolKey, csPool)).ShouldNot(Succeed())
+		})
+
+		It("Should roll its instances over to a new offering a batch at a time.", func() {
+			csPool := reconcileUntilScaled()
+			oldInstances := csPool.Status.Instances
+			Ω(oldInstances).Should(HaveLen(2))
+
+			csPool.Spec.Offering.Name = dummies.CSMachine1.Spec.Offering.Name
+			csPool.Spec.MaxBatchSize = pointer.Int32(1)
+			Ω(fakeCtrlClient.Update(ctx, csPool)).Should(Succeed())
+			for i := 0; i < 10; i++ {
+				_, err := MachinePoolReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: poolKey})
+				Ω(err).ShouldNot(HaveOccurred())
+				// Surging by one instance keeps both replicas running throughout.
+				Ω(len(sim.VirtualMachines())).Should(BeNumerically("<=", 3))
+			}
+
+			csPool = reconcileUntilScaled()
+			Ω(csPool.Status.Ready).Should(BeTrue())
+			Ω(csPool.Status.Instances).Should(HaveLen(2))
+			for _, instance := range csPool.Status.Instances {
+				Ω(instance.UpToDate).Should(BeTrue())
+				Ω(oldInstances).ShouldNot(ContainElement(HaveField("ID", instance.ID)))
+			}
+			for _, vm := range sim.VirtualMachines() {
+				Ω(vm.Serviceofferingname).Should(Equal(dummies.CSMachine1.Spec.Offering.Name))
+			}
+		})
+	})
+})
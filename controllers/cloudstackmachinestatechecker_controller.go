@@ -18,7 +18,6 @@ package controllers
 
 import (
 	"context"
-	"strings"
 	"time"
 
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -26,7 +25,9 @@ import (
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
 	csCtrlrUtils "sigs.k8s.io/cluster-api-provider-cloudstack/controllers/utils"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
 )
 
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=cloudstackmachinestatecheckers,verbs=get;list;watch;create;update;patch;delete
@@ -66,6 +67,7 @@ func (r *CloudStackMachineStateCheckerReconciler) Reconcile(ctx context.Context,
 		UsingBaseReconciler(r.ReconcilerBase).
 		ForRequest(req).
 		WithRequestCtx(ctx).
+		WithReadySummary(infrav1.InstanceRunningCondition).
 		RunBaseReconciliationStages()
 }
 
@@ -77,8 +79,8 @@ func (r *CloudStackMachineStateCheckerReconciliationRunner) Reconcile() (ctrl.Re
 		r.GetFailureDomainByName(func() string { return r.CSMachine.Spec.FailureDomainName }, r.FailureDomain),
 		r.AsFailureDomainUser(&r.FailureDomain.Spec),
 		func() (ctrl.Result, error) {
-			if err := r.CSClient.ResolveVMInstanceDetails(r.CSMachine); err != nil {
-				if !strings.Contains(strings.ToLower(err.Error()), "no match found") {
+			if err := r.CSClient.ResolveVMInstanceDetails(r.RequestCtx, r.CSMachine); err != nil {
+				if !cloud.IsNotFoundError(err) {
 					return r.ReturnWrappedError(err, "failed to resolve VM instance details")
 				}
 			}
@@ -91,8 +93,18 @@ func (r *CloudStackMachineStateCheckerReconciliationRunner) Reconcile() (ctrl.Re
 			capiTimeout := csRunning && !capiRunning && csTimeInState > 5*time.Minute
 
 			if csRunning && capiRunning {
+				conditions.MarkTrue(r.ReconciliationSubject, infrav1.InstanceRunningCondition)
 				r.ReconciliationSubject.Status.Ready = true
-			} else if !csRunning || capiTimeout {
+			} else if (!csRunning && !isResizing(r.CSMachine)) || capiTimeout {
+				if capiTimeout {
+					conditions.MarkFalse(r.ReconciliationSubject, infrav1.InstanceRunningCondition,
+						infrav1.NodeUnreachableReason, clusterv1.ConditionSeverityError,
+						"Instance running for %s without its machine running, deleting the machine", csTimeInState)
+				} else {
+					conditions.MarkFalse(r.ReconciliationSubject, infrav1.InstanceRunningCondition,
+						infrav1.InstanceNotRunningReason, clusterv1.ConditionSeverityWarning,
+						"Instance is %s, deleting the machine", r.CSMachine.Status.InstanceState)
+				}
 				r.Log.Info("CloudStack instance in bad state",
 					"name", r.CSMachine.Name,
 					"instance-id", r.CSMachine.Spec.InstanceID,
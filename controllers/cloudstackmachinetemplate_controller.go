@@ -0,0 +1,140 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
+	csCtrlrUtils "sigs.k8s.io/cluster-api-provider-cloudstack/controllers/utils"
+)
+
+//+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=cloudstackmachinetemplates,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=cloudstackmachinetemplates/status,verbs=get;update;patch
+
+// CloudStackMachineTemplateReconciliationRunner is a ReconciliationRunner with extensions specific to CloudStack
+// machine template reconciliation.
+type CloudStackMachineTemplateReconciliationRunner struct {
+	*csCtrlrUtils.ReconciliationRunner
+	ReconciliationSubject *infrav1.CloudStackMachineTemplate
+	FailureDomain         *infrav1.CloudStackFailureDomain
+	HealthProbeInterval   time.Duration
+}
+
+// CloudStackMachineTemplateReconciler publishes the capacity of the machines of CloudStackMachineTemplates.
+type CloudStackMachineTemplateReconciler struct {
+	csCtrlrUtils.ReconcilerBase
+	// HealthProbeInterval is how often the capacity is resolved again, catching changes of the offerings and templates
+	// in CloudStack. It's the interval failure domains are probed at.
+	HealthProbeInterval time.Duration
+}
+
+// Initialize a new CloudStackMachineTemplate reconciliation runner with concrete types and initialized member fields.
+func NewCSMachineTemplateReconciliationRunner() *CloudStackMachineTemplateReconciliationRunner {
+	// Set concrete type and init pointers.
+	r := &CloudStackMachineTemplateReconciliationRunner{ReconciliationSubject: &infrav1.CloudStackMachineTemplate{}}
+	r.FailureDomain = &infrav1.CloudStackFailureDomain{}
+	// Setup the base runner. Initializes pointers and links reconciliation methods.
+	r.ReconciliationRunner = csCtrlrUtils.NewRunner(r, r.ReconciliationSubject, "CloudStackMachineTemplate")
+	return r
+}
+
+func (reconciler *CloudStackMachineTemplateReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	r := NewCSMachineTemplateReconciliationRunner()
+	r.HealthProbeInterval = reconciler.HealthProbeInterval
+	if r.HealthProbeInterval <= 0 {
+		r.HealthProbeInterval = DefaultFailureDomainHealthProbeInterval
+	}
+	r.UsingBaseReconciler(reconciler.ReconcilerBase).ForRequest(req).WithRequestCtx(ctx)
+	return r.RunBaseReconciliationStages()
+}
+
+func (r *CloudStackMachineTemplateReconciliationRunner) Reconcile() (ctrl.Result, error) {
+	return r.RunReconciliationStages(
+		r.GetFailureDomain,
+		r.ResolveCapacity)
+}
+
+// GetFailureDomain fetches the failure domain in whose zone the template's capacity is resolved: the one its machines
+// are pinned to, or else the CloudStackCluster's first. Offerings and templates are typically the same in every zone.
+func (r *CloudStackMachineTemplateReconciliationRunner) GetFailureDomain() (ctrl.Result, error) {
+	name := r.ReconciliationSubject.Spec.Spec.Spec.FailureDomainName
+	if name == "" && len(r.CSCluster.Spec.FailureDomains) > 0 {
+		name = r.CSCluster.Spec.FailureDomains[0].Name
+	}
+	if name == "" {
+		return r.RequeueWithMessage("CloudStackCluster has no failure domains.")
+	}
+	fdName := infrav1.FailureDomainHashedMetaName(name, r.CAPICluster.Name)
+	if res, err := r.GetObjectByName(fdName, r.FailureDomain)(); r.ShouldReturn(res, err) {
+		return res, err
+	}
+	if r.FailureDomain.Name == "" {
+		return r.RequeueWithMessage("Failure domain not found.", "failureDomainName", name)
+	} else if r.FailureDomain.Spec.Zone.ID == "" {
+		return r.RequeueWithMessage("Failure domain zone not resolved.", "failureDomainName", name)
+	}
+	return ctrl.Result{}, nil
+}
+
+// ResolveCapacity publishes the CPU, memory and ephemeral storage of the template's machines, and the nodes they
+// become, as the failure domain's user. It's resolved again every HealthProbeInterval, as the template's offering or
+// CloudStack template may change without the template changing.
+func (r *CloudStackMachineTemplateReconciliationRunner) ResolveCapacity() (ctrl.Result, error) {
+	if res, err := r.AsFailureDomainUser(&r.FailureDomain.Spec)(); r.ShouldReturn(res, err) {
+		return res, err
+	}
+	if err := r.CSUser.ResolveMachineTemplateCapacity(
+		r.RequestCtx,
+		r.ReconciliationSubject, r.CSCluster, r.FailureDomain.Spec.Zone.ID); err != nil {
+		return r.ReturnWrappedError(err, "resolving machine template capacity")
+	}
+	return ctrl.Result{RequeueAfter: r.HealthProbeInterval}, nil
+}
+
+// ReconcileDelete has nothing to clean up: templates hold no CloudStack resources.
+func (r *CloudStackMachineTemplateReconciliationRunner) ReconcileDelete() (ctrl.Result, error) {
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager. Templates of no cluster, such as those of ClusterClasses,
+// are left alone, having no cluster to resolve their capacity in.
+func (reconciler *CloudStackMachineTemplateReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&infrav1.CloudStackMachineTemplate{}).
+		WithEventFilter(
+			predicate.Funcs{
+				CreateFunc: func(e event.CreateEvent) bool { return csCtrlrUtils.ClusterNameOf(e.Object) != "" },
+				UpdateFunc: func(e event.UpdateEvent) bool {
+					// Only reconcile templates as they join a cluster, not on their own status updates.
+					return csCtrlrUtils.ClusterNameOf(e.ObjectNew) != "" &&
+						(csCtrlrUtils.ClusterNameOf(e.ObjectOld) == "" || !reflect.DeepEqual(
+							e.ObjectOld.(*infrav1.CloudStackMachineTemplate).Spec,
+							e.ObjectNew.(*infrav1.CloudStackMachineTemplate).Spec))
+				},
+				DeleteFunc:  func(e event.DeleteEvent) bool { return false },
+				GenericFunc: func(e event.GenericEvent) bool { return csCtrlrUtils.ClusterNameOf(e.Object) != "" },
+			},
+		).
+		Complete(reconciler)
+}
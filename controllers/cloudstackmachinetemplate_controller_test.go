@@ -0,0 +1,97 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
+	csReconcilers "sigs.k8s.io/cluster-api-provider-cloudstack/controllers"
+	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta2"
+)
+
+var _ = Describe("CloudStackMachineTemplateReconciler", func() {
+	Context("With a fake ctrlRuntimeClient and a CloudStack simulator.", func() {
+		var templateKey client.ObjectKey
+
+		BeforeEach(func() {
+			setupSimulatorTestClient()
+			Ω(fakeCtrlClient.Create(ctx, dummies.CSFailureDomain1)).Should(Succeed())
+			// CAPI owns the templates of MachineDeployments by their cluster, without labeling them.
+			dummies.CSMachineTemplate1.OwnerReferences = []metav1.OwnerReference{{
+				APIVersion: clusterv1.GroupVersion.String(), Kind: "Cluster",
+				Name: dummies.CAPICluster.Name, UID: dummies.CAPICluster.UID}}
+			templateKey = client.ObjectKeyFromObject(dummies.CSMachineTemplate1)
+		})
+
+		// reconcileTemplate creates the template, reconciles it once and returns it.
+		reconcileTemplate := func() *infrav1.CloudStackMachineTemplate {
+			Ω(fakeCtrlClient.Create(ctx, dummies.CSMachineTemplate1)).Should(Succeed())
+			_, err := MachineTemplateReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: templateKey})
+			Ω(err).ShouldNot(HaveOccurred())
+			csTemplate := &infrav1.CloudStackMachineTemplate{}
+			Ω(fakeCtrlClient.Get(ctx, templateKey, csTemplate)).Should(Succeed())
+			return csTemplate
+		}
+
+		It("Should publish the capacity of its offering and template.", func() {
+			csTemplate := reconcileTemplate()
+			Ω(csTemplate.Status.Capacity).Should(Equal(corev1.ResourceList{
+				corev1.ResourceCPU:              resource.MustParse("2"),
+				corev1.ResourceMemory:           resource.MustParse("4Gi"),
+				corev1.ResourceEphemeralStorage: resource.MustParse("8Gi"),
+			}))
+			Ω(csTemplate.Status.NodeInfo).Should(Equal(&infrav1.NodeInfo{Architecture: "amd64", OperatingSystem: "linux"}))
+		})
+
+		It("Should resolve the capacity again every health probe interval.", func() {
+			Ω(fakeCtrlClient.Create(ctx, dummies.CSMachineTemplate1)).Should(Succeed())
+			res, err := MachineTemplateReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: templateKey})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(res.RequeueAfter).Should(Equal(csReconcilers.DefaultFailureDomainHealthProbeInterval))
+		})
+
+		It("Should take the capacity of a custom offering and root disk from details.", func() {
+			sim.AddScalableServiceOffering("Custom Instance", true, 0, 0)
+			spec := &dummies.CSMachineTemplate1.Spec.Spec.Spec
+			spec.Offering = infrav1.CloudStackResourceIdentifier{Name: "Custom Instance"}
+			spec.Details = map[string]string{"cpuNumber": "4", "memory": "8192", "rootdisksize": "20"}
+
+			csTemplate := reconcileTemplate()
+			Ω(csTemplate.Status.Capacity).Should(Equal(corev1.ResourceList{
+				corev1.ResourceCPU:              resource.MustParse("4"),
+				corev1.ResourceMemory:           resource.MustParse("8Gi"),
+				corev1.ResourceEphemeralStorage: resource.MustParse("20Gi"),
+			}))
+		})
+
+		It("Should leave the capacity of a custom offering unset without details.", func() {
+			sim.AddScalableServiceOffering("Custom Instance", true, 0, 0)
+			dummies.CSMachineTemplate1.Spec.Spec.Spec.Offering = infrav1.CloudStackResourceIdentifier{Name: "Custom Instance"}
+			Ω(fakeCtrlClient.Create(ctx, dummies.CSMachineTemplate1)).Should(Succeed())
+			_, err := MachineTemplateReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: templateKey})
+			Ω(err).Should(MatchError(ContainSubstring("details cpuNumber and memory must be set")))
+		})
+	})
+})
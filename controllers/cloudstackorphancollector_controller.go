@@ -0,0 +1,479 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
+	csCtrlrUtils "sigs.k8s.io/cluster-api-provider-cloudstack/controllers/utils"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/metrics"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/annotations"
+)
+
+const (
+	// OrphanCollectionModeReport reports orphaned CloudStack resources without removing them.
+	OrphanCollectionModeReport = "report"
+	// OrphanCollectionModeEnforce reports orphaned CloudStack resources and disposes of them after a grace period.
+	OrphanCollectionModeEnforce = "enforce"
+
+	OrphanedResourceFound    = "%s %s was created by CAPC but no CloudStackCluster uses it anymore"
+	UnownedResourceFound     = "%s %s was created by CAPC for clusters of another management cluster, leaving it alone"
+	OrphanedResourceDisposed = "Disposed of orphaned %s %s"
+	OrphanDisposalFailed     = "Disposing of orphaned resource failed: %s"
+)
+
+// CloudStackOrphanCollector periodically looks for CloudStack resources that CAPC created and that no
+// CloudStackCluster or CloudStackLoadBalancer of this management cluster uses anymore. It scans the account of
+// every CloudStackFailureDomain's credentials once per interval. Only resources tagged with this management cluster's
+// identity are disposed of; those of other management clusters sharing the account, or of clusters moved away, are
+// reported as unowned and left alone. Accounts whose failure domains are all gone keep
+// being scanned with the endpoint secret and account they last had, until the controller manager restarts or the
+// secret is removed.
+type CloudStackOrphanCollector struct {
+	csCtrlrUtils.ReconcilerBase
+	Mode        string
+	Interval    time.Duration
+	GracePeriod time.Duration
+
+	mu        sync.Mutex
+	scopes    map[orphanScope]*infrav1.CloudStackFailureDomainSpec
+	lastScans map[orphanScope]time.Time
+	firstSeen map[orphanScope]map[string]time.Time
+	disposing map[orphanScope]map[string]*infrav1.AsyncJob
+	unowned   map[orphanScope]map[string]bool
+	metrics   metrics.OrphanMetrics
+}
+
+// orphanScope identifies the CloudStack account a failure domain's credentials give access to.
+type orphanScope struct {
+	endpoint, domain, account string
+}
+
+// orphanScopeOf returns the scope of a failure domain's credentials.
+func orphanScopeOf(fdSpec *infrav1.CloudStackFailureDomainSpec) orphanScope {
+	return orphanScope{
+		endpoint: fdSpec.ACSEndpoint.Namespace + "/" + fdSpec.ACSEndpoint.Name,
+		domain:   fdSpec.Domain,
+		account:  fdSpec.Account,
+	}
+}
+
+// CloudStackOrphanCollectorReconciliationRunner is a ReconciliationRunner that scans for orphaned resources using a
+// CloudStackFailureDomain's credentials.
+type CloudStackOrphanCollectorReconciliationRunner struct {
+	*csCtrlrUtils.ReconciliationRunner
+	ReconciliationSubject *infrav1.CloudStackFailureDomain
+	Collector             *CloudStackOrphanCollector
+}
+
+// NewCSOrphanCollectorReconciliationRunner initializes a new orphan collector reconciliation runner.
+func NewCSOrphanCollectorReconciliationRunner(collector *CloudStackOrphanCollector) *CloudStackOrphanCollectorReconciliationRunner {
+	r := &CloudStackOrphanCollectorReconciliationRunner{
+		ReconciliationSubject: &infrav1.CloudStackFailureDomain{},
+		Collector:             collector,
+	}
+	r.ReconciliationRunner = csCtrlrUtils.NewRunner(r, r.ReconciliationSubject, "CloudStackOrphanCollector")
+	return r
+}
+
+// Reconcile is the method k8s will call upon a reconciliation request.
+func (collector *CloudStackOrphanCollector) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	return NewCSOrphanCollectorReconciliationRunner(collector).
+		UsingBaseReconciler(collector.ReconcilerBase).
+		ForRequest(req).
+		WithRequestCtx(ctx).
+		RunBaseReconciliationStages()
+}
+
+// Reconcile scans the failure domain's account for orphaned resources if it's due.
+func (r *CloudStackOrphanCollectorReconciliationRunner) Reconcile() (ctrl.Result, error) {
+	fdSpec := &r.ReconciliationSubject.Spec
+	scope := orphanScopeOf(fdSpec)
+	r.Collector.rememberScope(scope, fdSpec)
+	if wait := r.Collector.untilScanDue(scope); wait > 0 {
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+	if res, err := r.AsFailureDomainUser(fdSpec)(); r.ShouldReturn(res, err) {
+		return res, err
+	}
+	return r.Collector.scan(r.RequestCtx, r.Log, r.CSUser, scope, r.ReconciliationSubject)
+}
+
+// scan lists the CAPC resources of an account, reports the orphans among them and, in enforce mode, disposes of those
+// orphaned for the grace period. Events are recorded on the failure domain the account was scanned for, if any.
+func (collector *CloudStackOrphanCollector) scan(
+	ctx context.Context, log logr.Logger, csUser cloud.Client, scope orphanScope, fd *infrav1.CloudStackFailureDomain,
+) (ctrl.Result, error) {
+	eventf := func(eventType, reason, messageFmt string, args ...interface{}) {
+		if fd != nil {
+			collector.Recorder.Eventf(fd, eventType, reason, messageFmt, args...)
+		}
+	}
+
+	resources, err := csUser.ListCAPCResources(ctx)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "listing CAPC resources")
+	}
+	liveClusters, liveLoadBalancers, paused, err := collector.liveOwners(ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	var orphans, unowned []cloud.CAPCResource
+	for _, resource := range resources {
+		if !isOrphaned(resource, liveClusters, liveLoadBalancers) {
+			continue
+		} else if !isOwned(resource) {
+			unowned = append(unowned, resource)
+			continue
+		}
+		orphans = append(orphans, resource)
+	}
+
+	for _, resource := range collector.recordUnowned(scope, unowned) {
+		log.Info("Found resource of another management cluster", "type", resource.Type, "id", resource.ID,
+			"managementCluster", resource.ManagementClusterID, "clusters", resource.ClusterUIDs)
+		eventf("Normal", "UnownedResource", UnownedResourceFound, resource.Type, resource.ID)
+	}
+	found, due := collector.recordScan(scope, orphans)
+	for _, orphan := range found {
+		log.Info("Found orphaned resource", "type", orphan.Type, "id", orphan.ID)
+		eventf("Warning", "OrphanedResource", OrphanedResourceFound, orphan.Type, orphan.ID)
+	}
+	if collector.Mode != OrphanCollectionModeEnforce {
+		return ctrl.Result{RequeueAfter: collector.Interval}, nil
+	} else if paused {
+		log.Info("Not disposing of orphaned resources while a cluster is paused.")
+		return ctrl.Result{RequeueAfter: collector.Interval}, nil
+	}
+
+	jobs := collector.disposalJobs(scope)
+	var waitingOn cloud.ResourceType
+	for i := range due {
+		orphan := &due[i]
+		if waitingOn != "" && orphan.Type != waitingOn {
+			// Resources are disposed of after those they depend on are gone.
+			log.Info("Waiting for orphaned resources to be disposed of.", "type", waitingOn)
+			break
+		}
+		key := orphanKey(*orphan)
+		orphan.AsyncJob = jobs[key]
+		err := csUser.DisposeCAPCResource(ctx, orphan)
+		jobs[key] = orphan.AsyncJob
+		if cloud.IsJobPending(err) {
+			log.Info("Disposing of orphaned resource", "type", orphan.Type, "id", orphan.ID, "job", orphan.AsyncJob.ID)
+			waitingOn = orphan.Type
+			continue
+		} else if err != nil {
+			log.Error(err, "disposing of orphaned resource", "type", orphan.Type, "id", orphan.ID)
+			eventf("Warning", "OrphanDisposalFailed", OrphanDisposalFailed, err.Error())
+			continue
+		}
+		log.Info("Disposed of orphaned resource", "type", orphan.Type, "id", orphan.ID)
+		eventf("Normal", "OrphanDisposed", OrphanedResourceDisposed, orphan.Type, orphan.ID)
+		collector.metrics.IncDisposedResources(scope.endpoint, scope.domain, scope.account, string(orphan.Type))
+	}
+	collector.recordDisposalJobs(scope, jobs)
+	return ctrl.Result{RequeueAfter: collector.untilScanDue(scope)}, nil
+}
+
+// ReconcileDelete does nothing. The orphan collector only reads failure domains.
+func (r *CloudStackOrphanCollectorReconciliationRunner) ReconcileDelete() (ctrl.Result, error) {
+	return ctrl.Result{}, nil
+}
+
+// liveOwners returns the UIDs of the CloudStackClusters and the namespaced names of the CloudStackLoadBalancers that
+// exist, and whether any cluster is paused, as it is while being moved between management clusters.
+func (collector *CloudStackOrphanCollector) liveOwners(ctx context.Context) (
+	clusters map[string]bool, loadBalancers map[string]bool, paused bool, retErr error,
+) {
+	csClusters := &infrav1.CloudStackClusterList{}
+	if err := collector.K8sClient.List(ctx, csClusters); err != nil {
+		return nil, nil, false, err
+	}
+	clusters = make(map[string]bool, len(csClusters.Items))
+	for i := range csClusters.Items {
+		clusters[string(csClusters.Items[i].UID)] = true
+		paused = paused || annotations.HasPaused(&csClusters.Items[i])
+	}
+
+	capiClusters := &clusterv1.ClusterList{}
+	if err := collector.K8sClient.List(ctx, capiClusters); err != nil {
+		return nil, nil, false, err
+	}
+	for _, capiCluster := range capiClusters.Items {
+		paused = paused || capiCluster.Spec.Paused
+	}
+
+	csLBs := &infrav1.CloudStackLoadBalancerList{}
+	if err := collector.K8sClient.List(ctx, csLBs); err != nil {
+		return nil, nil, false, err
+	}
+	loadBalancers = make(map[string]bool, len(csLBs.Items))
+	for _, csLB := range csLBs.Items {
+		loadBalancers[csLB.Namespace+"/"+csLB.Name] = true
+	}
+	return clusters, loadBalancers, paused, nil
+}
+
+// isOrphaned reports whether none of the CloudStackClusters or the CloudStackLoadBalancer a resource is tagged with
+// exist. A resource CAPC created that no cluster is tagged on is orphaned too.
+func isOrphaned(resource cloud.CAPCResource, liveClusters, liveLoadBalancers map[string]bool) bool {
+	for _, uid := range resource.ClusterUIDs {
+		if liveClusters[uid] {
+			return false
+		}
+	}
+	return !liveLoadBalancers[resource.LoadBalancer]
+}
+
+// isOwned reports whether a resource is tagged with this management cluster's identity. Resources tagged with another
+// one belong to another management cluster sharing the account, or to a cluster that was moved to one. Resources
+// tagged with none were created before CAPC tagged them with it, and can't be told apart from those.
+func isOwned(resource cloud.CAPCResource) bool {
+	return cloud.ManagementClusterID != "" && resource.ManagementClusterID == cloud.ManagementClusterID
+}
+
+// initState sets up the collector's bookkeeping on first use. The caller must hold the lock.
+func (collector *CloudStackOrphanCollector) initState() {
+	if collector.lastScans == nil {
+		collector.scopes = map[orphanScope]*infrav1.CloudStackFailureDomainSpec{}
+		collector.lastScans = map[orphanScope]time.Time{}
+		collector.firstSeen = map[orphanScope]map[string]time.Time{}
+		collector.disposing = map[orphanScope]map[string]*infrav1.AsyncJob{}
+		collector.unowned = map[orphanScope]map[string]bool{}
+		collector.metrics = metrics.NewOrphanMetrics()
+	}
+}
+
+// rememberScope records the credentials an account is scanned with, to keep scanning it once its failure domains are
+// gone.
+func (collector *CloudStackOrphanCollector) rememberScope(scope orphanScope, fdSpec *infrav1.CloudStackFailureDomainSpec) {
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	collector.initState()
+	collector.scopes[scope] = &infrav1.CloudStackFailureDomainSpec{
+		ACSEndpoint: fdSpec.ACSEndpoint,
+		Domain:      fdSpec.Domain,
+		Account:     fdSpec.Account,
+	}
+}
+
+// forgetScope stops scanning an account whose failure domains are gone.
+func (collector *CloudStackOrphanCollector) forgetScope(scope orphanScope) {
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	delete(collector.scopes, scope)
+	delete(collector.lastScans, scope)
+	delete(collector.firstSeen, scope)
+	delete(collector.disposing, scope)
+	delete(collector.unowned, scope)
+}
+
+// untilScanDue returns how long to wait before the account can be scanned again. Accounts with orphans being disposed
+// of are scanned again as often as async jobs are polled.
+func (collector *CloudStackOrphanCollector) untilScanDue(scope orphanScope) time.Duration {
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	collector.initState()
+	lastScan, found := collector.lastScans[scope]
+	if !found {
+		return 0
+	} else if len(collector.disposing[scope]) > 0 {
+		return time.Until(lastScan.Add(csCtrlrUtils.AsyncJobPollInterval))
+	}
+	return time.Until(lastScan.Add(collector.Interval))
+}
+
+// orphanKey identifies an orphaned resource within its account.
+func orphanKey(orphan cloud.CAPCResource) string {
+	return string(orphan.Type) + "/" + orphan.ID
+}
+
+// disposalJobs returns the async jobs disposing of orphans in an account, by orphan.
+func (collector *CloudStackOrphanCollector) disposalJobs(scope orphanScope) map[string]*infrav1.AsyncJob {
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	jobs := map[string]*infrav1.AsyncJob{}
+	for key, job := range collector.disposing[scope] {
+		jobs[key] = job
+	}
+	return jobs
+}
+
+// recordDisposalJobs records the async jobs still disposing of orphans in an account after a scan.
+func (collector *CloudStackOrphanCollector) recordDisposalJobs(scope orphanScope, jobs map[string]*infrav1.AsyncJob) {
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	running := map[string]*infrav1.AsyncJob{}
+	for key, job := range jobs {
+		if job != nil {
+			running[key] = job
+		}
+	}
+	collector.disposing[scope] = running
+}
+
+// recordScan records the orphans found in an account and updates the metrics. It returns the orphans that weren't
+// found by the previous scan, and those that have been around for the grace period.
+func (collector *CloudStackOrphanCollector) recordScan(
+	scope orphanScope, orphans []cloud.CAPCResource,
+) (found []cloud.CAPCResource, due []cloud.CAPCResource) {
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	collector.initState()
+	now := time.Now()
+	collector.lastScans[scope] = now
+
+	firstSeen := make(map[string]time.Time, len(orphans))
+	counts := map[string]int{}
+	for _, orphan := range orphans {
+		key := orphanKey(orphan)
+		seen, seenBefore := collector.firstSeen[scope][key]
+		if !seenBefore {
+			seen = now
+			found = append(found, orphan)
+		}
+		firstSeen[key] = seen
+		if now.Sub(seen) >= collector.GracePeriod {
+			due = append(due, orphan)
+		}
+		counts[string(orphan.Type)]++
+	}
+	collector.firstSeen[scope] = firstSeen
+
+	resourceTypes := []string{}
+	for _, resourceType := range cloud.CAPCResourceTypes() {
+		resourceTypes = append(resourceTypes, string(resourceType))
+	}
+	collector.metrics.SetOrphanedResources(scope.endpoint, scope.domain, scope.account, resourceTypes, counts)
+	return found, due
+}
+
+// recordUnowned records the resources of other management clusters found in an account and updates the metrics. It
+// returns those that weren't found by the previous scan.
+func (collector *CloudStackOrphanCollector) recordUnowned(
+	scope orphanScope, resources []cloud.CAPCResource,
+) (found []cloud.CAPCResource) {
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	collector.initState()
+
+	unowned := make(map[string]bool, len(resources))
+	counts := map[string]int{}
+	for _, resource := range resources {
+		key := orphanKey(resource)
+		if !collector.unowned[scope][key] {
+			found = append(found, resource)
+		}
+		unowned[key] = true
+		counts[string(resource.Type)]++
+	}
+	collector.unowned[scope] = unowned
+
+	resourceTypes := []string{}
+	for _, resourceType := range cloud.CAPCResourceTypes() {
+		resourceTypes = append(resourceTypes, string(resourceType))
+	}
+	collector.metrics.SetUnownedResources(scope.endpoint, scope.domain, scope.account, resourceTypes, counts)
+	return found
+}
+
+// Start scans the accounts whose failure domains are all gone, which no failure domain's reconciliation scans anymore,
+// until ctx is done. It runs on the leader only, like the controllers.
+func (collector *CloudStackOrphanCollector) Start(ctx context.Context) error {
+	tick := collector.Interval
+	if tick > csCtrlrUtils.AsyncJobPollInterval {
+		tick = csCtrlrUtils.AsyncJobPollInterval
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			collector.scanGoneScopes(ctx)
+		}
+	}
+}
+
+// scanGoneScopes scans the remembered accounts no failure domain uses anymore, if they're due.
+func (collector *CloudStackOrphanCollector) scanGoneScopes(ctx context.Context) {
+	log := collector.BaseLogger.WithName("CloudStackOrphanCollector")
+	fds := &infrav1.CloudStackFailureDomainList{}
+	if err := collector.K8sClient.List(ctx, fds); err != nil {
+		log.Error(err, "listing failure domains")
+		return
+	}
+	live := map[orphanScope]bool{}
+	for i := range fds.Items {
+		live[orphanScopeOf(&fds.Items[i].Spec)] = true
+	}
+
+	collector.mu.Lock()
+	gone := map[orphanScope]*infrav1.CloudStackFailureDomainSpec{}
+	for scope, fdSpec := range collector.scopes {
+		if !live[scope] {
+			gone[scope] = fdSpec
+		}
+	}
+	collector.mu.Unlock()
+
+	for scope, fdSpec := range gone {
+		if collector.untilScanDue(scope) > 0 {
+			continue
+		}
+		scopeLog := log.WithValues("endpoint", scope.endpoint, "domain", scope.domain, "account", scope.account)
+		_, csUser, err := csCtrlrUtils.NewFailureDomainClients(ctx, collector.K8sClient, fdSpec)
+		if apierrors.IsNotFound(errors.Cause(err)) {
+			scopeLog.Info("Endpoint secret of failure domains that are gone was removed, not scanning their account anymore.")
+			collector.forgetScope(scope)
+			continue
+		} else if err != nil {
+			scopeLog.Error(err, "setting up client of failure domains that are gone")
+			continue
+		}
+		if _, err := collector.scan(ctx, scopeLog, csUser, scope, nil); err != nil {
+			scopeLog.Error(err, "scanning account of failure domains that are gone")
+		}
+	}
+}
+
+// SetupWithManager sets up the orphan collector with the Manager. It runs alongside the CloudStackFailureDomain
+// controller, scanning once a failure domain is created and then every interval, and scans the accounts of failure
+// domains that are gone in the background.
+func (collector *CloudStackOrphanCollector) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.Add(collector); err != nil {
+		return err
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("cloudstackorphancollector").
+		For(&infrav1.CloudStackFailureDomain{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(collector)
+}
@@ -0,0 +1,190 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
+	csReconcilers "sigs.k8s.io/cluster-api-provider-cloudstack/controllers"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/controllers/utils"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
+	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("CloudStackOrphanCollector", func() {
+	Context("With a fake ctrlRuntimeClient and a CloudStack simulator.", func() {
+		var (
+			fdKey          client.ObjectKey
+			orphanVM       string
+			liveVM         string
+			goneCluster    *infrav1.CloudStackCluster
+			deployTaggedVM func(name string, csCluster *infrav1.CloudStackCluster) string
+			retagVM        func(id string, csCluster *infrav1.CloudStackCluster)
+			collector      *csReconcilers.CloudStackOrphanCollector
+		)
+
+		BeforeEach(func() {
+			cloud.ManagementClusterID = "management-cluster"
+			DeferCleanup(func() { cloud.ManagementClusterID = "" })
+			setupSimulatorTestClient()
+			Ω(fakeCtrlClient.Create(ctx, dummies.CSFailureDomain1)).Should(Succeed())
+			fdKey = client.ObjectKeyFromObject(dummies.CSFailureDomain1)
+			Ω(fakeCtrlClient.Get(ctx, client.ObjectKeyFromObject(dummies.CSCluster), dummies.CSCluster)).Should(Succeed())
+
+			csClient, err := cloud.NewClientFromConf(dummies.SimulatorConf, nil)
+			Ω(err).ShouldNot(HaveOccurred())
+			deployTaggedVM = func(name string, csCluster *infrav1.CloudStackCluster) string {
+				csMachine := dummies.CSMachine1.DeepCopy()
+				csMachine.Name, csMachine.Spec.InstanceID = name, nil
+				Ω(csClient.GetOrCreateVMInstance(ctx, csMachine, dummies.CAPIMachine, csCluster,
+					dummies.CSFailureDomain1, dummies.CSAffinityGroup, "userdata")).Should(Succeed())
+				Ω(csClient.ReconcileVMInstanceTags(ctx, csMachine, csCluster)).Should(Succeed())
+				return *csMachine.Spec.InstanceID
+			}
+			retagVM = func(id string, csCluster *infrav1.CloudStackCluster) {
+				csMachine := dummies.CSMachine1.DeepCopy()
+				csMachine.Spec.InstanceID = &id
+				Ω(csClient.ReconcileVMInstanceTags(ctx, csMachine, csCluster)).Should(Succeed())
+			}
+			liveVM = deployTaggedVM("live-machine", dummies.CSCluster)
+			goneCluster = dummies.CSCluster.DeepCopy()
+			goneCluster.UID = "gone"
+			orphanVM = deployTaggedVM("orphaned-machine", goneCluster)
+
+			collector = &csReconcilers.CloudStackOrphanCollector{
+				ReconcilerBase: FailureDomainReconciler.ReconcilerBase,
+				Interval:       time.Hour,
+			}
+		})
+
+		It("Should report resources of clusters that are gone without disposing of them.", func() {
+			collector.Mode = csReconcilers.OrphanCollectionModeReport
+			res, err := collector.Reconcile(ctx, ctrl.Request{NamespacedName: fdKey})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(res.RequeueAfter).Should(Equal(time.Hour))
+			Ω(sim.VirtualMachines()).Should(HaveLen(2))
+
+			Ω(fakeRecorder.Events).Should(HaveLen(1))
+			event := <-fakeRecorder.Events
+			Ω(event).Should(ContainSubstring("Warning OrphanedResource"))
+			Ω(event).Should(ContainSubstring(orphanVM))
+		})
+
+		It("Should dispose of resources of clusters that are gone after the grace period, and scan once per interval.", func() {
+			collector.Mode = csReconcilers.OrphanCollectionModeEnforce
+			collector.GracePeriod = 0
+			_, err := collector.Reconcile(ctx, ctrl.Request{NamespacedName: fdKey})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			vms := sim.VirtualMachines()
+			Ω(vms).Should(HaveLen(1))
+			Ω(vms[0].Id).Should(Equal(liveVM))
+			Ω(len(fakeRecorder.Events)).Should(Equal(2))
+			Ω(<-fakeRecorder.Events).Should(ContainSubstring("Warning OrphanedResource"))
+			Ω(<-fakeRecorder.Events).Should(ContainSubstring("Normal OrphanDisposed"))
+
+			// The account was just scanned.
+			listTags := sim.RequestCount("listTags")
+			res, err := collector.Reconcile(ctx, ctrl.Request{NamespacedName: fdKey})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(res.RequeueAfter).Should(BeNumerically(">", 59*time.Minute))
+			Ω(sim.RequestCount("listTags")).Should(Equal(listTags))
+		})
+
+		It("Should report resources of other management clusters sharing the account without disposing of them.", func() {
+			cloud.ManagementClusterID = "other-management-cluster"
+			otherCluster := dummies.CSCluster.DeepCopy()
+			otherCluster.UID = "other"
+			otherVM := deployTaggedVM("other-machine", otherCluster)
+			cloud.ManagementClusterID = "management-cluster"
+
+			collector.Mode = csReconcilers.OrphanCollectionModeEnforce
+			collector.GracePeriod = 0
+			_, err := collector.Reconcile(ctx, ctrl.Request{NamespacedName: fdKey})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(sim.VirtualMachines()).Should(ConsistOf(HaveField("Id", liveVM), HaveField("Id", otherVM)))
+			Ω(len(fakeRecorder.Events)).Should(Equal(3))
+			event := <-fakeRecorder.Events
+			Ω(event).Should(ContainSubstring("Normal UnownedResource"))
+			Ω(event).Should(ContainSubstring(otherVM))
+			Ω(<-fakeRecorder.Events).Should(ContainSubstring("Warning OrphanedResource"))
+			Ω(<-fakeRecorder.Events).Should(ContainSubstring("Normal OrphanDisposed"))
+
+			// Resources of other management clusters are reported once.
+			collector.Interval = 0
+			_, err = collector.Reconcile(ctx, ctrl.Request{NamespacedName: fdKey})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(fakeRecorder.Events).Should(BeEmpty())
+		})
+
+		It("Should leave resources of clusters moved to another management cluster alone.", func() {
+			// The cluster the orphaned VM belonged to was moved, and the management cluster it was moved to has
+			// reconciled the VM under the cluster's new UID.
+			cloud.ManagementClusterID = "target-management-cluster"
+			movedCluster := goneCluster.DeepCopy()
+			movedCluster.UID = "moved"
+			retagVM(orphanVM, movedCluster)
+			cloud.ManagementClusterID = "management-cluster"
+
+			collector.Mode = csReconcilers.OrphanCollectionModeEnforce
+			collector.GracePeriod = 0
+			_, err := collector.Reconcile(ctx, ctrl.Request{NamespacedName: fdKey})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(sim.VirtualMachines()).Should(HaveLen(2))
+			Ω(fakeRecorder.Events).Should(HaveLen(1))
+			event := <-fakeRecorder.Events
+			Ω(event).Should(ContainSubstring("Normal UnownedResource"))
+			Ω(event).Should(ContainSubstring(orphanVM))
+		})
+
+		It("Should keep scanning the account of failure domains that are gone.", func() {
+			collector.Mode = csReconcilers.OrphanCollectionModeEnforce
+			collector.GracePeriod = 0
+			collector.Interval = 100 * time.Millisecond
+			_, err := collector.Reconcile(ctx, ctrl.Request{NamespacedName: fdKey})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(sim.VirtualMachines()).Should(HaveLen(1))
+
+			deployTaggedVM("orphaned-later", goneCluster)
+			Ω(fakeCtrlClient.Delete(ctx, dummies.CSFailureDomain1)).Should(Succeed())
+			startCtx, stop := context.WithCancel(ctx)
+			defer stop()
+			go func() {
+				defer GinkgoRecover()
+				Ω(collector.Start(startCtx)).Should(Succeed())
+			}()
+			Eventually(sim.VirtualMachines, 5*time.Second).Should(ConsistOf(HaveField("Id", liveVM)))
+		})
+
+		It("Should scan an account again soon while an orphaned VM is being expunged.", func() {
+			collector.Mode = csReconcilers.OrphanCollectionModeEnforce
+			collector.GracePeriod = 0
+			sim.HoldJobs("destroyVirtualMachine")
+			res, err := collector.Reconcile(ctx, ctrl.Request{NamespacedName: fdKey})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(res.RequeueAfter).Should(BeNumerically("~", utils.AsyncJobPollInterval, time.Second))
+			Ω(fakeRecorder.Events).Should(HaveLen(1))
+			Ω(<-fakeRecorder.Events).Should(ContainSubstring("Warning OrphanedResource"))
+		})
+	})
+})
@@ -53,9 +53,11 @@ import (
 	csReconcilers "sigs.k8s.io/cluster-api-provider-cloudstack/controllers"
 	csCtrlrUtils "sigs.k8s.io/cluster-api-provider-cloudstack/controllers/utils"
 	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/mocks"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/test/simulator"
 
 	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta2"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util/patch"
 	//+kubebuilder:scaffold:imports
 )
@@ -123,12 +125,18 @@ var (
 	mockCloudClient *mocks.MockClient
 	mockCSAPIClient *cloudstack.CloudStackClient
 
+	// Simulator Vars.
+	sim *simulator.Simulator
+
 	// Reconcilers
-	MachineReconciler       *csReconcilers.CloudStackMachineReconciler
-	ClusterReconciler       *csReconcilers.CloudStackClusterReconciler
-	FailureDomainReconciler *csReconcilers.CloudStackFailureDomainReconciler
-	IsoNetReconciler        *csReconcilers.CloudStackIsoNetReconciler
-	AffinityGReconciler     *csReconcilers.CloudStackAffinityGroupReconciler
+	MachineReconciler         *csReconcilers.CloudStackMachineReconciler
+	ClusterReconciler         *csReconcilers.CloudStackClusterReconciler
+	FailureDomainReconciler   *csReconcilers.CloudStackFailureDomainReconciler
+	IsoNetReconciler          *csReconcilers.CloudStackIsoNetReconciler
+	AffinityGReconciler       *csReconcilers.CloudStackAffinityGroupReconciler
+	LoadBalancerReconciler    *csReconcilers.CloudStackLoadBalancerReconciler
+	MachinePoolReconciler     *csReconcilers.CloudStackMachinePoolReconciler
+	MachineTemplateReconciler *csReconcilers.CloudStackMachineTemplateReconciler
 )
 
 var _ = BeforeSuite(func() {
@@ -146,6 +154,7 @@ var _ = BeforeSuite(func() {
 
 	Ω(infrav1.AddToScheme(scheme.Scheme)).Should(Succeed())
 	Ω(clusterv1.AddToScheme(scheme.Scheme)).Should(Succeed())
+	Ω(expv1.AddToScheme(scheme.Scheme)).Should(Succeed())
 	Ω(fakes.AddToScheme(scheme.Scheme)).Should(Succeed())
 
 	// Increase log verbosity.
@@ -223,6 +232,9 @@ func SetupTestEnvironment() {
 	FailureDomainReconciler = &csReconcilers.CloudStackFailureDomainReconciler{ReconcilerBase: base}
 	IsoNetReconciler = &csReconcilers.CloudStackIsoNetReconciler{ReconcilerBase: base}
 	AffinityGReconciler = &csReconcilers.CloudStackAffinityGroupReconciler{ReconcilerBase: base}
+	LoadBalancerReconciler = &csReconcilers.CloudStackLoadBalancerReconciler{ReconcilerBase: base}
+	MachinePoolReconciler = &csReconcilers.CloudStackMachinePoolReconciler{ReconcilerBase: base}
+	MachineTemplateReconciler = &csReconcilers.CloudStackMachineTemplateReconciler{ReconcilerBase: base}
 
 	ctx, cancel = context.WithCancel(context.TODO())
 
@@ -235,6 +247,9 @@ func SetupTestEnvironment() {
 	IsoNetReconciler.CSClient = mockCloudClient
 	MachineReconciler.CSClient = mockCloudClient
 	AffinityGReconciler.CSClient = mockCloudClient
+	LoadBalancerReconciler.CSClient = mockCloudClient
+	MachinePoolReconciler.CSClient = mockCloudClient
+	MachineTemplateReconciler.CSClient = mockCloudClient
 	FailureDomainReconciler.CSClient = mockCloudClient
 
 	setupClusterCRDs()
@@ -280,6 +295,9 @@ func setupFakeTestClient() {
 	FailureDomainReconciler = &csReconcilers.CloudStackFailureDomainReconciler{ReconcilerBase: base}
 	IsoNetReconciler = &csReconcilers.CloudStackIsoNetReconciler{ReconcilerBase: base}
 	AffinityGReconciler = &csReconcilers.CloudStackAffinityGroupReconciler{ReconcilerBase: base}
+	LoadBalancerReconciler = &csReconcilers.CloudStackLoadBalancerReconciler{ReconcilerBase: base}
+	MachinePoolReconciler = &csReconcilers.CloudStackMachinePoolReconciler{ReconcilerBase: base}
+	MachineTemplateReconciler = &csReconcilers.CloudStackMachineTemplateReconciler{ReconcilerBase: base}
 
 	// Set on reconcilers. The mock client wasn't available at suite startup, so set it now.
 	ClusterReconciler.CSClient = mockCloudClient
@@ -287,9 +305,51 @@ func setupFakeTestClient() {
 	MachineReconciler.CSClient = mockCloudClient
 	FailureDomainReconciler.CSClient = mockCloudClient
 	AffinityGReconciler.CSClient = mockCloudClient
+	LoadBalancerReconciler.CSClient = mockCloudClient
+	MachinePoolReconciler.CSClient = mockCloudClient
+	MachineTemplateReconciler.CSClient = mockCloudClient
+
+	DeferCleanup(func() {
+		cancel()
+	})
+}
+
+// Sets up a fake k8s controller runtime client and reconcilers that talk to a CloudStack simulator through the real
+// cloud client, rather than a mock.
+func setupSimulatorTestClient() {
+	dummies.SetDummyVars()
+	sim = simulator.New()
+	dummies.SetDummySimulatorVars(sim)
+
+	// Make a fake k8s client with CloudStack and CAPI cluster, and the credentials used to reach the simulator.
+	fakeCtrlClient = fake.NewClientBuilder().WithObjects(
+		dummies.CSCluster, dummies.CAPICluster, dummies.ACSEndpointSecret1).Build()
+	fakeRecorder = record.NewFakeRecorder(fakeEventBufferSize)
+
+	// Base reconciler shared across reconcilers. The default extension builds clients from failure domain secrets.
+	base := csCtrlrUtils.ReconcilerBase{
+		K8sClient:            fakeCtrlClient,
+		Scheme:               scheme.Scheme,
+		BaseLogger:           logger,
+		Recorder:             fakeRecorder,
+		CloudClientExtension: &csCtrlrUtils.CloudClientImplementation{},
+	}
+
+	ctx, cancel = context.WithCancel(context.TODO())
+
+	// Setup each specific reconciler.
+	ClusterReconciler = &csReconcilers.CloudStackClusterReconciler{ReconcilerBase: base}
+	MachineReconciler = &csReconcilers.CloudStackMachineReconciler{ReconcilerBase: base}
+	FailureDomainReconciler = &csReconcilers.CloudStackFailureDomainReconciler{ReconcilerBase: base}
+	IsoNetReconciler = &csReconcilers.CloudStackIsoNetReconciler{ReconcilerBase: base}
+	AffinityGReconciler = &csReconcilers.CloudStackAffinityGroupReconciler{ReconcilerBase: base}
+	LoadBalancerReconciler = &csReconcilers.CloudStackLoadBalancerReconciler{ReconcilerBase: base}
+	MachinePoolReconciler = &csReconcilers.CloudStackMachinePoolReconciler{ReconcilerBase: base}
+	MachineTemplateReconciler = &csReconcilers.CloudStackMachineTemplateReconciler{ReconcilerBase: base}
 
 	DeferCleanup(func() {
 		cancel()
+		sim.Close()
 	})
 }
 
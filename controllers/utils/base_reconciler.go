@@ -26,14 +26,17 @@ import (
 	"github.com/go-logr/logr"
 	"github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
 	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
 	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util/annotations"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -71,6 +74,7 @@ type ReconciliationRunner struct {
 	ConditionalResult      bool          // Stores a conidtinal result for stringing if else type methods.
 	returnEarly            bool          // A signal that the reconcile should return early.
 	additionalCommonStages []CloudStackReconcilerMethod
+	readyConditions        []clusterv1.ConditionType
 	ReconcileDelete        CloudStackReconcilerMethod
 	Reconcile              CloudStackReconcilerMethod
 	CSUser                 cloud.Client
@@ -126,6 +130,13 @@ func (r *ReconciliationRunner) WithAdditionalCommonStages(fns ...CloudStackRecon
 	return r
 }
 
+// WithReadySummary summarizes the passed conditions of the ReconciliationSubject into its Ready condition whenever
+// it's patched back. Only the conditions set on the subject are summarized.
+func (r *ReconciliationRunner) WithReadySummary(conditionTypes ...clusterv1.ConditionType) *ReconciliationRunner {
+	r.readyConditions = conditionTypes
+	return r
+}
+
 // SetupLogger sets up the reconciler's logger to log with name and namespace values.
 func (r *ReconciliationRunner) SetupLogger() (res ctrl.Result, retErr error) {
 	r.Log = r.BaseLogger.WithName(r.ControllerKind).WithValues("name", r.Request.Name, "namespace", r.Request.Namespace)
@@ -162,7 +173,7 @@ func (r *ReconciliationRunner) Else(fn CloudStackReconcilerMethod) CloudStackRec
 // GetCAPICluster gets the CAPI cluster the reconciliation subject belongs to.
 func (r *ReconciliationRunner) GetCAPICluster() (ctrl.Result, error) {
 	r.Log.V(1).Info("Getting CAPI cluster.")
-	name := r.ReconciliationSubject.GetLabels()[clusterv1.ClusterLabelName]
+	name := ClusterNameOf(r.ReconciliationSubject)
 	if name == "" {
 		r.Log.V(1).Info("Reconciliation Subject is missing cluster label or cluster does not exist. Skipping CAPI Cluster fetch.",
 			"SubjectKind", r.ReconciliationSubject.GetObjectKind().GroupVersionKind().Kind)
@@ -184,7 +195,7 @@ func (r *ReconciliationRunner) GetCAPICluster() (ctrl.Result, error) {
 // GetCSCluster gets the CAPI cluster the reconciliation subject belongs to.
 func (r *ReconciliationRunner) GetCSCluster() (ctrl.Result, error) {
 	r.Log.V(1).Info("Getting CloudStackCluster cluster.")
-	name := r.ReconciliationSubject.GetLabels()[clusterv1.ClusterLabelName]
+	name := ClusterNameOf(r.ReconciliationSubject)
 	if name == "" {
 		r.Log.V(1).Info("Reconciliation Subject is missing cluster label or cluster does not exist. Skipping CloudStackCluster fetch.",
 			"SubjectKind", r.ReconciliationSubject.GetObjectKind().GroupVersionKind().Kind)
@@ -369,10 +380,13 @@ func (r *ReconciliationRunner) ShouldReturn(rslt ctrl.Result, err error) bool {
 }
 
 // RunReconciliationStages runs CloudStackReconcilerMethods in order and exits if an error or requeue condition is set.
-// On exit patches changes back to API.
+// Stages waiting on a CloudStack async job requeue to poll it. On exit patches changes back to API.
 func (r *ReconciliationRunner) RunReconciliationStages(fns ...CloudStackReconcilerMethod) (ctrl.Result, error) {
 	for _, fn := range fns {
-		if rslt, err := fn(); err != nil {
+		if rslt, err := fn(); cloud.IsJobPending(err) {
+			r.Log.Info("Waiting for CloudStack async job. Requeuing.", "job", err.Error())
+			return ctrl.Result{RequeueAfter: AsyncJobPollInterval}, nil
+		} else if err != nil {
 			return rslt, err
 		} else if rslt.Requeue || rslt.RequeueAfter != time.Duration(0) || r.returnEarly {
 			return rslt, nil
@@ -386,8 +400,14 @@ func (r *ReconciliationRunner) RunReconciliationStages(fns ...CloudStackReconcil
 func (r *ReconciliationRunner) RunBaseReconciliationStages() (res ctrl.Result, retErr error) {
 	defer func() {
 		if r.Patcher != nil {
+			if setter, ok := r.ReconciliationSubject.(conditions.Setter); ok && len(r.readyConditions) > 0 {
+				conditions.SetSummary(setter, conditions.WithConditions(r.readyConditions...))
+			}
 			if err := r.Patcher.Patch(r.RequestCtx, r.ReconciliationSubject); err != nil {
-				if !strings.Contains(err.Error(), "is invalid: status.ready") {
+				// A subject whose last finalizer was just removed is gone before its status can be patched.
+				deleted := !r.ReconciliationSubject.GetDeletionTimestamp().IsZero() &&
+					len(r.ReconciliationSubject.GetFinalizers()) == 0 && kerrors.FilterOut(err, apierrors.IsNotFound) == nil
+				if !deleted && !strings.Contains(err.Error(), "is invalid: status.ready") {
 					err = errors.Wrapf(err, "error patching reconciliation subject")
 					retErr = multierror.Append(retErr, err)
 				}
@@ -19,4 +19,6 @@ package utils
 import "time"
 
 const RequeueTimeout = 5 * time.Second
-const DestoryVMRequeueInterval = 10 * time.Second
+
+// AsyncJobPollInterval is how often the CloudStack async jobs resources wait on are polled.
+const AsyncJobPollInterval = 10 * time.Second
@@ -17,6 +17,7 @@ limitations under the License.
 package utils
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -136,31 +137,44 @@ func (c *CloudClientImplementation) RegisterExtension(r *ReconciliationRunner) C
 // AsFailureDomainUser uses the credentials specified in the failure domain to set the ReconciliationSubject's CSUser client.
 func (c *CloudClientImplementation) AsFailureDomainUser(fdSpec *infrav1.CloudStackFailureDomainSpec) CloudStackReconcilerMethod {
 	return func() (ctrl.Result, error) {
-		endpointCredentials := &corev1.Secret{}
-		key := client.ObjectKey{Name: fdSpec.ACSEndpoint.Name, Namespace: fdSpec.ACSEndpoint.Namespace}
-		if err := c.K8sClient.Get(c.RequestCtx, key, endpointCredentials); err != nil {
-			return ctrl.Result{}, errors.Wrapf(err, "getting ACSEndpoint secret with ref: %v", fdSpec.ACSEndpoint)
+		csClient, csUser, err := NewFailureDomainClients(c.RequestCtx, c.K8sClient, fdSpec)
+		if csClient != nil {
+			c.CSClient = csClient
 		}
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		c.CSUser = csUser
+		return ctrl.Result{}, nil
+	}
+}
 
-		clientConfig := &corev1.ConfigMap{}
-		key = client.ObjectKey{Name: cloud.ClientConfigMapName, Namespace: cloud.ClientConfigMapNamespace}
-		_ = c.K8sClient.Get(c.RequestCtx, key, clientConfig)
+// NewFailureDomainClients returns a client with the credentials of the failure domain's endpoint secret, and one of
+// the failure domain's domain and account. The latter is the former when no account is set. The endpoint's client is
+// returned when the account's user can't be found too.
+func NewFailureDomainClients(
+	ctx context.Context, k8sClient client.Client, fdSpec *infrav1.CloudStackFailureDomainSpec,
+) (csClient cloud.Client, csUser cloud.Client, retErr error) {
+	endpointCredentials := &corev1.Secret{}
+	key := client.ObjectKey{Name: fdSpec.ACSEndpoint.Name, Namespace: fdSpec.ACSEndpoint.Namespace}
+	if err := k8sClient.Get(ctx, key, endpointCredentials); err != nil {
+		return nil, nil, errors.Wrapf(err, "getting ACSEndpoint secret with ref: %v", fdSpec.ACSEndpoint)
+	}
 
-		var err error
-		if c.CSClient, err = cloud.NewClientFromK8sSecret(endpointCredentials, clientConfig); err != nil {
-			return ctrl.Result{}, errors.Wrapf(err, "parsing ACSEndpoint secret with ref: %v", fdSpec.ACSEndpoint)
-		}
+	clientConfig := &corev1.ConfigMap{}
+	key = client.ObjectKey{Name: cloud.ClientConfigMapName, Namespace: cloud.ClientConfigMapNamespace}
+	_ = k8sClient.Get(ctx, key, clientConfig)
 
-		if fdSpec.Account != "" { // Set r.CSUser CloudStack Client per Account and Domain.
-			client, err := c.CSClient.NewClientInDomainAndAccount(fdSpec.Domain, fdSpec.Account)
-			if err != nil {
-				return ctrl.Result{}, err
-			}
-			c.CSUser = client
-		} else { // Set r.CSUser CloudStack Client to r.CSClient since Account & Domain weren't provided.
-			c.CSUser = c.CSClient
-		}
+	csClient, err := cloud.NewClientFromK8sSecret(endpointCredentials, clientConfig)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "parsing ACSEndpoint secret with ref: %v", fdSpec.ACSEndpoint)
+	}
 
-		return ctrl.Result{}, nil
+	if fdSpec.Account == "" { // Account & Domain weren't provided.
+		return csClient, csClient, nil
+	}
+	if csUser, err = csClient.NewClientInDomainAndAccount(ctx, fdSpec.Domain, fdSpec.Account); err != nil {
+		return csClient, nil, err
 	}
+	return csClient, csUser, nil
 }
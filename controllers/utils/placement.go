@@ -0,0 +1,183 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
+)
+
+// PlacementCandidate is a failure domain a worker machine can be placed in.
+type PlacementCandidate struct {
+	// Name of the failure domain.
+	Name string
+	// Machines is the number of the cluster's machines already in the failure domain.
+	Machines int
+	// Weight is the failure domain's share of the cluster's machines relative to the other candidates'. Failure
+	// domains weighing nothing aren't placed in.
+	Weight float64
+}
+
+// FailureDomainPlacer picks the failure domain to place a worker machine in.
+type FailureDomainPlacer interface {
+	// Place returns the name of the picked candidate and why it was picked.
+	Place(candidates []PlacementCandidate) (name string, reason string, err error)
+}
+
+// RandomPlacer places machines in a failure domain picked at random, regardless of weight.
+type RandomPlacer struct{}
+
+func (RandomPlacer) Place(candidates []PlacementCandidate) (string, string, error) {
+	if len(candidates) == 0 {
+		return "", "", errors.New("no failure domains to place the machine in")
+	}
+	picked := candidates[rand.Intn(len(candidates))] // #nosec G404 -- weak crypt rand doesn't matter here.
+	return picked.Name, fmt.Sprintf("picked at random out of %d failure domains", len(candidates)), nil
+}
+
+// WeightedPlacer places machines in the failure domain that, with the machine, holds the fewest machines relative to
+// its weight, so machines are spread in proportion to the weights. Ties go to the candidate listed first.
+type WeightedPlacer struct{}
+
+func (WeightedPlacer) Place(candidates []PlacementCandidate) (string, string, error) {
+	var picked *PlacementCandidate
+	var pickedLoad float64
+	counts := make([]string, 0, len(candidates))
+	for i := range candidates {
+		candidate := &candidates[i]
+		counts = append(counts, fmt.Sprintf("%s has %d at weight %s",
+			candidate.Name, candidate.Machines, strconv.FormatFloat(candidate.Weight, 'g', 3, 64)))
+		if candidate.Weight <= 0 {
+			continue
+		}
+		if load := float64(candidate.Machines+1) / candidate.Weight; picked == nil || load < pickedLoad {
+			picked, pickedLoad = candidate, load
+		}
+	}
+	if picked == nil {
+		return "", "", errors.Errorf("no failure domain to place the machine in weighs more than 0: %s",
+			strings.Join(counts, ", "))
+	}
+	return picked.Name, fmt.Sprintf("fewest machines for its weight (%s)", strings.Join(counts, ", ")), nil
+}
+
+// PlaceWorkerMachine picks the failure domain of a worker machine by the CloudStackCluster's placement strategy,
+// leaving out the excluded failure domains. It returns the name of the failure domain, and which strategy picked it
+// and why.
+func (r *ReconciliationRunner) PlaceWorkerMachine(machine client.Object, exclude ...string) (string, string, error) {
+	strategy := infrav1.PlacementStrategyRoundRobin
+	var weights map[string]int32
+	if placement := r.CSCluster.Spec.FailureDomainPlacement; placement != nil {
+		if placement.Strategy != "" {
+			strategy = placement.Strategy
+		}
+		weights = placement.Weights
+	}
+	candidates, err := r.placementCandidates(machine, exclude)
+	if err != nil {
+		return "", "", err
+	}
+
+	var placer FailureDomainPlacer = WeightedPlacer{}
+	note := ""
+	switch strategy {
+	case infrav1.PlacementStrategyRandom:
+		placer = RandomPlacer{}
+	case infrav1.PlacementStrategyWeighted:
+		for i := range candidates {
+			if weight, found := weights[candidates[i].Name]; found {
+				candidates[i].Weight = float64(weight)
+			}
+		}
+	case infrav1.PlacementStrategyCapacity:
+		if err := r.weighByFreeCapacity(candidates); err != nil {
+			// Placing the machine regardless beats holding it up, e.g. when the credentials can't list capacity.
+			r.Log.Info("Placing round robin, free capacity unavailable.", "error", err.Error())
+			for i := range candidates {
+				candidates[i].Weight = 1
+			}
+			note = fmt.Sprintf(", placed round robin as free capacity is unavailable: %s", err)
+		}
+	}
+	name, reason, err := placer.Place(candidates)
+	if err != nil {
+		return "", "", err
+	}
+	return name, fmt.Sprintf("%s strategy: %s%s", strategy, reason, note), nil
+}
+
+// placementCandidates returns the CloudStackCluster's failure domains that aren't excluded, with the number of the
+// cluster's machines in each, other than the machine being placed and those being deleted. Each weighs 1. Once the
+// CloudStackCluster lists failure domains in its status, those it leaves out as unhealthy aren't candidates either.
+func (r *ReconciliationRunner) placementCandidates(machine client.Object, exclude []string) ([]PlacementCandidate, error) {
+	excluded := map[string]bool{}
+	for _, name := range exclude {
+		excluded[name] = true
+	}
+	candidates := make([]PlacementCandidate, 0, len(r.CSCluster.Spec.FailureDomains))
+	for _, fdSpec := range r.CSCluster.Spec.FailureDomains {
+		if _, listed := r.CSCluster.Status.FailureDomains[fdSpec.Name]; excluded[fdSpec.Name] ||
+			(r.CSCluster.Status.FailureDomains != nil && !listed) {
+			continue
+		}
+		machines := &infrav1.CloudStackMachineList{}
+		if err := r.K8sClient.List(r.RequestCtx, machines, client.InNamespace(machine.GetNamespace()), client.MatchingLabels{
+			infrav1.FailureDomainLabelName: infrav1.FailureDomainHashedMetaName(fdSpec.Name, r.CAPICluster.Name)},
+		); err != nil {
+			return nil, errors.Wrapf(err, "listing machines in failure domain %s", fdSpec.Name)
+		}
+		count := 0
+		for _, m := range machines.Items {
+			if m.Name != machine.GetName() && m.DeletionTimestamp.IsZero() {
+				count++
+			}
+		}
+		candidates = append(candidates, PlacementCandidate{Name: fdSpec.Name, Machines: count, Weight: 1})
+	}
+	return candidates, nil
+}
+
+// weighByFreeCapacity weighs each candidate by the share of its zone's CPU or memory, whichever is scarcer, that's
+// free, counting only the pod or cluster a failure domain is narrowed to. Capacity is listed with the failure domain's
+// credentials, through clients of its own, leaving the runner's clients alone.
+func (r *ReconciliationRunner) weighByFreeCapacity(candidates []PlacementCandidate) error {
+	for i := range candidates {
+		fd := &infrav1.CloudStackFailureDomain{}
+		if res, err := r.GetFailureDomainByName(func() string { return candidates[i].Name }, fd)(); err != nil {
+			return errors.Wrapf(err, "getting failure domain %s", candidates[i].Name)
+		} else if r.ShouldReturn(res, err) || fd.Spec.Zone.ID == "" {
+			return errors.Errorf("failure domain %s not ready yet", candidates[i].Name)
+		}
+		csClient, _, err := NewFailureDomainClients(r.RequestCtx, r.K8sClient, &fd.Spec)
+		if csClient == nil {
+			return errors.Wrapf(err, "getting credentials of failure domain %s", candidates[i].Name)
+		}
+		free, err := csClient.GetZoneFreeCapacity(r.RequestCtx, &fd.Spec.Zone)
+		if err != nil {
+			return err
+		}
+		candidates[i].Weight = free
+	}
+	return nil
+}
@@ -18,16 +18,24 @@ package utils
 
 import (
 	"context"
+	"reflect"
 	"strings"
 
 	"github.com/pkg/errors"
 
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	capiControlPlanev1 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util"
+	ctrl "sigs.k8s.io/controller-runtime"
 	clientPkg "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 )
 
 // getMachineSetFromCAPIMachine attempts to fetch a MachineSet from CAPI machine owner reference.
@@ -159,8 +167,64 @@ func GetOwnerOfKind(ctx context.Context, c clientPkg.Client, owned clientPkg.Obj
 	return errors.Errorf("couldn't find owner of kind %s in namespace %s", gvk.Kind, owned.GetNamespace())
 }
 
-func ContainsNoMatchSubstring(err error) bool {
-	return strings.Contains(strings.ToLower(err.Error()), "no match")
+// CloudStackClusterToObjectsMapper maps a CloudStackCluster to the objects of the passed list's type that are labeled
+// with its CAPI cluster's name.
+func CloudStackClusterToObjectsMapper(
+	c clientPkg.Client, ro clientPkg.ObjectList, scheme *runtime.Scheme,
+) (handler.MapFunc, error) {
+	clusterToObjects, err := util.ClusterToObjectsMapper(c, ro, scheme)
+	if err != nil {
+		return nil, err
+	}
+	return func(o clientPkg.Object) []ctrl.Request {
+		ref := fetchOwnerRef(o.GetOwnerReferences(), "Cluster")
+		if ref == nil {
+			return nil
+		}
+		return clusterToObjects(&clusterv1.Cluster{ObjectMeta: meta.ObjectMeta{Name: ref.Name, Namespace: o.GetNamespace()}})
+	}, nil
+}
+
+// CloudStackClusterLoadBalancerChanged only lets through updates of a CloudStackCluster's load balancer configuration.
+var CloudStackClusterLoadBalancerChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldCluster, okOld := e.ObjectOld.(*infrav1.CloudStackCluster)
+		newCluster, okNew := e.ObjectNew.(*infrav1.CloudStackCluster)
+		return okOld && okNew && !reflect.DeepEqual(oldCluster.Spec.LoadBalancer, newCluster.Spec.LoadBalancer)
+	},
+	CreateFunc:  func(e event.CreateEvent) bool { return false },
+	DeleteFunc:  func(e event.DeleteEvent) bool { return false },
+	GenericFunc: func(e event.GenericEvent) bool { return false },
+}
+
+// CloudStackMachineToLoadBalancers maps a CloudStackMachine to the CloudStackLoadBalancers it's a member of.
+func CloudStackMachineToLoadBalancers(o clientPkg.Object) []ctrl.Request {
+	machine, ok := o.(*infrav1.CloudStackMachine)
+	if !ok {
+		return nil
+	}
+	requests := make([]ctrl.Request, 0, len(machine.Spec.LoadBalancerMemberships))
+	for _, membership := range machine.Spec.LoadBalancerMemberships {
+		requests = append(requests, ctrl.Request{
+			NamespacedName: types.NamespacedName{Namespace: machine.Namespace, Name: membership.Name}})
+	}
+	return requests
+}
+
+// ClusterNameOf returns the name of the cluster an object belongs to: that of its cluster label, or else that of the
+// CAPI Cluster owning it. CAPI owns the machine templates of MachineDeployments by their Cluster without labeling
+// them. It's empty for objects of no cluster.
+func ClusterNameOf(o clientPkg.Object) string {
+	if name := o.GetLabels()[clusterv1.ClusterLabelName]; name != "" {
+		return name
+	}
+	for _, ref := range o.GetOwnerReferences() {
+		if gv, err := schema.ParseGroupVersion(ref.APIVersion); err == nil &&
+			gv.Group == clusterv1.GroupVersion.Group && ref.Kind == "Cluster" {
+			return ref.Name
+		}
+	}
+	return ""
 }
 
 func ContainsAlreadyExistsSubstring(err error) bool {
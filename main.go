@@ -18,6 +18,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/rand"
 	"os"
@@ -27,16 +28,20 @@ import (
 
 	flag "github.com/spf13/pflag"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1beta1"
 
 	goflag "flag"
 
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	"k8s.io/klog/v2"
 
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 
@@ -46,6 +51,7 @@ import (
 	infrav1b2 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
 	"sigs.k8s.io/cluster-api-provider-cloudstack/controllers"
 	"sigs.k8s.io/cluster-api-provider-cloudstack/controllers/utils"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -57,6 +63,7 @@ var (
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 	utilruntime.Must(clusterv1.AddToScheme(scheme))
+	utilruntime.Must(expv1.AddToScheme(scheme))
 	utilruntime.Must(infrav1b1.AddToScheme(scheme))
 	utilruntime.Must(infrav1b2.AddToScheme(scheme))
 	utilruntime.Must(controlplanev1.AddToScheme(scheme))
@@ -71,6 +78,11 @@ type managerOpts struct {
 	WatchingNamespace    string
 	WatchFilterValue     string
 	CertDir              string
+	OrphanGCMode         string
+	OrphanGCInterval     time.Duration
+	OrphanGCGracePeriod  time.Duration
+	FDHealthInterval     time.Duration
+	ManagementClusterID  string
 }
 
 func setFlags() *managerOpts {
@@ -115,6 +127,38 @@ func setFlags() *managerOpts {
 		"webhook-cert-dir",
 		"/tmp/k8s-webhook-server/serving-certs/",
 		"Specify the directory where webhooks will get tls certificates.")
+	flag.StringVar(
+		&opts.OrphanGCMode,
+		"orphan-gc-mode",
+		"",
+		fmt.Sprintf(
+			"Look for CloudStack resources created by CAPC that no CloudStackCluster uses anymore. Either %q to report "+
+				"them as metrics and events, or %q to also dispose of them after the grace period. If unspecified, "+
+				"orphaned resources aren't looked for.",
+			controllers.OrphanCollectionModeReport, controllers.OrphanCollectionModeEnforce))
+	flag.DurationVar(
+		&opts.OrphanGCInterval,
+		"orphan-gc-interval",
+		10*time.Minute,
+		"How often each CloudStack account is scanned for orphaned resources.")
+	flag.DurationVar(
+		&opts.OrphanGCGracePeriod,
+		"orphan-gc-grace-period",
+		time.Hour,
+		"How long a resource must have been orphaned before it is disposed of in enforce mode.")
+	flag.DurationVar(
+		&opts.FDHealthInterval,
+		"failure-domain-health-interval",
+		controllers.DefaultFailureDomainHealthProbeInterval,
+		"How often failure domains are probed for whether they can take new machines. Unhealthy failure domains "+
+			"are left out of machine placement. Machine template capacities are resolved again as often.")
+	flag.StringVar(
+		&opts.ManagementClusterID,
+		"management-cluster-id",
+		"",
+		"Identifies this management cluster on the CloudStack resources CAPC creates, so that orphaned resource "+
+			"collection leaves those of other management clusters sharing an account alone. Must differ between "+
+			"management clusters. Defaults to the UID of the kube-system namespace.")
 	return opts
 }
 
@@ -153,7 +197,9 @@ func main() {
 		Scheme:     mgr.GetScheme()}
 
 	ctx := ctrl.SetupSignalHandler()
-	setupReconcilers(ctx, base, mgr)
+	setupManagementClusterID(ctx, mgr, opts)
+	setupReconcilers(ctx, base, mgr, opts)
+	setupOrphanCollector(base, mgr, opts)
 	infrav1b2.K8sClient = base.K8sClient
 
 	// +kubebuilder:scaffold:builder
@@ -181,6 +227,18 @@ func main() {
 		setupLog.Error(err, "unable to create webhook", "webhook", "CloudStackMachineTemplate")
 		os.Exit(1)
 	}
+	if err = (&infrav1b2.CloudStackIPPool{}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "CloudStackIPPool")
+		os.Exit(1)
+	}
+	if err = (&infrav1b2.CloudStackLoadBalancer{}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "CloudStackLoadBalancer")
+		os.Exit(1)
+	}
+	if err = (&infrav1b2.CloudStackMachinePool{}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "CloudStackMachinePool")
+		os.Exit(1)
+	}
 
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctx); err != nil {
@@ -189,7 +247,24 @@ func main() {
 	}
 }
 
-func setupReconcilers(ctx context.Context, base utils.ReconcilerBase, mgr manager.Manager) {
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get
+
+// setupManagementClusterID sets the identity CAPC tags the resources it creates with, the UID of the kube-system
+// namespace unless one is passed. The namespace lives as long as the management cluster does.
+func setupManagementClusterID(ctx context.Context, mgr manager.Manager, opts *managerOpts) {
+	if opts.ManagementClusterID == "" {
+		kubeSystem := &corev1.Namespace{}
+		if err := mgr.GetAPIReader().Get(ctx, client.ObjectKey{Name: metav1.NamespaceSystem}, kubeSystem); err != nil {
+			setupLog.Error(err, "unable to identify the management cluster, pass management-cluster-id")
+			os.Exit(1)
+		}
+		opts.ManagementClusterID = string(kubeSystem.UID)
+	}
+	cloud.ManagementClusterID = opts.ManagementClusterID
+	setupLog.Info("identified the management cluster", "id", cloud.ManagementClusterID)
+}
+
+func setupReconcilers(ctx context.Context, base utils.ReconcilerBase, mgr manager.Manager, opts *managerOpts) {
 	if err := (&controllers.CloudStackClusterReconciler{ReconcilerBase: base}).SetupWithManager(ctx, mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "CloudStackCluster")
 		os.Exit(1)
@@ -206,8 +281,50 @@ func setupReconcilers(ctx context.Context, base utils.ReconcilerBase, mgr manage
 		setupLog.Error(err, "unable to create controller", "controller", "CloudStackAffinityGroup")
 		os.Exit(1)
 	}
-	if err := (&controllers.CloudStackFailureDomainReconciler{ReconcilerBase: base}).SetupWithManager(mgr); err != nil {
+	if err := (&controllers.CloudStackFailureDomainReconciler{
+		ReconcilerBase: base, HealthProbeInterval: opts.FDHealthInterval}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "CloudStackFailureDomain")
 		os.Exit(1)
 	}
+	if err := (&controllers.CloudStackLoadBalancerReconciler{ReconcilerBase: base}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "CloudStackLoadBalancer")
+		os.Exit(1)
+	}
+	if err := (&controllers.CloudStackMachinePoolReconciler{ReconcilerBase: base}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "CloudStackMachinePool")
+		os.Exit(1)
+	}
+	if err := (&controllers.CloudStackMachineTemplateReconciler{
+		ReconcilerBase: base, HealthProbeInterval: opts.FDHealthInterval}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "CloudStackMachineTemplate")
+		os.Exit(1)
+	}
+}
+
+func setupOrphanCollector(base utils.ReconcilerBase, mgr manager.Manager, opts *managerOpts) {
+	switch opts.OrphanGCMode {
+	case "":
+		return
+	case controllers.OrphanCollectionModeReport, controllers.OrphanCollectionModeEnforce:
+	default:
+		setupLog.Error(errors.New("invalid orphan-gc-mode"), "unable to create controller",
+			"controller", "CloudStackOrphanCollector", "mode", opts.OrphanGCMode)
+		os.Exit(1)
+	}
+	// Resources of clusters in namespaces that aren't watched would look orphaned.
+	if opts.WatchingNamespace != "" {
+		setupLog.Error(errors.New("orphan-gc-mode can't be combined with namespace"), "unable to create controller",
+			"controller", "CloudStackOrphanCollector")
+		os.Exit(1)
+	}
+	collector := &controllers.CloudStackOrphanCollector{
+		ReconcilerBase: base,
+		Mode:           opts.OrphanGCMode,
+		Interval:       opts.OrphanGCInterval,
+		GracePeriod:    opts.OrphanGCGracePeriod,
+	}
+	if err := collector.SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "CloudStackOrphanCollector")
+		os.Exit(1)
+	}
 }
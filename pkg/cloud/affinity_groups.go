@@ -17,6 +17,8 @@ limitations under the License.
 package cloud
 
 import (
+	"context"
+
 	"github.com/pkg/errors"
 	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
 )
@@ -33,14 +35,17 @@ type AffinityGroup struct {
 }
 
 type AffinityGroupIface interface {
-	FetchAffinityGroup(*AffinityGroup) error
-	GetOrCreateAffinityGroup(*AffinityGroup) error
-	DeleteAffinityGroup(*AffinityGroup) error
-	AssociateAffinityGroup(*infrav1.CloudStackMachine, AffinityGroup) error
-	DisassociateAffinityGroup(*infrav1.CloudStackMachine, AffinityGroup) error
+	FetchAffinityGroup(context.Context, *AffinityGroup) error
+	GetOrCreateAffinityGroup(context.Context, *AffinityGroup) error
+	DeleteAffinityGroup(context.Context, *AffinityGroup) error
+	AssociateAffinityGroup(context.Context, *infrav1.CloudStackMachine, AffinityGroup) error
+	DisassociateAffinityGroup(context.Context, *infrav1.CloudStackMachine, AffinityGroup) error
 }
 
-func (c *client) FetchAffinityGroup(group *AffinityGroup) (reterr error) {
+func (c *client) FetchAffinityGroup(ctx context.Context, group *AffinityGroup) (reterr error) {
+	c, _, cancel := c.withTimeout(ctx, OperationResolve)
+	defer cancel()
+
 	if group.ID != "" {
 		affinityGroup, count, err := c.cs.AffinityGroup.GetAffinityGroupByID(group.ID)
 		if err != nil {
@@ -74,8 +79,11 @@ func (c *client) FetchAffinityGroup(group *AffinityGroup) (reterr error) {
 	return errors.Errorf(`could not fetch AffinityGroup by name "%s" or id "%s"`, group.Name, group.ID)
 }
 
-func (c *client) GetOrCreateAffinityGroup(group *AffinityGroup) (retErr error) {
-	if err := c.FetchAffinityGroup(group); err != nil { // Group not found?
+func (c *client) GetOrCreateAffinityGroup(ctx context.Context, group *AffinityGroup) (retErr error) {
+	c, ctx, cancel := c.withTimeout(ctx, OperationDefault)
+	defer cancel()
+
+	if err := c.FetchAffinityGroup(ctx, group); err != nil { // Group not found?
 		p := c.cs.AffinityGroup.NewCreateAffinityGroupParams(group.Name, group.Type)
 		p.SetName(group.Name)
 		resp, err := c.cs.AffinityGroup.CreateAffinityGroup(p)
@@ -88,7 +96,10 @@ func (c *client) GetOrCreateAffinityGroup(group *AffinityGroup) (retErr error) {
 	return nil
 }
 
-func (c *client) DeleteAffinityGroup(group *AffinityGroup) (retErr error) {
+func (c *client) DeleteAffinityGroup(ctx context.Context, group *AffinityGroup) (retErr error) {
+	c, _, cancel := c.withTimeout(ctx, OperationDefault)
+	defer cancel()
+
 	p := c.cs.AffinityGroup.NewDeleteAffinityGroupParams()
 	setIfNotEmpty(group.ID, p.SetId)
 	setIfNotEmpty(group.Name, p.SetName)
@@ -169,7 +180,10 @@ func (c *client) stopAndModifyAffinityGroups(csMachine *infrav1.CloudStackMachin
 	return err
 }
 
-func (c *client) AssociateAffinityGroup(csMachine *infrav1.CloudStackMachine, group AffinityGroup) (retErr error) {
+func (c *client) AssociateAffinityGroup(ctx context.Context, csMachine *infrav1.CloudStackMachine, group AffinityGroup) (retErr error) {
+	c, _, cancel := c.withTimeout(ctx, OperationUpdateVM)
+	defer cancel()
+
 	groups, err := c.getCurrentAffinityGroups(csMachine)
 	if err != nil {
 		return err
@@ -178,7 +192,10 @@ func (c *client) AssociateAffinityGroup(csMachine *infrav1.CloudStackMachine, gr
 	return c.stopAndModifyAffinityGroups(csMachine, groups)
 }
 
-func (c *client) DisassociateAffinityGroup(csMachine *infrav1.CloudStackMachine, group AffinityGroup) (retErr error) {
+func (c *client) DisassociateAffinityGroup(ctx context.Context, csMachine *infrav1.CloudStackMachine, group AffinityGroup) (retErr error) {
+	c, _, cancel := c.withTimeout(ctx, OperationUpdateVM)
+	defer cancel()
+
 	groups, err := c.getCurrentAffinityGroups(csMachine)
 	if err != nil {
 		return err
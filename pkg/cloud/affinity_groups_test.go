@@ -38,7 +38,6 @@ var _ = Describe("AffinityGroup Unit Tests", func() {
 		mockClient *cloudstack.CloudStackClient
 		ags        *cloudstack.MockAffinityGroupServiceIface
 		vms        *cloudstack.MockVirtualMachineServiceIface
-		client     cloud.Client
 	)
 
 	BeforeEach(func() {
@@ -60,13 +59,13 @@ var _ = Describe("AffinityGroup Unit Tests", func() {
 			dummies.AffinityGroup.ID = "" // Force name fetching.
 			ags.EXPECT().GetAffinityGroupByName(dummies.AffinityGroup.Name).Return(&cloudstack.AffinityGroup{}, 1, nil)
 
-			Ω(client.GetOrCreateAffinityGroup(dummies.AffinityGroup)).Should(Succeed())
+			Ω(client.GetOrCreateAffinityGroup(ctx, dummies.AffinityGroup)).Should(Succeed())
 		})
 
 		It("fetches an affinity group by ID", func() {
 			ags.EXPECT().GetAffinityGroupByID(dummies.AffinityGroup.ID).Return(&cloudstack.AffinityGroup{}, 1, nil)
 
-			Ω(client.GetOrCreateAffinityGroup(dummies.AffinityGroup)).Should(Succeed())
+			Ω(client.GetOrCreateAffinityGroup(ctx, dummies.AffinityGroup)).Should(Succeed())
 		})
 
 		It("creates an affinity group", func() {
@@ -78,7 +77,7 @@ var _ = Describe("AffinityGroup Unit Tests", func() {
 			ags.EXPECT().CreateAffinityGroup(ParamMatch(And(NameEquals(dummies.AffinityGroup.Name)))).
 				Return(&cloudstack.CreateAffinityGroupResponse{}, nil)
 
-			Ω(client.GetOrCreateAffinityGroup(dummies.AffinityGroup)).Should(Succeed())
+			Ω(client.GetOrCreateAffinityGroup(ctx, dummies.AffinityGroup)).Should(Succeed())
 		})
 
 		It("creates an affinity group if Name provided returns more than one affinity group", func() {
@@ -88,7 +87,7 @@ var _ = Describe("AffinityGroup Unit Tests", func() {
 			ags.EXPECT().NewCreateAffinityGroupParams(gomock.Any(), gomock.Any()).Return(agp)
 			ags.EXPECT().CreateAffinityGroup(agp).Return(&cloudstack.CreateAffinityGroupResponse{}, nil)
 
-			Ω(client.GetOrCreateAffinityGroup(dummies.AffinityGroup)).Should(Succeed())
+			Ω(client.GetOrCreateAffinityGroup(ctx, dummies.AffinityGroup)).Should(Succeed())
 		})
 
 		It("creates an affinity group if getting affinity group by name fails", func() {
@@ -98,7 +97,7 @@ var _ = Describe("AffinityGroup Unit Tests", func() {
 			ags.EXPECT().NewCreateAffinityGroupParams(gomock.Any(), gomock.Any()).Return(agp)
 			ags.EXPECT().CreateAffinityGroup(agp).Return(&cloudstack.CreateAffinityGroupResponse{}, nil)
 
-			Ω(client.GetOrCreateAffinityGroup(dummies.AffinityGroup)).Should(Succeed())
+			Ω(client.GetOrCreateAffinityGroup(ctx, dummies.AffinityGroup)).Should(Succeed())
 		})
 
 		It("creates an affinity group if ID provided returns more than one affinity group", func() {
@@ -107,7 +106,7 @@ var _ = Describe("AffinityGroup Unit Tests", func() {
 			ags.EXPECT().NewCreateAffinityGroupParams(gomock.Any(), gomock.Any()).Return(agp)
 			ags.EXPECT().CreateAffinityGroup(agp).Return(&cloudstack.CreateAffinityGroupResponse{}, nil)
 
-			Ω(client.GetOrCreateAffinityGroup(dummies.AffinityGroup)).Should(Succeed())
+			Ω(client.GetOrCreateAffinityGroup(ctx, dummies.AffinityGroup)).Should(Succeed())
 		})
 
 		It("creates an affinity group if getting affinity group by ID fails", func() {
@@ -116,7 +115,7 @@ var _ = Describe("AffinityGroup Unit Tests", func() {
 			ags.EXPECT().NewCreateAffinityGroupParams(gomock.Any(), gomock.Any()).Return(agp)
 			ags.EXPECT().CreateAffinityGroup(agp).Return(&cloudstack.CreateAffinityGroupResponse{}, nil)
 
-			Ω(client.GetOrCreateAffinityGroup(dummies.AffinityGroup)).Should(Succeed())
+			Ω(client.GetOrCreateAffinityGroup(ctx, dummies.AffinityGroup)).Should(Succeed())
 		})
 	})
 
@@ -126,7 +125,7 @@ var _ = Describe("AffinityGroup Unit Tests", func() {
 			ags.EXPECT().NewDeleteAffinityGroupParams().Return(agp)
 			ags.EXPECT().DeleteAffinityGroup(agp).Return(&cloudstack.DeleteAffinityGroupResponse{}, nil)
 
-			Ω(client.DeleteAffinityGroup(dummies.AffinityGroup)).Should(Succeed())
+			Ω(client.DeleteAffinityGroup(ctx, dummies.AffinityGroup)).Should(Succeed())
 		})
 	})
 
@@ -138,15 +137,16 @@ var _ = Describe("AffinityGroup Unit Tests", func() {
 		})
 
 		It("Associates an affinity group.", func() {
-			Ω(client.ResolveZone(&dummies.CSFailureDomain1.Spec.Zone)).Should(Succeed())
+			Ω(client.ResolveZone(ctx, &dummies.CSFailureDomain1.Spec.Zone)).Should(Succeed())
 			dummies.CSMachine1.Spec.DiskOffering.Name = ""
 
 			Ω(client.GetOrCreateVMInstance(
+				ctx,
 				dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster, dummies.CSFailureDomain1, dummies.CSAffinityGroup, "",
 			)).Should(Succeed())
 
-			Ω(client.GetOrCreateAffinityGroup(dummies.AffinityGroup)).Should(Succeed())
-			Ω(client.AssociateAffinityGroup(dummies.CSMachine1, *dummies.AffinityGroup)).Should(Succeed())
+			Ω(client.GetOrCreateAffinityGroup(ctx, dummies.AffinityGroup)).Should(Succeed())
+			Ω(client.AssociateAffinityGroup(ctx, dummies.CSMachine1, *dummies.AffinityGroup)).Should(Succeed())
 
 			// Make the created VM go away quickly by force stopping it.
 			p := realCSClient.VirtualMachine.NewStopVirtualMachineParams(*dummies.CSMachine1.Spec.InstanceID)
@@ -156,8 +156,8 @@ var _ = Describe("AffinityGroup Unit Tests", func() {
 		})
 
 		It("Creates and deletes an affinity group.", func() {
-			Ω(client.DeleteAffinityGroup(dummies.AffinityGroup)).Should(Succeed())
-			Ω(client.FetchAffinityGroup(dummies.AffinityGroup)).ShouldNot(Succeed())
+			Ω(client.DeleteAffinityGroup(ctx, dummies.AffinityGroup)).Should(Succeed())
+			Ω(client.FetchAffinityGroup(ctx, dummies.AffinityGroup)).ShouldNot(Succeed())
 		})
 	})
 
@@ -171,7 +171,7 @@ var _ = Describe("AffinityGroup Unit Tests", func() {
 		ags.EXPECT().UpdateVMAffinityGroup(uagp).Return(&cloudstack.UpdateVMAffinityGroupResponse{}, nil)
 		vms.EXPECT().NewStartVirtualMachineParams(*dummies.CSMachine1.Spec.InstanceID).Return(vmp)
 		vms.EXPECT().StartVirtualMachine(vmp).Return(&cloudstack.StartVirtualMachineResponse{}, nil)
-		Ω(client.AssociateAffinityGroup(dummies.CSMachine1, *dummies.AffinityGroup)).Should(Succeed())
+		Ω(client.AssociateAffinityGroup(ctx, dummies.CSMachine1, *dummies.AffinityGroup)).Should(Succeed())
 	})
 
 	It("Disassociate affinity group", func() {
@@ -184,6 +184,26 @@ var _ = Describe("AffinityGroup Unit Tests", func() {
 		ags.EXPECT().UpdateVMAffinityGroup(uagp).Return(&cloudstack.UpdateVMAffinityGroupResponse{}, nil)
 		vms.EXPECT().NewStartVirtualMachineParams(*dummies.CSMachine1.Spec.InstanceID).Return(vmp)
 		vms.EXPECT().StartVirtualMachine(vmp).Return(&cloudstack.StartVirtualMachineResponse{}, nil)
-		Ω(client.DisassociateAffinityGroup(dummies.CSMachine1, *dummies.AffinityGroup)).Should(Succeed())
+		Ω(client.DisassociateAffinityGroup(ctx, dummies.CSMachine1, *dummies.AffinityGroup)).Should(Succeed())
+	})
+
+	Context("with the simulator", func() {
+		UseSimulator()
+
+		It("places a VM in an affinity group", func() {
+			Ω(client.GetOrCreateAffinityGroup(ctx, dummies.AffinityGroup)).Should(Succeed())
+			Ω(dummies.AffinityGroup.ID).ShouldNot(BeEmpty())
+			Ω(client.GetOrCreateAffinityGroup(ctx, dummies.AffinityGroup)).Should(Succeed())
+			Ω(sim.AffinityGroups()).Should(HaveLen(1))
+
+			dummies.CSMachine1.Spec.AffinityGroupIDs = []string{dummies.AffinityGroup.ID}
+			Ω(client.GetOrCreateVMInstance(ctx, dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster,
+				dummies.CSFailureDomain1, dummies.CSAffinityGroup, "userdata")).Should(Succeed())
+			Ω(sim.AffinityGroups()[0].VirtualmachineIds).Should(ConsistOf(*dummies.CSMachine1.Spec.InstanceID))
+
+			Ω(client.DestroyVMInstance(ctx, dummies.CSMachine1)).Should(Succeed())
+			Ω(client.DeleteAffinityGroup(ctx, dummies.AffinityGroup)).Should(Succeed())
+			Ω(sim.AffinityGroups()).Should(BeEmpty())
+		})
 	})
 })
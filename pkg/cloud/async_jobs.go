@@ -0,0 +1,108 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
+)
+
+// The CloudStack API commands whose async jobs are recorded in resource status rather than waited for.
+const (
+	CommandDeployVM                 = "deployVirtualMachine"
+	CommandDestroyVM                = "destroyVirtualMachine"
+	CommandStopVM                   = "stopVirtualMachine"
+	CommandStartVM                  = "startVirtualMachine"
+	CommandAssociateIPAddress       = "associateIpAddress"
+	CommandCreateSnapshot           = "createSnapshot"
+	CommandDetachVolume             = "detachVolume"
+	CommandDisassociateIPAddress    = "disassociateIpAddress"
+	CommandCreateEgressFirewallRule = "createEgressFirewallRule"
+	CommandCreateFirewallRule       = "createFirewallRule"
+	CommandCreateLoadBalancerRule   = "createLoadBalancerRule"
+)
+
+// The job statuses queryAsyncJobResult reports.
+const (
+	jobStatusPending   = 0
+	jobStatusSucceeded = 1
+)
+
+// JobPendingError is returned by operations that started a CloudStack async job, or found the one they started
+// before still running. The job is recorded in the status of the resource the operation acts on, and the operation
+// polls it when called again, as reconcilers do on requeue.
+type JobPendingError struct {
+	Job infrav1.AsyncJob
+}
+
+func (e *JobPendingError) Error() string {
+	return fmt.Sprintf("waiting for CloudStack async job %s (%s)", e.Job.ID, e.Job.Command)
+}
+
+// IsJobPending reports whether err is an operation waiting on a CloudStack async job.
+func IsJobPending(err error) bool {
+	pending := &JobPendingError{}
+	return errors.As(err, &pending)
+}
+
+// startAsyncJob records the job an async command started in the passed status field and polls it once, so jobs
+// finishing right away don't cost a requeue. Commands that ran to completion, returning no job, have nothing to wait
+// for.
+func (c *client) startAsyncJob(job **infrav1.AsyncJob, command, jobID string) error {
+	if jobID == "" {
+		*job = nil
+		return nil
+	}
+	*job = &infrav1.AsyncJob{ID: jobID, Command: command, StartTime: metav1.Now()}
+	return c.pollAsyncJob(job)
+}
+
+// pollAsyncJob queries the job recorded in the passed status field, if there's one. It returns a JobPendingError
+// while the job runs, and the job's error if it failed. Jobs are cleared from the field once they're done. The errors
+// of failed jobs carry their job result, so they're classified like those of requests failing by themselves.
+func (c *client) pollAsyncJob(job **infrav1.AsyncJob) error {
+	if *job == nil {
+		return nil
+	}
+	recorded := **job
+	resp, err := c.cs.Asyncjob.QueryAsyncJobResult(c.cs.Asyncjob.NewQueryAsyncJobResultParams(recorded.ID))
+	if err != nil && strings.Contains(strings.ToLower(err.Error()), "unable to find job") {
+		// CloudStack purges finished jobs after a while. Whatever the job did is found in the resource's state.
+		*job = nil
+		return nil
+	} else if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return errors.Wrapf(err, "querying CloudStack async job %s (%s)", recorded.ID, recorded.Command)
+	}
+
+	switch resp.Jobstatus {
+	case jobStatusPending:
+		return &JobPendingError{Job: recorded}
+	case jobStatusSucceeded:
+		*job = nil
+		return nil
+	}
+	*job = nil
+	jobErr := errors.Errorf("CloudStack async job %s (%s) failed: %s", recorded.ID, recorded.Command, string(resp.Jobresult))
+	c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(jobErr)
+	return jobErr
+}
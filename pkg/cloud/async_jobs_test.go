@@ -0,0 +1,123 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/utils/pointer"
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
+	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta2"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/test/simulator"
+)
+
+var _ = Describe("Async jobs", func() {
+	UseSimulator()
+
+	It("records the jobs deploying and destroying a VM in its machine's status until they finish", func() {
+		sim.HoldJobs("deployVirtualMachine")
+		for i := 0; i < 2; i++ {
+			err := client.GetOrCreateVMInstance(ctx, dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster,
+				dummies.CSFailureDomain1, dummies.CSAffinityGroup, "userdata")
+			Ω(cloud.IsJobPending(err)).Should(BeTrue())
+			Ω(dummies.CSMachine1.Status.AsyncJob).ShouldNot(BeNil())
+			Ω(dummies.CSMachine1.Status.AsyncJob.Command).Should(Equal(cloud.CommandDeployVM))
+		}
+		Ω(sim.RequestCount("deployVirtualMachine")).Should(Equal(1))
+		Ω(dummies.CSMachine1.Spec.InstanceID).Should(Equal(pointer.String(sim.VirtualMachines()[0].Id)))
+
+		sim.ReleaseJobs("deployVirtualMachine")
+		Ω(client.GetOrCreateVMInstance(ctx, dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster,
+			dummies.CSFailureDomain1, dummies.CSAffinityGroup, "userdata")).Should(Succeed())
+		Ω(dummies.CSMachine1.Status.AsyncJob).Should(BeNil())
+		Ω(dummies.CSMachine1.Status.InstanceState).Should(Equal("Running"))
+
+		sim.HoldJobs("destroyVirtualMachine")
+		for i := 0; i < 2; i++ {
+			err := client.DestroyVMInstance(ctx, dummies.CSMachine1)
+			Ω(cloud.IsJobPending(err)).Should(BeTrue())
+			Ω(dummies.CSMachine1.Status.AsyncJob.Command).Should(Equal(cloud.CommandDestroyVM))
+		}
+		Ω(sim.RequestCount("destroyVirtualMachine")).Should(Equal(1))
+
+		sim.ReleaseJobs("destroyVirtualMachine")
+		Ω(client.DestroyVMInstance(ctx, dummies.CSMachine1)).Should(Succeed())
+		Ω(dummies.CSMachine1.Status.AsyncJob).Should(BeNil())
+		Ω(sim.VirtualMachines()).Should(BeEmpty())
+	})
+
+	It("records the jobs setting up an isolated network's rules and releasing its public IP in its status", func() {
+		dummies.SetDummyIsoNetToNameOnly()
+		dummies.CSFailureDomain1.Spec.Zone = dummies.Zone1
+		dummies.CSCluster.Spec.ControlPlaneEndpoint.Host = ""
+		dummies.CSCluster.Spec.LoadBalancer = &infrav1.LoadBalancerSpec{AllowedCIDRs: []string{"10.0.0.0/8"}}
+		sim.AddNetwork(dummies.Zone1.ID, "other-network", simulator.NetworkTypeShared, "10.20.0.0/24")
+
+		for _, command := range []string{
+			cloud.CommandCreateLoadBalancerRule, cloud.CommandCreateFirewallRule, cloud.CommandCreateEgressFirewallRule} {
+			sim.HoldJobs(command)
+			for i := 0; i < 2; i++ {
+				err := client.GetOrCreateIsolatedNetwork(ctx, dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)
+				Ω(cloud.IsJobPending(err)).Should(BeTrue())
+				Ω(dummies.CSISONet1.Status.AsyncJob.Command).Should(Equal(command))
+			}
+			Ω(sim.RequestCount(command)).Should(Equal(1))
+			sim.ReleaseJobs(command)
+		}
+		Ω(client.GetOrCreateIsolatedNetwork(
+			ctx, dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).Should(Succeed())
+		Ω(dummies.CSISONet1.Status.AsyncJob).Should(BeNil())
+		Ω(dummies.CSISONet1.Status.LBRuleID).Should(Equal(sim.LoadBalancerRules()[0].Id))
+		Ω(sim.FirewallRules()).Should(HaveLen(1))
+		Ω(sim.EgressFirewallRules()).Should(HaveLen(1))
+
+		sim.HoldJobs(cloud.CommandDisassociateIPAddress)
+		for i := 0; i < 2; i++ {
+			err := client.DisposeIsoNetResources(ctx, dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)
+			Ω(cloud.IsJobPending(err)).Should(BeTrue())
+			Ω(dummies.CSISONet1.Status.AsyncJob.Command).Should(Equal(cloud.CommandDisassociateIPAddress))
+		}
+		Ω(sim.RequestCount(cloud.CommandDisassociateIPAddress)).Should(Equal(1))
+
+		sim.ReleaseJobs(cloud.CommandDisassociateIPAddress)
+		Ω(client.DisposeIsoNetResources(
+			ctx, dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).Should(Succeed())
+		Ω(dummies.CSISONet1.Status.AsyncJob).Should(BeNil())
+		for _, net := range sim.Networks() {
+			Ω(net.Id).ShouldNot(Equal(dummies.CSISONet1.Spec.ID))
+		}
+	})
+
+	It("surfaces the error of a failed job and clears it from the machine's status", func() {
+		Ω(client.GetOrCreateVMInstance(ctx, dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster,
+			dummies.CSFailureDomain1, dummies.CSAffinityGroup, "userdata")).Should(Succeed())
+		sim.FailNext("destroyVirtualMachine", simulator.NewAPIError(
+			simulator.ErrorCodeInternalError, "Failed to destroy VM"))
+
+		err := client.DestroyVMInstance(ctx, dummies.CSMachine1)
+		Ω(err).Should(MatchError(ContainSubstring("Failed to destroy VM")))
+		Ω(cloud.IsJobPending(err)).Should(BeFalse())
+		Ω(dummies.CSMachine1.Status.AsyncJob).Should(BeNil())
+		Ω(sim.VirtualMachines()).Should(HaveLen(1))
+
+		// The next attempt starts a new job.
+		Ω(client.DestroyVMInstance(ctx, dummies.CSMachine1)).Should(Succeed())
+		Ω(sim.VirtualMachines()).Should(BeEmpty())
+		Ω(sim.RequestCount("destroyVirtualMachine")).Should(Equal(2))
+	})
+})
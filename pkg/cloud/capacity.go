@@ -0,0 +1,120 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
+)
+
+// CapacityIface resolves the resources machines of a template have, for the cluster autoscaler to scale from zero.
+type CapacityIface interface {
+	ResolveMachineTemplateCapacity(context.Context, *infrav1.CloudStackMachineTemplate, *infrav1.CloudStackCluster, string) error
+}
+
+// ResolveMachineTemplateCapacity sets the capacity and node info of a machine template from its compute offering and
+// CloudStack template in the passed zone. Custom offerings take their CPU count and memory from the cpuNumber and
+// memory details. The root disk is the size of the template, unless the offering or the rootdisksize detail asks for
+// a larger one.
+func (c *client) ResolveMachineTemplateCapacity(
+	ctx context.Context,
+	csTemplate *infrav1.CloudStackMachineTemplate,
+	csCluster *infrav1.CloudStackCluster,
+	zoneID string,
+) error {
+	c, _, cancel := c.withTimeout(ctx, OperationResolve)
+	defer cancel()
+
+	csMachine := &infrav1.CloudStackMachine{Spec: csTemplate.Spec.Spec.Spec}
+	offeringID, err := c.ResolveServiceOffering(csMachine, zoneID)
+	if err != nil {
+		return err
+	}
+	offering, count, err := c.cs.ServiceOffering.GetServiceOfferingByID(offeringID)
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return errors.Wrapf(err, "fetching Service Offering with ID %s", offeringID)
+	} else if count != 1 {
+		return errors.Errorf("expected 1 Service Offering with ID %s, but got %d", offeringID, count)
+	}
+	templateID, err := c.ResolveTemplate(csCluster, csMachine, zoneID)
+	if err != nil {
+		return err
+	}
+	template, count, err := c.cs.Template.GetTemplateByID(templateID, "executable")
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return errors.Wrapf(err, "fetching Template with ID %s", templateID)
+	} else if count != 1 {
+		return errors.Errorf("expected 1 Template with ID %s, but got %d", templateID, count)
+	}
+
+	cpus, memoryMB, rootDiskGB := int64(offering.Cpunumber), int64(offering.Memory), offering.Rootdisksize
+	overrides := map[string]*int64{"rootdisksize": &rootDiskGB}
+	if offering.Iscustomized { // CloudStack ignores these details for fixed offerings.
+		overrides["cpuNumber"], overrides["memory"] = &cpus, &memoryMB
+	}
+	for key, target := range overrides {
+		if value, found := csMachine.Spec.Details[key]; found {
+			if *target, err = strconv.ParseInt(value, 10, 64); err != nil {
+				return errors.Wrapf(err, "parsing detail %s", key)
+			}
+		}
+	}
+	if (cpus <= 0 || memoryMB <= 0) && offering.Iscustomized {
+		return errors.Errorf("Service Offering %s is customized, so details cpuNumber and memory must be set", offering.Name)
+	} else if cpus <= 0 || memoryMB <= 0 {
+		return errors.Errorf("Service Offering %s reports %d CPUs and %d MB of memory", offering.Name, cpus, memoryMB)
+	}
+	// CloudStack never makes a root disk smaller than its template.
+	rootDisk := template.Size
+	if rootDiskGB<<30 > rootDisk {
+		rootDisk = rootDiskGB << 30
+	}
+
+	csTemplate.Status.Capacity = corev1.ResourceList{
+		corev1.ResourceCPU:    *resource.NewQuantity(cpus, resource.DecimalSI),
+		corev1.ResourceMemory: *resource.NewQuantity(memoryMB<<20, resource.BinarySI),
+	}
+	if rootDisk > 0 {
+		csTemplate.Status.Capacity[corev1.ResourceEphemeralStorage] = *resource.NewQuantity(rootDisk, resource.BinarySI)
+	}
+	csTemplate.Status.NodeInfo = nodeInfoFromOSType(template.Ostypename)
+	return nil
+}
+
+// nodeInfoFromOSType tells the architecture and operating system of nodes from the OS type of their CloudStack
+// template, such as "Ubuntu 20.04 (64-bit)" or "Windows Server 2019 (64-bit)". OS types only name ARM architectures
+// outright, so all others are taken as amd64.
+func nodeInfoFromOSType(osType string) *infrav1.NodeInfo {
+	osType = strings.ToLower(osType)
+	nodeInfo := &infrav1.NodeInfo{Architecture: "amd64", OperatingSystem: "linux"}
+	if strings.Contains(osType, "arm64") || strings.Contains(osType, "aarch64") {
+		nodeInfo.Architecture = "arm64"
+	}
+	if strings.Contains(osType, "windows") {
+		nodeInfo.OperatingSystem = "windows"
+	}
+	return nodeInfo
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
+	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta2"
+)
+
+var _ = Describe("Machine template capacity", func() {
+	var csTemplate *infrav1.CloudStackMachineTemplate
+
+	UseSimulator()
+
+	BeforeEach(func() {
+		csTemplate = dummies.CSMachineTemplate1
+		csTemplate.Spec.Spec.Spec = *dummies.CSMachine1.Spec.DeepCopy()
+	})
+
+	It("reports the CPU, memory and root disk of the template's machines", func() {
+		Ω(client.ResolveMachineTemplateCapacity(ctx, csTemplate, dummies.CSCluster, dummies.Zone1.ID)).Should(Succeed())
+
+		Ω(csTemplate.Status.Capacity.Cpu().String()).Should(Equal("2"))
+		Ω(csTemplate.Status.Capacity.Memory().String()).Should(Equal("4Gi"))
+		Ω(csTemplate.Status.Capacity.StorageEphemeral().String()).Should(Equal("8Gi"))
+		Ω(csTemplate.Status.NodeInfo).Should(Equal(&infrav1.NodeInfo{Architecture: "amd64", OperatingSystem: "linux"}))
+	})
+
+	It("takes a custom offering's CPU count, memory and root disk size from the template's details", func() {
+		custom := sim.AddScalableServiceOffering("custom", true, 0, 0)
+		csTemplate.Spec.Spec.Spec.Offering = infrav1.CloudStackResourceIdentifier{Name: custom.Name}
+		csTemplate.Spec.Spec.Spec.Details = map[string]string{"cpuNumber": "4", "memory": "8192", "rootdisksize": "20"}
+
+		Ω(client.ResolveMachineTemplateCapacity(ctx, csTemplate, dummies.CSCluster, dummies.Zone1.ID)).Should(Succeed())
+		Ω(csTemplate.Status.Capacity.Cpu().String()).Should(Equal("4"))
+		Ω(csTemplate.Status.Capacity.Memory().String()).Should(Equal("8Gi"))
+		Ω(csTemplate.Status.Capacity.StorageEphemeral().String()).Should(Equal("20Gi"))
+	})
+
+	It("fails for a custom offering without the cpuNumber and memory details", func() {
+		custom := sim.AddScalableServiceOffering("custom", true, 0, 0)
+		csTemplate.Spec.Spec.Spec.Offering = infrav1.CloudStackResourceIdentifier{Name: custom.Name}
+		csTemplate.Spec.Spec.Spec.Details = nil
+
+		Ω(client.ResolveMachineTemplateCapacity(ctx, csTemplate, dummies.CSCluster, dummies.Zone1.ID)).
+			Should(MatchError(ContainSubstring("is customized, so details cpuNumber and memory must be set")))
+		Ω(csTemplate.Status.Capacity).Should(BeEmpty())
+	})
+
+	It("fails for a fixed offering without CPUs or memory, whatever the template's details", func() {
+		fixed := sim.AddScalableServiceOffering("fixed", false, 0, 0)
+		csTemplate.Spec.Spec.Spec.Offering = infrav1.CloudStackResourceIdentifier{Name: fixed.Name}
+		csTemplate.Spec.Spec.Spec.Details = map[string]string{"cpuNumber": "4", "memory": "8192"}
+
+		err := client.ResolveMachineTemplateCapacity(ctx, csTemplate, dummies.CSCluster, dummies.Zone1.ID)
+		Ω(err).Should(MatchError(ContainSubstring("Service Offering fixed reports 0 CPUs and 0 MB of memory")))
+		Ω(err).ShouldNot(MatchError(ContainSubstring("is customized")))
+		Ω(csTemplate.Status.Capacity).Should(BeEmpty())
+	})
+})
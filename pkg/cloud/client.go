@@ -18,8 +18,10 @@ package cloud
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"sync"
 	"time"
@@ -43,8 +45,14 @@ type Client interface {
 	TagIface
 	ZoneIFace
 	IsoNetworkIface
+	EndpointProviderIface
+	LoadBalancerIface
 	UserCredIFace
-	NewClientInDomainAndAccount(string, string) (Client, error)
+	OrphanIface
+	HealthIface
+	MachinePoolIface
+	CapacityIface
+	NewClientInDomainAndAccount(context.Context, string, string) (Client, error)
 }
 
 // cloud-config ini structure.
@@ -59,6 +67,12 @@ type client struct {
 	cs            *cloudstack.CloudStackClient
 	csAsync       *cloudstack.CloudStackClient
 	config        Config
+	verifySSL     bool
+	transport     http.RoundTripper
+	boundClients  *sync.Pool
+	ctxTransport  *contextTransport
+	timeouts      ClientTimeouts
+	limits        ClientRateLimits
 	customMetrics metrics.ACSCustomMetrics
 }
 
@@ -147,6 +161,14 @@ func NewClientFromYamlPath(confPath string, secretName string) (Client, error) {
 
 // NewClientFromConf creates a new Cloud Client form a map of strings to strings.
 func NewClientFromConf(conf Config, clientConfig *corev1.ConfigMap) (Client, error) {
+	return newClientFromConf(conf, clientConfig, GetClientTimeouts(clientConfig), GetClientRateLimits(clientConfig))
+}
+
+// newClientFromConf creates a new Cloud Client whose operations take no longer than the passed timeouts, and whose
+// requests are held to the passed rate limits along with those of the other clients of the endpoint.
+func newClientFromConf(
+	conf Config, clientConfig *corev1.ConfigMap, timeouts ClientTimeouts, limits ClientRateLimits,
+) (Client, error) {
 	cacheMutex.Lock()
 	defer cacheMutex.Unlock()
 
@@ -154,7 +176,7 @@ func NewClientFromConf(conf Config, clientConfig *corev1.ConfigMap) (Client, err
 		clientCache = newClientCache(clientConfig)
 	}
 
-	clientCacheKey := generateClientCacheKey(conf)
+	clientCacheKey := generateClientCacheKey(conf, timeouts, limits)
 	if client, exists := clientCache.Get(clientCacheKey); exists {
 		return client.(Client), nil
 	}
@@ -166,42 +188,50 @@ func NewClientFromConf(conf Config, clientConfig *corev1.ConfigMap) (Client, err
 
 	// The client returned from NewAsyncClient works in a synchronous way. On the other hand,
 	// a client returned from NewClient works in an asynchronous way. Dive into the constructor definition
-	// comments for more details
-	c := &client{config: conf}
-	c.cs = cloudstack.NewAsyncClient(conf.APIUrl, conf.APIKey, conf.SecretKey, verifySSL)
-	c.csAsync = cloudstack.NewClient(conf.APIUrl, conf.APIKey, conf.SecretKey, verifySSL)
+	// comments for more details. Operations use those of a bound copy of the client, made in their context.
+	transport := &rateLimitedTransport{limiter: limiterFor(conf.APIUrl, limits), base: newTransport(verifySSL)}
+	c := &client{config: conf, verifySSL: verifySSL, transport: transport, timeouts: timeouts, limits: limits}
+	httpClient := cloudstack.WithHTTPClient(&http.Client{Transport: transport, Timeout: defaultRequestTimeout})
+	c.cs = cloudstack.NewAsyncClient(conf.APIUrl, conf.APIKey, conf.SecretKey, verifySSL, httpClient)
+	c.csAsync = cloudstack.NewClient(conf.APIUrl, conf.APIKey, conf.SecretKey, verifySSL, httpClient)
 	c.customMetrics = metrics.NewCustomMetrics()
+	c.boundClients = &sync.Pool{New: func() interface{} { return c.newBoundClient() }}
 	clientCache.Set(clientCacheKey, c)
 
 	return c, nil
 }
 
 // NewClientInDomainAndAccount returns a new client in the specified domain and account.
-func (c *client) NewClientInDomainAndAccount(domain string, account string) (Client, error) {
+func (c *client) NewClientInDomainAndAccount(ctx context.Context, domain string, account string) (Client, error) {
+	c, ctx, cancel := c.withTimeout(ctx, OperationResolve)
+	defer cancel()
+
 	user := &User{}
 	user.Account.Domain.Path = domain
 	user.Account.Name = account
-	if found, err := c.GetUserWithKeys(user); err != nil {
+	if found, err := c.GetUserWithKeys(ctx, user); err != nil {
 		return nil, err
 	} else if !found {
 		return nil, errors.Errorf(
 			"could not find sufficient user (with API keys) in domain/account %s/%s", domain, account)
 	}
-	c.config.APIKey = user.APIKey
-	c.config.SecretKey = user.SecretKey
+	conf := c.config
+	conf.APIKey = user.APIKey
+	conf.SecretKey = user.SecretKey
 
-	return NewClientFromConf(c.config, nil)
+	return newClientFromConf(conf, nil, c.timeouts, c.limits)
 }
 
 // NewClientFromCSAPIClient creates a client from a CloudStack-Go API client. Mostly used for testing.
 func NewClientFromCSAPIClient(cs *cloudstack.CloudStackClient) Client {
-	c := &client{cs: cs, csAsync: cs, customMetrics: metrics.NewCustomMetrics()}
+	c := &client{cs: cs, csAsync: cs, timeouts: DefaultClientTimeouts, limits: DefaultClientRateLimits,
+		customMetrics: metrics.NewCustomMetrics()}
 	return c
 }
 
-// generateClientCacheKey generates a cache key from a Config
-func generateClientCacheKey(conf Config) string {
-	return fmt.Sprintf("%+v", conf)
+// generateClientCacheKey generates a cache key from a Config, the timeouts of the client's operations and its rate limits
+func generateClientCacheKey(conf Config, timeouts ClientTimeouts, limits ClientRateLimits) string {
+	return fmt.Sprintf("%+v %v %+v", conf, timeouts, limits)
 }
 
 // newClientCache returns a new instance of client cache
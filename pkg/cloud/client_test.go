@@ -98,6 +98,61 @@ var _ = Describe("Client", func() {
 		})
 	})
 
+	Context("GetClientTimeouts", func() {
+		It("Returns the default timeouts when a nil is passed", func() {
+			result := cloud.GetClientTimeouts(nil)
+			Ω(result).Should(Equal(cloud.DefaultClientTimeouts))
+			Ω(result.For(cloud.OperationResolve)).Should(Equal(time.Minute))
+			Ω(result.For(cloud.OperationCreateVM)).Should(Equal(5 * time.Minute))
+		})
+
+		It("Overrides the default and per operation timeouts from the input clientConfig map", func() {
+			clientConfig := &corev1.ConfigMap{}
+			clientConfig.Data = map[string]string{}
+			clientConfig.Data[cloud.ClientTimeoutKey] = "2m"
+			clientConfig.Data[cloud.ClientTimeoutKey+"-create-vm"] = "15m"
+			result := cloud.GetClientTimeouts(clientConfig)
+			Ω(result.For(cloud.OperationCreateVM)).Should(Equal(15 * time.Minute))
+			Ω(result.For(cloud.OperationNetwork)).Should(Equal(2 * time.Minute))
+			Ω(result.For(cloud.OperationResolve)).Should(Equal(time.Minute))
+		})
+
+		It("Ignores invalid timeouts", func() {
+			clientConfig := &corev1.ConfigMap{}
+			clientConfig.Data = map[string]string{}
+			clientConfig.Data[cloud.ClientTimeoutKey] = "5mXXX"
+			clientConfig.Data[cloud.ClientTimeoutKey+"-destroy-vm"] = "-1m"
+			result := cloud.GetClientTimeouts(clientConfig)
+			Ω(result).Should(Equal(cloud.DefaultClientTimeouts))
+		})
+	})
+
+	Context("GetClientRateLimits", func() {
+		It("Returns the default rate limits when a nil is passed", func() {
+			Ω(cloud.GetClientRateLimits(nil)).Should(Equal(cloud.DefaultClientRateLimits))
+		})
+
+		It("Overrides the default rate limits from the input clientConfig map", func() {
+			clientConfig := &corev1.ConfigMap{}
+			clientConfig.Data = map[string]string{}
+			clientConfig.Data[cloud.ClientQPSKey] = "2.5"
+			clientConfig.Data[cloud.ClientMaxInFlightKey] = "4"
+			result := cloud.GetClientRateLimits(clientConfig)
+			Ω(result.QPS).Should(Equal(2.5))
+			Ω(result.Burst).Should(Equal(cloud.DefaultClientRateLimits.Burst))
+			Ω(result.MaxInFlight).Should(Equal(4))
+		})
+
+		It("Ignores invalid rate limits", func() {
+			clientConfig := &corev1.ConfigMap{}
+			clientConfig.Data = map[string]string{}
+			clientConfig.Data[cloud.ClientQPSKey] = "fast"
+			clientConfig.Data[cloud.ClientBurstKey] = "-1"
+			clientConfig.Data[cloud.ClientMaxInFlightKey] = "0"
+			Ω(cloud.GetClientRateLimits(clientConfig)).Should(Equal(cloud.DefaultClientRateLimits))
+		})
+	})
+
 	Context("NewClientFromConf", func() {
 		clientConfig := &corev1.ConfigMap{}
 
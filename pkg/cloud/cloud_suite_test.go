@@ -17,6 +17,7 @@ limitations under the License.
 package cloud_test
 
 import (
+	"context"
 	"os"
 	"strings"
 	"testing"
@@ -24,18 +25,22 @@ import (
 	"github.com/apache/cloudstack-go/v2/cloudstack"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/uuid"
 	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
 	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta2"
 	"sigs.k8s.io/cluster-api-provider-cloudstack/test/helpers"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/test/simulator"
 )
 
 var (
 	// cloud.Client is our cloud package used to interact with ACS.
-	realCloudClient cloud.Client // Real cloud client is a cloud client connected to a real Apache CloudStack instance.
-	client          cloud.Client // client is simply a pointer to a cloud client object intended to be swapped per test.
+	realCloudClient cloud.Client         // Real cloud client is a cloud client connected to a real Apache CloudStack instance.
+	client          cloud.Client         // client is simply a pointer to a cloud client object intended to be swapped per test.
+	sim             *simulator.Simulator // sim is the CloudStack API simulator of the running spec, see UseSimulator.
 	realCSClient    *cloudstack.CloudStackClient
 	testDomainPath  string // Needed in before and in after suite.
+	ctx             = context.Background()
 )
 
 func TestCloud(t *testing.T) {
@@ -69,7 +74,7 @@ func TestCloud(t *testing.T) {
 			Ω(newUser.APIKey).ShouldNot(BeEmpty())
 
 			// Switch to test account user.
-			realCloudClient, connectionErr = realCloudClient.NewClientInDomainAndAccount(
+			realCloudClient, connectionErr = realCloudClient.NewClientInDomainAndAccount(ctx,
 				newAccount.Domain.Name, newAccount.Name)
 			Ω(connectionErr).ShouldNot(HaveOccurred())
 		}
@@ -88,10 +93,36 @@ func TestCloud(t *testing.T) {
 
 // FetchIntegTestResources runs through basic CloudStack Client setup methods needed to test others.
 func FetchIntegTestResources() {
-	Ω(realCloudClient.ResolveZone(&dummies.CSFailureDomain1.Spec.Zone)).Should(Succeed())
+	Ω(realCloudClient.ResolveZone(ctx, &dummies.CSFailureDomain1.Spec.Zone)).Should(Succeed())
 	Ω(dummies.CSFailureDomain1.Spec.Zone.ID).ShouldNot(BeEmpty())
 	dummies.CSMachine1.Spec.DiskOffering.Name = ""
 	dummies.CSCluster.Spec.ControlPlaneEndpoint.Host = ""
 	Ω(realCloudClient.GetOrCreateIsolatedNetwork(
+		ctx,
 		dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).Should(Succeed())
 }
+
+// UseSimulator makes sim a CloudStack API simulator seeded with the dummy resources and client a client of it, anew for
+// each spec of the container it's called in. The simulator is closed once the spec is done.
+func UseSimulator() {
+	BeforeEach(func() {
+		dummies.SetDummyVars()
+		sim = simulator.New()
+		DeferCleanup(sim.Close)
+		dummies.SetDummySimulatorVars(sim)
+
+		var err error
+		client, err = cloud.NewClientFromConf(dummies.SimulatorConf, SimulatorClientConfig(nil))
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+}
+
+// SimulatorClientConfig returns a client config map with the passed data for clients of the simulator. The client
+// cache takes its TTL from the config map of the first client made, so it's set to the one the client tests expect.
+func SimulatorClientConfig(data map[string]string) *corev1.ConfigMap {
+	clientConfig := &corev1.ConfigMap{Data: map[string]string{cloud.ClientCacheTTLKey: "100ms"}}
+	for key, value := range data {
+		clientConfig.Data[key] = value
+	}
+	return clientConfig
+}
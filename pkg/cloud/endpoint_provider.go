@@ -0,0 +1,218 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
+)
+
+type EndpointProviderIface interface {
+	ControlPlaneEndpointProvider(
+		*infrav1.CloudStackCluster, *infrav1.CloudStackFailureDomain, *infrav1.CloudStackIsolatedNetwork,
+	) (ControlPlaneEndpointProvider, error)
+}
+
+// ControlPlaneEndpointProvider fronts a cluster's control plane machines in a failure domain with the cluster's
+// control plane endpoint.
+type ControlPlaneEndpointProvider interface {
+	// GetOrCreateControlPlaneEndpoint sets up the endpoint and records it on the CloudStackCluster.
+	GetOrCreateControlPlaneEndpoint(context.Context) error
+	// AssignVMToControlPlaneEndpoint puts a control plane VM behind the endpoint.
+	AssignVMToControlPlaneEndpoint(ctx context.Context, instanceID string) error
+	// DisposeControlPlaneEndpoint releases what was set up for the endpoint once the cluster no longer uses it.
+	DisposeControlPlaneEndpoint(context.Context) error
+}
+
+// ControlPlaneEndpointProvider returns the control plane endpoint provider the CloudStackCluster selects for a
+// failure domain. The isolated network is only used by the IsolatedNetwork provider and may be nil otherwise.
+func (c *client) ControlPlaneEndpointProvider(
+	csCluster *infrav1.CloudStackCluster,
+	fd *infrav1.CloudStackFailureDomain,
+	isoNet *infrav1.CloudStackIsolatedNetwork,
+) (ControlPlaneEndpointProvider, error) {
+	switch provider := csCluster.ControlPlaneEndpointProviderFor(&fd.Spec); provider {
+	case infrav1.EndpointProviderIsolatedNetwork:
+		if netType := fd.Spec.Zone.Network.Type; netType != "" && netType != NetworkTypeIsolated && netType != NetworkTypeVPC {
+			return nil, errors.Errorf("control plane endpoint provider %s requires an isolated network, but network %s is %s",
+				provider, fd.Spec.Zone.Network.Name, fd.Spec.Zone.Network.Type)
+		} else if isoNet == nil {
+			return nil, errors.Errorf("control plane endpoint provider %s requires an isolated network", provider)
+		}
+		return &isolatedNetworkEndpoint{c: c, fd: fd, isoNet: isoNet, csCluster: csCluster}, nil
+	case infrav1.EndpointProviderNetworkLoadBalancer:
+		return &networkLoadBalancerEndpoint{c: c, fd: fd, csCluster: csCluster}, nil
+	case infrav1.EndpointProviderExternal:
+		return externalEndpoint{}, nil
+	default:
+		return nil, errors.Errorf("unknown control plane endpoint provider %s", provider)
+	}
+}
+
+// isolatedNetworkEndpoint load balances the control plane behind a public IP of a CAPC managed isolated network.
+type isolatedNetworkEndpoint struct {
+	c         *client
+	fd        *infrav1.CloudStackFailureDomain
+	isoNet    *infrav1.CloudStackIsolatedNetwork
+	csCluster *infrav1.CloudStackCluster
+}
+
+func (e *isolatedNetworkEndpoint) GetOrCreateControlPlaneEndpoint(ctx context.Context) error {
+	c, ctx, cancel := e.c.withTimeout(ctx, OperationNetwork)
+	defer cancel()
+
+	// Associate Public IP with CloudStackIsolatedNetwork
+	if err := c.AssociatePublicIPAddress(ctx, e.fd, e.isoNet, e.csCluster); err != nil {
+		return errors.Wrapf(err, "associating public IP address to csCluster")
+	}
+
+	// Setup a load balancing rule to map VMs to Public IP.
+	return errors.Wrap(c.GetOrCreateLoadBalancerRule(ctx, e.fd, e.isoNet, e.csCluster),
+		"getting or creating load balancing rule")
+}
+
+func (e *isolatedNetworkEndpoint) AssignVMToControlPlaneEndpoint(ctx context.Context, instanceID string) error {
+	c, ctx, cancel := e.c.withTimeout(ctx, OperationNetwork)
+	defer cancel()
+
+	if err := c.AssignVMToLoadBalancerRule(ctx, e.isoNet, instanceID); err != nil {
+		return err
+	}
+	return c.assignVMToAdditionalPortRules(e.isoNet, e.csCluster, instanceID)
+}
+
+func (e *isolatedNetworkEndpoint) DisposeControlPlaneEndpoint(ctx context.Context) error {
+	c, ctx, cancel := e.c.withTimeout(ctx, OperationNetwork)
+	defer cancel()
+
+	if e.isoNet.Status.PublicIPID == "" {
+		return nil
+	}
+	if err := c.DeleteClusterTag(ctx, ResourceTypeIPAddress, e.isoNet.Status.PublicIPID, e.csCluster); err != nil {
+		return err
+	}
+	return c.DisassociatePublicIPAddressIfNotInUse(ctx, e.isoNet)
+}
+
+// networkLoadBalancerEndpoint load balances the control plane behind a public IP associated with the failure domain's
+// network, which must be on an offering that provides load balancing. The public IP is looked up by the endpoint host and
+// the load balancer rule by the endpoint port. The only state it keeps is the job associating or disassociating the
+// public IP or creating its rules, recorded in the failure domain's status.
+type networkLoadBalancerEndpoint struct {
+	c         *client
+	fd        *infrav1.CloudStackFailureDomain
+	csCluster *infrav1.CloudStackCluster
+}
+
+// lbNetwork describes the failure domain's network in the form the load balancing methods work with.
+func (e *networkLoadBalancerEndpoint) lbNetwork() *infrav1.CloudStackIsolatedNetwork {
+	net := &infrav1.CloudStackIsolatedNetwork{}
+	net.Spec.Name = e.fd.Spec.Zone.Network.Name
+	net.Spec.ID = e.fd.Spec.Zone.Network.ID
+	net.Spec.ControlPlaneEndpoint = e.csCluster.Spec.ControlPlaneEndpoint
+	net.Status.AsyncJob = e.fd.Status.AsyncJob
+	return net
+}
+
+// resolve fills in the public IP and load balancer rule of an endpoint that was already set up.
+func (e *networkLoadBalancerEndpoint) resolve(ctx context.Context, c *client, net *infrav1.CloudStackIsolatedNetwork) error {
+	if e.csCluster.Spec.ControlPlaneEndpoint.Host == "" {
+		return errors.New("control plane endpoint host not yet set")
+	}
+	publicAddress, err := c.GetPublicIP(ctx, e.fd, net, e.csCluster)
+	if err != nil {
+		return errors.Wrap(err, "fetching the control plane endpoint's public IP address")
+	}
+	net.Status.PublicIPID = publicAddress.Id
+	return c.ResolveLoadBalancerRuleDetails(ctx, e.fd, net, e.csCluster)
+}
+
+func (e *networkLoadBalancerEndpoint) GetOrCreateControlPlaneEndpoint(ctx context.Context) error {
+	c, ctx, cancel := e.c.withTimeout(ctx, OperationNetwork)
+	defer cancel()
+
+	net := e.lbNetwork()
+	defer func() { e.fd.Status.AsyncJob = net.Status.AsyncJob }()
+	if err := c.pollNetworkJob(net); err != nil {
+		return err
+	}
+	if job := net.Status.AsyncJob; job != nil && job.Command == CommandAssociateIPAddress {
+		// The address being associated is tagged once the job is done.
+		publicAddress, err := c.GetPublicIP(ctx, e.fd, net, e.csCluster)
+		if err != nil {
+			return errors.Wrap(err, "fetching the control plane endpoint's public IP address")
+		}
+		net.Status.PublicIPID = publicAddress.Id
+	}
+	if err := c.AssociatePublicIPAddress(ctx, e.fd, net, e.csCluster); err != nil {
+		return errors.Wrapf(err, "associating public IP address to network %s", net.Spec.Name)
+	}
+	return errors.Wrap(c.GetOrCreateLoadBalancerRule(ctx, e.fd, net, e.csCluster),
+		"getting or creating load balancing rule")
+}
+
+func (e *networkLoadBalancerEndpoint) AssignVMToControlPlaneEndpoint(ctx context.Context, instanceID string) error {
+	c, ctx, cancel := e.c.withTimeout(ctx, OperationNetwork)
+	defer cancel()
+
+	net := e.lbNetwork()
+	if err := e.resolve(ctx, c, net); err != nil {
+		return err
+	}
+	if err := c.AssignVMToLoadBalancerRule(ctx, net, instanceID); err != nil {
+		return err
+	}
+	return c.assignVMToAdditionalPortRules(net, e.csCluster, instanceID)
+}
+
+func (e *networkLoadBalancerEndpoint) DisposeControlPlaneEndpoint(ctx context.Context) error {
+	c, ctx, cancel := e.c.withTimeout(ctx, OperationNetwork)
+	defer cancel()
+
+	if e.csCluster.Spec.ControlPlaneEndpoint.Host == "" {
+		return nil
+	}
+	net := e.lbNetwork()
+	defer func() { e.fd.Status.AsyncJob = net.Status.AsyncJob }()
+	if released, err := c.pollDisassociation(net); err != nil || released {
+		return err
+	}
+	publicAddress, err := c.GetPublicIP(ctx, e.fd, net, e.csCluster)
+	if err != nil {
+		return errors.Wrap(err, "fetching the control plane endpoint's public IP address")
+	} else if publicAddress.Associatednetworkid == "" ||
+		(net.Spec.ID != "" && publicAddress.Associatednetworkid != net.Spec.ID) { // Already released.
+		return nil
+	}
+	net.Status.PublicIPID = publicAddress.Id
+	if err := c.DeleteClusterTag(ctx, ResourceTypeIPAddress, net.Status.PublicIPID, e.csCluster); err != nil {
+		return err
+	}
+	// Releasing the address also removes its load balancer rules.
+	return c.DisassociatePublicIPAddressIfNotInUse(ctx, net)
+}
+
+// externalEndpoint leaves the control plane endpoint to something outside of CAPC.
+type externalEndpoint struct{}
+
+func (externalEndpoint) GetOrCreateControlPlaneEndpoint(context.Context) error { return nil }
+
+func (externalEndpoint) AssignVMToControlPlaneEndpoint(context.Context, string) error { return nil }
+
+func (externalEndpoint) DisposeControlPlaneEndpoint(context.Context) error { return nil }
@@ -0,0 +1,197 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_test
+
+import (
+	csapi "github.com/apache/cloudstack-go/v2/cloudstack"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
+	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta2"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/test/simulator"
+)
+
+var _ = Describe("Control plane endpoint providers", func() {
+	var (
+		mockCtrl   *gomock.Controller
+		mockClient *csapi.CloudStackClient
+		lbs        *csapi.MockLoadBalancerServiceIface
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockClient = csapi.NewMockClient(mockCtrl)
+		lbs = mockClient.LoadBalancer.(*csapi.MockLoadBalancerServiceIface)
+		client = cloud.NewClientFromCSAPIClient(mockClient)
+		dummies.SetDummyVars()
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	It("defaults to load balancing on the isolated network's public IP", func() {
+		dummies.CSFailureDomain1.Spec.Zone.Network.Type = cloud.NetworkTypeIsolated
+		dummies.CSISONet1.Status.LBRuleID = "lbruleid"
+		lbip := &csapi.ListLoadBalancerRuleInstancesParams{}
+		albp := &csapi.AssignToLoadBalancerRuleParams{}
+		lbs.EXPECT().NewListLoadBalancerRuleInstancesParams(dummies.CSISONet1.Status.LBRuleID).Return(lbip)
+		lbs.EXPECT().ListLoadBalancerRuleInstances(lbip).Return(&csapi.ListLoadBalancerRuleInstancesResponse{}, nil)
+		lbs.EXPECT().NewAssignToLoadBalancerRuleParams(dummies.CSISONet1.Status.LBRuleID).Return(albp)
+		lbs.EXPECT().AssignToLoadBalancerRule(albp).Return(&csapi.AssignToLoadBalancerRuleResponse{}, nil)
+
+		provider, err := client.ControlPlaneEndpointProvider(dummies.CSCluster, dummies.CSFailureDomain1, dummies.CSISONet1)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(provider.AssignVMToControlPlaneEndpoint(ctx, *dummies.CSMachine1.Spec.InstanceID)).Should(Succeed())
+	})
+
+	It("defaults to an external endpoint on shared networks", func() {
+		dummies.CSFailureDomain1.Spec.Zone.Network.Type = cloud.NetworkTypeShared
+
+		provider, err := client.ControlPlaneEndpointProvider(dummies.CSCluster, dummies.CSFailureDomain1, nil)
+		Ω(err).ShouldNot(HaveOccurred())
+		// The external provider makes no CloudStack calls, so the mock client would fail on any.
+		Ω(provider.GetOrCreateControlPlaneEndpoint(ctx)).Should(Succeed())
+		Ω(provider.AssignVMToControlPlaneEndpoint(ctx, *dummies.CSMachine1.Spec.InstanceID)).Should(Succeed())
+		Ω(provider.DisposeControlPlaneEndpoint(ctx)).Should(Succeed())
+	})
+
+	It("requires an endpoint host before assigning VMs to a network load balancer", func() {
+		dummies.CSCluster.Spec.ControlPlaneEndpointProvider = infrav1.EndpointProviderNetworkLoadBalancer
+		dummies.CSCluster.Spec.ControlPlaneEndpoint.Host = ""
+
+		provider, err := client.ControlPlaneEndpointProvider(dummies.CSCluster, dummies.CSFailureDomain1, nil)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(provider.AssignVMToControlPlaneEndpoint(ctx, *dummies.CSMachine1.Spec.InstanceID)).
+			Should(MatchError(ContainSubstring("host not yet set")))
+	})
+
+	It("rejects unknown providers", func() {
+		dummies.CSCluster.Spec.ControlPlaneEndpointProvider = "Magic"
+
+		_, err := client.ControlPlaneEndpointProvider(dummies.CSCluster, dummies.CSFailureDomain1, nil)
+		Ω(err).Should(MatchError(ContainSubstring("unknown control plane endpoint provider Magic")))
+	})
+
+	Context("with the simulator", func() {
+		UseSimulator()
+
+		It("load balances control plane VMs on a network with a load balancing offering", func() {
+			lbNet := sim.AddNetworkWithOffering(
+				dummies.Zone1.ID, "elb-network", simulator.SharedLBNetworkOffering, "10.30.0.0/24")
+			dummies.CSFailureDomain1.Spec.Zone.Network = infrav1.Network{
+				ID: lbNet.Id, Name: lbNet.Name, Type: cloud.NetworkTypeShared}
+			dummies.CSCluster.Spec.ControlPlaneEndpointProvider = infrav1.EndpointProviderNetworkLoadBalancer
+			dummies.CSCluster.Spec.ControlPlaneEndpoint.Host = ""
+
+			provider, err := client.ControlPlaneEndpointProvider(dummies.CSCluster, dummies.CSFailureDomain1, nil)
+			Ω(err).ShouldNot(HaveOccurred())
+			for i := 0; i < 2; i++ {
+				Ω(provider.GetOrCreateControlPlaneEndpoint(ctx)).Should(Succeed())
+			}
+			Ω(dummies.CSCluster.Spec.ControlPlaneEndpoint.Host).Should(BeElementOf(dummies.SimulatorPublicIPs))
+			Ω(sim.LoadBalancerRules()).Should(HaveLen(1))
+			Ω(sim.LoadBalancerRules()[0].Networkid).Should(Equal(lbNet.Id))
+
+			Ω(client.GetOrCreateVMInstance(ctx, dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster,
+				dummies.CSFailureDomain1, dummies.CSAffinityGroup, "")).Should(Succeed())
+			for i := 0; i < 2; i++ {
+				Ω(provider.AssignVMToControlPlaneEndpoint(ctx, *dummies.CSMachine1.Spec.InstanceID)).Should(Succeed())
+			}
+			Ω(sim.LoadBalancerRuleMembers(sim.LoadBalancerRules()[0].Id)).
+				Should(ConsistOf(*dummies.CSMachine1.Spec.InstanceID))
+
+			Ω(provider.DisposeControlPlaneEndpoint(ctx)).Should(Succeed())
+			Ω(sim.LoadBalancerRules()).Should(BeEmpty())
+			Ω(provider.DisposeControlPlaneEndpoint(ctx)).Should(Succeed())
+		})
+
+		It("records the job associating the load balancer's public IP in the failure domain status", func() {
+			lbNet := sim.AddNetworkWithOffering(
+				dummies.Zone1.ID, "elb-network", simulator.SharedLBNetworkOffering, "10.30.0.0/24")
+			dummies.CSFailureDomain1.Spec.Zone.Network = infrav1.Network{
+				ID: lbNet.Id, Name: lbNet.Name, Type: cloud.NetworkTypeShared}
+			dummies.CSCluster.Spec.ControlPlaneEndpointProvider = infrav1.EndpointProviderNetworkLoadBalancer
+			dummies.CSCluster.Spec.ControlPlaneEndpoint.Host = ""
+			sim.HoldJobs("associateIpAddress")
+
+			provider, err := client.ControlPlaneEndpointProvider(dummies.CSCluster, dummies.CSFailureDomain1, nil)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(cloud.IsJobPending(provider.GetOrCreateControlPlaneEndpoint(ctx))).Should(BeTrue())
+			Ω(dummies.CSFailureDomain1.Status.AsyncJob).ShouldNot(BeNil())
+			Ω(dummies.CSFailureDomain1.Status.AsyncJob.Command).Should(Equal(cloud.CommandAssociateIPAddress))
+			Ω(dummies.CSCluster.Spec.ControlPlaneEndpoint.Host).Should(BeElementOf(dummies.SimulatorPublicIPs))
+			Ω(sim.LoadBalancerRules()).Should(BeEmpty())
+
+			sim.ReleaseJobs("associateIpAddress")
+			Ω(provider.GetOrCreateControlPlaneEndpoint(ctx)).Should(Succeed())
+			Ω(dummies.CSFailureDomain1.Status.AsyncJob).Should(BeNil())
+			Ω(sim.LoadBalancerRules()).Should(HaveLen(1))
+		})
+
+		It("releases the load balancer's public IP of a failure domain whose network has no ID", func() {
+			lbNet := sim.AddNetworkWithOffering(
+				dummies.Zone1.ID, "elb-network", simulator.SharedLBNetworkOffering, "10.30.0.0/24")
+			dummies.CSFailureDomain1.Spec.Zone.Network = infrav1.Network{
+				ID: lbNet.Id, Name: lbNet.Name, Type: cloud.NetworkTypeShared}
+			dummies.CSCluster.Spec.ControlPlaneEndpointProvider = infrav1.EndpointProviderNetworkLoadBalancer
+			dummies.CSCluster.Spec.ControlPlaneEndpoint.Host = ""
+
+			provider, err := client.ControlPlaneEndpointProvider(dummies.CSCluster, dummies.CSFailureDomain1, nil)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(provider.GetOrCreateControlPlaneEndpoint(ctx)).Should(Succeed())
+			Ω(sim.LoadBalancerRules()).Should(HaveLen(1))
+
+			dummies.CSFailureDomain1.Spec.Zone.Network.ID = ""
+			Ω(provider.DisposeControlPlaneEndpoint(ctx)).Should(Succeed())
+			Ω(sim.LoadBalancerRules()).Should(BeEmpty())
+		})
+
+		It("fails to set up a network load balancer on a network without load balancing", func() {
+			dummies.CSCluster.Spec.ControlPlaneEndpointProvider = infrav1.EndpointProviderNetworkLoadBalancer
+			dummies.CSCluster.Spec.ControlPlaneEndpoint.Host = ""
+
+			provider, err := client.ControlPlaneEndpointProvider(dummies.CSCluster, dummies.CSFailureDomain1, nil)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(provider.GetOrCreateControlPlaneEndpoint(ctx)).Should(MatchError(ContainSubstring("doesn't support public IP")))
+		})
+
+		It("rejects the isolated network provider on a shared network", func() {
+			dummies.CSFailureDomain1.Spec.Zone.Network.Type = cloud.NetworkTypeShared
+			dummies.CSCluster.Spec.ControlPlaneEndpointProvider = infrav1.EndpointProviderIsolatedNetwork
+
+			_, err := client.ControlPlaneEndpointProvider(dummies.CSCluster, dummies.CSFailureDomain1, dummies.CSISONet1)
+			Ω(err).Should(MatchError(ContainSubstring("requires an isolated network")))
+		})
+
+		It("leaves an isolated network's public IP alone with an external endpoint", func() {
+			dummies.SetDummyIsoNetToNameOnly()
+			dummies.CSFailureDomain1.Spec.Zone = dummies.Zone1
+			dummies.CSCluster.Spec.ControlPlaneEndpointProvider = infrav1.EndpointProviderExternal
+			sim.AddNetwork(dummies.Zone1.ID, "other-network", simulator.NetworkTypeShared, "10.20.0.0/24")
+
+			Ω(client.GetOrCreateIsolatedNetwork(
+				ctx,
+				dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).Should(Succeed())
+			Ω(dummies.CSISONet1.Spec.ID).ShouldNot(BeEmpty())
+			Ω(sim.LoadBalancerRules()).Should(BeEmpty())
+			Ω(sim.EgressFirewallRules()).Should(HaveLen(1))
+		})
+	})
+})
@@ -0,0 +1,158 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"regexp"
+
+	"github.com/pkg/errors"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/metrics"
+)
+
+// ErrorClass tells whether retrying a failed CloudStack request may succeed.
+type ErrorClass string
+
+const (
+	// ErrorClassTransient errors may go away by themselves, as when CloudStack is unreachable, busy or fails
+	// internally. Requests failing with them are retried with backoff.
+	ErrorClassTransient ErrorClass = "Transient"
+
+	// ErrorClassNotFound errors are CloudStack finding no resource of the name or ID asked for. Whether that's a
+	// problem is up to the caller.
+	ErrorClassNotFound ErrorClass = "NotFound"
+
+	// ErrorClassCapacity errors are CloudStack finding no capacity for a request, as when no host in a zone has room
+	// for a VM.
+	ErrorClassCapacity ErrorClass = "Capacity"
+
+	// ErrorClassTerminal errors won't go away by retrying the same request, as with invalid parameters or exceeded
+	// account limits.
+	ErrorClassTerminal ErrorClass = "Terminal"
+)
+
+// The error codes of the CloudStack API that tell the request itself is at fault.
+const (
+	errorCodeMalformedParameter   = "430"
+	errorCodeParamError           = "431"
+	errorCodeUnsupportedAction    = "432"
+	errorCodeAccountError         = "531"
+	errorCodeAccountResourceLimit = "532"
+	errorCodeResourceAllocation   = "535"
+)
+
+var (
+	// capacityErrorRegexp matches the error code CloudStack fails requests it finds no capacity for with, such as
+	// deployments failing with an InsufficientServerCapacityException. Synchronous errors are formatted as
+	// "CloudStack API error 533 (CSExceptionErrorCode: ...)", while failed async jobs carry their JSON job result.
+	capacityErrorRegexp = regexp.MustCompile(`CloudStack API error 533\b|"errorcode"\s*:\s*533\b|Insufficient\w*CapacityException`)
+
+	// noMatchRegexp matches the errors of the CloudStack client's Get...ByName and Get...ByID methods finding no
+	// resource, as in "No match found for zone1: &{Count:0 Zones:[]}".
+	noMatchRegexp = regexp.MustCompile(`(?i)no match found`)
+
+	// resourceLimitRegexp matches the ResourceAllocationExceptions CloudStack throws for requests that would take an
+	// account over its resource limits, rather than for failing to allocate resources.
+	resourceLimitRegexp = regexp.MustCompile(`Maximum number of resources of type .* exceeded`)
+)
+
+// TerminalError is an error retrying won't recover a machine from. Reason is the CAPI failure reason it maps to.
+type TerminalError struct {
+	Reason capierrors.MachineStatusError
+	Err    error
+}
+
+func (e *TerminalError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TerminalError) Unwrap() error {
+	return e.Err
+}
+
+// NewTerminalError marks err as terminal, for the passed CAPI failure reason.
+func NewTerminalError(reason capierrors.MachineStatusError, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &TerminalError{Reason: reason, Err: err}
+}
+
+// ClassifyError returns the class of an error returned by CloudStack, going by its API error code where it has one.
+// Errors already marked terminal keep that class.
+func ClassifyError(err error) ErrorClass {
+	if err == nil {
+		return ""
+	}
+	terminal := &TerminalError{}
+	if errors.As(err, &terminal) {
+		return ErrorClassTerminal
+	} else if capacityErrorRegexp.MatchString(err.Error()) {
+		return ErrorClassCapacity
+	} else if noMatchRegexp.MatchString(err.Error()) {
+		return ErrorClassNotFound
+	}
+	switch code, _ := metrics.ErrorCodes(err); code {
+	case errorCodeMalformedParameter, errorCodeParamError, errorCodeUnsupportedAction,
+		errorCodeAccountError, errorCodeAccountResourceLimit:
+		return ErrorClassTerminal
+	case errorCodeResourceAllocation:
+		if resourceLimitRegexp.MatchString(err.Error()) {
+			return ErrorClassTerminal
+		}
+	}
+	return ErrorClassTransient
+}
+
+// AsTerminalError returns err as a TerminalError if it's terminal, and nil otherwise. Errors CloudStack didn't mark
+// terminal themselves are taken as machines asking for more than their account may have, or as invalid
+// configuration.
+func AsTerminalError(err error) *TerminalError {
+	if ClassifyError(err) != ErrorClassTerminal {
+		return nil
+	}
+	terminal := &TerminalError{}
+	if errors.As(err, &terminal) {
+		return terminal
+	}
+	code, _ := metrics.ErrorCodes(err)
+	if code == errorCodeAccountResourceLimit || code == errorCodeResourceAllocation {
+		return &TerminalError{Reason: capierrors.InsufficientResourcesMachineError, Err: err}
+	}
+	return &TerminalError{Reason: capierrors.InvalidConfigurationMachineError, Err: err}
+}
+
+// invalidConfigurationIfNotFound marks CloudStack finding no resource of the name or ID asked for as a terminal
+// invalid configuration.
+func invalidConfigurationIfNotFound(err error) error {
+	if IsNotFoundError(err) {
+		return NewTerminalError(capierrors.InvalidConfigurationMachineError, err)
+	}
+	return err
+}
+
+// IsCapacityError reports whether err is CloudStack failing a request for lack of capacity, as when no host in a zone
+// has room for a VM.
+func IsCapacityError(err error) bool {
+	return ClassifyError(err) == ErrorClassCapacity
+}
+
+// IsNotFoundError reports whether err is CloudStack finding no resource of the name or ID asked for.
+func IsNotFoundError(err error) bool {
+	return ClassifyError(err) == ErrorClassNotFound
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_test
+
+import (
+	"errors"
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+)
+
+var _ = Describe("Errors", func() {
+	DescribeTable("classifies capacity errors",
+		func(msg string, capacity bool) {
+			Ω(cloud.IsCapacityError(errors.New(msg))).Should(Equal(capacity))
+		},
+		Entry("from a failed async job", `{"cserrorcode":4250,"errorcode":533,"errortext":"Unable to create a deployment for VM[User|i-2-10-VM]"}`, true),
+		Entry("from a synchronous request", "CloudStack API error 533 (CSExceptionErrorCode: 4250): Insufficient address capacity", true),
+		Entry("by exception name", "com.cloud.exception.InsufficientServerCapacityException: Unable to create a deployment", true),
+		Entry("unrelated to capacity", "CloudStack API error 431 (CSExceptionErrorCode: 4350): Unable to find uuid 533", false),
+	)
+
+	It("doesn't classify a nil error", func() {
+		Ω(cloud.IsCapacityError(nil)).Should(BeFalse())
+		Ω(cloud.IsNotFoundError(nil)).Should(BeFalse())
+		Ω(cloud.AsTerminalError(nil)).Should(BeNil())
+	})
+
+	DescribeTable("classifies errors by whether retrying may succeed",
+		func(msg string, class cloud.ErrorClass) {
+			Ω(cloud.ClassifyError(errors.New(msg))).Should(Equal(class))
+		},
+		Entry("CloudStack finding nothing by name", "No match found for zone1: &{Count:0 Zones:[]}", cloud.ErrorClassNotFound),
+		Entry("an invalid parameter", "CloudStack API error 431 (CSExceptionErrorCode: 4350): Unable to find uuid 533",
+			cloud.ErrorClassTerminal),
+		Entry("an exceeded account limit", "CloudStack API error 535 (CSExceptionErrorCode: 4370): Maximum number of "+
+			"resources of type 'user_vm' for account name=admin in domain id=1 has been exceeded.", cloud.ErrorClassTerminal),
+		Entry("an account limit from a failed async job", `{"cserrorcode":4370,"errorcode":532,"errortext":"limit"}`,
+			cloud.ErrorClassTerminal),
+		Entry("a failed resource allocation", "CloudStack API error 535 (CSExceptionErrorCode: 4370): Unable to "+
+			"allocate a public IP address", cloud.ErrorClassTransient),
+		Entry("an internal error", "CloudStack API error 530 (CSExceptionErrorCode: 4250): Internal error",
+			cloud.ErrorClassTransient),
+		Entry("unauthorized credentials", "CloudStack API error 401 (CSExceptionErrorCode: 0): unable to verify user "+
+			"credentials and/or request signature", cloud.ErrorClassTransient),
+		Entry("an exceeded API limit", "CloudStack API error 429 (CSExceptionErrorCode: 4250): The given user has "+
+			"reached his/her account api limit, please retry after 1000 ms.", cloud.ErrorClassTransient),
+		Entry("an unreachable endpoint", "Get \"https://cloudstack/client/api\": dial tcp: connection refused",
+			cloud.ErrorClassTransient),
+	)
+
+	It("maps terminal errors to CAPI failure reasons", func() {
+		limit := cloud.AsTerminalError(errors.New(`{"cserrorcode":4370,"errorcode":532,"errortext":"limit"}`))
+		Ω(limit).ShouldNot(BeNil())
+		Ω(limit.Reason).Should(Equal(capierrors.InsufficientResourcesMachineError))
+
+		invalid := cloud.AsTerminalError(errors.New("CloudStack API error 431 (CSExceptionErrorCode: 4350): bad"))
+		Ω(invalid).ShouldNot(BeNil())
+		Ω(invalid.Reason).Should(Equal(capierrors.InvalidConfigurationMachineError))
+
+		marked := fmt.Errorf("deploying: %w", cloud.NewTerminalError(
+			capierrors.UnsupportedChangeMachineError, errors.New("No match found for tmpl")))
+		Ω(cloud.ClassifyError(marked)).Should(Equal(cloud.ErrorClassTerminal))
+		Ω(cloud.AsTerminalError(marked).Reason).Should(Equal(capierrors.UnsupportedChangeMachineError))
+
+		Ω(cloud.AsTerminalError(errors.New("No match found for tmpl"))).Should(BeNil())
+	})
+})
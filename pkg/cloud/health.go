@@ -0,0 +1,122 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/pkg/errors"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
+)
+
+// HealthIface probes whether failure domains can take new machines.
+type HealthIface interface {
+	ProbeFailureDomain(context.Context, *infrav1.CloudStackFailureDomainSpec) error
+}
+
+// FailureDomainProblem is what keeps a failure domain from taking new machines, as found by a probe.
+type FailureDomainProblem struct {
+	// Reason is one of the reasons of the FailureDomainHealthyCondition.
+	Reason   string
+	Severity clusterv1.ConditionSeverity
+	Message  string
+}
+
+func (p *FailureDomainProblem) Error() string {
+	return fmt.Sprintf("%s: %s", p.Reason, p.Message)
+}
+
+// Network states CloudStack doesn't deploy VMs on.
+var unavailableNetworkStates = map[string]bool{"Shutdown": true, "Destroy": true}
+
+// ProbeFailureDomain checks that a failure domain can take new machines: its zone is enabled for allocation, its
+// network is up, and its account has room for another VM within its resource limits. The account is only checked if
+// the failure domain names one. It returns a *FailureDomainProblem for the first check that fails, or the error of
+// CloudStack not being reachable with the client's credentials.
+func (c *client) ProbeFailureDomain(ctx context.Context, fdSpec *infrav1.CloudStackFailureDomainSpec) error {
+	c, ctx, cancel := c.withTimeout(ctx, OperationResolve)
+	defer cancel()
+
+	zone := fdSpec.Zone
+	zp := c.cs.Zone.NewListZonesParams()
+	zp.SetId(zone.ID)
+	zones, err := c.cs.Zone.ListZones(zp)
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return errors.Wrapf(err, "listing zone %s", zone.Name)
+	} else if zones.Count != 1 {
+		return &FailureDomainProblem{Reason: infrav1.ZoneDisabledReason, Severity: clusterv1.ConditionSeverityError,
+			Message: fmt.Sprintf("zone %s with ID %s not found", zone.Name, zone.ID)}
+	} else if state := zones.Zones[0].Allocationstate; state != "Enabled" {
+		return &FailureDomainProblem{Reason: infrav1.ZoneDisabledReason, Severity: clusterv1.ConditionSeverityError,
+			Message: fmt.Sprintf("zone %s has allocation state %s", zone.Name, state)}
+	}
+
+	if zone.Network.ID != "" {
+		np := c.cs.Network.NewListNetworksParams()
+		np.SetId(zone.Network.ID)
+		networks, err := c.cs.Network.ListNetworks(np)
+		if err != nil {
+			c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+			return errors.Wrapf(err, "listing network %s", zone.Network.Name)
+		} else if networks.Count != 1 {
+			return &FailureDomainProblem{Reason: infrav1.NetworkUnavailableReason, Severity: clusterv1.ConditionSeverityError,
+				Message: fmt.Sprintf("network %s with ID %s not found", zone.Network.Name, zone.Network.ID)}
+		} else if state := networks.Networks[0].State; unavailableNetworkStates[state] {
+			return &FailureDomainProblem{Reason: infrav1.NetworkUnavailableReason, Severity: clusterv1.ConditionSeverityError,
+				Message: fmt.Sprintf("network %s is in state %s", zone.Network.Name, state)}
+		}
+	}
+
+	if fdSpec.Account == "" {
+		return nil
+	}
+	// Accounts of the same name may be in other domains.
+	domain := &Domain{Path: fdSpec.Domain}
+	if err := c.ResolveDomain(ctx, domain); err != nil {
+		return errors.Wrapf(err, "resolving domain %s", fdSpec.Domain)
+	}
+	ap := c.cs.Account.NewListAccountsParams()
+	ap.SetName(fdSpec.Account)
+	ap.SetDomainid(domain.ID)
+	accounts, err := c.cs.Account.ListAccounts(ap)
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return errors.Wrapf(err, "listing account %s", fdSpec.Account)
+	} else if accounts.Count != 1 {
+		return errors.Errorf("expected 1 account with name %s in domain %s, but got %d",
+			fdSpec.Account, domain.Path, accounts.Count)
+	}
+	account := accounts.Accounts[0]
+	for _, limit := range []struct{ resource, available string }{
+		{"VMs", account.Vmavailable},
+		{"CPUs", account.Cpuavailable},
+		{"memory", account.Memoryavailable},
+	} {
+		// Unlimited resources are reported as "Unlimited".
+		if available, err := strconv.ParseInt(limit.available, 10, 64); err == nil && available <= 0 {
+			return &FailureDomainProblem{Reason: infrav1.ResourceLimitReachedReason,
+				Severity: clusterv1.ConditionSeverityWarning,
+				Message:  fmt.Sprintf("account %s has no %s left within its resource limits", account.Name, limit.resource)}
+		}
+	}
+	return nil
+}
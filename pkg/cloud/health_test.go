@@ -0,0 +1,63 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
+	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta2"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/test/simulator"
+)
+
+var _ = Describe("Failure domain health", func() {
+	UseSimulator()
+
+	It("probes whether a failure domain can take new machines", func() {
+		fdSpec := dummies.CSFailureDomain1.Spec
+		Ω(client.ProbeFailureDomain(ctx, &fdSpec)).Should(Succeed())
+
+		account := sim.AddAccount(sim.RootDomain().Id, "limited")
+		fdSpec.Domain, fdSpec.Account = "ROOT", account.Name
+		Ω(client.ProbeFailureDomain(ctx, &fdSpec)).Should(Succeed())
+		// The account of the same name in another domain is left alone.
+		subDomain := sim.AddDomain(sim.RootDomain().Id, "sub-domain")
+		sim.SetAccountVMsAvailable(sim.AddAccount(subDomain.Id, account.Name).Id, 0)
+		Ω(client.ProbeFailureDomain(ctx, &fdSpec)).Should(Succeed())
+		sim.SetAccountVMsAvailable(account.Id, 0)
+		problem := &cloud.FailureDomainProblem{}
+		Ω(errors.As(client.ProbeFailureDomain(ctx, &fdSpec), &problem)).Should(BeTrue())
+		Ω(problem.Reason).Should(Equal(infrav1.ResourceLimitReachedReason))
+
+		sim.SetZoneAllocationState(dummies.Zone1.ID, "Disabled")
+		Ω(errors.As(client.ProbeFailureDomain(ctx, &fdSpec), &problem)).Should(BeTrue())
+		Ω(problem.Reason).Should(Equal(infrav1.ZoneDisabledReason))
+	})
+
+	It("reports an unreachable management server apart from the failure domain's own problems", func() {
+		fdSpec := dummies.CSFailureDomain1.Spec
+		sim.FailNext("listZones", simulator.NewAPIError(simulator.ErrorCodeInternalError, "Management server down"))
+
+		err := client.ProbeFailureDomain(ctx, &fdSpec)
+		Ω(err).Should(HaveOccurred())
+		problem := &cloud.FailureDomainProblem{}
+		Ω(errors.As(err, &problem)).Should(BeFalse())
+	})
+})
@@ -17,7 +17,9 @@ limitations under the License.
 package cloud
 
 import (
+	"context"
 	"fmt"
+	"strconv"
 	"strings"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -33,9 +35,14 @@ import (
 )
 
 type VMIface interface {
-	GetOrCreateVMInstance(*infrav1.CloudStackMachine, *clusterv1.Machine, *infrav1.CloudStackCluster, *infrav1.CloudStackFailureDomain, *infrav1.CloudStackAffinityGroup, string) error
-	ResolveVMInstanceDetails(*infrav1.CloudStackMachine) error
-	DestroyVMInstance(*infrav1.CloudStackMachine) error
+	GetOrCreateVMInstance(context.Context, *infrav1.CloudStackMachine, *clusterv1.Machine, *infrav1.CloudStackCluster, *infrav1.CloudStackFailureDomain, *infrav1.CloudStackAffinityGroup, string) error
+	ResolveVMInstanceDetails(context.Context, *infrav1.CloudStackMachine) error
+	DestroyVMInstance(context.Context, *infrav1.CloudStackMachine) error
+	VMInstanceNeedsResize(context.Context, *infrav1.CloudStackMachine, *infrav1.CloudStackFailureDomain) (bool, error)
+	ResizeVMInstance(context.Context, *infrav1.CloudStackMachine, *infrav1.CloudStackFailureDomain) error
+	ReconcileVMInstanceTags(context.Context, *infrav1.CloudStackMachine, *infrav1.CloudStackCluster) error
+	AdoptVMInstance(context.Context, *infrav1.CloudStackMachine, *infrav1.CloudStackCluster, *infrav1.CloudStackFailureDomain) error
+	ReleaseVMInstance(context.Context, *infrav1.CloudStackMachine, *infrav1.CloudStackCluster) error
 }
 
 // Set infrastructure spec and status from the CloudStack API's virtual machine metrics type.
@@ -44,6 +51,21 @@ func setMachineDataFromVMMetrics(vmResponse *cloudstack.VirtualMachinesMetric, c
 	// InstanceID is later used as required parameter to destroy VM.
 	csMachine.Spec.InstanceID = pointer.String(vmResponse.Id)
 	csMachine.Status.Addresses = []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: vmResponse.Ipaddress}}
+	// The default NIC's addresses are listed first, then any additional NICs'. On dual-stack networks, a NIC's IPv6
+	// address follows its IPv4 one.
+	for _, defaultNIC := range []bool{true, false} {
+		for _, nic := range vmResponse.Nic {
+			if nic.Isdefault != defaultNIC {
+				continue
+			}
+			for _, address := range []string{nic.Ipaddress, nic.Ip6address} {
+				if address != "" && (!nic.Isdefault || address != vmResponse.Ipaddress) {
+					csMachine.Status.Addresses = append(csMachine.Status.Addresses,
+						corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: address})
+				}
+			}
+		}
+	}
 	newInstanceState := vmResponse.State
 	if newInstanceState != csMachine.Status.InstanceState || (newInstanceState != "" && csMachine.Status.InstanceStateLastUpdated.IsZero()) {
 		csMachine.Status.InstanceState = newInstanceState
@@ -53,11 +75,14 @@ func setMachineDataFromVMMetrics(vmResponse *cloudstack.VirtualMachinesMetric, c
 
 // ResolveVMInstanceDetails Retrieves VM instance details by csMachine.Spec.InstanceID or csMachine.Name, and
 // sets infrastructure machine spec and status if VM instance is found.
-func (c *client) ResolveVMInstanceDetails(csMachine *infrav1.CloudStackMachine) error {
+func (c *client) ResolveVMInstanceDetails(ctx context.Context, csMachine *infrav1.CloudStackMachine) error {
+	c, _, cancel := c.withTimeout(ctx, OperationResolve)
+	defer cancel()
+
 	// Attempt to fetch by ID.
 	if csMachine.Spec.InstanceID != nil {
 		vmResp, count, err := c.cs.VirtualMachine.GetVirtualMachinesMetricByID(*csMachine.Spec.InstanceID)
-		if err != nil && !strings.Contains(strings.ToLower(err.Error()), "no match found") {
+		if err != nil && !IsNotFoundError(err) {
 			c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
 			return err
 		} else if count > 1 {
@@ -71,7 +96,7 @@ func (c *client) ResolveVMInstanceDetails(csMachine *infrav1.CloudStackMachine)
 	// Attempt fetch by name.
 	if csMachine.Name != "" {
 		vmResp, count, err := c.cs.VirtualMachine.GetVirtualMachinesMetricByName(csMachine.Name) // add opts usage
-		if err != nil && !strings.Contains(strings.ToLower(err.Error()), "no match") {
+		if err != nil && !IsNotFoundError(err) {
 			c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
 			return err
 		} else if count > 1 {
@@ -205,40 +230,182 @@ func verifyDiskoffering(csMachine *infrav1.CloudStackMachine, c *client, diskOff
 	return diskOfferingID, nil
 }
 
+// ResolveMachineNetworks resolves the IDs of the additional networks in a machine's spec within the passed zone.
+// The spec itself is left untouched; resolved copies are returned in spec order.
+func (c *client) ResolveMachineNetworks(
+	csMachine *infrav1.CloudStackMachine, zoneID string,
+) (networks []infrav1.CloudStackMachineNetwork, retErr error) {
+	for _, network := range csMachine.Spec.Networks {
+		if len(network.ID) > 0 {
+			netDetails, count, err := c.cs.Network.GetNetworkByID(network.ID)
+			if err != nil {
+				c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+				return nil, multierror.Append(retErr, errors.Wrapf(err, "could not get Network by ID %s", network.ID))
+			} else if count != 1 {
+				return nil, multierror.Append(retErr, errors.Errorf(
+					"expected 1 Network with UUID %s, but got %d", network.ID, count))
+			} else if netDetails.Zoneid != zoneID {
+				return nil, multierror.Append(retErr, errors.Errorf(
+					"network with UUID %s is in zone %s, not zone %s", network.ID, netDetails.Zoneid, zoneID))
+			} else if len(network.Name) > 0 && network.Name != netDetails.Name {
+				return nil, multierror.Append(retErr, errors.Errorf(
+					"network name %s does not match name %s returned using UUID %s", network.Name, netDetails.Name, network.ID))
+			}
+			network.Name = netDetails.Name
+		} else {
+			netDetails, count, err := c.cs.Network.GetNetworkByName(network.Name, cloudstack.WithZone(zoneID))
+			if err != nil {
+				c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+				return nil, multierror.Append(retErr, errors.Wrapf(
+					err, "could not get Network ID from %s in zone %s", network.Name, zoneID))
+			} else if count != 1 {
+				return nil, multierror.Append(retErr, errors.Errorf(
+					"expected 1 Network with name %s in zone %s, but got %d", network.Name, zoneID, count))
+			}
+			network.ID = netDetails.Id
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// machineNICs lists the networks a machine gets NICs on: the failure domain's network plus any resolved additional
+// networks. The default NIC's network is listed first, as CloudStack makes the first network the default.
+func machineNICs(fdNetwork infrav1.Network, networks []infrav1.CloudStackMachineNetwork) []infrav1.CloudStackMachineNetwork {
+	nics := []infrav1.CloudStackMachineNetwork{{ID: fdNetwork.ID, Name: fdNetwork.Name}}
+	for _, network := range networks {
+		if network.ID == fdNetwork.ID { // Options for the failure domain network's NIC.
+			if network.IP != "" {
+				nics[0].IP = network.IP
+			}
+			nics[0].Default = nics[0].Default || network.Default
+			continue
+		}
+		nics = append(nics, network)
+	}
+	for i, nic := range nics {
+		if nic.Default {
+			nics[0], nics[i] = nics[i], nics[0]
+			break
+		}
+	}
+	return nics
+}
+
+// applyIPAllocation sets the address a machine was allocated from an IP pool on the NIC of the pool's network.
+func applyIPAllocation(nics []infrav1.CloudStackMachineNetwork, fdNetwork infrav1.Network, allocation *infrav1.CloudStackIPAllocation) error {
+	if allocation == nil {
+		return nil
+	}
+	for i, nic := range nics {
+		onFDNetwork := nic.ID == fdNetwork.ID && (allocation.Network == "" || allocation.Network == fdNetwork.Name)
+		if onFDNetwork || (allocation.Network != "" && (nic.Name == allocation.Network || nic.ID == allocation.Network)) {
+			if nic.IP != "" && nic.IP != allocation.IP {
+				return errors.Errorf("network %s has static IP %s but IP pool %s allocated %s",
+					allocation.Network, nic.IP, allocation.Pool, allocation.IP)
+			}
+			nics[i].IP = allocation.IP
+			return nil
+		}
+	}
+	return errors.Errorf("machine has no NIC on network %s of IP pool %s", allocation.Network, allocation.Pool)
+}
+
+// setNICs sets the networks, and static IPs if any were requested, on VM deployment parameters.
+func setNICs(p *cloudstack.DeployVirtualMachineParams, nics []infrav1.CloudStackMachineNetwork) {
+	var networkIDs []string
+	var ipToNetworkList []map[string]string
+	staticIPs := false
+	for _, nic := range nics {
+		networkIDs = append(networkIDs, nic.ID)
+		ipToNetwork := map[string]string{"networkid": nic.ID}
+		if nic.IP != "" {
+			ipToNetwork["ip"] = nic.IP
+			staticIPs = true
+		}
+		ipToNetworkList = append(ipToNetworkList, ipToNetwork)
+	}
+	// CloudStack refuses networkids alongside iptonetworklist, so only use the latter when it's needed.
+	if staticIPs {
+		p.SetIptonetworklist(ipToNetworkList)
+	} else {
+		p.SetNetworkids(networkIDs)
+	}
+}
+
 // GetOrCreateVMInstance CreateVMInstance will fetch or create a VM instance, and
 // sets the infrastructure machine spec and status accordingly.
 func (c *client) GetOrCreateVMInstance(
+	ctx context.Context,
 	csMachine *infrav1.CloudStackMachine,
 	capiMachine *clusterv1.Machine,
 	csCluster *infrav1.CloudStackCluster,
 	fd *infrav1.CloudStackFailureDomain,
 	affinity *infrav1.CloudStackAffinityGroup,
 	userData string) error {
+	c, ctx, cancel := c.withTimeout(ctx, OperationCreateVM)
+	defer cancel()
+
+	return c.getOrCreateVMInstance(ctx, csMachine, capiMachine.Name, csCluster, fd, affinity, userData, "")
+}
+
+// getOrCreateVMInstance fetches or creates the VM instance of a machine, displayed under the passed name, and puts it
+// in the named instance group if one is passed.
+func (c *client) getOrCreateVMInstance(
+	ctx context.Context,
+	csMachine *infrav1.CloudStackMachine,
+	displayName string,
+	csCluster *infrav1.CloudStackCluster,
+	fd *infrav1.CloudStackFailureDomain,
+	affinity *infrav1.CloudStackAffinityGroup,
+	userData string,
+	group string) error {
+
+	// Wait for the instance's deployment, if it's running. CloudStack leaves the instances it fails to deploy behind,
+	// so they're resolved for the caller to clean up.
+	if job := csMachine.Status.AsyncJob; job != nil && job.Command == CommandDeployVM {
+		if err := c.pollAsyncJob(&csMachine.Status.AsyncJob); IsJobPending(err) {
+			return err
+		} else if err != nil {
+			return c.failedDeployment(ctx, csMachine, err)
+		}
+		csMachine.Status.Status = pointer.String(metav1.StatusSuccess)
+	}
 
 	// Check if VM instance already exists.
-	if err := c.ResolveVMInstanceDetails(csMachine); err == nil ||
-		!strings.Contains(strings.ToLower(err.Error()), "no match") {
+	if err := c.ResolveVMInstanceDetails(ctx, csMachine); err == nil ||
+		!IsNotFoundError(err) {
 		return err
 	}
 
+	// The offerings, template and networks a machine asks for won't show up by retrying.
 	offeringID, err := c.ResolveServiceOffering(csMachine, fd.Spec.Zone.ID)
 	if err != nil {
-		return err
+		return invalidConfigurationIfNotFound(err)
 	}
 	templateID, err := c.ResolveTemplate(csCluster, csMachine, fd.Spec.Zone.ID)
 	if err != nil {
-		return err
+		return invalidConfigurationIfNotFound(err)
 	}
 	diskOfferingID, err := c.ResolveDiskOffering(csMachine, fd.Spec.Zone.ID)
 	if err != nil {
-		return err
+		return invalidConfigurationIfNotFound(err)
+	}
+	networks, err := c.ResolveMachineNetworks(csMachine, fd.Spec.Zone.ID)
+	if err != nil {
+		return invalidConfigurationIfNotFound(err)
 	}
 
 	// Create VM instance.
 	p := c.cs.VirtualMachine.NewDeployVirtualMachineParams(offeringID, templateID, fd.Spec.Zone.ID)
-	p.SetNetworkids([]string{fd.Spec.Zone.Network.ID})
+	nics := machineNICs(fd.Spec.Zone.Network, networks)
+	if err := applyIPAllocation(nics, fd.Spec.Zone.Network, csMachine.Status.IPAllocation); err != nil {
+		return err
+	}
+	setNICs(p, nics)
 	setIfNotEmpty(csMachine.Name, p.SetName)
-	setIfNotEmpty(capiMachine.Name, p.SetDisplayname)
+	setIfNotEmpty(displayName, p.SetDisplayname)
+	setIfNotEmpty(group, p.SetGroup)
 	setIfNotEmpty(diskOfferingID, p.SetDiskofferingid)
 	setIntIfPositive(csMachine.Spec.DiskOffering.CustomSize, p.SetSize)
 
@@ -262,8 +429,11 @@ func (c *client) GetOrCreateVMInstance(
 	if csMachine.Spec.Details != nil {
 		p.SetDetails(csMachine.Spec.Details)
 	}
+	if err := c.setPlacementScope(p, fd.Spec.Zone); err != nil {
+		return err
+	}
 
-	deployVMResp, err := c.cs.VirtualMachine.DeployVirtualMachine(p)
+	deployVMResp, err := c.csAsync.VirtualMachine.DeployVirtualMachine(p)
 	if err != nil {
 		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
 
@@ -282,47 +452,519 @@ func (c *client) GetOrCreateVMInstance(
 		}
 		csMachine.Spec.InstanceID = pointer.String(listVirtualMachinesResponse.VirtualMachines[0].Id)
 		csMachine.Status.InstanceState = listVirtualMachinesResponse.VirtualMachines[0].State
-	} else {
-		csMachine.Spec.InstanceID = pointer.String(deployVMResp.Id)
-		csMachine.Status.Status = pointer.String(metav1.StatusSuccess)
+		// Still report why deploying failed, so the caller can tell whether to try elsewhere.
+		if err2 := c.ResolveVMInstanceDetails(ctx, csMachine); err2 != nil {
+			return err2
+		}
+		return err
+	}
+	csMachine.Spec.InstanceID = pointer.String(deployVMResp.Id)
+	if err := c.startAsyncJob(&csMachine.Status.AsyncJob, CommandDeployVM, deployVMResp.JobID); IsJobPending(err) {
+		return err
+	} else if err != nil {
+		return c.failedDeployment(ctx, csMachine, err)
 	}
+	csMachine.Status.Status = pointer.String(metav1.StatusSuccess)
 	// Resolve uses a VM metrics request response to fill cloudstack machine status.
 	// The deployment response is insufficient.
-	return c.ResolveVMInstanceDetails(csMachine)
+	return c.ResolveVMInstanceDetails(ctx, csMachine)
+}
+
+// failedDeployment resolves the instance CloudStack left behind when its deployment job failed, for the caller to clean
+// up, and returns why deploying failed.
+func (c *client) failedDeployment(ctx context.Context, csMachine *infrav1.CloudStackMachine, err error) error {
+	if err2 := c.ResolveVMInstanceDetails(ctx, csMachine); err2 != nil && !IsNotFoundError(err2) {
+		return err2
+	}
+	return err
+}
+
+// setPlacementScope narrows a deployment to the pod and cluster of the failure domain's zone, and to the host it
+// deploys on if the zone is narrowed to a host tag.
+func (c *client) setPlacementScope(p *cloudstack.DeployVirtualMachineParams, zone infrav1.CloudStackZoneSpec) error {
+	if zone.Pod != nil {
+		setIfNotEmpty(zone.Pod.ID, p.SetPodid)
+	}
+	if zone.Cluster != nil {
+		setIfNotEmpty(zone.Cluster.ID, p.SetClusterid)
+	}
+	if zone.HostTag == "" {
+		return nil
+	}
+	hostID, err := c.pickTaggedHost(zone)
+	if err != nil {
+		return err
+	}
+	p.SetHostid(hostID)
+	return nil
+}
+
+// pickTaggedHost returns the enabled host carrying the zone's host tag, within its pod and cluster, with the most
+// unallocated memory.
+func (c *client) pickTaggedHost(zone infrav1.CloudStackZoneSpec) (string, error) {
+	p := c.cs.Host.NewListHostsParams()
+	p.SetZoneid(zone.ID)
+	p.SetType("Routing")
+	p.SetState("Up")
+	p.SetResourcestate("Enabled")
+	if zone.Pod != nil {
+		setIfNotEmpty(zone.Pod.ID, p.SetPodid)
+	}
+	if zone.Cluster != nil {
+		setIfNotEmpty(zone.Cluster.ID, p.SetClusterid)
+	}
+	resp, err := c.cs.Host.ListHosts(p)
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return "", errors.Wrapf(err, "listing hosts tagged %s in zone %s", zone.HostTag, zone.Name)
+	}
+
+	var picked *cloudstack.Host
+	for _, host := range resp.Hosts {
+		tagged := false
+		for _, tag := range strings.Split(host.Hosttags, ",") {
+			tagged = tagged || strings.TrimSpace(tag) == zone.HostTag
+		}
+		if tagged && (picked == nil || host.Memorytotal-host.Memoryallocated > picked.Memorytotal-picked.Memoryallocated) {
+			picked = host
+		}
+	}
+	if picked == nil {
+		return "", errors.Errorf("found no enabled host tagged %s in zone %s", zone.HostTag, zone.Name)
+	}
+	return picked.Id, nil
 }
 
 // DestroyVMInstance Destroys a VM instance. Assumes machine has been fetched prior and has an instance ID.
-func (c *client) DestroyVMInstance(csMachine *infrav1.CloudStackMachine) error {
+// The instance is expunged unless the machine's deletion policy is Destroy, in which case the tags marking it as
+// CAPC's are removed from it so it isn't taken for an orphan and expunged after all. Its data disks are deleted with it,
+// unless the machine's data disk deletion policy retains them, in which case they're recorded in its status.
+// Retaining data disks and destroying are started as async jobs recorded in the machine's status, which are polled on
+// later calls until the instance is gone. A job the machine's instance has running for something else is waited for first.
+func (c *client) DestroyVMInstance(ctx context.Context, csMachine *infrav1.CloudStackMachine) error {
+	c, ctx, cancel := c.withTimeout(ctx, OperationDestroyVM)
+	defer cancel()
+
+	expunge := csMachine.Spec.DeletionPolicy != infrav1.DeletionPolicyDestroy
+	job := csMachine.Status.AsyncJob
+	destroying := job != nil && job.Command == CommandDestroyVM
+	retaining := job != nil && (job.Command == CommandCreateSnapshot || job.Command == CommandDetachVolume)
+	if err := c.pollAsyncJob(&csMachine.Status.AsyncJob); IsJobPending(err) || (destroying && err != nil) {
+		return err
+	} else if retaining && err != nil {
+		return retainingDataDiskFailed(csMachine, job.Command, err)
+	} else if !destroying {
+		if gone, err := c.destroyVMInstance(csMachine, expunge); err != nil || gone {
+			return err
+		}
+	}
+
+	if err := c.ResolveVMInstanceDetails(ctx, csMachine); err == nil && (csMachine.Status.InstanceState == "Expunging" ||
+		csMachine.Status.InstanceState == "Expunged") {
+		// VM is stopped and getting expunged.  So the desired state is getting satisfied.  Let's move on.
+		return nil
+	} else if err == nil && !expunge && csMachine.Status.InstanceState == "Destroyed" {
+		// VM is destroyed and left to be recovered, by whoever recovers it rather than by CAPC.
+		return c.dropCAPCTags(ctx, *csMachine.Spec.InstanceID)
+	} else if err != nil {
+		if IsNotFoundError(err) {
+			// VM doesn't exist.  So the desired state is in effect.  Our work is done here.
+			return nil
+		}
+		return err
+	}
+
+	return errors.Errorf("VM instance with ID %s is %s after destroying it", *csMachine.Spec.InstanceID,
+		csMachine.Status.InstanceState)
+}
+
+// destroyVMInstance starts destroying a machine's VM instance, reporting whether the instance is gone already.
+func (c *client) destroyVMInstance(csMachine *infrav1.CloudStackMachine, expunge bool) (bool, error) {
 	// Attempt deletion regardless of machine state.
 	p := c.csAsync.VirtualMachine.NewDestroyVirtualMachineParams(*csMachine.Spec.InstanceID)
 	volIDs, err := c.listVMInstanceDatadiskVolumeIDs(*csMachine.Spec.InstanceID)
 	if err != nil {
-		return err
+		return false, err
 	}
-	p.SetExpunge(true)
-	setArrayIfNotEmpty(volIDs, p.SetVolumeids)
-	if _, err := c.csAsync.VirtualMachine.DestroyVirtualMachine(p); err != nil &&
-		strings.Contains(strings.ToLower(err.Error()), "unable to find uuid for id") {
+	switch csMachine.Spec.DataDiskDeletionPolicy {
+	case infrav1.DataDiskDeletionPolicyRetain, infrav1.DataDiskDeletionPolicySnapshotAndRetain:
+		if err := c.retainDataDisks(csMachine, volIDs); err != nil {
+			return false, err
+		}
+	default:
+		setArrayIfNotEmpty(volIDs, p.SetVolumeids)
+	}
+	p.SetExpunge(expunge)
+	resp, err := c.csAsync.VirtualMachine.DestroyVirtualMachine(p)
+	if err != nil && strings.Contains(strings.ToLower(err.Error()), "unable to find uuid for id") {
 		// VM doesn't exist. Success...
-		return nil
+		return true, nil
 	} else if err != nil {
 		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return false, err
+	}
+	var jobID string
+	if resp != nil {
+		jobID = resp.JobID
+	}
+	return false, c.startAsyncJob(&csMachine.Status.AsyncJob, CommandDestroyVM, jobID)
+}
+
+// dropCAPCTags removes the cluster, management cluster, created by CAPC and adopted by CAPC tags from a VM instance.
+func (c *client) dropCAPCTags(ctx context.Context, instanceID string) error {
+	tags, err := c.GetTags(ctx, ResourceTypeUserVM, instanceID)
+	if err != nil {
+		return errors.Wrapf(err, "getting tags of VM instance with ID %s", instanceID)
+	}
+	capcTags := map[string]string{}
+	for key, value := range tags {
+		if key == CreatedByCAPCTagName || key == AdoptedByCAPCTagName || key == ManagementClusterTagName ||
+			strings.HasPrefix(key, ClusterTagNamePrefix) {
+			capcTags[key] = value
+		}
+	}
+	if len(capcTags) == 0 {
+		return nil
+	}
+	return c.DeleteTags(ctx, ResourceTypeUserVM, instanceID, capcTags)
+}
+
+// retainDataDisks detaches a machine's data disks so they outlive its instance, snapshotting them first if its data
+// disk deletion policy asks for it. Snapshotting and detaching are started one disk at a time as async jobs recorded
+// in the machine's status, and a JobPendingError is returned while one runs. Each disk is recorded in the machine's
+// status before it's snapshotted, along with its snapshot, so a snapshot isn't taken twice.
+func (c *client) retainDataDisks(csMachine *infrav1.CloudStackMachine, volIDs []string) error {
+	snapshot := csMachine.Spec.DataDiskDeletionPolicy == infrav1.DataDiskDeletionPolicySnapshotAndRetain
+	for _, volID := range volIDs {
+		var retained *infrav1.RetainedDataDisk
+		for i := range csMachine.Status.RetainedDataDisks {
+			if csMachine.Status.RetainedDataDisks[i].VolumeID == volID {
+				retained = &csMachine.Status.RetainedDataDisks[i]
+			}
+		}
+		if retained == nil {
+			csMachine.Status.RetainedDataDisks = append(csMachine.Status.RetainedDataDisks,
+				infrav1.RetainedDataDisk{VolumeID: volID})
+			retained = &csMachine.Status.RetainedDataDisks[len(csMachine.Status.RetainedDataDisks)-1]
+		}
+
+		if snapshot && retained.SnapshotID == "" {
+			resp, err := c.csAsync.Snapshot.CreateSnapshot(c.csAsync.Snapshot.NewCreateSnapshotParams(volID))
+			if err != nil {
+				c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+				return errors.Wrapf(err, "snapshotting data disk with ID %s", volID)
+			}
+			retained.SnapshotID = resp.Id
+			if err := c.startAsyncJob(&csMachine.Status.AsyncJob, CommandCreateSnapshot, resp.JobID); err != nil {
+				return retainingDataDiskFailed(csMachine, CommandCreateSnapshot, err)
+			}
+		}
+
+		p := c.csAsync.Volume.NewDetachVolumeParams()
+		p.SetId(volID)
+		resp, err := c.csAsync.Volume.DetachVolume(p)
+		if err != nil {
+			c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+			return errors.Wrapf(err, "detaching data disk with ID %s", volID)
+		}
+		if err := c.startAsyncJob(&csMachine.Status.AsyncJob, CommandDetachVolume, resp.JobID); err != nil {
+			return retainingDataDiskFailed(csMachine, CommandDetachVolume, err)
+		}
+	}
+	return nil
+}
+
+// retainingDataDiskFailed returns the error of the job snapshotting or detaching the data disk a machine recorded
+// last, which is the one being retained. A disk whose snapshot failed is snapshotted again when retrying. Jobs still
+// running are returned as they are.
+func retainingDataDiskFailed(csMachine *infrav1.CloudStackMachine, command string, err error) error {
+	if IsJobPending(err) || len(csMachine.Status.RetainedDataDisks) == 0 {
+		return err
+	}
+	retained := &csMachine.Status.RetainedDataDisks[len(csMachine.Status.RetainedDataDisks)-1]
+	if command == CommandCreateSnapshot {
+		retained.SnapshotID = ""
+		return errors.Wrapf(err, "snapshotting data disk with ID %s", retained.VolumeID)
+	}
+	return errors.Wrapf(err, "detaching data disk with ID %s", retained.VolumeID)
+}
+
+// getVMInstance fetches the VM instance of a machine that has been created.
+func (c *client) getVMInstance(csMachine *infrav1.CloudStackMachine) (*cloudstack.VirtualMachine, error) {
+	if csMachine.Spec.InstanceID == nil {
+		return nil, errors.Errorf("machine %s has no instance yet", csMachine.Name)
+	}
+	vm, count, err := c.cs.VirtualMachine.GetVirtualMachineByID(*csMachine.Spec.InstanceID)
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return nil, errors.Wrapf(err, "fetching VM instance with ID %s", *csMachine.Spec.InstanceID)
+	} else if count != 1 {
+		return nil, errors.Errorf("expected 1 VM instance with ID %s, but got %d", *csMachine.Spec.InstanceID, count)
+	}
+	return vm, nil
+}
+
+// resizableDetails returns the details of a machine an in-place resize applies.
+func resizableDetails(csMachine *infrav1.CloudStackMachine) map[string]string {
+	details := map[string]string{}
+	for _, key := range infrav1.ResizableDetails {
+		if value, found := csMachine.Spec.Details[key]; found {
+			details[key] = value
+		}
+	}
+	return details
+}
+
+// VMInstanceNeedsResize reports whether a machine's VM instance differs from the compute offering or the custom CPU
+// and memory details of its spec.
+func (c *client) VMInstanceNeedsResize(ctx context.Context, csMachine *infrav1.CloudStackMachine, fd *infrav1.CloudStackFailureDomain) (bool, error) {
+	c, _, cancel := c.withTimeout(ctx, OperationResolve)
+	defer cancel()
+
+	vm, err := c.getVMInstance(csMachine)
+	if err != nil {
+		return false, err
+	}
+	offeringID, err := c.ResolveServiceOffering(csMachine, fd.Spec.Zone.ID)
+	if err != nil {
+		return false, err
+	}
+	return needsResize(vm, offeringID, csMachine), nil
+}
+
+// needsResize reports whether a VM instance differs from the passed compute offering or the custom CPU and memory
+// details of its machine's spec.
+func needsResize(vm *cloudstack.VirtualMachine, offeringID string, csMachine *infrav1.CloudStackMachine) bool {
+	if vm.Serviceofferingid != offeringID {
+		return true
+	}
+	current := map[string]int{"cpuNumber": vm.Cpunumber, "cpuSpeed": vm.Cpuspeed, "memory": vm.Memory}
+	for key, value := range resizableDetails(csMachine) {
+		if value != strconv.Itoa(current[key]) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResizeVMInstance scales a machine's VM instance to the compute offering and custom CPU and memory details of its
+// spec. Running instances are scaled live when both they and the offering allow dynamic scaling, and otherwise are
+// stopped, scaled and started again. Stopping and starting are async jobs recorded in the machine's status, which
+// are polled on later calls, each of which takes the resize as far as it can.
+func (c *client) ResizeVMInstance(ctx context.Context, csMachine *infrav1.CloudStackMachine, fd *infrav1.CloudStackFailureDomain) error {
+	c, ctx, cancel := c.withTimeout(ctx, OperationUpdateVM)
+	defer cancel()
+
+	// Finish stopping or starting the instance, if that's running.
+	if err := c.pollAsyncJob(&csMachine.Status.AsyncJob); err != nil {
+		return err
+	}
+	vm, err := c.getVMInstance(csMachine)
+	if err != nil {
 		return err
 	}
+	offeringID, err := c.ResolveServiceOffering(csMachine, fd.Spec.Zone.ID)
+	if err != nil {
+		return err
+	}
+	offering, count, err := c.cs.ServiceOffering.GetServiceOfferingByID(offeringID)
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return errors.Wrapf(err, "fetching Service Offering with ID %s", offeringID)
+	} else if count != 1 {
+		return errors.Errorf("expected 1 Service Offering with ID %s, but got %d", offeringID, count)
+	}
 
-	if err := c.ResolveVMInstanceDetails(csMachine); err == nil && (csMachine.Status.InstanceState == "Expunging" ||
-		csMachine.Status.InstanceState == "Expunged") {
-		// VM is stopped and getting expunged.  So the desired state is getting satisfied.  Let's move on.
+	p := c.cs.VirtualMachine.NewScaleVirtualMachineParams(vm.Id, offeringID)
+	if details := resizableDetails(csMachine); len(details) > 0 {
+		p.SetDetails(details)
+	}
+	scale := func() error {
+		if _, err := c.cs.VirtualMachine.ScaleVirtualMachine(p); err != nil {
+			c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+			return errors.Wrapf(err, "scaling VM instance with ID %s", vm.Id)
+		}
 		return nil
+	}
+
+	needed := needsResize(vm, offeringID, csMachine)
+	if vm.State == "Running" {
+		if !needed { // Started again after scaling.
+			return c.ResolveVMInstanceDetails(ctx, csMachine)
+		} else if vm.Isdynamicallyscalable && offering.Dynamicscalingenabled {
+			if err := scale(); err != nil {
+				return err
+			}
+			return c.ResolveVMInstanceDetails(ctx, csMachine)
+		}
+		resp, err := c.csAsync.VirtualMachine.StopVirtualMachine(c.csAsync.VirtualMachine.NewStopVirtualMachineParams(vm.Id))
+		if err != nil {
+			c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+			return errors.Wrapf(err, "stopping VM instance with ID %s to scale it", vm.Id)
+		} else if err := c.startAsyncJob(&csMachine.Status.AsyncJob, CommandStopVM, resp.JobID); err != nil {
+			return errors.Wrapf(err, "stopping VM instance with ID %s to scale it", vm.Id)
+		}
+	} else if vm.State != "Stopped" {
+		return errors.Errorf("VM instance with ID %s is %s, so it can't be scaled", vm.Id, vm.State)
+	}
+
+	var scaleErr error
+	if needed {
+		scaleErr = scale()
+	}
+	// Start the instance again even if scaling failed, so a refused resize doesn't take the machine down.
+	resp, err := c.csAsync.VirtualMachine.StartVirtualMachine(c.csAsync.VirtualMachine.NewStartVirtualMachineParams(vm.Id))
+	if err == nil {
+		err = c.startAsyncJob(&csMachine.Status.AsyncJob, CommandStartVM, resp.JobID)
+	} else {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+	}
+	if err != nil && !IsJobPending(err) {
+		return multierror.Append(scaleErr, errors.Wrapf(err, "starting VM instance with ID %s after scaling it", vm.Id))
+	} else if scaleErr != nil {
+		return scaleErr
 	} else if err != nil {
-		if strings.Contains(strings.ToLower(err.Error()), "no match found") {
-			// VM doesn't exist.  So the desired state is in effect.  Our work is done here.
-			return nil
+		return err
+	}
+	return c.ResolveVMInstanceDetails(ctx, csMachine)
+}
+
+// ReconcileVMInstanceTags tags a machine's VM instance and its volumes with the cluster's tags and the machine's
+// additional tags. Adopted instances are tagged as such too.
+func (c *client) ReconcileVMInstanceTags(ctx context.Context, csMachine *infrav1.CloudStackMachine, csCluster *infrav1.CloudStackCluster) error {
+	c, ctx, cancel := c.withTimeout(ctx, OperationDefault)
+	defer cancel()
+
+	if csMachine.Spec.InstanceID == nil {
+		return errors.Errorf("machine %s has no instance yet", csMachine.Name)
+	}
+	tags := ClusterResourceTags(csCluster, csMachine.Spec.AdditionalTags)
+	if csMachine.Spec.AdoptInstance {
+		tags[AdoptedByCAPCTagName] = "1"
+	}
+	if err := c.ReconcileTags(ctx, ResourceTypeUserVM, *csMachine.Spec.InstanceID, tags); err != nil {
+		return err
+	}
+
+	p := c.cs.Volume.NewListVolumesParams()
+	p.SetVirtualmachineid(*csMachine.Spec.InstanceID)
+	volumes, err := c.cs.Volume.ListVolumes(p)
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return errors.Wrapf(err, "listing volumes of VM instance with ID %s", *csMachine.Spec.InstanceID)
+	}
+	for _, volume := range volumes.Volumes {
+		if err := c.ReconcileTags(ctx, ResourceTypeVolume, volume.Id, tags); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AdoptVMInstance finds the existing VM instance of a machine by its instance ID or name and checks it fits the
+// machine: it must be in the failure domain's zone, have a NIC on its network, and use the machine's offering and
+// template. The machine's spec and status are set from the instance only once it passes.
+func (c *client) AdoptVMInstance(
+	ctx context.Context,
+	csMachine *infrav1.CloudStackMachine,
+	csCluster *infrav1.CloudStackCluster,
+	fd *infrav1.CloudStackFailureDomain,
+) (retErr error) {
+	c, ctx, cancel := c.withTimeout(ctx, OperationUpdateVM)
+	defer cancel()
+
+	found := csMachine.DeepCopy()
+	if err := c.ResolveVMInstanceDetails(ctx, found); err != nil {
+		if IsNotFoundError(err) {
+			return errors.Errorf("found no VM instance to adopt with ID %s or name %s",
+				pointer.StringDeref(csMachine.Spec.InstanceID, ""), csMachine.Name)
 		}
 		return err
 	}
+	vm, err := c.getVMInstance(found)
+	if err != nil {
+		return err
+	}
+
+	if vm.Zoneid != fd.Spec.Zone.ID {
+		retErr = multierror.Append(retErr, errors.Errorf(
+			"VM instance %s is in zone %s rather than the failure domain's zone %s", vm.Id, vm.Zoneid, fd.Spec.Zone.ID))
+	}
+	if network := fd.Spec.Zone.Network; network.ID != "" || network.Name != "" {
+		onNetwork := false
+		for _, nic := range vm.Nic {
+			onNetwork = onNetwork || (network.ID != "" && nic.Networkid == network.ID) ||
+				(network.ID == "" && nic.Networkname == network.Name)
+		}
+		if !onNetwork {
+			retErr = multierror.Append(retErr, errors.Errorf(
+				"VM instance %s has no NIC on the failure domain's network %s%s", vm.Id, network.ID, network.Name))
+		}
+	}
+	if offeringID, err := c.ResolveServiceOffering(csMachine, fd.Spec.Zone.ID); err != nil {
+		retErr = multierror.Append(retErr, err)
+	} else if vm.Serviceofferingid != offeringID {
+		retErr = multierror.Append(retErr, errors.Errorf(
+			"VM instance %s uses Service Offering %s rather than %s", vm.Id, vm.Serviceofferingid, offeringID))
+	}
+	if templateID, err := c.ResolveTemplate(csCluster, csMachine, fd.Spec.Zone.ID); err != nil {
+		retErr = multierror.Append(retErr, err)
+	} else if vm.Templateid != templateID {
+		retErr = multierror.Append(retErr, errors.Errorf(
+			"VM instance %s uses Template %s rather than %s", vm.Id, vm.Templateid, templateID))
+	}
+	if retErr != nil {
+		return retErr
+	}
+
+	found.DeepCopyInto(csMachine)
+	return nil
+}
+
+// ReleaseVMInstance hands a machine's VM instance back without destroying it. The instance is taken out of the load
+// balancer rules it's a member of, and the cluster, created by CAPC and adopted by CAPC tags are removed from it and
+// its volumes.
+func (c *client) ReleaseVMInstance(ctx context.Context, csMachine *infrav1.CloudStackMachine, csCluster *infrav1.CloudStackCluster) error {
+	c, ctx, cancel := c.withTimeout(ctx, OperationUpdateVM)
+	defer cancel()
+
+	if csMachine.Spec.InstanceID == nil {
+		return nil
+	}
+	instanceID := *csMachine.Spec.InstanceID
 
-	return errors.New("VM deletion in progress")
+	p := c.cs.LoadBalancer.NewListLoadBalancerRulesParams()
+	p.SetVirtualmachineid(instanceID)
+	p.SetListall(true)
+	rules, err := c.cs.LoadBalancer.ListLoadBalancerRules(p)
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return errors.Wrapf(err, "listing load balancer rules of VM instance with ID %s", instanceID)
+	}
+	for _, rule := range rules.LoadBalancerRules {
+		p := c.cs.LoadBalancer.NewRemoveFromLoadBalancerRuleParams(rule.Id)
+		p.SetVirtualmachineids([]string{instanceID})
+		if _, err := c.cs.LoadBalancer.RemoveFromLoadBalancerRule(p); err != nil {
+			c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+			return errors.Wrapf(err, "removing VM instance with ID %s from load balancer rule %s", instanceID, rule.Id)
+		}
+	}
+
+	capcTags := map[string]string{
+		generateClusterTagName(csCluster): "1", CreatedByCAPCTagName: "1", AdoptedByCAPCTagName: "1"}
+	volumesParams := c.cs.Volume.NewListVolumesParams()
+	volumesParams.SetVirtualmachineid(instanceID)
+	volumes, err := c.cs.Volume.ListVolumes(volumesParams)
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return errors.Wrapf(err, "listing volumes of VM instance with ID %s", instanceID)
+	}
+	for _, volume := range volumes.Volumes {
+		if err := c.DeleteTags(ctx, ResourceTypeVolume, volume.Id, capcTags); err != nil {
+			return err
+		}
+	}
+	return c.DeleteTags(ctx, ResourceTypeUserVM, instanceID, capcTags)
 }
 
 func (c *client) listVMInstanceDatadiskVolumeIDs(instanceID string) ([]string, error) {
@@ -29,7 +29,9 @@ import (
 
 	. "github.com/onsi/gomega"
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/utils/pointer"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/test/simulator"
 )
 
 var _ = Describe("Instance", func() {
@@ -52,7 +54,7 @@ var _ = Describe("Instance", func() {
 		dos        *cloudstack.MockDiskOfferingServiceIface
 		ts         *cloudstack.MockTemplateServiceIface
 		vs         *cloudstack.MockVolumeServiceIface
-		client     cloud.Client
+		ns         *cloudstack.MockNetworkServiceIface
 	)
 
 	BeforeEach(func() {
@@ -63,6 +65,7 @@ var _ = Describe("Instance", func() {
 		dos = mockClient.DiskOffering.(*cloudstack.MockDiskOfferingServiceIface)
 		ts = mockClient.Template.(*cloudstack.MockTemplateServiceIface)
 		vs = mockClient.Volume.(*cloudstack.MockVolumeServiceIface)
+		ns = mockClient.Network.(*cloudstack.MockNetworkServiceIface)
 		client = cloud.NewClientFromCSAPIClient(mockClient)
 
 		dummies.SetDummyVars()
@@ -75,19 +78,19 @@ var _ = Describe("Instance", func() {
 	Context("when fetching a VM instance", func() {
 		It("Handles an unknown error when fetching by ID", func() {
 			vms.EXPECT().GetVirtualMachinesMetricByID(*dummies.CSMachine1.Spec.InstanceID).Return(nil, -1, unknownError)
-			Ω(client.ResolveVMInstanceDetails(dummies.CSMachine1)).To(MatchError(unknownErrorMessage))
+			Ω(client.ResolveVMInstanceDetails(ctx, dummies.CSMachine1)).To(MatchError(unknownErrorMessage))
 		})
 
 		It("Handles finding more than one VM instance by ID", func() {
 			vms.EXPECT().GetVirtualMachinesMetricByID(*dummies.CSMachine1.Spec.InstanceID).Return(nil, 2, nil)
-			Ω(client.ResolveVMInstanceDetails(dummies.CSMachine1)).
+			Ω(client.ResolveVMInstanceDetails(ctx, dummies.CSMachine1)).
 				Should(MatchError("found more than one VM Instance with ID " + *dummies.CSMachine1.Spec.InstanceID))
 		})
 
 		It("sets dummies.CSMachine1 spec and status values when VM instance found by ID", func() {
 			vmsResp := &cloudstack.VirtualMachinesMetric{Id: *dummies.CSMachine1.Spec.InstanceID}
 			vms.EXPECT().GetVirtualMachinesMetricByID(*dummies.CSMachine1.Spec.InstanceID).Return(vmsResp, 1, nil)
-			Ω(client.ResolveVMInstanceDetails(dummies.CSMachine1)).Should(Succeed())
+			Ω(client.ResolveVMInstanceDetails(ctx, dummies.CSMachine1)).Should(Succeed())
 			Ω(dummies.CSMachine1.Spec.ProviderID).Should(Equal(pointer.String("cloudstack:///" + vmsResp.Id)))
 			Ω(dummies.CSMachine1.Spec.InstanceID).Should(Equal(pointer.String(vmsResp.Id)))
 		})
@@ -96,14 +99,14 @@ var _ = Describe("Instance", func() {
 			vms.EXPECT().GetVirtualMachinesMetricByID(*dummies.CSMachine1.Spec.InstanceID).Return(nil, -1, notFoundError)
 			vms.EXPECT().GetVirtualMachinesMetricByName(dummies.CSMachine1.Name).Return(nil, -1, unknownError)
 
-			Ω(client.ResolveVMInstanceDetails(dummies.CSMachine1)).Should(MatchError(unknownErrorMessage))
+			Ω(client.ResolveVMInstanceDetails(ctx, dummies.CSMachine1)).Should(MatchError(unknownErrorMessage))
 		})
 
 		It("handles finding more than one VM instance by Name", func() {
 			vms.EXPECT().GetVirtualMachinesMetricByID(*dummies.CSMachine1.Spec.InstanceID).Return(nil, -1, notFoundError)
 			vms.EXPECT().GetVirtualMachinesMetricByName(dummies.CSMachine1.Name).Return(nil, 2, nil)
 
-			Ω(client.ResolveVMInstanceDetails(dummies.CSMachine1)).Should(
+			Ω(client.ResolveVMInstanceDetails(ctx, dummies.CSMachine1)).Should(
 				MatchError("found more than one VM Instance with name " + dummies.CSMachine1.Name))
 		})
 
@@ -112,7 +115,7 @@ var _ = Describe("Instance", func() {
 			vms.EXPECT().GetVirtualMachinesMetricByName(dummies.CSMachine1.Name).
 				Return(&cloudstack.VirtualMachinesMetric{Id: *dummies.CSMachine1.Spec.InstanceID}, -1, nil)
 
-			Ω(client.ResolveVMInstanceDetails(dummies.CSMachine1)).Should(Succeed())
+			Ω(client.ResolveVMInstanceDetails(ctx, dummies.CSMachine1)).Should(Succeed())
 			Ω(dummies.CSMachine1.Spec.ProviderID).Should(Equal(
 				pointer.String(fmt.Sprintf("cloudstack:///%s", *dummies.CSMachine1.Spec.InstanceID))))
 			Ω(dummies.CSMachine1.Spec.InstanceID).Should(Equal(pointer.String(*dummies.CSMachine1.Spec.InstanceID)))
@@ -130,6 +133,7 @@ var _ = Describe("Instance", func() {
 		It("doesn't re-create if one already exists.", func() {
 			vms.EXPECT().GetVirtualMachinesMetricByID(*dummies.CSMachine1.Spec.InstanceID).Return(vmMetricResp, -1, nil)
 			Ω(client.GetOrCreateVMInstance(
+				ctx,
 				dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster, dummies.CSFailureDomain1, dummies.CSAffinityGroup, "")).
 				Should(Succeed())
 		})
@@ -137,6 +141,7 @@ var _ = Describe("Instance", func() {
 		It("returns unknown error while fetching VM instance", func() {
 			vms.EXPECT().GetVirtualMachinesMetricByID(*dummies.CSMachine1.Spec.InstanceID).Return(nil, -1, unknownError)
 			Ω(client.GetOrCreateVMInstance(
+				ctx,
 				dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster, dummies.CSFailureDomain1, dummies.CSAffinityGroup, "")).
 				Should(MatchError(unknownErrorMessage))
 		})
@@ -145,6 +150,7 @@ var _ = Describe("Instance", func() {
 			expectVMNotFound()
 			sos.EXPECT().GetServiceOfferingID(dummies.CSMachine1.Spec.Offering.Name, gomock.Any()).Return("", -1, unknownError)
 			Ω(client.GetOrCreateVMInstance(
+				ctx,
 				dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster, dummies.CSFailureDomain1, dummies.CSAffinityGroup, "")).
 				ShouldNot(Succeed())
 		})
@@ -153,6 +159,7 @@ var _ = Describe("Instance", func() {
 			expectVMNotFound()
 			sos.EXPECT().GetServiceOfferingID(dummies.CSMachine1.Spec.Offering.Name, gomock.Any()).Return("", 2, nil)
 			Ω(client.GetOrCreateVMInstance(
+				ctx,
 				dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster, dummies.CSFailureDomain1, dummies.CSAffinityGroup, "")).
 				ShouldNot(Succeed())
 		})
@@ -164,6 +171,7 @@ var _ = Describe("Instance", func() {
 			ts.EXPECT().GetTemplateID(dummies.CSMachine1.Spec.Template.Name, executableFilter, dummies.Zone1.ID).
 				Return("", -1, unknownError)
 			Ω(client.GetOrCreateVMInstance(
+				ctx,
 				dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster, dummies.CSFailureDomain1, dummies.CSAffinityGroup, "")).
 				ShouldNot(Succeed())
 		})
@@ -174,6 +182,7 @@ var _ = Describe("Instance", func() {
 				Return(dummies.CSMachine1.Spec.Offering.ID, 1, nil)
 			ts.EXPECT().GetTemplateID(dummies.CSMachine1.Spec.Template.Name, executableFilter, dummies.Zone1.ID).Return("", 2, nil)
 			Ω(client.GetOrCreateVMInstance(
+				ctx,
 				dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster, dummies.CSFailureDomain1, dummies.CSAffinityGroup, "")).
 				ShouldNot(Succeed())
 		})
@@ -185,6 +194,7 @@ var _ = Describe("Instance", func() {
 			ts.EXPECT().GetTemplateID(dummies.CSMachine1.Spec.Template.Name, executableFilter, dummies.Zone1.ID).Return(dummies.CSMachine1.Spec.Template.ID, 1, nil)
 			dos.EXPECT().GetDiskOfferingID(dummies.CSMachine1.Spec.DiskOffering.Name, gomock.Any()).Return(diskOfferingFakeID, 2, nil)
 			Ω(client.GetOrCreateVMInstance(
+				ctx,
 				dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster, dummies.CSFailureDomain1, dummies.CSAffinityGroup, "")).
 				ShouldNot(Succeed())
 		})
@@ -197,6 +207,7 @@ var _ = Describe("Instance", func() {
 			dos.EXPECT().GetDiskOfferingID(dummies.CSMachine1.Spec.DiskOffering.Name, gomock.Any()).Return(diskOfferingFakeID, 1, nil)
 			dos.EXPECT().GetDiskOfferingByID(diskOfferingFakeID).Return(&cloudstack.DiskOffering{Iscustomized: false}, 1, unknownError)
 			Ω(client.GetOrCreateVMInstance(
+				ctx,
 				dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster, dummies.CSFailureDomain1, dummies.CSAffinityGroup, "")).
 				ShouldNot(Succeed())
 		})
@@ -210,6 +221,7 @@ var _ = Describe("Instance", func() {
 			dos.EXPECT().GetDiskOfferingID(dummies.CSMachine1.Spec.DiskOffering.Name, gomock.Any()).Return(diskOfferingFakeID, 1, nil)
 			dos.EXPECT().GetDiskOfferingByID(diskOfferingFakeID).Return(&cloudstack.DiskOffering{Iscustomized: false}, 1, nil)
 			Ω(client.GetOrCreateVMInstance(
+				ctx,
 				dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster, dummies.CSFailureDomain1, dummies.CSAffinityGroup, "")).
 				ShouldNot(Succeed())
 		})
@@ -223,6 +235,7 @@ var _ = Describe("Instance", func() {
 			dos.EXPECT().GetDiskOfferingID(dummies.CSMachine1.Spec.DiskOffering.Name, gomock.Any()).Return(diskOfferingFakeID, 1, nil)
 			dos.EXPECT().GetDiskOfferingByID(diskOfferingFakeID).Return(&cloudstack.DiskOffering{Iscustomized: true}, 1, nil)
 			Ω(client.GetOrCreateVMInstance(
+				ctx,
 				dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster, dummies.CSFailureDomain1, dummies.CSAffinityGroup, "")).
 				ShouldNot(Succeed())
 		})
@@ -243,6 +256,7 @@ var _ = Describe("Instance", func() {
 			vms.EXPECT().NewListVirtualMachinesParams().Return(&cloudstack.ListVirtualMachinesParams{})
 			vms.EXPECT().ListVirtualMachines(gomock.Any()).Return(&cloudstack.ListVirtualMachinesResponse{}, nil)
 			Ω(client.GetOrCreateVMInstance(
+				ctx,
 				dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster, dummies.CSFailureDomain1, dummies.CSAffinityGroup, "")).
 				Should(MatchError(unknownErrorMessage))
 		})
@@ -271,6 +285,7 @@ var _ = Describe("Instance", func() {
 					}).Return(deploymentResp, nil)
 
 				Ω(client.GetOrCreateVMInstance(
+					ctx,
 					dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster, dummies.CSFailureDomain1, dummies.CSAffinityGroup, "")).
 					Should(Succeed())
 			}
@@ -387,6 +402,7 @@ var _ = Describe("Instance", func() {
 				sos.EXPECT().GetServiceOfferingByID(dummies.CSMachine1.Spec.Offering.ID).Return(&cloudstack.ServiceOffering{Name: "offering-not-match"}, 1, nil)
 				requiredRegexp := "offering name %s does not match name %s returned using UUID %s"
 				Ω(client.GetOrCreateVMInstance(
+					ctx,
 					dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster, dummies.CSFailureDomain1, dummies.CSAffinityGroup, "")).
 					Should(MatchError(MatchRegexp(requiredRegexp, dummies.CSMachine1.Spec.Offering.Name, "offering-not-match", offeringFakeID)))
 			})
@@ -401,6 +417,7 @@ var _ = Describe("Instance", func() {
 				ts.EXPECT().GetTemplateByID(dummies.CSMachine1.Spec.Template.ID, executableFilter).Return(&cloudstack.Template{Name: "template-not-match"}, 1, nil)
 				requiredRegexp := "template name %s does not match name %s returned using UUID %s"
 				Ω(client.GetOrCreateVMInstance(
+					ctx,
 					dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster, dummies.CSFailureDomain1, dummies.CSAffinityGroup, "")).
 					Should(MatchError(MatchRegexp(requiredRegexp, dummies.CSMachine1.Spec.Template.Name, "template-not-match", templateFakeID)))
 
@@ -419,11 +436,123 @@ var _ = Describe("Instance", func() {
 				dos.EXPECT().GetDiskOfferingID(dummies.CSMachine1.Spec.DiskOffering.Name, gomock.Any()).Return(diskOfferingFakeID+"-not-match", 1, nil)
 				requiredRegexp := "diskOffering ID %s does not match ID %s returned using name %s"
 				Ω(client.GetOrCreateVMInstance(
+					ctx,
 					dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster, dummies.CSFailureDomain1, dummies.CSAffinityGroup, "")).
 					Should(MatchError(MatchRegexp(requiredRegexp, dummies.CSMachine1.Spec.DiskOffering.ID, diskOfferingFakeID+"-not-match", dummies.CSMachine1.Spec.DiskOffering.Name)))
 
 			})
 		})
+		Context("when placing a VM on additional networks", func() {
+			const (
+				fdNetFakeID      = "fd-net-id"
+				storageNetFakeID = "storage-net-id"
+				mgmtNetFakeID    = "mgmt-net-id"
+			)
+
+			BeforeEach(func() {
+				dummies.CSFailureDomain1.Spec.Zone.Network.ID = fdNetFakeID
+				dummies.CSMachine1.Spec.Offering = infrav1.CloudStackResourceIdentifier{ID: offeringFakeID}
+				dummies.CSMachine1.Spec.Template = infrav1.CloudStackResourceIdentifier{ID: templateFakeID}
+				dummies.CSMachine1.Spec.DiskOffering = infrav1.CloudStackResourceDiskOffering{}
+				dummies.CSMachine1.Spec.Networks = []infrav1.CloudStackMachineNetwork{
+					{Name: "storage-net"}, {ID: mgmtNetFakeID}}
+
+				vms.EXPECT().GetVirtualMachinesMetricByID(*dummies.CSMachine1.Spec.InstanceID).
+					Return(nil, -1, notFoundError)
+				vms.EXPECT().GetVirtualMachinesMetricByName(dummies.CSMachine1.Name).Return(nil, -1, notFoundError)
+				sos.EXPECT().GetServiceOfferingByID(offeringFakeID).Return(&cloudstack.ServiceOffering{}, 1, nil)
+				ts.EXPECT().GetTemplateByID(templateFakeID, executableFilter).Return(&cloudstack.Template{}, 1, nil)
+				ns.EXPECT().GetNetworkByName("storage-net", gomock.Any()).
+					Return(&cloudstack.Network{Id: storageNetFakeID, Zoneid: dummies.Zone1.ID}, 1, nil)
+			})
+
+			It("attaches a NIC per network with the failure domain's network as the default", func() {
+				ns.EXPECT().GetNetworkByID(mgmtNetFakeID).
+					Return(&cloudstack.Network{Id: mgmtNetFakeID, Name: "mgmt-net", Zoneid: dummies.Zone1.ID}, 1, nil)
+				vms.EXPECT().NewDeployVirtualMachineParams(offeringFakeID, templateFakeID, dummies.Zone1.ID).
+					Return(&cloudstack.DeployVirtualMachineParams{})
+				vms.EXPECT().DeployVirtualMachine(gomock.Any()).Do(
+					func(p interface{}) {
+						networkIDs, _ := p.(*cloudstack.DeployVirtualMachineParams).GetNetworkids()
+						Ω(networkIDs).Should(Equal([]string{fdNetFakeID, storageNetFakeID, mgmtNetFakeID}))
+						_, found := p.(*cloudstack.DeployVirtualMachineParams).GetIptonetworklist()
+						Ω(found).Should(BeFalse())
+					}).Return(nil, unknownError)
+				vms.EXPECT().NewListVirtualMachinesParams().Return(&cloudstack.ListVirtualMachinesParams{})
+				vms.EXPECT().ListVirtualMachines(gomock.Any()).Return(&cloudstack.ListVirtualMachinesResponse{}, nil)
+
+				Ω(client.GetOrCreateVMInstance(
+					ctx,
+					dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster, dummies.CSFailureDomain1, dummies.CSAffinityGroup, "")).
+					Should(MatchError(unknownErrorMessage))
+			})
+
+			It("requests static IPs and puts the default network first", func() {
+				dummies.CSMachine1.Spec.Networks[0].IP = "10.0.0.10"
+				dummies.CSMachine1.Spec.Networks[1].Default = true
+				dummies.CSMachine1.Spec.Networks = append(dummies.CSMachine1.Spec.Networks,
+					infrav1.CloudStackMachineNetwork{ID: fdNetFakeID, IP: "10.1.0.10"})
+
+				ns.EXPECT().GetNetworkByID(mgmtNetFakeID).
+					Return(&cloudstack.Network{Id: mgmtNetFakeID, Name: "mgmt-net", Zoneid: dummies.Zone1.ID}, 1, nil)
+				ns.EXPECT().GetNetworkByID(fdNetFakeID).
+					Return(&cloudstack.Network{Id: fdNetFakeID, Name: "fd-net", Zoneid: dummies.Zone1.ID}, 1, nil)
+				vms.EXPECT().NewDeployVirtualMachineParams(offeringFakeID, templateFakeID, dummies.Zone1.ID).
+					Return(&cloudstack.DeployVirtualMachineParams{})
+				vms.EXPECT().DeployVirtualMachine(gomock.Any()).Do(
+					func(p interface{}) {
+						ipToNetworkList, _ := p.(*cloudstack.DeployVirtualMachineParams).GetIptonetworklist()
+						Ω(ipToNetworkList).Should(Equal([]map[string]string{
+							{"networkid": mgmtNetFakeID},
+							{"networkid": storageNetFakeID, "ip": "10.0.0.10"},
+							{"networkid": fdNetFakeID, "ip": "10.1.0.10"}}))
+						_, found := p.(*cloudstack.DeployVirtualMachineParams).GetNetworkids()
+						Ω(found).Should(BeFalse())
+					}).Return(nil, unknownError)
+				vms.EXPECT().NewListVirtualMachinesParams().Return(&cloudstack.ListVirtualMachinesParams{})
+				vms.EXPECT().ListVirtualMachines(gomock.Any()).Return(&cloudstack.ListVirtualMachinesResponse{}, nil)
+
+				Ω(client.GetOrCreateVMInstance(
+					ctx,
+					dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster, dummies.CSFailureDomain1, dummies.CSAffinityGroup, "")).
+					Should(MatchError(unknownErrorMessage))
+			})
+
+			It("returns errors when a network is outside the failure domain's zone", func() {
+				ns.EXPECT().GetNetworkByID(mgmtNetFakeID).
+					Return(&cloudstack.Network{Id: mgmtNetFakeID, Zoneid: dummies.Zone2.ID}, 1, nil)
+
+				Ω(client.GetOrCreateVMInstance(
+					ctx,
+					dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster, dummies.CSFailureDomain1, dummies.CSAffinityGroup, "")).
+					Should(MatchError(MatchRegexp("network with UUID %s is in zone %s", mgmtNetFakeID, dummies.Zone2.ID)))
+			})
+
+			It("requests the address allocated from an IP pool on the pool's network", func() {
+				dummies.CSMachine1.Status.IPAllocation = &infrav1.CloudStackIPAllocation{
+					Pool: "storage-pool", Network: "storage-net", IP: "10.0.0.20"}
+
+				ns.EXPECT().GetNetworkByID(mgmtNetFakeID).
+					Return(&cloudstack.Network{Id: mgmtNetFakeID, Name: "mgmt-net", Zoneid: dummies.Zone1.ID}, 1, nil)
+				vms.EXPECT().NewDeployVirtualMachineParams(offeringFakeID, templateFakeID, dummies.Zone1.ID).
+					Return(&cloudstack.DeployVirtualMachineParams{})
+				vms.EXPECT().DeployVirtualMachine(gomock.Any()).Do(
+					func(p interface{}) {
+						ipToNetworkList, _ := p.(*cloudstack.DeployVirtualMachineParams).GetIptonetworklist()
+						Ω(ipToNetworkList).Should(Equal([]map[string]string{
+							{"networkid": fdNetFakeID},
+							{"networkid": storageNetFakeID, "ip": "10.0.0.20"},
+							{"networkid": mgmtNetFakeID}}))
+					}).Return(nil, unknownError)
+				vms.EXPECT().NewListVirtualMachinesParams().Return(&cloudstack.ListVirtualMachinesParams{})
+				vms.EXPECT().ListVirtualMachines(gomock.Any()).Return(&cloudstack.ListVirtualMachinesResponse{}, nil)
+
+				Ω(client.GetOrCreateVMInstance(
+					ctx,
+					dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster, dummies.CSFailureDomain1, dummies.CSAffinityGroup, "")).
+					Should(MatchError(unknownErrorMessage))
+			})
+		})
 	})
 
 	Context("when destroying a VM instance", func() {
@@ -449,7 +578,7 @@ var _ = Describe("Instance", func() {
 			vms.EXPECT().DestroyVirtualMachine(expungeDestroyParams).Return(nil, fmt.Errorf("unable to find uuid for id"))
 			vs.EXPECT().NewListVolumesParams().Return(listVolumesParams)
 			vs.EXPECT().ListVolumes(listVolumesParams).Return(listVolumesResponse, nil)
-			Ω(client.DestroyVMInstance(dummies.CSMachine1)).
+			Ω(client.DestroyVMInstance(ctx, dummies.CSMachine1)).
 				Should(Succeed())
 		})
 
@@ -461,7 +590,7 @@ var _ = Describe("Instance", func() {
 			vms.EXPECT().DestroyVirtualMachine(expungeDestroyParams).Return(nil, fmt.Errorf("new error"))
 			vs.EXPECT().NewListVolumesParams().Return(listVolumesParams)
 			vs.EXPECT().ListVolumes(listVolumesParams).Return(listVolumesResponse, nil)
-			Ω(client.DestroyVMInstance(dummies.CSMachine1)).Should(MatchError("new error"))
+			Ω(client.DestroyVMInstance(ctx, dummies.CSMachine1)).Should(MatchError("new error"))
 		})
 
 		It("calls destroy without error but cannot resolve VM after", func() {
@@ -474,7 +603,7 @@ var _ = Describe("Instance", func() {
 			vs.EXPECT().ListVolumes(listVolumesParams).Return(listVolumesResponse, nil)
 			vms.EXPECT().GetVirtualMachinesMetricByID(*dummies.CSMachine1.Spec.InstanceID).Return(nil, -1, notFoundError)
 			vms.EXPECT().GetVirtualMachinesMetricByName(dummies.CSMachine1.Name).Return(nil, -1, notFoundError)
-			Ω(client.DestroyVMInstance(dummies.CSMachine1)).
+			Ω(client.DestroyVMInstance(ctx, dummies.CSMachine1)).
 				Should(Succeed())
 		})
 
@@ -490,7 +619,7 @@ var _ = Describe("Instance", func() {
 				Return(&cloudstack.VirtualMachinesMetric{
 					State: "Expunging",
 				}, 1, nil)
-			Ω(client.DestroyVMInstance(dummies.CSMachine1)).
+			Ω(client.DestroyVMInstance(ctx, dummies.CSMachine1)).
 				Should(Succeed())
 		})
 
@@ -506,7 +635,7 @@ var _ = Describe("Instance", func() {
 				Return(&cloudstack.VirtualMachinesMetric{
 					State: "Expunged",
 				}, 1, nil)
-			Ω(client.DestroyVMInstance(dummies.CSMachine1)).
+			Ω(client.DestroyVMInstance(ctx, dummies.CSMachine1)).
 				Should(Succeed())
 		})
 
@@ -522,7 +651,306 @@ var _ = Describe("Instance", func() {
 				Return(&cloudstack.VirtualMachinesMetric{
 					State: "Stopping",
 				}, 1, nil)
-			Ω(client.DestroyVMInstance(dummies.CSMachine1)).Should(MatchError("VM deletion in progress"))
+			Ω(client.DestroyVMInstance(ctx, dummies.CSMachine1)).Should(MatchError(ContainSubstring("is Stopping after destroying it")))
+		})
+
+		It("records the destroy job while it runs", func() {
+			ajs := mockClient.Asyncjob.(*cloudstack.MockAsyncjobServiceIface)
+			listVolumesParams.SetVirtualmachineid(*dummies.CSMachine1.Spec.InstanceID)
+			listVolumesParams.SetType("DATADISK")
+			vms.EXPECT().NewDestroyVirtualMachineParams(*dummies.CSMachine1.Spec.InstanceID).
+				Return(expungeDestroyParams)
+			vms.EXPECT().DestroyVirtualMachine(expungeDestroyParams).
+				Return(&cloudstack.DestroyVirtualMachineResponse{JobID: "job-id"}, nil)
+			vs.EXPECT().NewListVolumesParams().Return(listVolumesParams)
+			vs.EXPECT().ListVolumes(listVolumesParams).Return(listVolumesResponse, nil)
+			ajs.EXPECT().NewQueryAsyncJobResultParams("job-id").Return(&cloudstack.QueryAsyncJobResultParams{})
+			ajs.EXPECT().QueryAsyncJobResult(gomock.Any()).Return(&cloudstack.QueryAsyncJobResultResponse{Jobstatus: 0}, nil)
+
+			Ω(cloud.IsJobPending(client.DestroyVMInstance(ctx, dummies.CSMachine1))).Should(BeTrue())
+			Ω(dummies.CSMachine1.Status.AsyncJob).ShouldNot(BeNil())
+			Ω(dummies.CSMachine1.Status.AsyncJob.ID).Should(Equal("job-id"))
+			Ω(dummies.CSMachine1.Status.AsyncJob.Command).Should(Equal(cloud.CommandDestroyVM))
+		})
+
+		It("returns the error of a failed destroy job and forgets it", func() {
+			ajs := mockClient.Asyncjob.(*cloudstack.MockAsyncjobServiceIface)
+			dummies.CSMachine1.Status.AsyncJob = &infrav1.AsyncJob{ID: "job-id", Command: cloud.CommandDestroyVM}
+			ajs.EXPECT().NewQueryAsyncJobResultParams("job-id").Return(&cloudstack.QueryAsyncJobResultParams{})
+			ajs.EXPECT().QueryAsyncJobResult(gomock.Any()).Return(&cloudstack.QueryAsyncJobResultResponse{
+				Jobstatus: 2,
+				Jobresult: []byte(`{"errorcode": 530, "errortext": "Failed to destroy vm"}`),
+			}, nil)
+
+			Ω(client.DestroyVMInstance(ctx, dummies.CSMachine1)).Should(MatchError(ContainSubstring("Failed to destroy vm")))
+			Ω(dummies.CSMachine1.Status.AsyncJob).Should(BeNil())
+		})
+	})
+
+	Context("with the simulator", func() {
+		UseSimulator()
+
+		It("deploys a VM once and destroys it along with its data disk", func() {
+			for i := 0; i < 2; i++ {
+				Ω(client.GetOrCreateVMInstance(ctx, dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster,
+					dummies.CSFailureDomain1, dummies.CSAffinityGroup, "userdata")).Should(Succeed())
+			}
+
+			vms := sim.VirtualMachines()
+			Ω(vms).Should(HaveLen(1))
+			Ω(dummies.CSMachine1.Spec.InstanceID).Should(Equal(pointer.String(vms[0].Id)))
+			Ω(dummies.CSMachine1.Status.InstanceState).Should(Equal("Running"))
+			Ω(dummies.CSMachine1.Status.Addresses).ShouldNot(BeEmpty())
+			Ω(sim.UserData(vms[0].Id)).ShouldNot(BeEmpty())
+			Ω(sim.Volumes()).Should(HaveLen(2))
+
+			Ω(client.DestroyVMInstance(ctx, dummies.CSMachine1)).Should(Succeed())
+			Ω(sim.VirtualMachines()).Should(BeEmpty())
+			Ω(sim.Volumes()).Should(BeEmpty())
+
+			// Destroying a VM that's already gone is a no-op.
+			Ω(client.DestroyVMInstance(ctx, dummies.CSMachine1)).Should(Succeed())
+		})
+
+		It("deploys a VM with NICs on additional networks and reports every address", func() {
+			storageNet := sim.AddNetwork(dummies.Zone1.ID, "storage-net", simulator.NetworkTypeShared, "10.30.0.0/24")
+			dummies.CSMachine1.Spec.Networks = []infrav1.CloudStackMachineNetwork{
+				{Name: storageNet.Name, IP: "10.30.0.50"}}
+
+			Ω(client.GetOrCreateVMInstance(ctx, dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster,
+				dummies.CSFailureDomain1, dummies.CSAffinityGroup, "userdata")).Should(Succeed())
+
+			vms := sim.VirtualMachines()
+			Ω(vms).Should(HaveLen(1))
+			Ω(vms[0].Nic).Should(HaveLen(2))
+			Ω(vms[0].Nic[0].Networkid).Should(Equal(dummies.Net1.ID))
+			Ω(vms[0].Nic[0].Isdefault).Should(BeTrue())
+			Ω(vms[0].Nic[1].Networkid).Should(Equal(storageNet.Id))
+			Ω(dummies.CSMachine1.Status.Addresses).Should(Equal([]corev1.NodeAddress{
+				{Type: corev1.NodeInternalIP, Address: vms[0].Nic[0].Ipaddress},
+				{Type: corev1.NodeInternalIP, Address: "10.30.0.50"}}))
+		})
+
+		It("fails to deploy a VM its zone has no room for, leaving it in the Error state", func() {
+			sim.SetZoneCapacity(dummies.Zone1.ID, 0, 16<<30, 14<<30)
+			err := client.GetOrCreateVMInstance(ctx, dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster,
+				dummies.CSFailureDomain1, dummies.CSAffinityGroup, "")
+			Ω(cloud.IsCapacityError(err)).Should(BeTrue())
+			Ω(dummies.CSMachine1.Spec.InstanceID).ShouldNot(BeNil())
+			Ω(dummies.CSMachine1.Status.InstanceState).Should(Equal("Error"))
+			Ω(sim.Volumes()).Should(BeEmpty())
+
+			Ω(client.DestroyVMInstance(ctx, dummies.CSMachine1)).Should(Succeed())
+			Ω(sim.VirtualMachines()).Should(BeEmpty())
+		})
+
+		It("deploys a VM on the tagged host with the most memory left, within the failure domain's cluster", func() {
+			pod := sim.AddPod(dummies.Zone1.ID, "pod1")
+			cluster := sim.AddCluster(pod.Id, "cluster1")
+			sim.AddHost(cluster.Id, "untagged")
+			busy := sim.AddHost(cluster.Id, "busy", "gpu")
+			busy.Memoryallocated = 32 << 30
+			idle := sim.AddHost(cluster.Id, "idle", "ssd,gpu")
+			otherCluster := sim.AddCluster(pod.Id, "cluster2")
+			sim.AddHost(otherCluster.Id, "elsewhere", "gpu")
+			dummies.CSFailureDomain1.Spec.Zone.Cluster = &infrav1.CloudStackResourceIdentifier{ID: cluster.Id}
+			dummies.CSFailureDomain1.Spec.Zone.HostTag = "gpu"
+
+			Ω(client.GetOrCreateVMInstance(ctx, dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster,
+				dummies.CSFailureDomain1, dummies.CSAffinityGroup, "")).Should(Succeed())
+			Ω(sim.VirtualMachines()[0].Hostid).Should(Equal(idle.Id))
+
+			// With no host carrying the tag, there's nowhere to deploy.
+			dummies.CSFailureDomain1.Spec.Zone.HostTag = "fpga"
+			dummies.CSMachine1.Spec.InstanceID = nil
+			dummies.CSMachine1.Name = "another-machine"
+			Ω(client.GetOrCreateVMInstance(ctx, dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster,
+				dummies.CSFailureDomain1, dummies.CSAffinityGroup, "")).Should(MatchError(ContainSubstring("no enabled host tagged fpga")))
+		})
+
+		It("fails to deploy a VM whose failure domain's cluster has no room for it", func() {
+			pod := sim.AddPod(dummies.Zone1.ID, "pod1")
+			full := sim.AddCluster(pod.Id, "full")
+			sim.AddHost(full.Id, "full-host").Memoryallocated = 64 << 30
+			sim.AddHost(sim.AddCluster(pod.Id, "roomy").Id, "roomy-host")
+			dummies.CSFailureDomain1.Spec.Zone.Pod = &infrav1.CloudStackResourceIdentifier{ID: pod.Id}
+			dummies.CSFailureDomain1.Spec.Zone.Cluster = &infrav1.CloudStackResourceIdentifier{ID: full.Id}
+
+			err := client.GetOrCreateVMInstance(ctx, dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster,
+				dummies.CSFailureDomain1, dummies.CSAffinityGroup, "")
+			Ω(cloud.IsCapacityError(err)).Should(BeTrue())
+			Ω(dummies.CSMachine1.Status.InstanceState).Should(Equal("Error"))
+		})
+
+		It("destroys a VM without expunging it, so it can be recovered", func() {
+			dummies.CSMachine1.Spec.DeletionPolicy = infrav1.DeletionPolicyDestroy
+			Ω(client.GetOrCreateVMInstance(ctx, dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster,
+				dummies.CSFailureDomain1, dummies.CSAffinityGroup, "userdata")).Should(Succeed())
+
+			Ω(client.DestroyVMInstance(ctx, dummies.CSMachine1)).Should(Succeed())
+			vms := sim.VirtualMachines()
+			Ω(vms).Should(HaveLen(1))
+			Ω(vms[0].State).Should(Equal("Destroyed"))
+			Ω(dummies.CSMachine1.Status.InstanceState).Should(Equal("Destroyed"))
+			Ω(sim.Volumes()).Should(HaveLen(1)) // The root volume.
+
+			Ω(client.DestroyVMInstance(ctx, dummies.CSMachine1)).Should(Succeed())
+		})
+
+		It("detaches and retains data disks, snapshotting them first if asked to", func() {
+			for _, policy := range []infrav1.DataDiskDeletionPolicy{
+				infrav1.DataDiskDeletionPolicyRetain, infrav1.DataDiskDeletionPolicySnapshotAndRetain} {
+				csMachine := dummies.CSMachine1.DeepCopy()
+				csMachine.Name, csMachine.Spec.InstanceID = string(policy), nil
+				csMachine.Spec.DataDiskDeletionPolicy = policy
+				Ω(client.GetOrCreateVMInstance(ctx, csMachine, dummies.CAPIMachine, dummies.CSCluster,
+					dummies.CSFailureDomain1, dummies.CSAffinityGroup, "userdata")).Should(Succeed())
+				var dataDisk cloudstack.Volume
+				for _, volume := range sim.Volumes() {
+					if volume.Type == simulator.VolumeTypeDataDisk && volume.Virtualmachineid == *csMachine.Spec.InstanceID {
+						dataDisk = volume
+					}
+				}
+
+				Ω(client.DestroyVMInstance(ctx, csMachine)).Should(Succeed())
+				Ω(csMachine.Status.RetainedDataDisks).Should(HaveLen(1))
+				Ω(csMachine.Status.RetainedDataDisks[0].VolumeID).Should(Equal(dataDisk.Id))
+				if policy == infrav1.DataDiskDeletionPolicyRetain {
+					Ω(csMachine.Status.RetainedDataDisks[0].SnapshotID).Should(BeEmpty())
+				} else {
+					snapshots := sim.Snapshots()
+					Ω(snapshots).Should(HaveLen(1))
+					Ω(snapshots[0].Volumeid).Should(Equal(dataDisk.Id))
+					Ω(csMachine.Status.RetainedDataDisks[0].SnapshotID).Should(Equal(snapshots[0].Id))
+				}
+			}
+			Ω(sim.VirtualMachines()).Should(BeEmpty())
+			volumes := sim.Volumes()
+			Ω(volumes).Should(HaveLen(2))
+			for _, volume := range volumes {
+				Ω(volume.Type).Should(Equal(simulator.VolumeTypeDataDisk))
+				Ω(volume.Virtualmachineid).Should(BeEmpty())
+			}
+		})
+
+		It("records the jobs snapshotting and detaching retained data disks in the machine's status until they finish", func() {
+			dummies.CSMachine1.Spec.DataDiskDeletionPolicy = infrav1.DataDiskDeletionPolicySnapshotAndRetain
+			Ω(client.GetOrCreateVMInstance(ctx, dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster,
+				dummies.CSFailureDomain1, dummies.CSAffinityGroup, "userdata")).Should(Succeed())
+
+			for _, command := range []string{cloud.CommandCreateSnapshot, cloud.CommandDetachVolume} {
+				sim.HoldJobs(command)
+				for i := 0; i < 2; i++ {
+					err := client.DestroyVMInstance(ctx, dummies.CSMachine1)
+					Ω(cloud.IsJobPending(err)).Should(BeTrue())
+					Ω(dummies.CSMachine1.Status.AsyncJob.Command).Should(Equal(command))
+				}
+				Ω(sim.RequestCount(command)).Should(Equal(1))
+				Ω(sim.RequestCount("destroyVirtualMachine")).Should(BeZero())
+				sim.ReleaseJobs(command)
+			}
+
+			Ω(client.DestroyVMInstance(ctx, dummies.CSMachine1)).Should(Succeed())
+			Ω(dummies.CSMachine1.Status.AsyncJob).Should(BeNil())
+			Ω(sim.VirtualMachines()).Should(BeEmpty())
+			Ω(dummies.CSMachine1.Status.RetainedDataDisks).Should(HaveLen(1))
+			Ω(dummies.CSMachine1.Status.RetainedDataDisks[0].SnapshotID).Should(Equal(sim.Snapshots()[0].Id))
+		})
+
+		It("snapshots a data disk again once the job snapshotting it failed", func() {
+			dummies.CSMachine1.Spec.DataDiskDeletionPolicy = infrav1.DataDiskDeletionPolicySnapshotAndRetain
+			Ω(client.GetOrCreateVMInstance(ctx, dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster,
+				dummies.CSFailureDomain1, dummies.CSAffinityGroup, "userdata")).Should(Succeed())
+			sim.FailNext(cloud.CommandCreateSnapshot, simulator.NewAPIError(
+				simulator.ErrorCodeInternalError, "Failed to create snapshot"))
+
+			err := client.DestroyVMInstance(ctx, dummies.CSMachine1)
+			Ω(err).Should(MatchError(ContainSubstring("Failed to create snapshot")))
+			Ω(dummies.CSMachine1.Status.AsyncJob).Should(BeNil())
+			Ω(dummies.CSMachine1.Status.RetainedDataDisks).Should(HaveLen(1))
+			Ω(dummies.CSMachine1.Status.RetainedDataDisks[0].SnapshotID).Should(BeEmpty())
+			Ω(sim.VirtualMachines()).Should(HaveLen(1))
+
+			Ω(client.DestroyVMInstance(ctx, dummies.CSMachine1)).Should(Succeed())
+			Ω(sim.RequestCount(cloud.CommandCreateSnapshot)).Should(Equal(2))
+			Ω(sim.Snapshots()).Should(HaveLen(1))
+			Ω(dummies.CSMachine1.Status.RetainedDataDisks[0].SnapshotID).Should(Equal(sim.Snapshots()[0].Id))
+			Ω(sim.VirtualMachines()).Should(BeEmpty())
+		})
+
+		It("resizes a running VM live when both it and the new offering scale dynamically", func() {
+			small := sim.AddScalableServiceOffering("scalable-small", false, 1, 1024)
+			large := sim.AddScalableServiceOffering("scalable-large", false, 4, 8192)
+			dummies.CSMachine1.Spec.Offering = infrav1.CloudStackResourceIdentifier{Name: small.Name}
+			Ω(client.GetOrCreateVMInstance(ctx, dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster,
+				dummies.CSFailureDomain1, dummies.CSAffinityGroup, "userdata")).Should(Succeed())
+			Ω(client.VMInstanceNeedsResize(ctx, dummies.CSMachine1, dummies.CSFailureDomain1)).Should(BeFalse())
+
+			dummies.CSMachine1.Spec.Offering = infrav1.CloudStackResourceIdentifier{Name: large.Name}
+			Ω(client.VMInstanceNeedsResize(ctx, dummies.CSMachine1, dummies.CSFailureDomain1)).Should(BeTrue())
+			Ω(client.ResizeVMInstance(ctx, dummies.CSMachine1, dummies.CSFailureDomain1)).Should(Succeed())
+
+			vm := sim.VirtualMachines()[0]
+			Ω(vm.Serviceofferingid).Should(Equal(large.Id))
+			Ω(vm.Cpunumber).Should(Equal(4))
+			Ω(vm.Memory).Should(Equal(8192))
+			Ω(dummies.CSMachine1.Status.InstanceState).Should(Equal("Running"))
+			Ω(sim.RequestCount("stopVirtualMachine")).Should(BeZero())
+			Ω(client.VMInstanceNeedsResize(ctx, dummies.CSMachine1, dummies.CSFailureDomain1)).Should(BeFalse())
+		})
+
+		It("stops, scales and restarts a VM that can't scale live, applying custom CPU and memory details", func() {
+			custom := sim.AddScalableServiceOffering("custom", true, 1, 1024)
+			Ω(client.GetOrCreateVMInstance(ctx, dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster,
+				dummies.CSFailureDomain1, dummies.CSAffinityGroup, "userdata")).Should(Succeed())
+
+			dummies.CSMachine1.Spec.Offering = infrav1.CloudStackResourceIdentifier{Name: custom.Name}
+			dummies.CSMachine1.Spec.Details["cpuNumber"] = "3"
+			dummies.CSMachine1.Spec.Details["memory"] = "6144"
+
+			// A refused resize leaves the VM running on its old offering.
+			sim.FailNext("scaleVirtualMachine", simulator.NewAPIError(
+				simulator.ErrorCodeInsufficientCapacity, "Not enough capacity to scale the VM"))
+			err := client.ResizeVMInstance(ctx, dummies.CSMachine1, dummies.CSFailureDomain1)
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(ContainSubstring("Not enough capacity to scale the VM"))
+			Ω(sim.VirtualMachines()[0].Serviceofferingid).ShouldNot(Equal(custom.Id))
+			Ω(sim.VirtualMachines()[0].State).Should(Equal("Running"))
+
+			Ω(client.ResizeVMInstance(ctx, dummies.CSMachine1, dummies.CSFailureDomain1)).Should(Succeed())
+			vm := sim.VirtualMachines()[0]
+			Ω(vm.Serviceofferingid).Should(Equal(custom.Id))
+			Ω(vm.Cpunumber).Should(Equal(3))
+			Ω(vm.Memory).Should(Equal(6144))
+			Ω(vm.State).Should(Equal("Running"))
+			Ω(sim.RequestCount("stopVirtualMachine")).Should(Equal(2))
+			Ω(sim.RequestCount("startVirtualMachine")).Should(Equal(2))
+			Ω(client.VMInstanceNeedsResize(ctx, dummies.CSMachine1, dummies.CSFailureDomain1)).Should(BeFalse())
+		})
+
+		It("adopts an existing VM only once it fits the machine", func() {
+			existing := dummies.CSMachine1.DeepCopy()
+			existing.Spec.InstanceID = nil
+			Ω(client.GetOrCreateVMInstance(ctx, existing, dummies.CAPIMachine, dummies.CSCluster,
+				dummies.CSFailureDomain1, dummies.CSAffinityGroup, "userdata")).Should(Succeed())
+			sim.AddServiceOffering("other-offering", 4, 8192)
+
+			dummies.CSMachine1.Spec.InstanceID = nil
+			dummies.CSMachine1.Spec.Offering = infrav1.CloudStackResourceIdentifier{Name: "other-offering"}
+			Ω(client.AdoptVMInstance(ctx, dummies.CSMachine1, dummies.CSCluster, dummies.CSFailureDomain1)).
+				Should(MatchError(ContainSubstring("uses Service Offering")))
+			Ω(dummies.CSMachine1.Spec.InstanceID).Should(BeNil())
+
+			dummies.CSMachine1.Spec.Offering = existing.Spec.Offering
+			Ω(client.AdoptVMInstance(ctx, dummies.CSMachine1, dummies.CSCluster, dummies.CSFailureDomain1)).Should(Succeed())
+			Ω(dummies.CSMachine1.Spec.InstanceID).Should(Equal(existing.Spec.InstanceID))
+			Ω(dummies.CSMachine1.Status.InstanceState).Should(Equal("Running"))
+			Ω(sim.VirtualMachines()).Should(HaveLen(1))
+
+			dummies.CSMachine1.Name = "no-such-vm"
+			dummies.CSMachine1.Spec.InstanceID = nil
+			Ω(client.AdoptVMInstance(ctx, dummies.CSMachine1, dummies.CSCluster, dummies.CSFailureDomain1)).
+				Should(MatchError(ContainSubstring("found no VM instance to adopt")))
 		})
 	})
 })
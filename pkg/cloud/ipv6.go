@@ -0,0 +1,160 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/apache/cloudstack-go/v2/cloudstack"
+	"github.com/pkg/errors"
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
+)
+
+// The seconds to wait for asynchronous jobs started through custom requests, cloudstack-go's default.
+const customAsyncJobTimeout = 300
+
+// ipv6FirewallRule is an IPv6 firewall rule of an isolated network. cloudstack-go doesn't support the IPv6 firewall
+// and routing APIs yet, so they're called as custom requests.
+type ipv6FirewallRule struct {
+	ID          string `json:"id"`
+	Protocol    string `json:"protocol"`
+	StartPort   int    `json:"startport"`
+	EndPort     int    `json:"endport"`
+	CIDRList    string `json:"cidrlist"`
+	TrafficType string `json:"traffictype"`
+}
+
+// ipv6Network holds the IPv6 details of a network that cloudstack-go's Network lacks.
+type ipv6Network struct {
+	IP6CIDR   string `json:"ip6cidr"`
+	IP6Routes []struct {
+		Subnet  string `json:"subnet"`
+		Gateway string `json:"gateway"`
+	} `json:"ip6routes"`
+}
+
+// customRequest calls a CloudStack API cloudstack-go has no method for, waiting for the job of asynchronous APIs.
+func (c *client) customRequest(api string, params map[string]interface{}, async bool, result interface{}) error {
+	custom, ok := c.cs.Custom.(*cloudstack.CustomService)
+	if !ok {
+		return errors.Errorf("calling %s: custom requests aren't supported by this client", api)
+	}
+	p := &cloudstack.CustomServiceParams{}
+	for name, value := range params {
+		p.SetParam(name, value)
+	}
+	if !async {
+		err := custom.CustomRequest(api, p, result)
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return err
+	}
+
+	var job struct {
+		JobID string `json:"jobid"`
+	}
+	if err := custom.CustomRequest(api, p, &job); err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return err
+	}
+	jobResult, err := c.cs.GetAsyncJobResult(job.JobID, customAsyncJobTimeout)
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return err
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(jobResult, result)
+}
+
+// reconcileIPv6 records the IPv6 subnet and routes of a dual-stack isolated network, and makes its IPv6 firewall
+// allow all egress and the allowed CIDRs to reach the API server.
+func (c *client) reconcileIPv6(fd *infrav1.CloudStackFailureDomain, isoNet *infrav1.CloudStackIsolatedNetwork) error {
+	ipv6Spec := fd.Spec.Zone.Network.IPv6
+	if ipv6Spec == nil {
+		return nil
+	}
+
+	var networks struct {
+		Networks []ipv6Network `json:"network"`
+	}
+	if err := c.customRequest("listNetworks", map[string]interface{}{"id": isoNet.Spec.ID}, false, &networks); err != nil {
+		return errors.Wrapf(err, "fetching IPv6 details of network with ID %s", isoNet.Spec.ID)
+	} else if len(networks.Networks) != 1 {
+		return errors.Errorf("expected 1 network with ID %s, but got %d", isoNet.Spec.ID, len(networks.Networks))
+	}
+	network := networks.Networks[0]
+	if network.IP6CIDR == "" {
+		return errors.Errorf("network with ID %s has no IPv6 subnet, its offering must support IPv6", isoNet.Spec.ID)
+	}
+	isoNet.Status.IPv6CIDR = network.IP6CIDR
+	isoNet.Status.IPv6Routes = nil
+	for _, route := range network.IP6Routes {
+		isoNet.Status.IPv6Routes = append(isoNet.Status.IPv6Routes,
+			infrav1.IPv6Route{Subnet: route.Subnet, Gateway: route.Gateway})
+	}
+
+	return errors.Wrap(c.reconcileIPv6FirewallRules(isoNet, ipv6Spec.AllowedCIDRs), "configuring the IPv6 firewall")
+}
+
+// reconcileIPv6FirewallRules opens IPv6 egress, and the API server port to exactly the allowed CIDRs. Rules for other
+// traffic are left alone.
+func (c *client) reconcileIPv6FirewallRules(isoNet *infrav1.CloudStackIsolatedNetwork, allowedCIDRs []string) error {
+	var resp struct {
+		Rules []ipv6FirewallRule `json:"ipv6firewallrule"`
+	}
+	params := map[string]interface{}{"networkid": isoNet.Spec.ID}
+	if err := c.customRequest("listIpv6FirewallRules", params, false, &resp); err != nil {
+		return errors.Wrap(err, "listing IPv6 firewall rules")
+	}
+
+	egressOpen, apiServerOpen := false, false
+	for _, rule := range resp.Rules {
+		if strings.EqualFold(rule.TrafficType, "Egress") {
+			egressOpen = egressOpen || strings.EqualFold(rule.Protocol, "all")
+			continue
+		}
+		if !strings.EqualFold(rule.Protocol, NetworkProtocolTCP) || rule.StartPort != K8sDefaultAPIPort {
+			continue
+		}
+		if len(allowedCIDRs) > 0 && !apiServerOpen && rule.EndPort == rule.StartPort &&
+			sameCIDRs(rule.CIDRList, allowedCIDRs) {
+			apiServerOpen = true
+			continue
+		}
+		if err := c.customRequest("deleteIpv6FirewallRule", map[string]interface{}{"id": rule.ID}, true, nil); err != nil {
+			return errors.Wrapf(err, "deleting IPv6 firewall rule with ID %s", rule.ID)
+		}
+	}
+
+	if !egressOpen {
+		params := map[string]interface{}{
+			"networkid": isoNet.Spec.ID, "protocol": "all", "traffictype": "Egress", "cidrlist": []string{"::/0"}}
+		if err := c.customRequest("createIpv6FirewallRule", params, true, nil); err != nil {
+			return errors.Wrap(err, "opening IPv6 egress")
+		}
+	}
+	if len(allowedCIDRs) > 0 && !apiServerOpen {
+		params := map[string]interface{}{"networkid": isoNet.Spec.ID, "protocol": NetworkProtocolTCP,
+			"startport": K8sDefaultAPIPort, "endport": K8sDefaultAPIPort, "traffictype": "Ingress", "cidrlist": allowedCIDRs}
+		if err := c.customRequest("createIpv6FirewallRule", params, true, nil); err != nil {
+			return errors.Wrap(err, "opening the API server port to IPv6")
+		}
+	}
+	return nil
+}
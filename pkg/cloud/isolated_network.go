@@ -17,6 +17,7 @@ limitations under the License.
 package cloud
 
 import (
+	"context"
 	"strconv"
 	"strings"
 
@@ -27,39 +28,72 @@ import (
 )
 
 type IsoNetworkIface interface {
-	GetOrCreateIsolatedNetwork(*infrav1.CloudStackFailureDomain, *infrav1.CloudStackIsolatedNetwork, *infrav1.CloudStackCluster) error
+	GetOrCreateIsolatedNetwork(context.Context, *infrav1.CloudStackFailureDomain, *infrav1.CloudStackIsolatedNetwork, *infrav1.CloudStackCluster) error
 
-	AssociatePublicIPAddress(*infrav1.CloudStackFailureDomain, *infrav1.CloudStackIsolatedNetwork, *infrav1.CloudStackCluster) error
-	GetOrCreateLoadBalancerRule(*infrav1.CloudStackFailureDomain, *infrav1.CloudStackIsolatedNetwork, *infrav1.CloudStackCluster) error
-	OpenFirewallRules(*infrav1.CloudStackIsolatedNetwork) error
-	GetPublicIP(*infrav1.CloudStackFailureDomain, *infrav1.CloudStackIsolatedNetwork, *infrav1.CloudStackCluster) (*cloudstack.PublicIpAddress, error)
-	ResolveLoadBalancerRuleDetails(*infrav1.CloudStackFailureDomain, *infrav1.CloudStackIsolatedNetwork, *infrav1.CloudStackCluster) error
+	AssociatePublicIPAddress(context.Context, *infrav1.CloudStackFailureDomain, *infrav1.CloudStackIsolatedNetwork, *infrav1.CloudStackCluster) error
+	GetOrCreateLoadBalancerRule(context.Context, *infrav1.CloudStackFailureDomain, *infrav1.CloudStackIsolatedNetwork, *infrav1.CloudStackCluster) error
+	OpenFirewallRules(context.Context, *infrav1.CloudStackIsolatedNetwork) error
+	GetPublicIP(context.Context, *infrav1.CloudStackFailureDomain, *infrav1.CloudStackIsolatedNetwork, *infrav1.CloudStackCluster) (*cloudstack.PublicIpAddress, error)
+	ResolveLoadBalancerRuleDetails(context.Context, *infrav1.CloudStackFailureDomain, *infrav1.CloudStackIsolatedNetwork, *infrav1.CloudStackCluster) error
 
-	AssignVMToLoadBalancerRule(isoNet *infrav1.CloudStackIsolatedNetwork, instanceID string) error
-	DeleteNetwork(infrav1.Network) error
-	DisposeIsoNetResources(*infrav1.CloudStackFailureDomain, *infrav1.CloudStackIsolatedNetwork, *infrav1.CloudStackCluster) error
+	AssignVMToLoadBalancerRule(ctx context.Context, isoNet *infrav1.CloudStackIsolatedNetwork, instanceID string) error
+	DeleteNetwork(context.Context, infrav1.Network) error
+	DisposeIsoNetResources(context.Context, *infrav1.CloudStackFailureDomain, *infrav1.CloudStackIsolatedNetwork, *infrav1.CloudStackCluster) error
 }
 
-// getOfferingID fetches an offering id.
-func (c *client) getOfferingID() (string, error) {
-	offeringID, count, retErr := c.cs.NetworkOffering.GetNetworkOfferingID(NetOffering)
-	if retErr != nil {
-		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(retErr)
-		return "", retErr
+// resolveNetworkOffering fetches the ID of a network offering given by ID or name, or of the named default offering.
+func (c *client) resolveNetworkOffering(offering *infrav1.CloudStackResourceIdentifier, defaultName string) (string, error) {
+	if offering != nil && offering.ID != "" {
+		csOffering, count, err := c.cs.NetworkOffering.GetNetworkOfferingByID(offering.ID)
+		if err != nil {
+			c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+			return "", errors.Wrapf(err, "could not get network offering by ID %s", offering.ID)
+		} else if count != 1 {
+			return "", errors.Errorf("expected 1 network offering with UUID %s, but got %d", offering.ID, count)
+		} else if offering.Name != "" && offering.Name != csOffering.Name {
+			return "", errors.Errorf("network offering name %s does not match name %s returned using UUID %s",
+				offering.Name, csOffering.Name, offering.ID)
+		}
+		return offering.ID, nil
+	}
+
+	name := defaultName
+	if offering != nil && offering.Name != "" {
+		name = offering.Name
+	}
+	offeringID, count, err := c.cs.NetworkOffering.GetNetworkOfferingID(name)
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return "", errors.Wrapf(err, "could not get network offering ID from %s", name)
 	} else if count != 1 {
-		return "", errors.New("found more than one network offering")
+		return "", errors.Errorf("expected 1 network offering with name %s, but got %d", name, count)
 	}
 	return offeringID, nil
 }
 
-// AssociatePublicIPAddress Gets a PublicIP and associates the public IP to passed isolated network.
+// AssociatePublicIPAddress Gets a PublicIP and associates the public IP to passed isolated network. Associating is
+// started as an async job recorded in the network's status, which is polled on later calls until it's done.
 func (c *client) AssociatePublicIPAddress(
+	ctx context.Context,
 	fd *infrav1.CloudStackFailureDomain,
 	isoNet *infrav1.CloudStackIsolatedNetwork,
 	csCluster *infrav1.CloudStackCluster,
 ) (retErr error) {
+	c, ctx, cancel := c.withTimeout(ctx, OperationNetwork)
+	defer cancel()
+
+	// Finish associating the address, if that's running.
+	if job := isoNet.Status.AsyncJob; job != nil && job.Command == CommandAssociateIPAddress {
+		if err := c.pollAsyncJob(&isoNet.Status.AsyncJob); err != nil {
+			return errors.Wrapf(err,
+				"associating public IP address with ID %s to network with ID %s",
+				isoNet.Status.PublicIPID, isoNet.Spec.ID)
+		}
+		return c.tagAssociatedPublicIP(ctx, isoNet.Status.PublicIPID, csCluster)
+	}
+
 	// Check specified IP address is available or get an unused one if not specified.
-	publicAddress, err := c.GetPublicIP(fd, isoNet, csCluster)
+	publicAddress, err := c.GetPublicIP(ctx, fd, isoNet, csCluster)
 	if err != nil {
 		return errors.Wrapf(err, "fetching a public IP address")
 	}
@@ -67,66 +101,119 @@ func (c *client) AssociatePublicIPAddress(
 	csCluster.Spec.ControlPlaneEndpoint.Host = publicAddress.Ipaddress
 	isoNet.Status.PublicIPID = publicAddress.Id
 
-	// Check if the address is already associated with the network.
-	if publicAddress.Associatednetworkid == isoNet.Spec.ID {
-		return nil
+	// Check if the address is already associated with the network, or the VPC of a tier.
+	if publicAddress.Associatednetworkid == isoNet.Spec.ID ||
+		(isoNet.Spec.VPCID != "" && publicAddress.Vpcid == isoNet.Spec.VPCID) {
+		// Addresses associated before they were tagged as created by CAPC lack the cluster tag.
+		return errors.Wrapf(c.AddClusterTag(ctx, ResourceTypeIPAddress, publicAddress.Id, csCluster),
+			"adding tag to public IP address with ID %s", publicAddress.Id)
 	}
 
-	// Public IP found, but not yet associated with network -- associate it.
-	p := c.cs.Address.NewAssociateIpAddressParams()
+	// Public IP found, but not yet associated with network -- associate it. A VPC's addresses are associated with the
+	// VPC, and with the tier once a load balancer rule forwards to it.
+	p := c.csAsync.Address.NewAssociateIpAddressParams()
 	p.SetIpaddress(isoNet.Spec.ControlPlaneEndpoint.Host)
-	p.SetNetworkid(isoNet.Spec.ID)
-	if _, err := c.cs.Address.AssociateIpAddress(p); err != nil {
+	if isoNet.Spec.VPCID != "" {
+		p.SetVpcid(isoNet.Spec.VPCID)
+	} else {
+		p.SetNetworkid(isoNet.Spec.ID)
+	}
+	resp, err := c.csAsync.Address.AssociateIpAddress(p)
+	if err != nil {
 		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+	} else if resp != nil {
+		err = c.startAsyncJob(&isoNet.Status.AsyncJob, CommandAssociateIPAddress, resp.JobID)
+	}
+	if err != nil {
 		return errors.Wrapf(err,
 			"associating public IP address with ID %s to network with ID %s",
 			publicAddress.Id, isoNet.Spec.ID)
-	} else if err := c.AddClusterTag(ResourceTypeIPAddress, publicAddress.Id, csCluster); err != nil {
+	}
+	return c.tagAssociatedPublicIP(ctx, publicAddress.Id, csCluster)
+}
+
+// tagAssociatedPublicIP tags a public IP address CAPC associated as created by CAPC, and with the cluster's tag.
+func (c *client) tagAssociatedPublicIP(ctx context.Context, id string, csCluster *infrav1.CloudStackCluster) error {
+	if err := c.AddCreatedByCAPCTag(ctx, ResourceTypeIPAddress, id); err != nil {
 		return errors.Wrapf(err,
-			"adding tag to public IP address with ID %s", publicAddress.Id)
-	} else if err := c.AddCreatedByCAPCTag(ResourceTypeIPAddress, isoNet.Status.PublicIPID); err != nil {
+			"adding tag to public IP address with ID %s", id)
+	} else if err := c.AddClusterTag(ctx, ResourceTypeIPAddress, id, csCluster); err != nil {
 		return errors.Wrapf(err,
-			"adding tag to public IP address with ID %s", publicAddress.Id)
+			"adding tag to public IP address with ID %s", id)
 	}
 	return nil
 }
 
 // CreateIsolatedNetwork creates an isolated network in the relevant FailureDomain per passed network specification.
-func (c *client) CreateIsolatedNetwork(fd *infrav1.CloudStackFailureDomain, isoNet *infrav1.CloudStackIsolatedNetwork) (retErr error) {
+func (c *client) CreateIsolatedNetwork(ctx context.Context, fd *infrav1.CloudStackFailureDomain, isoNet *infrav1.CloudStackIsolatedNetwork) (retErr error) {
+	netSpec := fd.Spec.Zone.Network
+
 	// Get network offering ID.
-	offeringID, err := c.getOfferingID()
+	offeringID, err := c.resolveNetworkOffering(netSpec.Offering, NetOffering)
 	if err != nil {
 		return err
 	}
 
 	// Do isolated network creation.
 	p := c.cs.Network.NewCreateNetworkParams(isoNet.Spec.Name, isoNet.Spec.Name, offeringID, fd.Spec.Zone.ID)
+	if netSpec.CIDR != "" {
+		gateway, netmask, err := gatewayAndNetmask(netSpec.CIDR, netSpec.Gateway)
+		if err != nil {
+			return err
+		}
+		p.SetGateway(gateway)
+		p.SetNetmask(netmask)
+	}
+	setIfNotEmpty(netSpec.Domain, p.SetNetworkdomain)
+	setIfNotEmpty(netSpec.VLAN, p.SetVlan)
 	resp, err := c.cs.Network.CreateNetwork(p)
 	if err != nil {
 		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
 		return errors.Wrapf(err, "creating network with name %s", isoNet.Spec.Name)
 	}
 	isoNet.Spec.ID = resp.Id
-	return c.AddCreatedByCAPCTag(ResourceTypeNetwork, isoNet.Spec.ID)
+	return c.AddCreatedByCAPCTag(ctx, ResourceTypeNetwork, isoNet.Spec.ID)
 }
 
-// OpenFirewallRules opens a CloudStack firewall for an isolated network.
-func (c *client) OpenFirewallRules(isoNet *infrav1.CloudStackIsolatedNetwork) (retErr error) {
-	p := c.cs.Firewall.NewCreateEgressFirewallRuleParams(isoNet.Spec.ID, NetworkProtocolTCP)
-	_, retErr = c.cs.Firewall.CreateEgressFirewallRule(p)
+// OpenFirewallRules opens a CloudStack firewall for an isolated network. Opening it is started as an async job
+// recorded in the network's status.
+func (c *client) OpenFirewallRules(ctx context.Context, isoNet *infrav1.CloudStackIsolatedNetwork) (retErr error) {
+	c, _, cancel := c.withTimeout(ctx, OperationNetwork)
+	defer cancel()
+
+	p := c.csAsync.Firewall.NewCreateEgressFirewallRuleParams(isoNet.Spec.ID, NetworkProtocolTCP)
+	resp, retErr := c.csAsync.Firewall.CreateEgressFirewallRule(p)
+	if retErr == nil {
+		retErr = c.startAsyncJob(&isoNet.Status.AsyncJob, CommandCreateEgressFirewallRule, resp.JobID)
+	} else {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(retErr)
+	}
 	if retErr != nil && strings.Contains(strings.ToLower(retErr.Error()), "there is already") { // Already a firewall rule here.
 		retErr = nil
 	}
-	c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(retErr)
 	return retErr
 }
 
+// pollNetworkJob polls the job a network left running for its firewall or load balancer rules. The job associating its
+// public IP address is left to AssociatePublicIPAddress, which tags the address once the job is done.
+func (c *client) pollNetworkJob(isoNet *infrav1.CloudStackIsolatedNetwork) error {
+	job := isoNet.Status.AsyncJob
+	if job == nil || job.Command == CommandAssociateIPAddress {
+		return nil
+	}
+	return errors.Wrapf(c.pollAsyncJob(&isoNet.Status.AsyncJob), "setting up network %s", isoNet.Spec.Name)
+}
+
 // GetPublicIP gets a public IP with ID for cluster endpoint.
 func (c *client) GetPublicIP(
+	ctx context.Context,
 	fd *infrav1.CloudStackFailureDomain,
 	isoNet *infrav1.CloudStackIsolatedNetwork,
 	csCluster *infrav1.CloudStackCluster,
 ) (*cloudstack.PublicIpAddress, error) {
+	c, _, cancel := c.withTimeout(ctx, OperationResolve)
+	defer cancel()
+
 	ip := csCluster.Spec.ControlPlaneEndpoint.Host
 
 	p := c.cs.Address.NewListPublicIpAddressesParams()
@@ -145,6 +232,10 @@ func (c *client) GetPublicIP(
 		for _, v := range publicAddresses.PublicIpAddresses { // Pick first available address.
 			if v.Allocated != "" && v.Associatednetworkid == isoNet.Spec.ID && !v.Issourcenat { // IP Already allocated.
 				return v, nil
+			} else if v.Allocated != "" && isoNet.Spec.VPCID != "" && v.Vpcid == isoNet.Spec.VPCID &&
+				v.Associatednetworkid == "" && !v.Issourcenat && hasTag(v.Tags, CreatedByCAPCTagName) {
+				// Allocated to the VPC by CAPC, but no rule forwards to a tier yet.
+				return v, nil
 			}
 		}
 		for _, v := range publicAddresses.PublicIpAddresses { // Pick first available address.
@@ -184,10 +275,14 @@ func (c *client) GetIsolatedNetwork(isoNet *infrav1.CloudStackIsolatedNetwork) (
 
 // ResolveLoadBalancerRuleDetails resolves the details of a load balancer rule by PublicIPID and Port.
 func (c *client) ResolveLoadBalancerRuleDetails(
+	ctx context.Context,
 	fd *infrav1.CloudStackFailureDomain,
 	isoNet *infrav1.CloudStackIsolatedNetwork,
 	csCluster *infrav1.CloudStackCluster,
 ) error {
+	c, _, cancel := c.withTimeout(ctx, OperationResolve)
+	defer cancel()
+
 	p := c.cs.LoadBalancer.NewListLoadBalancerRulesParams()
 	p.SetPublicipid(isoNet.Status.PublicIPID)
 	loadBalancerRules, err := c.cs.LoadBalancer.ListLoadBalancerRules(p)
@@ -207,10 +302,14 @@ func (c *client) ResolveLoadBalancerRuleDetails(
 
 // GetOrCreateLoadBalancerRule Create a load balancer rule that can be assigned to instances.
 func (c *client) GetOrCreateLoadBalancerRule(
+	ctx context.Context,
 	fd *infrav1.CloudStackFailureDomain,
 	isoNet *infrav1.CloudStackIsolatedNetwork,
 	csCluster *infrav1.CloudStackCluster,
 ) (retErr error) {
+	c, _, cancel := c.withTimeout(ctx, OperationNetwork)
+	defer cancel()
+
 	// Check/set ports.
 	// Prefer control plane endpoint. Take iso net port if CP missing. Set to default if both missing.
 	if csCluster.Spec.ControlPlaneEndpoint.Port != 0 {
@@ -223,37 +322,51 @@ func (c *client) GetOrCreateLoadBalancerRule(
 	}
 
 	// Check if rule exists.
-	if err := c.ResolveLoadBalancerRuleDetails(fd, isoNet, csCluster); err == nil ||
-		!strings.Contains(strings.ToLower(err.Error()), "no load balancer rule found") {
+	rules, err := c.listLoadBalancerRules(isoNet)
+	if err != nil {
 		return errors.Wrap(err, "resolving load balancer rule details")
 	}
-
-	p := c.cs.LoadBalancer.NewCreateLoadBalancerRuleParams(
-		"roundrobin", "Kubernetes_API_Server", K8sDefaultAPIPort, K8sDefaultAPIPort)
-	p.SetPublicport(int(csCluster.Spec.ControlPlaneEndpoint.Port))
-	p.SetNetworkid(isoNet.Spec.ID)
-
-	p.SetPublicipid(isoNet.Status.PublicIPID)
-	p.SetProtocol(NetworkProtocolTCP)
-	resp, err := c.cs.LoadBalancer.CreateLoadBalancerRule(p)
-	if err != nil {
-		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
-		return err
+	if rule, found := rules[int(csCluster.Spec.ControlPlaneEndpoint.Port)]; found {
+		isoNet.Status.LBRuleID = rule.Id
+	} else {
+		managedFirewall := csCluster.ControlPlaneEndpointProviderFor(&fd.Spec) != infrav1.EndpointProviderNetworkLoadBalancer
+		ruleID, err := c.createLoadBalancerRule(&isoNet.Status.AsyncJob, isoNet, csCluster.Spec.LoadBalancer,
+			managedFirewall, APIServerLBRuleName, int(csCluster.Spec.ControlPlaneEndpoint.Port), K8sDefaultAPIPort,
+			csCluster.Spec.LoadBalancer.AllowedCIDRsFor(nil))
+		if ruleID != "" {
+			isoNet.Status.LBRuleID = ruleID
+		}
+		if err != nil {
+			return err
+		}
 	}
-	isoNet.Status.LBRuleID = resp.Id
-	return nil
+
+	// Apply the rest of the load balancer configuration.
+	return c.reconcileLoadBalancer(fd, isoNet, csCluster, rules)
 }
 
 // GetOrCreateIsolatedNetwork fetches or builds out the necessary structures for isolated network use.
 func (c *client) GetOrCreateIsolatedNetwork(
+	ctx context.Context,
 	fd *infrav1.CloudStackFailureDomain,
 	isoNet *infrav1.CloudStackIsolatedNetwork,
 	csCluster *infrav1.CloudStackCluster,
 ) error {
+	c, ctx, cancel := c.withTimeout(ctx, OperationNetwork)
+	defer cancel()
+
+	if err := c.pollNetworkJob(isoNet); err != nil {
+		return err
+	}
+
 	// Get or create the isolated network itself and resolve details into passed custom resources.
 	net := isoNet.Network()
-	if err := c.ResolveNetwork(net); err != nil { // Doesn't exist, create isolated network.
-		if err = c.CreateIsolatedNetwork(fd, isoNet); err != nil {
+	if fd.Spec.Zone.Network.Type == NetworkTypeVPC { // The network is a tier of a VPC.
+		if err := c.getOrCreateVPCTier(ctx, fd, isoNet, csCluster); err != nil {
+			return errors.Wrap(err, "getting or creating a VPC tier")
+		}
+	} else if err := c.ResolveNetwork(ctx, net); err != nil { // Doesn't exist, create isolated network.
+		if err = c.CreateIsolatedNetwork(ctx, fd, isoNet); err != nil {
 			return errors.Wrap(err, "creating a new isolated network")
 		}
 	} else { // Network existed and was resolved. Set ID on isoNet CloudStackIsolatedNetwork in case it only had name set.
@@ -262,30 +375,53 @@ func (c *client) GetOrCreateIsolatedNetwork(
 
 	// Tag the created network.
 	networkID := isoNet.Spec.ID
-	if err := c.AddClusterTag(ResourceTypeNetwork, networkID, csCluster); err != nil {
+	if err := c.AddClusterTag(ctx, ResourceTypeNetwork, networkID, csCluster); err != nil {
 		return errors.Wrapf(err, "tagging network with id %s", networkID)
 	}
 
-	// Associate Public IP with CloudStackIsolatedNetwork
-	if err := c.AssociatePublicIPAddress(fd, isoNet, csCluster); err != nil {
-		return errors.Wrapf(err, "associating public IP address to csCluster")
+	// Setup the control plane endpoint on the isolated network's public IP, unless something else provides it.
+	if provider := csCluster.Spec.ControlPlaneEndpointProvider; provider == "" || provider == infrav1.EndpointProviderIsolatedNetwork {
+		endpoint := &isolatedNetworkEndpoint{c: c, fd: fd, isoNet: isoNet, csCluster: csCluster}
+		if err := endpoint.GetOrCreateControlPlaneEndpoint(ctx); err != nil {
+			return err
+		}
 	}
 
-	// Setup a load balancing rule to map VMs to Public IP.
-	if err := c.GetOrCreateLoadBalancerRule(fd, isoNet, csCluster); err != nil {
-		return errors.Wrap(err, "getting or creating load balancing rule")
+	// Put the cluster's additional tags on what CAPC created for the network.
+	if err := c.reconcileAdditionalTags(ctx, ResourceTypeNetwork, networkID, csCluster); err != nil {
+		return errors.Wrapf(err, "tagging network with id %s", networkID)
+	}
+	if isoNet.Status.PublicIPID != "" {
+		if err := c.reconcileAdditionalTags(ctx, ResourceTypeIPAddress, isoNet.Status.PublicIPID, csCluster); err != nil {
+			return errors.Wrapf(err, "tagging public IP address with id %s", isoNet.Status.PublicIPID)
+		}
+	}
+
+	// VPC tiers have no egress firewall. Their network ACL list governs egress instead.
+	if isoNet.Spec.VPCID != "" {
+		return errors.Wrapf(c.reconcileAdditionalTags(ctx, ResourceTypeVPC, isoNet.Spec.VPCID, csCluster),
+			"tagging VPC with id %s", isoNet.Spec.VPCID)
 	}
 
 	//  Open the Isolated Network on endopint port.
-	return errors.Wrap(c.OpenFirewallRules(isoNet), "opening the isolated network's firewall")
+	if err := c.OpenFirewallRules(ctx, isoNet); err != nil {
+		return errors.Wrap(err, "opening the isolated network's firewall")
+	}
+	return c.reconcileIPv6(fd, isoNet)
 }
 
 // AssignVMToLoadBalancerRule assigns a VM instance to a load balancing rule (specifying lb membership).
-func (c *client) AssignVMToLoadBalancerRule(isoNet *infrav1.CloudStackIsolatedNetwork, instanceID string) (retErr error) {
+func (c *client) AssignVMToLoadBalancerRule(ctx context.Context, isoNet *infrav1.CloudStackIsolatedNetwork, instanceID string) (retErr error) {
+	c, _, cancel := c.withTimeout(ctx, OperationNetwork)
+	defer cancel()
 
+	return c.assignVMToLoadBalancerRuleID(isoNet.Status.LBRuleID, instanceID)
+}
+
+func (c *client) assignVMToLoadBalancerRuleID(ruleID string, instanceID string) (retErr error) {
 	// Check that the instance isn't already in LB rotation.
 	lbRuleInstances, retErr := c.cs.LoadBalancer.ListLoadBalancerRuleInstances(
-		c.cs.LoadBalancer.NewListLoadBalancerRuleInstancesParams(isoNet.Status.LBRuleID))
+		c.cs.LoadBalancer.NewListLoadBalancerRuleInstancesParams(ruleID))
 	if retErr != nil {
 		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(retErr)
 		return retErr
@@ -297,7 +433,7 @@ func (c *client) AssignVMToLoadBalancerRule(isoNet *infrav1.CloudStackIsolatedNe
 	}
 
 	// Assign to Load Balancer.
-	p := c.cs.LoadBalancer.NewAssignToLoadBalancerRuleParams(isoNet.Status.LBRuleID)
+	p := c.cs.LoadBalancer.NewAssignToLoadBalancerRuleParams(ruleID)
 	p.SetVirtualmachineids([]string{instanceID})
 	_, retErr = c.cs.LoadBalancer.AssignToLoadBalancerRule(p)
 	c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(retErr)
@@ -305,7 +441,10 @@ func (c *client) AssignVMToLoadBalancerRule(isoNet *infrav1.CloudStackIsolatedNe
 }
 
 // DeleteNetwork deletes an isolated network.
-func (c *client) DeleteNetwork(net infrav1.Network) error {
+func (c *client) DeleteNetwork(ctx context.Context, net infrav1.Network) error {
+	c, _, cancel := c.withTimeout(ctx, OperationNetwork)
+	defer cancel()
+
 	_, err := c.cs.Network.DeleteNetwork(c.cs.Network.NewDeleteNetworkParams(net.ID))
 	c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
 	return errors.Wrapf(err, "deleting network with id %s", net.ID)
@@ -313,31 +452,39 @@ func (c *client) DeleteNetwork(net infrav1.Network) error {
 
 // DisposeIsoNetResources cleans up isolated network resources.
 func (c *client) DisposeIsoNetResources(
+	ctx context.Context,
 	zone *infrav1.CloudStackFailureDomain,
 	isoNet *infrav1.CloudStackIsolatedNetwork,
 	csCluster *infrav1.CloudStackCluster,
 ) (retError error) {
-	if isoNet.Status.PublicIPID != "" {
-		if err := c.DeleteClusterTag(ResourceTypeIPAddress, isoNet.Status.PublicIPID, csCluster); err != nil {
-			return err
-		}
-		if err := c.DisassociatePublicIPAddressIfNotInUse(isoNet); err != nil {
-			return err
-		}
+	c, ctx, cancel := c.withTimeout(ctx, OperationNetwork)
+	defer cancel()
+
+	if released, err := c.pollDisassociation(isoNet); err != nil {
+		return err
+	} else if released {
+		isoNet.Status.PublicIPID = ""
+	}
+	endpoint := &isolatedNetworkEndpoint{c: c, fd: zone, isoNet: isoNet, csCluster: csCluster}
+	if err := endpoint.DisposeControlPlaneEndpoint(ctx); err != nil {
+		return err
 	}
-	if err := c.RemoveClusterTagFromNetwork(csCluster, *isoNet.Network()); err != nil {
+	if err := c.RemoveClusterTagFromNetwork(ctx, csCluster, *isoNet.Network()); err != nil {
 		return err
 	}
-	if err := c.DeleteNetworkIfNotInUse(csCluster, *isoNet.Network()); err != nil {
+	if err := c.DeleteNetworkIfNotInUse(ctx, csCluster, *isoNet.Network()); err != nil {
 		return err
 	}
+	if isoNet.Spec.VPCID != "" {
+		return c.disposeVPCResources(ctx, isoNet, csCluster)
+	}
 
 	return nil
 }
 
 // DeleteNetworkIfNotInUse deletes an isolated network if the network is no longer in use (indicated by in use tags).
-func (c *client) DeleteNetworkIfNotInUse(csCluster *infrav1.CloudStackCluster, net infrav1.Network) (retError error) {
-	tags, err := c.GetTags(ResourceTypeNetwork, net.ID)
+func (c *client) DeleteNetworkIfNotInUse(ctx context.Context, csCluster *infrav1.CloudStackCluster, net infrav1.Network) (retError error) {
+	tags, err := c.GetTags(ctx, ResourceTypeNetwork, net.ID)
 	if err != nil {
 		return err
 	}
@@ -350,7 +497,7 @@ func (c *client) DeleteNetworkIfNotInUse(csCluster *infrav1.CloudStackCluster, n
 	}
 
 	if clusterTagCount == 0 && tags[CreatedByCAPCTagName] != "" {
-		return c.DeleteNetwork(net)
+		return c.DeleteNetwork(ctx, net)
 	}
 
 	return nil
@@ -358,8 +505,8 @@ func (c *client) DeleteNetworkIfNotInUse(csCluster *infrav1.CloudStackCluster, n
 
 // DisassociatePublicIPAddressIfNotInUse removes a CloudStack public IP association from passed isolated network
 // if it is no longer in use (indicated by in use tags).
-func (c *client) DisassociatePublicIPAddressIfNotInUse(isoNet *infrav1.CloudStackIsolatedNetwork) (retError error) {
-	if tagsAllowDisposal, err := c.DoClusterTagsAllowDisposal(ResourceTypeIPAddress, isoNet.Status.PublicIPID); err != nil {
+func (c *client) DisassociatePublicIPAddressIfNotInUse(ctx context.Context, isoNet *infrav1.CloudStackIsolatedNetwork) (retError error) {
+	if tagsAllowDisposal, err := c.DoClusterTagsAllowDisposal(ctx, ResourceTypeIPAddress, isoNet.Status.PublicIPID); err != nil {
 		return err
 	} else if publicIP, _, err := c.cs.Address.GetPublicIpAddressByID(isoNet.Status.PublicIPID); err != nil {
 		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
@@ -367,21 +514,38 @@ func (c *client) DisassociatePublicIPAddressIfNotInUse(isoNet *infrav1.CloudStac
 	} else if publicIP == nil || publicIP.Issourcenat { // Can't disassociate an address if it's the source NAT address.
 		return nil
 	} else if tagsAllowDisposal {
-		return c.DisassociatePublicIPAddress(isoNet)
+		return c.DisassociatePublicIPAddress(ctx, isoNet)
 	}
 	return nil
 }
 
-// DisassociatePublicIPAddress removes a CloudStack public IP association from passed isolated network.
-func (c *client) DisassociatePublicIPAddress(isoNet *infrav1.CloudStackIsolatedNetwork) (retErr error) {
+// DisassociatePublicIPAddress removes a CloudStack public IP association from passed isolated network. Disassociating
+// is started as an async job recorded in the network's status, which pollDisassociation finishes.
+func (c *client) DisassociatePublicIPAddress(ctx context.Context, isoNet *infrav1.CloudStackIsolatedNetwork) (retErr error) {
 	// Remove the CAPC creation tag, so it won't be there the next time this address is associated.
-	retErr = c.DeleteCreatedByCAPCTag(ResourceTypeIPAddress, isoNet.Status.PublicIPID)
+	retErr = c.DeleteCreatedByCAPCTag(ctx, ResourceTypeIPAddress, isoNet.Status.PublicIPID)
 	if retErr != nil {
 		return retErr
 	}
 
-	p := c.cs.Address.NewDisassociateIpAddressParams(isoNet.Status.PublicIPID)
-	_, retErr = c.cs.Address.DisassociateIpAddress(p)
-	c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(retErr)
-	return retErr
+	p := c.csAsync.Address.NewDisassociateIpAddressParams(isoNet.Status.PublicIPID)
+	resp, retErr := c.csAsync.Address.DisassociateIpAddress(p)
+	if retErr != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(retErr)
+		return retErr
+	}
+	return c.startAsyncJob(&isoNet.Status.AsyncJob, CommandDisassociateIPAddress, resp.JobID)
+}
+
+// pollDisassociation polls the job disassociating a network's public IP address, if one was started. It reports
+// whether the address was released.
+func (c *client) pollDisassociation(isoNet *infrav1.CloudStackIsolatedNetwork) (bool, error) {
+	job := isoNet.Status.AsyncJob
+	if job == nil || job.Command != CommandDisassociateIPAddress {
+		return false, nil
+	}
+	if err := c.pollAsyncJob(&isoNet.Status.AsyncJob); err != nil {
+		return false, errors.Wrapf(err, "disassociating the public IP address of network %s", isoNet.Spec.Name)
+	}
+	return true, nil
 }
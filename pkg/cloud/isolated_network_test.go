@@ -18,14 +18,18 @@ package cloud_test
 
 import (
 	"strconv"
+	"strings"
 
 	csapi "github.com/apache/cloudstack-go/v2/cloudstack"
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
 	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
 	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta2"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/test/simulator"
 )
 
 var _ = Describe("Network", func() {
@@ -45,7 +49,6 @@ var _ = Describe("Network", func() {
 		as         *csapi.MockAddressServiceIface
 		lbs        *csapi.MockLoadBalancerServiceIface
 		rs         *csapi.MockResourcetagsServiceIface
-		client     cloud.Client
 	)
 
 	BeforeEach(func() {
@@ -107,7 +110,7 @@ var _ = Describe("Network", func() {
 				&csapi.ListLoadBalancerRulesResponse{LoadBalancerRules: []*csapi.LoadBalancerRule{
 					{Publicport: strconv.Itoa(int(dummies.EndPointPort)), Id: dummies.LBRuleID}}}, nil)
 
-			Ω(client.GetOrCreateIsolatedNetwork(dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).Should(Succeed())
+			Ω(client.GetOrCreateIsolatedNetwork(ctx, dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).Should(Succeed())
 		})
 
 		It("fails to get network offering from CloudStack", func() {
@@ -115,7 +118,7 @@ var _ = Describe("Network", func() {
 			ns.EXPECT().GetNetworkByID(dummies.ISONet1.ID).Return(nil, 0, nil)
 			nos.EXPECT().GetNetworkOfferingID(gomock.Any()).Return("", -1, fakeError)
 
-			err := client.GetOrCreateIsolatedNetwork(dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)
+			err := client.GetOrCreateIsolatedNetwork(ctx, dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)
 			Ω(err).ShouldNot(Succeed())
 			Ω(err.Error()).Should(ContainSubstring("creating a new isolated network"))
 		})
@@ -129,7 +132,7 @@ var _ = Describe("Network", func() {
 			fs.EXPECT().CreateEgressFirewallRule(&csapi.CreateEgressFirewallRuleParams{}).
 				Return(&csapi.CreateEgressFirewallRuleResponse{}, nil)
 
-			Ω(client.OpenFirewallRules(dummies.CSISONet1)).Should(Succeed())
+			Ω(client.OpenFirewallRules(ctx, dummies.CSISONet1)).Should(Succeed())
 		})
 	})
 
@@ -142,7 +145,7 @@ var _ = Describe("Network", func() {
 			fs.EXPECT().CreateEgressFirewallRule(&csapi.CreateEgressFirewallRuleParams{}).
 				Return(&csapi.CreateEgressFirewallRuleResponse{}, errors.New("there is already a rule like this"))
 
-			Ω(client.OpenFirewallRules(dummies.CSISONet1)).Should(Succeed())
+			Ω(client.OpenFirewallRules(ctx, dummies.CSISONet1)).Should(Succeed())
 		})
 	})
 
@@ -154,7 +157,7 @@ var _ = Describe("Network", func() {
 					Count:             1,
 					PublicIpAddresses: []*csapi.PublicIpAddress{{Id: "PublicIPID", Ipaddress: ipAddress}},
 				}, nil)
-			publicIPAddress, err := client.GetPublicIP(dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)
+			publicIPAddress, err := client.GetPublicIP(ctx, dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)
 			Ω(err).Should(Succeed())
 			Ω(publicIPAddress).ShouldNot(BeNil())
 			Ω(publicIPAddress.Ipaddress).Should(Equal(ipAddress))
@@ -169,7 +172,7 @@ var _ = Describe("Network", func() {
 					Count:             0,
 					PublicIpAddresses: []*csapi.PublicIpAddress{},
 				}, nil)
-			publicIPAddress, err := client.GetPublicIP(dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)
+			publicIPAddress, err := client.GetPublicIP(ctx, dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)
 			Ω(publicIPAddress).Should(BeNil())
 			Ω(err.Error()).Should(ContainSubstring("no public addresses found in available networks"))
 		})
@@ -190,7 +193,7 @@ var _ = Describe("Network", func() {
 							Associatednetworkid: "1",
 						}},
 				}, nil)
-			publicIPAddress, err := client.GetPublicIP(dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)
+			publicIPAddress, err := client.GetPublicIP(ctx, dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)
 			Ω(publicIPAddress).Should(BeNil())
 			Ω(err.Error()).Should(ContainSubstring("all Public IP Address(es) found were already allocated"))
 		})
@@ -218,7 +221,7 @@ var _ = Describe("Network", func() {
 				Return(&csapi.CreateTagsParams{}).Times(2)
 			rs.EXPECT().CreateTags(gomock.Any()).Return(&csapi.CreateTagsResponse{}, nil).Times(2)
 
-			Ω(client.AssociatePublicIPAddress(dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).Should(Succeed())
+			Ω(client.AssociatePublicIPAddress(ctx, dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).Should(Succeed())
 		})
 
 		It("Failure Associating Public IP to Isolated network", func() {
@@ -231,7 +234,7 @@ var _ = Describe("Network", func() {
 			aip := &csapi.AssociateIpAddressParams{}
 			as.EXPECT().NewAssociateIpAddressParams().Return(aip)
 			as.EXPECT().AssociateIpAddress(aip).Return(nil, errors.New("Failed to allocate IP address"))
-			Ω(client.AssociatePublicIPAddress(dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster).Error()).Should(ContainSubstring("associating public IP address with ID"))
+			Ω(client.AssociatePublicIPAddress(ctx, dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster).Error()).Should(ContainSubstring("associating public IP address with ID"))
 		})
 	})
 
@@ -243,7 +246,7 @@ var _ = Describe("Network", func() {
 					{Publicport: strconv.Itoa(int(dummies.EndPointPort)), Id: dummies.LBRuleID}}}, nil)
 
 			dummies.CSISONet1.Status.LBRuleID = ""
-			Ω(client.ResolveLoadBalancerRuleDetails(dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).Should(Succeed())
+			Ω(client.ResolveLoadBalancerRuleDetails(ctx, dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).Should(Succeed())
 			Ω(dummies.CSISONet1.Status.LBRuleID).Should(Equal(dummies.LBRuleID))
 		})
 
@@ -254,7 +257,7 @@ var _ = Describe("Network", func() {
 					{Publicport: "differentPublicPort", Id: dummies.LBRuleID}}}, nil)
 
 			dummies.CSISONet1.Status.LBRuleID = ""
-			Ω(client.ResolveLoadBalancerRuleDetails(dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster).Error()).
+			Ω(client.ResolveLoadBalancerRuleDetails(ctx, dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster).Error()).
 				Should(Equal("no load balancer rule found"))
 		})
 
@@ -264,7 +267,7 @@ var _ = Describe("Network", func() {
 				nil, fakeError)
 
 			dummies.CSISONet1.Status.LBRuleID = ""
-			Ω(client.ResolveLoadBalancerRuleDetails(dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster).Error()).
+			Ω(client.ResolveLoadBalancerRuleDetails(ctx, dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster).Error()).
 				Should(ContainSubstring("listing load balancer rules"))
 		})
 
@@ -275,7 +278,7 @@ var _ = Describe("Network", func() {
 					LoadBalancerRules: []*csapi.LoadBalancerRule{
 						{Publicport: strconv.Itoa(int(dummies.EndPointPort)), Id: dummies.LBRuleID}}}, nil)
 
-			Ω(client.GetOrCreateLoadBalancerRule(dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).Should(Succeed())
+			Ω(client.GetOrCreateLoadBalancerRule(ctx, dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).Should(Succeed())
 			Ω(dummies.CSISONet1.Status.LBRuleID).Should(Equal(dummies.LBRuleID))
 		})
 	})
@@ -291,7 +294,7 @@ var _ = Describe("Network", func() {
 			lbs.EXPECT().NewAssignToLoadBalancerRuleParams(dummies.CSISONet1.Status.LBRuleID).Return(albp)
 			lbs.EXPECT().AssignToLoadBalancerRule(albp).Return(&csapi.AssignToLoadBalancerRuleResponse{}, nil)
 
-			Ω(client.AssignVMToLoadBalancerRule(dummies.CSISONet1, *dummies.CSMachine1.Spec.InstanceID)).Should(Succeed())
+			Ω(client.AssignVMToLoadBalancerRule(ctx, dummies.CSISONet1, *dummies.CSMachine1.Spec.InstanceID)).Should(Succeed())
 		})
 
 		It("Associating VM to LB rule fails", func() {
@@ -304,7 +307,7 @@ var _ = Describe("Network", func() {
 			lbs.EXPECT().NewAssignToLoadBalancerRuleParams(dummies.CSISONet1.Status.LBRuleID).Return(albp)
 			lbs.EXPECT().AssignToLoadBalancerRule(albp).Return(nil, fakeError)
 
-			Ω(client.AssignVMToLoadBalancerRule(dummies.CSISONet1, *dummies.CSMachine1.Spec.InstanceID)).ShouldNot(Succeed())
+			Ω(client.AssignVMToLoadBalancerRule(ctx, dummies.CSISONet1, *dummies.CSMachine1.Spec.InstanceID)).ShouldNot(Succeed())
 		})
 
 		It("LB Rule already assigned to VM", func() {
@@ -319,7 +322,7 @@ var _ = Describe("Network", func() {
 				}},
 			}, nil)
 
-			Ω(client.AssignVMToLoadBalancerRule(dummies.CSISONet1, *dummies.CSMachine1.Spec.InstanceID)).Should(Succeed())
+			Ω(client.AssignVMToLoadBalancerRule(ctx, dummies.CSISONet1, *dummies.CSMachine1.Spec.InstanceID)).Should(Succeed())
 		})
 	})
 
@@ -334,15 +337,51 @@ var _ = Describe("Network", func() {
 			lbs.EXPECT().CreateLoadBalancerRule(gomock.Any()).
 				Return(&csapi.CreateLoadBalancerRuleResponse{Id: "2ndLBRuleID"}, nil)
 
-			Ω(client.GetOrCreateLoadBalancerRule(dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).Should(Succeed())
+			Ω(client.GetOrCreateLoadBalancerRule(ctx, dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).Should(Succeed())
 			Ω(dummies.CSISONet1.Status.LBRuleID).Should(Equal("2ndLBRuleID"))
 		})
 
+		It("creates the rule as configured and only opens it to the allowlist", func() {
+			dummies.CSCluster.Spec.LoadBalancer = &infrav1.LoadBalancerSpec{
+				Algorithm: "source", AllowedCIDRs: []string{"10.0.0.0/8"}}
+			createRuleParams := &csapi.CreateLoadBalancerRuleParams{}
+			createFirewallParams := &csapi.CreateFirewallRuleParams{}
+
+			lbs.EXPECT().NewListLoadBalancerRulesParams().Return(&csapi.ListLoadBalancerRulesParams{})
+			lbs.EXPECT().ListLoadBalancerRules(gomock.Any()).Return(&csapi.ListLoadBalancerRulesResponse{}, nil)
+			lbs.EXPECT().NewCreateLoadBalancerRuleParams("source", cloud.APIServerLBRuleName, cloud.K8sDefaultAPIPort,
+				cloud.K8sDefaultAPIPort).Return(createRuleParams)
+			lbs.EXPECT().CreateLoadBalancerRule(createRuleParams).
+				Return(&csapi.CreateLoadBalancerRuleResponse{Id: "2ndLBRuleID"}, nil)
+			lbs.EXPECT().NewListLBStickinessPoliciesParams().Return(&csapi.ListLBStickinessPoliciesParams{})
+			lbs.EXPECT().ListLBStickinessPolicies(gomock.Any()).Return(&csapi.ListLBStickinessPoliciesResponse{}, nil)
+			lbs.EXPECT().NewListLBHealthCheckPoliciesParams().Return(&csapi.ListLBHealthCheckPoliciesParams{})
+			lbs.EXPECT().ListLBHealthCheckPolicies(gomock.Any()).Return(&csapi.ListLBHealthCheckPoliciesResponse{}, nil)
+			fs.EXPECT().NewListFirewallRulesParams().Return(&csapi.ListFirewallRulesParams{})
+			fs.EXPECT().ListFirewallRules(gomock.Any()).Return(&csapi.ListFirewallRulesResponse{
+				FirewallRules: []*csapi.FirewallRule{{Id: "openToAll", Protocol: "tcp", Cidrlist: "0.0.0.0/0",
+					Startport: int(dummies.EndPointPort), Endport: int(dummies.EndPointPort)}}}, nil)
+			fs.EXPECT().NewDeleteFirewallRuleParams("openToAll").Return(&csapi.DeleteFirewallRuleParams{})
+			fs.EXPECT().DeleteFirewallRule(gomock.Any()).Return(&csapi.DeleteFirewallRuleResponse{}, nil)
+			fs.EXPECT().NewCreateFirewallRuleParams(dummies.CSISONet1.Status.PublicIPID, cloud.NetworkProtocolTCP).
+				Return(createFirewallParams)
+			fs.EXPECT().CreateFirewallRule(createFirewallParams).Return(&csapi.CreateFirewallRuleResponse{}, nil)
+
+			Ω(client.GetOrCreateLoadBalancerRule(ctx, dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).Should(Succeed())
+			Ω(dummies.CSISONet1.Status.LBRuleID).Should(Equal("2ndLBRuleID"))
+			openFirewall, _ := createRuleParams.GetOpenfirewall()
+			Ω(openFirewall).Should(BeFalse())
+			allowedCIDRs, _ := createFirewallParams.GetCidrlist()
+			Ω(allowedCIDRs).Should(Equal([]string{"10.0.0.0/8"}))
+			startPort, _ := createFirewallParams.GetStartport()
+			Ω(startPort).Should(Equal(int(dummies.EndPointPort)))
+		})
+
 		It("Fails to resolve load balancer rule details", func() {
 			lbs.EXPECT().NewListLoadBalancerRulesParams().Return(&csapi.ListLoadBalancerRulesParams{})
 			lbs.EXPECT().ListLoadBalancerRules(gomock.Any()).
 				Return(nil, fakeError)
-			err := client.GetOrCreateLoadBalancerRule(dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)
+			err := client.GetOrCreateLoadBalancerRule(ctx, dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)
 			Ω(err).ShouldNot(Succeed())
 			Ω(err.Error()).Should(ContainSubstring(errorMessage))
 		})
@@ -356,7 +395,7 @@ var _ = Describe("Network", func() {
 				Return(&csapi.CreateLoadBalancerRuleParams{})
 			lbs.EXPECT().CreateLoadBalancerRule(gomock.Any()).
 				Return(nil, fakeError)
-			err := client.GetOrCreateLoadBalancerRule(dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)
+			err := client.GetOrCreateLoadBalancerRule(ctx, dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)
 			Ω(err).ShouldNot(Succeed())
 			Ω(err.Error()).Should(Equal(errorMessage))
 
@@ -369,14 +408,14 @@ var _ = Describe("Network", func() {
 			ns.EXPECT().NewDeleteNetworkParams(dummies.ISONet1.ID).Return(dnp)
 			ns.EXPECT().DeleteNetwork(dnp).Return(&csapi.DeleteNetworkResponse{}, nil)
 
-			Ω(client.DeleteNetwork(dummies.ISONet1)).Should(Succeed())
+			Ω(client.DeleteNetwork(ctx, dummies.ISONet1)).Should(Succeed())
 		})
 
 		It("Network deletion failure", func() {
 			dnp := &csapi.DeleteNetworkParams{}
 			ns.EXPECT().NewDeleteNetworkParams(dummies.ISONet1.ID).Return(dnp)
 			ns.EXPECT().DeleteNetwork(dnp).Return(nil, fakeError)
-			err := client.DeleteNetwork(dummies.ISONet1)
+			err := client.DeleteNetwork(ctx, dummies.ISONet1)
 			Ω(err).ShouldNot(Succeed())
 			Ω(err.Error()).Should(ContainSubstring("deleting network with id " + dummies.ISONet1.ID))
 		})
@@ -390,7 +429,7 @@ var _ = Describe("Network", func() {
 			rs.EXPECT().ListTags(rtlp).Return(&csapi.ListTagsResponse{}, nil).Times(4)
 			as.EXPECT().GetPublicIpAddressByID(dummies.CSISONet1.Status.PublicIPID).Return(&csapi.PublicIpAddress{}, 1, nil)
 
-			Ω(client.DisposeIsoNetResources(dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).Should(Succeed())
+			Ω(client.DisposeIsoNetResources(ctx, dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).Should(Succeed())
 		})
 
 		It("delete all isolated network resources when managed by CAPC", func() {
@@ -408,7 +447,7 @@ var _ = Describe("Network", func() {
 			as.EXPECT().NewDisassociateIpAddressParams(dummies.CSISONet1.Status.PublicIPID).Return(dap)
 			as.EXPECT().DisassociateIpAddress(dap).Return(&csapi.DisassociateIpAddressResponse{}, nil)
 
-			Ω(client.DisposeIsoNetResources(dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).Should(Succeed())
+			Ω(client.DisposeIsoNetResources(ctx, dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).Should(Succeed())
 		})
 
 		It("disassociate IP address fails due to failure in deleting a resource i.e., disassociate Public IP", func() {
@@ -425,7 +464,7 @@ var _ = Describe("Network", func() {
 			as.EXPECT().NewDisassociateIpAddressParams(dummies.CSISONet1.Status.PublicIPID).Return(dap)
 			as.EXPECT().DisassociateIpAddress(dap).Return(nil, fakeError)
 
-			Ω(client.DisposeIsoNetResources(dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).ShouldNot(Succeed())
+			Ω(client.DisposeIsoNetResources(ctx, dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).ShouldNot(Succeed())
 		})
 
 	})
@@ -434,12 +473,12 @@ var _ = Describe("Network", func() {
 		BeforeEach(func() {
 			client = realCloudClient
 			// Delete any existing tags
-			existingTags, err := client.GetTags(cloud.ResourceTypeNetwork, dummies.Net1.ID)
+			existingTags, err := client.GetTags(ctx, cloud.ResourceTypeNetwork, dummies.Net1.ID)
 			if err != nil {
 				Fail("Failed to get existing tags. Error: " + err.Error())
 			}
 			if len(existingTags) != 0 {
-				err = client.DeleteTags(cloud.ResourceTypeNetwork, dummies.Net1.ID, existingTags)
+				err = client.DeleteTags(ctx, cloud.ResourceTypeNetwork, dummies.Net1.ID, existingTags)
 				if err != nil {
 					Fail("Failed to delete existing tags. Error: " + err.Error())
 				}
@@ -458,7 +497,7 @@ var _ = Describe("Network", func() {
 			dummies.SetDummyIsoNetToNameOnly()
 			dummies.SetClusterSpecToNet(&dummies.ISONet1)
 
-			Ω(client.ResolveNetwork(&dummies.ISONet1)).Should(Succeed())
+			Ω(client.ResolveNetwork(ctx, &dummies.ISONet1)).Should(Succeed())
 			Ω(dummies.ISONet1.ID).ShouldNot(BeEmpty())
 			Ω(dummies.ISONet1.Type).Should(Equal(cloud.NetworkTypeIsolated))
 		})
@@ -468,18 +507,125 @@ var _ = Describe("Network", func() {
 			dummies.SetDummyIsoNetToNameOnly()
 			dummies.SetClusterSpecToNet(&dummies.ISONet1)
 			dummies.CSCluster.Spec.ControlPlaneEndpoint.Host = ""
-			Ω(client.ResolveNetwork(&dummies.ISONet1)).Should(Succeed())
+			Ω(client.ResolveNetwork(ctx, &dummies.ISONet1)).Should(Succeed())
 		})
 
 		It("adds an isolated network and doesn't fail when asked to GetOrCreateIsolatedNetwork multiple times", func() {
-			Ω(client.GetOrCreateIsolatedNetwork(dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).Should(Succeed())
-			Ω(client.GetOrCreateIsolatedNetwork(dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).Should(Succeed())
+			Ω(client.GetOrCreateIsolatedNetwork(ctx, dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).Should(Succeed())
+			Ω(client.GetOrCreateIsolatedNetwork(ctx, dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).Should(Succeed())
 
 			// Network should now exist if it didn't at the start.
-			Ω(client.ResolveNetwork(&dummies.ISONet1)).Should(Succeed())
+			Ω(client.ResolveNetwork(ctx, &dummies.ISONet1)).Should(Succeed())
 
 			// Do once more.
-			Ω(client.GetOrCreateIsolatedNetwork(dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).Should(Succeed())
+			Ω(client.GetOrCreateIsolatedNetwork(ctx, dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).Should(Succeed())
+		})
+	})
+
+	Context("with the simulator", func() {
+		UseSimulator()
+
+		It("builds out an isolated network idempotently", func() {
+			dummies.SetDummyIsoNetToNameOnly()
+			dummies.CSFailureDomain1.Spec.Zone = dummies.Zone1
+			dummies.CSCluster.Spec.ControlPlaneEndpoint.Host = ""
+			// ResolveNetwork falls back to listing by an empty ID, which matches a lone network.
+			sim.AddNetwork(dummies.Zone1.ID, "other-network", simulator.NetworkTypeShared, "10.20.0.0/24")
+
+			for i := 0; i < 2; i++ {
+				Ω(client.GetOrCreateIsolatedNetwork(
+					ctx,
+					dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).Should(Succeed())
+			}
+
+			var isoNets int
+			for _, net := range sim.Networks() {
+				if net.Name == dummies.ISONet1.Name {
+					isoNets++
+					Ω(net.Id).Should(Equal(dummies.CSISONet1.Spec.ID))
+				}
+			}
+			Ω(isoNets).Should(Equal(1))
+			Ω(sim.LoadBalancerRules()).Should(HaveLen(1))
+			Ω(sim.EgressFirewallRules()).Should(HaveLen(1))
+			Ω(sim.FirewallRules()).Should(HaveLen(1)) // Opened by CloudStack along with the load balancer rule.
+			Ω(sim.Tags(dummies.CSISONet1.Spec.ID)).Should(HaveKey(cloud.CreatedByCAPCTagName))
+			Ω(dummies.CSCluster.Spec.ControlPlaneEndpoint.Host).Should(BeElementOf(dummies.SimulatorPublicIPs))
+			Ω(dummies.CSISONet1.Status.LBRuleID).Should(Equal(sim.LoadBalancerRules()[0].Id))
+		})
+
+		It("creates an isolated network with the configured offering, CIDR, domain and VLAN", func() {
+			offering := sim.AddIsolatedNetworkOffering("IsolatedWithVLAN", true)
+			dummies.SetDummyIsoNetToNameOnly()
+			dummies.CSFailureDomain1.Spec.Zone = dummies.Zone1
+			dummies.CSFailureDomain1.Spec.Zone.Network.Offering = &infrav1.CloudStackResourceIdentifier{Name: offering.Name}
+			dummies.CSFailureDomain1.Spec.Zone.Network.CIDR = "172.16.8.0/22"
+			dummies.CSFailureDomain1.Spec.Zone.Network.Domain = "cluster.example.com"
+			dummies.CSFailureDomain1.Spec.Zone.Network.VLAN = "vlan://120"
+			dummies.CSCluster.Spec.ControlPlaneEndpoint.Host = ""
+			sim.AddNetwork(dummies.Zone1.ID, "other-network", simulator.NetworkTypeShared, "10.20.0.0/24")
+
+			Ω(client.GetOrCreateIsolatedNetwork(
+				ctx,
+				dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).Should(Succeed())
+			var isoNet csapi.Network
+			for _, net := range sim.Networks() {
+				if net.Id == dummies.CSISONet1.Spec.ID {
+					isoNet = net
+				}
+			}
+			Ω(isoNet.Networkofferingid).Should(Equal(offering.Id))
+			Ω(isoNet.Cidr).Should(Equal("172.16.8.0/22"))
+			Ω(isoNet.Gateway).Should(Equal("172.16.8.1"))
+			Ω(isoNet.Networkdomain).Should(Equal("cluster.example.com"))
+			Ω(isoNet.Vlan).Should(Equal("vlan://120"))
+		})
+
+		It("builds out a dual-stack isolated network and reports machines' IPv6 addresses", func() {
+			offering := sim.AddDualStackNetworkOffering("DualStack")
+			dummies.SetDummyIsoNetToNameOnly()
+			dummies.CSFailureDomain1.Spec.Zone = dummies.Zone1
+			dummies.CSFailureDomain1.Spec.Zone.Network.Offering = &infrav1.CloudStackResourceIdentifier{Name: offering.Name}
+			dummies.CSFailureDomain1.Spec.Zone.Network.IPv6 = &infrav1.IPv6Spec{AllowedCIDRs: []string{"2001:db8:1::/48"}}
+			dummies.CSCluster.Spec.ControlPlaneEndpoint.Host = ""
+			sim.AddNetwork(dummies.Zone1.ID, "other-network", simulator.NetworkTypeShared, "10.20.0.0/24")
+
+			for i := 0; i < 2; i++ {
+				Ω(client.GetOrCreateIsolatedNetwork(
+					ctx,
+					dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).Should(Succeed())
+			}
+			subnet := dummies.CSISONet1.Status.IPv6CIDR
+			Ω(subnet).Should(HaveSuffix("::/64"))
+			Ω(dummies.CSISONet1.Status.IPv6Routes).Should(HaveLen(1))
+			Ω(dummies.CSISONet1.Status.IPv6Routes[0].Subnet).Should(Equal(subnet))
+			Ω(sim.IPv6FirewallRules(dummies.CSISONet1.Spec.ID)).Should(HaveLen(2))
+
+			// Dropping the allowlist closes the API server port again, leaving egress open.
+			dummies.CSFailureDomain1.Spec.Zone.Network.IPv6.AllowedCIDRs = nil
+			Ω(client.GetOrCreateIsolatedNetwork(
+				ctx,
+				dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).Should(Succeed())
+			rules := sim.IPv6FirewallRules(dummies.CSISONet1.Spec.ID)
+			Ω(rules).Should(HaveLen(1))
+			Ω(rules[0].Traffictype).Should(Equal("Egress"))
+
+			dummies.CSFailureDomain1.Spec.Zone.Network.ID = dummies.CSISONet1.Spec.ID
+			Ω(client.GetOrCreateVMInstance(ctx, dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster,
+				dummies.CSFailureDomain1, dummies.CSAffinityGroup, "")).Should(Succeed())
+			Ω(dummies.CSMachine1.Status.Addresses).Should(HaveLen(2))
+			Ω(dummies.CSMachine1.Status.Addresses[1].Type).Should(Equal(corev1.NodeInternalIP))
+			Ω(dummies.CSMachine1.Status.Addresses[1].Address).Should(HavePrefix(strings.TrimSuffix(subnet, "/64")))
+		})
+
+		It("fails to create an isolated network with a VLAN its offering doesn't allow", func() {
+			dummies.SetDummyIsoNetToNameOnly()
+			dummies.CSFailureDomain1.Spec.Zone = dummies.Zone1
+			dummies.CSFailureDomain1.Spec.Zone.Network.VLAN = "120"
+			sim.AddNetwork(dummies.Zone1.ID, "other-network", simulator.NetworkTypeShared, "10.20.0.0/24")
+
+			Ω(client.GetOrCreateIsolatedNetwork(ctx, dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).
+				Should(MatchError(ContainSubstring("specifyVlan=false")))
 		})
 	})
 })
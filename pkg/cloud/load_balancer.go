@@ -0,0 +1,588 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/apache/cloudstack-go/v2/cloudstack"
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
+)
+
+const (
+	APIServerLBRuleName  = "Kubernetes_API_Server"
+	StickinessPolicyName = "Kubernetes_Stickiness"
+)
+
+// LoadBalancerIface manages the load balancer rules of CloudStackLoadBalancers.
+type LoadBalancerIface interface {
+	GetOrCreateLoadBalancerRules(context.Context, *infrav1.CloudStackLoadBalancer, *infrav1.CloudStackIsolatedNetwork) error
+	SetLoadBalancerRuleMembers(ctx context.Context, ruleID string, instanceIDs []string) error
+	DeleteLoadBalancerRules(context.Context, *infrav1.CloudStackLoadBalancer, *infrav1.CloudStackIsolatedNetwork) error
+}
+
+// listLoadBalancerRules lists the load balancer rules on the network's public IP address by public port.
+func (c *client) listLoadBalancerRules(isoNet *infrav1.CloudStackIsolatedNetwork) (map[int]*cloudstack.LoadBalancerRule, error) {
+	p := c.cs.LoadBalancer.NewListLoadBalancerRulesParams()
+	p.SetPublicipid(isoNet.Status.PublicIPID)
+	resp, err := c.cs.LoadBalancer.ListLoadBalancerRules(p)
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return nil, errors.Wrap(err, "listing load balancer rules")
+	}
+	rules := map[int]*cloudstack.LoadBalancerRule{}
+	for _, rule := range resp.LoadBalancerRules {
+		if port, err := strconv.Atoi(rule.Publicport); err == nil {
+			rules[port] = rule
+		}
+	}
+	return rules, nil
+}
+
+// createLoadBalancerRule creates a load balancer rule on the network's public IP address as configured by a load
+// balancer, if any. When CAPC manages the ingress firewall, CloudStack is kept from opening the public port to everyone.
+// Creating the rule is started as an async job recorded in the passed status field. The rule's ID is returned while
+// the job runs too.
+func (c *client) createLoadBalancerRule(
+	job **infrav1.AsyncJob,
+	isoNet *infrav1.CloudStackIsolatedNetwork,
+	lb *infrav1.LoadBalancerSpec,
+	managedFirewall bool,
+	name string,
+	publicPort, privatePort int,
+	allowedCIDRs []string,
+) (string, error) {
+	p := c.csAsync.LoadBalancer.NewCreateLoadBalancerRuleParams(lb.AlgorithmOrDefault(), name, privatePort, privatePort)
+	p.SetPublicport(publicPort)
+	p.SetNetworkid(isoNet.Spec.ID)
+	p.SetPublicipid(isoNet.Status.PublicIPID)
+	p.SetProtocol(NetworkProtocolTCP)
+	if lb != nil {
+		// VPC public IP addresses have no firewall, so the rule's CIDR list restricts them instead.
+		if managedFirewall && isoNet.Spec.VPCID == "" {
+			p.SetOpenfirewall(false)
+		} else {
+			p.SetCidrlist(allowedCIDRs)
+		}
+	}
+	resp, err := c.csAsync.LoadBalancer.CreateLoadBalancerRule(p)
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return "", err
+	}
+	if err := c.startAsyncJob(job, CommandCreateLoadBalancerRule, resp.JobID); IsJobPending(err) {
+		return resp.Id, err
+	} else if err != nil {
+		return "", err
+	}
+	return resp.Id, nil
+}
+
+// reconcileLoadBalancer applies the CloudStackCluster's load balancer configuration to the rules on the control plane
+// endpoint's public IP address, whose API server rule has already been set up. Rules for ports that are no longer
+// forwarded are removed, leaving those of CloudStackLoadBalancers alone. The ingress firewall is managed unless the
+// failure domain's network load balances the endpoint, where the allowlists restrict the load balancer rules instead.
+func (c *client) reconcileLoadBalancer(
+	fd *infrav1.CloudStackFailureDomain,
+	isoNet *infrav1.CloudStackIsolatedNetwork,
+	csCluster *infrav1.CloudStackCluster,
+	rules map[int]*cloudstack.LoadBalancerRule,
+) error {
+	lb := csCluster.Spec.LoadBalancer
+	if lb == nil {
+		return nil
+	}
+	managedFirewall := csCluster.ControlPlaneEndpointProviderFor(&fd.Spec) != infrav1.EndpointProviderNetworkLoadBalancer
+
+	apiPort := int(csCluster.Spec.ControlPlaneEndpoint.Port)
+	ingress := map[int][]string{apiPort: lb.AllowedCIDRsFor(nil)}
+	if err := c.reconcileLoadBalancerRulePolicies(isoNet.Status.LBRuleID, rules[apiPort], lb, lb.HealthCheck); err != nil {
+		return errors.Wrap(err, "configuring the API server load balancer rule")
+	}
+
+	for i := range lb.AdditionalPorts {
+		mapping := &lb.AdditionalPorts[i]
+		publicPort, privatePort := int(mapping.PublicPort), int(mapping.PrivatePort)
+		if privatePort == 0 {
+			privatePort = publicPort
+		}
+		ingress[publicPort] = lb.AllowedCIDRsFor(mapping)
+
+		rule, ruleID := rules[publicPort], ""
+		if rule != nil && rule.Privateport != strconv.Itoa(privatePort) { // Private ports can't be updated.
+			if err := c.deleteLoadBalancerRule(rule.Id); err != nil {
+				return errors.Wrapf(err, "replacing load balancer rule %s", mapping.Name)
+			}
+			rule = nil
+		}
+		if rule != nil {
+			ruleID = rule.Id
+		} else {
+			var err error
+			if ruleID, err = c.createLoadBalancerRule(&isoNet.Status.AsyncJob,
+				isoNet, lb, managedFirewall, mapping.Name, publicPort, privatePort, ingress[publicPort]); err != nil {
+				return errors.Wrapf(err, "creating load balancer rule %s", mapping.Name)
+			}
+		}
+		if err := c.reconcileLoadBalancerRulePolicies(ruleID, rule, lb, nil); err != nil {
+			return errors.Wrapf(err, "configuring load balancer rule %s", mapping.Name)
+		}
+	}
+
+	for port, rule := range rules {
+		if _, forwarded := ingress[port]; !forwarded && loadBalancerRuleOwner(rule) == "" {
+			if err := c.deleteLoadBalancerRule(rule.Id); err != nil {
+				return errors.Wrapf(err, "removing load balancer rule %s", rule.Name)
+			}
+			ingress[port] = nil // Close the port again.
+		}
+	}
+
+	if !managedFirewall {
+		return nil
+	}
+	return errors.Wrap(c.reconcileIngressFirewallRules(&isoNet.Status.AsyncJob, isoNet, ingress),
+		"configuring the ingress firewall")
+}
+
+func (c *client) deleteLoadBalancerRule(ruleID string) error {
+	_, err := c.cs.LoadBalancer.DeleteLoadBalancerRule(c.cs.LoadBalancer.NewDeleteLoadBalancerRuleParams(ruleID))
+	c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+	return err
+}
+
+// reconcileLoadBalancerRulePolicies sets a rule's algorithm, stickiness and health check policies. The existing rule
+// is nil if it was just created as configured.
+func (c *client) reconcileLoadBalancerRulePolicies(
+	ruleID string,
+	existing *cloudstack.LoadBalancerRule,
+	lb *infrav1.LoadBalancerSpec,
+	healthCheck *infrav1.LoadBalancerHealthCheckPolicy,
+) error {
+	if existing != nil && existing.Algorithm != lb.AlgorithmOrDefault() {
+		p := c.cs.LoadBalancer.NewUpdateLoadBalancerRuleParams(ruleID)
+		p.SetAlgorithm(lb.AlgorithmOrDefault())
+		if _, err := c.cs.LoadBalancer.UpdateLoadBalancerRule(p); err != nil {
+			c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+			return errors.Wrap(err, "updating load balancing algorithm")
+		}
+	}
+	if err := c.reconcileStickinessPolicy(ruleID, lb.Stickiness); err != nil {
+		return errors.Wrap(err, "setting stickiness policy")
+	}
+	return errors.Wrap(c.reconcileHealthCheckPolicy(ruleID, healthCheck), "setting health check policy")
+}
+
+// reconcileStickinessPolicy replaces a rule's stickiness policies with the wanted one, if any.
+func (c *client) reconcileStickinessPolicy(ruleID string, want *infrav1.LoadBalancerStickinessPolicy) error {
+	p := c.cs.LoadBalancer.NewListLBStickinessPoliciesParams()
+	p.SetLbruleid(ruleID)
+	resp, err := c.cs.LoadBalancer.ListLBStickinessPolicies(p)
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return err
+	}
+	found := false
+	for _, policies := range resp.LBStickinessPolicies {
+		for _, policy := range policies.Stickinesspolicy {
+			if want != nil && !found && policy.Methodname == want.Method && paramsContain(policy.Params, want.Params) {
+				found = true
+				continue
+			}
+			_, err := c.cs.LoadBalancer.DeleteLBStickinessPolicy(c.cs.LoadBalancer.NewDeleteLBStickinessPolicyParams(policy.Id))
+			if err != nil {
+				c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+				return err
+			}
+		}
+	}
+	if want == nil || found {
+		return nil
+	}
+	createParams := c.cs.LoadBalancer.NewCreateLBStickinessPolicyParams(ruleID, want.Method, StickinessPolicyName)
+	if len(want.Params) > 0 {
+		createParams.SetParam(want.Params)
+	}
+	_, err = c.cs.LoadBalancer.CreateLBStickinessPolicy(createParams)
+	c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+	return err
+}
+
+// paramsContain reports whether CloudStack's view of a policy's parameters includes the wanted ones.
+func paramsContain(have, want map[string]string) bool {
+	for key, value := range want {
+		if have[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// reconcileHealthCheckPolicy replaces a rule's health check policies with the wanted one, if any.
+func (c *client) reconcileHealthCheckPolicy(ruleID string, want *infrav1.LoadBalancerHealthCheckPolicy) error {
+	p := c.cs.LoadBalancer.NewListLBHealthCheckPoliciesParams()
+	p.SetLbruleid(ruleID)
+	resp, err := c.cs.LoadBalancer.ListLBHealthCheckPolicies(p)
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return err
+	}
+	found := false
+	for _, policies := range resp.LBHealthCheckPolicies {
+		for _, policy := range policies.Healthcheckpolicy {
+			if want != nil && !found && healthCheckMatches(policy, want) {
+				found = true
+				continue
+			}
+			_, err := c.cs.LoadBalancer.DeleteLBHealthCheckPolicy(c.cs.LoadBalancer.NewDeleteLBHealthCheckPolicyParams(policy.Id))
+			if err != nil {
+				c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+				return err
+			}
+		}
+	}
+	if want == nil || found {
+		return nil
+	}
+	createParams := c.cs.LoadBalancer.NewCreateLBHealthCheckPolicyParams(ruleID)
+	setIfNotEmpty(want.PingPath, createParams.SetPingpath)
+	if want.IntervalSeconds > 0 {
+		createParams.SetIntervaltime(want.IntervalSeconds)
+	}
+	if want.TimeoutSeconds > 0 {
+		createParams.SetResponsetimeout(want.TimeoutSeconds)
+	}
+	if want.HealthyThreshold > 0 {
+		createParams.SetHealthythreshold(want.HealthyThreshold)
+	}
+	if want.UnhealthyThreshold > 0 {
+		createParams.SetUnhealthythreshold(want.UnhealthyThreshold)
+	}
+	_, err = c.cs.LoadBalancer.CreateLBHealthCheckPolicy(createParams)
+	c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+	return err
+}
+
+// healthCheckMatches compares the fields of a health check policy that were set, leaving the rest to CloudStack.
+func healthCheckMatches(have cloudstack.LBHealthCheckPolicyHealthcheckpolicy, want *infrav1.LoadBalancerHealthCheckPolicy) bool {
+	intMatches := func(have, want int) bool { return want == 0 || have == want }
+	return (want.PingPath == "" || have.Pingpath == want.PingPath) &&
+		intMatches(have.Healthcheckinterval, want.IntervalSeconds) &&
+		intMatches(have.Responsetime, want.TimeoutSeconds) &&
+		intMatches(have.Healthcheckthresshold, want.HealthyThreshold) &&
+		intMatches(have.Unhealthcheckthresshold, want.UnhealthyThreshold)
+}
+
+// reconcileIngressFirewallRules makes the firewall rules on the network's public IP address allow exactly the wanted
+// source CIDRs to each port. Ports wanted with no CIDRs are closed. Rules for other ports are left alone. Rules are
+// created one at a time, each by an async job recorded in the passed status field.
+func (c *client) reconcileIngressFirewallRules(
+	job **infrav1.AsyncJob, isoNet *infrav1.CloudStackIsolatedNetwork, ingress map[int][]string,
+) error {
+	if isoNet.Spec.VPCID != "" { // VPC public IP addresses have no firewall.
+		return nil
+	}
+	p := c.cs.Firewall.NewListFirewallRulesParams()
+	p.SetIpaddressid(isoNet.Status.PublicIPID)
+	resp, err := c.cs.Firewall.ListFirewallRules(p)
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return errors.Wrap(err, "listing firewall rules")
+	}
+	open := map[int]bool{}
+	for _, rule := range resp.FirewallRules {
+		cidrs, managed := ingress[rule.Startport]
+		if !managed {
+			continue
+		}
+		if len(cidrs) > 0 && !open[rule.Startport] && rule.Endport == rule.Startport &&
+			strings.EqualFold(rule.Protocol, NetworkProtocolTCP) && sameCIDRs(rule.Cidrlist, cidrs) {
+			open[rule.Startport] = true
+			continue
+		}
+		_, err := c.cs.Firewall.DeleteFirewallRule(c.cs.Firewall.NewDeleteFirewallRuleParams(rule.Id))
+		if err != nil {
+			c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+			return errors.Wrapf(err, "deleting firewall rule for port %d", rule.Startport)
+		}
+	}
+
+	ports := make([]int, 0, len(ingress))
+	for port := range ingress {
+		ports = append(ports, port)
+	}
+	sort.Ints(ports)
+	for _, port := range ports {
+		if cidrs := ingress[port]; len(cidrs) > 0 && !open[port] {
+			createParams := c.csAsync.Firewall.NewCreateFirewallRuleParams(isoNet.Status.PublicIPID, NetworkProtocolTCP)
+			createParams.SetStartport(port)
+			createParams.SetEndport(port)
+			createParams.SetCidrlist(cidrs)
+			resp, err := c.csAsync.Firewall.CreateFirewallRule(createParams)
+			if err != nil {
+				c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+			} else {
+				err = c.startAsyncJob(job, CommandCreateFirewallRule, resp.JobID)
+			}
+			if err != nil {
+				return errors.Wrapf(err, "opening port %d", port)
+			}
+		}
+	}
+	return nil
+}
+
+// sameCIDRs compares CloudStack's comma separated CIDR list with a list of CIDRs, ignoring order.
+func sameCIDRs(cidrList string, cidrs []string) bool {
+	have := strings.Split(strings.ReplaceAll(cidrList, " ", ""), ",")
+	want := append([]string{}, cidrs...)
+	sort.Strings(have)
+	sort.Strings(want)
+	return strings.Join(have, ",") == strings.Join(want, ",")
+}
+
+// assignVMToAdditionalPortRules puts a VM behind the load balancer rules of the CloudStackCluster's additional ports.
+func (c *client) assignVMToAdditionalPortRules(
+	isoNet *infrav1.CloudStackIsolatedNetwork,
+	csCluster *infrav1.CloudStackCluster,
+	instanceID string,
+) error {
+	lb := csCluster.Spec.LoadBalancer
+	if lb == nil || len(lb.AdditionalPorts) == 0 {
+		return nil
+	}
+	rules, err := c.listLoadBalancerRules(isoNet)
+	if err != nil {
+		return err
+	}
+	for _, mapping := range lb.AdditionalPorts {
+		rule, found := rules[int(mapping.PublicPort)]
+		if !found {
+			return errors.Errorf("no load balancer rule found for %s", mapping.Name)
+		}
+		if err := c.assignVMToLoadBalancerRuleID(rule.Id, instanceID); err != nil {
+			return errors.Wrapf(err, "assigning VM to load balancer rule %s", mapping.Name)
+		}
+	}
+	return nil
+}
+
+// loadBalancerOwnerName identifies a CloudStackLoadBalancer in the tags of the load balancer rules it owns.
+func loadBalancerOwnerName(csLB *infrav1.CloudStackLoadBalancer) string {
+	return csLB.Namespace + "/" + csLB.Name
+}
+
+// loadBalancerRuleOwner returns the CloudStackLoadBalancer that owns a load balancer rule, or an empty string.
+func loadBalancerRuleOwner(rule *cloudstack.LoadBalancerRule) string {
+	for _, tag := range rule.Tags {
+		if tag.Key == LoadBalancerTagName {
+			return tag.Value
+		}
+	}
+	return ""
+}
+
+// GetOrCreateLoadBalancerRules creates and configures the CloudStackLoadBalancer's rules on the network's public IP
+// address, and records their IDs. Rules it owns are tagged as such: rules for ports no longer listed are removed, and
+// ports forwarded by rules it doesn't own are refused. Each rule's port is only opened to its allowlist.
+func (c *client) GetOrCreateLoadBalancerRules(
+	ctx context.Context,
+	csLB *infrav1.CloudStackLoadBalancer,
+	isoNet *infrav1.CloudStackIsolatedNetwork,
+) error {
+	c, ctx, cancel := c.withTimeout(ctx, OperationNetwork)
+	defer cancel()
+
+	if err := c.pollAsyncJob(&csLB.Status.AsyncJob); err != nil {
+		return errors.Wrap(err, "setting up load balancer rules")
+	}
+	lb := csLB.LoadBalancerSpec()
+	owner := loadBalancerOwnerName(csLB)
+	rules, err := c.listLoadBalancerRules(isoNet)
+	if err != nil {
+		return err
+	}
+
+	ruleIDs := map[string]string{}
+	ingress := map[int][]string{}
+	for i := range lb.AdditionalPorts {
+		mapping := &lb.AdditionalPorts[i]
+		publicPort, privatePort := int(mapping.PublicPort), int(mapping.PrivatePort)
+		if privatePort == 0 {
+			privatePort = publicPort
+		}
+
+		rule, ruleID := rules[publicPort], ""
+		if rule != nil && loadBalancerRuleOwner(rule) != owner {
+			return errors.Errorf("public port %d is already forwarded by load balancer rule %s", publicPort, rule.Name)
+		}
+		ingress[publicPort] = lb.AllowedCIDRsFor(mapping)
+		if rule != nil && rule.Privateport != strconv.Itoa(privatePort) { // Private ports can't be updated.
+			if err := c.deleteLoadBalancerRule(rule.Id); err != nil {
+				return errors.Wrapf(err, "replacing load balancer rule %s", mapping.Name)
+			}
+			rule = nil
+		}
+		if rule != nil {
+			ruleID = rule.Id
+			if err := c.reconcileManagementClusterTag(ctx, rule); err != nil {
+				return errors.Wrapf(err, "tagging load balancer rule %s", mapping.Name)
+			}
+		} else if ruleID, err = c.createOwnedLoadBalancerRule(ctx, csLB, isoNet, lb, owner, mapping, privatePort); err != nil {
+			return errors.Wrapf(err, "creating load balancer rule %s", mapping.Name)
+		}
+		if err := c.reconcileLoadBalancerRulePolicies(ruleID, rule, lb, lb.HealthCheck); err != nil {
+			return errors.Wrapf(err, "configuring load balancer rule %s", mapping.Name)
+		}
+		ruleIDs[mapping.Name] = ruleID
+	}
+
+	for port, rule := range rules {
+		if _, forwarded := ingress[port]; !forwarded && loadBalancerRuleOwner(rule) == owner {
+			if err := c.deleteLoadBalancerRule(rule.Id); err != nil {
+				return errors.Wrapf(err, "removing load balancer rule %s", rule.Name)
+			}
+			ingress[port] = nil // Close the port again.
+		}
+	}
+	if err := c.reconcileIngressFirewallRules(&csLB.Status.AsyncJob, isoNet, ingress); err != nil {
+		return errors.Wrap(err, "configuring the ingress firewall")
+	}
+	csLB.Status.RuleIDs = ruleIDs
+	return nil
+}
+
+// reconcileManagementClusterTag marks a rule as this management cluster's, taking over those of a
+// CloudStackLoadBalancer moved here.
+func (c *client) reconcileManagementClusterTag(ctx context.Context, rule *cloudstack.LoadBalancerRule) error {
+	for _, tag := range rule.Tags {
+		if tag.Key == ManagementClusterTagName && tag.Value == ManagementClusterID {
+			return nil
+		}
+	}
+	return c.ReconcileTags(ctx, ResourceTypeLoadBalancer, rule.Id, managementClusterTags())
+}
+
+// createOwnedLoadBalancerRule creates a CloudStackLoadBalancer's rule and tags it as owned by it, recording the job
+// creating it in the load balancer's status. The rule is tagged while the job runs, and removed again if it can't be
+// tagged, so that it isn't mistaken for someone else's.
+func (c *client) createOwnedLoadBalancerRule(
+	ctx context.Context,
+	csLB *infrav1.CloudStackLoadBalancer,
+	isoNet *infrav1.CloudStackIsolatedNetwork,
+	lb *infrav1.LoadBalancerSpec,
+	owner string,
+	mapping *infrav1.LoadBalancerPortMapping,
+	privatePort int,
+) (string, error) {
+	ruleID, err := c.createLoadBalancerRule(&csLB.Status.AsyncJob,
+		isoNet, lb, true, mapping.Name, int(mapping.PublicPort), privatePort, lb.AllowedCIDRsFor(mapping))
+	if ruleID == "" {
+		return "", err
+	}
+	tags := managementClusterTags()
+	tags[LoadBalancerTagName] = owner
+	if tagErr := c.AddTags(ctx, ResourceTypeLoadBalancer, ruleID, tags); tagErr != nil {
+		if deleteErr := c.deleteLoadBalancerRule(ruleID); deleteErr != nil {
+			tagErr = multierror.Append(tagErr, deleteErr)
+		}
+		return "", errors.Wrap(tagErr, "tagging load balancer rule")
+	}
+	return ruleID, err
+}
+
+// SetLoadBalancerRuleMembers makes the given VMs the only ones behind a load balancer rule.
+func (c *client) SetLoadBalancerRuleMembers(ctx context.Context, ruleID string, instanceIDs []string) error {
+	c, _, cancel := c.withTimeout(ctx, OperationNetwork)
+	defer cancel()
+
+	resp, err := c.cs.LoadBalancer.ListLoadBalancerRuleInstances(
+		c.cs.LoadBalancer.NewListLoadBalancerRuleInstancesParams(ruleID))
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return errors.Wrap(err, "listing load balancer rule members")
+	}
+	wanted := map[string]bool{}
+	for _, id := range instanceIDs {
+		wanted[id] = true
+	}
+	var toRemove []string
+	for _, instance := range resp.LoadBalancerRuleInstances {
+		if wanted[instance.Id] {
+			delete(wanted, instance.Id)
+		} else {
+			toRemove = append(toRemove, instance.Id)
+		}
+	}
+	toAdd := make([]string, 0, len(wanted))
+	for id := range wanted {
+		toAdd = append(toAdd, id)
+	}
+	sort.Strings(toAdd)
+
+	if len(toAdd) > 0 {
+		p := c.cs.LoadBalancer.NewAssignToLoadBalancerRuleParams(ruleID)
+		p.SetVirtualmachineids(toAdd)
+		if _, err := c.cs.LoadBalancer.AssignToLoadBalancerRule(p); err != nil {
+			c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+			return errors.Wrap(err, "assigning VMs to load balancer rule")
+		}
+	}
+	if len(toRemove) > 0 {
+		p := c.cs.LoadBalancer.NewRemoveFromLoadBalancerRuleParams(ruleID)
+		p.SetVirtualmachineids(toRemove)
+		if _, err := c.cs.LoadBalancer.RemoveFromLoadBalancerRule(p); err != nil {
+			c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+			return errors.Wrap(err, "removing VMs from load balancer rule")
+		}
+	}
+	return nil
+}
+
+// DeleteLoadBalancerRules removes the CloudStackLoadBalancer's rules from the network's public IP address and closes
+// their ports again.
+func (c *client) DeleteLoadBalancerRules(
+	ctx context.Context,
+	csLB *infrav1.CloudStackLoadBalancer,
+	isoNet *infrav1.CloudStackIsolatedNetwork,
+) error {
+	c, _, cancel := c.withTimeout(ctx, OperationNetwork)
+	defer cancel()
+
+	if isoNet.Status.PublicIPID == "" { // Nothing was set up.
+		return nil
+	}
+	rules, err := c.listLoadBalancerRules(isoNet)
+	if err != nil {
+		return err
+	}
+	ingress := map[int][]string{}
+	for port, rule := range rules {
+		if loadBalancerRuleOwner(rule) == loadBalancerOwnerName(csLB) {
+			if err := c.deleteLoadBalancerRule(rule.Id); err != nil {
+				return errors.Wrapf(err, "removing load balancer rule %s", rule.Name)
+			}
+			ingress[port] = nil
+		}
+	}
+	return errors.Wrap(c.reconcileIngressFirewallRules(&csLB.Status.AsyncJob, isoNet, ingress), "closing the ingress firewall")
+}
@@ -0,0 +1,249 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_test
+
+import (
+	"strconv"
+
+	csapi "github.com/apache/cloudstack-go/v2/cloudstack"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
+	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta2"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/test/simulator"
+)
+
+var _ = Describe("Load balancers", func() {
+	UseSimulator()
+
+	Context("Load balancer configuration", func() {
+		BeforeEach(func() {
+			dummies.SetDummyIsoNetToNameOnly()
+			dummies.CSFailureDomain1.Spec.Zone = dummies.Zone1
+			dummies.CSCluster.Spec.ControlPlaneEndpoint.Host = ""
+			sim.AddNetwork(dummies.Zone1.ID, "other-network", simulator.NetworkTypeShared, "10.20.0.0/24")
+		})
+
+		// rulesByPort indexes the simulator's load balancer rules by public port.
+		rulesByPort := func() map[string]csapi.LoadBalancerRule {
+			rules := map[string]csapi.LoadBalancerRule{}
+			for _, rule := range sim.LoadBalancerRules() {
+				rules[rule.Publicport] = rule
+			}
+			return rules
+		}
+		// allowedCIDRsByPort indexes the simulator's ingress firewall rules by port.
+		allowedCIDRsByPort := func() map[int]string {
+			cidrs := map[int]string{}
+			for _, rule := range sim.FirewallRules() {
+				cidrs[rule.Startport] = rule.Cidrlist
+			}
+			return cidrs
+		}
+
+		It("configures the isolated network's load balancer and follows changes to it", func() {
+			apiPort := strconv.Itoa(int(dummies.EndPointPort))
+			dummies.CSCluster.Spec.LoadBalancer = &infrav1.LoadBalancerSpec{
+				Algorithm:    "leastconn",
+				Stickiness:   &infrav1.LoadBalancerStickinessPolicy{Method: "SourceBased"},
+				HealthCheck:  &infrav1.LoadBalancerHealthCheckPolicy{PingPath: "/healthz", UnhealthyThreshold: 3},
+				AllowedCIDRs: []string{"10.0.0.0/8"},
+				AdditionalPorts: []infrav1.LoadBalancerPortMapping{
+					{Name: "konnectivity", PublicPort: 8132, AllowedCIDRs: []string{"10.1.0.0/16", "10.2.0.0/16"}}},
+			}
+			for i := 0; i < 2; i++ {
+				Ω(client.GetOrCreateIsolatedNetwork(
+					ctx,
+					dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).Should(Succeed())
+			}
+
+			rules := rulesByPort()
+			Ω(rules).Should(HaveLen(2))
+			Ω(rules[apiPort].Algorithm).Should(Equal("leastconn"))
+			Ω(rules["8132"].Algorithm).Should(Equal("leastconn"))
+			Ω(rules["8132"].Privateport).Should(Equal("8132"))
+			Ω(sim.LoadBalancerStickinessPolicies(rules[apiPort].Id)).Should(HaveLen(1))
+			Ω(sim.LoadBalancerStickinessPolicies(rules["8132"].Id)).Should(HaveLen(1))
+			healthChecks := sim.LoadBalancerHealthCheckPolicies(rules[apiPort].Id)
+			Ω(healthChecks).Should(HaveLen(1))
+			Ω(healthChecks[0].Pingpath).Should(Equal("/healthz"))
+			Ω(healthChecks[0].Unhealthcheckthresshold).Should(Equal(3))
+			Ω(sim.LoadBalancerHealthCheckPolicies(rules["8132"].Id)).Should(BeEmpty())
+			Ω(allowedCIDRsByPort()).Should(Equal(map[int]string{
+				int(dummies.EndPointPort): "10.0.0.0/8", 8132: "10.1.0.0/16,10.2.0.0/16"}))
+
+			// Control plane VMs go behind every forwarded port.
+			dummies.CSFailureDomain1.Spec.Zone.Network.ID = dummies.CSISONet1.Spec.ID
+			dummies.CSFailureDomain1.Spec.Zone.Network.Type = cloud.NetworkTypeIsolated
+			Ω(client.GetOrCreateVMInstance(ctx, dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster,
+				dummies.CSFailureDomain1, dummies.CSAffinityGroup, "")).Should(Succeed())
+			provider, err := client.ControlPlaneEndpointProvider(dummies.CSCluster, dummies.CSFailureDomain1, dummies.CSISONet1)
+			Ω(err).ShouldNot(HaveOccurred())
+			for i := 0; i < 2; i++ {
+				Ω(provider.AssignVMToControlPlaneEndpoint(ctx, *dummies.CSMachine1.Spec.InstanceID)).Should(Succeed())
+			}
+			Ω(sim.LoadBalancerRuleMembers(rules[apiPort].Id)).Should(ConsistOf(*dummies.CSMachine1.Spec.InstanceID))
+			Ω(sim.LoadBalancerRuleMembers(rules["8132"].Id)).Should(ConsistOf(*dummies.CSMachine1.Spec.InstanceID))
+
+			dummies.CSCluster.Spec.LoadBalancer = &infrav1.LoadBalancerSpec{
+				HealthCheck:  &infrav1.LoadBalancerHealthCheckPolicy{PingPath: "/readyz"},
+				AllowedCIDRs: []string{"192.168.0.0/16"},
+			}
+			Ω(client.GetOrCreateIsolatedNetwork(
+				ctx,
+				dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).Should(Succeed())
+
+			rules = rulesByPort()
+			Ω(rules).Should(HaveLen(1))
+			Ω(rules[apiPort].Algorithm).Should(Equal("roundrobin"))
+			Ω(sim.LoadBalancerStickinessPolicies(rules[apiPort].Id)).Should(BeEmpty())
+			healthChecks = sim.LoadBalancerHealthCheckPolicies(rules[apiPort].Id)
+			Ω(healthChecks).Should(HaveLen(1))
+			Ω(healthChecks[0].Pingpath).Should(Equal("/readyz"))
+			Ω(allowedCIDRsByPort()).Should(Equal(map[int]string{int(dummies.EndPointPort): "192.168.0.0/16"}))
+		})
+
+		It("restricts a network load balancer's rules to the allowlist", func() {
+			lbNet := sim.AddNetworkWithOffering(
+				dummies.Zone1.ID, "elb-network", simulator.SharedLBNetworkOffering, "10.30.0.0/24")
+			dummies.CSFailureDomain1.Spec.Zone.Network = infrav1.Network{
+				ID: lbNet.Id, Name: lbNet.Name, Type: cloud.NetworkTypeShared}
+			dummies.CSCluster.Spec.ControlPlaneEndpointProvider = infrav1.EndpointProviderNetworkLoadBalancer
+			dummies.CSCluster.Spec.LoadBalancer = &infrav1.LoadBalancerSpec{AllowedCIDRs: []string{"10.0.0.0/8"}}
+
+			provider, err := client.ControlPlaneEndpointProvider(dummies.CSCluster, dummies.CSFailureDomain1, nil)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(provider.GetOrCreateControlPlaneEndpoint(ctx)).Should(Succeed())
+			Ω(sim.LoadBalancerRules()).Should(HaveLen(1))
+			Ω(sim.LoadBalancerRules()[0].Cidrlist).Should(Equal("10.0.0.0/8"))
+			Ω(sim.FirewallRules()).Should(BeEmpty())
+		})
+	})
+
+	Context("Worker load balancers", func() {
+		var rules map[string]csapi.LoadBalancerRule
+
+		BeforeEach(func() {
+			dummies.SetDummyIsoNetToNameOnly()
+			dummies.CSFailureDomain1.Spec.Zone = dummies.Zone1
+			dummies.CSCluster.Spec.ControlPlaneEndpoint.Host = ""
+			dummies.CSCluster.Spec.LoadBalancer = &infrav1.LoadBalancerSpec{}
+			sim.AddNetwork(dummies.Zone1.ID, "other-network", simulator.NetworkTypeShared, "10.20.0.0/24")
+			Ω(client.GetOrCreateIsolatedNetwork(
+				ctx,
+				dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).Should(Succeed())
+			dummies.CSLoadBalancer1.Spec.AllowedCIDRs = []string{"10.0.0.0/8"}
+		})
+
+		// refreshRules indexes the simulator's load balancer rules by public port.
+		refreshRules := func() {
+			rules = map[string]csapi.LoadBalancerRule{}
+			for _, rule := range sim.LoadBalancerRules() {
+				rules[rule.Publicport] = rule
+			}
+		}
+		// allowedCIDRsByPort indexes the simulator's ingress firewall rules by port.
+		allowedCIDRsByPort := func() map[int]string {
+			cidrs := map[int]string{}
+			for _, rule := range sim.FirewallRules() {
+				cidrs[rule.Startport] = rule.Cidrlist
+			}
+			return cidrs
+		}
+
+		It("owns its rules alongside the control plane endpoint's and removes them again", func() {
+			for i := 0; i < 2; i++ {
+				Ω(client.GetOrCreateLoadBalancerRules(ctx, dummies.CSLoadBalancer1, dummies.CSISONet1)).Should(Succeed())
+			}
+			refreshRules()
+			Ω(rules).Should(HaveLen(3))
+			Ω(rules["80"].Privateport).Should(Equal("30080"))
+			Ω(sim.Tags(rules["443"].Id)).Should(HaveKeyWithValue(
+				cloud.LoadBalancerTagName, dummies.CSLoadBalancer1.Namespace+"/"+dummies.CSLoadBalancer1.Name))
+			Ω(dummies.CSLoadBalancer1.Status.RuleIDs).Should(Equal(map[string]string{
+				"http": rules["80"].Id, "https": rules["443"].Id}))
+			Ω(allowedCIDRsByPort()).Should(Equal(map[int]string{
+				int(dummies.EndPointPort): "0.0.0.0/0", 80: "10.0.0.0/8", 443: "10.0.0.0/8"}))
+
+			// Reconciling the control plane endpoint leaves the rules alone.
+			Ω(client.GetOrCreateIsolatedNetwork(
+				ctx,
+				dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).Should(Succeed())
+			Ω(sim.LoadBalancerRules()).Should(HaveLen(3))
+
+			dummies.CSLoadBalancer1.Spec.Rules = dummies.CSLoadBalancer1.Spec.Rules[:1]
+			Ω(client.GetOrCreateLoadBalancerRules(ctx, dummies.CSLoadBalancer1, dummies.CSISONet1)).Should(Succeed())
+			refreshRules()
+			Ω(rules).Should(HaveLen(2))
+			Ω(rules).ShouldNot(HaveKey("443"))
+			Ω(allowedCIDRsByPort()).Should(Equal(map[int]string{int(dummies.EndPointPort): "0.0.0.0/0", 80: "10.0.0.0/8"}))
+
+			Ω(client.DeleteLoadBalancerRules(ctx, dummies.CSLoadBalancer1, dummies.CSISONet1)).Should(Succeed())
+			refreshRules()
+			Ω(rules).Should(HaveLen(1))
+			Ω(rules).Should(HaveKey(strconv.Itoa(int(dummies.EndPointPort))))
+			Ω(allowedCIDRsByPort()).Should(Equal(map[int]string{int(dummies.EndPointPort): "0.0.0.0/0"}))
+		})
+
+		It("sets the members of its rules", func() {
+			Ω(client.GetOrCreateLoadBalancerRules(ctx, dummies.CSLoadBalancer1, dummies.CSISONet1)).Should(Succeed())
+			dummies.CSFailureDomain1.Spec.Zone.Network.ID = dummies.CSISONet1.Spec.ID
+			dummies.CSFailureDomain1.Spec.Zone.Network.Type = cloud.NetworkTypeIsolated
+			Ω(client.GetOrCreateVMInstance(ctx, dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster,
+				dummies.CSFailureDomain1, dummies.CSAffinityGroup, "")).Should(Succeed())
+			ruleID := dummies.CSLoadBalancer1.Status.RuleIDs["http"]
+
+			for i := 0; i < 2; i++ {
+				Ω(client.SetLoadBalancerRuleMembers(ctx, ruleID, []string{*dummies.CSMachine1.Spec.InstanceID})).Should(Succeed())
+			}
+			Ω(sim.LoadBalancerRuleMembers(ruleID)).Should(ConsistOf(*dummies.CSMachine1.Spec.InstanceID))
+
+			Ω(client.SetLoadBalancerRuleMembers(ctx, ruleID, nil)).Should(Succeed())
+			Ω(sim.LoadBalancerRuleMembers(ruleID)).Should(BeEmpty())
+		})
+
+		It("releases a retained VM from its rules and drops the tags marking it as CAPC's", func() {
+			Ω(client.GetOrCreateLoadBalancerRules(ctx, dummies.CSLoadBalancer1, dummies.CSISONet1)).Should(Succeed())
+			dummies.CSFailureDomain1.Spec.Zone.Network.ID = dummies.CSISONet1.Spec.ID
+			dummies.CSMachine1.Spec.AdditionalTags = map[string]string{"team": "storage"}
+			Ω(client.GetOrCreateVMInstance(ctx, dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster,
+				dummies.CSFailureDomain1, dummies.CSAffinityGroup, "")).Should(Succeed())
+			Ω(client.ReconcileVMInstanceTags(ctx, dummies.CSMachine1, dummies.CSCluster)).Should(Succeed())
+			instanceID := *dummies.CSMachine1.Spec.InstanceID
+			ruleID := dummies.CSLoadBalancer1.Status.RuleIDs["http"]
+			Ω(client.SetLoadBalancerRuleMembers(ctx, ruleID, []string{instanceID})).Should(Succeed())
+
+			for i := 0; i < 2; i++ {
+				Ω(client.ReleaseVMInstance(ctx, dummies.CSMachine1, dummies.CSCluster)).Should(Succeed())
+			}
+			Ω(sim.VirtualMachines()).Should(HaveLen(1))
+			Ω(sim.LoadBalancerRuleMembers(ruleID)).Should(BeEmpty())
+			Ω(sim.Tags(instanceID)).Should(Equal(map[string]string{"team": "storage"}))
+			for _, volume := range sim.Volumes() {
+				Ω(sim.Tags(volume.Id)).Should(Equal(map[string]string{"team": "storage"}))
+			}
+		})
+
+		It("refuses ports forwarded by rules it doesn't own", func() {
+			dummies.CSLoadBalancer1.Spec.Rules[0].PublicPort = dummies.EndPointPort
+			Ω(client.GetOrCreateLoadBalancerRules(ctx, dummies.CSLoadBalancer1, dummies.CSISONet1)).
+				Should(MatchError(ContainSubstring("already forwarded")))
+			Ω(sim.LoadBalancerRules()).Should(HaveLen(1))
+		})
+	})
+})
@@ -0,0 +1,127 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
+)
+
+// MachinePoolIface manages the CloudStack instance groups backing CloudStackMachinePools, and their VM instances.
+type MachinePoolIface interface {
+	GetOrCreateInstanceGroup(ctx context.Context, name string) (string, error)
+	DeleteInstanceGroup(ctx context.Context, id string) error
+	ListInstanceGroupVMs(ctx context.Context, groupID string) ([]infrav1.CloudStackMachinePoolInstance, error)
+	GetOrCreateInstanceGroupVM(context.Context, *infrav1.CloudStackMachine, *infrav1.CloudStackCluster, *infrav1.CloudStackFailureDomain, string, string) error
+}
+
+// GetOrCreateInstanceGroup returns the ID of the client's account's instance group of the passed name, creating the
+// group if there's none.
+func (c *client) GetOrCreateInstanceGroup(ctx context.Context, name string) (string, error) {
+	c, _, cancel := c.withTimeout(ctx, OperationDefault)
+	defer cancel()
+
+	if groupID, err := c.instanceGroupID(name); err != nil || groupID != "" {
+		return groupID, err
+	}
+	group, err := c.cs.VMGroup.CreateInstanceGroup(c.cs.VMGroup.NewCreateInstanceGroupParams(name))
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return "", errors.Wrapf(err, "creating instance group %s", name)
+	} else if group.Id != "" {
+		return group.Id, nil
+	}
+	// The CloudStack client doesn't unwrap the group from the creation response, so it's looked up instead.
+	if groupID, err := c.instanceGroupID(name); err != nil || groupID != "" {
+		return groupID, err
+	}
+	return "", errors.Errorf("instance group %s not found after creating it", name)
+}
+
+// instanceGroupID returns the ID of the client's account's instance group of the passed name, or an empty ID if
+// there's none.
+func (c *client) instanceGroupID(name string) (string, error) {
+	p := c.cs.VMGroup.NewListInstanceGroupsParams()
+	p.SetName(name)
+	resp, err := c.cs.VMGroup.ListInstanceGroups(p)
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return "", errors.Wrapf(err, "listing instance groups named %s", name)
+	}
+	for _, group := range resp.InstanceGroups {
+		if group.Name == name { // Listing matches names partially.
+			return group.Id, nil
+		}
+	}
+	return "", nil
+}
+
+// DeleteInstanceGroup deletes an instance group. Its VM instances are left as they are, outside of any group.
+func (c *client) DeleteInstanceGroup(ctx context.Context, id string) error {
+	c, _, cancel := c.withTimeout(ctx, OperationDefault)
+	defer cancel()
+
+	_, err := c.cs.VMGroup.DeleteInstanceGroup(c.cs.VMGroup.NewDeleteInstanceGroupParams(id))
+	if err != nil && strings.Contains(strings.ToLower(err.Error()), "unable to find uuid for id") {
+		return nil // Already gone.
+	} else if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return errors.Wrapf(err, "deleting instance group with ID %s", id)
+	}
+	return nil
+}
+
+// ListInstanceGroupVMs lists the VM instances in an instance group, other than those being expunged.
+func (c *client) ListInstanceGroupVMs(ctx context.Context, groupID string) ([]infrav1.CloudStackMachinePoolInstance, error) {
+	c, _, cancel := c.withTimeout(ctx, OperationResolve)
+	defer cancel()
+
+	p := c.cs.VirtualMachine.NewListVirtualMachinesParams()
+	p.SetGroupid(groupID)
+	resp, err := c.cs.VirtualMachine.ListVirtualMachines(p)
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return nil, errors.Wrapf(err, "listing VM instances of instance group with ID %s", groupID)
+	}
+	instances := make([]infrav1.CloudStackMachinePoolInstance, 0, len(resp.VirtualMachines))
+	for _, vm := range resp.VirtualMachines {
+		if vm.State == "Expunging" || vm.State == "Expunged" {
+			continue
+		}
+		instances = append(instances, infrav1.CloudStackMachinePoolInstance{ID: vm.Id, Name: vm.Name, State: vm.State})
+	}
+	return instances, nil
+}
+
+// GetOrCreateInstanceGroupVM fetches or creates the VM instance a machine stands in for, putting it in the named
+// instance group. The instance is displayed under the machine's name, and has no affinity group.
+func (c *client) GetOrCreateInstanceGroupVM(
+	ctx context.Context,
+	csMachine *infrav1.CloudStackMachine,
+	csCluster *infrav1.CloudStackCluster,
+	fd *infrav1.CloudStackFailureDomain,
+	group string,
+	userData string) error {
+	c, ctx, cancel := c.withTimeout(ctx, OperationCreateVM)
+	defer cancel()
+
+	return c.getOrCreateVMInstance(ctx, csMachine, csMachine.Name, csCluster, fd, nil, userData, group)
+}
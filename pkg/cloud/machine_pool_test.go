@@ -0,0 +1,47 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta2"
+)
+
+var _ = Describe("Machine pools", func() {
+	UseSimulator()
+
+	It("deploys VMs into an instance group and lists them by group", func() {
+		groupID, err := client.GetOrCreateInstanceGroup(ctx, "pool-fd1")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(groupID).ShouldNot(BeEmpty())
+		Ω(client.GetOrCreateInstanceGroup(ctx, "pool-fd1")).Should(Equal(groupID))
+		Ω(sim.InstanceGroups()).Should(HaveLen(1))
+
+		dummies.CSMachine1.Spec.InstanceID = nil
+		Ω(client.GetOrCreateInstanceGroupVM(ctx, dummies.CSMachine1, dummies.CSCluster, dummies.CSFailureDomain1,
+			"pool-fd1", "userdata")).Should(Succeed())
+		instances, err := client.ListInstanceGroupVMs(ctx, groupID)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(instances).Should(ConsistOf(HaveField("ID", *dummies.CSMachine1.Spec.InstanceID)))
+
+		Ω(client.DeleteInstanceGroup(ctx, groupID)).Should(Succeed())
+		Ω(client.DeleteInstanceGroup(ctx, groupID)).Should(Succeed())
+		Ω(sim.InstanceGroups()).Should(BeEmpty())
+		Ω(sim.VirtualMachines()[0].Groupid).Should(BeEmpty())
+	})
+})
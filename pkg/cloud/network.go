@@ -17,14 +17,17 @@ limitations under the License.
 package cloud
 
 import (
+	"context"
+	"net"
+
 	"github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
 	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
 )
 
 type NetworkIface interface {
-	ResolveNetwork(*infrav1.Network) error
-	RemoveClusterTagFromNetwork(*infrav1.CloudStackCluster, infrav1.Network) error
+	ResolveNetwork(context.Context, *infrav1.Network) error
+	RemoveClusterTagFromNetwork(context.Context, *infrav1.CloudStackCluster, infrav1.Network) error
 }
 
 const (
@@ -32,6 +35,7 @@ const (
 	K8sDefaultAPIPort   = 6443
 	NetworkTypeIsolated = "Isolated"
 	NetworkTypeShared   = "Shared"
+	NetworkTypeVPC      = "VPC"
 	NetworkProtocolTCP  = "tcp"
 )
 
@@ -45,7 +49,10 @@ func NetworkExists(net infrav1.Network) bool {
 }
 
 // ResolveNetwork fetches networks' ID, Name, and Type.
-func (c *client) ResolveNetwork(net *infrav1.Network) (retErr error) {
+func (c *client) ResolveNetwork(ctx context.Context, net *infrav1.Network) (retErr error) {
+	c, _, cancel := c.withTimeout(ctx, OperationResolve)
+	defer cancel()
+
 	// TODO rebuild this to consider cases with networks in many zones.
 	// Use ListNetworks instead.
 	netName := net.Name
@@ -76,20 +83,39 @@ func (c *client) ResolveNetwork(net *infrav1.Network) (retErr error) {
 	return nil
 }
 
+// gatewayAndNetmask returns the gateway and netmask of a network with the passed CIDR. The gateway defaults to the
+// CIDR's first address.
+func gatewayAndNetmask(cidr, gateway string) (string, string, error) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "parsing CIDR %s", cidr)
+	}
+	if gateway == "" {
+		first := make(net.IP, len(ipNet.IP))
+		copy(first, ipNet.IP)
+		first[len(first)-1]++
+		gateway = first.String()
+	}
+	return gateway, net.IP(ipNet.Mask).String(), nil
+}
+
 func generateNetworkTagName(csCluster *infrav1.CloudStackCluster) string {
 	return ClusterTagNamePrefix + string(csCluster.UID)
 }
 
 // RemoveClusterTagFromNetwork the cluster in use tag from a network.
-func (c *client) RemoveClusterTagFromNetwork(csCluster *infrav1.CloudStackCluster, net infrav1.Network) (retError error) {
-	tags, err := c.GetTags(ResourceTypeNetwork, net.ID)
+func (c *client) RemoveClusterTagFromNetwork(ctx context.Context, csCluster *infrav1.CloudStackCluster, net infrav1.Network) (retError error) {
+	c, ctx, cancel := c.withTimeout(ctx, OperationNetwork)
+	defer cancel()
+
+	tags, err := c.GetTags(ctx, ResourceTypeNetwork, net.ID)
 	if err != nil {
 		return err
 	}
 
 	ClusterTagName := generateNetworkTagName(csCluster)
 	if tagValue := tags[ClusterTagName]; tagValue != "" {
-		if err = c.DeleteTags(ResourceTypeNetwork, net.ID, map[string]string{ClusterTagName: tagValue}); err != nil {
+		if err = c.DeleteTags(ctx, ResourceTypeNetwork, net.ID, map[string]string{ClusterTagName: tagValue}); err != nil {
 			return err
 		}
 	}
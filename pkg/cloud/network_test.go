@@ -56,19 +56,19 @@ var _ = Describe("Network", func() {
 			ns.EXPECT().GetNetworkByName(dummies.ISONet1.Name).Return(nil, 0, nil)
 			ns.EXPECT().GetNetworkByID(dummies.ISONet1.ID).Return(dummies.CAPCNetToCSAPINet(&dummies.ISONet1), 1, nil)
 
-			Ω(client.ResolveNetwork(&dummies.ISONet1)).Should(Succeed())
+			Ω(client.ResolveNetwork(ctx, &dummies.ISONet1)).Should(Succeed())
 		})
 
 		It("resolves network by Name", func() {
 			ns.EXPECT().GetNetworkByName(dummies.ISONet1.Name).Return(dummies.CAPCNetToCSAPINet(&dummies.ISONet1), 1, nil)
 
-			Ω(client.ResolveNetwork(&dummies.ISONet1)).Should(Succeed())
+			Ω(client.ResolveNetwork(ctx, &dummies.ISONet1)).Should(Succeed())
 		})
 
 		It("When there exists more than one network with the same name", func() {
 			ns.EXPECT().GetNetworkByName(dummies.ISONet1.Name).Return(dummies.CAPCNetToCSAPINet(&dummies.ISONet1), 2, nil)
 			ns.EXPECT().GetNetworkByID(dummies.ISONet1.ID).Return(nil, 2, errors.New("There is more then one result for Network UUID"))
-			err := client.ResolveNetwork(&dummies.ISONet1)
+			err := client.ResolveNetwork(ctx, &dummies.ISONet1)
 			Ω(err).ShouldNot(Succeed())
 			Ω(err.Error()).Should(ContainSubstring(fmt.Sprintf("expected 1 Network with name %s, but got %d", dummies.ISONet1.Name, 2)))
 		})
@@ -83,7 +83,7 @@ var _ = Describe("Network", func() {
 			rs.EXPECT().DeleteTags(rtdp).Return(&csapi.DeleteTagsResponse{}, nil)
 			rs.EXPECT().NewListTagsParams().Return(rtlp)
 			rs.EXPECT().ListTags(rtlp).Return(createdByCAPCResponse, nil)
-			Ω(client.RemoveClusterTagFromNetwork(dummies.CSCluster, dummies.ISONet1)).Should(Succeed())
+			Ω(client.RemoveClusterTagFromNetwork(ctx, dummies.CSCluster, dummies.ISONet1)).Should(Succeed())
 		})
 	})
 })
@@ -0,0 +1,188 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
+)
+
+type OrphanIface interface {
+	ListCAPCResources(context.Context) ([]CAPCResource, error)
+	DisposeCAPCResource(context.Context, *CAPCResource) error
+}
+
+// CAPCResource is a CloudStack resource tagged as created by CAPC, or a load balancer rule tagged with the
+// CloudStackLoadBalancer owning it.
+type CAPCResource struct {
+	Type ResourceType
+	ID   string
+	// ClusterUIDs are the UIDs of the CloudStackClusters whose cluster tags the resource carries.
+	ClusterUIDs []string
+	// LoadBalancer is the namespace/name of the CloudStackLoadBalancer owning a load balancer rule.
+	LoadBalancer string
+	// ManagementClusterID identifies the management cluster the resource belongs to. Empty for resources tagged before
+	// CAPC put it on them.
+	ManagementClusterID string
+	// AsyncJob is the CloudStack job expunging a VM, while it runs.
+	AsyncJob *infrav1.AsyncJob
+}
+
+// capcResourceDisposalOrder orders resource types so that each is disposed of before the resources it depends on.
+// Volumes are left out: they are disposed of with their VMs.
+var capcResourceDisposalOrder = map[ResourceType]int{
+	ResourceTypeUserVM:        0,
+	ResourceTypeLoadBalancer:  1,
+	ResourceTypeIPAddress:     2,
+	ResourceTypeNetwork:       3,
+	ResourceTypeVPC:           4,
+	ResourceTypeAffinityGroup: 5,
+}
+
+// CAPCResourceTypes returns the types of the resources ListCAPCResources lists, in disposal order.
+func CAPCResourceTypes() []ResourceType {
+	types := make([]ResourceType, 0, len(capcResourceDisposalOrder))
+	for rType := range capcResourceDisposalOrder {
+		types = append(types, rType)
+	}
+	sort.Slice(types, func(i, j int) bool { return capcResourceDisposalOrder[types[i]] < capcResourceDisposalOrder[types[j]] })
+	return types
+}
+
+// ListCAPCResources lists the resources visible to the client's account that CAPC created, in disposal order. VMs CAPC
+// adopted rather than created are left out.
+func (c *client) ListCAPCResources(ctx context.Context) ([]CAPCResource, error) {
+	c, _, cancel := c.withTimeout(ctx, OperationResolve)
+	defer cancel()
+
+	p := c.cs.Resourcetags.NewListTagsParams()
+	p.SetListall(true)
+	resp, err := c.cs.Resourcetags.ListTags(p)
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return nil, errors.Wrap(err, "listing resource tags")
+	}
+
+	type resourceKey struct {
+		rType ResourceType
+		id    string
+	}
+	resources := map[resourceKey]*CAPCResource{}
+	createdByCAPC, adoptedByCAPC := map[resourceKey]bool{}, map[resourceKey]bool{}
+	for _, tag := range resp.Tags {
+		key := resourceKey{rType: ResourceType(tag.Resourcetype), id: tag.Resourceid}
+		if _, found := capcResourceDisposalOrder[key.rType]; !found {
+			continue
+		}
+		resource, found := resources[key]
+		if !found {
+			resource = &CAPCResource{Type: key.rType, ID: key.id}
+			resources[key] = resource
+		}
+		switch {
+		case tag.Key == CreatedByCAPCTagName:
+			createdByCAPC[key] = true
+		case tag.Key == AdoptedByCAPCTagName:
+			adoptedByCAPC[key] = true
+		case strings.HasPrefix(tag.Key, ClusterTagNamePrefix):
+			resource.ClusterUIDs = append(resource.ClusterUIDs, strings.TrimPrefix(tag.Key, ClusterTagNamePrefix))
+		case tag.Key == LoadBalancerTagName && key.rType == ResourceTypeLoadBalancer:
+			resource.LoadBalancer = tag.Value
+		case tag.Key == ManagementClusterTagName:
+			resource.ManagementClusterID = tag.Value
+		}
+	}
+
+	capcResources := make([]CAPCResource, 0, len(resources))
+	for key, resource := range resources {
+		if (createdByCAPC[key] && !adoptedByCAPC[key]) || resource.LoadBalancer != "" {
+			sort.Strings(resource.ClusterUIDs)
+			capcResources = append(capcResources, *resource)
+		}
+	}
+	sort.Slice(capcResources, func(i, j int) bool {
+		if capcResources[i].Type != capcResources[j].Type {
+			return capcResourceDisposalOrder[capcResources[i].Type] < capcResourceDisposalOrder[capcResources[j].Type]
+		}
+		return capcResources[i].ID < capcResources[j].ID
+	})
+	return capcResources, nil
+}
+
+// DisposeCAPCResource removes a resource CAPC created. Resources still in use by others, like a network with VMs
+// that CAPC doesn't manage, fail to be removed. VMs are expunged by an async job recorded in the resource, which is
+// polled on later calls until it's done.
+func (c *client) DisposeCAPCResource(ctx context.Context, resource *CAPCResource) error {
+	c, ctx, cancel := c.withTimeout(ctx, OperationDestroyVM)
+	defer cancel()
+
+	var err error
+	switch resource.Type {
+	case ResourceTypeUserVM:
+		err = c.expungeVMInstance(ctx, resource)
+	case ResourceTypeLoadBalancer:
+		err = c.deleteLoadBalancerRule(resource.ID)
+	case ResourceTypeIPAddress:
+		err = c.releasePublicIPAddress(ctx, resource.ID)
+	case ResourceTypeNetwork:
+		_, err = c.cs.Network.DeleteNetwork(c.cs.Network.NewDeleteNetworkParams(resource.ID))
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+	case ResourceTypeVPC:
+		_, err = c.cs.VPC.DeleteVPC(c.cs.VPC.NewDeleteVPCParams(resource.ID))
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+	case ResourceTypeAffinityGroup:
+		err = c.DeleteAffinityGroup(ctx, &AffinityGroup{ID: resource.ID})
+	default:
+		return errors.Errorf("disposing of %s resources is not supported", resource.Type)
+	}
+	return errors.Wrapf(err, "disposing of %s with ID %s", resource.Type, resource.ID)
+}
+
+// expungeVMInstance expunges a VM instance along with its data disks, the way the VM of a machine being deleted is.
+func (c *client) expungeVMInstance(ctx context.Context, resource *CAPCResource) error {
+	csMachine := &infrav1.CloudStackMachine{}
+	csMachine.Spec.InstanceID = &resource.ID
+	csMachine.Spec.DeletionPolicy = infrav1.DeletionPolicyExpunge
+	csMachine.Spec.DataDiskDeletionPolicy = infrav1.DataDiskDeletionPolicyDelete
+	csMachine.Status.AsyncJob = resource.AsyncJob
+	err := c.DestroyVMInstance(ctx, csMachine)
+	resource.AsyncJob = csMachine.Status.AsyncJob
+	return err
+}
+
+// releasePublicIPAddress disassociates a public IP address and drops its CAPC creation tag. Source NAT addresses are
+// released with their network instead.
+func (c *client) releasePublicIPAddress(ctx context.Context, id string) error {
+	publicIP, count, err := c.cs.Address.GetPublicIpAddressByID(id)
+	if err != nil && count != 0 {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return err
+	} else if count == 0 || publicIP.Issourcenat {
+		return nil
+	}
+	if _, err := c.cs.Address.DisassociateIpAddress(c.cs.Address.NewDisassociateIpAddressParams(id)); err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return err
+	}
+	// Drop the tag last, so a failed release is retried.
+	return c.DeleteCreatedByCAPCTag(ctx, ResourceTypeIPAddress, id)
+}
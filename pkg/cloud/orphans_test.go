@@ -0,0 +1,140 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
+	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta2"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/test/simulator"
+)
+
+var _ = Describe("Orphaned resources", func() {
+	UseSimulator()
+
+	BeforeEach(func() {
+		cloud.ManagementClusterID = "management-cluster"
+		DeferCleanup(func() { cloud.ManagementClusterID = "" })
+		dummies.SetDummyIsoNetToNameOnly()
+		dummies.CSFailureDomain1.Spec.Zone = dummies.Zone1
+		dummies.CSCluster.Spec.ControlPlaneEndpoint.Host = ""
+		sim.AddNetwork(dummies.Zone1.ID, "other-network", simulator.NetworkTypeShared, "10.20.0.0/24")
+		Ω(client.GetOrCreateIsolatedNetwork(ctx, dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).
+			Should(Succeed())
+		dummies.CSFailureDomain1.Spec.Zone.Network.ID = dummies.CSISONet1.Spec.ID
+		Ω(client.GetOrCreateVMInstance(ctx, dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster,
+			dummies.CSFailureDomain1, dummies.CSAffinityGroup, "userdata")).Should(Succeed())
+		Ω(client.ReconcileVMInstanceTags(ctx, dummies.CSMachine1, dummies.CSCluster)).Should(Succeed())
+		Ω(client.GetOrCreateAffinityGroup(ctx, dummies.AffinityGroup)).Should(Succeed())
+		Ω(client.ReconcileTags(ctx, cloud.ResourceTypeAffinityGroup, dummies.AffinityGroup.ID,
+			cloud.ClusterResourceTags(dummies.CSCluster, nil))).Should(Succeed())
+	})
+
+	It("lists the resources CAPC created in disposal order, with the clusters using them", func() {
+		resources, err := client.ListCAPCResources(ctx)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		clusterUIDs := []string{string(dummies.CSCluster.UID)}
+		Ω(resources).Should(Equal([]cloud.CAPCResource{
+			{Type: cloud.ResourceTypeUserVM, ID: *dummies.CSMachine1.Spec.InstanceID, ClusterUIDs: clusterUIDs,
+				ManagementClusterID: "management-cluster"},
+			{Type: cloud.ResourceTypeIPAddress, ID: dummies.CSISONet1.Status.PublicIPID, ClusterUIDs: clusterUIDs,
+				ManagementClusterID: "management-cluster"},
+			{Type: cloud.ResourceTypeNetwork, ID: dummies.CSISONet1.Spec.ID, ClusterUIDs: clusterUIDs,
+				ManagementClusterID: "management-cluster"},
+			{Type: cloud.ResourceTypeAffinityGroup, ID: dummies.AffinityGroup.ID, ClusterUIDs: clusterUIDs,
+				ManagementClusterID: "management-cluster"},
+		}))
+	})
+
+	It("marks the resources of a cluster moved to another management cluster as that one's", func() {
+		cloud.ManagementClusterID = "target-management-cluster"
+		movedCluster := dummies.CSCluster.DeepCopy()
+		movedCluster.UID = "moved"
+		Ω(client.AddClusterTag(ctx, cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID, movedCluster)).Should(Succeed())
+		Ω(client.AddClusterTag(ctx, cloud.ResourceTypeIPAddress, dummies.CSISONet1.Status.PublicIPID, movedCluster)).
+			Should(Succeed())
+		Ω(client.ReconcileVMInstanceTags(ctx, dummies.CSMachine1, movedCluster)).Should(Succeed())
+		Ω(client.ReconcileTags(ctx, cloud.ResourceTypeAffinityGroup, dummies.AffinityGroup.ID,
+			cloud.ClusterResourceTags(movedCluster, nil))).Should(Succeed())
+
+		resources, err := client.ListCAPCResources(ctx)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(resources).Should(HaveLen(4))
+		for _, resource := range resources {
+			Ω(resource.ManagementClusterID).Should(Equal("target-management-cluster"))
+			Ω(resource.ClusterUIDs).Should(ContainElement("moved"))
+		}
+	})
+
+	It("leaves out VMs CAPC adopted", func() {
+		dummies.CSMachine1.Spec.AdoptInstance = true
+		Ω(client.ReconcileVMInstanceTags(ctx, dummies.CSMachine1, dummies.CSCluster)).Should(Succeed())
+
+		resources, err := client.ListCAPCResources(ctx)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(resources).ShouldNot(ContainElement(HaveField("Type", cloud.ResourceTypeUserVM)))
+	})
+
+	It("leaves out VMs destroyed without being expunged, so they can still be recovered", func() {
+		dummies.CSMachine1.Spec.DeletionPolicy = infrav1.DeletionPolicyDestroy
+		dummies.CSMachine1.Spec.AdditionalTags = map[string]string{"team": "storage"}
+		Ω(client.ReconcileVMInstanceTags(ctx, dummies.CSMachine1, dummies.CSCluster)).Should(Succeed())
+		for i := 0; i < 2; i++ {
+			Ω(client.DestroyVMInstance(ctx, dummies.CSMachine1)).Should(Succeed())
+		}
+		Ω(sim.VirtualMachines()).Should(ConsistOf(HaveField("State", "Destroyed")))
+		Ω(sim.Tags(*dummies.CSMachine1.Spec.InstanceID)).Should(Equal(map[string]string{"team": "storage"}))
+
+		resources, err := client.ListCAPCResources(ctx)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(resources).ShouldNot(ContainElement(HaveField("Type", cloud.ResourceTypeUserVM)))
+	})
+
+	It("expunges VMs with an async job recorded in the resource", func() {
+		sim.HoldJobs("destroyVirtualMachine")
+		vm := cloud.CAPCResource{Type: cloud.ResourceTypeUserVM, ID: *dummies.CSMachine1.Spec.InstanceID}
+		Ω(cloud.IsJobPending(client.DisposeCAPCResource(ctx, &vm))).Should(BeTrue())
+		Ω(vm.AsyncJob).ShouldNot(BeNil())
+		Ω(vm.AsyncJob.Command).Should(Equal(cloud.CommandDestroyVM))
+
+		sim.ReleaseJobs("destroyVirtualMachine")
+		Ω(client.DisposeCAPCResource(ctx, &vm)).Should(Succeed())
+		Ω(vm.AsyncJob).Should(BeNil())
+		Ω(sim.VirtualMachines()).Should(BeEmpty())
+	})
+
+	It("disposes of the resources CAPC created", func() {
+		resources, err := client.ListCAPCResources(ctx)
+		Ω(err).ShouldNot(HaveOccurred())
+		for i := range resources {
+			Ω(client.DisposeCAPCResource(ctx, &resources[i])).Should(Succeed())
+		}
+
+		Ω(sim.VirtualMachines()).Should(BeEmpty())
+		Ω(sim.Volumes()).Should(BeEmpty())
+		Ω(sim.Networks()).Should(HaveLen(2)) // The shared networks CAPC didn't create.
+		Ω(sim.AffinityGroups()).Should(BeEmpty())
+		Ω(sim.LoadBalancerRules()).Should(BeEmpty())
+		for _, ip := range sim.PublicIPAddresses() {
+			Ω(ip.State).Should(Equal("Free"))
+		}
+		Ω(client.ListCAPCResources(ctx)).Should(BeEmpty())
+	})
+})
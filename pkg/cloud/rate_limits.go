@@ -0,0 +1,267 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	corev1 "k8s.io/api/core/v1"
+
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/metrics"
+)
+
+const (
+	ClientQPSKey         = "client-qps"
+	ClientBurstKey       = "client-burst"
+	ClientMaxInFlightKey = "client-max-in-flight"
+)
+
+// DefaultClientRateLimits keep the steady polling of many clusters' machines from flooding a management server, while
+// letting the bursts of requests of creating a cluster through.
+var DefaultClientRateLimits = ClientRateLimits{QPS: 20, Burst: 100, MaxInFlight: 20}
+
+// The backoff applied to an endpoint throttling requests, doubling while it keeps doing so.
+const (
+	minThrottleBackoff = time.Second
+	maxThrottleBackoff = time.Minute
+)
+
+// ClientRateLimits bound the CloudStack API requests made to an endpoint, by the clients of all its users together.
+type ClientRateLimits struct {
+	// QPS is the rate requests are made at once a burst of them used up the endpoint's tokens.
+	QPS float64
+	// Burst is the number of requests made right away before QPS applies.
+	Burst int
+	// MaxInFlight is the number of requests awaiting their responses at once.
+	MaxInFlight int
+}
+
+// GetClientRateLimits returns the rate limits from the passed config map. Invalid and non-positive values are ignored.
+func GetClientRateLimits(clientConfig *corev1.ConfigMap) ClientRateLimits {
+	limits := DefaultClientRateLimits
+	if clientConfig == nil {
+		return limits
+	}
+	if qps, err := strconv.ParseFloat(clientConfig.Data[ClientQPSKey], 64); err == nil && qps > 0 {
+		limits.QPS = qps
+	}
+	if burst, err := strconv.Atoi(clientConfig.Data[ClientBurstKey]); err == nil && burst > 0 {
+		limits.Burst = burst
+	}
+	if maxInFlight, err := strconv.Atoi(clientConfig.Data[ClientMaxInFlightKey]); err == nil && maxInFlight > 0 {
+		limits.MaxInFlight = maxInFlight
+	}
+	return limits
+}
+
+// endpointLimiter holds back the requests made to a CloudStack API endpoint to its rate limits, and backs off while
+// the endpoint throttles them.
+type endpointLimiter struct {
+	endpoint string
+	tokens   *rate.Limiter
+	metrics  metrics.ClientMetrics
+
+	mu           sync.Mutex
+	limits       ClientRateLimits
+	inFlight     int
+	slotFreed    chan struct{} // Closed and replaced whenever a request got its response, or MaxInFlight changed.
+	backoff      time.Duration
+	backoffUntil time.Time
+}
+
+var endpointLimiters = map[string]*endpointLimiter{}
+var endpointLimitersMutex sync.Mutex
+
+// limiterFor returns the limiter shared by the clients of an endpoint. The limiter of an endpoint whose limits changed
+// is updated in place, so the clients made before hold to the new limits too.
+func limiterFor(apiURL string, limits ClientRateLimits) *endpointLimiter {
+	endpointLimitersMutex.Lock()
+	defer endpointLimitersMutex.Unlock()
+
+	if l, found := endpointLimiters[apiURL]; found {
+		l.setLimits(limits)
+		return l
+	}
+	l := &endpointLimiter{
+		endpoint:  apiURL,
+		limits:    limits,
+		tokens:    rate.NewLimiter(rate.Limit(limits.QPS), limits.Burst),
+		slotFreed: make(chan struct{}),
+		metrics:   metrics.NewClientMetrics(),
+	}
+	endpointLimiters[apiURL] = l
+	return l
+}
+
+// setLimits changes the limits requests are held to, including those already waiting.
+func (l *endpointLimiter) setLimits(limits ClientRateLimits) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.limits == limits {
+		return
+	}
+	l.tokens.SetLimit(rate.Limit(limits.QPS))
+	l.tokens.SetBurst(limits.Burst)
+	if limits.MaxInFlight != l.limits.MaxInFlight {
+		l.freeSlot()
+	}
+	l.limits = limits
+}
+
+// freeSlot wakes the requests waiting for one of the endpoint's in-flight slots. l.mu must be held.
+func (l *endpointLimiter) freeSlot() {
+	close(l.slotFreed)
+	l.slotFreed = make(chan struct{})
+}
+
+// wait blocks until a request may be made to the endpoint, or ctx is done. The returned func must be called once the
+// request got its response.
+func (l *endpointLimiter) wait(ctx context.Context) (release func(), err error) {
+	start := time.Now()
+	defer func() { l.metrics.ObserveQueueWait(l.endpoint, time.Since(start)) }()
+
+	l.mu.Lock()
+	backoff := time.Until(l.backoffUntil)
+	l.mu.Unlock()
+	if backoff > 0 {
+		timer := time.NewTimer(backoff)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if err := l.tokens.Wait(ctx); err != nil {
+		return nil, err
+	}
+	for {
+		l.mu.Lock()
+		if l.inFlight < l.limits.MaxInFlight {
+			l.inFlight++
+			l.mu.Unlock()
+			break
+		}
+		slotFreed := l.slotFreed
+		l.mu.Unlock()
+		select {
+		case <-slotFreed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	l.metrics.AddInFlight(l.endpoint, 1)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			l.inFlight--
+			l.freeSlot()
+			l.mu.Unlock()
+			l.metrics.AddInFlight(l.endpoint, -1)
+		})
+	}, nil
+}
+
+// observe backs off from the endpoint when it throttled a request, for twice as long as before if it did so again
+// right after the last backoff. Responses of any other status reset the backoff.
+func (l *endpointLimiter) observe(statusCode int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if statusCode != http.StatusTooManyRequests {
+		l.backoff = 0
+		return
+	}
+	l.metrics.IncThrottled(l.endpoint)
+	if time.Now().Before(l.backoffUntil) {
+		return // Made before the backoff started.
+	}
+	l.backoff *= 2
+	if l.backoff < minThrottleBackoff {
+		l.backoff = minThrottleBackoff
+	} else if l.backoff > maxThrottleBackoff {
+		l.backoff = maxThrottleBackoff
+	}
+	l.backoffUntil = time.Now().Add(l.backoff)
+}
+
+// rateLimitedTransport makes HTTP requests within the rate limits of their CloudStack API endpoint. Requests the
+// endpoint throttles are made again once its backoff is waited out, until their context is done.
+type rateLimitedTransport struct {
+	limiter *endpointLimiter
+	base    http.RoundTripper
+}
+
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for {
+		release, err := t.limiter.wait(req.Context())
+		if err != nil {
+			return nil, err
+		}
+		resp, err := t.base.RoundTrip(req)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		t.limiter.observe(resp.StatusCode)
+		if resp.StatusCode != http.StatusTooManyRequests || (req.Body != nil && req.GetBody == nil) {
+			// The request is in flight until its response is read.
+			resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+			return resp, nil
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		release()
+		if req, err = retriableRequest(req); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// retriableRequest returns a copy of a request to make it again, with its body rewound.
+func retriableRequest(req *http.Request) (*http.Request, error) {
+	if req.GetBody == nil {
+		return req, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	retry := req.Clone(req.Context())
+	retry.Body = body
+	return retry, nil
+}
+
+// releasingBody releases the in-flight slot of the request it's the response body of once it's closed.
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
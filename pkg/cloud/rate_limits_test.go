@@ -0,0 +1,104 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
+	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta2"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/test/simulator"
+)
+
+var _ = Describe("Rate limits", func() {
+	UseSimulator()
+
+	// newLimitedClient returns a client of the simulator held to the passed rate limits.
+	newLimitedClient := func(limits map[string]string) cloud.Client {
+		limitedClient, err := cloud.NewClientFromConf(dummies.SimulatorConf, SimulatorClientConfig(limits))
+		Ω(err).ShouldNot(HaveOccurred())
+		return limitedClient
+	}
+
+	It("makes requests at the configured rate once a burst of them is used up", func() {
+		limitedClient := newLimitedClient(map[string]string{cloud.ClientQPSKey: "10", cloud.ClientBurstKey: "2"})
+		start := time.Now()
+		for i := 0; i < 5; i++ {
+			zone := dummies.CSFailureDomain1.Spec.Zone
+			Ω(limitedClient.ResolveZone(ctx, &zone)).Should(Succeed())
+		}
+		Ω(time.Since(start)).Should(BeNumerically(">=", 250*time.Millisecond))
+	})
+
+	It("caps the requests awaiting their responses at once, across the endpoint's clients", func() {
+		account := sim.AddAccount(sim.RootDomain().Id, "sub-account")
+		sim.AddUser(account.Id, "sub-user", "sub-api-key", "sub-secret-key")
+		limitedClient := newLimitedClient(map[string]string{cloud.ClientMaxInFlightKey: "1"})
+		subClient, err := limitedClient.NewClientInDomainAndAccount(ctx, "ROOT", "sub-account")
+		Ω(err).ShouldNot(HaveOccurred())
+		clients := []cloud.Client{limitedClient, limitedClient, subClient}
+
+		sim.SetLatency(100 * time.Millisecond)
+		start := time.Now()
+		done := make(chan error, len(clients))
+		for _, c := range clients {
+			go func(c cloud.Client) {
+				zone := dummies.CSFailureDomain1.Spec.Zone
+				done <- c.ResolveZone(ctx, &zone)
+			}(c)
+		}
+		for range clients {
+			Ω(<-done).Should(Succeed())
+		}
+		Ω(time.Since(start)).Should(BeNumerically(">=", 3*100*time.Millisecond))
+	})
+
+	It("backs off from an endpoint throttling its requests", func() {
+		limitedClient := newLimitedClient(map[string]string{cloud.ClientQPSKey: "100"})
+		sim.FailNext("listZones", simulator.NewAPIError(simulator.ErrorCodeAPILimitExceeded,
+			"The given user has reached his/her account api limit, please retry after 1000 ms."))
+		// Resolving the zone by name is made again once the backoff is waited out, and finds its ID.
+		zone := infrav1.CloudStackZoneSpec{Name: dummies.CSFailureDomain1.Spec.Zone.Name}
+		start := time.Now()
+		Ω(limitedClient.ResolveZone(ctx, &zone)).Should(Succeed())
+		Ω(time.Since(start)).Should(BeNumerically(">=", 900*time.Millisecond))
+		Ω(sim.RequestCount("listZones")).Should(Equal(3))
+		Ω(zone.ID).Should(Equal(dummies.CSFailureDomain1.Spec.Zone.ID))
+	})
+
+	It("holds the clients made before the limits changed to the new ones", func() {
+		limitedClient := newLimitedClient(map[string]string{cloud.ClientMaxInFlightKey: "1"})
+		newLimitedClient(map[string]string{cloud.ClientMaxInFlightKey: "3"})
+
+		sim.SetLatency(200 * time.Millisecond)
+		start := time.Now()
+		done := make(chan error, 3)
+		for i := 0; i < 3; i++ {
+			go func() {
+				zone := dummies.CSFailureDomain1.Spec.Zone
+				done <- limitedClient.ResolveZone(ctx, &zone)
+			}()
+		}
+		for i := 0; i < 3; i++ {
+			Ω(<-done).Should(Succeed())
+		}
+		Ω(time.Since(start)).Should(BeNumerically("<", 3*200*time.Millisecond))
+	})
+})
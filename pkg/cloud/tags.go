@@ -17,8 +17,10 @@ limitations under the License.
 package cloud
 
 import (
+	"context"
 	"strings"
 
+	"github.com/apache/cloudstack-go/v2/cloudstack"
 	"github.com/hashicorp/go-multierror"
 
 	"github.com/pkg/errors"
@@ -26,25 +28,46 @@ import (
 )
 
 type TagIface interface {
-	AddClusterTag(ResourceType, string, *infrav1.CloudStackCluster) error
-	DeleteClusterTag(ResourceType, string, *infrav1.CloudStackCluster) error
-	AddCreatedByCAPCTag(ResourceType, string) error
-	DeleteCreatedByCAPCTag(ResourceType, string) error
-	DoClusterTagsAllowDisposal(ResourceType, string) (bool, error)
-	AddTags(ResourceType, string, map[string]string) error
-	GetTags(ResourceType, string) (map[string]string, error)
-	DeleteTags(ResourceType, string, map[string]string) error
+	AddClusterTag(context.Context, ResourceType, string, *infrav1.CloudStackCluster) error
+	DeleteClusterTag(context.Context, ResourceType, string, *infrav1.CloudStackCluster) error
+	AddCreatedByCAPCTag(context.Context, ResourceType, string) error
+	DeleteCreatedByCAPCTag(context.Context, ResourceType, string) error
+	DoClusterTagsAllowDisposal(context.Context, ResourceType, string) (bool, error)
+	AddTags(context.Context, ResourceType, string, map[string]string) error
+	GetTags(context.Context, ResourceType, string) (map[string]string, error)
+	DeleteTags(context.Context, ResourceType, string, map[string]string) error
+	ReconcileTags(context.Context, ResourceType, string, map[string]string) error
 }
 
 type ResourceType string
 
 const (
-	ClusterTagNamePrefix               = "CAPC_cluster_"
-	CreatedByCAPCTagName               = "created_by_CAPC"
-	ResourceTypeNetwork   ResourceType = "Network"
-	ResourceTypeIPAddress ResourceType = "PublicIpAddress"
+	ClusterTagNamePrefix                   = "CAPC_cluster_"
+	CreatedByCAPCTagName                   = "created_by_CAPC"
+	AdoptedByCAPCTagName                   = "adopted_by_CAPC"
+	LoadBalancerTagName                    = "CAPC_load_balancer"
+	ManagementClusterTagName               = "CAPC_management_cluster"
+	ResourceTypeNetwork       ResourceType = "Network"
+	ResourceTypeIPAddress     ResourceType = "PublicIpAddress"
+	ResourceTypeLoadBalancer  ResourceType = "LoadBalancer"
+	ResourceTypeVPC           ResourceType = "Vpc"
+	ResourceTypeUserVM        ResourceType = "UserVm"
+	ResourceTypeVolume        ResourceType = "Volume"
+	ResourceTypeAffinityGroup ResourceType = "AffinityGroup"
 )
 
+// ManagementClusterID identifies the management cluster CAPC runs in. It's put on the resources CAPC creates, so
+// that the orphan collector of one management cluster leaves those of others sharing an account alone.
+var ManagementClusterID string
+
+// managementClusterTags returns the tag marking a resource as this management cluster's, if it has an identity.
+func managementClusterTags() map[string]string {
+	if ManagementClusterID == "" {
+		return map[string]string{}
+	}
+	return map[string]string{ManagementClusterTagName: ManagementClusterID}
+}
+
 // ignoreAlreadyPresentErrors returns nil if the error is an already present tag error.
 func ignoreAlreadyPresentErrors(err error, rType ResourceType, rID string) error {
 	matchSubString := strings.ToLower("already on " + string(rType) + " with id " + rID)
@@ -54,8 +77,8 @@ func ignoreAlreadyPresentErrors(err error, rType ResourceType, rID string) error
 	return nil
 }
 
-func (c *client) IsCapcManaged(resourceType ResourceType, resourceID string) (bool, error) {
-	tags, err := c.GetTags(resourceType, resourceID)
+func (c *client) IsCapcManaged(ctx context.Context, resourceType ResourceType, resourceID string) (bool, error) {
+	tags, err := c.GetTags(ctx, resourceType, resourceID)
 	if err != nil {
 		return false, errors.Wrapf(err,
 			"checking if %s with ID: %s is tagged as CAPC managed", resourceType, resourceID)
@@ -65,42 +88,63 @@ func (c *client) IsCapcManaged(resourceType ResourceType, resourceID string) (bo
 }
 
 // AddClusterTag adds cluster tag to a resource. This tag indicates the resource is used by a given the cluster.
-func (c *client) AddClusterTag(rType ResourceType, rID string, csCluster *infrav1.CloudStackCluster) error {
-	if managedByCAPC, err := c.IsCapcManaged(rType, rID); err != nil {
+// The resource is marked as this management cluster's too, so a cluster moved here takes its resources along.
+func (c *client) AddClusterTag(ctx context.Context, rType ResourceType, rID string, csCluster *infrav1.CloudStackCluster) error {
+	c, ctx, cancel := c.withTimeout(ctx, OperationDefault)
+	defer cancel()
+
+	if managedByCAPC, err := c.IsCapcManaged(ctx, rType, rID); err != nil {
 		return err
 	} else if managedByCAPC {
 		ClusterTagName := generateClusterTagName(csCluster)
-		return c.AddTags(rType, rID, map[string]string{ClusterTagName: "1"})
+		if err := c.AddTags(ctx, rType, rID, map[string]string{ClusterTagName: "1"}); err != nil {
+			return err
+		}
+		return c.ReconcileTags(ctx, rType, rID, managementClusterTags())
 	}
 	return nil
 }
 
 // DeleteClusterTag deletes the tag that associates the resource with a given cluster.
-func (c *client) DeleteClusterTag(rType ResourceType, rID string, csCluster *infrav1.CloudStackCluster) error {
-	if managedByCAPC, err := c.IsCapcManaged(rType, rID); err != nil {
+func (c *client) DeleteClusterTag(ctx context.Context, rType ResourceType, rID string, csCluster *infrav1.CloudStackCluster) error {
+	c, ctx, cancel := c.withTimeout(ctx, OperationDefault)
+	defer cancel()
+
+	if managedByCAPC, err := c.IsCapcManaged(ctx, rType, rID); err != nil {
 		return err
 	} else if managedByCAPC {
 		ClusterTagName := generateClusterTagName(csCluster)
-		return c.DeleteTags(rType, rID, map[string]string{ClusterTagName: "1"})
+		return c.DeleteTags(ctx, rType, rID, map[string]string{ClusterTagName: "1"})
 	}
 	return nil
 }
 
-// AddCreatedByCAPCTag adds the tag that indicates that the resource was created by CAPC.
-// This is useful when a resource is disassociated but not deleted.
-func (c *client) AddCreatedByCAPCTag(rType ResourceType, rID string) error {
-	return c.AddTags(rType, rID, map[string]string{CreatedByCAPCTagName: "1"})
+// AddCreatedByCAPCTag adds the tag that indicates that the resource was created by CAPC, and by which management
+// cluster. This is useful when a resource is disassociated but not deleted.
+func (c *client) AddCreatedByCAPCTag(ctx context.Context, rType ResourceType, rID string) error {
+	c, ctx, cancel := c.withTimeout(ctx, OperationDefault)
+	defer cancel()
+
+	tags := managementClusterTags()
+	tags[CreatedByCAPCTagName] = "1"
+	return c.AddTags(ctx, rType, rID, tags)
 }
 
 // DeleteCreatedByCAPCTag deletes the tag that indicates that the resource was created by CAPC.
-func (c *client) DeleteCreatedByCAPCTag(rType ResourceType, rID string) error {
-	return c.DeleteTags(rType, rID, map[string]string{CreatedByCAPCTagName: "1"})
+func (c *client) DeleteCreatedByCAPCTag(ctx context.Context, rType ResourceType, rID string) error {
+	c, ctx, cancel := c.withTimeout(ctx, OperationDefault)
+	defer cancel()
+
+	return c.DeleteTags(ctx, rType, rID, map[string]string{CreatedByCAPCTagName: "1"})
 }
 
 // DoClusterTagsAllowDisposal checks to see if the resource is in a state that makes it eligible for disposal.  CAPC can
 // dispose of a resource if the tags show it was created by CAPC and isn't being used by any clusters.
-func (c *client) DoClusterTagsAllowDisposal(resourceType ResourceType, resourceID string) (bool, error) {
-	tags, err := c.GetTags(resourceType, resourceID)
+func (c *client) DoClusterTagsAllowDisposal(ctx context.Context, resourceType ResourceType, resourceID string) (bool, error) {
+	c, ctx, cancel := c.withTimeout(ctx, OperationResolve)
+	defer cancel()
+
+	tags, err := c.GetTags(ctx, resourceType, resourceID)
 	if err != nil {
 		return false, err
 	}
@@ -116,7 +160,10 @@ func (c *client) DoClusterTagsAllowDisposal(resourceType ResourceType, resourceI
 }
 
 // AddTags adds arbitrary tags to a resource.
-func (c *client) AddTags(resourceType ResourceType, resourceID string, tags map[string]string) error {
+func (c *client) AddTags(ctx context.Context, resourceType ResourceType, resourceID string, tags map[string]string) error {
+	c, _, cancel := c.withTimeout(ctx, OperationDefault)
+	defer cancel()
+
 	p := c.cs.Resourcetags.NewCreateTagsParams([]string{resourceID}, string(resourceType), tags)
 	_, err := c.cs.Resourcetags.CreateTags(p)
 	c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
@@ -124,7 +171,10 @@ func (c *client) AddTags(resourceType ResourceType, resourceID string, tags map[
 }
 
 // GetTags gets all of a resource's tags.
-func (c *client) GetTags(resourceType ResourceType, resourceID string) (map[string]string, error) {
+func (c *client) GetTags(ctx context.Context, resourceType ResourceType, resourceID string) (map[string]string, error) {
+	c, _, cancel := c.withTimeout(ctx, OperationResolve)
+	defer cancel()
+
 	p := c.cs.Resourcetags.NewListTagsParams()
 	p.SetResourceid(resourceID)
 	p.SetResourcetype(string(resourceType))
@@ -143,14 +193,17 @@ func (c *client) GetTags(resourceType ResourceType, resourceID string) (map[stri
 
 // DeleteTags deletes the given tags from a resource.
 // Ignores errors if the tag is not present.
-func (c *client) DeleteTags(resourceType ResourceType, resourceID string, tagsToDelete map[string]string) error {
+func (c *client) DeleteTags(ctx context.Context, resourceType ResourceType, resourceID string, tagsToDelete map[string]string) error {
+	c, ctx, cancel := c.withTimeout(ctx, OperationDefault)
+	defer cancel()
+
 	for tagkey, tagval := range tagsToDelete {
 		p := c.cs.Resourcetags.NewDeleteTagsParams([]string{resourceID}, string(resourceType))
 		p.SetTags(tagsToDelete)
 		if _, err1 := c.cs.Resourcetags.DeleteTags(p); err1 != nil { // Error in deletion attempt. Check for tag.
 			c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err1)
 			currTag := map[string]string{tagkey: tagval}
-			if tags, err2 := c.GetTags(resourceType, resourceID); len(tags) != 0 {
+			if tags, err2 := c.GetTags(ctx, resourceType, resourceID); len(tags) != 0 {
 				c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err2)
 				if _, foundTag := tags[tagkey]; foundTag {
 					return errors.Wrapf(multierror.Append(err1, err2),
@@ -162,6 +215,80 @@ func (c *client) DeleteTags(resourceType ResourceType, resourceID string, tagsTo
 	return nil
 }
 
+// ReconcileTags ensures a resource has the given tags, replacing the values of any that drifted. Its other tags are
+// left alone.
+func (c *client) ReconcileTags(ctx context.Context, resourceType ResourceType, resourceID string, tags map[string]string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	c, ctx, cancel := c.withTimeout(ctx, OperationDefault)
+	defer cancel()
+
+	current, err := c.GetTags(ctx, resourceType, resourceID)
+	if err != nil {
+		return errors.Wrapf(err, "getting tags of %s with ID %s", resourceType, resourceID)
+	}
+	toAdd, toDelete := map[string]string{}, map[string]string{}
+	for key, value := range tags {
+		if currentValue, found := current[key]; !found {
+			toAdd[key] = value
+		} else if currentValue != value {
+			toDelete[key] = currentValue
+			toAdd[key] = value
+		}
+	}
+	if len(toDelete) > 0 {
+		if err := c.DeleteTags(ctx, resourceType, resourceID, toDelete); err != nil {
+			return err
+		}
+	}
+	if len(toAdd) > 0 {
+		return errors.Wrapf(c.AddTags(ctx, resourceType, resourceID, toAdd),
+			"tagging %s with ID %s", resourceType, resourceID)
+	}
+	return nil
+}
+
+// reconcileAdditionalTags puts the cluster's additional tags on a resource CAPC created. Resources CAPC uses but
+// didn't create keep their own tags.
+func (c *client) reconcileAdditionalTags(ctx context.Context, rType ResourceType, rID string, csCluster *infrav1.CloudStackCluster) error {
+	if len(csCluster.Spec.AdditionalTags) == 0 {
+		return nil
+	}
+	if managedByCAPC, err := c.IsCapcManaged(ctx, rType, rID); err != nil || !managedByCAPC {
+		return err
+	}
+	return c.ReconcileTags(ctx, rType, rID, csCluster.Spec.AdditionalTags)
+}
+
+// ClusterResourceTags returns the tags of a resource CAPC creates for a cluster: the cluster, created by CAPC and
+// management cluster tags, and the cluster's additional tags with the passed overrides applied.
+func ClusterResourceTags(csCluster *infrav1.CloudStackCluster, overrides map[string]string) map[string]string {
+	tags := map[string]string{}
+	for key, value := range csCluster.Spec.AdditionalTags {
+		tags[key] = value
+	}
+	for key, value := range overrides {
+		tags[key] = value
+	}
+	for key, value := range managementClusterTags() {
+		tags[key] = value
+	}
+	tags[generateClusterTagName(csCluster)] = "1"
+	tags[CreatedByCAPCTagName] = "1"
+	return tags
+}
+
+// hasTag reports whether the tags embedded in a resource include the named tag.
+func hasTag(tags []cloudstack.Tags, name string) bool {
+	for _, tag := range tags {
+		if tag.Key == name {
+			return true
+		}
+	}
+	return false
+}
+
 func generateClusterTagName(csCluster *infrav1.CloudStackCluster) string {
 	return ClusterTagNamePrefix + string(csCluster.UID)
 }
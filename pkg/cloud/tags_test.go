@@ -24,6 +24,7 @@ import (
 	"github.com/pkg/errors"
 	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
 	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta2"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/test/simulator"
 )
 
 var _ = Describe("Tag Unit Tests", func() {
@@ -36,7 +37,6 @@ var _ = Describe("Tag Unit Tests", func() {
 		mockCtrl   *gomock.Controller
 		mockClient *csapi.CloudStackClient
 		rs         *csapi.MockResourcetagsServiceIface
-		client     cloud.Client
 	)
 
 	BeforeEach(func() {
@@ -52,12 +52,12 @@ var _ = Describe("Tag Unit Tests", func() {
 			client = realCloudClient
 			FetchIntegTestResources()
 
-			existingTags, err := client.GetTags(cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID)
+			existingTags, err := client.GetTags(ctx, cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID)
 			if err != nil {
 				Fail("Failed to get existing tags. Error: " + err.Error())
 			}
 			if len(existingTags) > 0 {
-				err = client.DeleteTags(cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID, existingTags)
+				err = client.DeleteTags(ctx, cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID, existingTags)
 				if err != nil {
 					Fail("Failed to delete existing tags. Error: " + err.Error())
 				}
@@ -65,87 +65,87 @@ var _ = Describe("Tag Unit Tests", func() {
 		})
 
 		It("adds and gets a resource tag", func() {
-			Ω(client.AddTags(cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID, dummies.Tags)).Should(Succeed())
-			Ω(client.GetTags(cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID)).Should(Equal(dummies.Tags))
+			Ω(client.AddTags(ctx, cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID, dummies.Tags)).Should(Succeed())
+			Ω(client.GetTags(ctx, cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID)).Should(Equal(dummies.Tags))
 		})
 
 		It("deletes a resource tag", func() {
-			Ω(client.AddTags(cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID, dummies.Tags)).Should(Succeed())
-			Ω(client.DeleteTags(cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID, dummies.Tags)).Should(Succeed())
-			Ω(client.GetTags(cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID)).Should(Equal(map[string]string{}))
+			Ω(client.AddTags(ctx, cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID, dummies.Tags)).Should(Succeed())
+			Ω(client.DeleteTags(ctx, cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID, dummies.Tags)).Should(Succeed())
+			Ω(client.GetTags(ctx, cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID)).Should(Equal(map[string]string{}))
 		})
 
 		It("returns an error when you delete a tag that doesn't exist", func() {
-			Ω(client.DeleteTags(cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID, dummies.Tags)).Should(Succeed())
+			Ω(client.DeleteTags(ctx, cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID, dummies.Tags)).Should(Succeed())
 		})
 
 		It("adds the tags for a cluster (resource created by CAPC)", func() {
-			Ω(client.AddCreatedByCAPCTag(cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID)).
+			Ω(client.AddCreatedByCAPCTag(ctx, cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID)).
 				Should(Succeed())
-			Ω(client.AddClusterTag(cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID, dummies.CSCluster)).
+			Ω(client.AddClusterTag(ctx, cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID, dummies.CSCluster)).
 				Should(Succeed())
 
 			// Verify tags
-			tags, err := client.GetTags(cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID)
+			tags, err := client.GetTags(ctx, cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID)
 			Ω(err).ShouldNot(HaveOccurred())
 
 			Ω(tags[dummies.CSClusterTagKey]).Should(Equal(dummies.CSClusterTagVal))
 		})
 
 		It("does not fail when the cluster tags are added twice", func() {
-			Ω(client.AddClusterTag(cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID, dummies.CSCluster)).Should(Succeed())
-			Ω(client.AddClusterTag(cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID, dummies.CSCluster)).Should(Succeed())
+			Ω(client.AddClusterTag(ctx, cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID, dummies.CSCluster)).Should(Succeed())
+			Ω(client.AddClusterTag(ctx, cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID, dummies.CSCluster)).Should(Succeed())
 		})
 
 		It("doesn't adds the tags for a cluster (resource NOT created by CAPC)", func() {
-			Ω(client.AddClusterTag(cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID, dummies.CSCluster)).Should(Succeed())
+			Ω(client.AddClusterTag(ctx, cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID, dummies.CSCluster)).Should(Succeed())
 
 			// Verify tags
-			tags, err := client.GetTags(cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID)
+			tags, err := client.GetTags(ctx, cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID)
 			Ω(err).Should(BeNil())
 			Ω(tags[dummies.CreatedByCapcKey]).Should(Equal(""))
 			Ω(tags[dummies.CSClusterTagKey]).Should(Equal(""))
 		})
 
 		It("deletes a cluster tag", func() {
-			Ω(client.AddClusterTag(cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID, dummies.CSCluster)).Should(Succeed())
-			Ω(client.DeleteClusterTag(cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID, dummies.CSCluster)).Should(Succeed())
+			Ω(client.AddClusterTag(ctx, cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID, dummies.CSCluster)).Should(Succeed())
+			Ω(client.DeleteClusterTag(ctx, cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID, dummies.CSCluster)).Should(Succeed())
 
-			Ω(client.GetTags(cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID)).ShouldNot(HaveKey(dummies.CSClusterTagKey))
+			Ω(client.GetTags(ctx, cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID)).ShouldNot(HaveKey(dummies.CSClusterTagKey))
 		})
 
 		It("adds and deletes a created by capc tag", func() {
-			Ω(client.AddCreatedByCAPCTag(cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID)).Should(Succeed())
-			Ω(client.DeleteCreatedByCAPCTag(cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID)).Should(Succeed())
+			Ω(client.AddCreatedByCAPCTag(ctx, cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID)).Should(Succeed())
+			Ω(client.DeleteCreatedByCAPCTag(ctx, cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID)).Should(Succeed())
 		})
 
 		It("does not fail when cluster and CAPC created tags are deleted twice", func() {
-			Ω(client.AddClusterTag(cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID, dummies.CSCluster)).Should(Succeed())
-			Ω(client.DeleteClusterTag(cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID, dummies.CSCluster)).Should(Succeed())
-			Ω(client.DeleteClusterTag(cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID, dummies.CSCluster)).Should(Succeed())
-			Ω(client.DeleteCreatedByCAPCTag(cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID)).Should(Succeed())
-			Ω(client.DeleteCreatedByCAPCTag(cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID)).Should(Succeed())
+			Ω(client.AddClusterTag(ctx, cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID, dummies.CSCluster)).Should(Succeed())
+			Ω(client.DeleteClusterTag(ctx, cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID, dummies.CSCluster)).Should(Succeed())
+			Ω(client.DeleteClusterTag(ctx, cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID, dummies.CSCluster)).Should(Succeed())
+			Ω(client.DeleteCreatedByCAPCTag(ctx, cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID)).Should(Succeed())
+			Ω(client.DeleteCreatedByCAPCTag(ctx, cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID)).Should(Succeed())
 		})
 
 		It("does not allow a resource to be deleted when there are no tags", func() {
-			tagsAllowDisposal, err := client.DoClusterTagsAllowDisposal(cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID)
+			tagsAllowDisposal, err := client.DoClusterTagsAllowDisposal(ctx, cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID)
 			Ω(err).Should(BeNil())
 			Ω(tagsAllowDisposal).Should(BeFalse())
 		})
 
 		It("does not allow a resource to be deleted when there is a cluster tag", func() {
-			Ω(client.AddClusterTag(cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID, dummies.CSCluster)).Should(Succeed())
-			tagsAllowDisposal, err := client.DoClusterTagsAllowDisposal(cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID)
+			Ω(client.AddClusterTag(ctx, cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID, dummies.CSCluster)).Should(Succeed())
+			tagsAllowDisposal, err := client.DoClusterTagsAllowDisposal(ctx, cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID)
 			Ω(err).Should(BeNil())
 			Ω(tagsAllowDisposal).Should(BeFalse())
 		})
 
 		It("does allow a resource to be deleted when there are no cluster tags and there is a CAPC created tag", func() {
-			Ω(client.AddClusterTag(cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID, dummies.CSCluster)).Should(Succeed())
-			Ω(client.AddCreatedByCAPCTag(cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID)).Should(Succeed())
-			Ω(client.DeleteClusterTag(cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID, dummies.CSCluster)).Should(Succeed())
+			Ω(client.AddClusterTag(ctx, cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID, dummies.CSCluster)).Should(Succeed())
+			Ω(client.AddCreatedByCAPCTag(ctx, cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID)).Should(Succeed())
+			Ω(client.DeleteClusterTag(ctx, cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID, dummies.CSCluster)).Should(Succeed())
 
-			tagsAllowDisposal, err := client.DoClusterTagsAllowDisposal(cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID)
+			tagsAllowDisposal, err := client.DoClusterTagsAllowDisposal(ctx, cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID)
 			Ω(err).Should(BeNil())
 			Ω(tagsAllowDisposal).Should(BeTrue())
 		})
@@ -160,7 +160,7 @@ var _ = Describe("Tag Unit Tests", func() {
 			rs.EXPECT().ListTags(rtlp).Return(createdByCAPCResponse, nil)
 			rs.EXPECT().NewCreateTagsParams(gomock.Any(), gomock.Any(), gomock.Any()).Return(ctp)
 			rs.EXPECT().CreateTags(ctp).Return(&csapi.CreateTagsResponse{}, nil)
-			Ω(client.AddClusterTag(cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID, dummies.CSCluster)).Should(Succeed())
+			Ω(client.AddClusterTag(ctx, cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID, dummies.CSCluster)).Should(Succeed())
 		})
 	})
 
@@ -181,7 +181,7 @@ var _ = Describe("Tag Unit Tests", func() {
 				}},
 			}, nil)
 
-			err := client.DeleteTags(cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID, tags)
+			err := client.DeleteTags(ctx, cloud.ResourceTypeNetwork, dummies.CSISONet1.Spec.ID, tags)
 			Ω(err).ShouldNot(Succeed())
 			Ω(err.Error()).Should(ContainSubstring("could not remove tag"))
 		})
@@ -192,8 +192,64 @@ var _ = Describe("Tag Unit Tests", func() {
 			rs.EXPECT().NewListTagsParams().Return(&csapi.ListTagsParams{})
 			rs.EXPECT().ListTags(gomock.Any()).Return(nil, fakeError)
 
-			_, err := client.GetTags(cloud.ResourceTypeNetwork, dummies.ISONet1.ID)
+			_, err := client.GetTags(ctx, cloud.ResourceTypeNetwork, dummies.ISONet1.ID)
 			Ω(err).ShouldNot(Succeed())
 		})
 	})
+
+	Context("with the simulator", func() {
+		UseSimulator()
+
+		It("adds, gets, and deletes tags", func() {
+			tags := map[string]string{"key1": "value1", "key2": "value2"}
+			Ω(client.AddTags(ctx, cloud.ResourceTypeNetwork, dummies.Net1.ID, tags)).Should(Succeed())
+			Ω(client.GetTags(ctx, cloud.ResourceTypeNetwork, dummies.Net1.ID)).Should(Equal(tags))
+
+			// Re-adding a tag is tolerated by the client.
+			Ω(client.AddTags(ctx, cloud.ResourceTypeNetwork, dummies.Net1.ID, tags)).Should(Succeed())
+
+			Ω(client.DeleteTags(ctx, cloud.ResourceTypeNetwork, dummies.Net1.ID, map[string]string{"key1": "value1"})).
+				Should(Succeed())
+			Ω(sim.Tags(dummies.Net1.ID)).Should(Equal(map[string]string{"key2": "value2"}))
+		})
+
+		It("tags a VM and its volumes with the cluster's and machine's tags and reverts drift", func() {
+			dummies.CSCluster.Spec.AdditionalTags = map[string]string{"cost-center": "1234", "team": "platform"}
+			dummies.CSMachine1.Spec.AdditionalTags = map[string]string{"team": "storage"}
+			Ω(client.GetOrCreateVMInstance(ctx, dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster,
+				dummies.CSFailureDomain1, dummies.CSAffinityGroup, "userdata")).Should(Succeed())
+			Ω(client.ReconcileVMInstanceTags(ctx, dummies.CSMachine1, dummies.CSCluster)).Should(Succeed())
+
+			expected := cloud.ClusterResourceTags(dummies.CSCluster, dummies.CSMachine1.Spec.AdditionalTags)
+			Ω(expected).Should(HaveKeyWithValue("team", "storage"))
+			Ω(expected).Should(HaveKeyWithValue(cloud.CreatedByCAPCTagName, "1"))
+			Ω(sim.Tags(*dummies.CSMachine1.Spec.InstanceID)).Should(Equal(expected))
+			Ω(sim.Volumes()).Should(HaveLen(2))
+			for _, volume := range sim.Volumes() {
+				Ω(sim.Tags(volume.Id)).Should(Equal(expected))
+			}
+
+			Ω(client.DeleteTags(ctx, cloud.ResourceTypeUserVM, *dummies.CSMachine1.Spec.InstanceID,
+				map[string]string{"cost-center": "1234"})).Should(Succeed())
+			Ω(client.AddTags(ctx, cloud.ResourceTypeUserVM, *dummies.CSMachine1.Spec.InstanceID,
+				map[string]string{"cost-center": "9999", "owner": "someone"})).Should(Succeed())
+			Ω(client.ReconcileVMInstanceTags(ctx, dummies.CSMachine1, dummies.CSCluster)).Should(Succeed())
+			expected["owner"] = "someone" // Tags CAPC doesn't manage are left alone.
+			Ω(sim.Tags(*dummies.CSMachine1.Spec.InstanceID)).Should(Equal(expected))
+		})
+
+		It("puts the cluster's additional tags on the isolated network and public IP it creates", func() {
+			dummies.SetDummyIsoNetToNameOnly()
+			dummies.CSFailureDomain1.Spec.Zone = dummies.Zone1
+			dummies.CSCluster.Spec.ControlPlaneEndpoint.Host = ""
+			dummies.CSCluster.Spec.AdditionalTags = map[string]string{"cost-center": "1234"}
+			shared := sim.AddNetwork(dummies.Zone1.ID, "other-network", simulator.NetworkTypeShared, "10.20.0.0/24")
+			Ω(client.GetOrCreateIsolatedNetwork(ctx, dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).
+				Should(Succeed())
+			Ω(sim.Tags(shared.Id)).Should(BeEmpty())
+
+			Ω(sim.Tags(dummies.CSISONet1.Spec.ID)).Should(HaveKeyWithValue("cost-center", "1234"))
+			Ω(sim.Tags(dummies.CSISONet1.Status.PublicIPID)).Should(HaveKeyWithValue("cost-center", "1234"))
+		})
+	})
 })
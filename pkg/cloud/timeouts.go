@@ -0,0 +1,158 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"time"
+
+	"github.com/apache/cloudstack-go/v2/cloudstack"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Operation is a kind of Client call with a timeout of its own.
+type Operation string
+
+const (
+	// OperationResolve covers calls that only look CloudStack resources up.
+	OperationResolve Operation = "resolve"
+	// OperationCreateVM covers deploying VM instances.
+	OperationCreateVM Operation = "create-vm"
+	// OperationUpdateVM covers changing VM instances, which may stop and start them.
+	OperationUpdateVM Operation = "update-vm"
+	// OperationDestroyVM covers destroying VM instances and other resources.
+	OperationDestroyVM Operation = "destroy-vm"
+	// OperationNetwork covers creating and deleting networks, public IPs, firewall and load balancer rules.
+	OperationNetwork Operation = "network"
+	// OperationDefault covers all other calls, and the operations without a timeout of their own.
+	OperationDefault Operation = "default"
+)
+
+const ClientTimeoutKey = "client-timeout"
+
+// defaultRequestTimeout is how long the CloudStack API client waits for the response to a request by itself, in calls
+// made outside of an operation with a timeout.
+const defaultRequestTimeout = time.Minute
+
+// DefaultClientTimeouts match how long the CloudStack API client waits for API calls and async jobs by itself.
+var DefaultClientTimeouts = ClientTimeouts{
+	OperationResolve: time.Minute,
+	OperationDefault: 5 * time.Minute,
+}
+
+// ClientTimeouts bound how long each Operation may take, CloudStack API calls and async jobs included.
+type ClientTimeouts map[Operation]time.Duration
+
+// For returns the timeout of an operation.
+func (t ClientTimeouts) For(op Operation) time.Duration {
+	if timeout, found := t[op]; found {
+		return timeout
+	}
+	return t[OperationDefault]
+}
+
+// GetClientTimeouts returns the operation timeouts from the passed config map. The client-timeout key sets the default
+// and client-timeout-<operation> keys, such as client-timeout-create-vm, override it for an operation.
+func GetClientTimeouts(clientConfig *corev1.ConfigMap) ClientTimeouts {
+	timeouts := ClientTimeouts{}
+	for op, timeout := range DefaultClientTimeouts {
+		timeouts[op] = timeout
+	}
+	if clientConfig == nil {
+		return timeouts
+	}
+	if timeout, err := time.ParseDuration(clientConfig.Data[ClientTimeoutKey]); err == nil && timeout > 0 {
+		timeouts[OperationDefault] = timeout
+	}
+	for _, op := range []Operation{
+		OperationResolve, OperationCreateVM, OperationUpdateVM, OperationDestroyVM, OperationNetwork,
+	} {
+		if timeout, err := time.ParseDuration(clientConfig.Data[ClientTimeoutKey+"-"+string(op)]); err == nil && timeout > 0 {
+			timeouts[op] = timeout
+		}
+	}
+	return timeouts
+}
+
+// newTransport returns the HTTP transport CloudStack API calls are made over, shared by the calls of a client.
+func newTransport(verifySSL bool) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: !verifySSL} // #nosec G402 -- Configured by the user.
+	return transport
+}
+
+// contextTransport makes HTTP requests in the context of the operation the client using it is in, which the
+// CloudStack API client doesn't do by itself. Each bound client has its own, only used by the operation holding it.
+type contextTransport struct {
+	ctx  context.Context
+	base http.RoundTripper
+}
+
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.ctx == nil {
+		return t.base.RoundTrip(req)
+	}
+	return t.base.RoundTrip(req.WithContext(t.ctx))
+}
+
+// newBoundClient returns a copy of the client whose CloudStack API calls are made in the context its contextTransport
+// is set to. Async jobs are waited for until that context is done, however long the operation's timeout.
+func (c *client) newBoundClient() *client {
+	transport := &contextTransport{base: c.transport}
+	httpClient := cloudstack.WithHTTPClient(&http.Client{Transport: transport})
+	var longest time.Duration
+	for _, timeout := range c.timeouts {
+		if timeout > longest {
+			longest = timeout
+		}
+	}
+	asyncTimeout := cloudstack.WithAsyncTimeout(int64(longest.Seconds()) + 1)
+	bound := *c
+	bound.ctxTransport = transport
+	bound.cs = cloudstack.NewAsyncClient(c.config.APIUrl, c.config.APIKey, c.config.SecretKey, c.verifySSL,
+		httpClient, asyncTimeout)
+	bound.csAsync = cloudstack.NewClient(c.config.APIUrl, c.config.APIKey, c.config.SecretKey, c.verifySSL,
+		httpClient, asyncTimeout)
+	return &bound
+}
+
+// withTimeout returns a client whose CloudStack API calls, async job polling included, give up once ctx is done or the
+// operation's timeout has passed, along with the context the operation's calls of other operations should be made in.
+// The client is a bound copy taken from those the client keeps for reuse, and given back once the operation is
+// canceled. Operations called by an operation use its bound client, until they're canceled themselves. Clients of
+// mocked CloudStack APIs are returned as they are.
+func (c *client) withTimeout(ctx context.Context, op Operation) (*client, context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeouts.For(op))
+	if c.transport == nil {
+		return c, ctx, cancel
+	}
+	bound := c
+	if c.ctxTransport == nil {
+		bound = c.boundClients.Get().(*client)
+	}
+	outer := bound.ctxTransport.ctx
+	bound.ctxTransport.ctx = ctx
+	return bound, ctx, func() {
+		cancel()
+		bound.ctxTransport.ctx = outer
+		if bound != c {
+			c.boundClients.Put(bound)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
+	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta2"
+)
+
+var _ = Describe("Timeouts", func() {
+	UseSimulator()
+
+	It("gives up on calls that take longer than their operation's timeout", func() {
+		clientConfig := SimulatorClientConfig(map[string]string{cloud.ClientTimeoutKey + "-resolve": "200ms"})
+		slowClient, err := cloud.NewClientFromConf(dummies.SimulatorConf, clientConfig)
+		Ω(err).ShouldNot(HaveOccurred())
+		zone := dummies.CSFailureDomain1.Spec.Zone
+		Ω(slowClient.ResolveZone(ctx, &zone)).Should(Succeed())
+
+		sim.SetLatency(5 * time.Second)
+		start := time.Now()
+		Ω(slowClient.ResolveZone(ctx, &zone)).Should(MatchError(ContainSubstring("context deadline exceeded")))
+		Ω(time.Since(start)).Should(BeNumerically("<", 2*time.Second))
+	})
+
+	It("makes the calls of later operations in their own context", func() {
+		clientConfig := SimulatorClientConfig(map[string]string{cloud.ClientTimeoutKey + "-resolve": "200ms"})
+		slowClient, err := cloud.NewClientFromConf(dummies.SimulatorConf, clientConfig)
+		Ω(err).ShouldNot(HaveOccurred())
+		zone := dummies.CSFailureDomain1.Spec.Zone
+
+		sim.SetLatency(5 * time.Second)
+		Ω(slowClient.ResolveZone(ctx, &zone)).Should(MatchError(ContainSubstring("context deadline exceeded")))
+		sim.SetLatency(0)
+		for i := 0; i < 3; i++ {
+			Ω(slowClient.ResolveZone(ctx, &zone)).Should(Succeed())
+		}
+	})
+
+	It("gives up on calls once their context is canceled", func() {
+		sim.SetLatency(5 * time.Second)
+		callCtx, cancel := context.WithCancel(ctx)
+		time.AfterFunc(200*time.Millisecond, cancel)
+		start := time.Now()
+		Ω(client.ResolveZone(callCtx, &dummies.CSFailureDomain1.Spec.Zone)).Should(
+			MatchError(ContainSubstring("context canceled")))
+		Ω(time.Since(start)).Should(BeNumerically("<", 2*time.Second))
+	})
+})
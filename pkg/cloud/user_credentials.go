@@ -17,6 +17,7 @@ limitations under the License.
 package cloud
 
 import (
+	"context"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -28,11 +29,11 @@ const (
 )
 
 type UserCredIFace interface {
-	ResolveDomain(*Domain) error
-	ResolveAccount(*Account) error
-	ResolveUser(*User) error
-	ResolveUserKeys(*User) error
-	GetUserWithKeys(*User) (bool, error)
+	ResolveDomain(context.Context, *Domain) error
+	ResolveAccount(context.Context, *Account) error
+	ResolveUser(context.Context, *User) error
+	ResolveUserKeys(context.Context, *User) error
+	GetUserWithKeys(context.Context, *User) (bool, error)
 }
 
 // Domain contains specifications that identify a domain.
@@ -59,7 +60,10 @@ type User struct {
 }
 
 // ResolveDomain resolves a domain's information.
-func (c *client) ResolveDomain(domain *Domain) error {
+func (c *client) ResolveDomain(ctx context.Context, domain *Domain) error {
+	c, _, cancel := c.withTimeout(ctx, OperationResolve)
+	defer cancel()
+
 	// A domain can be specified by Id, Name, and or Path.
 	// Parse path and use it to set name if not present.
 	tokens := []string{}
@@ -128,9 +132,12 @@ func (c *client) ResolveDomain(domain *Domain) error {
 }
 
 // ResolveAccount resolves an account's information.
-func (c *client) ResolveAccount(account *Account) error {
+func (c *client) ResolveAccount(ctx context.Context, account *Account) error {
+	c, ctx, cancel := c.withTimeout(ctx, OperationResolve)
+	defer cancel()
+
 	// Resolve domain prior to any account resolution activity.
-	if err := c.ResolveDomain(&account.Domain); err != nil {
+	if err := c.ResolveDomain(ctx, &account.Domain); err != nil {
 		return errors.Wrapf(err, "resolving domain %s details", account.Domain.Name)
 	}
 
@@ -154,9 +161,12 @@ func (c *client) ResolveAccount(account *Account) error {
 }
 
 // ResolveUser resolves a user's information.
-func (c *client) ResolveUser(user *User) error {
+func (c *client) ResolveUser(ctx context.Context, user *User) error {
+	c, ctx, cancel := c.withTimeout(ctx, OperationResolve)
+	defer cancel()
+
 	// Resolve account prior to any user resolution activity.
-	if err := c.ResolveAccount(&user.Account); err != nil {
+	if err := c.ResolveAccount(ctx, &user.Account); err != nil {
 		return errors.Wrapf(err, "resolving account %s details", user.Account.Name)
 	}
 
@@ -179,9 +189,12 @@ func (c *client) ResolveUser(user *User) error {
 }
 
 // ResolveUserKeys resolves a user's api keys.
-func (c *client) ResolveUserKeys(user *User) error {
+func (c *client) ResolveUserKeys(ctx context.Context, user *User) error {
+	c, ctx, cancel := c.withTimeout(ctx, OperationResolve)
+	defer cancel()
+
 	// Resolve user prior to any api key resolution activity.
-	if err := c.ResolveUser(user); err != nil {
+	if err := c.ResolveUser(ctx, user); err != nil {
 		return errors.Wrap(err, "error encountered when resolving user details")
 	}
 
@@ -198,9 +211,12 @@ func (c *client) ResolveUserKeys(user *User) error {
 
 // GetUserWithKeys will search a domain and account for the first user that has api keys.
 // Returns true if a user is found and false otherwise.
-func (c *client) GetUserWithKeys(user *User) (bool, error) {
+func (c *client) GetUserWithKeys(ctx context.Context, user *User) (bool, error) {
+	c, ctx, cancel := c.withTimeout(ctx, OperationResolve)
+	defer cancel()
+
 	// Resolve account prior to any user resolution activity.
-	if err := c.ResolveAccount(&user.Account); err != nil {
+	if err := c.ResolveAccount(ctx, &user.Account); err != nil {
 		return false, errors.Wrapf(err, "resolving account %s details", user.Account.Name)
 	}
 
@@ -218,7 +234,7 @@ func (c *client) GetUserWithKeys(user *User) (bool, error) {
 	// Return first user with keys.
 	for _, possibleUser := range resp.Users {
 		user.ID = possibleUser.Id
-		if err := c.ResolveUserKeys(user); err == nil {
+		if err := c.ResolveUserKeys(ctx, user); err == nil {
 			return true, nil
 		}
 	}
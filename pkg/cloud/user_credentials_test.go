@@ -71,7 +71,7 @@ var _ = Describe("User Credentials", func() {
 				Path: "ROOT/domainPath1",
 			}}}, nil)
 
-			Ω(client.ResolveDomain(&dummies.Domain)).Should(Succeed())
+			Ω(client.ResolveDomain(ctx, &dummies.Domain)).Should(Succeed())
 		})
 
 		It("search for CloudStack domain with incorrect domain path", func() {
@@ -83,7 +83,7 @@ var _ = Describe("User Credentials", func() {
 				Path: "ROOT/domainPath1",
 			}}}, nil)
 
-			err := client.ResolveDomain(&dummies.Domain)
+			err := client.ResolveDomain(ctx, &dummies.Domain)
 			Ω(err).ShouldNot(Succeed())
 			Ω(err.Error()).Should(Equal(fmt.Sprintf("domain Path %s did not match domain ID %s", dummies.Domain.Path, dummies.Domain.ID)))
 		})
@@ -97,7 +97,7 @@ var _ = Describe("User Credentials", func() {
 				Path: "ROOT/domainPath1",
 			}}}, nil)
 
-			err := client.ResolveDomain(&dummies.Domain)
+			err := client.ResolveDomain(ctx, &dummies.Domain)
 			Ω(err).ShouldNot(Succeed())
 			Ω(err.Error()).Should(Equal(fmt.Sprintf("domain ID %s provided, expected exactly one domain, got %d", dummies.Domain.ID, 2)))
 		})
@@ -110,7 +110,7 @@ var _ = Describe("User Credentials", func() {
 				Name: "domainName",
 			}}}, nil)
 
-			Ω(client.ResolveDomain(&dummies.Domain)).Should(Succeed())
+			Ω(client.ResolveDomain(ctx, &dummies.Domain)).Should(Succeed())
 		})
 
 		It("search for CloudStack domain when only domain Name is provided, but returns > 1 domain", func() {
@@ -121,7 +121,7 @@ var _ = Describe("User Credentials", func() {
 				Name: "domainName",
 			}}}, nil)
 
-			err := client.ResolveDomain(&dummies.Domain)
+			err := client.ResolveDomain(ctx, &dummies.Domain)
 			Ω(err).ShouldNot(Succeed())
 			Ω(err.Error()).Should(Equal(fmt.Sprintf("only domain name: %s provided, expected exactly one domain, got %d", dummies.Domain.Name, 2)))
 		})
@@ -142,7 +142,7 @@ var _ = Describe("User Credentials", func() {
 				Name: dummies.AccountName,
 			}}}, nil)
 
-			Ω(client.ResolveAccount(&dummies.Account)).Should(Succeed())
+			Ω(client.ResolveAccount(ctx, &dummies.Account)).Should(Succeed())
 
 		})
 
@@ -157,7 +157,7 @@ var _ = Describe("User Credentials", func() {
 			as.EXPECT().NewListAccountsParams().Return(asp)
 			as.EXPECT().ListAccounts(asp).Return(&csapi.ListAccountsResponse{Count: 0, Accounts: []*csapi.Account{}}, nil)
 
-			err := client.ResolveAccount(&dummies.Account)
+			err := client.ResolveAccount(ctx, &dummies.Account)
 			Ω(err).ShouldNot(Succeed())
 			Ω(err.Error()).Should(ContainSubstring("could not find account"))
 		})
@@ -173,7 +173,7 @@ var _ = Describe("User Credentials", func() {
 			as.EXPECT().NewListAccountsParams().Return(asp)
 			as.EXPECT().ListAccounts(asp).Return(&csapi.ListAccountsResponse{Count: 2, Accounts: []*csapi.Account{}}, nil)
 
-			err := client.ResolveAccount(&dummies.Account)
+			err := client.ResolveAccount(ctx, &dummies.Account)
 			Ω(err).ShouldNot(Succeed())
 			Ω(err.Error()).Should(ContainSubstring("expected 1 Account with account name"))
 		})
@@ -189,7 +189,7 @@ var _ = Describe("User Credentials", func() {
 			as.EXPECT().NewListAccountsParams().Return(asp)
 			as.EXPECT().ListAccounts(asp).Return(nil, fakeError)
 
-			Ω(client.ResolveAccount(&dummies.Account)).ShouldNot(Succeed())
+			Ω(client.ResolveAccount(ctx, &dummies.Account)).ShouldNot(Succeed())
 		})
 	})
 
@@ -221,7 +221,7 @@ var _ = Describe("User Credentials", func() {
 				}},
 			}, nil)
 
-			Ω(client.ResolveUser(&dummies.User)).Should(Succeed())
+			Ω(client.ResolveUser(ctx, &dummies.User)).Should(Succeed())
 		})
 
 		It("search for user fails while resolving account in CloudStack", func() {
@@ -235,7 +235,7 @@ var _ = Describe("User Credentials", func() {
 			as.EXPECT().NewListAccountsParams().Return(asp)
 			as.EXPECT().ListAccounts(asp).Return(nil, fakeError)
 
-			err := client.ResolveUser(&dummies.User)
+			err := client.ResolveUser(ctx, &dummies.User)
 			Ω(err).ShouldNot(Succeed())
 			Ω(err.Error()).Should(ContainSubstring("resolving account"))
 		})
@@ -257,7 +257,7 @@ var _ = Describe("User Credentials", func() {
 			us.EXPECT().NewListUsersParams().Return(usp)
 			us.EXPECT().ListUsers(usp).Return(nil, fakeError)
 
-			Ω(client.ResolveUser(&dummies.User)).ShouldNot(Succeed())
+			Ω(client.ResolveUser(ctx, &dummies.User)).ShouldNot(Succeed())
 		})
 
 		It("search for user in CloudStack results in more than one user", func() {
@@ -280,7 +280,7 @@ var _ = Describe("User Credentials", func() {
 				Users: []*csapi.User{},
 			}, nil)
 
-			err := client.ResolveUser(&dummies.User)
+			err := client.ResolveUser(ctx, &dummies.User)
 			Ω(err).ShouldNot(Succeed())
 			Ω(err.Error()).Should(ContainSubstring("expected 1 User with username"))
 		})
@@ -321,7 +321,7 @@ var _ = Describe("User Credentials", func() {
 				Secretkey: dummies.SecretKey,
 			}, nil)
 
-			Ω(client.ResolveUserKeys(&dummies.User)).Should(Succeed())
+			Ω(client.ResolveUserKeys(ctx, &dummies.User)).Should(Succeed())
 		})
 
 		It("get user keys fils when resolving user", func() {
@@ -331,7 +331,7 @@ var _ = Describe("User Credentials", func() {
 			us.EXPECT().NewListUsersParams().Return(usp)
 			us.EXPECT().ListUsers(usp).Return(nil, fakeError)
 
-			err := client.ResolveUserKeys(&dummies.User)
+			err := client.ResolveUserKeys(ctx, &dummies.User)
 			Ω(err).ShouldNot(Succeed())
 			Ω(err.Error()).Should(ContainSubstring("error encountered when resolving user details"))
 
@@ -353,7 +353,7 @@ var _ = Describe("User Credentials", func() {
 			us.EXPECT().NewGetUserKeysParams(gomock.Any()).Return(ukp)
 			us.EXPECT().GetUserKeys(ukp).Return(nil, fakeError)
 
-			err := client.ResolveUserKeys(&dummies.User)
+			err := client.ResolveUserKeys(ctx, &dummies.User)
 			Ω(err).ShouldNot(Succeed())
 			Ω(err.Error()).Should(ContainSubstring("error encountered when resolving user api keys"))
 		})
@@ -398,7 +398,7 @@ var _ = Describe("User Credentials", func() {
 				Secretkey: dummies.SecretKey,
 			}, nil)
 
-			result, err := client.GetUserWithKeys(&dummies.User)
+			result, err := client.GetUserWithKeys(ctx, &dummies.User)
 			Ω(err).Should(Succeed())
 			Ω(result).Should(BeTrue())
 		})
@@ -409,7 +409,7 @@ var _ = Describe("User Credentials", func() {
 			as.EXPECT().NewListAccountsParams().Return(asp)
 			as.EXPECT().ListAccounts(asp).Return(nil, fakeError)
 
-			result, err := client.GetUserWithKeys(&dummies.User)
+			result, err := client.GetUserWithKeys(ctx, &dummies.User)
 			Ω(err.Error()).Should(ContainSubstring(fmt.Sprintf("resolving account %s details", dummies.User.Account.Name)))
 			Ω(result).Should(BeFalse())
 		})
@@ -427,7 +427,7 @@ var _ = Describe("User Credentials", func() {
 			us.EXPECT().NewListUsersParams().Return(usp)
 			us.EXPECT().ListUsers(usp).Return(nil, fakeError)
 
-			result, err := client.GetUserWithKeys(&dummies.User)
+			result, err := client.GetUserWithKeys(ctx, &dummies.User)
 			Ω(err).ShouldNot(Succeed())
 			Ω(result).Should(BeFalse())
 		})
@@ -447,40 +447,40 @@ var _ = Describe("User Credentials", func() {
 		})
 
 		It("can resolve a domain from the path", func() {
-			Ω(client.ResolveDomain(&domain)).Should(Succeed())
+			Ω(client.ResolveDomain(ctx, &domain)).Should(Succeed())
 			Ω(domain.ID).ShouldNot(BeEmpty())
 		})
 
 		It("can resolve an account from the domain path and account name", func() {
-			Ω(client.ResolveAccount(&account)).Should(Succeed())
+			Ω(client.ResolveAccount(ctx, &account)).Should(Succeed())
 			Ω(account.ID).ShouldNot(BeEmpty())
 		})
 
 		It("can resolve a user from the domain path, account name, and user name", func() {
-			Ω(client.ResolveUser(&user)).Should(Succeed())
+			Ω(client.ResolveUser(ctx, &user)).Should(Succeed())
 			Ω(user.ID).ShouldNot(BeEmpty())
 		})
 
 		It("can get sub-domain user's credentials", func() {
-			Ω(client.ResolveUserKeys(&user)).Should(Succeed())
+			Ω(client.ResolveUserKeys(ctx, &user)).Should(Succeed())
 
 			Ω(user.APIKey).ShouldNot(BeEmpty())
 			Ω(user.SecretKey).ShouldNot(BeEmpty())
 		})
 
 		It("can get an arbitrary user with keys from domain and account specifications alone", func() {
-			found, err := client.GetUserWithKeys(&user)
+			found, err := client.GetUserWithKeys(ctx, &user)
 			Ω(err).ShouldNot(HaveOccurred())
 			Ω(found).Should(BeTrue())
 			Ω(user.APIKey).ShouldNot(BeEmpty())
 		})
 
 		It("can get create a new client as another user", func() {
-			found, err := client.GetUserWithKeys(&user)
+			found, err := client.GetUserWithKeys(ctx, &user)
 			Ω(err).ShouldNot(HaveOccurred())
 			Ω(found).Should(BeTrue())
 			Ω(user.APIKey).ShouldNot(BeEmpty())
-			newClient, err := client.NewClientInDomainAndAccount(user.Account.Domain.Name, user.Account.Name)
+			newClient, err := client.NewClientInDomainAndAccount(ctx, user.Account.Domain.Name, user.Account.Name)
 			Ω(err).ShouldNot(HaveOccurred())
 			Ω(newClient).ShouldNot(BeNil())
 		})
@@ -0,0 +1,339 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
+)
+
+const (
+	VPCOffering        = "Default VPC offering"
+	VPCTierNetOffering = "DefaultIsolatedNetworkOfferingForVpcNetworks"
+)
+
+// defaultACLRules allow all traffic in and out of a VPC tier, like CloudStack's default_allow network ACL list.
+var defaultACLRules = []infrav1.NetworkACLRule{
+	{Protocol: "all", TrafficType: "Ingress", Action: "Allow"},
+	{Protocol: "all", TrafficType: "Egress", Action: "Allow"},
+}
+
+// getOrCreateVPCTier resolves or creates the failure domain's VPC and the isolated network's tier in it, and makes
+// the tier's network ACL list match the VPC's ACL rules.
+func (c *client) getOrCreateVPCTier(
+	ctx context.Context,
+	fd *infrav1.CloudStackFailureDomain,
+	isoNet *infrav1.CloudStackIsolatedNetwork,
+	csCluster *infrav1.CloudStackCluster,
+) error {
+	vpcSpec := fd.Spec.Zone.Network.VPC
+	if vpcSpec == nil {
+		return errors.Errorf("network %s has the VPC network type but no VPC", fd.Spec.Zone.Network.Name)
+	}
+	if err := c.getOrCreateVPC(ctx, fd, vpcSpec, isoNet); err != nil {
+		return err
+	}
+	if err := c.AddClusterTag(ctx, ResourceTypeVPC, isoNet.Spec.VPCID, csCluster); err != nil {
+		return errors.Wrapf(err, "tagging VPC with ID %s", isoNet.Spec.VPCID)
+	}
+	if err := c.getOrCreateNetworkACLList(isoNet); err != nil {
+		return err
+	}
+	if err := c.reconcileNetworkACLRules(isoNet.Status.ACLListID, vpcSpec.ACLRules); err != nil {
+		return errors.Wrap(err, "configuring the tier's network ACL list")
+	}
+
+	net := isoNet.Network()
+	if err := c.ResolveNetwork(ctx, net); err != nil { // Doesn't exist, create the tier.
+		return c.createVPCTier(ctx, fd, vpcSpec, isoNet)
+	}
+	isoNet.Spec.ID = net.ID
+	return c.ensureTierNetworkACLList(isoNet)
+}
+
+// getOrCreateVPC resolves the VPC by ID or by name in the failure domain's zone, creating it if there is none of that
+// name.
+func (c *client) getOrCreateVPC(
+	ctx context.Context,
+	fd *infrav1.CloudStackFailureDomain,
+	vpcSpec *infrav1.VPC,
+	isoNet *infrav1.CloudStackIsolatedNetwork,
+) error {
+	p := c.cs.VPC.NewListVPCsParams()
+	p.SetZoneid(fd.Spec.Zone.ID)
+	setIfNotEmpty(vpcSpec.ID, p.SetId)
+	setIfNotEmpty(vpcSpec.Name, p.SetName)
+	resp, err := c.cs.VPC.ListVPCs(p)
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return errors.Wrap(err, "listing VPCs")
+	} else if resp.Count > 1 {
+		return errors.Errorf("expected 1 VPC with name %s, but got %d", vpcSpec.Name, resp.Count)
+	} else if resp.Count == 1 {
+		isoNet.Spec.VPCID = resp.VPCs[0].Id
+		return nil
+	} else if vpcSpec.ID != "" {
+		return errors.Errorf("no VPC with ID %s found in zone %s", vpcSpec.ID, fd.Spec.Zone.Name)
+	} else if vpcSpec.CIDR == "" {
+		return errors.Errorf("VPC %s doesn't exist and can't be created without a CIDR", vpcSpec.Name)
+	}
+
+	offering := vpcSpec.Offering
+	if offering == "" {
+		offering = VPCOffering
+	}
+	offeringID, count, err := c.cs.VPC.GetVPCOfferingID(offering)
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return errors.Wrapf(err, "fetching VPC offering %s", offering)
+	} else if count != 1 {
+		return errors.Errorf("expected 1 VPC offering with name %s, but got %d", offering, count)
+	}
+	createParams := c.cs.VPC.NewCreateVPCParams(vpcSpec.CIDR, vpcSpec.Name, vpcSpec.Name, offeringID, fd.Spec.Zone.ID)
+	vpc, err := c.cs.VPC.CreateVPC(createParams)
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return errors.Wrapf(err, "creating VPC with name %s", vpcSpec.Name)
+	}
+	isoNet.Spec.VPCID = vpc.Id
+	return c.AddCreatedByCAPCTag(ctx, ResourceTypeVPC, vpc.Id)
+}
+
+// getOrCreateNetworkACLList resolves or creates the tier's network ACL list, which is named after the tier.
+func (c *client) getOrCreateNetworkACLList(isoNet *infrav1.CloudStackIsolatedNetwork) error {
+	p := c.cs.NetworkACL.NewListNetworkACLListsParams()
+	p.SetVpcid(isoNet.Spec.VPCID)
+	p.SetName(isoNet.Spec.Name)
+	resp, err := c.cs.NetworkACL.ListNetworkACLLists(p)
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return errors.Wrap(err, "listing network ACL lists")
+	} else if resp.Count > 0 {
+		isoNet.Status.ACLListID = resp.NetworkACLLists[0].Id
+		return nil
+	}
+
+	createParams := c.cs.NetworkACL.NewCreateNetworkACLListParams(isoNet.Spec.Name, isoNet.Spec.VPCID)
+	createParams.SetDescription("Network ACL list of VPC tier " + isoNet.Spec.Name)
+	list, err := c.cs.NetworkACL.CreateNetworkACLList(createParams)
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return errors.Wrapf(err, "creating network ACL list %s", isoNet.Spec.Name)
+	}
+	isoNet.Status.ACLListID = list.Id
+	return nil
+}
+
+// aclRuleKey identifies an ACL rule by what it matches and does, so that existing rules can be compared to wanted
+// ones.
+func aclRuleKey(protocol, startPort, endPort, cidrs, trafficType, action string) string {
+	return strings.ToLower(strings.Join([]string{protocol, startPort, endPort, cidrs, trafficType, action}, "|"))
+}
+
+// reconcileNetworkACLRules makes the items of a network ACL list exactly the wanted rules, numbered in the order they
+// are listed, or the default rules if none are wanted. As item numbers are unique in a list, a list that doesn't
+// match is rebuilt.
+func (c *client) reconcileNetworkACLRules(aclID string, rules []infrav1.NetworkACLRule) error {
+	if len(rules) == 0 {
+		rules = defaultACLRules
+	}
+	p := c.cs.NetworkACL.NewListNetworkACLsParams()
+	p.SetAclid(aclID)
+	resp, err := c.cs.NetworkACL.ListNetworkACLs(p)
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return errors.Wrap(err, "listing network ACL rules")
+	}
+
+	inSync := len(resp.NetworkACLs) == len(rules)
+	for _, item := range resp.NetworkACLs {
+		if item.Number < 1 || item.Number > len(rules) ||
+			aclRuleKey(item.Protocol, item.Startport, item.Endport, item.Cidrlist, item.Traffictype, item.Action) !=
+				aclRuleKey(aclRuleFields(rules[item.Number-1])) {
+			inSync = false
+		}
+	}
+	if inSync {
+		return nil
+	}
+	for _, item := range resp.NetworkACLs {
+		if err := c.deleteNetworkACLRule(item.Id); err != nil {
+			return err
+		}
+	}
+	for i, rule := range rules {
+		if err := c.createNetworkACLRule(aclID, i+1, rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// aclRuleFields returns the fields of a wanted ACL rule, defaulted the way CloudStack reports them.
+func aclRuleFields(rule infrav1.NetworkACLRule) (protocol, startPort, endPort, cidrs, trafficType, action string) {
+	protocol, trafficType, action = rule.Protocol, rule.TrafficType, rule.Action
+	if trafficType == "" {
+		trafficType = "Ingress"
+	}
+	if action == "" {
+		action = "Allow"
+	}
+	cidrs = strings.Join(rule.CIDRs, ",")
+	if cidrs == "" {
+		cidrs = "0.0.0.0/0"
+	}
+	if rule.StartPort != 0 {
+		end := rule.EndPort
+		if end == 0 {
+			end = rule.StartPort
+		}
+		startPort, endPort = strconv.Itoa(int(rule.StartPort)), strconv.Itoa(int(end))
+	}
+	return protocol, startPort, endPort, cidrs, trafficType, action
+}
+
+func (c *client) createNetworkACLRule(aclID string, number int, rule infrav1.NetworkACLRule) error {
+	protocol, startPort, endPort, cidrs, trafficType, action := aclRuleFields(rule)
+	p := c.cs.NetworkACL.NewCreateNetworkACLParams(protocol)
+	p.SetAclid(aclID)
+	p.SetNumber(number)
+	p.SetCidrlist(strings.Split(cidrs, ","))
+	p.SetTraffictype(trafficType)
+	p.SetAction(action)
+	if startPort != "" {
+		p.SetStartport(int(rule.StartPort))
+		end, _ := strconv.Atoi(endPort)
+		p.SetEndport(end)
+	}
+	if _, err := c.cs.NetworkACL.CreateNetworkACL(p); err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return errors.Wrapf(err, "creating network ACL rule %d", number)
+	}
+	return nil
+}
+
+func (c *client) deleteNetworkACLRule(id string) error {
+	_, err := c.cs.NetworkACL.DeleteNetworkACL(c.cs.NetworkACL.NewDeleteNetworkACLParams(id))
+	c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+	return errors.Wrapf(err, "deleting network ACL rule with ID %s", id)
+}
+
+// createVPCTier creates the isolated network as a tier of its VPC, with the tier's network ACL list.
+func (c *client) createVPCTier(
+	ctx context.Context,
+	fd *infrav1.CloudStackFailureDomain,
+	vpcSpec *infrav1.VPC,
+	isoNet *infrav1.CloudStackIsolatedNetwork,
+) error {
+	gateway, netmask, err := gatewayAndNetmask(vpcSpec.TierCIDR, "")
+	if err != nil {
+		return err
+	}
+	offeringID, err := c.resolveNetworkOffering(&infrav1.CloudStackResourceIdentifier{Name: vpcSpec.TierOffering},
+		VPCTierNetOffering)
+	if err != nil {
+		return err
+	}
+
+	p := c.cs.Network.NewCreateNetworkParams(isoNet.Spec.Name, isoNet.Spec.Name, offeringID, fd.Spec.Zone.ID)
+	p.SetVpcid(isoNet.Spec.VPCID)
+	p.SetAclid(isoNet.Status.ACLListID)
+	p.SetGateway(gateway)
+	p.SetNetmask(netmask)
+	resp, err := c.cs.Network.CreateNetwork(p)
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return errors.Wrapf(err, "creating VPC tier with name %s", isoNet.Spec.Name)
+	}
+	isoNet.Spec.ID = resp.Id
+	return c.AddCreatedByCAPCTag(ctx, ResourceTypeNetwork, isoNet.Spec.ID)
+}
+
+// ensureTierNetworkACLList puts an existing tier on its network ACL list.
+func (c *client) ensureTierNetworkACLList(isoNet *infrav1.CloudStackIsolatedNetwork) error {
+	tier, count, err := c.cs.Network.GetNetworkByID(isoNet.Spec.ID)
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return errors.Wrapf(err, "fetching VPC tier with ID %s", isoNet.Spec.ID)
+	} else if count != 1 {
+		return errors.Errorf("expected 1 Network with UUID %s, but got %d", isoNet.Spec.ID, count)
+	} else if tier.Vpcid != isoNet.Spec.VPCID {
+		return errors.Errorf("network %s isn't a tier of VPC with ID %s", isoNet.Spec.Name, isoNet.Spec.VPCID)
+	} else if tier.Aclid == isoNet.Status.ACLListID {
+		return nil
+	}
+	p := c.cs.NetworkACL.NewReplaceNetworkACLListParams(isoNet.Status.ACLListID)
+	p.SetNetworkid(isoNet.Spec.ID)
+	_, err = c.cs.NetworkACL.ReplaceNetworkACLList(p)
+	c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+	return errors.Wrapf(err, "replacing network ACL list of VPC tier %s", isoNet.Spec.Name)
+}
+
+// disposeVPCResources removes the tier's network ACL list once the tier is gone, and the VPC once no cluster uses
+// it, if CAPC created it.
+func (c *client) disposeVPCResources(ctx context.Context, isoNet *infrav1.CloudStackIsolatedNetwork, csCluster *infrav1.CloudStackCluster) error {
+	p := c.cs.Network.NewListNetworksParams()
+	p.SetVpcid(isoNet.Spec.VPCID)
+	tiers, err := c.cs.Network.ListNetworks(p)
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return errors.Wrap(err, "listing VPC tiers")
+	}
+	for _, tier := range tiers.Networks {
+		if tier.Id == isoNet.Spec.ID { // Still in use by another cluster.
+			return nil
+		}
+	}
+	if err := c.deleteNetworkACLList(isoNet); err != nil {
+		return err
+	}
+
+	if err := c.DeleteClusterTag(ctx, ResourceTypeVPC, isoNet.Spec.VPCID, csCluster); err != nil {
+		return err
+	}
+	if allowDisposal, err := c.DoClusterTagsAllowDisposal(ctx, ResourceTypeVPC, isoNet.Spec.VPCID); err != nil {
+		return err
+	} else if !allowDisposal || tiers.Count > 0 { // Not CAPC's to remove, or someone else's tiers are still in it.
+		return nil
+	}
+	_, err = c.cs.VPC.DeleteVPC(c.cs.VPC.NewDeleteVPCParams(isoNet.Spec.VPCID))
+	c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+	return errors.Wrapf(err, "deleting VPC with ID %s", isoNet.Spec.VPCID)
+}
+
+// deleteNetworkACLList deletes the tier's network ACL list if it's still there.
+func (c *client) deleteNetworkACLList(isoNet *infrav1.CloudStackIsolatedNetwork) error {
+	p := c.cs.NetworkACL.NewListNetworkACLListsParams()
+	p.SetVpcid(isoNet.Spec.VPCID)
+	p.SetName(isoNet.Spec.Name)
+	resp, err := c.cs.NetworkACL.ListNetworkACLLists(p)
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return errors.Wrap(err, "listing network ACL lists")
+	}
+	for _, list := range resp.NetworkACLLists {
+		if _, err := c.cs.NetworkACL.DeleteNetworkACLList(c.cs.NetworkACL.NewDeleteNetworkACLListParams(list.Id)); err != nil {
+			c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+			return errors.Wrapf(err, "deleting network ACL list with ID %s", list.Id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_test
+
+import (
+	csapi "github.com/apache/cloudstack-go/v2/cloudstack"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
+	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta2"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/test/simulator"
+)
+
+var _ = Describe("VPC networks", func() {
+	UseSimulator()
+
+	BeforeEach(func() {
+		dummies.SetDummyIsoNetToNameOnly()
+		dummies.CSFailureDomain1.Spec.Zone = dummies.Zone1
+		dummies.CSFailureDomain1.Spec.Zone.Network = infrav1.Network{Name: "capc-vpc", Type: infrav1.NetworkTypeVPC,
+			VPC: &infrav1.VPC{Name: "capc-vpc", CIDR: "10.40.0.0/16", TierCIDR: "10.40.1.0/24"}}
+		dummies.CSCluster.Spec.ControlPlaneEndpoint.Host = ""
+		sim.AddNetwork(dummies.Zone1.ID, "other-network", simulator.NetworkTypeShared, "10.20.0.0/24")
+	})
+
+	// tier returns the simulator's network for the isolated network, if there is one.
+	tier := func() *csapi.Network {
+		for _, net := range sim.Networks() {
+			if net.Name == dummies.CSISONet1.Spec.Name {
+				return &net
+			}
+		}
+		return nil
+	}
+
+	It("builds out a VPC and a tier in it idempotently and removes them again", func() {
+		for i := 0; i < 2; i++ {
+			Ω(client.GetOrCreateIsolatedNetwork(
+				ctx,
+				dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).Should(Succeed())
+		}
+
+		Ω(sim.VPCs()).Should(HaveLen(1))
+		vpc := sim.VPCs()[0]
+		Ω(vpc.Cidr).Should(Equal("10.40.0.0/16"))
+		Ω(dummies.CSISONet1.Spec.VPCID).Should(Equal(vpc.Id))
+		Ω(tier()).ShouldNot(BeNil())
+		Ω(tier().Vpcid).Should(Equal(vpc.Id))
+		Ω(tier().Gateway).Should(Equal("10.40.1.1"))
+		Ω(tier().Aclid).Should(Equal(dummies.CSISONet1.Status.ACLListID))
+		Ω(sim.NetworkACLs(dummies.CSISONet1.Status.ACLListID)).Should(HaveLen(2))
+
+		Ω(sim.LoadBalancerRules()).Should(HaveLen(1))
+		Ω(sim.FirewallRules()).Should(BeEmpty())
+		Ω(sim.EgressFirewallRules()).Should(BeEmpty())
+		Ω(dummies.CSCluster.Spec.ControlPlaneEndpoint.Host).Should(BeElementOf(dummies.SimulatorPublicIPs))
+		for _, ip := range sim.PublicIPAddresses() {
+			if ip.Ipaddress == dummies.CSCluster.Spec.ControlPlaneEndpoint.Host {
+				Ω(ip.Vpcid).Should(Equal(vpc.Id))
+				Ω(ip.Issourcenat).Should(BeFalse())
+			}
+		}
+
+		Ω(client.DisposeIsoNetResources(
+			ctx,
+			dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).Should(Succeed())
+		Ω(tier()).Should(BeNil())
+		Ω(sim.VPCs()).Should(BeEmpty())
+		Ω(sim.LoadBalancerRules()).Should(BeEmpty())
+		for _, list := range sim.NetworkACLLists() {
+			Ω(list.Id).ShouldNot(Equal(dummies.CSISONet1.Status.ACLListID))
+		}
+	})
+
+	It("adds a tier to an existing VPC with the configured ACL rules and leaves the VPC behind", func() {
+		vpc := sim.AddVPC(dummies.Zone1.ID, "existing-vpc", "10.50.0.0/16")
+		dummies.CSFailureDomain1.Spec.Zone.Network.VPC = &infrav1.VPC{ID: vpc.Id, TierCIDR: "10.50.3.0/24",
+			ACLRules: []infrav1.NetworkACLRule{
+				{Protocol: "tcp", StartPort: dummies.EndPointPort, EndPort: dummies.EndPointPort,
+					CIDRs: []string{"192.0.2.0/24"}, TrafficType: "Ingress", Action: "Allow"},
+				{Protocol: "all", TrafficType: "Egress", Action: "Allow"},
+			}}
+
+		Ω(client.GetOrCreateIsolatedNetwork(
+			ctx,
+			dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).Should(Succeed())
+		Ω(sim.VPCs()).Should(HaveLen(1))
+		Ω(tier().Vpcid).Should(Equal(vpc.Id))
+		acls := sim.NetworkACLs(dummies.CSISONet1.Status.ACLListID)
+		Ω(acls).Should(HaveLen(2))
+		Ω(acls[0].Cidrlist).Should(Equal("192.0.2.0/24"))
+
+		// Dropping a rule rebuilds the list.
+		dummies.CSFailureDomain1.Spec.Zone.Network.VPC.ACLRules = dummies.CSFailureDomain1.Spec.Zone.Network.VPC.ACLRules[:1]
+		Ω(client.GetOrCreateIsolatedNetwork(
+			ctx,
+			dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).Should(Succeed())
+		Ω(sim.NetworkACLs(dummies.CSISONet1.Status.ACLListID)).Should(HaveLen(1))
+
+		Ω(client.DisposeIsoNetResources(
+			ctx,
+			dummies.CSFailureDomain1, dummies.CSISONet1, dummies.CSCluster)).Should(Succeed())
+		Ω(tier()).Should(BeNil())
+		Ω(sim.VPCs()).Should(HaveLen(1))
+	})
+})
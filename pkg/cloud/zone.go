@@ -17,17 +17,31 @@ limitations under the License.
 package cloud
 
 import (
+	"context"
+
+	"github.com/apache/cloudstack-go/v2/cloudstack"
 	"github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
 	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
 )
 
 type ZoneIFace interface {
-	ResolveZone(*infrav1.CloudStackZoneSpec) error
-	ResolveNetworkForZone(*infrav1.CloudStackZoneSpec) error
+	ResolveZone(context.Context, *infrav1.CloudStackZoneSpec) error
+	ResolveNetworkForZone(context.Context, *infrav1.CloudStackZoneSpec) error
+	GetZoneFreeCapacity(context.Context, *infrav1.CloudStackZoneSpec) (float64, error)
+	ResolveZoneScope(context.Context, *infrav1.CloudStackZoneSpec) error
 }
 
-func (c *client) ResolveZone(zSpec *infrav1.CloudStackZoneSpec) (retErr error) {
+// Capacity types listCapacity reports.
+const (
+	capacityTypeMemory = 0
+	capacityTypeCPU    = 1
+)
+
+func (c *client) ResolveZone(ctx context.Context, zSpec *infrav1.CloudStackZoneSpec) (retErr error) {
+	c, _, cancel := c.withTimeout(ctx, OperationResolve)
+	defer cancel()
+
 	if zoneID, count, err := c.cs.Zone.GetZoneID(zSpec.Name); err != nil {
 		retErr = multierror.Append(retErr, errors.Wrapf(err, "could not get Zone ID from %v", zSpec.Name))
 		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
@@ -52,8 +66,54 @@ func (c *client) ResolveZone(zSpec *infrav1.CloudStackZoneSpec) (retErr error) {
 	return nil
 }
 
+// ResolveZoneScope resolves the pod and cluster a resolved zone's placement is narrowed to, checking they're in the
+// zone and the cluster in the pod. CloudStack only lets root admins list them.
+func (c *client) ResolveZoneScope(ctx context.Context, zSpec *infrav1.CloudStackZoneSpec) error {
+	c, _, cancel := c.withTimeout(ctx, OperationResolve)
+	defer cancel()
+
+	if pod := zSpec.Pod; pod != nil {
+		p := c.cs.Pod.NewListPodsParams()
+		p.SetZoneid(zSpec.ID)
+		setIfNotEmpty(pod.ID, p.SetId)
+		setIfNotEmpty(pod.Name, p.SetName)
+		resp, err := c.cs.Pod.ListPods(p)
+		if err != nil {
+			c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+			return errors.Wrapf(err, "listing pod with ID %q or name %q", pod.ID, pod.Name)
+		} else if resp.Count != 1 {
+			return errors.Errorf("expected 1 pod with ID %q or name %q in zone %s, but got %d",
+				pod.ID, pod.Name, zSpec.Name, resp.Count)
+		}
+		pod.ID, pod.Name = resp.Pods[0].Id, resp.Pods[0].Name
+	}
+
+	if cluster := zSpec.Cluster; cluster != nil {
+		p := c.cs.Cluster.NewListClustersParams()
+		p.SetZoneid(zSpec.ID)
+		if zSpec.Pod != nil {
+			p.SetPodid(zSpec.Pod.ID)
+		}
+		setIfNotEmpty(cluster.ID, p.SetId)
+		setIfNotEmpty(cluster.Name, p.SetName)
+		resp, err := c.cs.Cluster.ListClusters(p)
+		if err != nil {
+			c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+			return errors.Wrapf(err, "listing cluster with ID %q or name %q", cluster.ID, cluster.Name)
+		} else if resp.Count != 1 {
+			return errors.Errorf("expected 1 cluster with ID %q or name %q in zone %s, but got %d",
+				cluster.ID, cluster.Name, zSpec.Name, resp.Count)
+		}
+		cluster.ID, cluster.Name = resp.Clusters[0].Id, resp.Clusters[0].Name
+	}
+	return nil
+}
+
 // ResolveNetworkForZone fetches details on Zone's specified network.
-func (c *client) ResolveNetworkForZone(zSpec *infrav1.CloudStackZoneSpec) (retErr error) {
+func (c *client) ResolveNetworkForZone(ctx context.Context, zSpec *infrav1.CloudStackZoneSpec) (retErr error) {
+	c, _, cancel := c.withTimeout(ctx, OperationResolve)
+	defer cancel()
+
 	netName := zSpec.Network.Name
 	netDetails, count, err := c.cs.Network.GetNetworkByName(netName)
 	if err != nil {
@@ -64,7 +124,7 @@ func (c *client) ResolveNetworkForZone(zSpec *infrav1.CloudStackZoneSpec) (retEr
 			"expected 1 Network with name %s, but got %d", netName, count))
 	} else { // Got netID from the network's name.
 		zSpec.Network.ID = netDetails.Id
-		zSpec.Network.Type = netDetails.Type
+		resolveNetworkType(&zSpec.Network, netDetails)
 		return nil
 	}
 
@@ -78,6 +138,59 @@ func (c *client) ResolveNetworkForZone(zSpec *infrav1.CloudStackZoneSpec) (retEr
 	}
 	zSpec.Network.Name = netDetails.Name
 	zSpec.Network.ID = netDetails.Id
-	zSpec.Network.Type = netDetails.Type
+	resolveNetworkType(&zSpec.Network, netDetails)
 	return nil
 }
+
+// resolveNetworkType sets the network's type from CloudStack's, keeping the VPC type of VPC tiers, which CloudStack
+// reports as isolated networks.
+func resolveNetworkType(net *infrav1.Network, netDetails *cloudstack.Network) {
+	if net.Type != NetworkTypeVPC {
+		net.Type = netDetails.Type
+	}
+}
+
+// GetZoneFreeCapacity returns the share of a zone's CPU or memory, whichever is scarcer, that isn't in use, between 0
+// and 1. Only the pod or cluster the zone's placement is narrowed to is counted. Listing capacity requires a root
+// admin.
+func (c *client) GetZoneFreeCapacity(ctx context.Context, zSpec *infrav1.CloudStackZoneSpec) (float64, error) {
+	c, _, cancel := c.withTimeout(ctx, OperationResolve)
+	defer cancel()
+
+	zoneID := zSpec.ID
+	p := c.cs.SystemCapacity.NewListCapacityParams()
+	p.SetZoneid(zoneID)
+	if zSpec.Pod != nil {
+		setIfNotEmpty(zSpec.Pod.ID, p.SetPodid)
+	}
+	if zSpec.Cluster != nil {
+		setIfNotEmpty(zSpec.Cluster.ID, p.SetClusterid)
+	}
+	resp, err := c.cs.SystemCapacity.ListCapacity(p)
+	if err != nil {
+		c.customMetrics.EvaluateErrorAndIncrementAcsReconciliationErrorCounter(err)
+		return 0, errors.Wrapf(err, "listing capacity of zone %s", zoneID)
+	}
+
+	free, found := 1.0, false
+	for _, capacity := range resp.Capacity {
+		if capacity.Zoneid != zoneID || (capacity.Type != capacityTypeMemory && capacity.Type != capacityTypeCPU) {
+			continue
+		}
+		found = true
+		if capacity.Capacitytotal <= 0 {
+			free = 0
+			continue
+		}
+		if f := 1 - float64(capacity.Capacityused)/float64(capacity.Capacitytotal); f < free {
+			free = f
+		}
+	}
+	if !found {
+		return 0, errors.Errorf("no CPU or memory capacity listed for zone %s", zoneID)
+	}
+	if free < 0 {
+		free = 0
+	}
+	return free, nil
+}
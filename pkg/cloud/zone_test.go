@@ -24,6 +24,7 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/pkg/errors"
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
 	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
 	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta2"
 )
@@ -35,7 +36,6 @@ var _ = Describe("Zone", func() {
 
 	fakeError := errors.New(errorMessage)
 	var (
-		client     cloud.Client
 		mockCtrl   *gomock.Controller
 		mockClient *csapi.CloudStackClient
 		zs         *csapi.MockZoneServiceIface
@@ -61,7 +61,7 @@ var _ = Describe("Zone", func() {
 			zs.EXPECT().GetZoneID(dummies.Zone1.Name).Return("", -1, expectedErr)
 			zs.EXPECT().GetZoneByID(dummies.Zone1.ID).Return(nil, -1, expectedErr)
 
-			err := client.ResolveZone(&dummies.CSFailureDomain1.Spec.Zone)
+			err := client.ResolveZone(ctx, &dummies.CSFailureDomain1.Spec.Zone)
 			Expect(errors.Cause(err)).To(MatchError(expectedErr))
 		})
 
@@ -69,7 +69,7 @@ var _ = Describe("Zone", func() {
 			zs.EXPECT().GetZoneID(dummies.Zone1.Name).Return(dummies.Zone1.ID, 2, nil)
 			zs.EXPECT().GetZoneByID(dummies.Zone1.ID).Return(nil, -1, fmt.Errorf("Not found"))
 
-			Ω(client.ResolveZone(&dummies.CSFailureDomain1.Spec.Zone)).Should(MatchError(And(
+			Ω(client.ResolveZone(ctx, &dummies.CSFailureDomain1.Spec.Zone)).Should(MatchError(And(
 				ContainSubstring("expected 1 Zone with name "+dummies.Zone1.Name+", but got 2"),
 				ContainSubstring("could not get Zone by ID "+dummies.Zone1.ID+": Not found"))))
 		})
@@ -78,7 +78,7 @@ var _ = Describe("Zone", func() {
 			zs.EXPECT().GetZoneID(dummies.Zone1.Name).Return(dummies.Zone1.ID, 2, nil)
 			zs.EXPECT().GetZoneByID(dummies.Zone1.ID).Return(&csapi.Zone{}, 2, nil)
 
-			Ω(client.ResolveZone(&dummies.CSFailureDomain1.Spec.Zone).Error()).
+			Ω(client.ResolveZone(ctx, &dummies.CSFailureDomain1.Spec.Zone).Error()).
 				Should(ContainSubstring("expected 1 Zone with name " + dummies.Zone1.Name + ", but got 2"))
 		})
 	})
@@ -87,14 +87,14 @@ var _ = Describe("Zone", func() {
 		It("get network by name specfied in zone spec", func() {
 			ns.EXPECT().GetNetworkByName(dummies.Zone1.Network.Name).Return(&csapi.Network{}, 1, nil)
 
-			Ω(client.ResolveNetworkForZone(&dummies.CSFailureDomain1.Spec.Zone)).Should(Succeed())
+			Ω(client.ResolveNetworkForZone(ctx, &dummies.CSFailureDomain1.Spec.Zone)).Should(Succeed())
 		})
 
 		It("get network by name specfied in zone spec returns > 1 network", func() {
 			ns.EXPECT().GetNetworkByName(dummies.Zone2.Network.Name).Return(&csapi.Network{}, 2, nil)
 			ns.EXPECT().GetNetworkByID(dummies.Zone2.Network.ID).Return(&csapi.Network{}, 2, nil)
 
-			Ω(client.ResolveNetworkForZone(&dummies.CSFailureDomain2.Spec.Zone)).Should(MatchError(And(
+			Ω(client.ResolveNetworkForZone(ctx, &dummies.CSFailureDomain2.Spec.Zone)).Should(MatchError(And(
 				ContainSubstring(fmt.Sprintf("expected 1 Network with name %s, but got %d", dummies.Zone2.Network.Name, 2)),
 				ContainSubstring(fmt.Sprintf("expected 1 Network with UUID %v, but got %d", dummies.Zone2.Network.ID, 2)))))
 		})
@@ -102,14 +102,60 @@ var _ = Describe("Zone", func() {
 		It("get network by id specfied in zone spec", func() {
 			ns.EXPECT().GetNetworkByName(dummies.Zone2.Network.Name).Return(nil, -1, fakeError)
 			ns.EXPECT().GetNetworkByID(dummies.Zone2.Network.ID).Return(&csapi.Network{}, 1, nil)
-			Ω(client.ResolveNetworkForZone(&dummies.CSFailureDomain2.Spec.Zone)).Should(Succeed())
+			Ω(client.ResolveNetworkForZone(ctx, &dummies.CSFailureDomain2.Spec.Zone)).Should(Succeed())
 		})
 
 		It("get network by id fails", func() {
 			ns.EXPECT().GetNetworkByName(dummies.Zone2.Network.Name).Return(nil, -1, fakeError)
 			ns.EXPECT().GetNetworkByID(dummies.Zone2.Network.ID).Return(nil, -1, fakeError)
 
-			Ω(client.ResolveNetworkForZone(&dummies.CSFailureDomain2.Spec.Zone).Error()).Should(ContainSubstring(fmt.Sprintf("could not get Network by ID %s", dummies.Zone2.Network.ID)))
+			Ω(client.ResolveNetworkForZone(ctx, &dummies.CSFailureDomain2.Spec.Zone).Error()).Should(ContainSubstring(fmt.Sprintf("could not get Network by ID %s", dummies.Zone2.Network.ID)))
+		})
+	})
+
+	Context("with the simulator", func() {
+		UseSimulator()
+
+		It("resolves a zone and its network by name", func() {
+			zone := dummies.CSFailureDomain1.Spec.Zone
+			zone.ID, zone.Network.ID = "", ""
+
+			Ω(client.ResolveZone(ctx, &zone)).Should(Succeed())
+			Ω(zone.ID).Should(Equal(dummies.Zone1.ID))
+			Ω(client.ResolveNetworkForZone(ctx, &zone)).Should(Succeed())
+			Ω(zone.Network.ID).Should(Equal(dummies.Net1.ID))
+			Ω(zone.Network.Type).Should(Equal(cloud.NetworkTypeShared))
+		})
+
+		It("fails to resolve a zone that doesn't exist", func() {
+			Ω(client.ResolveZone(ctx, &dummies.Zone2)).ShouldNot(Succeed())
+		})
+
+		It("reports the share of a zone's CPU or memory that's free, whichever is scarcer", func() {
+			_, err := client.GetZoneFreeCapacity(ctx, &dummies.Zone1)
+			Ω(err).Should(MatchError(ContainSubstring("no CPU or memory capacity listed")))
+
+			sim.SetZoneCapacity(dummies.Zone1.ID, 0, 1000, 250)
+			sim.SetZoneCapacity(dummies.Zone1.ID, 1, 100, 60)
+			Ω(client.GetZoneFreeCapacity(ctx, &dummies.Zone1)).Should(BeNumerically("~", 0.4))
+		})
+
+		It("resolves the pod and cluster a zone is narrowed to by name", func() {
+			pod := sim.AddPod(dummies.Zone1.ID, "pod1")
+			cluster := sim.AddCluster(pod.Id, "cluster1")
+			otherPod := sim.AddPod(dummies.Zone1.ID, "pod2")
+			sim.AddCluster(otherPod.Id, "cluster2")
+			zone := dummies.Zone1
+			zone.Pod = &infrav1.CloudStackResourceIdentifier{Name: "pod1"}
+			zone.Cluster = &infrav1.CloudStackResourceIdentifier{Name: "cluster1"}
+
+			Ω(client.ResolveZoneScope(ctx, &zone)).Should(Succeed())
+			Ω(zone.Pod.ID).Should(Equal(pod.Id))
+			Ω(zone.Cluster.ID).Should(Equal(cluster.Id))
+
+			// A cluster of another pod is out of scope.
+			zone.Cluster = &infrav1.CloudStackResourceIdentifier{Name: "cluster2"}
+			Ω(client.ResolveZoneScope(ctx, &zone)).Should(MatchError(ContainSubstring("expected 1 cluster")))
 		})
 	})
 })
@@ -0,0 +1,76 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ClientMetrics encapsulates the metrics reported by the rate limiting of CloudStack API requests.
+type ClientMetrics struct {
+	queueWait *prometheus.HistogramVec
+	inFlight  *prometheus.GaugeVec
+	throttled *prometheus.CounterVec
+}
+
+// clientLabels identify the CloudStack API endpoint requests are made to.
+var clientLabels = []string{"acs_endpoint"}
+
+// NewClientMetrics constructs a ClientMetrics and registers its metrics.
+func NewClientMetrics() ClientMetrics {
+	return ClientMetrics{
+		queueWait: register(prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "capc_acs_request_queue_wait_seconds",
+				Help:    "Time CloudStack API requests wait for the endpoint's rate and concurrency limits before they're made",
+				Buckets: prometheus.ExponentialBuckets(0.001, 4, 9),
+			},
+			clientLabels,
+		)).(*prometheus.HistogramVec),
+		inFlight: register(prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "capc_acs_requests_in_flight",
+				Help: "Number of CloudStack API requests awaiting their responses",
+			},
+			clientLabels,
+		)).(*prometheus.GaugeVec),
+		throttled: register(prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "capc_acs_requests_throttled_total",
+				Help: "Count of CloudStack API requests the endpoint refused for exceeding its API limit",
+			},
+			clientLabels,
+		)).(*prometheus.CounterVec),
+	}
+}
+
+// ObserveQueueWait records the time a request waited before it was made.
+func (m *ClientMetrics) ObserveQueueWait(endpoint string, wait time.Duration) {
+	m.queueWait.WithLabelValues(endpoint).Observe(wait.Seconds())
+}
+
+// AddInFlight records requests being made, or getting their responses when delta is negative.
+func (m *ClientMetrics) AddInFlight(endpoint string, delta float64) {
+	m.inFlight.WithLabelValues(endpoint).Add(delta)
+}
+
+// IncThrottled counts a request the endpoint throttled.
+func (m *ClientMetrics) IncThrottled(endpoint string) {
+	m.throttled.WithLabelValues(endpoint).Inc()
+}
@@ -26,9 +26,18 @@ import (
 // AcsCustomMetrics encapsulates all CloudStack custom metrics defined for the controller.
 type ACSCustomMetrics struct {
 	acsReconciliationErrorCount *prometheus.CounterVec
-	errorCodeRegexp             *regexp.Regexp
 }
 
+var (
+	// ACS standard error messages of the form "CloudStack API error 431 (CSExceptionErrorCode: 9999):..."
+	//  This regexp is used to extract error codes and CSExceptionCodes from the message.
+	errorCodeRegexp = regexp.MustCompile(`CloudStack API error ([0-9]+) \(CSExceptionErrorCode: ([0-9]+)\)`)
+
+	// Failed async jobs return their job result instead, as in {"cserrorcode":4250,"errorcode":533,...}.
+	jobErrorCodeRegexp   = regexp.MustCompile(`"errorcode"\s*:\s*([0-9]+)`)
+	jobCSErrorCodeRegexp = regexp.MustCompile(`"cserrorcode"\s*:\s*([0-9]+)`)
+)
+
 // NewCustomMetrics constructs an ACSCustomMetrics with all desired CloudStack custom metrics and any supporting resources.
 func NewCustomMetrics() ACSCustomMetrics {
 	customMetrics := ACSCustomMetrics{}
@@ -48,10 +57,6 @@ func NewCustomMetrics() ACSCustomMetrics {
 		}
 	}
 
-	// ACS standard error messages of the form "CloudStack API error 431 (CSExceptionErrorCode: 9999):..."
-	//  This regexp is used to extract CSExceptionCodes from the message.
-	customMetrics.errorCodeRegexp, _ = regexp.Compile(".+CSExceptionErrorCode: ([0-9]+).+")
-
 	return customMetrics
 }
 
@@ -59,11 +64,29 @@ func NewCustomMetrics() ACSCustomMetrics {
 // the custom acs_reconciliation_errors counter, labeled with the error code if present in the error message.
 func (m *ACSCustomMetrics) EvaluateErrorAndIncrementAcsReconciliationErrorCounter(acsError error) {
 	if acsError != nil {
-		matches := m.errorCodeRegexp.FindStringSubmatch(acsError.Error())
-		if len(matches) > 1 {
-			m.acsReconciliationErrorCount.WithLabelValues(matches[1]).Inc()
+		if _, csErrorCode := ErrorCodes(acsError); csErrorCode != "" {
+			m.acsReconciliationErrorCount.WithLabelValues(csErrorCode).Inc()
 		} else {
 			m.acsReconciliationErrorCount.WithLabelValues("No error code").Inc()
 		}
 	}
 }
+
+// ErrorCodes extracts the API error code, such as 431, and the CSExceptionErrorCode of a CloudStack error, whether
+// the request failed or its async job did. Either is empty if the error doesn't carry it.
+func ErrorCodes(acsError error) (errorCode, csErrorCode string) {
+	if acsError == nil {
+		return "", ""
+	}
+	msg := acsError.Error()
+	if matches := errorCodeRegexp.FindStringSubmatch(msg); matches != nil {
+		return matches[1], matches[2]
+	}
+	if matches := jobErrorCodeRegexp.FindStringSubmatch(msg); matches != nil {
+		errorCode = matches[1]
+	}
+	if matches := jobCSErrorCodeRegexp.FindStringSubmatch(msg); matches != nil {
+		csErrorCode = matches[1]
+	}
+	return errorCode, csErrorCode
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	crtlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// OrphanMetrics encapsulates the metrics reported by the orphaned CloudStack resource collector.
+type OrphanMetrics struct {
+	orphanedResources *prometheus.GaugeVec
+	unownedResources  *prometheus.GaugeVec
+	disposedResources *prometheus.CounterVec
+}
+
+// orphanLabels identify the CloudStack account scanned and the type of resource.
+var orphanLabels = []string{"acs_endpoint", "domain", "account", "resource_type"}
+
+// NewOrphanMetrics constructs an OrphanMetrics and registers its metrics.
+func NewOrphanMetrics() OrphanMetrics {
+	return OrphanMetrics{
+		orphanedResources: register(prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "capc_orphaned_resources",
+				Help: "Number of CloudStack resources created by CAPC that no CloudStackCluster uses anymore",
+			},
+			orphanLabels,
+		)).(*prometheus.GaugeVec),
+		unownedResources: register(prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "capc_unowned_resources",
+				Help: "Number of CloudStack resources created by CAPC for clusters of other management clusters",
+			},
+			orphanLabels,
+		)).(*prometheus.GaugeVec),
+		disposedResources: register(prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "capc_orphaned_resources_disposed_total",
+				Help: "Count of orphaned CloudStack resources disposed of",
+			},
+			orphanLabels,
+		)).(*prometheus.CounterVec),
+	}
+}
+
+// register registers a collector, returning the one already registered in its place if there is one.
+func register(collector prometheus.Collector) prometheus.Collector {
+	if err := crtlmetrics.Registry.Register(collector); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return are.ExistingCollector
+		}
+		// Something else went wrong!
+		panic(err)
+	}
+	return collector
+}
+
+// SetOrphanedResources records the number of orphaned resources of each resource type found in an account. Types
+// missing from counts are recorded as zero.
+func (m *OrphanMetrics) SetOrphanedResources(endpoint, domain, account string, resourceTypes []string, counts map[string]int) {
+	for _, resourceType := range resourceTypes {
+		m.orphanedResources.WithLabelValues(endpoint, domain, account, resourceType).Set(float64(counts[resourceType]))
+	}
+}
+
+// SetUnownedResources records the number of resources of other management clusters of each resource type found in an
+// account. Types missing from counts are recorded as zero.
+func (m *OrphanMetrics) SetUnownedResources(endpoint, domain, account string, resourceTypes []string, counts map[string]int) {
+	for _, resourceType := range resourceTypes {
+		m.unownedResources.WithLabelValues(endpoint, domain, account, resourceType).Set(float64(counts[resourceType]))
+	}
+}
+
+// IncDisposedResources counts an orphaned resource disposed of.
+func (m *OrphanMetrics) IncDisposedResources(endpoint, domain, account, resourceType string) {
+	m.disposedResources.WithLabelValues(endpoint, domain, account, resourceType).Inc()
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dummies
+
+import (
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/test/simulator"
+)
+
+var ( // Declare exported simulator dummy vars.
+	SimulatorConf      cloud.Config
+	SimulatorPublicIPs []string
+)
+
+// SetDummySimulatorVars seeds a CloudStack simulator with the zone, network, offerings, and template the dummy vars
+// reference, then points the dummy zone, network, failure domain, and endpoint secret at the simulated resources.
+// It is intended to be called after SetDummyVars().
+func SetDummySimulatorVars(sim *simulator.Simulator) {
+	SimulatorConf = cloud.Config{
+		APIUrl:    sim.APIUrl(),
+		APIKey:    simulator.AdminAPIKey,
+		SecretKey: simulator.AdminSecretKey,
+		VerifySSL: "false",
+	}
+	SimulatorPublicIPs = []string{"192.0.2.10", "192.0.2.11", "192.0.2.12"}
+
+	zone := sim.AddZone(Zone1.Name)
+	net := sim.AddNetwork(zone.Id, Net1.Name, Net1.Type, "10.10.0.0/24")
+	sim.AddTemplate(zone.Id, CSMachine1.Spec.Template.Name)
+	sim.AddServiceOffering(CSMachine1.Spec.Offering.Name, 2, 4096)
+	sim.AddServiceOffering(CSMachinePool1.Spec.Offering.Name, 2, 2048)
+	sim.AddDiskOffering(CSMachine1.Spec.DiskOffering.Name, false, 10)
+	sim.AddPublicIPAddresses(zone.Id, SimulatorPublicIPs...)
+
+	Net1.ID = net.Id
+	Zone1.ID = zone.Id
+	Zone1.Network = Net1
+	CSFailureDomain1.Spec.Zone = Zone1
+	CSCluster.Spec.FailureDomains[0] = CSFailureDomain1.Spec
+	CSMachine1.Spec.InstanceID = nil
+
+	// Resources CAPC creates itself start out unresolved.
+	ISONet1.ID = ""
+	CSISONet1.Spec.ID = ""
+	AffinityGroup.ID = ""
+	CSAffinityGroup.Spec.ID = ""
+
+	ACSEndpointSecret1.StringData = map[string]string{
+		"api-key":    SimulatorConf.APIKey,
+		"secret-key": SimulatorConf.SecretKey,
+		"api-url":    SimulatorConf.APIUrl,
+		"verify-ssl": SimulatorConf.VerifySSL,
+	}
+	// Fake controller runtime clients don't fold StringData into Data the way an API server does.
+	ACSEndpointSecret1.Data = map[string][]byte{}
+	for k, v := range ACSEndpointSecret1.StringData {
+		ACSEndpointSecret1.Data[k] = []byte(v)
+	}
+}
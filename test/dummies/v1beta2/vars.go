@@ -13,6 +13,7 @@ import (
 	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
 	"sigs.k8s.io/cluster-api-provider-cloudstack/test/fakes"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1beta1"
 )
 
 // GetYamlVal fetches the values in test/e2e/config/cloudstack.yaml by yaml node. A common config file.
@@ -39,6 +40,10 @@ var ( // Declare exported dummy vars.
 	Zone2                   infrav1.CloudStackZoneSpec
 	CSFailureDomain1        *infrav1.CloudStackFailureDomain
 	CSFailureDomain2        *infrav1.CloudStackFailureDomain
+	CSIPPool1               *infrav1.CloudStackIPPool
+	CSLoadBalancer1         *infrav1.CloudStackLoadBalancer
+	CSMachinePool1          *infrav1.CloudStackMachinePool
+	CAPIMachinePool         *expv1.MachinePool
 	Net1                    infrav1.Network
 	Net2                    infrav1.Network
 	ISONet1                 infrav1.Network
@@ -110,8 +115,11 @@ func SetDummyVars() {
 	SetDummyCAPIMachineVars()
 	SetDummyCSMachineTemplateVars()
 	SetDummyCSMachineVars()
+	SetDummyCSIPPoolVars()
+	SetDummyCSLoadBalancerVars()
 	SetDummyTagVars()
 	SetDummyBootstrapSecretVar()
+	SetDummyMachinePoolVars()
 	SetCSMachineOwner()
 	SetDummyOwnerReferences()
 	LBRuleID = "FakeLBRuleID"
@@ -252,6 +260,94 @@ func SetDummyCSMachineVars() {
 	}
 }
 
+// SetDummyCSIPPoolVars resets the CloudStackIPPool dummy variable.
+func SetDummyCSIPPoolVars() {
+	CSIPPool1 = &infrav1.CloudStackIPPool{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: CSApiVersion,
+			Kind:       "CloudStackIPPool",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-ip-pool-1",
+			Namespace: "default",
+		},
+		Spec: infrav1.CloudStackIPPoolSpec{
+			Addresses: []string{"10.10.0.20-10.10.0.22"},
+		},
+	}
+}
+
+// SetDummyCSLoadBalancerVars resets the CloudStackLoadBalancer dummy variable.
+func SetDummyCSLoadBalancerVars() {
+	CSLoadBalancer1 = &infrav1.CloudStackLoadBalancer{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: CSApiVersion,
+			Kind:       "CloudStackLoadBalancer",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-load-balancer-1",
+			Namespace: "default",
+			Labels:    ClusterLabel,
+		},
+		Spec: infrav1.CloudStackLoadBalancerSpec{
+			FailureDomainName: CSFailureDomain1.Spec.Name,
+			Rules: []infrav1.LoadBalancerPortMapping{
+				{Name: "http", PublicPort: 80, PrivatePort: 30080},
+				{Name: "https", PublicPort: 443, PrivatePort: 30443},
+			},
+		},
+	}
+}
+
+// SetDummyMachinePoolVars resets the CloudStackMachinePool and CAPI MachinePool dummy variables.
+func SetDummyMachinePoolVars() {
+	CAPIMachinePool = &expv1.MachinePool{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: expv1.GroupVersion.String(),
+			Kind:       "MachinePool",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "capi-test-machine-pool",
+			Namespace: "default",
+			Labels:    ClusterLabel,
+			UID:       "capi-test-machine-pool-uid",
+		},
+		Spec: expv1.MachinePoolSpec{
+			ClusterName: ClusterName,
+			Replicas:    pointer.Int32(2),
+			Template: clusterv1.MachineTemplateSpec{
+				Spec: clusterv1.MachineSpec{
+					ClusterName: ClusterName,
+					Bootstrap:   clusterv1.Bootstrap{DataSecretName: pointer.String(BootstrapSecret.Name)},
+				},
+			},
+		},
+	}
+	CSMachinePool1 = &infrav1.CloudStackMachinePool{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: CSApiVersion,
+			Kind:       "CloudStackMachinePool",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-machine-pool-1",
+			Namespace: "default",
+			Labels:    ClusterLabel,
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: expv1.GroupVersion.String(),
+				Kind:       "MachinePool",
+				Name:       CAPIMachinePool.Name,
+				UID:        CAPIMachinePool.UID,
+			}},
+		},
+		Spec: infrav1.CloudStackMachinePoolSpec{
+			CloudStackMachinePoolInstanceSpec: infrav1.CloudStackMachinePoolInstanceSpec{
+				Template: infrav1.CloudStackResourceIdentifier{Name: GetYamlVal("CLOUDSTACK_TEMPLATE_NAME")},
+				Offering: infrav1.CloudStackResourceIdentifier{Name: GetYamlVal("CLOUDSTACK_WORKER_MACHINE_OFFERING")},
+			},
+		},
+	}
+}
+
 func SetDummyZoneVars() {
 	Zone1 = infrav1.CloudStackZoneSpec{Network: Net1}
 	Zone1.Name = GetYamlVal("CLOUDSTACK_ZONE_NAME")
@@ -0,0 +1,971 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package simulator
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/apache/cloudstack-go/v2/cloudstack"
+)
+
+const (
+	AffinityGroupTypeAffinity     = "host affinity"
+	AffinityGroupTypeAntiAffinity = "host anti-affinity"
+
+	VolumeTypeRoot     = "ROOT"
+	VolumeTypeDataDisk = "DATADISK"
+
+	// The size given to root volumes, templates don't carry one in the simulator.
+	rootVolumeSize = int64(8) << 30
+)
+
+func init() {
+	registerCommand("listZones", false, (*Simulator).listZones)
+	registerCommand("listCapacity", false, (*Simulator).listCapacity)
+	registerCommand("listPods", false, (*Simulator).listPods)
+	registerCommand("listClusters", false, (*Simulator).listClusters)
+	registerCommand("listHosts", false, (*Simulator).listHosts)
+	registerCommand("listServiceOfferings", false, (*Simulator).listServiceOfferings)
+	registerCommand("listDiskOfferings", false, (*Simulator).listDiskOfferings)
+	registerCommand("listTemplates", false, (*Simulator).listTemplates)
+	registerCommand("listVirtualMachines", false, (*Simulator).listVirtualMachines)
+	registerCommand("listVirtualMachinesMetrics", false, (*Simulator).listVirtualMachinesMetrics)
+	registerCommand("deployVirtualMachine", true, (*Simulator).deployVirtualMachine)
+	registerCommand("startVirtualMachine", true, (*Simulator).startVirtualMachine)
+	registerCommand("stopVirtualMachine", true, (*Simulator).stopVirtualMachine)
+	registerCommand("scaleVirtualMachine", true, (*Simulator).scaleVirtualMachine)
+	registerCommand("destroyVirtualMachine", true, (*Simulator).destroyVirtualMachine)
+	registerCommand("listVolumes", false, (*Simulator).listVolumes)
+	registerCommand("detachVolume", true, (*Simulator).detachVolume)
+	registerCommand("listSnapshots", false, (*Simulator).listSnapshots)
+	registerCommand("createSnapshot", true, (*Simulator).createSnapshot)
+	registerCommand("listAffinityGroups", false, (*Simulator).listAffinityGroups)
+	registerCommand("createAffinityGroup", true, (*Simulator).createAffinityGroup)
+	registerCommand("deleteAffinityGroup", true, (*Simulator).deleteAffinityGroup)
+	registerCommand("updateVMAffinityGroup", true, (*Simulator).updateVMAffinityGroup)
+	registerCommand("listInstanceGroups", false, (*Simulator).listInstanceGroups)
+	registerCommand("createInstanceGroup", false, (*Simulator).createInstanceGroup)
+	registerCommand("deleteInstanceGroup", false, (*Simulator).deleteInstanceGroup)
+}
+
+// notFound is the error CloudStack returns when a UUID parameter doesn't resolve to an entity.
+func notFound(param, id string) *APIError {
+	return paramError("Invalid parameter %s value=%s due to incorrect long value format, "+
+		"or entity does not exist or due to incorrect parameter annotation for the field in api cmd class.", param, id)
+}
+
+func (s *Simulator) listZones(p url.Values) (interface{}, error) {
+	ret := []*cloudstack.Zone{}
+	for _, zone := range s.zones {
+		if matches(p, "id", zone.Id) && matchesName(p, zone.Name) {
+			ret = append(ret, zone)
+		}
+	}
+	return listResponse("zone", ret, len(ret)), nil
+}
+
+// Types of capacity, as listCapacity reports them.
+const (
+	capacityTypeMemory = 0
+	capacityTypeCPU    = 1
+)
+
+func (s *Simulator) listCapacity(p url.Values) (interface{}, error) {
+	ret := []*cloudstack.Capacity{}
+	for _, capacity := range s.capacities {
+		if matches(p, "zoneid", capacity.Zoneid) && matches(p, "podid", capacity.Podid) &&
+			matches(p, "clusterid", capacity.Clusterid) && matches(p, "type", strconv.Itoa(capacity.Type)) {
+			ret = append(ret, capacity)
+		}
+	}
+	return listResponse("capacity", ret, len(ret)), nil
+}
+
+func (s *Simulator) listPods(p url.Values) (interface{}, error) {
+	ret := []*cloudstack.Pod{}
+	for _, pod := range s.pods {
+		if matches(p, "id", pod.Id) && matchesName(p, pod.Name) && matches(p, "zoneid", pod.Zoneid) {
+			ret = append(ret, pod)
+		}
+	}
+	return listResponse("pod", ret, len(ret)), nil
+}
+
+func (s *Simulator) listClusters(p url.Values) (interface{}, error) {
+	ret := []*cloudstack.Cluster{}
+	for _, cluster := range s.clusters {
+		if matches(p, "id", cluster.Id) && matchesName(p, cluster.Name) && matches(p, "zoneid", cluster.Zoneid) &&
+			matches(p, "podid", cluster.Podid) {
+			ret = append(ret, cluster)
+		}
+	}
+	return listResponse("cluster", ret, len(ret)), nil
+}
+
+func (s *Simulator) listHosts(p url.Values) (interface{}, error) {
+	ret := []*cloudstack.Host{}
+	for _, host := range s.hosts {
+		if matches(p, "id", host.Id) && matchesName(p, host.Name) && matches(p, "zoneid", host.Zoneid) &&
+			matches(p, "podid", host.Podid) && matches(p, "clusterid", host.Clusterid) && matches(p, "type", host.Type) &&
+			matches(p, "state", host.State) && matches(p, "resourcestate", host.Resourcestate) {
+			ret = append(ret, host)
+		}
+	}
+	return listResponse("host", ret, len(ret)), nil
+}
+
+func (s *Simulator) listServiceOfferings(p url.Values) (interface{}, error) {
+	ret := []*cloudstack.ServiceOffering{}
+	for _, offering := range s.serviceOfferings {
+		if matches(p, "id", offering.Id) && matchesName(p, offering.Name) {
+			ret = append(ret, offering)
+		}
+	}
+	return listResponse("serviceoffering", ret, len(ret)), nil
+}
+
+func (s *Simulator) listDiskOfferings(p url.Values) (interface{}, error) {
+	ret := []*cloudstack.DiskOffering{}
+	for _, offering := range s.diskOfferings {
+		if matches(p, "id", offering.Id) && matchesName(p, offering.Name) {
+			ret = append(ret, offering)
+		}
+	}
+	return listResponse("diskoffering", ret, len(ret)), nil
+}
+
+func (s *Simulator) listTemplates(p url.Values) (interface{}, error) {
+	if p.Get("templatefilter") == "" {
+		return nil, paramError("Unable to execute API command listtemplates due to missing parameter templatefilter")
+	}
+	ret := []*cloudstack.Template{}
+	for _, template := range s.templates {
+		if matches(p, "id", template.Id) && matchesName(p, template.Name) && matches(p, "zoneid", template.Zoneid) {
+			ret = append(ret, template)
+		}
+	}
+	return listResponse("template", ret, len(ret)), nil
+}
+
+// filterVirtualMachines returns the VMs matching the filters of the list VM commands.
+func (s *Simulator) filterVirtualMachines(p url.Values) []*cloudstack.VirtualMachine {
+	ret := []*cloudstack.VirtualMachine{}
+	for _, vm := range s.virtualMachines {
+		if !matches(p, "id", vm.Id) || !matchesName(p, vm.Name) || !matches(p, "zoneid", vm.Zoneid) ||
+			!matches(p, "templateid", vm.Templateid) || !matches(p, "state", vm.State) ||
+			!matches(p, "groupid", vm.Groupid) {
+			continue
+		}
+		if networkID := p.Get("networkid"); networkID != "" && findNic(vm, networkID) == nil {
+			continue
+		}
+		vm.Tags = s.resourceTags("UserVm", vm.Id)
+		ret = append(ret, vm)
+	}
+	return ret
+}
+
+func (s *Simulator) listVirtualMachines(p url.Values) (interface{}, error) {
+	ret := s.filterVirtualMachines(p)
+	return listResponse("virtualmachine", ret, len(ret)), nil
+}
+
+func (s *Simulator) listVirtualMachinesMetrics(p url.Values) (interface{}, error) {
+	vms := s.filterVirtualMachines(p)
+	ret := make([]map[string]interface{}, 0, len(vms))
+	for _, vm := range vms {
+		metric, err := toMap(vm)
+		if err != nil {
+			return nil, err
+		}
+		for _, nic := range vm.Nic {
+			if nic.Isdefault {
+				metric["ipaddress"] = nic.Ipaddress
+			}
+		}
+		metric["cputotal"] = strconv.Itoa(vm.Cpunumber*vm.Cpuspeed) + " Ghz"
+		metric["memorytotal"] = strconv.Itoa(vm.Memory) + " MiB"
+		ret = append(ret, metric)
+	}
+	return listResponse("virtualmachine", ret, len(ret)), nil
+}
+
+// toMap converts a CloudStack entity to its generic JSON representation, so fields can be added to it.
+func toMap(entity interface{}) (map[string]interface{}, error) {
+	b, err := json.Marshal(entity)
+	if err != nil {
+		return nil, err
+	}
+	ret := map[string]interface{}{}
+	return ret, json.Unmarshal(b, &ret)
+}
+
+func findNic(vm *cloudstack.VirtualMachine, networkID string) *cloudstack.Nic {
+	for idx := range vm.Nic {
+		if vm.Nic[idx].Networkid == networkID {
+			return &vm.Nic[idx]
+		}
+	}
+	return nil
+}
+
+func (s *Simulator) findVirtualMachine(id string) *cloudstack.VirtualMachine {
+	for _, vm := range s.virtualMachines {
+		if vm.Id == id {
+			return vm
+		}
+	}
+	return nil
+}
+
+// findInstanceGroup finds an instance group by ID, or by name among the caller's account's groups.
+func (s *Simulator) findInstanceGroup(id, name string) *cloudstack.InstanceGroup {
+	for _, group := range s.instanceGroups {
+		if (id != "" && group.Id == id) || (id == "" && name != "" && group.Name == name &&
+			group.Account == s.caller.Account && group.Domainid == s.caller.Domainid) {
+			return group
+		}
+	}
+	return nil
+}
+
+func (s *Simulator) newInstanceGroup(name string) *cloudstack.InstanceGroup {
+	group := &cloudstack.InstanceGroup{Id: s.newID(), Name: name, Account: s.caller.Account,
+		Domain: s.caller.Domain, Domainid: s.caller.Domainid, Created: now()}
+	s.instanceGroups = append(s.instanceGroups, group)
+	return group
+}
+
+func (s *Simulator) findServiceOffering(id string) *cloudstack.ServiceOffering {
+	for _, offering := range s.serviceOfferings {
+		if offering.Id == id {
+			return offering
+		}
+	}
+	return nil
+}
+
+func (s *Simulator) findAffinityGroup(id, name string) *cloudstack.AffinityGroup {
+	for _, group := range s.affinityGroups {
+		if (id != "" && group.Id == id) || (id == "" && name != "" && group.Name == name &&
+			group.Account == s.caller.Account && group.Domainid == s.caller.Domainid) {
+			return group
+		}
+	}
+	return nil
+}
+
+func (s *Simulator) deployVirtualMachine(p url.Values) (interface{}, error) {
+	var zone *cloudstack.Zone
+	for _, z := range s.zones {
+		if z.Id == p.Get("zoneid") {
+			zone = z
+		}
+	}
+	if zone == nil {
+		return nil, notFound("zoneid", p.Get("zoneid"))
+	}
+	offering := s.findServiceOffering(p.Get("serviceofferingid"))
+	if offering == nil {
+		return nil, notFound("serviceofferingid", p.Get("serviceofferingid"))
+	}
+	var template *cloudstack.Template
+	for _, t := range s.templates {
+		if t.Id == p.Get("templateid") {
+			template = t
+		}
+	}
+	if template == nil {
+		return nil, notFound("templateid", p.Get("templateid"))
+	} else if template.Zoneid != zone.Id {
+		return nil, paramError("Template %s is not available in zone %s", template.Id, zone.Id)
+	}
+
+	if id := p.Get("podid"); id != "" && s.findPod(id, zone.Id) == nil {
+		return nil, notFound("podid", id)
+	}
+	if id := p.Get("clusterid"); id != "" && s.findCluster(id, zone.Id) == nil {
+		return nil, notFound("clusterid", id)
+	}
+	if id := p.Get("hostid"); id != "" && s.findHost(id, zone.Id) == nil {
+		return nil, notFound("hostid", id)
+	}
+
+	var diskOffering *cloudstack.DiskOffering
+	if id := p.Get("diskofferingid"); id != "" {
+		for _, o := range s.diskOfferings {
+			if o.Id == id {
+				diskOffering = o
+			}
+		}
+		if diskOffering == nil {
+			return nil, notFound("diskofferingid", id)
+		} else if diskOffering.Iscustomized && p.Get("size") == "" {
+			return nil, paramError("This disk offering requires a custom size specified")
+		}
+	}
+
+	networkIDs := listParam(p, "networkids")
+	requestedIPs := map[string]string{}
+	ipToNetworkList := mapParam(p, "iptonetworklist")
+	if len(ipToNetworkList) > 0 && (len(networkIDs) > 0 || p.Get("ipaddress") != "") {
+		return nil, paramError("NetworkIds and ipAddress can't be specified along with ipToNetworkMap parameter")
+	}
+	for _, m := range ipToNetworkList {
+		if m["networkid"] == "" {
+			continue
+		}
+		networkIDs = append(networkIDs, m["networkid"])
+		requestedIPs[m["networkid"]] = m["ip"]
+	}
+	if len(networkIDs) == 0 {
+		return nil, paramError("Can't deploy a VM in an advanced zone without specifying networks")
+	}
+	if ip := p.Get("ipaddress"); ip != "" {
+		requestedIPs[networkIDs[0]] = ip
+	}
+
+	name := p.Get("name")
+	vmID := s.newID()
+	if name == "" {
+		name = "VM-" + vmID
+	}
+
+	networks := make([]*cloudstack.Network, 0, len(networkIDs))
+	for _, networkID := range networkIDs {
+		net := s.findNetwork(networkID)
+		if net == nil {
+			return nil, notFound("networkids", networkID)
+		} else if net.Zoneid != zone.Id {
+			return nil, paramError("Network %s doesn't belong to zone %s", net.Id, zone.Id)
+		}
+		for _, vm := range s.virtualMachines {
+			if vm.Name == name && findNic(vm, net.Id) != nil {
+				return nil, paramError("The vm with hostName %s already exists in the network domain: %s; network=%s",
+					name, net.Networkdomain, net.Name)
+			}
+		}
+		networks = append(networks, net)
+	}
+
+	// CloudStack creates the instance group a VM is deployed into if the caller has none of its name.
+	var instanceGroup *cloudstack.InstanceGroup
+	if name := p.Get("group"); name != "" {
+		if instanceGroup = s.findInstanceGroup("", name); instanceGroup == nil {
+			instanceGroup = s.newInstanceGroup(name)
+		}
+	}
+
+	var groups []*cloudstack.AffinityGroup
+	for _, groupID := range listParam(p, "affinitygroupids") {
+		group := s.findAffinityGroup(groupID, "")
+		if group == nil {
+			return nil, notFound("affinitygroupids", groupID)
+		}
+		groups = append(groups, group)
+	}
+
+	vm := &cloudstack.VirtualMachine{
+		Id:                    vmID,
+		Name:                  name,
+		Displayname:           p.Get("displayname"),
+		Instancename:          "i-" + vmID,
+		State:                 "Running",
+		Zoneid:                zone.Id,
+		Zonename:              zone.Name,
+		Templateid:            template.Id,
+		Templatename:          template.Name,
+		Templatedisplaytext:   template.Displaytext,
+		Serviceofferingid:     offering.Id,
+		Serviceofferingname:   offering.Name,
+		Cpunumber:             offering.Cpunumber,
+		Cpuspeed:              offering.Cpuspeed,
+		Memory:                offering.Memory,
+		Isdynamicallyscalable: offering.Dynamicscalingenabled,
+		Keypair:               p.Get("keypair"),
+		Hypervisor:            template.Hypervisor,
+		Account:               s.caller.Account,
+		Domain:                s.caller.Domain,
+		Domainid:              s.caller.Domainid,
+		Userid:                s.caller.Id,
+		Username:              s.caller.Username,
+		Created:               now(),
+	}
+	if vm.Displayname == "" {
+		vm.Displayname = name
+	}
+	if details := mapParam(p, "details"); len(details) > 0 {
+		vm.Details = map[string]string{}
+		for _, detail := range details {
+			for k, v := range detail {
+				vm.Details[k] = v
+			}
+		}
+	}
+	if err := applyComputeDetails(vm, offering, vm.Details); err != nil {
+		return nil, err
+	}
+
+	// CloudStack checks the caller's account limits before allocating anything for the VM.
+	account := s.callerAccount()
+	if available, err := strconv.Atoi(account.Vmavailable); err == nil && available <= 0 {
+		return nil, NewAPIError(ErrorCodeResourceAllocation, "Maximum number of resources of type 'user_vm' for "+
+			"account name=%s in domain id=%s has been exceeded.", account.Name, account.Domainid)
+	}
+
+	// Allocate all addresses before creating anything, so a failed allocation leaves no trace.
+	nics := make([]cloudstack.Nic, 0, len(networks))
+	for idx, net := range networks {
+		ip, err := s.allocateGuestIP(net, requestedIPs[net.Id])
+		if err != nil {
+			for _, nic := range nics {
+				s.releaseGuestIP(nic.Networkid, nic.Ipaddress)
+			}
+			return nil, err
+		}
+		nics = append(nics, cloudstack.Nic{
+			Id:               s.newID(),
+			Networkid:        net.Id,
+			Networkname:      net.Name,
+			Ipaddress:        ip,
+			Gateway:          net.Gateway,
+			Netmask:          net.Netmask,
+			Ip6address:       s.allocateGuestIPv6(net),
+			Ip6cidr:          net.Ip6cidr,
+			Ip6gateway:       net.Ip6gateway,
+			Isdefault:        idx == 0,
+			Traffictype:      "Guest",
+			Type:             net.Type,
+			Virtualmachineid: vmID,
+			Deviceid:         strconv.Itoa(idx),
+			Macaddress:       macAddress(s.nextID),
+		})
+	}
+	vm.Nic = nics
+
+	for _, group := range groups {
+		group.VirtualmachineIds = append(group.VirtualmachineIds, vmID)
+		vm.Affinitygroup = append(vm.Affinitygroup, cloudstack.VirtualMachineAffinitygroup{
+			Id: group.Id, Name: group.Name, Type: group.Type, Account: group.Account, Domainid: group.Domainid})
+	}
+	if instanceGroup != nil {
+		vm.Group, vm.Groupid = instanceGroup.Name, instanceGroup.Id
+	}
+	if diskOffering != nil {
+		vm.Diskofferingid = diskOffering.Id
+		vm.Diskofferingname = diskOffering.Name
+	}
+
+	// CloudStack records a VM it finds no room for in the Error state, holding its NICs until it's expunged, before
+	// failing its deployment.
+	host, scoped := s.pickHost(zone.Id, p, vm)
+	if !s.zoneFits(zone.Id, vm) || (scoped && host == nil) {
+		vm.State = "Error"
+		s.virtualMachines = append(s.virtualMachines, vm)
+		return nil, &jobFailure{NewAPIError(ErrorCodeInsufficientCapacity,
+			"Unable to create a deployment for VM[User|%s]", vm.Instancename)}
+	}
+	if host != nil {
+		vm.Hostid, vm.Hostname = host.Id, host.Name
+		host.Memoryallocated += int64(vm.Memory) << 20
+	}
+	s.virtualMachines = append(s.virtualMachines, vm)
+	s.userData[vmID] = p.Get("userdata")
+	s.newVolume(vm, VolumeTypeRoot, "ROOT-"+vmID, nil, rootVolumeSize)
+	if diskOffering != nil {
+		size := diskOffering.Disksize << 30
+		if customSize, err := strconv.ParseInt(p.Get("size"), 10, 64); err == nil && customSize > 0 {
+			size = customSize << 30
+		}
+		s.newVolume(vm, VolumeTypeDataDisk, "DATA-"+vmID, diskOffering, size)
+	}
+	return map[string]interface{}{"virtualmachine": vm}, nil
+}
+
+// callerAccount returns the account of the user calling the API.
+func (s *Simulator) callerAccount() *cloudstack.Account {
+	for _, account := range s.accounts {
+		if account.Id == s.caller.Accountid {
+			return account
+		}
+	}
+	panic("simulator: no account with ID " + s.caller.Accountid)
+}
+
+// zoneFits reports whether a zone has the memory and CPU left that a VM needs. Capacity that isn't set is unlimited.
+func (s *Simulator) zoneFits(zoneID string, vm *cloudstack.VirtualMachine) bool {
+	for _, capacity := range s.capacities {
+		if capacity.Zoneid != zoneID {
+			continue
+		}
+		var needed int64
+		switch capacity.Type {
+		case capacityTypeMemory:
+			needed = int64(vm.Memory) << 20
+		case capacityTypeCPU:
+			needed = int64(vm.Cpunumber) * int64(vm.Cpuspeed)
+		}
+		if capacity.Capacityused+needed > capacity.Capacitytotal {
+			return false
+		}
+	}
+	return true
+}
+
+// pickHost returns the host a VM deploys on: the requested one, or else the first enabled host of the zone in the
+// requested pod and cluster with the memory left for it. The zone's placement is only simulated once it has hosts;
+// until then no host is picked, and scoped is false.
+func (s *Simulator) pickHost(zoneID string, p url.Values, vm *cloudstack.VirtualMachine) (host *cloudstack.Host, scoped bool) {
+	for _, h := range s.hosts {
+		if h.Zoneid != zoneID {
+			continue
+		}
+		scoped = true
+		if matches(p, "hostid", h.Id) && matches(p, "podid", h.Podid) && matches(p, "clusterid", h.Clusterid) &&
+			h.Resourcestate == "Enabled" && h.State == "Up" && h.Memoryallocated+int64(vm.Memory)<<20 <= h.Memorytotal {
+			return h, true
+		}
+	}
+	return nil, scoped
+}
+
+func (s *Simulator) findPod(id, zoneID string) *cloudstack.Pod {
+	for _, pod := range s.pods {
+		if pod.Id == id && pod.Zoneid == zoneID {
+			return pod
+		}
+	}
+	return nil
+}
+
+func (s *Simulator) findCluster(id, zoneID string) *cloudstack.Cluster {
+	for _, cluster := range s.clusters {
+		if cluster.Id == id && cluster.Zoneid == zoneID {
+			return cluster
+		}
+	}
+	return nil
+}
+
+func (s *Simulator) findHost(id, zoneID string) *cloudstack.Host {
+	for _, host := range s.hosts {
+		if host.Id == id && host.Zoneid == zoneID {
+			return host
+		}
+	}
+	return nil
+}
+
+func macAddress(seed int) string {
+	return "02:00:" + strings.Join([]string{
+		hexByte(seed >> 24), hexByte(seed >> 16), hexByte(seed >> 8), hexByte(seed)}, ":")
+}
+
+func hexByte(b int) string {
+	const digits = "0123456789abcdef"
+	return string([]byte{digits[(b>>4)&0xf], digits[b&0xf]})
+}
+
+func (s *Simulator) newVolume(
+	vm *cloudstack.VirtualMachine, volumeType, name string, offering *cloudstack.DiskOffering, size int64,
+) *cloudstack.Volume {
+	vol := &cloudstack.Volume{
+		Id:               s.newID(),
+		Name:             name,
+		Type:             volumeType,
+		State:            "Ready",
+		Size:             size,
+		Zoneid:           vm.Zoneid,
+		Zonename:         vm.Zonename,
+		Virtualmachineid: vm.Id,
+		Vmname:           vm.Name,
+		Vmdisplayname:    vm.Displayname,
+		Vmstate:          vm.State,
+		Account:          vm.Account,
+		Domainid:         vm.Domainid,
+		Attached:         now(),
+		Created:          now(),
+	}
+	if volumeType == VolumeTypeRoot {
+		vol.Templateid = vm.Templateid
+		vol.Templatename = vm.Templatename
+	}
+	if offering != nil {
+		vol.Diskofferingid = offering.Id
+		vol.Diskofferingname = offering.Name
+	}
+	s.volumes = append(s.volumes, vol)
+	return vol
+}
+
+func (s *Simulator) startVirtualMachine(p url.Values) (interface{}, error) {
+	vm := s.findVirtualMachine(p.Get("id"))
+	if vm == nil || vm.State == "Destroyed" {
+		return nil, notFound("id", p.Get("id"))
+	}
+	vm.State = "Running"
+	return map[string]interface{}{"virtualmachine": vm}, nil
+}
+
+func (s *Simulator) stopVirtualMachine(p url.Values) (interface{}, error) {
+	vm := s.findVirtualMachine(p.Get("id"))
+	if vm == nil || vm.State == "Destroyed" {
+		return nil, notFound("id", p.Get("id"))
+	}
+	vm.State = "Stopped"
+	return map[string]interface{}{"virtualmachine": vm}, nil
+}
+
+// scaleVirtualMachine moves a VM to another compute offering, or changes the CPU and memory of one on a custom
+// offering. Running VMs can only be scaled when both they and the offering allow dynamic scaling.
+func (s *Simulator) scaleVirtualMachine(p url.Values) (interface{}, error) {
+	vm := s.findVirtualMachine(p.Get("id"))
+	if vm == nil || vm.State == "Destroyed" {
+		return nil, notFound("id", p.Get("id"))
+	}
+	offering := s.findServiceOffering(p.Get("serviceofferingid"))
+	if offering == nil {
+		return nil, notFound("serviceofferingid", p.Get("serviceofferingid"))
+	}
+	if vm.State == "Running" && (!vm.Isdynamicallyscalable || !offering.Dynamicscalingenabled) {
+		return nil, paramError("Unable to scale the running vm %s, dynamic scaling is disabled", vm.Name)
+	}
+	details := map[string]string{}
+	for _, detail := range mapParam(p, "details") {
+		for k, v := range detail {
+			details[k] = v
+		}
+	}
+	scaled := *vm
+	if err := applyComputeDetails(&scaled, offering, details); err != nil {
+		return nil, err
+	}
+	vm.Serviceofferingid, vm.Serviceofferingname = offering.Id, offering.Name
+	vm.Cpunumber, vm.Cpuspeed, vm.Memory = scaled.Cpunumber, scaled.Cpuspeed, scaled.Memory
+	for k, v := range details {
+		if vm.Details == nil {
+			vm.Details = map[string]string{}
+		}
+		vm.Details[k] = v
+	}
+	return map[string]interface{}{"virtualmachine": vm}, nil
+}
+
+// applyComputeDetails sizes a VM by its compute offering, taking the CPU and memory of custom offerings from the
+// cpuNumber, cpuSpeed and memory details.
+func applyComputeDetails(vm *cloudstack.VirtualMachine, offering *cloudstack.ServiceOffering, details map[string]string) error {
+	vm.Cpunumber, vm.Cpuspeed, vm.Memory = offering.Cpunumber, offering.Cpuspeed, offering.Memory
+	if !offering.Iscustomized {
+		return nil
+	}
+	for detail, value := range map[string]*int{"cpuNumber": &vm.Cpunumber, "cpuSpeed": &vm.Cpuspeed, "memory": &vm.Memory} {
+		if details[detail] == "" && *value > 0 {
+			continue
+		}
+		parsed, err := strconv.Atoi(details[detail])
+		if err != nil || parsed <= 0 {
+			return paramError("Invalid %s %q for the custom compute offering %s", detail, details[detail], offering.Name)
+		}
+		*value = parsed
+	}
+	return nil
+}
+
+func (s *Simulator) destroyVirtualMachine(p url.Values) (interface{}, error) {
+	vm := s.findVirtualMachine(p.Get("id"))
+	if vm == nil {
+		return nil, paramError("Unable to find uuid for id %s", p.Get("id"))
+	}
+	expunge, _ := strconv.ParseBool(p.Get("expunge"))
+	if vm.State == "Destroyed" && !expunge {
+		return nil, paramError("Unable to find uuid for id %s", p.Get("id"))
+	}
+
+	// Volumes passed along are destroyed with the VM. Other data disks are detached and left behind.
+	deleteVolumes := map[string]bool{}
+	for _, volumeID := range listParam(p, "volumeids") {
+		deleteVolumes[volumeID] = true
+	}
+	volumes := s.volumes[:0]
+	for _, vol := range s.volumes {
+		if vol.Virtualmachineid == vm.Id {
+			if vol.Type == VolumeTypeRoot && expunge || deleteVolumes[vol.Id] {
+				s.deleteResourceTags(vol.Id)
+				continue
+			} else if vol.Type == VolumeTypeDataDisk {
+				vol.Virtualmachineid, vol.Vmname, vol.Vmdisplayname, vol.Vmstate, vol.Attached = "", "", "", "", ""
+			}
+		}
+		volumes = append(volumes, vol)
+	}
+	s.volumes = volumes
+
+	vm.State = "Destroyed"
+	if !expunge {
+		return map[string]interface{}{"virtualmachine": vm}, nil
+	}
+
+	// Expunging releases everything the VM held.
+	for _, nic := range vm.Nic {
+		s.releaseGuestIP(nic.Networkid, nic.Ipaddress)
+	}
+	if host := s.findHost(vm.Hostid, vm.Zoneid); host != nil {
+		host.Memoryallocated -= int64(vm.Memory) << 20
+	}
+	for _, group := range s.affinityGroups {
+		group.VirtualmachineIds = removeString(group.VirtualmachineIds, vm.Id)
+	}
+	for ruleID, members := range s.lbRuleMembers {
+		s.lbRuleMembers[ruleID] = removeString(members, vm.Id)
+	}
+	s.deleteResourceTags(vm.Id)
+	delete(s.userData, vm.Id)
+	vms := s.virtualMachines[:0]
+	for _, v := range s.virtualMachines {
+		if v.Id != vm.Id {
+			vms = append(vms, v)
+		}
+	}
+	s.virtualMachines = vms
+	vm.State = "Expunging"
+	return map[string]interface{}{"virtualmachine": vm}, nil
+}
+
+func removeString(list []string, remove string) []string {
+	ret := []string{}
+	for _, item := range list {
+		if item != remove {
+			ret = append(ret, item)
+		}
+	}
+	return ret
+}
+
+func (s *Simulator) listVolumes(p url.Values) (interface{}, error) {
+	ret := []*cloudstack.Volume{}
+	for _, vol := range s.volumes {
+		if matches(p, "id", vol.Id) && matchesName(p, vol.Name) && matches(p, "virtualmachineid", vol.Virtualmachineid) &&
+			matches(p, "type", vol.Type) && matches(p, "zoneid", vol.Zoneid) {
+			vol.Tags = s.resourceTags("Volume", vol.Id)
+			ret = append(ret, vol)
+		}
+	}
+	return listResponse("volume", ret, len(ret)), nil
+}
+
+func (s *Simulator) findVolume(id string) *cloudstack.Volume {
+	for _, vol := range s.volumes {
+		if vol.Id == id {
+			return vol
+		}
+	}
+	return nil
+}
+
+func (s *Simulator) detachVolume(p url.Values) (interface{}, error) {
+	vol := s.findVolume(p.Get("id"))
+	if vol == nil {
+		return nil, notFound("id", p.Get("id"))
+	} else if vol.Virtualmachineid == "" {
+		return nil, paramError("Please specify a volume that is attached to a VM.")
+	} else if vol.Type == VolumeTypeRoot {
+		return nil, paramError("Can't detach ROOT volume %s", vol.Id)
+	}
+	vol.Virtualmachineid, vol.Vmname, vol.Vmdisplayname, vol.Vmstate, vol.Attached = "", "", "", "", ""
+	return map[string]interface{}{"volume": vol}, nil
+}
+
+func (s *Simulator) listSnapshots(p url.Values) (interface{}, error) {
+	ret := []*cloudstack.Snapshot{}
+	for _, snapshot := range s.snapshots {
+		if matches(p, "id", snapshot.Id) && matches(p, "volumeid", snapshot.Volumeid) {
+			ret = append(ret, snapshot)
+		}
+	}
+	return listResponse("snapshot", ret, len(ret)), nil
+}
+
+func (s *Simulator) createSnapshot(p url.Values) (interface{}, error) {
+	vol := s.findVolume(p.Get("volumeid"))
+	if vol == nil {
+		return nil, notFound("volumeid", p.Get("volumeid"))
+	}
+	name := p.Get("name")
+	if name == "" {
+		name = vol.Name + "_" + strconv.Itoa(s.nextID)
+	}
+	snapshot := &cloudstack.Snapshot{
+		Id:           s.newID(),
+		Name:         name,
+		State:        "BackedUp",
+		Snapshottype: "MANUAL",
+		Volumeid:     vol.Id,
+		Volumename:   vol.Name,
+		Volumetype:   vol.Type,
+		Virtualsize:  vol.Size,
+		Zoneid:       vol.Zoneid,
+	}
+	s.snapshots = append(s.snapshots, snapshot)
+	return map[string]interface{}{"snapshot": snapshot}, nil
+}
+
+func (s *Simulator) listAffinityGroups(p url.Values) (interface{}, error) {
+	ret := []*cloudstack.AffinityGroup{}
+	for _, group := range s.affinityGroups {
+		if !matches(p, "id", group.Id) || !matchesName(p, group.Name) || !matches(p, "type", group.Type) {
+			continue
+		}
+		if vmID := p.Get("virtualmachineid"); vmID != "" && !containsString(group.VirtualmachineIds, vmID) {
+			continue
+		}
+		ret = append(ret, group)
+	}
+	return listResponse("affinitygroup", ret, len(ret)), nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Simulator) createAffinityGroup(p url.Values) (interface{}, error) {
+	name, groupType := p.Get("name"), p.Get("type")
+	if groupType != AffinityGroupTypeAffinity && groupType != AffinityGroupTypeAntiAffinity {
+		return nil, paramError("Unable to create affinity group, invalid affinity group type %s", groupType)
+	}
+	if s.findAffinityGroup("", name) != nil {
+		return nil, paramError("Unable to create affinity group, a group with name %s already exists.", name)
+	}
+	group := &cloudstack.AffinityGroup{Id: s.newID(), Name: name, Type: groupType, Description: p.Get("description"),
+		Account: s.caller.Account, Domain: s.caller.Domain, Domainid: s.caller.Domainid, VirtualmachineIds: []string{}}
+	s.affinityGroups = append(s.affinityGroups, group)
+	return map[string]interface{}{"affinitygroup": group}, nil
+}
+
+func (s *Simulator) deleteAffinityGroup(p url.Values) (interface{}, error) {
+	group := s.findAffinityGroup(p.Get("id"), p.Get("name"))
+	if group == nil {
+		if p.Get("id") != "" {
+			return nil, notFound("id", p.Get("id"))
+		}
+		return nil, paramError("Unable to find affinity group by name %s", p.Get("name"))
+	}
+	groups := s.affinityGroups[:0]
+	for _, g := range s.affinityGroups {
+		if g.Id != group.Id {
+			groups = append(groups, g)
+		}
+	}
+	s.affinityGroups = groups
+	for _, vm := range s.virtualMachines {
+		remaining := vm.Affinitygroup[:0]
+		for _, g := range vm.Affinitygroup {
+			if g.Id != group.Id {
+				remaining = append(remaining, g)
+			}
+		}
+		vm.Affinitygroup = remaining
+	}
+	s.deleteResourceTags(group.Id)
+	return successResponse(), nil
+}
+
+func (s *Simulator) updateVMAffinityGroup(p url.Values) (interface{}, error) {
+	vm := s.findVirtualMachine(p.Get("id"))
+	if vm == nil {
+		return nil, notFound("id", p.Get("id"))
+	} else if vm.State != "Stopped" {
+		return nil, paramError("Unable to update affinity groups of the virtual machine %s in state %s, "+
+			"the vm must be stopped", vm.Name, vm.State)
+	}
+	// CloudStack creates the instance group a VM is deployed into if the caller has none of its name.
+	var instanceGroup *cloudstack.InstanceGroup
+	if name := p.Get("group"); name != "" {
+		if instanceGroup = s.findInstanceGroup("", name); instanceGroup == nil {
+			instanceGroup = s.newInstanceGroup(name)
+		}
+	}
+
+	var groups []*cloudstack.AffinityGroup
+	for _, groupID := range listParam(p, "affinitygroupids") {
+		group := s.findAffinityGroup(groupID, "")
+		if group == nil {
+			return nil, notFound("affinitygroupids", groupID)
+		}
+		groups = append(groups, group)
+	}
+
+	vm.Affinitygroup = nil
+	for _, group := range s.affinityGroups {
+		group.VirtualmachineIds = removeString(group.VirtualmachineIds, vm.Id)
+	}
+	for _, group := range groups {
+		group.VirtualmachineIds = append(group.VirtualmachineIds, vm.Id)
+		vm.Affinitygroup = append(vm.Affinitygroup, cloudstack.VirtualMachineAffinitygroup{
+			Id: group.Id, Name: group.Name, Type: group.Type, Account: group.Account, Domainid: group.Domainid})
+	}
+	return map[string]interface{}{"virtualmachine": vm}, nil
+}
+
+func (s *Simulator) listInstanceGroups(p url.Values) (interface{}, error) {
+	ret := []*cloudstack.InstanceGroup{}
+	for _, group := range s.instanceGroups {
+		if matches(p, "id", group.Id) && matchesName(p, group.Name) &&
+			group.Account == s.caller.Account && group.Domainid == s.caller.Domainid {
+			ret = append(ret, group)
+		}
+	}
+	return listResponse("instancegroup", ret, len(ret)), nil
+}
+
+func (s *Simulator) createInstanceGroup(p url.Values) (interface{}, error) {
+	name := p.Get("name")
+	if name == "" {
+		return nil, paramError("Unable to execute API command createinstancegroup due to missing parameter name")
+	} else if s.findInstanceGroup("", name) != nil {
+		return nil, paramError("Unable to create vm group, a group with name %s already exists for account %s",
+			name, s.caller.Account)
+	}
+	return map[string]interface{}{"instancegroup": s.newInstanceGroup(name)}, nil
+}
+
+func (s *Simulator) deleteInstanceGroup(p url.Values) (interface{}, error) {
+	group := s.findInstanceGroup(p.Get("id"), "")
+	if group == nil {
+		return nil, paramError("Unable to find uuid for id %s", p.Get("id"))
+	}
+	groups := s.instanceGroups[:0]
+	for _, g := range s.instanceGroups {
+		if g.Id != group.Id {
+			groups = append(groups, g)
+		}
+	}
+	s.instanceGroups = groups
+	for _, vm := range s.virtualMachines {
+		if vm.Groupid == group.Id {
+			vm.Group, vm.Groupid = "", ""
+		}
+	}
+	return successResponse(), nil
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package simulator
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/apache/cloudstack-go/v2/cloudstack"
+)
+
+const (
+	// The zone's IPv6 guest prefix, which dual-stack isolated networks get a /64 of.
+	guestIPv6Prefix = "fd00:cafe:0:%x::/64"
+	// The public IPv6 range the virtual routers of dual-stack isolated networks get an address of.
+	publicIPv6Range = "2001:db8::%x"
+)
+
+// Ipv6FirewallRule is an IPv6 firewall rule of an isolated network, which cloudstack-go has no type for.
+type Ipv6FirewallRule struct {
+	Id          string `json:"id"`
+	Networkid   string `json:"networkid"`
+	Protocol    string `json:"protocol"`
+	Startport   int    `json:"startport,omitempty"`
+	Endport     int    `json:"endport,omitempty"`
+	Cidrlist    string `json:"cidrlist"`
+	Traffictype string `json:"traffictype"`
+	State       string `json:"state"`
+}
+
+func init() {
+	registerCommand("listIpv6FirewallRules", false, (*Simulator).listIPv6FirewallRules)
+	registerCommand("createIpv6FirewallRule", true, (*Simulator).createIPv6FirewallRule)
+	registerCommand("deleteIpv6FirewallRule", true, (*Simulator).deleteIPv6FirewallRule)
+}
+
+// assignIPv6Subnet gives a network on a dual-stack offering a /64 of the guest prefix, routed via a public address
+// of its virtual router.
+func (s *Simulator) assignIPv6Subnet(n *cloudstack.Network) {
+	s.ipv6Subnets++
+	_, subnet, _ := net.ParseCIDR(fmt.Sprintf(guestIPv6Prefix, s.ipv6Subnets))
+	n.Ip6cidr = subnet.String()
+	n.Ip6gateway = offsetIPv6(subnet.IP, 1).String()
+	s.ipv6Routers[n.Id] = fmt.Sprintf(publicIPv6Range, s.ipv6Subnets)
+	s.guestIPs[n.Id][n.Ip6gateway] = true
+}
+
+// allocateGuestIPv6 reserves the lowest free IPv6 address of a dual-stack network, if it is one.
+func (s *Simulator) allocateGuestIPv6(n *cloudstack.Network) string {
+	if n.Ip6cidr == "" {
+		return ""
+	}
+	_, subnet, _ := net.ParseCIDR(n.Ip6cidr)
+	used := s.guestIPs[n.Id]
+	for offset := uint16(2); ; offset++ {
+		if candidate := offsetIPv6(subnet.IP, offset).String(); !used[candidate] {
+			used[candidate] = true
+			return candidate
+		}
+	}
+}
+
+func offsetIPv6(ip net.IP, offset uint16) net.IP {
+	ret := make(net.IP, net.IPv6len)
+	copy(ret, ip.To16())
+	binary.BigEndian.PutUint16(ret[14:], binary.BigEndian.Uint16(ret[14:])+offset)
+	return ret
+}
+
+// withIPv6Routes adds the routes of a dual-stack network to its JSON representation, as cloudstack-go's type lacks
+// them.
+func (s *Simulator) withIPv6Routes(n *cloudstack.Network) (interface{}, error) {
+	router, found := s.ipv6Routers[n.Id]
+	if !found {
+		return n, nil
+	}
+	ret, err := toMap(n)
+	if err != nil {
+		return nil, err
+	}
+	ret["ip6routes"] = []map[string]string{{"subnet": n.Ip6cidr, "gateway": router}}
+	return ret, nil
+}
+
+func (s *Simulator) listIPv6FirewallRules(p url.Values) (interface{}, error) {
+	ret := []*Ipv6FirewallRule{}
+	for _, rule := range s.ipv6FirewallRules {
+		if matches(p, "id", rule.Id) && matches(p, "networkid", rule.Networkid) &&
+			matches(p, "traffictype", rule.Traffictype) {
+			ret = append(ret, rule)
+		}
+	}
+	return listResponse("ipv6firewallrule", ret, len(ret)), nil
+}
+
+func (s *Simulator) createIPv6FirewallRule(p url.Values) (interface{}, error) {
+	n := s.findNetwork(p.Get("networkid"))
+	if n == nil {
+		return nil, notFound("networkid", p.Get("networkid"))
+	} else if n.Ip6cidr == "" {
+		return nil, paramError("The network %s does not support IPv6", n.Name)
+	}
+	protocol := strings.ToLower(p.Get("protocol"))
+	if !aclProtocols[protocol] {
+		return nil, paramError("Invalid protocol %s", p.Get("protocol"))
+	}
+	trafficType := "Ingress"
+	if p.Get("traffictype") != "" {
+		if trafficType = aclTrafficTypes[strings.ToLower(p.Get("traffictype"))]; trafficType == "" {
+			return nil, paramError("Invalid traffic type %s", p.Get("traffictype"))
+		}
+	}
+	cidrList := strings.ReplaceAll(p.Get("cidrlist"), " ", "")
+	if cidrList == "" {
+		cidrList = "::/0"
+	}
+	for _, cidr := range strings.Split(cidrList, ",") {
+		if ip, _, err := net.ParseCIDR(cidr); err != nil || ip.To4() != nil {
+			return nil, paramError("Invalid IPv6 CIDR %s", cidr)
+		}
+	}
+	start, end := parsePortRange(p)
+	rule := &Ipv6FirewallRule{Id: s.newID(), Networkid: n.Id, Protocol: protocol, Startport: start, Endport: end,
+		Cidrlist: cidrList, Traffictype: trafficType, State: "Active"}
+	s.ipv6FirewallRules = append(s.ipv6FirewallRules, rule)
+	return map[string]interface{}{"ipv6firewallrule": rule}, nil
+}
+
+func (s *Simulator) deleteIPv6FirewallRule(p url.Values) (interface{}, error) {
+	rules := s.ipv6FirewallRules[:0]
+	found := false
+	for _, rule := range s.ipv6FirewallRules {
+		if rule.Id == p.Get("id") {
+			found = true
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	if !found {
+		return nil, notFound("id", p.Get("id"))
+	}
+	s.ipv6FirewallRules = rules
+	return successResponse(), nil
+}
+
+// forgetIPv6Network drops the IPv6 firewall rules and routes of a deleted network.
+func (s *Simulator) forgetIPv6Network(networkID string) {
+	rules := s.ipv6FirewallRules[:0]
+	for _, rule := range s.ipv6FirewallRules {
+		if rule.Networkid != networkID {
+			rules = append(rules, rule)
+		}
+	}
+	s.ipv6FirewallRules = rules
+	delete(s.ipv6Routers, networkID)
+}
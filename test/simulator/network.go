@@ -0,0 +1,844 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package simulator
+
+import (
+	"encoding/binary"
+	"net"
+	"net/url"
+	"strconv"
+
+	"github.com/apache/cloudstack-go/v2/cloudstack"
+)
+
+var (
+	lbAlgorithms        = map[string]bool{"roundrobin": true, "leastconn": true, "source": true}
+	lbStickinessMethods = map[string]bool{"LbCookie": true, "AppCookie": true, "SourceBased": true}
+)
+
+func init() {
+	registerCommand("listNetworkOfferings", false, (*Simulator).listNetworkOfferings)
+	registerCommand("listNetworks", false, (*Simulator).listNetworks)
+	registerCommand("createNetwork", false, (*Simulator).createNetwork)
+	registerCommand("deleteNetwork", true, (*Simulator).deleteNetwork)
+	registerCommand("listPublicIpAddresses", false, (*Simulator).listPublicIPAddresses)
+	registerCommand("associateIpAddress", true, (*Simulator).associateIPAddress)
+	registerCommand("disassociateIpAddress", true, (*Simulator).disassociateIPAddress)
+	registerCommand("listLoadBalancerRules", false, (*Simulator).listLoadBalancerRules)
+	registerCommand("createLoadBalancerRule", true, (*Simulator).createLoadBalancerRule)
+	registerCommand("deleteLoadBalancerRule", true, (*Simulator).deleteLoadBalancerRule)
+	registerCommand("assignToLoadBalancerRule", true, (*Simulator).assignToLoadBalancerRule)
+	registerCommand("removeFromLoadBalancerRule", true, (*Simulator).removeFromLoadBalancerRule)
+	registerCommand("updateLoadBalancerRule", true, (*Simulator).updateLoadBalancerRule)
+	registerCommand("listLoadBalancerRuleInstances", false, (*Simulator).listLoadBalancerRuleInstances)
+	registerCommand("listLBStickinessPolicies", false, (*Simulator).listLBStickinessPolicies)
+	registerCommand("createLBStickinessPolicy", true, (*Simulator).createLBStickinessPolicy)
+	registerCommand("deleteLBStickinessPolicy", true, (*Simulator).deleteLBStickinessPolicy)
+	registerCommand("listLBHealthCheckPolicies", false, (*Simulator).listLBHealthCheckPolicies)
+	registerCommand("createLBHealthCheckPolicy", true, (*Simulator).createLBHealthCheckPolicy)
+	registerCommand("deleteLBHealthCheckPolicy", true, (*Simulator).deleteLBHealthCheckPolicy)
+	registerCommand("listFirewallRules", false, (*Simulator).listFirewallRules)
+	registerCommand("createFirewallRule", true, (*Simulator).createFirewallRule)
+	registerCommand("deleteFirewallRule", true, (*Simulator).deleteFirewallRule)
+	registerCommand("listEgressFirewallRules", false, (*Simulator).listEgressFirewallRules)
+	registerCommand("createEgressFirewallRule", true, (*Simulator).createEgressFirewallRule)
+	registerCommand("deleteEgressFirewallRule", true, (*Simulator).deleteEgressFirewallRule)
+}
+
+func (s *Simulator) findNetwork(id string) *cloudstack.Network {
+	for _, n := range s.networks {
+		if n.Id == id {
+			return n
+		}
+	}
+	return nil
+}
+
+// offersService reports whether a network offering provides the named service.
+func offersService(offering *cloudstack.NetworkOffering, service string) bool {
+	for _, offered := range offering.Service {
+		if offered.Name == service {
+			return true
+		}
+	}
+	return false
+}
+
+// networkSupports reports whether a network's offering provides the named service.
+func (s *Simulator) networkSupports(n *cloudstack.Network, service string) bool {
+	for _, offering := range s.networkOfferings {
+		if offering.Id == n.Networkofferingid {
+			return offersService(offering, service)
+		}
+	}
+	return false
+}
+
+func (s *Simulator) findPublicIP(id string) *cloudstack.PublicIpAddress {
+	for _, ip := range s.publicIPs {
+		if ip.Id == id {
+			return ip
+		}
+	}
+	return nil
+}
+
+func (s *Simulator) findLoadBalancerRule(id string) *cloudstack.LoadBalancerRule {
+	for _, rule := range s.lbRules {
+		if rule.Id == id {
+			return rule
+		}
+	}
+	return nil
+}
+
+func (s *Simulator) listNetworkOfferings(p url.Values) (interface{}, error) {
+	ret := []*cloudstack.NetworkOffering{}
+	for _, offering := range s.networkOfferings {
+		if matches(p, "id", offering.Id) && matchesName(p, offering.Name) && matches(p, "guestiptype", offering.Guestiptype) {
+			ret = append(ret, offering)
+		}
+	}
+	return listResponse("networkoffering", ret, len(ret)), nil
+}
+
+func (s *Simulator) listNetworks(p url.Values) (interface{}, error) {
+	ret := []interface{}{}
+	for _, n := range s.networks {
+		if matches(p, "id", n.Id) && matchesName(p, n.Name) && matches(p, "zoneid", n.Zoneid) &&
+			matches(p, "type", n.Type) && matches(p, "vpcid", n.Vpcid) {
+			n.Tags = s.resourceTags("Network", n.Id)
+			network, err := s.withIPv6Routes(n)
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, network)
+		}
+	}
+	return listResponse("network", ret, len(ret)), nil
+}
+
+// newNetwork creates a network from an offering, acquiring a source NAT address for it if the offering needs one.
+func (s *Simulator) newNetwork(zone *cloudstack.Zone, offering *cloudstack.NetworkOffering, name, cidr string) *cloudstack.Network {
+	if cidr == "" {
+		cidr = defaultGuestCIDR
+	}
+	_, ipNet, _ := net.ParseCIDR(cidr)
+	n := &cloudstack.Network{
+		Id:                  s.newID(),
+		Name:                name,
+		Displaytext:         name,
+		Type:                offering.Guestiptype,
+		Zoneid:              zone.Id,
+		Zonename:            zone.Name,
+		Networkofferingid:   offering.Id,
+		Networkofferingname: offering.Name,
+		Cidr:                ipNet.String(),
+		Gateway:             offsetIP(ipNet.IP, 1).String(),
+		Netmask:             net.IP(ipNet.Mask).String(),
+		Networkdomain:       "cs" + strconv.Itoa(s.nextID) + "cloud.internal",
+		Traffictype:         "Guest",
+		State:               "Allocated",
+		Canusefordeploy:     true,
+		Acltype:             "Account",
+		Created:             now(),
+	}
+	if offering.Guestiptype == NetworkTypeShared {
+		n.Acltype = "Domain"
+	}
+	if s.caller != nil {
+		n.Account, n.Domain, n.Domainid = s.caller.Account, s.caller.Domain, s.caller.Domainid
+	}
+	s.networks = append(s.networks, n)
+	s.guestIPs[n.Id] = map[string]bool{n.Gateway: true}
+	if s.dualStackOfferings[offering.Id] {
+		s.assignIPv6Subnet(n)
+	}
+
+	if offering.Guestiptype == NetworkTypeIsolated && !offering.Forvpc && offersService(offering, "SourceNat") {
+		for _, ip := range s.publicIPs {
+			if ip.Zoneid == zone.Id && ip.State == "Free" {
+				s.allocatePublicIP(ip, n)
+				ip.Issourcenat = true
+				break
+			}
+		}
+	}
+	return n
+}
+
+func offsetIP(ip net.IP, offset uint32) net.IP {
+	ip4 := ip.To4()
+	ret := make(net.IP, 4)
+	binary.BigEndian.PutUint32(ret, binary.BigEndian.Uint32(ip4)+offset)
+	return ret
+}
+
+// allocateGuestIP reserves the requested address in the network, or the lowest free one if none is requested.
+func (s *Simulator) allocateGuestIP(n *cloudstack.Network, requested string) (string, error) {
+	_, ipNet, err := net.ParseCIDR(n.Cidr)
+	if err != nil {
+		return "", NewAPIError(ErrorCodeInternalError, "network %s has an invalid CIDR %s", n.Id, n.Cidr)
+	}
+	used := s.guestIPs[n.Id]
+	if requested != "" {
+		if ip := net.ParseIP(requested); ip == nil || !ipNet.Contains(ip) {
+			return "", paramError("IP address %s is not in the network %s CIDR %s", requested, n.Name, n.Cidr)
+		} else if used[requested] {
+			return "", paramError("The IP address %s is already in use in network %s", requested, n.Name)
+		}
+		used[requested] = true
+		return requested, nil
+	}
+	ones, bits := ipNet.Mask.Size()
+	for offset := uint32(2); offset < uint32(1)<<(bits-ones)-1; offset++ {
+		candidate := offsetIP(ipNet.IP, offset).String()
+		if !used[candidate] {
+			used[candidate] = true
+			return candidate, nil
+		}
+	}
+	return "", NewAPIError(ErrorCodeInsufficientCapacity, "Insufficient address capacity in network %s", n.Name)
+}
+
+func (s *Simulator) releaseGuestIP(networkID, ip string) {
+	if used, found := s.guestIPs[networkID]; found {
+		delete(used, ip)
+	}
+}
+
+func (s *Simulator) createNetwork(p url.Values) (interface{}, error) {
+	var zone *cloudstack.Zone
+	for _, z := range s.zones {
+		if z.Id == p.Get("zoneid") {
+			zone = z
+		}
+	}
+	if zone == nil {
+		return nil, notFound("zoneid", p.Get("zoneid"))
+	}
+	var offering *cloudstack.NetworkOffering
+	for _, o := range s.networkOfferings {
+		if o.Id == p.Get("networkofferingid") {
+			offering = o
+		}
+	}
+	if offering == nil {
+		return nil, notFound("networkofferingid", p.Get("networkofferingid"))
+	}
+	var vpc *cloudstack.VPC
+	if p.Get("vpcid") != "" {
+		var err error
+		if vpc, err = s.validateVPCTier(p, offering); err != nil {
+			return nil, err
+		}
+	} else if offering.Forvpc {
+		return nil, paramError("Network offering id=%s can only be used for VPC networks", offering.Id)
+	}
+
+	if p.Get("vlan") != "" && !offering.Specifyvlan {
+		return nil, paramError("Can't specify vlan; corresponding offering says specifyVlan=false")
+	}
+
+	cidr := ""
+	if gateway, netmask := p.Get("gateway"), p.Get("netmask"); gateway != "" || netmask != "" {
+		gatewayIP, mask := net.ParseIP(gateway), net.IPMask(net.ParseIP(netmask).To4())
+		if gatewayIP == nil || net.ParseIP(netmask) == nil {
+			return nil, paramError("Invalid gateway %s or netmask %s", gateway, netmask)
+		}
+		ones, _ := mask.Size()
+		cidr = gatewayIP.Mask(mask).String() + "/" + strconv.Itoa(ones)
+	}
+
+	n := s.newNetwork(zone, offering, p.Get("name"), cidr)
+	if displayText := p.Get("displaytext"); displayText != "" {
+		n.Displaytext = displayText
+	}
+	if gateway := p.Get("gateway"); gateway != "" {
+		n.Gateway = gateway
+		s.guestIPs[n.Id] = map[string]bool{gateway: true}
+	}
+	if networkDomain := p.Get("networkdomain"); networkDomain != "" {
+		n.Networkdomain = networkDomain
+	}
+	n.Vlan = p.Get("vlan")
+	if vpc != nil {
+		n.Vpcid = vpc.Id
+		// Like CloudStack, tiers created without an ACL list deny all traffic.
+		aclID := p.Get("aclid")
+		for _, list := range s.networkACLLists {
+			if list.Id == aclID || (aclID == "" && list.Name == DefaultDenyACLList && list.Vpcid == "") {
+				n.Aclid, n.Aclname = list.Id, list.Name
+			}
+		}
+	}
+	return map[string]interface{}{"network": n}, nil
+}
+
+func (s *Simulator) deleteNetwork(p url.Values) (interface{}, error) {
+	n := s.findNetwork(p.Get("id"))
+	if n == nil {
+		return nil, notFound("id", p.Get("id"))
+	}
+	for _, vm := range s.virtualMachines {
+		if findNic(vm, n.Id) != nil {
+			return nil, NewAPIError(ErrorCodeInternalError,
+				"Can't delete the network, not all user vms are expunged. Vm %s is in %s state", vm.Name, vm.State)
+		}
+	}
+	for _, ip := range s.publicIPs {
+		if ip.Associatednetworkid != n.Id {
+			continue
+		}
+		if ip.Vpcid == "" {
+			s.releasePublicIP(ip)
+			continue
+		}
+		// VPC addresses stay with the VPC, losing the rules forwarding to the tier.
+		s.removeLoadBalancerRules(ip.Id)
+		ip.Associatednetworkid, ip.Associatednetworkname = "", ""
+	}
+	egressRules := s.egressRules[:0]
+	for _, rule := range s.egressRules {
+		if rule.Networkid != n.Id {
+			egressRules = append(egressRules, rule)
+		}
+	}
+	s.egressRules = egressRules
+	networks := s.networks[:0]
+	for _, other := range s.networks {
+		if other.Id != n.Id {
+			networks = append(networks, other)
+		}
+	}
+	s.networks = networks
+	s.forgetIPv6Network(n.Id)
+	delete(s.guestIPs, n.Id)
+	s.deleteResourceTags(n.Id)
+	return successResponse(), nil
+}
+
+func (s *Simulator) listPublicIPAddresses(p url.Values) (interface{}, error) {
+	allocatedOnly := true
+	if v, err := strconv.ParseBool(p.Get("allocatedonly")); err == nil {
+		allocatedOnly = v
+	}
+	ret := []*cloudstack.PublicIpAddress{}
+	for _, ip := range s.publicIPs {
+		if (allocatedOnly && ip.State == "Free") || !matches(p, "id", ip.Id) || !matches(p, "ipaddress", ip.Ipaddress) ||
+			!matches(p, "zoneid", ip.Zoneid) || !matches(p, "associatednetworkid", ip.Associatednetworkid) ||
+			!matches(p, "vpcid", ip.Vpcid) || !matches(p, "issourcenat", strconv.FormatBool(ip.Issourcenat)) {
+			continue
+		}
+		ip.Tags = s.resourceTags("PublicIpAddress", ip.Id)
+		ret = append(ret, ip)
+	}
+	return listResponse("publicipaddress", ret, len(ret)), nil
+}
+
+func (s *Simulator) allocatePublicIP(ip *cloudstack.PublicIpAddress, n *cloudstack.Network) {
+	ip.State = "Allocated"
+	ip.Allocated = now()
+	ip.Associatednetworkid = n.Id
+	ip.Associatednetworkname = n.Name
+	if s.caller != nil {
+		ip.Account, ip.Domain, ip.Domainid = s.caller.Account, s.caller.Domain, s.caller.Domainid
+	}
+}
+
+// removeLoadBalancerRules deletes the load balancer rules on a public IP address.
+func (s *Simulator) removeLoadBalancerRules(ipID string) {
+	lbRules := s.lbRules[:0]
+	for _, rule := range s.lbRules {
+		if rule.Publicipid == ipID {
+			s.forgetLoadBalancerRule(rule.Id)
+			continue
+		}
+		lbRules = append(lbRules, rule)
+	}
+	s.lbRules = lbRules
+}
+
+// releasePublicIP returns an address to the free pool along with the rules and tags that were on it.
+func (s *Simulator) releasePublicIP(ip *cloudstack.PublicIpAddress) {
+	s.removeLoadBalancerRules(ip.Id)
+	firewallRules := s.firewallRules[:0]
+	for _, rule := range s.firewallRules {
+		if rule.Ipaddressid != ip.Id {
+			firewallRules = append(firewallRules, rule)
+		}
+	}
+	s.firewallRules = firewallRules
+	s.deleteResourceTags(ip.Id)
+
+	ip.State = "Free"
+	ip.Allocated, ip.Associatednetworkid, ip.Associatednetworkname = "", "", ""
+	ip.Account, ip.Domain, ip.Domainid, ip.Vpcid = "", "", "", ""
+	ip.Issourcenat = false
+}
+
+func (s *Simulator) associateIPAddress(p url.Values) (interface{}, error) {
+	var n *cloudstack.Network
+	var vpc *cloudstack.VPC
+	zoneID, zoneName := "", ""
+	if vpcID := p.Get("vpcid"); vpcID != "" {
+		if vpc = s.findVPC(vpcID); vpc == nil {
+			return nil, notFound("vpcid", vpcID)
+		}
+		zoneID, zoneName = vpc.Zoneid, vpc.Zonename
+	} else if n = s.findNetwork(p.Get("networkid")); n == nil {
+		return nil, notFound("networkid", p.Get("networkid"))
+	} else if !s.networkSupports(n, "SourceNat") && !s.networkSupports(n, "StaticNat") && !s.networkSupports(n, "Lb") {
+		return nil, paramError("Network id=%s doesn't support public IP addresses", n.Id)
+	} else {
+		zoneID, zoneName = n.Zoneid, n.Zonename
+	}
+	var ip *cloudstack.PublicIpAddress
+	for _, candidate := range s.publicIPs {
+		if candidate.Zoneid != zoneID {
+			continue
+		}
+		if address := p.Get("ipaddress"); address != "" {
+			if candidate.Ipaddress == address {
+				ip = candidate
+				break
+			}
+		} else if candidate.State == "Free" {
+			ip = candidate
+			break
+		}
+	}
+	if ip == nil {
+		if p.Get("ipaddress") != "" {
+			return nil, paramError("Unable to find public IP address %s in zone %s", p.Get("ipaddress"), zoneID)
+		}
+		return nil, NewAPIError(ErrorCodeInsufficientCapacity, "Insufficient address capacity in zone %s", zoneName)
+	} else if ip.State != "Free" {
+		return nil, paramError("IP address %s is already allocated", ip.Ipaddress)
+	}
+	if vpc != nil {
+		s.allocateVPCPublicIP(ip, vpc)
+	} else {
+		s.allocatePublicIP(ip, n)
+	}
+	return map[string]interface{}{"ipaddress": ip}, nil
+}
+
+func (s *Simulator) disassociateIPAddress(p url.Values) (interface{}, error) {
+	ip := s.findPublicIP(p.Get("id"))
+	if ip == nil || ip.State == "Free" {
+		return nil, notFound("id", p.Get("id"))
+	} else if ip.Issourcenat {
+		return nil, paramError("IP address id=%s is a source NAT for network id=%s and can't be released",
+			ip.Id, ip.Associatednetworkid)
+	}
+	s.releasePublicIP(ip)
+	return successResponse(), nil
+}
+
+func (s *Simulator) listLoadBalancerRules(p url.Values) (interface{}, error) {
+	ret := []*cloudstack.LoadBalancerRule{}
+	for _, rule := range s.lbRules {
+		if matches(p, "id", rule.Id) && matchesName(p, rule.Name) && matches(p, "publicipid", rule.Publicipid) &&
+			matches(p, "networkid", rule.Networkid) && matches(p, "zoneid", rule.Zoneid) &&
+			(p.Get("virtualmachineid") == "" || containsString(s.lbRuleMembers[rule.Id], p.Get("virtualmachineid"))) {
+			rule.Tags = s.resourceTags("LoadBalancer", rule.Id)
+			ret = append(ret, rule)
+		}
+	}
+	return listResponse("loadbalancerrule", ret, len(ret)), nil
+}
+
+// portRangesOverlap reports whether the port range [start1, end1] overlaps [start2, end2].
+func portRangesOverlap(start1, end1, start2, end2 int) bool {
+	return start1 <= end2 && start2 <= end1
+}
+
+func (s *Simulator) createLoadBalancerRule(p url.Values) (interface{}, error) {
+	ip := s.findPublicIP(p.Get("publicipid"))
+	if ip == nil || ip.State == "Free" {
+		return nil, notFound("publicipid", p.Get("publicipid"))
+	}
+	networkID := p.Get("networkid")
+	if ip.Vpcid != "" && ip.Associatednetworkid == "" { // A VPC address is associated with the tier of its first rule.
+		if n := s.findNetwork(networkID); n == nil || n.Vpcid != ip.Vpcid {
+			return nil, paramError("The IP address %s of VPC id=%s requires a network of the VPC", ip.Ipaddress, ip.Vpcid)
+		}
+	} else if networkID == "" {
+		networkID = ip.Associatednetworkid
+	} else if networkID != ip.Associatednetworkid {
+		return nil, paramError("The IP address %s is not associated with network id=%s", ip.Ipaddress, networkID)
+	}
+	n := s.findNetwork(networkID)
+	if n == nil || !s.networkSupports(n, "Lb") {
+		return nil, paramError("LB service is not supported in network id=%s", networkID)
+	}
+	if !lbAlgorithms[p.Get("algorithm")] {
+		return nil, paramError("Invalid algorithm: %s", p.Get("algorithm"))
+	}
+	publicPort, err := strconv.Atoi(p.Get("publicport"))
+	if err != nil {
+		return nil, paramError("Invalid public port %s", p.Get("publicport"))
+	}
+	protocol := p.Get("protocol")
+	if protocol == "" {
+		protocol = "tcp"
+	}
+	for _, rule := range s.lbRules {
+		existingPort, _ := strconv.Atoi(rule.Publicport)
+		if rule.Publicipid == ip.Id && existingPort == publicPort {
+			return nil, paramError("The range specified, %d-%d, conflicts with rule %s which has %d-%d",
+				publicPort, publicPort, rule.Id, existingPort, existingPort)
+		}
+	}
+	rule := &cloudstack.LoadBalancerRule{
+		Id:          s.newID(),
+		Name:        p.Get("name"),
+		Description: p.Get("description"),
+		Algorithm:   p.Get("algorithm"),
+		Privateport: p.Get("privateport"),
+		Publicport:  p.Get("publicport"),
+		Publicip:    ip.Ipaddress,
+		Publicipid:  ip.Id,
+		Networkid:   networkID,
+		Protocol:    protocol,
+		Cidrlist:    p.Get("cidrlist"),
+		Zoneid:      ip.Zoneid,
+		Zonename:    ip.Zonename,
+		State:       "Add",
+		Account:     s.caller.Account,
+		Domain:      s.caller.Domain,
+		Domainid:    s.caller.Domainid,
+	}
+	s.lbRules = append(s.lbRules, rule)
+	s.lbRuleMembers[rule.Id] = []string{}
+	ip.Associatednetworkid, ip.Associatednetworkname = n.Id, n.Name
+
+	// Like CloudStack, open the public port to everyone unless asked not to.
+	if s.networkSupports(n, "Firewall") && p.Get("openfirewall") != "false" {
+		s.firewallRules = append(s.firewallRules, &cloudstack.FirewallRule{Id: s.newID(), Ipaddressid: ip.Id,
+			Ipaddress: ip.Ipaddress, Networkid: networkID, Protocol: protocol, Startport: publicPort, Endport: publicPort,
+			Cidrlist: "0.0.0.0/0", State: "Active"})
+	}
+	return map[string]interface{}{"loadbalancer": rule}, nil
+}
+
+func (s *Simulator) deleteLoadBalancerRule(p url.Values) (interface{}, error) {
+	if s.findLoadBalancerRule(p.Get("id")) == nil {
+		return nil, notFound("id", p.Get("id"))
+	}
+	rules := s.lbRules[:0]
+	for _, rule := range s.lbRules {
+		if rule.Id != p.Get("id") {
+			rules = append(rules, rule)
+		}
+	}
+	s.lbRules = rules
+	s.forgetLoadBalancerRule(p.Get("id"))
+	return successResponse(), nil
+}
+
+// forgetLoadBalancerRule drops what hangs off a removed load balancer rule.
+func (s *Simulator) forgetLoadBalancerRule(id string) {
+	delete(s.lbRuleMembers, id)
+	delete(s.lbStickinessPolicies, id)
+	delete(s.lbHealthCheckPolicies, id)
+	s.deleteResourceTags(id)
+}
+
+func (s *Simulator) updateLoadBalancerRule(p url.Values) (interface{}, error) {
+	rule := s.findLoadBalancerRule(p.Get("id"))
+	if rule == nil {
+		return nil, notFound("id", p.Get("id"))
+	}
+	if algorithm := p.Get("algorithm"); algorithm != "" {
+		if !lbAlgorithms[algorithm] {
+			return nil, paramError("Invalid algorithm: %s", algorithm)
+		}
+		rule.Algorithm = algorithm
+	}
+	if name := p.Get("name"); name != "" {
+		rule.Name = name
+	}
+	return map[string]interface{}{"loadbalancer": rule}, nil
+}
+
+func (s *Simulator) assignToLoadBalancerRule(p url.Values) (interface{}, error) {
+	rule := s.findLoadBalancerRule(p.Get("id"))
+	if rule == nil {
+		return nil, notFound("id", p.Get("id"))
+	}
+	vmIDs := listParam(p, "virtualmachineids")
+	for _, vmID := range vmIDs {
+		vm := s.findVirtualMachine(vmID)
+		if vm == nil {
+			return nil, notFound("virtualmachineids", vmID)
+		} else if findNic(vm, rule.Networkid) == nil {
+			return nil, paramError("VM %s doesn't have a nic in the network of load balancer rule %s", vm.Id, rule.Id)
+		} else if containsString(s.lbRuleMembers[rule.Id], vmID) {
+			return nil, paramError("VM %s is already mapped to load balancer rule %s", vm.Id, rule.Id)
+		}
+	}
+	s.lbRuleMembers[rule.Id] = append(s.lbRuleMembers[rule.Id], vmIDs...)
+	rule.State = "Active"
+	return successResponse(), nil
+}
+
+func (s *Simulator) removeFromLoadBalancerRule(p url.Values) (interface{}, error) {
+	rule := s.findLoadBalancerRule(p.Get("id"))
+	if rule == nil {
+		return nil, notFound("id", p.Get("id"))
+	}
+	for _, vmID := range listParam(p, "virtualmachineids") {
+		if !containsString(s.lbRuleMembers[rule.Id], vmID) {
+			return nil, paramError("VM %s is not mapped to load balancer rule %s", vmID, rule.Id)
+		}
+		s.lbRuleMembers[rule.Id] = removeString(s.lbRuleMembers[rule.Id], vmID)
+	}
+	return successResponse(), nil
+}
+
+func (s *Simulator) listLoadBalancerRuleInstances(p url.Values) (interface{}, error) {
+	rule := s.findLoadBalancerRule(p.Get("id"))
+	if rule == nil {
+		return nil, notFound("id", p.Get("id"))
+	}
+	ret := []*cloudstack.VirtualMachine{}
+	for _, vmID := range s.lbRuleMembers[rule.Id] {
+		if vm := s.findVirtualMachine(vmID); vm != nil {
+			ret = append(ret, vm)
+		}
+	}
+	return listResponse("loadbalancerruleinstance", ret, len(ret)), nil
+}
+
+func (s *Simulator) listLBStickinessPolicies(p url.Values) (interface{}, error) {
+	rule := s.findLoadBalancerRule(p.Get("lbruleid"))
+	if rule == nil {
+		return nil, notFound("lbruleid", p.Get("lbruleid"))
+	}
+	ret := []*cloudstack.LBStickinessPolicy{{Lbruleid: rule.Id, Zoneid: rule.Zoneid, Account: rule.Account,
+		Domain: rule.Domain, Domainid: rule.Domainid, State: rule.State,
+		Stickinesspolicy: append([]cloudstack.LBStickinessPolicyStickinesspolicy{}, s.lbStickinessPolicies[rule.Id]...)}}
+	return listResponse("lbstickinesspolicy", ret, len(ret)), nil
+}
+
+func (s *Simulator) createLBStickinessPolicy(p url.Values) (interface{}, error) {
+	rule := s.findLoadBalancerRule(p.Get("lbruleid"))
+	if rule == nil {
+		return nil, notFound("lbruleid", p.Get("lbruleid"))
+	} else if !lbStickinessMethods[p.Get("methodname")] {
+		return nil, paramError("Failed to create Stickiness policy: Method %s is not supported", p.Get("methodname"))
+	} else if len(s.lbStickinessPolicies[rule.Id]) > 0 {
+		return nil, paramError("Failed to create Stickiness policy: Already policy attached to load balancer rule %s", rule.Id)
+	}
+	policy := cloudstack.LBStickinessPolicyStickinesspolicy{Id: s.newID(), Name: p.Get("name"),
+		Methodname: p.Get("methodname"), Description: p.Get("description"), Params: keyValueParam(p, "param"),
+		State: "Active"}
+	s.lbStickinessPolicies[rule.Id] = append(s.lbStickinessPolicies[rule.Id], policy)
+	return map[string]interface{}{"stickinesspolicies": &cloudstack.LBStickinessPolicy{Lbruleid: rule.Id,
+		Zoneid: rule.Zoneid, State: rule.State, Stickinesspolicy: []cloudstack.LBStickinessPolicyStickinesspolicy{policy}}}, nil
+}
+
+func (s *Simulator) deleteLBStickinessPolicy(p url.Values) (interface{}, error) {
+	for ruleID, policies := range s.lbStickinessPolicies {
+		for i, policy := range policies {
+			if policy.Id == p.Get("id") {
+				s.lbStickinessPolicies[ruleID] = append(policies[:i:i], policies[i+1:]...)
+				return successResponse(), nil
+			}
+		}
+	}
+	return nil, notFound("id", p.Get("id"))
+}
+
+func (s *Simulator) listLBHealthCheckPolicies(p url.Values) (interface{}, error) {
+	rule := s.findLoadBalancerRule(p.Get("lbruleid"))
+	if rule == nil {
+		return nil, notFound("lbruleid", p.Get("lbruleid"))
+	}
+	ret := []*cloudstack.LBHealthCheckPolicy{{Lbruleid: rule.Id, Zoneid: rule.Zoneid, Account: rule.Account,
+		Domain: rule.Domain, Domainid: rule.Domainid,
+		Healthcheckpolicy: append([]cloudstack.LBHealthCheckPolicyHealthcheckpolicy{}, s.lbHealthCheckPolicies[rule.Id]...)}}
+	return listResponse("lbhealthcheckpolicy", ret, len(ret)), nil
+}
+
+// intParam reads an integer parameter, falling back to a default when it's unset.
+func intParam(p url.Values, key string, def int) (int, error) {
+	if p.Get(key) == "" {
+		return def, nil
+	}
+	val, err := strconv.Atoi(p.Get(key))
+	if err != nil || val < 1 {
+		return 0, paramError("Invalid %s %s", key, p.Get(key))
+	}
+	return val, nil
+}
+
+func (s *Simulator) createLBHealthCheckPolicy(p url.Values) (interface{}, error) {
+	rule := s.findLoadBalancerRule(p.Get("lbruleid"))
+	if rule == nil {
+		return nil, notFound("lbruleid", p.Get("lbruleid"))
+	} else if len(s.lbHealthCheckPolicies[rule.Id]) > 0 {
+		return nil, paramError("Failed to create HealthCheck policy: Already policy attached to load balancer rule %s", rule.Id)
+	}
+	policy := cloudstack.LBHealthCheckPolicyHealthcheckpolicy{Id: s.newID(), Pingpath: p.Get("pingpath"),
+		Description: p.Get("description"), State: "Active"}
+	if policy.Pingpath == "" {
+		policy.Pingpath = "/"
+	}
+	var err error
+	for _, param := range []struct {
+		key string
+		def int
+		val *int
+	}{
+		{"intervaltime", 5, &policy.Healthcheckinterval},
+		{"responsetimeout", 2, &policy.Responsetime},
+		{"healthythreshold", 2, &policy.Healthcheckthresshold},
+		{"unhealthythreshold", 10, &policy.Unhealthcheckthresshold},
+	} {
+		if *param.val, err = intParam(p, param.key, param.def); err != nil {
+			return nil, err
+		}
+	}
+	s.lbHealthCheckPolicies[rule.Id] = append(s.lbHealthCheckPolicies[rule.Id], policy)
+	return map[string]interface{}{"healthcheckpolicies": &cloudstack.LBHealthCheckPolicy{Lbruleid: rule.Id,
+		Zoneid: rule.Zoneid, Healthcheckpolicy: []cloudstack.LBHealthCheckPolicyHealthcheckpolicy{policy}}}, nil
+}
+
+func (s *Simulator) deleteLBHealthCheckPolicy(p url.Values) (interface{}, error) {
+	for ruleID, policies := range s.lbHealthCheckPolicies {
+		for i, policy := range policies {
+			if policy.Id == p.Get("id") {
+				s.lbHealthCheckPolicies[ruleID] = append(policies[:i:i], policies[i+1:]...)
+				return successResponse(), nil
+			}
+		}
+	}
+	return nil, notFound("id", p.Get("id"))
+}
+
+// parsePortRange reads the startport and endport parameters of a firewall rule. The end port defaults to the start.
+func parsePortRange(p url.Values) (int, int) {
+	start, _ := strconv.Atoi(p.Get("startport"))
+	end, err := strconv.Atoi(p.Get("endport"))
+	if err != nil {
+		end = start
+	}
+	return start, end
+}
+
+func (s *Simulator) listFirewallRules(p url.Values) (interface{}, error) {
+	ret := []*cloudstack.FirewallRule{}
+	for _, rule := range s.firewallRules {
+		if matches(p, "id", rule.Id) && matches(p, "ipaddressid", rule.Ipaddressid) && matches(p, "networkid", rule.Networkid) {
+			ret = append(ret, rule)
+		}
+	}
+	return listResponse("firewallrule", ret, len(ret)), nil
+}
+
+func (s *Simulator) createFirewallRule(p url.Values) (interface{}, error) {
+	ip := s.findPublicIP(p.Get("ipaddressid"))
+	if ip == nil || ip.State == "Free" {
+		return nil, notFound("ipaddressid", p.Get("ipaddressid"))
+	}
+	start, end := parsePortRange(p)
+	cidrList := p.Get("cidrlist")
+	if cidrList == "" {
+		cidrList = "0.0.0.0/0"
+	}
+	for _, rule := range s.firewallRules {
+		if rule.Ipaddressid == ip.Id && rule.Protocol == p.Get("protocol") && rule.Cidrlist == cidrList &&
+			portRangesOverlap(start, end, rule.Startport, rule.Endport) {
+			return nil, paramError("There is already a firewall rule specified for the ip address id=%s and port range %d-%d",
+				ip.Id, rule.Startport, rule.Endport)
+		}
+	}
+	rule := &cloudstack.FirewallRule{Id: s.newID(), Ipaddressid: ip.Id, Ipaddress: ip.Ipaddress,
+		Networkid: ip.Associatednetworkid, Protocol: p.Get("protocol"), Startport: start, Endport: end,
+		Cidrlist: cidrList, State: "Active"}
+	s.firewallRules = append(s.firewallRules, rule)
+	return map[string]interface{}{"firewallrule": rule}, nil
+}
+
+func (s *Simulator) deleteFirewallRule(p url.Values) (interface{}, error) {
+	rules := s.firewallRules[:0]
+	found := false
+	for _, rule := range s.firewallRules {
+		if rule.Id == p.Get("id") {
+			found = true
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	if !found {
+		return nil, notFound("id", p.Get("id"))
+	}
+	s.firewallRules = rules
+	return successResponse(), nil
+}
+
+func (s *Simulator) listEgressFirewallRules(p url.Values) (interface{}, error) {
+	ret := []*cloudstack.EgressFirewallRule{}
+	for _, rule := range s.egressRules {
+		if matches(p, "id", rule.Id) && matches(p, "networkid", rule.Networkid) {
+			ret = append(ret, rule)
+		}
+	}
+	return listResponse("firewallrule", ret, len(ret)), nil
+}
+
+func (s *Simulator) createEgressFirewallRule(p url.Values) (interface{}, error) {
+	n := s.findNetwork(p.Get("networkid"))
+	if n == nil {
+		return nil, notFound("networkid", p.Get("networkid"))
+	}
+	start, end := parsePortRange(p)
+	cidrList := p.Get("cidrlist")
+	if cidrList == "" {
+		cidrList = n.Cidr
+	}
+	for _, rule := range s.egressRules {
+		if rule.Networkid == n.Id && rule.Protocol == p.Get("protocol") && rule.Cidrlist == cidrList &&
+			portRangesOverlap(start, end, rule.Startport, rule.Endport) {
+			return nil, paramError("There is already a firewall rule specified for the network id=%s", n.Id)
+		}
+	}
+	rule := &cloudstack.EgressFirewallRule{Id: s.newID(), Networkid: n.Id, Protocol: p.Get("protocol"),
+		Startport: start, Endport: end, Cidrlist: cidrList, State: "Active"}
+	s.egressRules = append(s.egressRules, rule)
+	return map[string]interface{}{"firewallrule": rule}, nil
+}
+
+func (s *Simulator) deleteEgressFirewallRule(p url.Values) (interface{}, error) {
+	rules := s.egressRules[:0]
+	found := false
+	for _, rule := range s.egressRules {
+		if rule.Id == p.Get("id") {
+			found = true
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	if !found {
+		return nil, notFound("id", p.Get("id"))
+	}
+	s.egressRules = rules
+	return successResponse(), nil
+}
@@ -0,0 +1,214 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package simulator
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/apache/cloudstack-go/v2/cloudstack"
+)
+
+func init() {
+	registerCommand("listTags", false, (*Simulator).listTags)
+	registerCommand("createTags", true, (*Simulator).createTags)
+	registerCommand("deleteTags", true, (*Simulator).deleteTags)
+	registerCommand("listDomains", false, (*Simulator).listDomains)
+	registerCommand("listAccounts", false, (*Simulator).listAccounts)
+	registerCommand("listUsers", false, (*Simulator).listUsers)
+	registerCommand("getUserKeys", false, (*Simulator).getUserKeys)
+}
+
+// taggableResourceType returns the canonical name of a taggable resource type and whether the resource exists.
+func (s *Simulator) taggableResourceType(resourceType, id string) (string, bool) {
+	switch strings.ToLower(resourceType) {
+	case "uservm":
+		return "UserVm", s.findVirtualMachine(id) != nil
+	case "network":
+		return "Network", s.findNetwork(id) != nil
+	case "publicipaddress":
+		return "PublicIpAddress", s.findPublicIP(id) != nil
+	case "loadbalancer":
+		return "LoadBalancer", s.findLoadBalancerRule(id) != nil
+	case "vpc":
+		return "Vpc", s.findVPC(id) != nil
+	case "affinitygroup":
+		return "AffinityGroup", s.findAffinityGroup(id, "") != nil
+	case "volume":
+		for _, vol := range s.volumes {
+			if vol.Id == id {
+				return "Volume", true
+			}
+		}
+		return "Volume", false
+	case "template":
+		for _, template := range s.templates {
+			if template.Id == id {
+				return "Template", true
+			}
+		}
+		return "Template", false
+	}
+	return resourceType, false
+}
+
+// resourceTags returns the tags on a resource in the form they are embedded in other entities.
+func (s *Simulator) resourceTags(resourceType, id string) []cloudstack.Tags {
+	ret := []cloudstack.Tags{}
+	for _, tag := range s.tags {
+		if tag.Resourcetype == resourceType && tag.Resourceid == id {
+			ret = append(ret, cloudstack.Tags{Key: tag.Key, Value: tag.Value, Resourceid: tag.Resourceid,
+				Resourcetype: tag.Resourcetype, Account: tag.Account, Domainid: tag.Domainid})
+		}
+	}
+	return ret
+}
+
+// deleteResourceTags drops all tags of a removed resource.
+func (s *Simulator) deleteResourceTags(id string) {
+	tags := s.tags[:0]
+	for _, tag := range s.tags {
+		if tag.Resourceid != id {
+			tags = append(tags, tag)
+		}
+	}
+	s.tags = tags
+}
+
+func (s *Simulator) listTags(p url.Values) (interface{}, error) {
+	ret := []*cloudstack.Tag{}
+	for _, tag := range s.tags {
+		if matches(p, "resourceid", tag.Resourceid) && strings.EqualFold(tag.Resourcetype, orDefault(p.Get("resourcetype"), tag.Resourcetype)) &&
+			matches(p, "key", tag.Key) && matches(p, "value", tag.Value) {
+			ret = append(ret, tag)
+		}
+	}
+	return listResponse("tag", ret, len(ret)), nil
+}
+
+func orDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+func (s *Simulator) createTags(p url.Values) (interface{}, error) {
+	resourceIDs := listParam(p, "resourceids")
+	tags := keyValueParam(p, "tags")
+	if len(resourceIDs) == 0 || len(tags) == 0 {
+		return nil, paramError("Unable to execute API command createtags due to missing parameter resourceids or tags")
+	}
+	for _, id := range resourceIDs {
+		resourceType, found := s.taggableResourceType(p.Get("resourcetype"), id)
+		if !found {
+			return nil, paramError("Unable to find resource by id %s and type %s", id, p.Get("resourcetype"))
+		}
+		for key := range tags {
+			for _, tag := range s.tags {
+				if tag.Resourceid == id && tag.Key == key {
+					return nil, paramError("tag %s already on %s with id %s", key, resourceType, id)
+				}
+			}
+		}
+	}
+	for _, id := range resourceIDs {
+		resourceType, _ := s.taggableResourceType(p.Get("resourcetype"), id)
+		for key, value := range tags {
+			s.tags = append(s.tags, &cloudstack.Tag{Key: key, Value: value, Resourceid: id, Resourcetype: resourceType,
+				Account: s.caller.Account, Domain: s.caller.Domain, Domainid: s.caller.Domainid})
+		}
+	}
+	return successResponse(), nil
+}
+
+func (s *Simulator) deleteTags(p url.Values) (interface{}, error) {
+	resourceIDs := listParam(p, "resourceids")
+	toDelete := keyValueParam(p, "tags")
+	deleteTag := func(tag *cloudstack.Tag) bool {
+		if !containsString(resourceIDs, tag.Resourceid) || !strings.EqualFold(tag.Resourcetype, p.Get("resourcetype")) {
+			return false
+		} else if len(toDelete) == 0 {
+			return true
+		}
+		value, found := toDelete[tag.Key]
+		return found && (value == "" || value == tag.Value)
+	}
+
+	remaining := s.tags[:0]
+	deleted := 0
+	for _, tag := range s.tags {
+		if deleteTag(tag) {
+			deleted++
+			continue
+		}
+		remaining = append(remaining, tag)
+	}
+	if deleted == 0 {
+		return nil, paramError("Unable to find any tags which conform to specified delete parameters.")
+	}
+	s.tags = remaining
+	return successResponse(), nil
+}
+
+func (s *Simulator) listDomains(p url.Values) (interface{}, error) {
+	ret := []*cloudstack.Domain{}
+	for _, domain := range s.domains {
+		if matches(p, "id", domain.Id) && matchesName(p, domain.Name) && matches(p, "level", strconv.Itoa(domain.Level)) {
+			ret = append(ret, domain)
+		}
+	}
+	return listResponse("domain", ret, len(ret)), nil
+}
+
+func (s *Simulator) listAccounts(p url.Values) (interface{}, error) {
+	ret := []*cloudstack.Account{}
+	for _, account := range s.accounts {
+		if matches(p, "id", account.Id) && matchesName(p, account.Name) && matches(p, "domainid", account.Domainid) {
+			ret = append(ret, account)
+		}
+	}
+	return listResponse("account", ret, len(ret)), nil
+}
+
+func (s *Simulator) listUsers(p url.Values) (interface{}, error) {
+	ret := []*cloudstack.User{}
+	for _, user := range s.users {
+		if matches(p, "id", user.Id) && matches(p, "account", user.Account) && matches(p, "domainid", user.Domainid) &&
+			matches(p, "username", user.Username) {
+			// Keys are only ever returned by getUserKeys.
+			listed := *user
+			listed.Apikey, listed.Secretkey = "", ""
+			ret = append(ret, &listed)
+		}
+	}
+	return listResponse("user", ret, len(ret)), nil
+}
+
+func (s *Simulator) getUserKeys(p url.Values) (interface{}, error) {
+	for _, user := range s.users {
+		if user.Id == p.Get("id") {
+			if user.Apikey == "" {
+				return map[string]interface{}{"userkeys": map[string]string{}}, nil
+			}
+			return map[string]interface{}{"userkeys": map[string]string{
+				"apikey": user.Apikey, "secretkey": user.Secretkey}}, nil
+		}
+	}
+	return nil, notFound("id", p.Get("id"))
+}
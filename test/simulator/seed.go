@@ -0,0 +1,337 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package simulator
+
+import (
+	"strings"
+
+	"github.com/apache/cloudstack-go/v2/cloudstack"
+)
+
+const (
+	RootDomainName = "ROOT"
+	AdminAccount   = "admin"
+	AdminUsername  = "admin"
+
+	IsolatedNetworkOffering = "DefaultIsolatedNetworkOfferingWithSourceNatService"
+	SharedNetworkOffering   = "DefaultSharedNetworkOffering"
+	// A shared network offering with elastic IPs and load balancing, as provided by NetScaler.
+	SharedLBNetworkOffering = "DefaultSharedNetscalerEIPandELBNetworkOffering"
+	// The network offering of VPC tiers, and the offering VPCs are created with.
+	VPCTierNetworkOffering = "DefaultIsolatedNetworkOfferingForVpcNetworks"
+	VPCOffering            = "Default VPC offering"
+
+	// The network ACL lists every VPC can use.
+	DefaultAllowACLList = "default_allow"
+	DefaultDenyACLList  = "default_deny"
+
+	NetworkTypeIsolated = "Isolated"
+	NetworkTypeShared   = "Shared"
+
+	// The CIDR given to isolated networks created without one, as CloudStack does.
+	defaultGuestCIDR = "10.1.1.0/24"
+)
+
+// seed creates the objects a fresh CloudStack installation comes with.
+func (s *Simulator) seed() {
+	root := &cloudstack.Domain{Id: s.newID(), Name: RootDomainName, Path: RootDomainName, Level: 0, State: "Active"}
+	s.domains = append(s.domains, root)
+	admin := s.addAccount(root, AdminAccount)
+	s.addUser(admin, AdminUsername, AdminAPIKey, AdminSecretKey)
+
+	s.networkOfferings = append(s.networkOfferings,
+		&cloudstack.NetworkOffering{Id: s.newID(), Name: IsolatedNetworkOffering, Displaytext: IsolatedNetworkOffering,
+			Guestiptype: NetworkTypeIsolated, Traffictype: "Guest", State: "Enabled", Isdefault: true,
+			Egressdefaultpolicy: false, Service: offeringServices("Dhcp", "Dns", "UserData", "SourceNat", "StaticNat",
+				"PortForwarding", "Lb", "Firewall")},
+		&cloudstack.NetworkOffering{Id: s.newID(), Name: SharedNetworkOffering, Displaytext: SharedNetworkOffering,
+			Guestiptype: NetworkTypeShared, Traffictype: "Guest", State: "Enabled", Isdefault: true, Specifyvlan: true,
+			Service: offeringServices("Dhcp", "Dns", "UserData")},
+		&cloudstack.NetworkOffering{Id: s.newID(), Name: SharedLBNetworkOffering, Displaytext: SharedLBNetworkOffering,
+			Guestiptype: NetworkTypeShared, Traffictype: "Guest", State: "Enabled", Specifyvlan: true,
+			Service: offeringServices("Dhcp", "Dns", "UserData", "StaticNat", "Lb")},
+		&cloudstack.NetworkOffering{Id: s.newID(), Name: VPCTierNetworkOffering, Displaytext: VPCTierNetworkOffering,
+			Guestiptype: NetworkTypeIsolated, Traffictype: "Guest", State: "Enabled", Forvpc: true,
+			Service: offeringServices("Dhcp", "Dns", "UserData", "SourceNat", "StaticNat", "PortForwarding", "Lb",
+				"NetworkACL")})
+	s.vpcOfferings = append(s.vpcOfferings, &cloudstack.VPCOffering{Id: s.newID(), Name: VPCOffering,
+		Displaytext: VPCOffering, State: "Enabled", Isdefault: true, Created: now()})
+	s.networkACLLists = append(s.networkACLLists,
+		&cloudstack.NetworkACLList{Id: s.newID(), Name: DefaultAllowACLList, Description: "Default Network ACL Allow All"},
+		&cloudstack.NetworkACLList{Id: s.newID(), Name: DefaultDenyACLList, Description: "Default Network ACL Deny All"})
+}
+
+func offeringServices(names ...string) []cloudstack.NetworkOfferingServiceInternal {
+	services := make([]cloudstack.NetworkOfferingServiceInternal, 0, len(names))
+	for _, name := range names {
+		services = append(services, cloudstack.NetworkOfferingServiceInternal{Name: name})
+	}
+	return services
+}
+
+// AddZone adds an enabled advanced networking zone.
+func (s *Simulator) AddZone(name string) *cloudstack.Zone {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	zone := &cloudstack.Zone{Id: s.newID(), Name: name, Networktype: "Advanced", Allocationstate: "Enabled"}
+	s.zones = append(s.zones, zone)
+	return zone
+}
+
+// SetZoneCapacity sets the total and used amount of a type of capacity of a zone, such as 0 for memory in bytes or 1
+// for CPU in MHz. Once set, VMs needing more than what's left of it fail to deploy in the zone.
+func (s *Simulator) SetZoneCapacity(zoneID string, capacityType int, total, used int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, capacity := range s.capacities {
+		if capacity.Zoneid == zoneID && capacity.Type == capacityType {
+			capacity.Capacitytotal, capacity.Capacityused = total, used
+			return
+		}
+	}
+	s.capacities = append(s.capacities, &cloudstack.Capacity{
+		Zoneid: zoneID, Type: capacityType, Capacitytotal: total, Capacityused: used})
+}
+
+// AddPod adds an enabled pod to a zone.
+func (s *Simulator) AddPod(zoneID, name string) *cloudstack.Pod {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pod := &cloudstack.Pod{Id: s.newID(), Name: name, Zoneid: zoneID, Allocationstate: "Enabled"}
+	for _, zone := range s.zones {
+		if zone.Id == zoneID {
+			pod.Zonename = zone.Name
+		}
+	}
+	s.pods = append(s.pods, pod)
+	return pod
+}
+
+// AddCluster adds an enabled cluster of hosts to a pod.
+func (s *Simulator) AddCluster(podID, name string) *cloudstack.Cluster {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cluster := &cloudstack.Cluster{Id: s.newID(), Name: name, Podid: podID, Allocationstate: "Enabled"}
+	for _, pod := range s.pods {
+		if pod.Id == podID {
+			cluster.Podname, cluster.Zoneid, cluster.Zonename = pod.Name, pod.Zoneid, pod.Zonename
+		}
+	}
+	s.clusters = append(s.clusters, cluster)
+	return cluster
+}
+
+// AddHost adds an enabled hypervisor host with 64GiB of memory and the given host tags to a cluster. Once a zone has
+// hosts, VMs deploy on one that's in the requested pod, cluster, or host, and has memory left for them, or fail to.
+func (s *Simulator) AddHost(clusterID, name string, tags ...string) *cloudstack.Host {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	host := &cloudstack.Host{Id: s.newID(), Name: name, Clusterid: clusterID, Type: "Routing", State: "Up",
+		Resourcestate: "Enabled", Memorytotal: 64 << 30, Hosttags: strings.Join(tags, ",")}
+	for _, cluster := range s.clusters {
+		if cluster.Id == clusterID {
+			host.Clustername, host.Podid, host.Podname = cluster.Name, cluster.Podid, cluster.Podname
+			host.Zoneid, host.Zonename = cluster.Zoneid, cluster.Zonename
+		}
+	}
+	s.hosts = append(s.hosts, host)
+	return host
+}
+
+// AddNetwork adds a pre-existing guest network, as an administrator would have set up, to a zone.
+func (s *Simulator) AddNetwork(zoneID, name, networkType, cidr string) *cloudstack.Network {
+	offeringName := SharedNetworkOffering
+	if networkType == NetworkTypeIsolated {
+		offeringName = IsolatedNetworkOffering
+	}
+	return s.AddNetworkWithOffering(zoneID, name, offeringName, cidr)
+}
+
+// AddNetworkWithOffering adds a pre-existing guest network on the named network offering to a zone.
+func (s *Simulator) AddNetworkWithOffering(zoneID, name, offeringName, cidr string) *cloudstack.Network {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var offering *cloudstack.NetworkOffering
+	for _, o := range s.networkOfferings {
+		if o.Name == offeringName {
+			offering = o
+		}
+	}
+	net := s.newNetwork(s.findZone(zoneID), offering, name, cidr)
+	net.State = "Implemented"
+	return net
+}
+
+// AddIsolatedNetworkOffering adds an enabled isolated network offering with the same services as the default one.
+func (s *Simulator) AddIsolatedNetworkOffering(name string, specifyVLAN bool) *cloudstack.NetworkOffering {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addIsolatedNetworkOffering(name, specifyVLAN)
+}
+
+// AddDualStackNetworkOffering adds an enabled isolated network offering like the default one, but whose networks get
+// an IPv6 subnet as well.
+func (s *Simulator) AddDualStackNetworkOffering(name string) *cloudstack.NetworkOffering {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	offering := s.addIsolatedNetworkOffering(name, false)
+	s.dualStackOfferings[offering.Id] = true
+	return offering
+}
+
+func (s *Simulator) addIsolatedNetworkOffering(name string, specifyVLAN bool) *cloudstack.NetworkOffering {
+	offering := &cloudstack.NetworkOffering{Id: s.newID(), Name: name, Displaytext: name,
+		Guestiptype: NetworkTypeIsolated, Traffictype: "Guest", State: "Enabled", Specifyvlan: specifyVLAN,
+		Service: offeringServices("Dhcp", "Dns", "UserData", "SourceNat", "StaticNat", "PortForwarding", "Lb", "Firewall")}
+	s.networkOfferings = append(s.networkOfferings, offering)
+	return offering
+}
+
+// AddVPC adds a pre-existing VPC, as an administrator would have set up, to a zone.
+func (s *Simulator) AddVPC(zoneID, name, cidr string) *cloudstack.VPC {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.newVPC(s.findZone(zoneID), s.vpcOfferings[0], name, cidr)
+}
+
+// AddTemplate adds a ready, executable template to a zone.
+func (s *Simulator) AddTemplate(zoneID, name string) *cloudstack.Template {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	zone := s.findZone(zoneID)
+	template := &cloudstack.Template{Id: s.newID(), Name: name, Displaytext: name, Zoneid: zone.Id, Zonename: zone.Name,
+		Isready: true, Ispublic: true, Isfeatured: true, Status: "Download Complete", Templatetype: "USER",
+		Hypervisor: "KVM", Format: "QCOW2", Ostypename: "Ubuntu 20.04 (64-bit)", Size: rootVolumeSize, Created: now()}
+	s.templates = append(s.templates, template)
+	return template
+}
+
+// AddServiceOffering adds a compute offering available in all zones.
+func (s *Simulator) AddServiceOffering(name string, cpuNumber, memoryMB int) *cloudstack.ServiceOffering {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	offering := &cloudstack.ServiceOffering{Id: s.newID(), Name: name, Displaytext: name,
+		Cpunumber: cpuNumber, Cpuspeed: 1000, Memory: memoryMB, Storagetype: "shared", Created: now()}
+	s.serviceOfferings = append(s.serviceOfferings, offering)
+	return offering
+}
+
+// AddScalableServiceOffering adds a compute offering with dynamic scaling enabled. Custom offerings take their CPU
+// count and memory from VM details, and default to the passed ones.
+func (s *Simulator) AddScalableServiceOffering(name string, customized bool, cpuNumber, memoryMB int) *cloudstack.ServiceOffering {
+	offering := s.AddServiceOffering(name, cpuNumber, memoryMB)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	offering.Iscustomized, offering.Dynamicscalingenabled = customized, true
+	return offering
+}
+
+// AddDiskOffering adds a disk offering available in all zones. Customized offerings take their size at deploy time.
+func (s *Simulator) AddDiskOffering(name string, customized bool, sizeGB int64) *cloudstack.DiskOffering {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	offering := &cloudstack.DiskOffering{Id: s.newID(), Name: name, Displaytext: name,
+		Iscustomized: customized, Disksize: sizeGB, Storagetype: "shared", Created: now()}
+	s.diskOfferings = append(s.diskOfferings, offering)
+	return offering
+}
+
+// AddPublicIPAddresses adds unallocated public IP addresses to a zone's public IP range.
+func (s *Simulator) AddPublicIPAddresses(zoneID string, addresses ...string) []*cloudstack.PublicIpAddress {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	zone := s.findZone(zoneID)
+	ret := make([]*cloudstack.PublicIpAddress, 0, len(addresses))
+	for _, address := range addresses {
+		ip := &cloudstack.PublicIpAddress{Id: s.newID(), Ipaddress: address, Zoneid: zone.Id, Zonename: zone.Name,
+			State: "Free", Forvirtualnetwork: true}
+		s.publicIPs = append(s.publicIPs, ip)
+		ret = append(ret, ip)
+	}
+	return ret
+}
+
+// AddDomain adds a sub-domain under the passed parent domain.
+func (s *Simulator) AddDomain(parentID, name string) *cloudstack.Domain {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	parent := s.findDomain(parentID)
+	domain := &cloudstack.Domain{Id: s.newID(), Name: name, Path: parent.Path + "/" + name, Level: parent.Level + 1,
+		Parentdomainid: parent.Id, Parentdomainname: parent.Name, State: "Active"}
+	parent.Haschild = true
+	s.domains = append(s.domains, domain)
+	return domain
+}
+
+// AddAccount adds an account to a domain.
+func (s *Simulator) AddAccount(domainID, name string) *cloudstack.Account {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addAccount(s.findDomain(domainID), name)
+}
+
+// AddUser adds a user to an account. Users added with empty keys can't call the API.
+func (s *Simulator) AddUser(accountID, username, apiKey, secretKey string) *cloudstack.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, account := range s.accounts {
+		if account.Id == accountID {
+			return s.addUser(account, username, apiKey, secretKey)
+		}
+	}
+	panic("simulator: no account with ID " + accountID)
+}
+
+// RootDomain returns the ROOT domain.
+func (s *Simulator) RootDomain() *cloudstack.Domain {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.domains[0]
+}
+
+func (s *Simulator) addAccount(domain *cloudstack.Domain, name string) *cloudstack.Account {
+	account := &cloudstack.Account{Id: s.newID(), Name: name, Domain: domain.Name, Domainid: domain.Id,
+		Domainpath: domain.Path, State: "enabled", Roletype: "Admin", Created: now()}
+	s.accounts = append(s.accounts, account)
+	return account
+}
+
+func (s *Simulator) addUser(account *cloudstack.Account, username, apiKey, secretKey string) *cloudstack.User {
+	user := &cloudstack.User{Id: s.newID(), Username: username, Account: account.Name, Accountid: account.Id,
+		Domain: account.Domain, Domainid: account.Domainid, Apikey: apiKey, Secretkey: secretKey,
+		State: "enabled", Created: now()}
+	s.users = append(s.users, user)
+	return user
+}
+
+func (s *Simulator) findZone(id string) *cloudstack.Zone {
+	for _, zone := range s.zones {
+		if zone.Id == id {
+			return zone
+		}
+	}
+	panic("simulator: no zone with ID " + id)
+}
+
+func (s *Simulator) findDomain(id string) *cloudstack.Domain {
+	for _, domain := range s.domains {
+		if domain.Id == id {
+			return domain
+		}
+	}
+	panic("simulator: no domain with ID " + id)
+}
@@ -0,0 +1,494 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package simulator provides an in-process, stateful fake of the CloudStack API.
+//
+// The simulator speaks the same HTTP protocol as a CloudStack management server, so the real CloudStack-Go
+// client (and therefore the CAPC cloud.Client built by cloud.NewClientFromConf) can be pointed at it. Unlike the
+// gomock based mocks, the simulator keeps state between calls: creating a resource twice really creates two
+// resources, deleting a resource really removes it, and so on.
+package simulator
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apache/cloudstack-go/v2/cloudstack"
+)
+
+const (
+	// Default credentials of the root admin user seeded into every simulator.
+	AdminAPIKey    = "simulator-admin-api-key"
+	AdminSecretKey = "simulator-admin-secret-key"
+
+	// CloudStack HTTP error codes returned by the simulator.
+	ErrorCodeUnauthorized         = 401
+	ErrorCodeAPILimitExceeded     = 429
+	ErrorCodeParamError           = 431
+	ErrorCodeInternalError        = 530
+	ErrorCodeInsufficientCapacity = 533
+	ErrorCodeResourceAllocation   = 535
+
+	// CloudStack exception codes that accompany the HTTP error codes.
+	csExceptionCodeInvalidParameter = 4350
+	csExceptionCodeCloudRuntime     = 4250
+
+	// Format used by CloudStack for date fields.
+	timeFormat = "2006-01-02T15:04:05-0700"
+)
+
+// APIError is an error response as returned by the CloudStack API.
+type APIError struct {
+	ErrorCode   int    `json:"errorcode"`
+	CSErrorCode int    `json:"cserrorcode"`
+	ErrorText   string `json:"errortext"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("CloudStack API error %d (CSExceptionErrorCode: %d): %s", e.ErrorCode, e.CSErrorCode, e.ErrorText)
+}
+
+// NewAPIError builds an APIError with the CloudStack exception code matching the passed HTTP error code.
+func NewAPIError(code int, format string, args ...interface{}) *APIError {
+	csCode := csExceptionCodeCloudRuntime
+	if code == ErrorCodeParamError {
+		csCode = csExceptionCodeInvalidParameter
+	}
+	return &APIError{ErrorCode: code, CSErrorCode: csCode, ErrorText: fmt.Sprintf(format, args...)}
+}
+
+// jobFailure is returned by an asynchronous command's handler that started its job, leaving its side effects behind,
+// but failed to complete it. The job is created and fails with the wrapped error, rather than the request itself.
+type jobFailure struct {
+	*APIError
+}
+
+// paramError is shorthand for the parameter validation errors CloudStack returns for bad input.
+func paramError(format string, args ...interface{}) *APIError {
+	return NewAPIError(ErrorCodeParamError, format, args...)
+}
+
+// handlerFunc implements a single CloudStack API command. The returned value is serialized as the command's
+// response body, or as the job result for asynchronous commands.
+type handlerFunc func(s *Simulator, p url.Values) (interface{}, error)
+
+type command struct {
+	handler handlerFunc
+	async   bool
+}
+
+// commands maps CloudStack API command names to their simulated implementations.
+var commands = map[string]command{}
+
+// registerCommand adds a command to the simulator's command table.
+func registerCommand(name string, async bool, handler handlerFunc) {
+	commands[name] = command{handler: handler, async: async}
+}
+
+func init() {
+	registerCommand("queryAsyncJobResult", false, (*Simulator).queryAsyncJobResult)
+}
+
+type asyncJob struct {
+	id      string
+	cmd     string
+	created time.Time
+	result  interface{}
+	err     *APIError
+	held    bool
+}
+
+// Simulator is an in-process CloudStack API server.
+type Simulator struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	nextID int
+
+	jobs         map[string]*asyncJob
+	injected     map[string][]*APIError
+	held         map[string]bool
+	requestCount map[string]int
+	latency      time.Duration
+
+	zones                 []*cloudstack.Zone
+	capacities            []*cloudstack.Capacity
+	pods                  []*cloudstack.Pod
+	clusters              []*cloudstack.Cluster
+	hosts                 []*cloudstack.Host
+	networkOfferings      []*cloudstack.NetworkOffering
+	serviceOfferings      []*cloudstack.ServiceOffering
+	diskOfferings         []*cloudstack.DiskOffering
+	templates             []*cloudstack.Template
+	networks              []*cloudstack.Network
+	virtualMachines       []*cloudstack.VirtualMachine
+	volumes               []*cloudstack.Volume
+	snapshots             []*cloudstack.Snapshot
+	publicIPs             []*cloudstack.PublicIpAddress
+	lbRules               []*cloudstack.LoadBalancerRule
+	lbRuleMembers         map[string][]string
+	lbStickinessPolicies  map[string][]cloudstack.LBStickinessPolicyStickinesspolicy
+	lbHealthCheckPolicies map[string][]cloudstack.LBHealthCheckPolicyHealthcheckpolicy
+	guestIPs              map[string]map[string]bool
+	userData              map[string]string
+	firewallRules         []*cloudstack.FirewallRule
+	egressRules           []*cloudstack.EgressFirewallRule
+	ipv6FirewallRules     []*Ipv6FirewallRule
+	ipv6Routers           map[string]string
+	ipv6Subnets           int
+	dualStackOfferings    map[string]bool
+	affinityGroups        []*cloudstack.AffinityGroup
+	instanceGroups        []*cloudstack.InstanceGroup
+	vpcOfferings          []*cloudstack.VPCOffering
+	vpcs                  []*cloudstack.VPC
+	networkACLLists       []*cloudstack.NetworkACLList
+	networkACLs           []*cloudstack.NetworkACL
+	tags                  []*cloudstack.Tag
+	domains               []*cloudstack.Domain
+	accounts              []*cloudstack.Account
+	users                 []*cloudstack.User
+
+	// The user making the request currently being served.
+	caller *cloudstack.User
+}
+
+// New starts a simulator seeded with a ROOT domain, an admin account and user, the default network and VPC offerings,
+// and the default network ACL lists.
+// The caller is responsible for calling Close.
+func New() *Simulator {
+	s := &Simulator{
+		jobs:                  map[string]*asyncJob{},
+		injected:              map[string][]*APIError{},
+		held:                  map[string]bool{},
+		requestCount:          map[string]int{},
+		lbRuleMembers:         map[string][]string{},
+		lbStickinessPolicies:  map[string][]cloudstack.LBStickinessPolicyStickinesspolicy{},
+		lbHealthCheckPolicies: map[string][]cloudstack.LBHealthCheckPolicyHealthcheckpolicy{},
+		guestIPs:              map[string]map[string]bool{},
+		ipv6Routers:           map[string]string{},
+		dualStackOfferings:    map[string]bool{},
+		userData:              map[string]string{},
+	}
+	s.seed()
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// APIUrl returns the URL CloudStack clients should use to reach the simulator.
+func (s *Simulator) APIUrl() string {
+	return s.URL + "/client/api"
+}
+
+// FailNext makes the next call of the named command fail with the passed error instead of being executed.
+// Asynchronous commands fail through their job result, as they do in CloudStack.
+func (s *Simulator) FailNext(cmd string, err *APIError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.injected[cmd] = append(s.injected[cmd], err)
+}
+
+// HoldJobs keeps the jobs of the named async command reported pending until ReleaseJobs is called, as those of
+// CloudStack are while they run. The commands still take effect right away. Clients waiting on held jobs block until
+// they're released or the clients give up.
+func (s *Simulator) HoldJobs(cmd string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.held[cmd] = true
+}
+
+// ReleaseJobs finishes the held jobs of the named async command, and stops holding its new ones.
+func (s *Simulator) ReleaseJobs(cmd string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.held, cmd)
+	for _, job := range s.jobs {
+		if job.cmd == cmd {
+			job.held = false
+		}
+	}
+}
+
+// RequestCount returns the number of times the named command has been called.
+func (s *Simulator) RequestCount(cmd string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requestCount[cmd]
+}
+
+// SetLatency makes the simulator take the passed time to answer each request, as a slow or hung management server
+// does. Requests whose clients give up in the meantime go unanswered.
+func (s *Simulator) SetLatency(latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = latency
+}
+
+// newID returns a new UUID formatted resource ID, unique within this simulator.
+func (s *Simulator) newID() string {
+	s.nextID++
+	return fmt.Sprintf("00000000-0000-4000-8000-%012d", s.nextID)
+}
+
+func now() string {
+	return time.Now().Format(timeFormat)
+}
+
+func (s *Simulator) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	params := r.Form
+	cmdName := params.Get("command")
+	responseKey := strings.ToLower(cmdName) + "response"
+
+	s.mu.Lock()
+	latency := s.latency
+	s.mu.Unlock()
+	select {
+	case <-time.After(latency):
+	case <-r.Context().Done():
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requestCount[cmdName]++
+
+	body, err := s.execute(cmdName, params)
+	if err != nil {
+		apiErr, ok := err.(*APIError)
+		if !ok {
+			apiErr = NewAPIError(ErrorCodeInternalError, err.Error())
+		}
+		writeJSON(w, apiErr.ErrorCode, map[string]interface{}{responseKey: apiErr})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{responseKey: body})
+}
+
+// execute authenticates and runs a single command, returning the response body.
+func (s *Simulator) execute(cmdName string, params url.Values) (interface{}, error) {
+	caller, err := s.authenticate(params)
+	if err != nil {
+		return nil, err
+	}
+	s.caller = caller
+
+	cmd, found := commands[cmdName]
+	if !found {
+		return nil, NewAPIError(ErrorCodeParamError, "The given command does not exist or it is not available for user: %s", cmdName)
+	}
+
+	var injected *APIError
+	if errs := s.injected[cmdName]; len(errs) > 0 {
+		injected, s.injected[cmdName] = errs[0], errs[1:]
+	}
+
+	if !cmd.async {
+		if injected != nil {
+			return nil, injected
+		}
+		return cmd.handler(s, params)
+	}
+
+	job := &asyncJob{id: s.newID(), cmd: cmdName, created: time.Now(), err: injected, held: s.held[cmdName]}
+	if injected == nil {
+		result, err := cmd.handler(s, params)
+		if failure, ok := err.(*jobFailure); ok {
+			job.err = failure.APIError
+		} else if err != nil {
+			return nil, err
+		}
+		job.result = result
+	}
+	s.jobs[job.id] = job
+	return map[string]string{"jobid": job.id, "id": resultID(job.result)}, nil
+}
+
+// resultID digs the ID of the affected resource out of an async job result, if there is one.
+func resultID(result interface{}) string {
+	b, err := json.Marshal(result)
+	if err != nil {
+		return ""
+	}
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return ""
+	}
+	for _, v := range m {
+		var entity struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(v, &entity); err == nil {
+			return entity.ID
+		}
+	}
+	return ""
+}
+
+func (s *Simulator) queryAsyncJobResult(p url.Values) (interface{}, error) {
+	job, found := s.jobs[p.Get("jobid")]
+	if !found {
+		return nil, paramError("Unable to find job by id %s", p.Get("jobid"))
+	}
+	resp := map[string]interface{}{
+		"jobid":         job.id,
+		"cmd":           job.cmd,
+		"created":       job.created.Format(timeFormat),
+		"completed":     job.created.Format(timeFormat),
+		"jobresulttype": "object",
+	}
+	if job.held {
+		delete(resp, "completed")
+		resp["jobstatus"] = 0
+	} else if job.err != nil {
+		resp["jobstatus"] = 2
+		resp["jobresultcode"] = job.err.ErrorCode
+		resp["jobresult"] = job.err
+	} else {
+		resp["jobstatus"] = 1
+		resp["jobresult"] = job.result
+	}
+	return resp, nil
+}
+
+// authenticate finds the user owning the request's API key and verifies the request signature the same way a
+// CloudStack management server does.
+func (s *Simulator) authenticate(params url.Values) (*cloudstack.User, error) {
+	unauthorized := NewAPIError(ErrorCodeUnauthorized, "unable to verify user credentials and/or request signature")
+	apiKey := params.Get("apiKey")
+	var user *cloudstack.User
+	for _, u := range s.users {
+		if u.Apikey != "" && u.Apikey == apiKey {
+			user = u
+			break
+		}
+	}
+	if user == nil {
+		return nil, unauthorized
+	}
+
+	signed := url.Values{}
+	for k, v := range params {
+		if k != "signature" {
+			signed[k] = v
+		}
+	}
+	mac := hmac.New(sha1.New, []byte(user.Secretkey))
+	mac.Write([]byte(strings.ReplaceAll(strings.ToLower(encodeValues(signed)), "+", "%20")))
+	if base64.StdEncoding.EncodeToString(mac.Sum(nil)) != params.Get("signature") {
+		return nil, unauthorized
+	}
+	return user, nil
+}
+
+// encodeValues serializes parameters sorted by key, URL encoding only the values, as CloudStack signs them.
+func encodeValues(v url.Values) string {
+	var buf bytes.Buffer
+	keys := make([]string, 0, len(v))
+	for k := range v {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, val := range v[k] {
+			if buf.Len() > 0 {
+				buf.WriteByte('&')
+			}
+			buf.WriteString(k + "=" + url.QueryEscape(val))
+		}
+	}
+	return buf.String()
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// successResponse is the body CloudStack returns for commands that don't return an entity.
+func successResponse() map[string]interface{} {
+	return map[string]interface{}{"success": true, "displaytext": ""}
+}
+
+// listResponse builds a list command response body with the list keyed by the passed entity name.
+func listResponse(key string, items interface{}, count int) map[string]interface{} {
+	if count == 0 {
+		return map[string]interface{}{"count": 0}
+	}
+	return map[string]interface{}{"count": count, key: items}
+}
+
+// listParam splits a comma separated list parameter.
+func listParam(p url.Values, key string) []string {
+	if p.Get(key) == "" {
+		return nil
+	}
+	return strings.Split(p.Get(key), ",")
+}
+
+// mapParam parses map parameters of the form key[i].name=value into a slice of maps ordered by index.
+func mapParam(p url.Values, key string) []map[string]string {
+	var ret []map[string]string
+	for k, v := range p {
+		if !strings.HasPrefix(k, key+"[") {
+			continue
+		}
+		var idx int
+		var field string
+		if _, err := fmt.Sscanf(strings.TrimPrefix(k, key), "[%d].%s", &idx, &field); err != nil {
+			continue
+		}
+		for len(ret) <= idx {
+			ret = append(ret, map[string]string{})
+		}
+		ret[idx][field] = v[0]
+	}
+	return ret
+}
+
+// keyValueParam parses map parameters of the form key[i].key=k&key[i].value=v into a map.
+func keyValueParam(p url.Values, key string) map[string]string {
+	ret := map[string]string{}
+	for _, m := range mapParam(p, key) {
+		ret[m["key"]] = m["value"]
+	}
+	return ret
+}
+
+// matches reports whether a list filter parameter is unset or equal to the passed value.
+func matches(p url.Values, key, value string) bool {
+	return p.Get(key) == "" || p.Get(key) == value
+}
+
+// matchesName applies the name and keyword filters of list commands.
+func matchesName(p url.Values, name string) bool {
+	keyword := strings.ToLower(p.Get("keyword"))
+	return matches(p, "name", name) && (keyword == "" || strings.Contains(strings.ToLower(name), keyword))
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package simulator_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/pkg/cloud"
+	dummies "sigs.k8s.io/cluster-api-provider-cloudstack/test/dummies/v1beta2"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/test/simulator"
+)
+
+var _ = Describe("Simulator", func() {
+	var ( // Declare shared vars.
+		sim    *simulator.Simulator
+		client cloud.Client
+		ctx    = context.Background()
+	)
+
+	BeforeEach(func() {
+		dummies.SetDummyVars()
+		sim = simulator.New()
+		dummies.SetDummySimulatorVars(sim)
+
+		var err error
+		client, err = cloud.NewClientFromConf(dummies.SimulatorConf, nil)
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		sim.Close()
+	})
+
+	Context("Authentication", func() {
+		It("rejects requests signed with unknown credentials", func() {
+			conf := dummies.SimulatorConf
+			conf.SecretKey = "not-the-secret-key"
+			badClient, err := cloud.NewClientFromConf(conf, nil)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			err = badClient.ResolveZone(ctx, &dummies.CSFailureDomain1.Spec.Zone)
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(ContainSubstring("unable to verify user credentials"))
+		})
+
+		It("creates clients for users in sub-domains and accounts", func() {
+			domain := sim.AddDomain(sim.RootDomain().Id, "sub-domain")
+			account := sim.AddAccount(domain.Id, "sub-account")
+			sim.AddUser(account.Id, "sub-user", "sub-api-key", "sub-secret-key")
+
+			subClient, err := client.NewClientInDomainAndAccount(ctx, "ROOT/sub-domain", "sub-account")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(subClient.ResolveZone(ctx, &dummies.CSFailureDomain1.Spec.Zone)).Should(Succeed())
+		})
+	})
+
+	Context("Fault injection", func() {
+		It("surfaces injected API errors", func() {
+			sim.FailNext("deployVirtualMachine", simulator.NewAPIError(
+				simulator.ErrorCodeInsufficientCapacity, "Unable to create a deployment for VM"))
+
+			err := client.GetOrCreateVMInstance(ctx, dummies.CSMachine1, dummies.CAPIMachine, dummies.CSCluster,
+				dummies.CSFailureDomain1, dummies.CSAffinityGroup, "userdata")
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(ContainSubstring("Unable to create a deployment for VM"))
+			Ω(sim.VirtualMachines()).Should(BeEmpty())
+			Ω(sim.RequestCount("deployVirtualMachine")).Should(Equal(1))
+		})
+	})
+})
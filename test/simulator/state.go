@@ -0,0 +1,267 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package simulator
+
+import (
+	"strconv"
+
+	"github.com/apache/cloudstack-go/v2/cloudstack"
+)
+
+// The accessors below return copies of the simulator's state so tests can assert on it without racing the server.
+
+// VirtualMachines returns all VMs that haven't been expunged.
+func (s *Simulator) VirtualMachines() []cloudstack.VirtualMachine {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ret := make([]cloudstack.VirtualMachine, 0, len(s.virtualMachines))
+	for _, vm := range s.virtualMachines {
+		ret = append(ret, *vm)
+	}
+	return ret
+}
+
+// UserData returns the (encoded) user data a VM was deployed with.
+func (s *Simulator) UserData(vmID string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.userData[vmID]
+}
+
+// SetVirtualMachineState changes a VM's state as if something outside of CAPC had acted on it.
+func (s *Simulator) SetVirtualMachineState(vmID, state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if vm := s.findVirtualMachine(vmID); vm != nil {
+		vm.State = state
+	}
+}
+
+// SetZoneAllocationState enables or disables a zone for allocation, as a CloudStack administrator would.
+func (s *Simulator) SetZoneAllocationState(zoneID, state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, zone := range s.zones {
+		if zone.Id == zoneID {
+			zone.Allocationstate = state
+		}
+	}
+}
+
+// Accounts returns all accounts, starting with the admin account.
+func (s *Simulator) Accounts() []cloudstack.Account {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ret := make([]cloudstack.Account, 0, len(s.accounts))
+	for _, account := range s.accounts {
+		ret = append(ret, *account)
+	}
+	return ret
+}
+
+// SetAccountVMsAvailable sets how many more VMs an account may deploy within its resource limits. The account's
+// deployments fail once none are available. Deployments don't count down what's available.
+func (s *Simulator) SetAccountVMsAvailable(accountID string, available int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, account := range s.accounts {
+		if account.Id == accountID {
+			account.Vmavailable = strconv.Itoa(available)
+		}
+	}
+}
+
+// Volumes returns all volumes.
+func (s *Simulator) Volumes() []cloudstack.Volume {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ret := make([]cloudstack.Volume, 0, len(s.volumes))
+	for _, vol := range s.volumes {
+		ret = append(ret, *vol)
+	}
+	return ret
+}
+
+// Snapshots returns all volume snapshots.
+func (s *Simulator) Snapshots() []cloudstack.Snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ret := make([]cloudstack.Snapshot, 0, len(s.snapshots))
+	for _, snapshot := range s.snapshots {
+		ret = append(ret, *snapshot)
+	}
+	return ret
+}
+
+// Networks returns all guest networks.
+func (s *Simulator) Networks() []cloudstack.Network {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ret := make([]cloudstack.Network, 0, len(s.networks))
+	for _, n := range s.networks {
+		ret = append(ret, *n)
+	}
+	return ret
+}
+
+// PublicIPAddresses returns all public IP addresses, allocated or not.
+func (s *Simulator) PublicIPAddresses() []cloudstack.PublicIpAddress {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ret := make([]cloudstack.PublicIpAddress, 0, len(s.publicIPs))
+	for _, ip := range s.publicIPs {
+		ret = append(ret, *ip)
+	}
+	return ret
+}
+
+// LoadBalancerRules returns all load balancer rules.
+func (s *Simulator) LoadBalancerRules() []cloudstack.LoadBalancerRule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ret := make([]cloudstack.LoadBalancerRule, 0, len(s.lbRules))
+	for _, rule := range s.lbRules {
+		ret = append(ret, *rule)
+	}
+	return ret
+}
+
+// LoadBalancerRuleMembers returns the IDs of the VMs assigned to a load balancer rule.
+func (s *Simulator) LoadBalancerRuleMembers(ruleID string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.lbRuleMembers[ruleID]...)
+}
+
+// LoadBalancerStickinessPolicies returns the stickiness policies of a load balancer rule.
+func (s *Simulator) LoadBalancerStickinessPolicies(ruleID string) []cloudstack.LBStickinessPolicyStickinesspolicy {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]cloudstack.LBStickinessPolicyStickinesspolicy{}, s.lbStickinessPolicies[ruleID]...)
+}
+
+// LoadBalancerHealthCheckPolicies returns the health check policies of a load balancer rule.
+func (s *Simulator) LoadBalancerHealthCheckPolicies(ruleID string) []cloudstack.LBHealthCheckPolicyHealthcheckpolicy {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]cloudstack.LBHealthCheckPolicyHealthcheckpolicy{}, s.lbHealthCheckPolicies[ruleID]...)
+}
+
+// FirewallRules returns all ingress firewall rules.
+func (s *Simulator) FirewallRules() []cloudstack.FirewallRule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ret := make([]cloudstack.FirewallRule, 0, len(s.firewallRules))
+	for _, rule := range s.firewallRules {
+		ret = append(ret, *rule)
+	}
+	return ret
+}
+
+// EgressFirewallRules returns all egress firewall rules.
+func (s *Simulator) EgressFirewallRules() []cloudstack.EgressFirewallRule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ret := make([]cloudstack.EgressFirewallRule, 0, len(s.egressRules))
+	for _, rule := range s.egressRules {
+		ret = append(ret, *rule)
+	}
+	return ret
+}
+
+// AffinityGroups returns all affinity groups.
+func (s *Simulator) AffinityGroups() []cloudstack.AffinityGroup {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ret := make([]cloudstack.AffinityGroup, 0, len(s.affinityGroups))
+	for _, group := range s.affinityGroups {
+		ret = append(ret, *group)
+	}
+	return ret
+}
+
+// InstanceGroups returns all instance groups.
+func (s *Simulator) InstanceGroups() []cloudstack.InstanceGroup {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ret := make([]cloudstack.InstanceGroup, 0, len(s.instanceGroups))
+	for _, group := range s.instanceGroups {
+		ret = append(ret, *group)
+	}
+	return ret
+}
+
+// IPv6FirewallRules returns the IPv6 firewall rules of a network.
+func (s *Simulator) IPv6FirewallRules(networkID string) []Ipv6FirewallRule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ret := []Ipv6FirewallRule{}
+	for _, rule := range s.ipv6FirewallRules {
+		if rule.Networkid == networkID {
+			ret = append(ret, *rule)
+		}
+	}
+	return ret
+}
+
+// VPCs returns all VPCs.
+func (s *Simulator) VPCs() []cloudstack.VPC {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ret := make([]cloudstack.VPC, 0, len(s.vpcs))
+	for _, vpc := range s.vpcs {
+		ret = append(ret, *vpc)
+	}
+	return ret
+}
+
+// NetworkACLLists returns all network ACL lists, including the default ones.
+func (s *Simulator) NetworkACLLists() []cloudstack.NetworkACLList {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ret := make([]cloudstack.NetworkACLList, 0, len(s.networkACLLists))
+	for _, list := range s.networkACLLists {
+		ret = append(ret, *list)
+	}
+	return ret
+}
+
+// NetworkACLs returns the items of a network ACL list.
+func (s *Simulator) NetworkACLs(aclID string) []cloudstack.NetworkACL {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ret := []cloudstack.NetworkACL{}
+	for _, item := range s.networkACLs {
+		if item.Aclid == aclID {
+			ret = append(ret, *item)
+		}
+	}
+	return ret
+}
+
+// Tags returns the tags on a resource as a map of key to value.
+func (s *Simulator) Tags(resourceID string) map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ret := map[string]string{}
+	for _, tag := range s.tags {
+		if tag.Resourceid == resourceID {
+			ret[tag.Key] = tag.Value
+		}
+	}
+	return ret
+}
@@ -0,0 +1,398 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package simulator
+
+import (
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/apache/cloudstack-go/v2/cloudstack"
+)
+
+var (
+	aclProtocols    = map[string]bool{"tcp": true, "udp": true, "icmp": true, "all": true}
+	aclActions      = map[string]string{"allow": "Allow", "deny": "Deny"}
+	aclTrafficTypes = map[string]string{"ingress": "Ingress", "egress": "Egress"}
+)
+
+func init() {
+	registerCommand("listVPCOfferings", false, (*Simulator).listVPCOfferings)
+	registerCommand("listVPCs", false, (*Simulator).listVPCs)
+	registerCommand("createVPC", true, (*Simulator).createVPC)
+	registerCommand("deleteVPC", true, (*Simulator).deleteVPC)
+	registerCommand("listNetworkACLLists", false, (*Simulator).listNetworkACLLists)
+	registerCommand("createNetworkACLList", true, (*Simulator).createNetworkACLList)
+	registerCommand("deleteNetworkACLList", true, (*Simulator).deleteNetworkACLList)
+	registerCommand("replaceNetworkACLList", true, (*Simulator).replaceNetworkACLList)
+	registerCommand("listNetworkACLs", false, (*Simulator).listNetworkACLs)
+	registerCommand("createNetworkACL", true, (*Simulator).createNetworkACL)
+	registerCommand("deleteNetworkACL", true, (*Simulator).deleteNetworkACL)
+}
+
+func (s *Simulator) findVPC(id string) *cloudstack.VPC {
+	for _, vpc := range s.vpcs {
+		if vpc.Id == id {
+			return vpc
+		}
+	}
+	return nil
+}
+
+func (s *Simulator) findNetworkACLList(id string) *cloudstack.NetworkACLList {
+	for _, list := range s.networkACLLists {
+		if list.Id == id {
+			return list
+		}
+	}
+	return nil
+}
+
+func (s *Simulator) listVPCOfferings(p url.Values) (interface{}, error) {
+	ret := []*cloudstack.VPCOffering{}
+	for _, offering := range s.vpcOfferings {
+		if matches(p, "id", offering.Id) && matchesName(p, offering.Name) {
+			ret = append(ret, offering)
+		}
+	}
+	return listResponse("vpcoffering", ret, len(ret)), nil
+}
+
+func (s *Simulator) listVPCs(p url.Values) (interface{}, error) {
+	ret := []*cloudstack.VPC{}
+	for _, vpc := range s.vpcs {
+		if matches(p, "id", vpc.Id) && matchesName(p, vpc.Name) && matches(p, "zoneid", vpc.Zoneid) {
+			vpc.Tags = s.resourceTags("Vpc", vpc.Id)
+			ret = append(ret, vpc)
+		}
+	}
+	return listResponse("vpc", ret, len(ret)), nil
+}
+
+// newVPC creates a VPC and acquires the source NAT address of its router.
+func (s *Simulator) newVPC(zone *cloudstack.Zone, offering *cloudstack.VPCOffering, name, cidr string) *cloudstack.VPC {
+	vpc := &cloudstack.VPC{
+		Id:              s.newID(),
+		Name:            name,
+		Displaytext:     name,
+		Cidr:            cidr,
+		Zoneid:          zone.Id,
+		Zonename:        zone.Name,
+		Vpcofferingid:   offering.Id,
+		Vpcofferingname: offering.Name,
+		Networkdomain:   "cs" + strconv.Itoa(s.nextID) + "cloud.internal",
+		State:           "Enabled",
+		Created:         now(),
+	}
+	if s.caller != nil {
+		vpc.Account, vpc.Domain, vpc.Domainid = s.caller.Account, s.caller.Domain, s.caller.Domainid
+	}
+	s.vpcs = append(s.vpcs, vpc)
+	for _, ip := range s.publicIPs {
+		if ip.Zoneid == zone.Id && ip.State == "Free" {
+			s.allocateVPCPublicIP(ip, vpc)
+			ip.Issourcenat = true
+			break
+		}
+	}
+	return vpc
+}
+
+func (s *Simulator) createVPC(p url.Values) (interface{}, error) {
+	var zone *cloudstack.Zone
+	for _, z := range s.zones {
+		if z.Id == p.Get("zoneid") {
+			zone = z
+		}
+	}
+	if zone == nil {
+		return nil, notFound("zoneid", p.Get("zoneid"))
+	}
+	var offering *cloudstack.VPCOffering
+	for _, o := range s.vpcOfferings {
+		if o.Id == p.Get("vpcofferingid") {
+			offering = o
+		}
+	}
+	if offering == nil {
+		return nil, notFound("vpcofferingid", p.Get("vpcofferingid"))
+	}
+	if _, _, err := net.ParseCIDR(p.Get("cidr")); err != nil {
+		return nil, paramError("Invalid CIDR specified %s", p.Get("cidr"))
+	}
+	vpc := s.newVPC(zone, offering, p.Get("name"), p.Get("cidr"))
+	if displayText := p.Get("displaytext"); displayText != "" {
+		vpc.Displaytext = displayText
+	}
+	return map[string]interface{}{"vpc": vpc}, nil
+}
+
+func (s *Simulator) deleteVPC(p url.Values) (interface{}, error) {
+	vpc := s.findVPC(p.Get("id"))
+	if vpc == nil {
+		return nil, notFound("id", p.Get("id"))
+	}
+	for _, n := range s.networks {
+		if n.Vpcid == vpc.Id {
+			return nil, paramError("VPC id=%s has active networks and can't be deleted", vpc.Id)
+		}
+	}
+	for _, ip := range s.publicIPs {
+		if ip.Vpcid == vpc.Id {
+			s.releasePublicIP(ip)
+		}
+	}
+	lists := s.networkACLLists[:0]
+	for _, list := range s.networkACLLists {
+		if list.Vpcid == vpc.Id {
+			s.forgetNetworkACLList(list.Id)
+			continue
+		}
+		lists = append(lists, list)
+	}
+	s.networkACLLists = lists
+	vpcs := s.vpcs[:0]
+	for _, other := range s.vpcs {
+		if other.Id != vpc.Id {
+			vpcs = append(vpcs, other)
+		}
+	}
+	s.vpcs = vpcs
+	s.deleteResourceTags(vpc.Id)
+	return successResponse(), nil
+}
+
+// allocateVPCPublicIP acquires an address for a VPC. It's associated with a tier once a rule is put on it.
+func (s *Simulator) allocateVPCPublicIP(ip *cloudstack.PublicIpAddress, vpc *cloudstack.VPC) {
+	ip.State = "Allocated"
+	ip.Allocated = now()
+	ip.Vpcid = vpc.Id
+	if s.caller != nil {
+		ip.Account, ip.Domain, ip.Domainid = s.caller.Account, s.caller.Domain, s.caller.Domainid
+	}
+}
+
+// validateVPCTier checks the parameters of a network being created as a tier of a VPC.
+func (s *Simulator) validateVPCTier(p url.Values, offering *cloudstack.NetworkOffering) (*cloudstack.VPC, error) {
+	vpc := s.findVPC(p.Get("vpcid"))
+	if vpc == nil {
+		return nil, notFound("vpcid", p.Get("vpcid"))
+	} else if !offering.Forvpc {
+		return nil, paramError("Network offering id=%s can't be used for VPC networks", offering.Id)
+	} else if p.Get("gateway") == "" || p.Get("netmask") == "" {
+		return nil, paramError("Gateway and netmask are required when creating a network in a VPC")
+	}
+	_, vpcNet, _ := net.ParseCIDR(vpc.Cidr)
+	if gateway := net.ParseIP(p.Get("gateway")); gateway == nil || !vpcNet.Contains(gateway) {
+		return nil, paramError("Network gateway %s is not within the VPC CIDR %s", p.Get("gateway"), vpc.Cidr)
+	}
+	if aclID := p.Get("aclid"); aclID != "" {
+		if list := s.findNetworkACLList(aclID); list == nil || (list.Vpcid != "" && list.Vpcid != vpc.Id) {
+			return nil, paramError("Unable to find network ACL list id=%s in VPC id=%s", aclID, vpc.Id)
+		}
+	}
+	return vpc, nil
+}
+
+func (s *Simulator) listNetworkACLLists(p url.Values) (interface{}, error) {
+	networkACLID := ""
+	if networkID := p.Get("networkid"); networkID != "" {
+		n := s.findNetwork(networkID)
+		if n == nil {
+			return nil, notFound("networkid", networkID)
+		}
+		networkACLID = n.Aclid
+	}
+	ret := []*cloudstack.NetworkACLList{}
+	for _, list := range s.networkACLLists {
+		if matches(p, "id", list.Id) && matchesName(p, list.Name) && matches(p, "vpcid", list.Vpcid) &&
+			(networkACLID == "" || list.Id == networkACLID) {
+			ret = append(ret, list)
+		}
+	}
+	return listResponse("networkacllist", ret, len(ret)), nil
+}
+
+func (s *Simulator) createNetworkACLList(p url.Values) (interface{}, error) {
+	vpc := s.findVPC(p.Get("vpcid"))
+	if vpc == nil {
+		return nil, notFound("vpcid", p.Get("vpcid"))
+	}
+	for _, list := range s.networkACLLists {
+		if list.Vpcid == vpc.Id && list.Name == p.Get("name") {
+			return nil, paramError("Network ACL list with name %s already exists in VPC id=%s", list.Name, vpc.Id)
+		}
+	}
+	list := &cloudstack.NetworkACLList{Id: s.newID(), Name: p.Get("name"), Description: p.Get("description"),
+		Vpcid: vpc.Id, Fordisplay: true}
+	s.networkACLLists = append(s.networkACLLists, list)
+	return map[string]interface{}{"networkacllist": list}, nil
+}
+
+func (s *Simulator) deleteNetworkACLList(p url.Values) (interface{}, error) {
+	list := s.findNetworkACLList(p.Get("id"))
+	if list == nil {
+		return nil, notFound("id", p.Get("id"))
+	} else if list.Vpcid == "" {
+		return nil, paramError("Default network ACL list id=%s can't be removed", list.Id)
+	}
+	for _, n := range s.networks {
+		if n.Aclid == list.Id {
+			return nil, paramError("Network ACL list id=%s is still in use by network id=%s", list.Id, n.Id)
+		}
+	}
+	lists := s.networkACLLists[:0]
+	for _, other := range s.networkACLLists {
+		if other.Id != list.Id {
+			lists = append(lists, other)
+		}
+	}
+	s.networkACLLists = lists
+	s.forgetNetworkACLList(list.Id)
+	return successResponse(), nil
+}
+
+// forgetNetworkACLList drops the items of a removed network ACL list.
+func (s *Simulator) forgetNetworkACLList(id string) {
+	items := s.networkACLs[:0]
+	for _, item := range s.networkACLs {
+		if item.Aclid != id {
+			items = append(items, item)
+		}
+	}
+	s.networkACLs = items
+}
+
+func (s *Simulator) replaceNetworkACLList(p url.Values) (interface{}, error) {
+	list := s.findNetworkACLList(p.Get("aclid"))
+	if list == nil {
+		return nil, notFound("aclid", p.Get("aclid"))
+	}
+	n := s.findNetwork(p.Get("networkid"))
+	if n == nil {
+		return nil, notFound("networkid", p.Get("networkid"))
+	} else if n.Vpcid == "" {
+		return nil, paramError("Network id=%s isn't part of a VPC", n.Id)
+	} else if list.Vpcid != "" && list.Vpcid != n.Vpcid {
+		return nil, paramError("Network ACL list id=%s and network id=%s belong to different VPCs", list.Id, n.Id)
+	}
+	n.Aclid, n.Aclname = list.Id, list.Name
+	return successResponse(), nil
+}
+
+func (s *Simulator) listNetworkACLs(p url.Values) (interface{}, error) {
+	aclID := p.Get("aclid")
+	if networkID := p.Get("networkid"); networkID != "" {
+		n := s.findNetwork(networkID)
+		if n == nil {
+			return nil, notFound("networkid", networkID)
+		}
+		aclID = n.Aclid
+	}
+	ret := []*cloudstack.NetworkACL{}
+	for _, item := range s.networkACLs {
+		if matches(p, "id", item.Id) && (aclID == "" || item.Aclid == aclID) &&
+			matches(p, "traffictype", item.Traffictype) && matches(p, "protocol", item.Protocol) {
+			ret = append(ret, item)
+		}
+	}
+	return listResponse("networkacl", ret, len(ret)), nil
+}
+
+func (s *Simulator) createNetworkACL(p url.Values) (interface{}, error) {
+	aclID := p.Get("aclid")
+	if aclID == "" {
+		n := s.findNetwork(p.Get("networkid"))
+		if n == nil {
+			return nil, paramError("Either aclid or networkid is required")
+		}
+		aclID = n.Aclid
+	}
+	list := s.findNetworkACLList(aclID)
+	if list == nil {
+		return nil, notFound("aclid", aclID)
+	} else if list.Vpcid == "" {
+		return nil, paramError("Default network ACL list id=%s can't be modified", list.Id)
+	}
+
+	protocol := strings.ToLower(p.Get("protocol"))
+	if !aclProtocols[protocol] {
+		return nil, paramError("Invalid protocol %s", p.Get("protocol"))
+	}
+	action, trafficType := "Allow", "Ingress"
+	if p.Get("action") != "" {
+		if action = aclActions[strings.ToLower(p.Get("action"))]; action == "" {
+			return nil, paramError("Invalid action %s. Valid values are Allow and Deny", p.Get("action"))
+		}
+	}
+	if p.Get("traffictype") != "" {
+		if trafficType = aclTrafficTypes[strings.ToLower(p.Get("traffictype"))]; trafficType == "" {
+			return nil, paramError("Invalid traffic type %s. Valid values are Ingress and Egress", p.Get("traffictype"))
+		}
+	}
+	cidrList := p.Get("cidrlist")
+	if cidrList == "" {
+		cidrList = "0.0.0.0/0"
+	}
+	number, err := intParam(p, "number", 0)
+	if err != nil {
+		return nil, err
+	}
+	highest := 0
+	for _, item := range s.networkACLs {
+		if item.Aclid != list.Id {
+			continue
+		} else if item.Number == number {
+			return nil, paramError("ACL item with number %d already exists in ACL id=%s", number, list.Id)
+		} else if item.Number > highest {
+			highest = item.Number
+		}
+	}
+	if number == 0 {
+		number = highest + 1
+	}
+
+	item := &cloudstack.NetworkACL{Id: s.newID(), Aclid: list.Id, Aclname: list.Name, Action: action,
+		Cidrlist: cidrList, Number: number, Protocol: protocol, Traffictype: trafficType, Reason: p.Get("reason"),
+		State: "Active", Fordisplay: true}
+	if protocol == "tcp" || protocol == "udp" {
+		start, end := parsePortRange(p)
+		if start != 0 {
+			item.Startport, item.Endport = strconv.Itoa(start), strconv.Itoa(end)
+		}
+	}
+	s.networkACLs = append(s.networkACLs, item)
+	return map[string]interface{}{"networkacl": item}, nil
+}
+
+func (s *Simulator) deleteNetworkACL(p url.Values) (interface{}, error) {
+	items := s.networkACLs[:0]
+	found := false
+	for _, item := range s.networkACLs {
+		if item.Id == p.Get("id") {
+			found = true
+			continue
+		}
+		items = append(items, item)
+	}
+	if !found {
+		return nil, notFound("id", p.Get("id"))
+	}
+	s.networkACLs = items
+	return successResponse(), nil
+}